@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/alecthomas/kong"
+
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+// Backend is embedded by commands that connect to the selected backend,
+// which is configured using the same flags the start command does.
+type Backend struct {
+	registry.Selector `embed:""`
+}
+
+func (b *Backend) AfterApply(kctx *kong.Context) error {
+	d, args, err := b.Selected()
+	if err != nil {
+		return err
+	}
+	kctx.Bind(pkgcmd.NewBackendConnector(d, args))
+	return nil
+}
+
+type InspectCmd struct {
+	Backend           `embed:""`
+	pkgcmd.InspectCmd `embed:""`
+}
+
+type DrainCmd struct {
+	Backend         `embed:""`
+	pkgcmd.DrainCmd `embed:""`
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+	"github.com/triggermesh/brokers/pkg/broker"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+// StartCmd starts the broker using any of the registered backends.
+type StartCmd struct {
+	registry.Selector `embed:""`
+}
+
+func (c *StartCmd) Run(globals *pkgcmd.Globals) error {
+	globals.Logger.Debugf("Creating %s backend client", c.Backend)
+	backend, factory, err := c.Backends(registry.Options{
+		BrokerName: globals.BrokerName,
+		Logger:     globals.Logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := broker.NewInstance(globals, backend, broker.InstanceWithBackendFactory(factory))
+	if err != nil {
+		return err
+	}
+
+	return b.Start(globals.Context)
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/google/uuid"
+
+	"github.com/triggermesh/brokers/cmd/broker/cmd"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+
+	// Backends available for selection.
+	_ "github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	_ "github.com/triggermesh/brokers/pkg/backend/impl/redis"
+)
+
+type cli struct {
+	pkgcmd.Globals
+
+	Start cmd.StartCmd `cmd:"" help:"Starts the TriggerMesh broker."`
+
+	// Named apart from the Validate method that Globals exposes to kong.
+	ValidateConfig pkgcmd.ValidateCmd `cmd:"" name:"validate" help:"Validates the broker configuration without starting the broker."`
+
+	Inspect cmd.InspectCmd `cmd:"" help:"Inspects the triggers state at the backend."`
+	Drain   cmd.DrainCmd   `cmd:"" help:"Discards the events pending to be dispatched to a trigger."`
+}
+
+func main() {
+	cli := cli{
+		Globals: pkgcmd.Globals{
+			Context: context.Background(),
+		},
+		Start: cmd.StartCmd{
+			Selector: *registry.NewSelector(),
+		},
+		Inspect: cmd.InspectCmd{
+			Backend: cmd.Backend{Selector: *registry.NewSelector()},
+		},
+		Drain: cmd.DrainCmd{
+			Backend: cmd.Backend{Selector: *registry.NewSelector()},
+		},
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		panic(fmt.Errorf("error retrieving the host name: %w", err))
+	}
+
+	kc := kong.Parse(&cli,
+		kong.Vars{
+			"hostname":  hostname,
+			"unique_id": uuid.New().String(),
+		},
+		cli.Start.Vars("memory"))
+
+	err = cli.Initialize()
+	if err != nil {
+		panic(fmt.Errorf("error initializing: %w", err))
+	}
+	defer cli.Flush()
+
+	err = kc.Run(&cli.Globals)
+	kc.FatalIfErrorf(err)
+}
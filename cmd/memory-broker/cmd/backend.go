@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/alecthomas/kong"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+// Backend is embedded by commands that connect to the memory backend, which is
+// configured using the same flags the start command does.
+type Backend struct {
+	Memory memory.MemoryArgs `embed:"" prefix:"memory." envprefix:"MEMORY_"`
+}
+
+func (b *Backend) Validate() error {
+	return b.Memory.Validate()
+}
+
+func (b *Backend) AfterApply(kctx *kong.Context) error {
+	kctx.Bind(pkgcmd.NewBackendConnector(memory.Definition, &b.Memory))
+	return nil
+}
+
+type InspectCmd struct {
+	Backend           `embed:""`
+	pkgcmd.InspectCmd `embed:""`
+}
+
+type DrainCmd struct {
+	Backend         `embed:""`
+	pkgcmd.DrainCmd `embed:""`
+}
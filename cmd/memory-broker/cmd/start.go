@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
 	"github.com/triggermesh/brokers/pkg/broker"
 	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
 )
@@ -19,9 +20,12 @@ func (s *StartCmd) Validate() error {
 
 func (c *StartCmd) Run(globals *pkgcmd.Globals) error {
 	globals.Logger.Debug("Creating memory backend client")
-	backend := memory.New(&c.Memory, globals.Logger.Named("memory"))
+	backend, factory := memory.Definition.Backends(&c.Memory, registry.Options{
+		BrokerName: globals.BrokerName,
+		Logger:     globals.Logger,
+	})
 
-	b, err := broker.NewInstance(globals, backend)
+	b, err := broker.NewInstance(globals, backend, broker.InstanceWithBackendFactory(factory))
 	if err != nil {
 		return err
 	}
@@ -19,6 +19,12 @@ type cli struct {
 	pkgcmd.Globals
 
 	Start cmd.StartCmd `cmd:"" help:"Starts the TriggerMesh broker."`
+
+	// Named apart from the Validate method that Globals exposes to kong.
+	ValidateConfig pkgcmd.ValidateCmd `cmd:"" name:"validate" help:"Validates the broker configuration without starting the broker."`
+
+	Inspect cmd.InspectCmd `cmd:"" help:"Inspects the triggers state at the backend."`
+	Drain   cmd.DrainCmd   `cmd:"" help:"Discards the events pending to be dispatched to a trigger."`
 }
 
 func main() {
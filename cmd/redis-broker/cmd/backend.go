@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"github.com/alecthomas/kong"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/redis"
+	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
+)
+
+// Backend is embedded by commands that connect to the Redis backend, which is
+// configured using the same flags the start command does.
+type Backend struct {
+	Redis redis.RedisArgs `embed:"" prefix:"redis." envprefix:"REDIS_"`
+}
+
+func (b *Backend) Validate() error {
+	return b.Redis.Validate()
+}
+
+func (b *Backend) AfterApply(kctx *kong.Context) error {
+	kctx.Bind(pkgcmd.NewBackendConnector(redis.Definition, &b.Redis))
+	return nil
+}
+
+type InspectCmd struct {
+	Backend           `embed:""`
+	pkgcmd.InspectCmd `embed:""`
+}
+
+type DrainCmd struct {
+	Backend         `embed:""`
+	pkgcmd.DrainCmd `embed:""`
+}
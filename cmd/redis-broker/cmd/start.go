@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"github.com/triggermesh/brokers/pkg/backend/impl/redis"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
 	"github.com/triggermesh/brokers/pkg/broker"
 	pkgcmd "github.com/triggermesh/brokers/pkg/broker/cmd"
 )
@@ -19,13 +20,12 @@ func (s *StartCmd) Validate() error {
 
 func (c *StartCmd) Run(globals *pkgcmd.Globals) error {
 	globals.Logger.Debug("Creating Redis backend client")
+	backend, factory := redis.Definition.Backends(&c.Redis, registry.Options{
+		BrokerName: globals.BrokerName,
+		Logger:     globals.Logger,
+	})
 
-	// Use InstanceName as Redis instance at the consumer group.
-	// TODO add namespace to instance name when running at kubernetes
-	c.Redis.Instance = globals.BrokerName
-	backend := redis.New(&c.Redis, globals.Logger.Named("redis"))
-
-	b, err := broker.NewInstance(globals, backend)
+	b, err := broker.NewInstance(globals, backend, broker.InstanceWithBackendFactory(factory))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,449 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+	"github.com/triggermesh/brokers/pkg/firehose"
+	"github.com/triggermesh/brokers/pkg/stream"
+	"github.com/triggermesh/brokers/pkg/tap"
+	"github.com/triggermesh/brokers/pkg/topology"
+)
+
+const (
+	triggersPath        = "/v1/triggers"
+	deletedTriggersPath = "/v1/deletedtriggers"
+	topologyPath        = "/v1/topology"
+
+	// Maximum size for request bodies.
+	maxBodySize = 1 << 20
+)
+
+// ConfigCallback is called with the resulting configuration after
+// it has been modified through the admin API.
+type ConfigCallback func(*cfgbroker.Config)
+
+// Server exposes an authenticated HTTP API to manage the broker at runtime.
+type Server struct {
+	port  int
+	token string
+
+	store     store.ConfigStore
+	callbacks []ConfigCallback
+
+	// Last known broker configuration.
+	config *cfgbroker.Config
+
+	// Producer for test events, disabled if nil.
+	producer backend.EventProducer
+	// Generator for the ID of test events that do not inform it.
+	idGenerator eventid.Generator
+
+	// Serve the web UI.
+	ui bool
+
+	// Hub for streaming dispatch decisions, disabled if nil.
+	firehose *firehose.Hub
+
+	// Hub for tapping ingested events, disabled if nil.
+	tap *tap.Hub
+
+	// Hub of the streams of triggers consumers subscribe to, disabled if
+	// nil.
+	streams *stream.Hub
+
+	// Serve the CloudEvents Subscription API, and the delivery options of
+	// the triggers created for subscriptions.
+	subscriptions         bool
+	subscriptionsDelivery *cfgbroker.DeliveryOptions
+
+	// Time deleted triggers can be restored, zero if triggers are deleted
+	// right away.
+	deletionGracePeriod time.Duration
+	now                 func() time.Time
+
+	// Validator for modified configurations besides their schema, if any.
+	validator cfgbroker.Validator
+
+	mux    *http.ServeMux
+	m      sync.Mutex
+	logger *zap.SugaredLogger
+}
+
+type ServerOption func(*Server)
+
+// New creates an admin server that persists configuration changes
+// using the informed store.
+func New(s store.ConfigStore, logger *zap.SugaredLogger, opts ...ServerOption) *Server {
+	srv := &Server{
+		port:        9090,
+		store:       s,
+		config:      &cfgbroker.Config{},
+		idGenerator: eventid.Default(),
+		mux:         http.NewServeMux(),
+		now:         time.Now,
+		logger:      logger,
+	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	srv.mux.HandleFunc(triggersPath, srv.handleTriggers)
+	srv.mux.HandleFunc(triggersPath+"/", srv.handleTrigger)
+	srv.mux.HandleFunc(deadLettersPath, srv.handleDeadLetters)
+	srv.mux.Handle(topologyPath, topology.Handler(srv.currentConfig))
+	if srv.deletionGracePeriod > 0 {
+		srv.mux.HandleFunc(deletedTriggersPath, srv.handleDeletedTriggers)
+		srv.mux.HandleFunc(deletedTriggersPath+"/", srv.handleDeletedTrigger)
+	}
+	if srv.producer != nil {
+		srv.mux.HandleFunc(eventsPath, srv.handleEvents)
+	}
+	if srv.firehose != nil {
+		srv.mux.HandleFunc(firehosePath, srv.handleFirehose)
+	}
+	if srv.streams != nil {
+		srv.mux.HandleFunc(subscribePath, srv.handleSubscribe)
+	}
+	if srv.tap != nil {
+		srv.mux.HandleFunc(tapPath, srv.handleTap)
+	}
+	if srv.subscriptions {
+		srv.mux.HandleFunc(subscriptionsPath, srv.handleSubscriptions)
+		srv.mux.HandleFunc(subscriptionsPath+"/", srv.handleSubscription)
+	}
+
+	return srv
+}
+
+func ServerWithPort(port int) ServerOption {
+	return func(s *Server) {
+		s.port = port
+	}
+}
+
+// ServerWithToken sets the bearer token that requests must inform.
+func ServerWithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// ServerWithDeletionGracePeriod retains deleted triggers for the grace
+// period, during which they can be restored. Zero deletes them right away.
+func ServerWithDeletionGracePeriod(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.deletionGracePeriod = d
+	}
+}
+
+// ServerWithValidator rejects modified configurations that do not pass
+// the validator.
+func ServerWithValidator(v cfgbroker.Validator) ServerOption {
+	return func(s *Server) {
+		s.validator = v
+	}
+}
+
+// AddCallback registers a function that will be called with the
+// configuration resulting from changes done through the admin API.
+func (s *Server) AddCallback(cb ConfigCallback) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.callbacks = append(s.callbacks, cb)
+}
+
+// Handle registers an authenticated handler at the admin server.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// UpdateFromConfig keeps track of the broker configuration, which is
+// used as the base for changes done through the admin API.
+func (s *Server) UpdateFromConfig(c *cfgbroker.Config) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.config = c
+}
+
+func (s *Server) currentConfig() *cfgbroker.Config {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.config
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Infow("Starting admin server", zap.Int("port", s.port))
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(sctx)
+
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// Handler returns the handler of the API, for serving it from servers other
+// than the one started by Start.
+func (s *Server) Handler() http.Handler {
+	return s.handler()
+}
+
+// handler authenticates requests to the API, the UI, if enabled, is
+// served to any request. Consumer registrations are authenticated with
+// the token of each consumer.
+func (s *Server) handler() http.Handler {
+	api := s.registrations(s.authenticate(s.mux))
+	if !s.ui {
+		return api
+	}
+
+	ui := uiHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			http.Redirect(w, r, uiPath, http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, uiPath):
+			ui.ServeHTTP(w, r)
+		default:
+			api.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(requestAuthorization(r)), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestAuthorization returns the authorization header of the request.
+// Browsers cannot set headers for websocket connections, the token can be
+// informed as a query parameter instead.
+func requestAuthorization(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if auth == "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		if t := r.URL.Query().Get("access_token"); t != "" {
+			auth = "Bearer " + t
+		}
+	}
+	return auth
+}
+
+func (s *Server) handleTriggers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.m.Lock()
+	triggers := s.config.Triggers
+	s.m.Unlock()
+
+	if triggers == nil {
+		triggers = map[string]cfgbroker.Trigger{}
+	}
+	writeJSON(w, http.StatusOK, triggers)
+}
+
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, triggersPath+"/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.m.Lock()
+		t, ok := s.config.Triggers[name]
+		s.m.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("trigger %q not found", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+
+	case http.MethodPut:
+		t := cfgbroker.Trigger{}
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&t); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse trigger: %v", err))
+			return
+		}
+
+		created, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			// A trigger created with the name of a deleted trigger
+			// replaces it.
+			_, exists := c.Triggers[name]
+			c.Triggers[name] = t
+			delete(c.DeletedTriggers, name)
+			return !exists
+		})
+		if err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, t)
+
+	case http.MethodDelete:
+		found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			t, exists := c.Triggers[name]
+			delete(c.Triggers, name)
+			if exists && s.deletionGracePeriod > 0 {
+				c.DeletedTriggers[name] = cfgbroker.DeletedTrigger{Trigger: t, DeletedAt: s.now().UTC()}
+			}
+			return exists
+		})
+		if err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("trigger %q not found", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// errInvalidConfig wraps validation errors of the modified configuration.
+type errInvalidConfig struct {
+	err error
+}
+
+func (e *errInvalidConfig) Error() string {
+	return e.err.Error()
+}
+
+// modify applies the change function to a copy of the current configuration,
+// then validates, persists and notifies the resulting configuration.
+func (s *Server) modify(ctx context.Context, change func(*cfgbroker.Config) bool) (bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Triggers are replaced as a whole, a shallow copy of the map
+	// is enough to not modify the current configuration.
+	c := &cfgbroker.Config{
+		Ingest:   s.config.Ingest,
+		Triggers: make(map[string]cfgbroker.Trigger, len(s.config.Triggers)),
+		Brokers:  s.config.Brokers,
+		Features: s.config.Features,
+
+		Registration: s.config.Registration,
+
+		DeletedTriggers: make(map[string]cfgbroker.DeletedTrigger, len(s.config.DeletedTriggers)),
+	}
+	for k, v := range s.config.Triggers {
+		c.Triggers[k] = v
+	}
+
+	// Deleted triggers whose grace period expired are dropped.
+	now := s.now()
+	for k, v := range s.config.DeletedTriggers {
+		if now.Before(v.DeletedAt.Add(s.deletionGracePeriod)) {
+			c.DeletedTriggers[k] = v
+		}
+	}
+
+	ok := change(c)
+	if len(c.DeletedTriggers) == 0 {
+		c.DeletedTriggers = nil
+	}
+
+	if err := c.Validate(ctx); err != nil {
+		return false, &errInvalidConfig{err: err}
+	}
+	if s.validator != nil {
+		if err := s.validator(c); err != nil {
+			return false, &errInvalidConfig{err: err}
+		}
+	}
+
+	if err := s.store.Write(c); err != nil {
+		return false, err
+	}
+
+	s.config = c
+	for _, cb := range s.callbacks {
+		cb(c)
+	}
+
+	return ok, nil
+}
+
+func (s *Server) writeModifyError(w http.ResponseWriter, name string, err error) {
+	ierr := &errInvalidConfig{}
+	switch {
+	case errors.As(err, &ierr):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, store.ErrReadOnly):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		s.logger.Errorw("Could not persist broker configuration", zap.String("trigger", name), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "could not persist broker configuration")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
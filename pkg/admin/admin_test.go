@@ -0,0 +1,388 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+	"github.com/triggermesh/brokers/pkg/firehose"
+	"github.com/triggermesh/brokers/pkg/stream"
+	"github.com/triggermesh/brokers/pkg/tap"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestTriggersAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.conf")
+	s := New(store.NewFile(path), zap.NewNop().Sugar(), ServerWithToken("secret"))
+	s.UpdateFromConfig(&cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{},
+		Features: map[string]bool{"some-feature": true},
+	})
+
+	var applied *cfgbroker.Config
+	s.AddCallback(func(c *cfgbroker.Config) { applied = c })
+
+	h := s.authenticate(s.mux)
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/v1/triggers", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/v1/triggers", "wrong", "").Code)
+
+	trigger := `{"target":{"url":"http://localhost:8888"}}`
+	assert.Equal(t, http.StatusCreated, do(http.MethodPut, "/v1/triggers/t1", "secret", trigger).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/v1/triggers/t1", "secret", trigger).Code)
+
+	require.NotNil(t, applied)
+	assert.Contains(t, applied.Triggers, "t1")
+	assert.Equal(t, map[string]bool{"some-feature": true}, applied.Features, "Changes must keep the rest of the configuration")
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	persisted, err := cfgbroker.Parse(string(b))
+	require.NoError(t, err)
+	assert.Equal(t, applied, persisted)
+
+	rr := do(http.MethodGet, "/v1/triggers", "secret", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "http://localhost:8888")
+
+	invalid := `{"target":{"url":"http://[bad"}}`
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, "/v1/triggers/t2", "secret", invalid).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/triggers/t2", "secret", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/triggers/t1", "secret", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/v1/triggers/t1", "secret", "").Code)
+	assert.Empty(t, applied.Triggers)
+}
+
+type fakeProducer struct {
+	events []*cloudevents.Event
+}
+
+func (p *fakeProducer) Produce(_ context.Context, event *cloudevents.Event) error {
+	// Reject events the way the ingest instance does.
+	if event.Type() == "rejected.type" {
+		return cehttp.NewResult(http.StatusBadRequest, "event rejected")
+	}
+	if event.Type() == "failed.type" {
+		return protocol.ResultNACK
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestUIAndEventsAPI(t *testing.T) {
+	dlsFile := filepath.Join(t.TempDir(), "dls.jsonl")
+	require.NoError(t, os.WriteFile(dlsFile, []byte(`{"id":"1"}`+"\n"+`{"id":"2"}`+"\n"+`{"id":"3"}`+"\n"), 0o600))
+
+	p := &fakeProducer{}
+	s := New(store.NewMemory(), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithEventProducer(p),
+		ServerWithUI(true))
+	s.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"t1": {Target: cfgbroker.Target{DeliveryOptions: &cfgbroker.DeliveryOptions{
+			DeadLetterSinks: []cfgbroker.DeadLetterSink{{File: &dlsFile}},
+		}}},
+	}})
+
+	h := s.handler()
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/ui/", "", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<title>TriggerMesh Broker</title>")
+	assert.Equal(t, http.StatusFound, do(http.MethodGet, "/", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/v1/deadletters/t1", "", "").Code)
+
+	rr = do(http.MethodGet, "/v1/deadletters/t1?limit=2", "secret", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[{"file":"`+dlsFile+`","events":[{"id":"2"},{"id":"3"}]}]`, rr.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/deadletters/t2", "secret", "").Code)
+
+	event := `{"specversion":"1.0","type":"test.type","source":"test.source","data":{"hello":"world"}}`
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/v1/events", "secret", event).Code)
+	require.Len(t, p.events, 1)
+	assert.NotEmpty(t, p.events[0].ID())
+	assert.Equal(t, "test.type", p.events[0].Type())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/v1/events", "secret", `{"specversion":"1.0"}`).Code)
+
+	// Rejections from ingest are informed along with their status code.
+	event = `{"specversion":"1.0","type":"rejected.type","source":"test.source"}`
+	rr = do(http.MethodPost, "/v1/events", "secret", event)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "event rejected")
+
+	event = `{"specversion":"1.0","type":"failed.type","source":"test.source"}`
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodPost, "/v1/events", "secret", event).Code)
+	assert.Len(t, p.events, 1)
+}
+
+func TestFirehose(t *testing.T) {
+	hub := firehose.NewHub()
+	s := New(store.NewMemory(), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithFirehose(hub))
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/firehose?trigger=t1"
+	_, err := websocket.Dial(wsURL, "", srv.URL)
+	assert.Error(t, err, "unauthenticated connection must be rejected")
+
+	conn, err := websocket.Dial(wsURL+"&access_token=secret", "", srv.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, hub.Active, time.Second, 10*time.Millisecond)
+	hub.Publish(&firehose.Record{Trigger: "t2", EventID: "ignored"})
+	hub.Publish(&firehose.Record{Trigger: "t1", EventID: "e1", Decision: firehose.DecisionDelivery})
+
+	r := &firehose.Record{}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, websocket.JSON.Receive(conn, r))
+	assert.Equal(t, "e1", r.EventID)
+}
+
+func TestTap(t *testing.T) {
+	hub := tap.NewHub()
+	s := New(store.NewMemory(), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithTap(hub))
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	get := func(query string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/tap?"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	for _, q := range []string{"filter=type+%3D+%3D", "duration=PT1H", "sample=2", "rate=0"} {
+		res := get(q)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, q)
+	}
+
+	res := get("filter=" + url.QueryEscape("type = 'order.created'") + "&duration=PT1S")
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	require.Eventually(t, hub.Active, time.Second, 10*time.Millisecond)
+	ignored := lib.NewCloudEvent(lib.CloudEventWithIDOption("ignored"), lib.CloudEventWithTypeOption("order.deleted"))
+	matching := lib.NewCloudEvent(lib.CloudEventWithIDOption("e1"), lib.CloudEventWithTypeOption("order.created"))
+	hub.Publish(context.Background(), &ignored)
+	hub.Publish(context.Background(), &matching)
+
+	// The stream ends once the duration expires.
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"id":"e1"`)
+	assert.NotContains(t, string(body), "ignored")
+	assert.Contains(t, string(body), "event: end\ndata: {\"dropped\":0}")
+	assert.False(t, hub.Active(), "The tap must be detached")
+}
+
+func TestSubscribe(t *testing.T) {
+	hub := stream.NewHub()
+	st := hub.Stream("t1", 10)
+	s := New(store.NewMemory(), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithStreams(hub))
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/subscribe?trigger=t2", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "Triggers that do not stream events must be rejected")
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/subscribe?trigger=t1&access_token=secret"
+	conn, err := websocket.Dial(wsURL, "", srv.URL)
+	require.NoError(t, err)
+
+	ev := cloudevents.NewEvent()
+	ev.SetID("e1")
+	ev.SetType("test.type")
+	ev.SetSource("test.source")
+	require.Eventually(t, func() bool { return st.Publish(&ev) == nil },
+		time.Second, 10*time.Millisecond)
+
+	m := &streamMessage{}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, websocket.JSON.Receive(conn, m))
+	assert.Equal(t, "e1", m.Event.ID())
+	conn.Close()
+
+	// Server-sent events consumers resume after the last event received.
+	req, err = http.NewRequest(http.MethodGet, srv.URL+"/v1/subscribe?trigger=t1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Last-Event-ID", m.Token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err = http.DefaultClient.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	ev.SetID("e2")
+	require.NoError(t, st.Publish(&ev))
+
+	sc := bufio.NewScanner(res.Body)
+	require.True(t, sc.Scan())
+	assert.True(t, strings.HasPrefix(sc.Text(), "id: "))
+	assert.NotEqual(t, "id: "+m.Token, sc.Text())
+	require.True(t, sc.Scan())
+	assert.Contains(t, sc.Text(), `"id":"e2"`)
+}
+
+func TestSubscribeConsumers(t *testing.T) {
+	t.Setenv("TEST_ORDERS_TOKEN", "orders-token")
+	ordersEnv := "TEST_ORDERS_TOKEN"
+
+	hub := stream.NewHub()
+	hub.Stream("orders.stream", 10)
+	hub.Stream("billing.stream", 10)
+	s := New(store.NewMemory(), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithStreams(hub))
+	s.UpdateFromConfig(&cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{
+			"orders.stream":  {Owner: "orders", Target: cfgbroker.Target{Stream: &cfgbroker.StreamTarget{}}},
+			"billing.stream": {Target: cfgbroker.Target{Stream: &cfgbroker.StreamTarget{}}},
+		},
+		Registration: &cfgbroker.Registration{
+			Consumers: map[string]cfgbroker.Consumer{
+				"orders": {Token: cfgbroker.SecretSource{Env: &ordersEnv}, MaxTriggers: 1},
+			},
+		},
+	})
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	subscribe := func(trigger, token string) int {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/subscribe?trigger="+trigger, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, subscribe("orders.stream", "orders-token"))
+	assert.Equal(t, http.StatusNotFound, subscribe("billing.stream", "orders-token"),
+		"Consumers must only subscribe to the triggers they own")
+	assert.Equal(t, http.StatusOK, subscribe("billing.stream", "secret"))
+	assert.Equal(t, http.StatusUnauthorized, subscribe("orders.stream", "other"))
+}
+
+func TestDeletedTriggersAPI(t *testing.T) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	s := New(store.NewMemory(), zap.NewNop().Sugar(), ServerWithToken("secret"), ServerWithDeletionGracePeriod(time.Hour))
+	s.now = func() time.Time { return now }
+
+	var applied *cfgbroker.Config
+	s.AddCallback(func(c *cfgbroker.Config) { applied = c })
+
+	h := s.authenticate(s.mux)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	trigger := `{"target":{"url":"http://localhost:8888"}}`
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/v1/triggers/t1", trigger).Code)
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/triggers/t1", "").Code)
+
+	assert.NotContains(t, applied.Triggers, "t1")
+	require.Contains(t, applied.DeletedTriggers, "t1")
+	assert.Equal(t, now, applied.DeletedTriggers["t1"].DeletedAt)
+
+	rr := do(http.MethodGet, "/v1/deletedtriggers", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"t1"`)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/deletedtriggers/t1", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/deadletters/t1", "").Code,
+		"Dead letters of deleted triggers must be readable")
+
+	// Restoring moves the trigger back.
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/v1/deletedtriggers/t1", "").Code)
+	assert.Contains(t, applied.Triggers, "t1")
+	assert.Empty(t, applied.DeletedTriggers)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/v1/deletedtriggers/t1", "").Code)
+
+	// Creating a trigger replaces the deleted one.
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/triggers/t1", "").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/v1/triggers/t1", trigger).Code)
+	assert.Empty(t, applied.DeletedTriggers)
+
+	// Deleted triggers can be deleted for good.
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/triggers/t1", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/deletedtriggers/t1", "").Code)
+	assert.Empty(t, applied.DeletedTriggers)
+
+	// Deleted triggers cannot be restored after the grace period.
+	require.Equal(t, http.StatusCreated, do(http.MethodPut, "/v1/triggers/t2", trigger).Code)
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/triggers/t2", "").Code)
+	now = now.Add(time.Hour)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/deletedtriggers/t2", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/v1/deletedtriggers/t2", "").Code)
+	assert.Empty(t, applied.DeletedTriggers)
+}
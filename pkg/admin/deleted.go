@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// handleDeletedTriggers lists the triggers that can be restored.
+func (s *Server) handleDeletedTriggers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.m.Lock()
+	now := s.now()
+	deleted := make(map[string]cfgbroker.DeletedTrigger, len(s.config.DeletedTriggers))
+	for k, v := range s.config.DeletedTriggers {
+		if now.Before(v.DeletedAt.Add(s.deletionGracePeriod)) {
+			deleted[k] = v
+		}
+	}
+	s.m.Unlock()
+
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// handleDeletedTrigger retrieves a deleted trigger, restores it when
+// receiving a POST request, or deletes it for good.
+func (s *Server) handleDeletedTrigger(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, deletedTriggersPath+"/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.m.Lock()
+		d, ok := s.config.DeletedTriggers[name]
+		expired := ok && !s.now().Before(d.DeletedAt.Add(s.deletionGracePeriod))
+		s.m.Unlock()
+
+		if !ok || expired {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("deleted trigger %q not found", name))
+			return
+		}
+		writeJSON(w, http.StatusOK, d)
+
+	case http.MethodPost:
+		var restored cfgbroker.Trigger
+		conflict := false
+		found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			d, ok := c.DeletedTriggers[name]
+			if !ok {
+				return false
+			}
+			if _, conflict = c.Triggers[name]; conflict {
+				return false
+			}
+			delete(c.DeletedTriggers, name)
+			c.Triggers[name] = d.Trigger
+			restored = d.Trigger
+			return true
+		})
+		switch {
+		case err != nil:
+			s.writeModifyError(w, name, err)
+		case conflict:
+			writeError(w, http.StatusConflict, fmt.Sprintf("trigger %q already exists", name))
+		case !found:
+			writeError(w, http.StatusNotFound, fmt.Sprintf("deleted trigger %q not found", name))
+		default:
+			writeJSON(w, http.StatusOK, restored)
+		}
+
+	case http.MethodDelete:
+		found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			_, exists := c.DeletedTriggers[name]
+			delete(c.DeletedTriggers, name)
+			return exists
+		})
+		if err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("deleted trigger %q not found", name))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	eventsPath      = "/v1/events"
+	deadLettersPath = "/v1/deadletters/"
+
+	// Default number of dead lettered events returned.
+	defaultDeadLettersLimit = 100
+)
+
+// ServerWithEventProducer enables producing test events through the
+// admin API. The producer is expected to be the ingest instance, so that
+// test events are handled as any other ingested event.
+func ServerWithEventProducer(p backend.EventProducer) ServerOption {
+	return func(s *Server) {
+		s.producer = p
+	}
+}
+
+// ServerWithIDGenerator sets the generator for the ID of test events that
+// do not inform it.
+func ServerWithIDGenerator(gen eventid.Generator) ServerOption {
+	return func(s *Server) {
+		s.idGenerator = gen
+	}
+}
+
+// handleEvents produces a structured CloudEvent to the broker. The id
+// attribute is generated if not informed.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not read event: %v", err))
+		return
+	}
+
+	event := cloudevents.NewEvent()
+	if err := json.Unmarshal(b, &event); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse event: %v", err))
+		return
+	}
+
+	if event.ID() == "" {
+		event.SetID(s.idGenerator.NewID())
+	}
+
+	if err := event.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := s.producer.Produce(r.Context(), &event); err != nil {
+		var res *cehttp.Result
+		if errors.As(err, &res) && res.StatusCode >= 400 && res.StatusCode < 500 {
+			writeError(w, res.StatusCode, res.Error())
+			return
+		}
+
+		s.logger.Errorw("Could not produce test event", zap.String("id", event.ID()), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "could not produce event")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, &event)
+}
+
+// handleDeadLetters returns the last events written to the dead letter
+// files of a trigger, as many as informed by the limit query parameter.
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, deadLettersPath)
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	limit := defaultDeadLettersLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = v
+	}
+
+	// Dead letter files of deleted triggers are kept along with them.
+	s.m.Lock()
+	t, ok := s.config.Triggers[name]
+	if d, deleted := s.config.DeletedTriggers[name]; !ok && deleted {
+		t, ok = d.Trigger, true
+	}
+	s.m.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("trigger %q not found", name))
+		return
+	}
+
+	res := []deadLetterFile{}
+	for _, path := range deadLetterFiles(&t) {
+		events, err := tailEvents(path, limit)
+		if err != nil {
+			s.logger.Errorw("Could not read dead letter file", zap.String("file", path), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "could not read dead letter file")
+			return
+		}
+		res = append(res, deadLetterFile{File: path, Events: events})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// deadLetterFile contains the events read from a dead letter file.
+type deadLetterFile struct {
+	File   string            `json:"file"`
+	Events []json.RawMessage `json:"events"`
+}
+
+func deadLetterFiles(t *cfgbroker.Trigger) []string {
+	if t.Target.DeliveryOptions == nil {
+		return nil
+	}
+
+	var files []string
+	for _, dls := range t.Target.DeliveryOptions.DeadLetterSinks {
+		if dls.File != nil && *dls.File != "" {
+			files = append(files, *dls.File)
+		}
+	}
+	return files
+}
+
+// tailEvents returns the last JSON lines of the file. Files that do not
+// exist contain no events.
+func tailEvents(path string, limit int) ([]json.RawMessage, error) {
+	events := []json.RawMessage{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return events, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxBodySize)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !json.Valid(line) {
+			continue
+		}
+		events = append(events, json.RawMessage(append([]byte(nil), line...)))
+		if len(events) > limit {
+			events = events[1:]
+		}
+	}
+
+	return events, sc.Err()
+}
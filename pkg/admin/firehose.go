@@ -0,0 +1,96 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/triggermesh/brokers/pkg/firehose"
+)
+
+const (
+	firehosePath = "/v1/firehose"
+
+	// Period for informing observers about dropped records.
+	firehoseDroppedPeriod = 5 * time.Second
+)
+
+// ServerWithFirehose streams the hub records through a websocket endpoint.
+func ServerWithFirehose(hub *firehose.Hub) ServerOption {
+	return func(s *Server) {
+		s.firehose = hub
+	}
+}
+
+// firehoseDropped is sent to observers when records were dropped.
+type firehoseDropped struct {
+	Dropped uint64 `json:"dropped"`
+}
+
+func (s *Server) handleFirehose(w http.ResponseWriter, r *http.Request) {
+	f, err := firehose.ParseFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ws := websocket.Server{
+		// Requests are authenticated, there is no need to check the
+		// origin, which non browser clients do not inform.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			s.streamFirehose(conn, f)
+		},
+	}
+	ws.ServeHTTP(w, r)
+}
+
+func (s *Server) streamFirehose(conn *websocket.Conn, f *firehose.Filter) {
+	o := s.firehose.Subscribe(f)
+	defer s.firehose.Unsubscribe(o)
+
+	// Observers are not expected to send messages, reading detects
+	// when they close the connection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(firehoseDroppedPeriod)
+	defer ticker.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-closed:
+			return
+
+		case r := <-o.C:
+			if err := websocket.JSON.Send(conn, r); err != nil {
+				s.logger.Debugw("Firehose observer disconnected", zap.Error(err))
+				return
+			}
+
+		case <-ticker.C:
+			if d := o.Dropped(); d != dropped {
+				dropped = d
+				if err := websocket.JSON.Send(conn, &firehoseDropped{Dropped: d}); err != nil {
+					s.logger.Debugw("Firehose observer disconnected", zap.Error(err))
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const registrationsPath = "/v1/registrations"
+
+// registration is the document consumers send to register a trigger that
+// delivers to their URL.
+type registration struct {
+	// Name of the trigger, unique for the consumer.
+	Name    string             `json:"name"`
+	URL     string             `json:"url"`
+	Filters []cfgbroker.Filter `json:"filters,omitempty"`
+}
+
+// registeredTrigger is the response for registrations, informing the
+// name of the trigger at the broker.
+type registeredTrigger struct {
+	Name    string            `json:"name"`
+	Trigger cfgbroker.Trigger `json:"trigger"`
+}
+
+// consumerKey is the context key of the identity of the consumer that
+// authenticated the request.
+type consumerKey struct{}
+
+// registrations serves the requests of consumers that register their own
+// triggers, which are not authenticated with the admin token but with the
+// token of each consumer. Stream subscriptions and CloudEvents
+// subscriptions informing the token of a consumer are served for the
+// triggers it owns.
+func (s *Server) registrations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == registrationsPath:
+			s.handleRegistrations(w, r)
+		case strings.HasPrefix(r.URL.Path, registrationsPath+"/"):
+			s.handleRegistration(w, r)
+		case r.URL.Path == subscribePath && s.streams != nil:
+			s.serveConsumer(w, r, requestAuthorization(r), s.handleSubscribe, next)
+		case r.URL.Path == subscriptionsPath && s.subscriptions:
+			s.serveConsumer(w, r, r.Header.Get("Authorization"), s.handleSubscriptions, next)
+		case strings.HasPrefix(r.URL.Path, subscriptionsPath+"/") && s.subscriptions:
+			s.serveConsumer(w, r, r.Header.Get("Authorization"), s.handleSubscription, next)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serveConsumer serves the request with the handler, informing the
+// consumer at the context, when the authorization informs the token of a
+// consumer, and with next otherwise.
+func (s *Server) serveConsumer(w http.ResponseWriter, r *http.Request, authorization string, h http.HandlerFunc, next http.Handler) {
+	if id, _, ok := s.lookupConsumer(authorization); ok {
+		h(w, r.WithContext(context.WithValue(r.Context(), consumerKey{}, id)))
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// consumerFor returns the identity and settings of the consumer whose
+// token is informed at the request.
+func (s *Server) consumerFor(w http.ResponseWriter, r *http.Request) (string, *cfgbroker.Consumer, bool) {
+	if id, c, ok := s.lookupConsumer(r.Header.Get("Authorization")); ok {
+		return id, c, true
+	}
+
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, "unauthorized")
+	return "", nil, false
+}
+
+// lookupConsumer returns the identity and settings of the consumer whose
+// bearer token the authorization header value informs.
+func (s *Server) lookupConsumer(authorization string) (string, *cfgbroker.Consumer, bool) {
+	s.m.Lock()
+	var consumers map[string]cfgbroker.Consumer
+	if s.config.Registration != nil {
+		consumers = s.config.Registration.Consumers
+	}
+	s.m.Unlock()
+
+	auth := []byte(authorization)
+	for id, c := range consumers {
+		token, err := secret.Read(&c.Token)
+		if err != nil {
+			s.logger.Errorw("Could not read consumer token", zap.String("consumer", id), zap.Error(err))
+			continue
+		}
+		if token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+token)) == 1 {
+			c := c
+			return id, &c, true
+		}
+	}
+	return "", nil, false
+}
+
+// consumerFromContext returns the identity of the consumer that
+// authenticated the request, false if it was authenticated with the admin
+// token.
+func consumerFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(consumerKey{}).(string)
+	return id, ok
+}
+
+// handleRegistrations lists the triggers registered by the consumer, or
+// registers a trigger when receiving a POST request.
+func (s *Server) handleRegistrations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, consumer, ok := s.consumerFor(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.m.Lock()
+		triggers := make(map[string]cfgbroker.Trigger)
+		for name, t := range s.config.Triggers {
+			if t.Owner == id {
+				triggers[name] = t
+			}
+		}
+		s.m.Unlock()
+
+		writeJSON(w, http.StatusOK, triggers)
+		return
+	}
+
+	reg := registration{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&reg); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse registration: %v", err))
+		return
+	}
+
+	if msgs := validation.IsDNS1123Label(reg.Name); len(msgs) != 0 {
+		writeError(w, http.StatusUnprocessableEntity, "registration name must be a DNS label: "+strings.Join(msgs, ", "))
+		return
+	}
+	if u, err := url.Parse(reg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusUnprocessableEntity, "registration URL must be an HTTP URL")
+		return
+	}
+	if !consumer.AllowsURL(reg.URL) {
+		writeError(w, http.StatusForbidden, "registration URL is not allowed for the consumer")
+		return
+	}
+	if usesWASM(reg.Filters) {
+		writeError(w, http.StatusForbidden, "registration filters cannot reference WASM modules")
+		return
+	}
+
+	name := id + "." + reg.Name
+	t := cfgbroker.Trigger{
+		Filters: reg.Filters,
+		Target: cfgbroker.Target{
+			URL:             &reg.URL,
+			DeliveryOptions: consumer.DeliveryOptions,
+		},
+		Owner: id,
+	}
+
+	quotaExceeded, conflict := false, false
+	created, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+		if existing, ok := c.Triggers[name]; ok {
+			// Triggers informed at the configuration with the name of a
+			// registration are not replaced.
+			if existing.Owner != id {
+				conflict = true
+				return false
+			}
+			c.Triggers[name] = t
+			return false
+		}
+
+		n := 0
+		for _, t := range c.Triggers {
+			if t.Owner == id {
+				n++
+			}
+		}
+		if n >= consumer.MaxTriggers {
+			quotaExceeded = true
+			return false
+		}
+
+		c.Triggers[name] = t
+		delete(c.DeletedTriggers, name)
+		return true
+	})
+	if err != nil {
+		s.writeModifyError(w, name, err)
+		return
+	}
+
+	switch {
+	case conflict:
+		writeError(w, http.StatusConflict, fmt.Sprintf("trigger %q is not registered by the consumer", name))
+	case quotaExceeded:
+		writeError(w, http.StatusForbidden, fmt.Sprintf("consumer cannot register more than %d triggers", consumer.MaxTriggers))
+	case created:
+		writeJSON(w, http.StatusCreated, registeredTrigger{Name: name, Trigger: t})
+	default:
+		writeJSON(w, http.StatusOK, registeredTrigger{Name: name, Trigger: t})
+	}
+}
+
+// usesWASM returns true if any of the filters, nested ones included,
+// references a WASM module, which consumers are not allowed to load at the
+// broker.
+func usesWASM(filters []cfgbroker.Filter) bool {
+	for _, f := range filters {
+		if f.WASM != nil || usesWASM(f.All) || usesWASM(f.Any) {
+			return true
+		}
+		if f.Not != nil && usesWASM([]cfgbroker.Filter{*f.Not}) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRegistration retrieves or deletes a trigger registered by the
+// consumer, referenced by the name informed when registering.
+func (s *Server) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	reg := strings.TrimPrefix(r.URL.Path, registrationsPath+"/")
+	if reg == "" || strings.Contains(reg, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, _, ok := s.consumerFor(w, r)
+	if !ok {
+		return
+	}
+	name := id + "." + reg
+
+	if r.Method == http.MethodGet {
+		s.m.Lock()
+		t, ok := s.config.Triggers[name]
+		s.m.Unlock()
+
+		if !ok || t.Owner != id {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("registration %q not found", reg))
+			return
+		}
+		writeJSON(w, http.StatusOK, registeredTrigger{Name: name, Trigger: t})
+		return
+	}
+
+	found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+		t, exists := c.Triggers[name]
+		if !exists || t.Owner != id {
+			return false
+		}
+		delete(c.Triggers, name)
+		if s.deletionGracePeriod > 0 {
+			c.DeletedTriggers[name] = cfgbroker.DeletedTrigger{Trigger: t, DeletedAt: s.now().UTC()}
+		}
+		return true
+	})
+	if err != nil {
+		s.writeModifyError(w, name, err)
+		return
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("registration %q not found", reg))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
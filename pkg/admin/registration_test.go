@@ -0,0 +1,125 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+)
+
+func TestRegistrationsAPI(t *testing.T) {
+	t.Setenv("TEST_ORDERS_TOKEN", "orders-token")
+	t.Setenv("TEST_BILLING_TOKEN", "billing-token")
+	ordersEnv, billingEnv := "TEST_ORDERS_TOKEN", "TEST_BILLING_TOKEN"
+
+	s := New(store.NewFile(filepath.Join(t.TempDir(), "broker.conf")), zap.NewNop().Sugar(), ServerWithToken("secret"))
+	s.UpdateFromConfig(&cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{
+			"billing.static": {Target: cfgbroker.Target{URL: strPtr("http://billing")}},
+		},
+		Registration: &cfgbroker.Registration{
+			Consumers: map[string]cfgbroker.Consumer{
+				"orders": {
+					Token:             cfgbroker.SecretSource{Env: &ordersEnv},
+					MaxTriggers:       1,
+					TargetURLPrefixes: []string{"http://orders.svc/events"},
+				},
+				"billing": {
+					Token:       cfgbroker.SecretSource{Env: &billingEnv},
+					MaxTriggers: 5,
+				},
+			},
+		},
+	})
+
+	var applied *cfgbroker.Config
+	s.AddCallback(func(c *cfgbroker.Config) { applied = c })
+
+	h := s.handler()
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	reg := `{"name":"created","url":"http://orders.svc/events","filters":[{"exact":{"type":"order.created"}}]}`
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/v1/registrations", "", reg).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/v1/registrations", "secret", reg).Code,
+		"The admin token must not be accepted as a consumer token")
+
+	rr := do(http.MethodPost, "/v1/registrations", "orders-token", reg)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var res registeredTrigger
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	assert.Equal(t, "orders.created", res.Name)
+
+	require.NotNil(t, applied)
+	tr := applied.Triggers["orders.created"]
+	assert.Equal(t, "orders", tr.Owner)
+	assert.Equal(t, "http://orders.svc/events", *tr.Target.URL)
+	assert.Len(t, tr.Filters, 1)
+
+	// Registering again updates the trigger.
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/v1/registrations", "orders-token", reg).Code)
+
+	t.Run("quota and scope", func(t *testing.T) {
+		rr := do(http.MethodPost, "/v1/registrations", "orders-token", `{"name":"other","url":"http://orders.svc/events/other"}`)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Contains(t, rr.Body.String(), "more than 1 triggers")
+
+		for _, u := range []string{
+			"http://billing.svc",
+			"http://orders.svc.evil.com/events",
+			"http://orders.svc/events-admin",
+			"http://orders.svc/events/../admin",
+			"https://orders.svc/events",
+		} {
+			rr = do(http.MethodPost, "/v1/registrations", "orders-token", `{"name":"created","url":"`+u+`"}`)
+			assert.Equal(t, http.StatusForbidden, rr.Code, "URL %s must not be allowed", u)
+		}
+
+		rr = do(http.MethodPost, "/v1/registrations", "billing-token",
+			`{"name":"wasm","url":"http://billing.svc","filters":[{"not":{"wasm":{"module":"/tmp/filter.wasm"}}}]}`)
+		assert.Equal(t, http.StatusForbidden, rr.Code, "WASM filters must not be registered")
+
+		rr = do(http.MethodPost, "/v1/registrations", "billing-token", `{"name":"static","url":"http://billing.svc"}`)
+		assert.Equal(t, http.StatusConflict, rr.Code, "Triggers not registered by the consumer must not be replaced")
+
+		rr = do(http.MethodPost, "/v1/registrations", "billing-token", `{"name":"Not_A_Label","url":"http://billing.svc"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("consumers only see their triggers", func(t *testing.T) {
+		rr := do(http.MethodGet, "/v1/registrations", "billing-token", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{}`, rr.Body.String())
+
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/registrations/created", "billing-token", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/v1/registrations/static", "billing-token", "").Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/v1/registrations/created", "orders-token", "").Code)
+	})
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/v1/registrations/created", "orders-token", "").Code)
+	assert.NotContains(t, applied.Triggers, "orders.created")
+	assert.Contains(t, applied.Triggers, "billing.static")
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/triggermesh/brokers/pkg/stream"
+)
+
+const subscribePath = "/v1/subscribe"
+
+// ServerWithStreams lets consumers receive the events of the triggers that
+// stream them through websocket or server-sent events connections.
+func ServerWithStreams(hub *stream.Hub) ServerOption {
+	return func(s *Server) {
+		s.streams = hub
+	}
+}
+
+// streamMessage is sent to websocket consumers for each event.
+type streamMessage struct {
+	// Token that resumes the stream after the event.
+	Token string            `json:"token"`
+	Event cloudevents.Event `json:"event"`
+}
+
+// handleSubscribe connects the consumer to the stream of the trigger,
+// resuming it after the event informed by the resume query parameter, or the
+// Last-Event-ID header of server-sent events. Registration consumers can
+// only subscribe to the triggers they own.
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	name := r.URL.Query().Get("trigger")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "trigger query parameter must be informed")
+		return
+	}
+	st := s.streams.Get(name)
+	if id, ok := consumerFromContext(r.Context()); ok && !s.ownedBy(name, id) {
+		st = nil
+	}
+	if st == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("trigger %q does not stream events", name))
+		return
+	}
+
+	resume := r.URL.Query().Get("resume")
+	if resume == "" {
+		resume = r.Header.Get("Last-Event-ID")
+	}
+
+	c, err := st.Subscribe(resume)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("trigger %q does not stream events", name))
+		return
+	}
+	defer c.Close()
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		ws := websocket.Server{
+			// Requests are authenticated, there is no need to check the
+			// origin, which non browser clients do not inform.
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(conn *websocket.Conn) {
+				defer conn.Close()
+				s.streamWebsocket(conn, c)
+			},
+		}
+		ws.ServeHTTP(w, r)
+		return
+	}
+
+	s.streamEvents(w, r, c)
+}
+
+// ownedBy returns true if the trigger is owned by the registration
+// consumer.
+func (s *Server) ownedBy(trigger, consumer string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	t, ok := s.config.Triggers[trigger]
+	return ok && t.Owner == consumer
+}
+
+// streamWebsocket sends the events of the stream as JSON messages. Events
+// are only taken from the stream once the previous message was written,
+// which applies the backpressure of the consumer to the stream.
+func (s *Server) streamWebsocket(conn *websocket.Conn, c *stream.Consumer) {
+	ctx, cancel := context.WithCancel(conn.Request().Context())
+	defer cancel()
+
+	// Consumers are not expected to send messages, reading detects when
+	// they close the connection.
+	go func() {
+		defer cancel()
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		m, err := c.Next(ctx)
+		if err != nil {
+			return
+		}
+		if err := websocket.JSON.Send(conn, &streamMessage{Token: m.Token, Event: m.Event}); err != nil {
+			s.logger.Debugw("Stream consumer disconnected", zap.Error(err))
+			return
+		}
+	}
+}
+
+// streamEvents sends the events of the stream as server-sent events, whose
+// ID is the token that resumes the stream after them.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, c *stream.Consumer) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		m, err := c.Next(r.Context())
+		if err != nil {
+			return
+		}
+
+		data, err := json.Marshal(m.Event)
+		if err != nil {
+			s.logger.Errorw("Could not serialize streamed event", zap.String("id", m.Event.ID()), zap.Error(err))
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", m.Token, data); err != nil {
+			s.logger.Debugw("Stream consumer disconnected", zap.Error(err))
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	subscriptionsPath = "/subscriptions"
+
+	// Prefix of the name of the triggers created for subscriptions, which
+	// is followed by the subscription ID.
+	subscriptionTriggerPrefix = "subscription."
+
+	// Protocol supported for subscriptions.
+	subscriptionProtocolHTTP = "HTTP"
+)
+
+// ServerWithSubscriptions serves the CloudEvents Subscription API, creating
+// a trigger for each subscription that delivers to its sink using the
+// delivery options, if any.
+func ServerWithSubscriptions(enabled bool, delivery *cfgbroker.DeliveryOptions) ServerOption {
+	return func(s *Server) {
+		s.subscriptions = enabled
+		s.subscriptionsDelivery = delivery
+	}
+}
+
+// subscription as defined by the CloudEvents Subscription API.
+type subscription struct {
+	ID               string               `json:"id,omitempty"`
+	Source           string               `json:"source,omitempty"`
+	Types            []string             `json:"types,omitempty"`
+	Config           json.RawMessage      `json:"config,omitempty"`
+	Filters          []subscriptionFilter `json:"filters,omitempty"`
+	Sink             string               `json:"sink"`
+	SinkCredential   json.RawMessage      `json:"sinkcredential,omitempty"`
+	Protocol         string               `json:"protocol"`
+	ProtocolSettings json.RawMessage      `json:"protocolsettings,omitempty"`
+}
+
+// subscriptionFilter is a filter expression of the CloudEvents Subscription
+// API filter dialect, which maps to the filters of triggers.
+type subscriptionFilter struct {
+	All    []subscriptionFilter `json:"all,omitempty"`
+	Any    []subscriptionFilter `json:"any,omitempty"`
+	Not    *subscriptionFilter  `json:"not,omitempty"`
+	Exact  map[string]string    `json:"exact,omitempty"`
+	Prefix map[string]string    `json:"prefix,omitempty"`
+	Suffix map[string]string    `json:"suffix,omitempty"`
+	SQL    string               `json:"sql,omitempty"`
+}
+
+func (f *subscriptionFilter) toTrigger() cfgbroker.Filter {
+	tf := cfgbroker.Filter{
+		All:    toTriggerFilters(f.All),
+		Any:    toTriggerFilters(f.Any),
+		Exact:  f.Exact,
+		Prefix: f.Prefix,
+		Suffix: f.Suffix,
+		CESQL:  f.SQL,
+	}
+	if f.Not != nil {
+		n := f.Not.toTrigger()
+		tf.Not = &n
+	}
+	return tf
+}
+
+func toTriggerFilters(fs []subscriptionFilter) []cfgbroker.Filter {
+	if len(fs) == 0 {
+		return nil
+	}
+	tfs := make([]cfgbroker.Filter, 0, len(fs))
+	for i := range fs {
+		tfs = append(tfs, fs[i].toTrigger())
+	}
+	return tfs
+}
+
+func fromTriggerFilter(tf *cfgbroker.Filter) subscriptionFilter {
+	f := subscriptionFilter{
+		All:    fromTriggerFilters(tf.All),
+		Any:    fromTriggerFilters(tf.Any),
+		Exact:  tf.Exact,
+		Prefix: tf.Prefix,
+		Suffix: tf.Suffix,
+		SQL:    tf.CESQL,
+	}
+	if tf.Not != nil {
+		n := fromTriggerFilter(tf.Not)
+		f.Not = &n
+	}
+	return f
+}
+
+func fromTriggerFilters(tfs []cfgbroker.Filter) []subscriptionFilter {
+	if len(tfs) == 0 {
+		return nil
+	}
+	fs := make([]subscriptionFilter, 0, len(tfs))
+	for i := range tfs {
+		fs = append(fs, fromTriggerFilter(&tfs[i]))
+	}
+	return fs
+}
+
+// subscriptionTrigger returns the trigger for the subscription, owned by the
+// consumer, if any. The source and types of the subscription are added to
+// its filters.
+func (s *Server) subscriptionTrigger(sub *subscription, owner string) (cfgbroker.Trigger, error) {
+	var filters []cfgbroker.Filter
+	if sub.Source != "" {
+		filters = append(filters, cfgbroker.Filter{Exact: map[string]string{"source": sub.Source}})
+	}
+	if len(sub.Types) != 0 {
+		types := make([]cfgbroker.Filter, 0, len(sub.Types))
+		for _, t := range sub.Types {
+			types = append(types, cfgbroker.Filter{Exact: map[string]string{"type": t}})
+		}
+		filters = append(filters, cfgbroker.Filter{Any: types})
+	}
+	filters = append(filters, toTriggerFilters(sub.Filters)...)
+
+	delivery, err := s.subscriptionDeliveryOptions()
+	if err != nil {
+		return cfgbroker.Trigger{}, err
+	}
+
+	sink := sub.Sink
+	return cfgbroker.Trigger{
+		Filters: filters,
+		Target: cfgbroker.Target{
+			URL:             &sink,
+			DeliveryOptions: delivery,
+		},
+		Owner: owner,
+	}, nil
+}
+
+// subscriptionDeliveryOptions returns a copy of the delivery options of
+// subscriptions, so that the triggers created for them do not share them.
+func (s *Server) subscriptionDeliveryOptions() (*cfgbroker.DeliveryOptions, error) {
+	if s.subscriptionsDelivery == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(s.subscriptionsDelivery)
+	if err != nil {
+		return nil, fmt.Errorf("could not copy subscriptions delivery options: %w", err)
+	}
+	d := &cfgbroker.DeliveryOptions{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("could not copy subscriptions delivery options: %w", err)
+	}
+	return d, nil
+}
+
+// subscriptionConsumer returns the identity and settings of the consumer
+// that authenticated the request, nil settings when authenticated with the
+// admin token. The error response is written when the consumer is no
+// longer configured.
+func (s *Server) subscriptionConsumer(w http.ResponseWriter, r *http.Request) (string, *cfgbroker.Consumer, bool) {
+	id, ok := consumerFromContext(r.Context())
+	if !ok {
+		return "", nil, true
+	}
+
+	s.m.Lock()
+	var c cfgbroker.Consumer
+	found := false
+	if s.config.Registration != nil {
+		c, found = s.config.Registration.Consumers[id]
+	}
+	s.m.Unlock()
+
+	if !found {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return "", nil, false
+	}
+	return id, &c, true
+}
+
+// visibleTo returns true if the trigger can be managed by the consumer,
+// which is any trigger when authenticated with the admin token.
+func visibleTo(t *cfgbroker.Trigger, consumer string) bool {
+	return consumer == "" || t.Owner == consumer
+}
+
+// triggerSubscription returns the subscription of the trigger, whose source
+// and types are informed as filters.
+func triggerSubscription(name string, t *cfgbroker.Trigger) subscription {
+	sub := subscription{
+		ID:       strings.TrimPrefix(name, subscriptionTriggerPrefix),
+		Filters:  fromTriggerFilters(t.Filters),
+		Protocol: subscriptionProtocolHTTP,
+	}
+	if t.Target.URL != nil {
+		sub.Sink = *t.Target.URL
+	}
+	return sub
+}
+
+// validateSubscription returns a message when the subscription cannot be
+// applied.
+func validateSubscription(sub *subscription) string {
+	switch {
+	case sub.Protocol != subscriptionProtocolHTTP:
+		return fmt.Sprintf("subscription protocol must be %s", subscriptionProtocolHTTP)
+	case len(sub.Config) != 0:
+		return "subscription config is not supported"
+	case len(sub.SinkCredential) != 0:
+		return "subscription sink credentials are not supported"
+	case len(sub.ProtocolSettings) != 0:
+		return "subscription protocol settings are not supported"
+	}
+
+	if u, err := url.Parse(sub.Sink); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "subscription sink must be an HTTP URL"
+	}
+	return ""
+}
+
+// handleSubscriptions lists the subscriptions, or creates a subscription
+// with a new ID when receiving a POST request. Consumers authenticated with
+// their registration token only manage their own subscriptions, which
+// count towards the triggers they can register.
+func (s *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	owner, consumer, ok := s.subscriptionConsumer(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.m.Lock()
+		subs := []subscription{}
+		for name, t := range s.config.Triggers {
+			t := t
+			if strings.HasPrefix(name, subscriptionTriggerPrefix) && visibleTo(&t, owner) {
+				subs = append(subs, triggerSubscription(name, &t))
+			}
+		}
+		s.m.Unlock()
+
+		sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+		writeJSON(w, http.StatusOK, subs)
+
+	case http.MethodPost:
+		sub, ok := readSubscription(w, r)
+		if !ok {
+			return
+		}
+		if sub.ID != "" {
+			writeError(w, http.StatusBadRequest, "subscription ID is assigned by the broker")
+			return
+		}
+		if consumer != nil && !consumer.AllowsURL(sub.Sink) {
+			writeError(w, http.StatusForbidden, "subscription sink is not allowed for the consumer")
+			return
+		}
+
+		sub.ID = uuid.New().String()
+		name := subscriptionTriggerPrefix + sub.ID
+		t, err := s.subscriptionTrigger(sub, owner)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		quotaExceeded := false
+		if _, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			if consumer != nil {
+				n := 0
+				for _, t := range c.Triggers {
+					if t.Owner == owner {
+						n++
+					}
+				}
+				if n >= consumer.MaxTriggers {
+					quotaExceeded = true
+					return false
+				}
+			}
+			c.Triggers[name] = t
+			return true
+		}); err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		if quotaExceeded {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("consumer cannot register more than %d triggers", consumer.MaxTriggers))
+			return
+		}
+
+		w.Header().Set("Location", subscriptionsPath+"/"+sub.ID)
+		writeJSON(w, http.StatusCreated, triggerSubscription(name, &t))
+	}
+}
+
+// handleSubscription retrieves, updates or deletes a subscription.
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, subscriptionsPath+"/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	name := subscriptionTriggerPrefix + id
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	owner, consumer, ok := s.subscriptionConsumer(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.m.Lock()
+		t, ok := s.config.Triggers[name]
+		s.m.Unlock()
+
+		if !ok || !visibleTo(&t, owner) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("subscription %q not found", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, triggerSubscription(name, &t))
+
+	case http.MethodPut:
+		sub, ok := readSubscription(w, r)
+		if !ok {
+			return
+		}
+		if sub.ID != "" && sub.ID != id {
+			writeError(w, http.StatusBadRequest, "subscription ID does not match the path")
+			return
+		}
+
+		if consumer != nil && !consumer.AllowsURL(sub.Sink) {
+			writeError(w, http.StatusForbidden, "subscription sink is not allowed for the consumer")
+			return
+		}
+
+		t, err := s.subscriptionTrigger(sub, owner)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			existing, ok := c.Triggers[name]
+			if !ok || !visibleTo(&existing, owner) {
+				return false
+			}
+			// Subscriptions keep their owner when replaced.
+			t.Owner = existing.Owner
+			c.Triggers[name] = t
+			return true
+		})
+		if err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("subscription %q not found", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, triggerSubscription(name, &t))
+
+	case http.MethodDelete:
+		var deleted cfgbroker.Trigger
+		found, err := s.modify(r.Context(), func(c *cfgbroker.Config) bool {
+			t, exists := c.Triggers[name]
+			if !exists || !visibleTo(&t, owner) {
+				return false
+			}
+			delete(c.Triggers, name)
+			if s.deletionGracePeriod > 0 {
+				c.DeletedTriggers[name] = cfgbroker.DeletedTrigger{Trigger: t, DeletedAt: s.now().UTC()}
+			}
+			deleted = t
+			return true
+		})
+		if err != nil {
+			s.writeModifyError(w, name, err)
+			return
+		}
+
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("subscription %q not found", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, triggerSubscription(name, &deleted))
+	}
+}
+
+// readSubscription parses and validates the subscription at the request
+// body, writing the error response when not valid.
+func readSubscription(w http.ResponseWriter, r *http.Request) (*subscription, bool) {
+	sub := &subscription{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(sub); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse subscription: %v", err))
+		return nil, false
+	}
+
+	if msg := validateSubscription(sub); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return nil, false
+	}
+	return sub, true
+}
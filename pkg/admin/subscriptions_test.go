@@ -0,0 +1,204 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+)
+
+func TestSubscriptionsAPI(t *testing.T) {
+	retry := int32(3)
+	s := New(store.NewFile(filepath.Join(t.TempDir(), "broker.conf")), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithSubscriptions(true, &cfgbroker.DeliveryOptions{Retry: &retry}))
+	s.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"static": {Target: cfgbroker.Target{URL: strPtr("http://static")}},
+	}})
+
+	var applied *cfgbroker.Config
+	s.AddCallback(func(c *cfgbroker.Config) { applied = c })
+
+	h := s.handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	sub := `{"source":"orders","types":["order.created","order.updated"],"filters":[{"sql":"amount > 100"}],"sink":"http://orders.svc/events","protocol":"HTTP"}`
+	rr := do(http.MethodPost, "/subscriptions", sub)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var created subscription
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.NotEmpty(t, created.ID)
+	assert.Equal(t, "/subscriptions/"+created.ID, rr.Header().Get("Location"))
+
+	require.NotNil(t, applied)
+	tr := applied.Triggers["subscription."+created.ID]
+	assert.Equal(t, "http://orders.svc/events", *tr.Target.URL)
+	assert.Equal(t, &retry, tr.Target.DeliveryOptions.Retry, "Triggers must use the default delivery options")
+
+	rr = do(http.MethodPost, "/subscriptions", `{"sink":"http://billing.svc","protocol":"HTTP"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var other subscription
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &other))
+	assert.NotSame(t, tr.Target.DeliveryOptions, applied.Triggers["subscription."+other.ID].Target.DeliveryOptions,
+		"Triggers must not share their delivery options")
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/subscriptions/"+other.ID, "").Code)
+	assert.Equal(t, []cfgbroker.Filter{
+		{Exact: map[string]string{"source": "orders"}},
+		{Any: []cfgbroker.Filter{
+			{Exact: map[string]string{"type": "order.created"}},
+			{Exact: map[string]string{"type": "order.updated"}},
+		}},
+		{CESQL: "amount > 100"},
+	}, tr.Filters)
+
+	t.Run("not valid", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"protocol":   `{"sink":"http://orders.svc","protocol":"KAFKA"}`,
+			"sink":       `{"sink":"orders.svc","protocol":"HTTP"}`,
+			"config":     `{"sink":"http://orders.svc","protocol":"HTTP","config":{"a":"b"}}`,
+			"id":         `{"id":"mine","sink":"http://orders.svc","protocol":"HTTP"}`,
+			"unknown":    `{"sink":"http://orders.svc","protocol":"HTTP","other":true}`,
+			"bad filter": `{"sink":"http://orders.svc","protocol":"HTTP","filters":[{"wasm":{}}]}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/subscriptions", body).Code, name)
+		}
+	})
+
+	rr = do(http.MethodGet, "/subscriptions", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var subs []subscription
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &subs))
+	require.Len(t, subs, 1, "Triggers not created for subscriptions must not be listed")
+	assert.Equal(t, created.ID, subs[0].ID)
+
+	rr = do(http.MethodPut, "/subscriptions/"+created.ID, `{"sink":"http://billing.svc","protocol":"HTTP"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "http://billing.svc", *applied.Triggers["subscription."+created.ID].Target.URL)
+	assert.Empty(t, applied.Triggers["subscription."+created.ID].Filters)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/subscriptions/other", `{"sink":"http://billing.svc","protocol":"HTTP"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/subscriptions/"+created.ID, `{"id":"other","sink":"http://billing.svc","protocol":"HTTP"}`).Code)
+
+	rr = do(http.MethodGet, "/subscriptions/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"id":"`+created.ID+`","sink":"http://billing.svc","protocol":"HTTP"}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/subscriptions/"+created.ID, "").Code)
+	assert.NotContains(t, applied.Triggers, "subscription."+created.ID)
+	assert.Contains(t, applied.Triggers, "static")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/subscriptions/"+created.ID, "").Code)
+}
+
+func TestSubscriptionsAPIConsumers(t *testing.T) {
+	t.Setenv("TEST_ORDERS_TOKEN", "orders-token")
+	t.Setenv("TEST_BILLING_TOKEN", "billing-token")
+	ordersEnv, billingEnv := "TEST_ORDERS_TOKEN", "TEST_BILLING_TOKEN"
+
+	s := New(store.NewFile(filepath.Join(t.TempDir(), "broker.conf")), zap.NewNop().Sugar(),
+		ServerWithToken("secret"),
+		ServerWithSubscriptions(true, nil))
+	s.UpdateFromConfig(&cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{
+			"subscription.static": {Target: cfgbroker.Target{URL: strPtr("http://static")}},
+		},
+		Registration: &cfgbroker.Registration{
+			Consumers: map[string]cfgbroker.Consumer{
+				"orders": {
+					Token:             cfgbroker.SecretSource{Env: &ordersEnv},
+					MaxTriggers:       1,
+					TargetURLPrefixes: []string{"http://orders.svc/events"},
+				},
+				"billing": {
+					Token:       cfgbroker.SecretSource{Env: &billingEnv},
+					MaxTriggers: 5,
+				},
+			},
+		},
+	})
+
+	var applied *cfgbroker.Config
+	s.AddCallback(func(c *cfgbroker.Config) { applied = c })
+
+	h := s.handler()
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	list := func(token string) []subscription {
+		rr := do(http.MethodGet, "/subscriptions", token, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var subs []subscription
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &subs))
+		return subs
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/subscriptions", "unknown-token", "").Code)
+	assert.Empty(t, list("orders-token"), "Consumers must only list their own subscriptions")
+
+	assert.Equal(t, http.StatusForbidden,
+		do(http.MethodPost, "/subscriptions", "orders-token", `{"sink":"http://billing.svc/events","protocol":"HTTP"}`).Code,
+		"Sinks must be allowed for the consumer")
+
+	rr := do(http.MethodPost, "/subscriptions", "orders-token", `{"sink":"http://orders.svc/events/created","protocol":"HTTP"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created subscription
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	name := "subscription." + created.ID
+	assert.Equal(t, "orders", applied.Triggers[name].Owner)
+
+	assert.Equal(t, http.StatusForbidden,
+		do(http.MethodPost, "/subscriptions", "orders-token", `{"sink":"http://orders.svc/events","protocol":"HTTP"}`).Code,
+		"Subscriptions must count towards the consumer quota")
+
+	require.Len(t, list("orders-token"), 1)
+	assert.Empty(t, list("billing-token"))
+	assert.Len(t, list("secret"), 2, "The admin token must list all subscriptions")
+
+	// Subscriptions of other consumers are not found.
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/subscriptions/"+created.ID, "billing-token", "").Code)
+	assert.Equal(t, http.StatusNotFound,
+		do(http.MethodPut, "/subscriptions/"+created.ID, "billing-token", `{"sink":"http://billing.svc","protocol":"HTTP"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/subscriptions/"+created.ID, "billing-token", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/subscriptions/static", "orders-token", "").Code)
+
+	rr = do(http.MethodPut, "/subscriptions/"+created.ID, "secret", `{"sink":"http://orders.svc/events/updated","protocol":"HTTP"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "orders", applied.Triggers[name].Owner, "Subscriptions must keep their owner when replaced")
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/subscriptions/"+created.ID, "orders-token", "").Code)
+	assert.NotContains(t, applied.Triggers, name)
+}
+
+func TestSubscriptionsAPIDisabled(t *testing.T) {
+	s := New(store.NewMemory(), zap.NewNop().Sugar(), ServerWithToken("secret"))
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	s.handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
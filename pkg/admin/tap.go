@@ -0,0 +1,127 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/tap"
+)
+
+const (
+	tapPath = "/v1/tap"
+
+	// Time taps are attached for when not informed, and the maximum.
+	defaultTapDuration = time.Minute
+	maxTapDuration     = 10 * time.Minute
+)
+
+// ServerWithTap streams samples of the ingested events that match ad-hoc
+// filters as server-sent events.
+func ServerWithTap(hub *tap.Hub) ServerOption {
+	return func(s *Server) {
+		s.tap = hub
+	}
+}
+
+// tapEnd is sent to observers when the tap is detached.
+type tapEnd struct {
+	Dropped uint64 `json:"dropped"`
+}
+
+// handleTap attaches a tap for the CESQL expression informed by the filter
+// query parameter, streaming the matching events until the ISO8601 duration
+// parameter expires. The sample and rate parameters limit the events sent.
+func (s *Server) handleTap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := tap.ParseFilter(q.Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("filter is not a valid CESQL expression: %v", err))
+		return
+	}
+
+	d := defaultTapDuration
+	if v := q.Get("duration"); v != "" {
+		p, err := period.Parse(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("duration is not an ISO8601 duration: %v", err))
+			return
+		}
+		if d = p.DurationApprox(); d <= 0 || d > maxTapDuration {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("duration must be positive and up to %s", maxTapDuration))
+			return
+		}
+	}
+
+	sample := 1.0
+	if v := q.Get("sample"); v != "" {
+		if sample, err = strconv.ParseFloat(v, 64); err != nil || sample < 0 || sample > 1 {
+			writeError(w, http.StatusBadRequest, "sample must be a number from 0 to 1")
+			return
+		}
+	}
+
+	maxRate := float64(tap.MaxRate)
+	if v := q.Get("rate"); v != "" {
+		if maxRate, err = strconv.ParseFloat(v, 64); err != nil || maxRate <= 0 || maxRate > tap.MaxRate {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("rate must be a positive number up to %d", tap.MaxRate))
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	t := s.tap.Attach(filter, sample, maxRate)
+	defer s.tap.Detach(t)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-timer.C:
+			data, _ := json.Marshal(&tapEnd{Dropped: t.Dropped()})
+			_, _ = fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+
+		case event := <-t.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.Errorw("Could not serialize tapped event", zap.String("id", event.ID()), zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				s.logger.Debugw("Tap observer disconnected", zap.Error(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
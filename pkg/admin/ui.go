@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+const uiPath = "/ui/"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// ServerWithUI serves the web UI from the admin server. The UI assets are
+// served without authentication, the UI asks for the admin token to use
+// the API.
+func ServerWithUI(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.ui = enabled
+	}
+}
+
+func uiHandler() http.Handler {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		// Embedded files are known at build time.
+		panic(err)
+	}
+	return http.StripPrefix(uiPath, http.FileServer(http.FS(sub)))
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+'use strict';
+
+const refreshPeriodMs = 2000;
+const maxLiveRecords = 50;
+
+let token = sessionStorage.getItem('token') || '';
+let previous = null;
+let live = null;
+
+async function api(method, path, body) {
+  const res = await fetch(path, {
+    method: method,
+    headers: {
+      'Authorization': 'Bearer ' + token,
+      'Content-Type': 'application/json',
+    },
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+
+  const text = await res.text();
+  const data = text ? JSON.parse(text) : null;
+  if (!res.ok) {
+    throw new Error((data && data.error) || res.statusText);
+  }
+  return data;
+}
+
+function showError(err) {
+  const el = document.getElementById('error');
+  el.textContent = err ? err.message : '';
+  el.hidden = !err;
+}
+
+function cell(row, text, className) {
+  const td = document.createElement('td');
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+  row.appendChild(td);
+  return td;
+}
+
+// rate calculates the events per second delivered and failed since the
+// previous refresh.
+function rate(name, st, now) {
+  if (!previous || !previous.triggers[name]) {
+    return '-';
+  }
+  const prev = previous.triggers[name];
+  const seconds = (now - previous.time) / 1000;
+  const count = (st.delivered + st.failed) - (prev.delivered + prev.failed);
+  return seconds > 0 ? (count / seconds).toFixed(1) : '-';
+}
+
+async function refresh() {
+  if (!token) {
+    return;
+  }
+
+  try {
+    const [triggers, status] = await Promise.all([
+      api('GET', '/v1/triggers'),
+      api('GET', '/v1/status').catch(() => ({ triggers: {} })),
+    ]);
+    const now = Date.now();
+
+    const tbody = document.getElementById('triggers');
+    tbody.replaceChildren();
+
+    for (const name of Object.keys(triggers).sort()) {
+      const t = triggers[name];
+      const st = status.triggers[name] || { delivered: 0, failed: 0 };
+      const row = document.createElement('tr');
+
+      cell(row, name);
+      cell(row, (t.target && t.target.url) || '');
+      if (st.ready === undefined) {
+        cell(row, '-');
+      } else {
+        cell(row, st.ready ? 'yes' : 'no (' + st.reason + ')', st.ready ? 'ready' : 'not-ready');
+      }
+      cell(row, st.delivered);
+      cell(row, st.failed);
+      cell(row, rate(name, st, now));
+      cell(row, st.lastError || '');
+
+      const actions = cell(row, '');
+      const button = document.createElement('button');
+      button.textContent = 'Dead letters';
+      button.onclick = () => showDeadLetters(name);
+      actions.appendChild(button);
+
+      tbody.appendChild(row);
+    }
+
+    previous = { time: now, triggers: status.triggers };
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function showDeadLetters(name) {
+  try {
+    const files = await api('GET', '/v1/deadletters/' + encodeURIComponent(name));
+    const content = document.getElementById('deadletters-content');
+    content.replaceChildren();
+
+    if (files.length === 0) {
+      content.textContent = 'The Trigger has no dead letter files configured.';
+    }
+
+    for (const f of files) {
+      const h = document.createElement('h3');
+      h.textContent = f.file + ' (' + f.events.length + ' events)';
+      content.appendChild(h);
+
+      for (const ev of f.events) {
+        const pre = document.createElement('pre');
+        pre.textContent = JSON.stringify(ev, null, 2);
+        content.appendChild(pre);
+      }
+    }
+
+    document.getElementById('deadletters-trigger').textContent = name;
+    document.getElementById('deadletters').hidden = false;
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function sendEvent(e) {
+  e.preventDefault();
+  const result = document.getElementById('send-result');
+
+  try {
+    const event = {
+      specversion: '1.0',
+      type: document.getElementById('event-type').value,
+      source: document.getElementById('event-source').value,
+      datacontenttype: 'application/json',
+    };
+
+    const exts = document.getElementById('event-extensions').value.trim();
+    if (exts) {
+      Object.assign(event, JSON.parse(exts));
+    }
+
+    const data = document.getElementById('event-data').value.trim();
+    if (data) {
+      event.data = JSON.parse(data);
+    }
+
+    const sent = await api('POST', '/v1/events', event);
+    result.textContent = 'Sent event ' + sent.id;
+    result.className = '';
+  } catch (err) {
+    result.textContent = err.message;
+    result.className = 'error';
+  }
+}
+
+function toggleLive(e) {
+  e.preventDefault();
+  const button = document.getElementById('live-toggle');
+
+  if (live) {
+    live.close();
+    return;
+  }
+
+  const params = new URLSearchParams({ access_token: token });
+  const trigger = document.getElementById('live-trigger').value.trim();
+  if (trigger) {
+    params.set('trigger', trigger);
+  }
+  const type = document.getElementById('live-type').value.trim();
+  if (type) {
+    params.set('type', type);
+  }
+  params.set('sample', document.getElementById('live-sample').value || '1');
+
+  const scheme = location.protocol === 'https:' ? 'wss:' : 'ws:';
+  live = new WebSocket(scheme + '//' + location.host + '/v1/firehose?' + params.toString());
+  button.textContent = 'Stop';
+
+  live.onmessage = (msg) => {
+    const r = JSON.parse(msg.data);
+    if (r.dropped !== undefined) {
+      document.getElementById('live-dropped').textContent = r.dropped + ' records dropped';
+      return;
+    }
+
+    const tbody = document.getElementById('live-records');
+    const row = document.createElement('tr');
+    cell(row, new Date(r.time).toLocaleTimeString());
+    cell(row, r.trigger);
+    cell(row, r.decision);
+    cell(row, r.eventType + ' ' + r.eventId);
+    cell(row, r.outcome ? r.outcome + (r.error ? ': ' + r.error : '') : '');
+    cell(row, r.latencyMs !== undefined ? r.latencyMs.toFixed(1) : '');
+    tbody.prepend(row);
+
+    while (tbody.children.length > maxLiveRecords) {
+      tbody.lastChild.remove();
+    }
+  };
+
+  live.onclose = () => {
+    live = null;
+    button.textContent = 'Start';
+  };
+}
+
+document.getElementById('token').value = token;
+document.getElementById('login').onsubmit = (e) => {
+  e.preventDefault();
+  token = document.getElementById('token').value;
+  sessionStorage.setItem('token', token);
+  previous = null;
+  refresh();
+};
+document.getElementById('send').onsubmit = sendEvent;
+document.getElementById('live').onsubmit = toggleLive;
+
+refresh();
+setInterval(refresh, refreshPeriodMs);
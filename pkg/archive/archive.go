@@ -0,0 +1,182 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package archive keeps the events ingested by the broker for a retention
+// period, so that they can be queried and re-injected.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Default range for queries that do not inform it.
+	defaultQueryRange = time.Hour
+
+	// Default and maximum number of events returned by queries.
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// Archive writes ingested events to the store. A nil archive does not
+// archive events.
+type Archive struct {
+	store     backend.EventArchive
+	retention time.Duration
+
+	logger *zap.SugaredLogger
+}
+
+// New creates an archive whose events are kept at the store for the
+// retention duration.
+func New(store backend.EventArchive, retention time.Duration, logger *zap.SugaredLogger) *Archive {
+	return &Archive{
+		store:     store,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+type reinjectedKey struct{}
+
+// ContextWithReinjected flags the context of events re-injected from the
+// archive, which are ingested again.
+func ContextWithReinjected(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reinjectedKey{}, true)
+}
+
+// IsReinjected returns true when the context flags an event re-injected
+// from the archive.
+func IsReinjected(ctx context.Context) bool {
+	v, ok := ctx.Value(reinjectedKey{}).(bool)
+	return ok && v
+}
+
+// Add archives the event. Ingestion does not fail when the event cannot
+// be archived, which is logged. Re-injected events are already archived.
+func (a *Archive) Add(ctx context.Context, event *cloudevents.Event) {
+	if a == nil || IsReinjected(ctx) {
+		return
+	}
+
+	if err := a.store.ArchiveEvent(ctx, event, a.retention); err != nil {
+		a.logger.Warnw("Could not archive CloudEvent", zap.Error(err),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+	}
+}
+
+// Query returns the archived events that match the query, oldest first.
+func (a *Archive) Query(ctx context.Context, q *backend.ArchiveQuery) ([]cloudevents.Event, error) {
+	return a.store.QueryArchive(ctx, q)
+}
+
+// ReinjectResult informs the events that were re-injected.
+type ReinjectResult struct {
+	Reinjected int      `json:"reinjected"`
+	Failed     []string `json:"failed,omitempty"`
+}
+
+// Handler serves the archived events that match the query parameters,
+// and re-injects them to the producer when receiving a POST request. The
+// producer is expected to be the ingest instance, so that re-injected
+// events are handled as any other ingested event.
+func Handler(a *Archive, producer backend.EventProducer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		q, msg := parseQuery(req)
+		if msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		events, err := a.Query(req.Context(), q)
+		if err != nil {
+			a.logger.Errorw("Could not query archived events", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "could not query archived events")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(events)
+			return
+		}
+
+		res := ReinjectResult{}
+		ctx := ContextWithReinjected(req.Context())
+		for i := range events {
+			if err := producer.Produce(ctx, &events[i]); err != nil {
+				a.logger.Errorw("Could not re-inject archived CloudEvent", zap.Error(err),
+					zap.String("type", events[i].Type()), zap.String("source", events[i].Source()), zap.String("id", events[i].ID()))
+				res.Failed = append(res.Failed, events[i].ID())
+				continue
+			}
+			res.Reinjected++
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+// parseQuery returns the query informed at the request parameters, or a
+// message describing why it is not valid.
+func parseQuery(req *http.Request) (*backend.ArchiveQuery, string) {
+	p := req.URL.Query()
+	q := &backend.ArchiveQuery{
+		To:     time.Now(),
+		Type:   p.Get("type"),
+		Source: p.Get("source"),
+		ID:     p.Get("id"),
+		Limit:  defaultQueryLimit,
+	}
+
+	if v := p.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, "to parameter is not an RFC3339 time"
+		}
+		q.To = t
+	}
+
+	q.From = q.To.Add(-defaultQueryRange)
+	if v := p.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, "from parameter is not an RFC3339 time"
+		}
+		q.From = t
+	}
+
+	if !q.From.Before(q.To) {
+		return nil, "from parameter must be before to parameter"
+	}
+
+	if v := p.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxQueryLimit {
+			return nil, "limit parameter must be a number between 1 and " + strconv.Itoa(maxQueryLimit)
+		}
+		q.Limit = n
+	}
+
+	return q, ""
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fakeStore struct {
+	events []cloudevents.Event
+	query  *backend.ArchiveQuery
+	err    error
+}
+
+func (f *fakeStore) ArchiveEvent(_ context.Context, event *cloudevents.Event, _ time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func (f *fakeStore) QueryArchive(_ context.Context, q *backend.ArchiveQuery) ([]cloudevents.Event, error) {
+	f.query = q
+	res := []cloudevents.Event{}
+	for i := range f.events {
+		if q.Matches(&f.events[i]) && len(res) < q.Limit {
+			res = append(res, f.events[i])
+		}
+	}
+	return res, nil
+}
+
+// fakeProducer archives the events it produces, as ingest does.
+type fakeProducer struct {
+	archive    *Archive
+	produced   []string
+	reinjected int
+}
+
+func (f *fakeProducer) Produce(ctx context.Context, event *cloudevents.Event) error {
+	if event.ID() == "fail" {
+		return errors.New("backend is down")
+	}
+	f.produced = append(f.produced, event.ID())
+	if IsReinjected(ctx) {
+		f.reinjected++
+	}
+	f.archive.Add(ctx, event)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	store := &fakeStore{}
+	a := New(store, time.Hour, zap.NewNop().Sugar())
+	for _, id := range []string{"e1", "e2", "fail"} {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id), lib.CloudEventWithTypeOption("order.created"))
+		a.Add(context.Background(), &ev)
+	}
+	other := lib.NewCloudEvent(lib.CloudEventWithIDOption("e3"), lib.CloudEventWithTypeOption("order.paid"))
+	a.Add(context.Background(), &other)
+
+	producer := &fakeProducer{archive: a}
+	h := Handler(a, producer)
+
+	t.Run("query", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+			"/v1/archive?type=order.created&limit=2&from=2023-03-01T00:00:00Z&to=2023-03-02T00:00:00Z", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var events []cloudevents.Event
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+		require.Len(t, events, 2)
+		assert.Equal(t, "e1", events[0].ID())
+		assert.Equal(t, "e2", events[1].ID())
+
+		assert.Equal(t, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), store.query.From)
+		assert.Equal(t, time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC), store.query.To)
+	})
+
+	t.Run("default range", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/archive", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, defaultQueryRange, store.query.To.Sub(store.query.From))
+		assert.Equal(t, defaultQueryLimit, store.query.Limit)
+	})
+
+	t.Run("reinject", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/archive?type=order.created", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res ReinjectResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, ReinjectResult{Reinjected: 2, Failed: []string{"fail"}}, res)
+		assert.Equal(t, []string{"e1", "e2"}, producer.produced)
+		assert.Equal(t, 2, producer.reinjected, "Re-injected events must be flagged")
+		assert.Len(t, store.events, 4, "Re-injected events must not be archived again")
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		for _, q := range []string{"from=yesterday", "limit=0", "from=2023-03-02T00:00:00Z&to=2023-03-01T00:00:00Z"} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/archive?"+q, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "Query %q", q)
+		}
+	})
+}
+
+func TestAddFailure(t *testing.T) {
+	a := New(&fakeStore{err: errors.New("archive is full")}, time.Hour, zap.NewNop().Sugar())
+	ev := lib.NewCloudEvent()
+	assert.NotPanics(t, func() { a.Add(context.Background(), &ev) })
+
+	var disabled *Archive
+	assert.NotPanics(t, func() { disabled.Add(context.Background(), &ev) })
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Outcome string
+
+const (
+	// OutcomeDelivered is set when the target acknowledged the event.
+	OutcomeDelivered Outcome = "delivered"
+	// OutcomeRejected is set when the target did not acknowledge the event.
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeUndelivered is set when the event could not reach the target.
+	OutcomeUndelivered Outcome = "undelivered"
+	// OutcomeUnknown is set when the delivery outcome could not be determined.
+	OutcomeUnknown Outcome = "unknown"
+	// OutcomeDryRun is set when the event matched a dry run trigger, which
+	// does not deliver it.
+	OutcomeDryRun Outcome = "dry-run"
+)
+
+const (
+	// CloudEvents type for audit records sent to a CloudEvents sink.
+	RecordEventType = "io.triggermesh.broker.audit.delivery"
+
+	// Size of the queue for records pending to be sent.
+	cloudEventsSinkQueueSize = 1000
+)
+
+// Record of a delivery attempt.
+type Record struct {
+	Time        time.Time `json:"time"`
+	EventID     string    `json:"eventId"`
+	EventSource string    `json:"eventSource"`
+	EventType   string    `json:"eventType"`
+	Trigger     string    `json:"trigger"`
+	Target      string    `json:"target"`
+	Attempts    int       `json:"attempts"`
+	Outcome     Outcome   `json:"outcome"`
+	LatencyMs   float64   `json:"latencyMs"`
+	Error       string    `json:"error,omitempty"`
+
+	// Provenance lists the broker instances the event was ingested by.
+	Provenance []string `json:"provenance,omitempty"`
+}
+
+// Sink receives audit records.
+type Sink interface {
+	Write(*Record)
+}
+
+// NewSink creates an audit sink from its URI. Supported values are
+// "stdout", an absolute path scheme prefixed with "file://", and HTTP(S)
+// URLs that will receive records as CloudEvents.
+func NewSink(ctx context.Context, uri string, source string, logger *zap.SugaredLogger) (Sink, error) {
+	if uri == "stdout" {
+		return &writerSink{w: os.Stdout, logger: logger}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("audit sink %q cannot be parsed: %w", uri, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		f, err := os.OpenFile(u.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("could not open audit file: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			f.Close()
+		}()
+		return &writerSink{w: f, logger: logger}, nil
+
+	case "http", "https":
+		return newCloudEventsSink(ctx, uri, source, logger)
+	}
+
+	return nil, errors.New("audit sink must be stdout, a file:// path or an HTTP URL")
+}
+
+// writerSink writes records as JSON lines.
+type writerSink struct {
+	w      io.Writer
+	logger *zap.SugaredLogger
+	m      sync.Mutex
+}
+
+func (s *writerSink) Write(r *Record) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		s.logger.Errorw("Could not serialize audit record", zap.Error(err))
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, err = s.w.Write(append(b, '\n')); err != nil {
+		s.logger.Errorw("Could not write audit record", zap.Error(err))
+	}
+}
+
+// cloudEventsSink sends records asynchronously as CloudEvents.
+type cloudEventsSink struct {
+	client cloudevents.Client
+	source string
+	queue  chan *Record
+	logger *zap.SugaredLogger
+}
+
+func newCloudEventsSink(ctx context.Context, uri, source string, logger *zap.SugaredLogger) (*cloudEventsSink, error) {
+	c, err := cloudevents.NewClientHTTP(cloudevents.WithTarget(uri))
+	if err != nil {
+		return nil, fmt.Errorf("could not create CloudEvents client for audit: %w", err)
+	}
+
+	s := &cloudEventsSink{
+		client: c,
+		source: source,
+		queue:  make(chan *Record, cloudEventsSinkQueueSize),
+		logger: logger,
+	}
+
+	go s.run(ctx)
+
+	return s, nil
+}
+
+func (s *cloudEventsSink) Write(r *Record) {
+	select {
+	case s.queue <- r:
+	default:
+		s.logger.Warnw("Audit record discarded due to full queue", zap.String("id", r.EventID), zap.String("trigger", r.Trigger))
+	}
+}
+
+func (s *cloudEventsSink) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-s.queue:
+			event := cloudevents.NewEvent()
+			event.SetID(uuid.New().String())
+			event.SetType(RecordEventType)
+			event.SetSource(s.source)
+			event.SetSubject(r.Trigger)
+			event.SetTime(r.Time)
+			if err := event.SetData(cloudevents.ApplicationJSON, r); err != nil {
+				s.logger.Errorw("Could not serialize audit record", zap.Error(err))
+				continue
+			}
+
+			if res := s.client.Send(ctx, event); !cloudevents.IsACK(res) {
+				s.logger.Errorw("Could not send audit record", zap.Error(res), zap.String("id", r.EventID), zap.String("trigger", r.Trigger))
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewSink(t *testing.T) {
+	dir := t.TempDir()
+
+	tcs := map[string]struct {
+		uri     string
+		isError bool
+	}{
+		"stdout": {
+			uri: "stdout",
+		},
+		"file": {
+			uri: "file://" + filepath.Join(dir, "audit.jsonl"),
+		},
+		"file not writable": {
+			uri:     "file://" + filepath.Join(dir, "missing", "audit.jsonl"),
+			isError: true,
+		},
+		"http": {
+			uri: "http://localhost:8080",
+		},
+		"unsupported scheme": {
+			uri:     "kafka://localhost:9092",
+			isError: true,
+		},
+		"not parseable": {
+			uri:     "http://local host:\n",
+			isError: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s, err := NewSink(ctx, tc.uri, "test", zap.NewNop().Sugar())
+			if tc.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, s)
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := NewSink(ctx, "file://"+path, "test", zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	records := []*Record{
+		{EventID: "1", Trigger: "t1", Attempts: 1, Outcome: OutcomeDelivered},
+		{EventID: "2", Trigger: "t1", Attempts: 3, Outcome: OutcomeRejected, Error: "500"},
+	}
+	for _, r := range records {
+		s.Write(r)
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var read []*Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := &Record{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), r))
+		read = append(read, r)
+	}
+	assert.Equal(t, records, read)
+}
+
+func TestCloudEventsSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan cloudevents.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := cehttp.NewEventFromHTTPRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- *event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(ctx, srv.URL, "test-broker", zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	s.Write(&Record{EventID: "1", Trigger: "t1", Attempts: 1, Outcome: OutcomeDelivered})
+
+	select {
+	case event := <-received:
+		assert.Equal(t, RecordEventType, event.Type())
+		assert.Equal(t, "test-broker", event.Source())
+		assert.Equal(t, "t1", event.Subject())
+
+		r := &Record{}
+		require.NoError(t, event.DataAs(r))
+		assert.Equal(t, "1", r.EventID)
+		assert.Equal(t, OutcomeDelivered, r.Outcome)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Audit record was not received")
+	}
+}
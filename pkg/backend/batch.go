@@ -0,0 +1,143 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// ProduceBatcher groups the events produced concurrently, producing them
+// to the backend at once when the batch reaches its maximum size or when
+// the oldest event waited for the maximum delay. Callers block until the
+// batch their event belongs to is produced, receiving its outcome.
+type ProduceBatcher struct {
+	producer EventProducer
+	size     int
+	delay    time.Duration
+
+	pending []*batchedEvent
+	timer   *time.Timer
+	// Once closed events are produced right away.
+	closed bool
+	// Batches being produced.
+	flushing sync.WaitGroup
+
+	m sync.Mutex
+}
+
+type batchedEvent struct {
+	event *cloudevents.Event
+	done  chan error
+}
+
+// NewProduceBatcher returns a batcher that produces to the producer, using
+// the BatchProducer interface when implemented.
+func NewProduceBatcher(p EventProducer, size int, delay time.Duration) *ProduceBatcher {
+	return &ProduceBatcher{
+		producer: p,
+		size:     size,
+		delay:    delay,
+	}
+}
+
+// Produce adds the event to the ongoing batch and waits for it to be
+// produced. Since batches mix events of different callers, the context is
+// only used once the batcher is closed.
+func (b *ProduceBatcher) Produce(ctx context.Context, event *cloudevents.Event) error {
+	b.m.Lock()
+	if b.closed {
+		b.m.Unlock()
+		return b.producer.Produce(ctx, event)
+	}
+
+	be := &batchedEvent{event: event, done: make(chan error, 1)}
+	b.pending = append(b.pending, be)
+
+	var batch []*batchedEvent
+	switch {
+	case len(b.pending) >= b.size:
+		batch = b.take()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.delay, b.flushPending)
+	}
+	b.m.Unlock()
+
+	if batch != nil {
+		b.produce(batch)
+	}
+
+	return <-be.done
+}
+
+// Close produces the ongoing batch and waits for the batches being
+// produced. Events produced after closing are not batched.
+func (b *ProduceBatcher) Close() {
+	b.m.Lock()
+	b.closed = true
+	batch := b.take()
+	b.m.Unlock()
+
+	if batch != nil {
+		b.produce(batch)
+	}
+	b.flushing.Wait()
+}
+
+func (b *ProduceBatcher) flushPending() {
+	b.m.Lock()
+	batch := b.take()
+	b.m.Unlock()
+
+	if batch != nil {
+		b.produce(batch)
+	}
+}
+
+// take returns the ongoing batch, if any, and starts a new one. It must be
+// called holding the lock.
+func (b *ProduceBatcher) take() []*batchedEvent {
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	batch := b.pending
+	b.pending = nil
+	b.flushing.Add(1)
+	return batch
+}
+
+func (b *ProduceBatcher) produce(batch []*batchedEvent) {
+	defer b.flushing.Done()
+
+	// The backend must be reachable after the callers contexts are done,
+	// as it happens when shutting down.
+	ctx := context.Background()
+
+	bp, ok := b.producer.(BatchProducer)
+	if !ok || len(batch) == 1 {
+		for _, be := range batch {
+			be.done <- b.producer.Produce(ctx, be.event)
+		}
+		return
+	}
+
+	events := make([]*cloudevents.Event, len(batch))
+	for i, be := range batch {
+		events[i] = be.event
+	}
+
+	errs := bp.ProduceBatch(ctx, events)
+	for i, be := range batch {
+		be.done <- errs[i]
+	}
+}
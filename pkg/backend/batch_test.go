@@ -0,0 +1,118 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBatchProducer struct {
+	batches  [][]string
+	produced []string
+	m        sync.Mutex
+}
+
+func (p *fakeBatchProducer) Produce(_ context.Context, e *cloudevents.Event) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.produced = append(p.produced, e.ID())
+	return nil
+}
+
+func (p *fakeBatchProducer) ProduceBatch(_ context.Context, events []*cloudevents.Event) []error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	ids := make([]string, 0, len(events))
+	errs := make([]error, len(events))
+	for i, e := range events {
+		ids = append(ids, e.ID())
+		if e.Type() == "fail" {
+			errs[i] = errors.New("rejected")
+		}
+	}
+	p.batches = append(p.batches, ids)
+	return errs
+}
+
+func newBatchedEvent(id, typ string) *cloudevents.Event {
+	e := cloudevents.NewEvent()
+	e.SetID(id)
+	e.SetType(typ)
+	e.SetSource("test")
+	return &e
+}
+
+func TestProduceBatcher(t *testing.T) {
+	p := &fakeBatchProducer{}
+	b := NewProduceBatcher(p, 3, time.Hour)
+
+	errs := make([]error, 3)
+	var wg sync.WaitGroup
+	for i, typ := range []string{"ok", "fail", "ok"} {
+		wg.Add(1)
+		go func(i int, typ string) {
+			defer wg.Done()
+			errs[i] = b.Produce(context.Background(), newBatchedEvent(string(rune('a'+i)), typ))
+		}(i, typ)
+	}
+	wg.Wait()
+
+	assert.Len(t, p.batches, 1, "Full batches must be produced at once")
+	assert.Len(t, p.batches[0], 3)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1], "Each caller must receive the outcome of its event")
+	assert.NoError(t, errs[2])
+
+	// Batches that do not fill are produced when closing.
+	done := make(chan error)
+	go func() {
+		done <- b.Produce(context.Background(), newBatchedEvent("d", "ok"))
+	}()
+	assert.Eventually(t, func() bool {
+		b.m.Lock()
+		defer b.m.Unlock()
+		return len(b.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	b.Close()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"d"}, p.produced)
+
+	// Events produced after closing are not batched.
+	assert.NoError(t, b.Produce(context.Background(), newBatchedEvent("e", "ok")))
+	assert.Equal(t, []string{"d", "e"}, p.produced)
+	assert.Len(t, p.batches, 1)
+}
+
+func TestProduceBatcherDelay(t *testing.T) {
+	p := &fakeBatchProducer{}
+	b := NewProduceBatcher(p, 100, 20*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, b.Produce(context.Background(), newBatchedEvent(string(rune('a'+i)), "ok")))
+		}(i)
+	}
+	wg.Wait()
+
+	// Events that arrive after the first batch was produced start another.
+	p.m.Lock()
+	defer p.m.Unlock()
+	n := len(p.produced)
+	for _, batch := range p.batches {
+		n += len(batch)
+	}
+	assert.Equal(t, 2, n, "Batches must be produced after the delay")
+}
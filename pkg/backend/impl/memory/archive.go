@@ -0,0 +1,78 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+var _ backend.EventArchive = (*memory)(nil)
+
+// archivedEvents keeps the archived events in memory, ordered by the
+// time they were archived at.
+type archivedEvents struct {
+	entries []archivedEvent
+	m       sync.Mutex
+
+	// now is replaced at tests.
+	now func() time.Time
+}
+
+type archivedEvent struct {
+	at      time.Time
+	expires time.Time
+	event   cloudevents.Event
+}
+
+func (s *memory) ArchiveEvent(ctx context.Context, event *cloudevents.Event, retention time.Duration) error {
+	a := &s.archive
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+
+	// Entries share the retention, hence expire in order.
+	expired := 0
+	for expired < len(a.entries) && now.After(a.entries[expired].expires) {
+		expired++
+	}
+	a.entries = append(a.entries[expired:], archivedEvent{
+		at:      now,
+		expires: now.Add(retention),
+		event:   event.Clone(),
+	})
+
+	return nil
+}
+
+func (s *memory) QueryArchive(ctx context.Context, q *backend.ArchiveQuery) ([]cloudevents.Event, error) {
+	a := &s.archive
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	events := []cloudevents.Event{}
+	for i := range a.entries {
+		e := &a.entries[i]
+		if e.at.Before(q.From) {
+			continue
+		}
+		if e.at.After(q.To) || len(events) == q.Limit {
+			break
+		}
+		if q.Matches(&e.event) {
+			events = append(events, e.event.Clone())
+		}
+	}
+
+	return events, nil
+}
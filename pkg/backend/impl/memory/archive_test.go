@@ -0,0 +1,54 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestArchive(t *testing.T) {
+	s := &memory{}
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.archive.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for _, id := range []string{"e1", "e2", "e3", "e4"} {
+		typ := "type.a"
+		if id == "e2" {
+			typ = "type.b"
+		}
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id), lib.CloudEventWithTypeOption(typ))
+		require.NoError(t, s.ArchiveEvent(ctx, &ev, time.Hour))
+		now = now.Add(10 * time.Minute)
+	}
+
+	ids := func(q *backend.ArchiveQuery) []string {
+		events, err := s.QueryArchive(ctx, q)
+		require.NoError(t, err)
+		res := []string{}
+		for _, e := range events {
+			res = append(res, e.ID())
+		}
+		return res
+	}
+
+	from := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"e1", "e2", "e3", "e4"}, ids(&backend.ArchiveQuery{From: from, To: now, Limit: 10}))
+	assert.Equal(t, []string{"e1", "e3"}, ids(&backend.ArchiveQuery{From: from, To: now, Type: "type.a", Limit: 2}))
+	assert.Equal(t, []string{"e2", "e3"}, ids(&backend.ArchiveQuery{From: from.Add(5 * time.Minute), To: from.Add(20 * time.Minute), Limit: 10}))
+
+	// Archiving discards the expired events.
+	now = now.Add(45 * time.Minute)
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("e5"))
+	require.NoError(t, s.ArchiveEvent(ctx, &ev, time.Hour))
+	assert.Equal(t, []string{"e4", "e5"}, ids(&backend.ArchiveQuery{From: from, To: now, Limit: 10}))
+}
@@ -0,0 +1,30 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+var _ backend.BacklogReporter = (*memory)(nil)
+
+// Backlog returns the events at the buffer for every subscription, since
+// buffered events are dispatched to all of them at once. Scheduled events
+// are not pending until their delivery time.
+func (s *memory) Backlog(_ context.Context, names []string) (map[string]int64, error) {
+	n := int64(len(s.buffer))
+
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	backlog := make(map[string]int64, len(names))
+	for _, name := range names {
+		if _, ok := s.ccbs[name]; ok {
+			backlog[name] = n
+		}
+	}
+	return backlog, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestProduceBusy(t *testing.T) {
+	ctx := context.Background()
+	s := New(&MemoryArgs{BufferSize: 1, ProduceTimeout: "PT0.01S", ProduceTimeoutDuration: 10 * time.Millisecond},
+		zaptest.NewLogger(t).Sugar())
+	require.NoError(t, s.Init(ctx))
+
+	ev := lib.NewCloudEvent()
+	require.NoError(t, s.Produce(ctx, &ev))
+
+	// The buffer is full while the backend is not started.
+	err := s.Produce(ctx, &ev)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, backend.ErrBackendBusy), "Full buffers must inform the backend is busy")
+}
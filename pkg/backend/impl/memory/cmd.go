@@ -15,7 +15,14 @@ type MemoryArgs struct {
 	BufferSize     int    `help:"Number of events that can be hosted in the backend." env:"BUFFER_SIZE" default:"10000"`
 	ProduceTimeout string `help:"Maximum wait time for producing an event to the backend." env:"PRODUCE_TIMEOUT" default:"PT5S"`
 
-	ProduceTimeoutDuration time.Duration `kong:"-"`
+	PersistencePath string `help:"Path to the file where buffered events are persisted to survive restarts. Persistence is disabled if empty." env:"PERSISTENCE_PATH"`
+	SnapshotPeriod  string `help:"Period for compacting persisted events into a snapshot using ISO8601." env:"SNAPSHOT_PERIOD" default:"PT1M"`
+
+	RedeliveryDelay string `help:"Time before events not acknowledged by a subscription are dispatched to it again using ISO8601." env:"REDELIVERY_DELAY" default:"PT30S"`
+
+	ProduceTimeoutDuration  time.Duration `kong:"-"`
+	SnapshotPeriodDuration  time.Duration `kong:"-"`
+	RedeliveryDelayDuration time.Duration `kong:"-"`
 }
 
 func (ma *MemoryArgs) Validate() error {
@@ -40,6 +47,27 @@ func (ma *MemoryArgs) Validate() error {
 		}
 	}
 
+	if ma.PersistencePath != "" && ma.SnapshotPeriod != "" {
+		p, err := period.Parse(ma.SnapshotPeriod)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Snapshot period is not an ISO8601 duration: %v", err))
+		} else {
+			ma.SnapshotPeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if ma.RedeliveryDelay != "" {
+		p, err := period.Parse(ma.RedeliveryDelay)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Redelivery delay is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() <= 0:
+			msg = append(msg, "Redelivery delay must be greater than zero.")
+		default:
+			ma.RedeliveryDelayDuration = p.DurationApprox()
+		}
+	}
+
 	if len(msg) == 0 {
 		return nil
 	}
@@ -0,0 +1,59 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Period for removing expired keys.
+const dedupSweepPeriod = time.Minute
+
+var _ backend.Deduplicator = (*memory)(nil)
+
+// dedupKeys keeps the expiration time for produced event keys.
+type dedupKeys struct {
+	keys      map[string]time.Time
+	lastSweep time.Time
+	m         sync.Mutex
+}
+
+func (s *memory) MarkProduced(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.dedup.m.Lock()
+	defer s.dedup.m.Unlock()
+
+	now := time.Now()
+	if s.dedup.keys == nil {
+		s.dedup.keys = make(map[string]time.Time)
+		s.dedup.lastSweep = now
+	}
+
+	if now.Sub(s.dedup.lastSweep) > dedupSweepPeriod {
+		for k, exp := range s.dedup.keys {
+			if now.After(exp) {
+				delete(s.dedup.keys, k)
+			}
+		}
+		s.dedup.lastSweep = now
+	}
+
+	if exp, ok := s.dedup.keys[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+
+	s.dedup.keys[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *memory) UnmarkProduced(ctx context.Context, key string) error {
+	s.dedup.m.Lock()
+	defer s.dedup.m.Unlock()
+
+	delete(s.dedup.keys, key)
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeduplication(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := map[string]struct {
+		ttl      time.Duration
+		unmark   bool
+		expected bool
+	}{
+		"duplicated within the ttl":   {ttl: time.Hour, expected: false},
+		"produced again after expiry": {ttl: -time.Second, expected: true},
+		"unmarked":                    {ttl: time.Hour, unmark: true, expected: true},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			s := New(&MemoryArgs{}, zap.NewNop().Sugar()).(*memory)
+
+			ok, err := s.MarkProduced(ctx, "key", tc.ttl)
+			require.NoError(t, err)
+			require.True(t, ok, "Keys must be produced the first time")
+
+			if tc.unmark {
+				require.NoError(t, s.UnmarkProduced(ctx, "key"))
+			}
+
+			ok, err = s.MarkProduced(ctx, "key", tc.ttl)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ok)
+
+			ok, err = s.MarkProduced(ctx, "other", tc.ttl)
+			require.NoError(t, err)
+			assert.True(t, ok, "Keys must be tracked independently")
+		})
+	}
+}
+
+func TestDeduplicationSweep(t *testing.T) {
+	ctx := context.Background()
+	s := New(&MemoryArgs{}, zap.NewNop().Sugar()).(*memory)
+
+	_, err := s.MarkProduced(ctx, "expired", -time.Second)
+	require.NoError(t, err)
+	s.dedup.lastSweep = time.Now().Add(-2 * dedupSweepPeriod)
+
+	_, err = s.MarkProduced(ctx, "live", time.Hour)
+	require.NoError(t, err)
+	assert.NotContains(t, s.dedup.keys, "expired", "Expired keys must be swept")
+	assert.Contains(t, s.dedup.keys, "live")
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Suffix added to the persistence path for the recorded deliveries file.
+const fixturesFileSuffix = ".fixtures"
+
+var _ backend.FixtureStore = (*memory)(nil)
+
+// fixtureDeliveries keeps the deliveries recorded for each trigger, indexed
+// by event key, which are written to a file when persistence is enabled.
+type fixtureDeliveries struct {
+	triggers map[string]map[string]json.RawMessage
+	path     string
+	m        sync.Mutex
+}
+
+// load reads the recorded deliveries file, if it exists.
+func (f *fixtureDeliveries) load(path string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.path = path
+	f.triggers = make(map[string]map[string]json.RawMessage)
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("could not read fixtures file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &f.triggers); err != nil {
+		return fmt.Errorf("could not parse fixtures file: %w", err)
+	}
+
+	return nil
+}
+
+// save writes the recorded deliveries file, replacing the previous one.
+func (f *fixtureDeliveries) save() error {
+	b, err := json.Marshal(f.triggers)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("could not write fixtures file: %w", err)
+	}
+
+	return os.Rename(tmp, f.path)
+}
+
+func (s *memory) RecordFixture(ctx context.Context, trigger, key string, delivery []byte) error {
+	f := &s.fixtures
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.triggers == nil {
+		f.triggers = make(map[string]map[string]json.RawMessage)
+	}
+
+	deliveries, ok := f.triggers[trigger]
+	if !ok {
+		deliveries = make(map[string]json.RawMessage)
+		f.triggers[trigger] = deliveries
+	}
+	deliveries[key] = append(json.RawMessage(nil), delivery...)
+
+	if f.path == "" {
+		return nil
+	}
+
+	return f.save()
+}
+
+func (s *memory) Fixture(ctx context.Context, trigger, key string) ([]byte, error) {
+	f := &s.fixtures
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	d, ok := f.triggers[trigger][key]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]byte(nil), d...), nil
+}
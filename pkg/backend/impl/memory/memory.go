@@ -8,58 +8,211 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"go.uber.org/zap"
 
 	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/metrics"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
 )
 
 func New(args *MemoryArgs, logger *zap.SugaredLogger) backend.Interface {
 	return &memory{
-		ccbs:    make(map[string]backend.ConsumerDispatcher),
-		closing: false,
-		args:    args,
-		logger:  logger,
+		ccbs:      make(map[string]backend.ConsumerDispatcher),
+		paused:    make(map[string]*heldEvents),
+		scheduled: newScheduler(),
+		args:      args,
+		logger:    logger,
 	}
 }
 
+// bufferedEvent wraps events at the buffer along with the sequence
+// number at the write ahead log, when persistence is enabled.
+type bufferedEvent struct {
+	seq   uint64
+	event *cloudevents.Event
+	// Delivery time for scheduled events.
+	at time.Time
+
+	// Time the event was produced, and its serialized size when the
+	// retention limits the buffered bytes.
+	produced time.Time
+	size     int64
+}
+
 type memory struct {
 	args *MemoryArgs
 
 	ccbs    map[string]backend.ConsumerDispatcher
-	closing bool
-	buffer  chan *cloudevents.Event
-	logger  *zap.SugaredLogger
-	m       sync.RWMutex
+	closing atomic.Bool
+	buffer  chan bufferedEvent
+
+	// wal is only set when persistence is enabled.
+	wal *wal
+	// cipher encrypts the events persisted at the write ahead log.
+	cipher *encryption.Cipher
+
+	// Events held until their delivery time.
+	scheduled *scheduler
+
+	// Limits of the buffered events, along with the buffer size.
+	retention     backend.Retention
+	bufferedBytes atomic.Int64
+
+	// Events held for paused subscriptions, indexed by name.
+	paused map[string]*heldEvents
+	pm     sync.Mutex
+
+	dedup dedupKeys
+
+	// Hourly throughput counters.
+	throughput throughputCounters
+
+	// Deliveries recorded for triggers.
+	fixtures fixtureDeliveries
+
+	// Events archived since ingested.
+	archive archivedEvents
+
+	reporter metrics.Reporter
+	logger   *zap.SugaredLogger
+	m        sync.RWMutex
 }
 
 func (s *memory) Info() *backend.Info {
 	return &backend.Info{
-		Name: "Memory",
+		Name:    "Memory",
+		Durable: s.args.PersistencePath != "",
 	}
 }
 
+// SetCipher encrypts the events persisted at the write ahead log.
+func (s *memory) SetCipher(c *encryption.Cipher) {
+	s.cipher = c
+}
+
 func (s *memory) Init(ctx context.Context) error {
-	s.buffer = make(chan *cloudevents.Event, s.args.BufferSize)
+	reporter, err := metrics.NewReporter(ctx, s.Info().Name, s.logger)
+	if err != nil {
+		return fmt.Errorf("could not setup backend stats reporter: %w", err)
+	}
+
+	s.reporter = reporter
+
+	if s.args.PersistencePath == "" {
+		s.buffer = make(chan bufferedEvent, s.args.BufferSize)
+		return nil
+	}
+
+	w, pending, err := openWAL(s.args.PersistencePath, s.cipher)
+	if err != nil {
+		return err
+	}
+	s.wal = w
+
+	if err := s.throughput.load(s.args.PersistencePath + throughputFileSuffix); err != nil {
+		return err
+	}
+
+	if err := s.fixtures.load(s.args.PersistencePath + fixturesFileSuffix); err != nil {
+		return err
+	}
+
+	// Make sure all recovered events fit in the buffer.
+	size := s.args.BufferSize
+	if len(pending) > size {
+		s.logger.Warnf("Recovered %d events exceed the buffer size %d", len(pending), size)
+		size = len(pending)
+	}
+
+	s.buffer = make(chan bufferedEvent, size)
+	now := time.Now()
+	for _, be := range pending {
+		if be.at.After(now) {
+			s.scheduled.add(be)
+			continue
+		}
+		s.buffer <- be
+	}
+
+	if len(pending) != 0 {
+		s.logger.Infof("Recovered %d pending events from %s", len(pending), s.args.PersistencePath)
+	}
+
 	return nil
 }
 
 func (s *memory) Produce(ctx context.Context, event *cloudevents.Event) error {
-	if s.closing {
+	if s.closing.Load() {
 		return errors.New("rejecting events due to backend closing")
 	}
 
+	start := time.Now()
+	be := bufferedEvent{event: event, produced: start}
+
+	// Invalid scheduling extensions are expected to be rejected at ingest,
+	// events that inform them are delivered right away.
+	if at, ok, _ := backend.ScheduledTime(event, start); ok {
+		be.at = at
+	}
+
+	if s.wal != nil {
+		seq, err := s.wal.append(event, be.at)
+		if err != nil {
+			s.reporter.ReportOperation("produce", false, float64(time.Since(start)/time.Millisecond))
+			return fmt.Errorf("failed to persist the event: %w", err)
+		}
+		be.seq = seq
+	}
+
+	if !be.at.IsZero() {
+		s.scheduled.add(be)
+		s.reporter.ReportOperation("schedule", true, float64(time.Since(start)/time.Millisecond))
+		return nil
+	}
+
+	if s.retention.Limited() {
+		s.retain(&be)
+	}
+
 	select {
 	case <-time.After(s.args.ProduceTimeoutDuration):
-		return fmt.Errorf("failed to add the event to the buffer after %s", s.args.ProduceTimeout)
-	case s.buffer <- event:
+		if s.wal != nil {
+			// The event was rejected, remove it from the log.
+			if err := s.wal.ack(be.seq); err != nil {
+				s.logger.Errorw("Could not remove rejected event from the write ahead log", zap.Error(err))
+			}
+		}
+		s.reporter.ReportOperation("produce", false, float64(time.Since(start)/time.Millisecond))
+		return fmt.Errorf("failed to add the event to the buffer after %s: %w", s.args.ProduceTimeout, backend.ErrBackendBusy)
+	case s.buffer <- be:
+		s.bufferedBytes.Add(be.size)
 	}
+
+	s.reporter.ReportOperation("produce", true, float64(time.Since(start)/time.Millisecond))
 	return nil
 }
 
-func (s *memory) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
+// Subscribe adds the consumer dispatcher to the subscriptions. Events are
+// dispatched sequentially, which already honors the ordering key option.
+// Dispatched events are not retained, subscriptions always start from the
+// next event.
+func (s *memory) Subscribe(name string, ccb backend.ConsumerDispatcher, opts ...backend.SubscribeOption) error {
+	so := backend.NewSubscribeOptions(opts...)
+	if so.StartingOffset == backend.StartingOffsetEarliest {
+		s.logger.Warnw("Memory backend does not retain dispatched events, the subscription starts from the next event",
+			zap.String("subscription", name))
+	}
+
+	if so.Paused {
+		if err := s.PauseSubscription(name); err != nil {
+			return err
+		}
+	}
+
 	s.m.Lock()
 	defer s.m.Unlock()
 	s.ccbs[name] = ccb
@@ -69,30 +222,76 @@ func (s *memory) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
 
 func (s *memory) Unsubscribe(name string) {
 	s.m.Lock()
-	defer s.m.Unlock()
 	delete(s.ccbs, name)
+	s.m.Unlock()
+
+	s.discardHeld(name)
 }
 
 func (s *memory) Start(ctx context.Context) error {
+	// Snapshots are only needed when persistence is enabled.
+	var snapshotCh <-chan time.Time
+	if s.wal != nil && s.args.SnapshotPeriodDuration > 0 {
+		ticker := time.NewTicker(s.args.SnapshotPeriodDuration)
+		defer ticker.Stop()
+		snapshotCh = ticker.C
+	}
+
+	// Release scheduled events to the buffer when they are due.
+	schedCtx, schedCancel := context.WithCancel(context.Background())
+	defer schedCancel()
+	schedDone := make(chan struct{})
 	go func() {
-		<-ctx.Done()
-		s.closing = true
+		defer close(schedDone)
+		s.scheduled.run(schedCtx, func(be bufferedEvent) {
+			select {
+			case s.buffer <- be:
+			case <-schedCtx.Done():
+				// keep the event scheduled if the buffer is not being
+				// consumed anymore.
+				s.scheduled.add(be)
+			}
+		})
 	}()
 
 	closing := false
 
 	for {
 		select {
-		case event := <-s.buffer:
-			s.fanOut(event)
+		case be := <-s.buffer:
+			s.fanOut(be)
+		case <-snapshotCh:
+			if err := s.wal.snapshot(); err != nil {
+				s.logger.Errorw("Could not snapshot the write ahead log", zap.Error(err))
+			}
 		case <-ctx.Done():
 			// signal to reject new events being produced
-			s.closing = true
+			s.closing.Store(true)
+
+			// stop releasing scheduled events before closing the buffer.
+			schedCancel()
+			<-schedDone
 			close(s.buffer)
 
+			if n := s.heldLen(); n != 0 {
+				if s.wal != nil {
+					s.logger.Infof("%d events held for paused subscriptions will be recovered from %s", n, s.args.PersistencePath)
+				} else {
+					s.logger.Warnf("%d events held for paused subscriptions were lost", n)
+				}
+			}
+
+			if n := s.scheduled.len(); n != 0 {
+				if s.wal != nil {
+					s.logger.Infof("%d scheduled events will be recovered from %s", n, s.args.PersistencePath)
+				} else {
+					s.logger.Warnf("%d scheduled events were lost", n)
+				}
+			}
+
 			// loop all remaining elements from the channel
-			for event := range s.buffer {
-				s.fanOut(event)
+			for be := range s.buffer {
+				s.fanOut(be)
 			}
 			closing = true
 		}
@@ -101,14 +300,59 @@ func (s *memory) Start(ctx context.Context) error {
 			break
 		}
 	}
+
+	if s.wal != nil {
+		if err := s.wal.snapshot(); err != nil {
+			s.logger.Errorw("Could not snapshot the write ahead log", zap.Error(err))
+		}
+		return s.wal.close()
+	}
+
 	return nil
 }
 
-func (s *memory) fanOut(event *cloudevents.Event) {
+func (s *memory) fanOut(be bufferedEvent) {
+	start := time.Now()
+
+	if s.expired(be, start) {
+		s.trim(be, backend.TrimReasonAge)
+		return
+	}
+	s.bufferedBytes.Add(-be.size)
+
+	// The fan out keeps a reference to the event until it is dispatched to
+	// or held for every subscription.
+	r := &redelivery{be: be, pending: 1}
+
 	s.m.RLock()
-	defer s.m.RUnlock()
-	for _, ccb := range s.ccbs {
-		ccb(event)
+	var nacked []string
+	for name, ccb := range s.ccbs {
+		if s.hold(name, r) {
+			continue
+		}
+		if err := ccb(be.event); err != nil {
+			nacked = append(nacked, name)
+		}
+	}
+	s.m.RUnlock()
+	s.reporter.ReportOperation("dispatch", true, float64(time.Since(start)/time.Millisecond))
+
+	r.add(len(nacked))
+	for _, name := range nacked {
+		s.scheduleRedelivery(r, name)
+	}
+
+	if r.release() {
+		s.ackDispatched(be)
+	}
+}
+
+// ackDispatched marks the event as dispatched to all subscriptions.
+func (s *memory) ackDispatched(be bufferedEvent) {
+	if s.wal != nil {
+		if err := s.wal.ack(be.seq); err != nil {
+			s.logger.Errorw("Could not mark event as dispatched at the write ahead log", zap.Error(err))
+		}
 	}
 }
 
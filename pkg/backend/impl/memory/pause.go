@@ -0,0 +1,158 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"go.uber.org/zap"
+)
+
+// heldEvents are the events held for a paused subscription, in the order
+// they were produced.
+type heldEvents struct {
+	events []*redelivery
+
+	// resumed subscriptions dispatch the held events, which stops when
+	// paused again, changing the generation.
+	resumed    bool
+	generation int
+}
+
+// PauseSubscription holds the events for the subscription until resumed,
+// up to the buffer size, discarding the oldest ones beyond it.
+func (s *memory) PauseSubscription(name string) error {
+	s.pm.Lock()
+	defer s.pm.Unlock()
+
+	h, ok := s.paused[name]
+	if !ok {
+		s.paused[name] = &heldEvents{}
+		s.logger.Infow("Subscription paused", zap.String("subscription", name))
+		return nil
+	}
+
+	if h.resumed {
+		h.resumed = false
+		h.generation++
+		s.logger.Infow("Subscription paused", zap.String("subscription", name))
+	}
+	return nil
+}
+
+// ResumeSubscription dispatches the events held for the subscription in
+// order, and dispatches later events once all of them were.
+func (s *memory) ResumeSubscription(name string) error {
+	s.pm.Lock()
+	defer s.pm.Unlock()
+
+	h, ok := s.paused[name]
+	if !ok || h.resumed {
+		return nil
+	}
+
+	h.resumed = true
+	h.generation++
+	s.logger.Infow("Subscription resumed", zap.String("subscription", name), zap.Int("held", len(h.events)))
+
+	go s.dispatchHeld(name, h, h.generation)
+	return nil
+}
+
+// hold appends the event to those held for the subscription, returning
+// false if the subscription is not paused nor dispatching held events.
+func (s *memory) hold(name string, r *redelivery) bool {
+	s.pm.Lock()
+	defer s.pm.Unlock()
+
+	h, ok := s.paused[name]
+	if !ok {
+		return false
+	}
+
+	r.add(1)
+	h.events = append(h.events, r)
+
+	if len(h.events) > s.args.BufferSize {
+		discarded := h.events[0]
+		h.events = h.events[1:]
+
+		s.logger.Warnw("Event held for paused subscription discarded due to buffer size",
+			zap.String("subscription", name), zap.String("id", discarded.be.event.ID()))
+		if discarded.release() {
+			s.ackDispatched(discarded.be)
+		}
+	}
+
+	return true
+}
+
+// dispatchHeld dispatches the events held for the subscription until none
+// is left, or until the subscription is paused again.
+func (s *memory) dispatchHeld(name string, h *heldEvents, generation int) {
+	for {
+		// Events not dispatched when closing are recovered from the write
+		// ahead log, like those waiting to be dispatched again.
+		if s.closing.Load() {
+			return
+		}
+
+		s.pm.Lock()
+		if s.paused[name] != h || h.generation != generation {
+			s.pm.Unlock()
+			return
+		}
+		if len(h.events) == 0 {
+			delete(s.paused, name)
+			s.pm.Unlock()
+			return
+		}
+		r := h.events[0]
+		h.events = h.events[1:]
+		s.pm.Unlock()
+
+		s.m.RLock()
+		ccb, ok := s.ccbs[name]
+		s.m.RUnlock()
+
+		if ok {
+			if err := ccb(r.be.event); err != nil {
+				s.scheduleRedelivery(r, name)
+				continue
+			}
+		}
+
+		if r.release() {
+			s.ackDispatched(r.be)
+		}
+	}
+}
+
+// discardHeld releases the events held for the subscription.
+func (s *memory) discardHeld(name string) {
+	s.pm.Lock()
+	defer s.pm.Unlock()
+
+	h, ok := s.paused[name]
+	if !ok {
+		return
+	}
+	delete(s.paused, name)
+
+	for _, r := range h.events {
+		if r.release() {
+			s.ackDispatched(r.be)
+		}
+	}
+}
+
+// heldLen returns the number of events held for paused subscriptions.
+func (s *memory) heldLen() int {
+	s.pm.Lock()
+	defer s.pm.Unlock()
+
+	n := 0
+	for _, h := range s.paused {
+		n += len(h.events)
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestPauseSubscription(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	s := New(&MemoryArgs{BufferSize: 2}, logger).(*memory)
+
+	r, err := metrics.NewReporter(context.Background(), "Memory", logger)
+	require.NoError(t, err)
+	s.reporter = r
+
+	var dispatched []string
+	var m sync.Mutex
+	require.NoError(t, s.Subscribe("paused", func(e *cloudevents.Event) error {
+		m.Lock()
+		defer m.Unlock()
+		dispatched = append(dispatched, e.ID())
+		return nil
+	}, backend.SubscribeWithPaused(true)))
+
+	ids := []string{"1", "2", "3"}
+	for _, id := range ids {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+		s.fanOut(bufferedEvent{event: &ev})
+	}
+
+	assert.Empty(t, dispatched, "Events must not be dispatched while paused")
+	assert.Equal(t, 2, s.heldLen(), "Events beyond the buffer size must be discarded")
+
+	require.NoError(t, s.ResumeSubscription("paused"))
+	assert.Eventually(t, func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(dispatched) == 2
+	}, time.Second, time.Millisecond, "Held events must be dispatched when resumed")
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("4"))
+	s.fanOut(bufferedEvent{event: &ev})
+
+	assert.Eventually(t, func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(dispatched) == 3
+	}, time.Second, time.Millisecond, "Events produced after resuming must be dispatched")
+
+	m.Lock()
+	defer m.Unlock()
+	assert.Equal(t, []string{"2", "3", "4"}, dispatched, "Events must be dispatched in order")
+}
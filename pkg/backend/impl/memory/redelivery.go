@@ -0,0 +1,79 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// redelivery tracks the subscriptions that did not acknowledge an event,
+// which is marked as dispatched once all of them acknowledge it.
+type redelivery struct {
+	be      bufferedEvent
+	pending int
+	m       sync.Mutex
+}
+
+// add increments the subscriptions the event is pending for.
+func (r *redelivery) add(n int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.pending += n
+}
+
+// release decrements the subscriptions the event is pending for, returning
+// true when none is left.
+func (r *redelivery) release() bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.pending--
+	return r.pending == 0
+}
+
+// redeliver dispatches the event again to each of the subscriptions after
+// the redelivery delay, until they acknowledge it. Events waiting to be
+// dispatched again when the backend stops are recovered from the write
+// ahead log when persistence is enabled, being lost otherwise.
+func (s *memory) redeliver(be bufferedEvent, names []string) {
+	r := &redelivery{be: be, pending: len(names)}
+	for _, name := range names {
+		s.scheduleRedelivery(r, name)
+	}
+}
+
+func (s *memory) scheduleRedelivery(r *redelivery, name string) {
+	time.AfterFunc(s.args.RedeliveryDelayDuration, func() {
+		if s.closing.Load() {
+			s.logger.Warnw("Event not acknowledged was not dispatched again due to backend closing",
+				zap.String("subscription", name), zap.String("id", r.be.event.ID()))
+			return
+		}
+
+		// Subscriptions paused meanwhile hold the event, which keeps its
+		// own reference.
+		if s.hold(name, r) {
+			r.release()
+			return
+		}
+
+		s.m.RLock()
+		ccb, ok := s.ccbs[name]
+		s.m.RUnlock()
+
+		// Subscriptions removed meanwhile do not need the event anymore.
+		if ok {
+			if err := ccb(r.be.event); err != nil {
+				s.scheduleRedelivery(r, name)
+				return
+			}
+		}
+
+		if r.release() {
+			s.ackDispatched(r.be)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestRedelivery(t *testing.T) {
+	s := New(&MemoryArgs{RedeliveryDelayDuration: 10 * time.Millisecond}, zaptest.NewLogger(t).Sugar()).(*memory)
+
+	var attempts int32
+	s.ccbs["nacking"] = func(*cloudevents.Event) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("not delivered")
+		}
+		return nil
+	}
+
+	ev := lib.NewCloudEvent()
+	s.redeliver(bufferedEvent{event: &ev}, []string{"nacking", "removed"})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&attempts) == 3
+	}, time.Second, time.Millisecond, "Events must be dispatched again until acknowledged")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts), "Acknowledged events must not be dispatched again")
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+)
+
+// Definition of the memory backend at the registry.
+var Definition = registry.Definition{
+	Name:    "memory",
+	NewArgs: func() registry.Args { return &MemoryArgs{} },
+	New:     newBackend,
+}
+
+func init() {
+	registry.Register(Definition)
+}
+
+func newBackend(a registry.Args, hosted string, o registry.Options) backend.Interface {
+	args := *a.(*MemoryArgs)
+	logger := o.Logger.Named("memory")
+
+	// Hosted brokers use their own buffer, and persistence file prefixed
+	// with their name.
+	if hosted != "" {
+		if args.PersistencePath != "" {
+			args.PersistencePath = filepath.Join(filepath.Dir(args.PersistencePath),
+				hosted+"-"+filepath.Base(args.PersistencePath))
+		}
+		logger = logger.With(zap.String("broker", hosted))
+	}
+
+	return New(&args, logger)
+}
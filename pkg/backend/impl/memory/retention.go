@@ -0,0 +1,75 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// SetRetention discards the oldest buffered events beyond the retention
+// limits, which apply along with the buffer size. Scheduled events are not
+// limited until released to the buffer.
+func (s *memory) SetRetention(r backend.Retention) {
+	s.retention = r
+}
+
+// retain makes room at the buffer for the event, discarding the oldest
+// buffered events while the event does not fit the retention limits.
+func (s *memory) retain(be *bufferedEvent) {
+	if s.retention.MaxBytes > 0 {
+		if b, err := be.event.MarshalJSON(); err == nil {
+			be.size = int64(len(b))
+		}
+	}
+
+	for {
+		var reason string
+		switch {
+		case s.retention.MaxEvents > 0 && int64(len(s.buffer)) >= s.retention.MaxEvents:
+			reason = backend.TrimReasonCount
+		case s.retention.MaxBytes > 0 && s.bufferedBytes.Load()+be.size > s.retention.MaxBytes:
+			reason = backend.TrimReasonBytes
+		default:
+			return
+		}
+
+		select {
+		case oldest, ok := <-s.buffer:
+			if !ok {
+				return
+			}
+			s.trim(oldest, reason)
+		default:
+			// Buffered events are being dispatched meanwhile.
+			return
+		}
+	}
+}
+
+// expired returns true if the event exceeds the maximum age since it was
+// produced, or released to the buffer when scheduled.
+func (s *memory) expired(be bufferedEvent, now time.Time) bool {
+	if s.retention.MaxAge == 0 || be.produced.IsZero() {
+		return false
+	}
+
+	t := be.produced
+	if be.at.After(t) {
+		t = be.at
+	}
+	return now.Sub(t) > s.retention.MaxAge
+}
+
+// trim discards a buffered event, which is not dispatched.
+func (s *memory) trim(be bufferedEvent, reason string) {
+	s.bufferedBytes.Add(-be.size)
+	s.ackDispatched(be)
+
+	s.logger.Debugw("Buffered event discarded due to retention", zap.String("reason", reason), zap.String("id", be.event.ID()))
+	s.reporter.ReportTrimmed(reason, 1)
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestRetention(t *testing.T) {
+	ev := lib.NewCloudEvent()
+	b, err := ev.MarshalJSON()
+	require.NoError(t, err)
+	size := int64(len(b))
+
+	tcs := map[string]struct {
+		retention backend.Retention
+		expected  []string
+	}{
+		"unlimited": {
+			expected: []string{"1", "2", "3"},
+		},
+		"max events": {
+			retention: backend.Retention{MaxEvents: 2},
+			expected:  []string{"2", "3"},
+		},
+		"max bytes": {
+			retention: backend.Retention{MaxBytes: 2 * size},
+			expected:  []string{"2", "3"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			s := New(&MemoryArgs{BufferSize: 10, ProduceTimeoutDuration: time.Second}, zaptest.NewLogger(t).Sugar()).(*memory)
+			s.SetRetention(tc.retention)
+			require.NoError(t, s.Init(context.Background()))
+
+			for _, id := range []string{"1", "2", "3"} {
+				ev := ev.Clone()
+				ev.SetID(id)
+				require.NoError(t, s.Produce(context.Background(), &ev))
+			}
+
+			var ids []string
+			for len(s.buffer) != 0 {
+				ids = append(ids, (<-s.buffer).event.ID())
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestRetentionMaxAge(t *testing.T) {
+	s := New(&MemoryArgs{BufferSize: 10}, zaptest.NewLogger(t).Sugar()).(*memory)
+	s.SetRetention(backend.Retention{MaxAge: time.Minute})
+	require.NoError(t, s.Init(context.Background()))
+
+	var dispatched []string
+	s.ccbs["subscription"] = func(e *cloudevents.Event) error {
+		dispatched = append(dispatched, e.ID())
+		return nil
+	}
+
+	now := time.Now()
+	expired := lib.NewCloudEvent(lib.CloudEventWithIDOption("expired"))
+	s.fanOut(bufferedEvent{event: &expired, produced: now.Add(-2 * time.Minute)})
+	scheduled := lib.NewCloudEvent(lib.CloudEventWithIDOption("scheduled"))
+	s.fanOut(bufferedEvent{event: &scheduled, produced: now.Add(-2 * time.Minute), at: now})
+	recent := lib.NewCloudEvent(lib.CloudEventWithIDOption("recent"))
+	s.fanOut(bufferedEvent{event: &recent, produced: now})
+
+	assert.Equal(t, []string{"scheduled", "recent"}, dispatched, "Expired events must not be dispatched")
+}
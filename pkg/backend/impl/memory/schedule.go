@@ -0,0 +1,110 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduledEvents is a min heap of events ordered by delivery time.
+type scheduledEvents []bufferedEvent
+
+func (h scheduledEvents) Len() int           { return len(h) }
+func (h scheduledEvents) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h scheduledEvents) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *scheduledEvents) Push(x interface{}) {
+	*h = append(*h, x.(bufferedEvent))
+}
+
+func (h *scheduledEvents) Pop() interface{} {
+	old := *h
+	n := len(old)
+	be := old[n-1]
+	*h = old[:n-1]
+	return be
+}
+
+// scheduler holds events until their delivery time.
+type scheduler struct {
+	events scheduledEvents
+	// wake signals that an event was scheduled.
+	wake chan struct{}
+	m    sync.Mutex
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (s *scheduler) add(be bufferedEvent) {
+	s.m.Lock()
+	heap.Push(&s.events, be)
+	s.m.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.events)
+}
+
+// due returns the events whose delivery time has arrived, and the
+// time until the next scheduled event.
+func (s *scheduler) due(now time.Time) ([]bufferedEvent, time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var res []bufferedEvent
+	for len(s.events) != 0 && !s.events[0].at.After(now) {
+		res = append(res, heap.Pop(&s.events).(bufferedEvent))
+	}
+
+	// Wait until new events are scheduled.
+	next := time.Duration(1<<63 - 1)
+	if len(s.events) != 0 {
+		next = s.events[0].at.Sub(now)
+	}
+
+	return res, next
+}
+
+// run releases due events to the dispatch function until the
+// context is done.
+func (s *scheduler) run(ctx context.Context, dispatch func(bufferedEvent)) {
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-t.C:
+		}
+
+		events, next := s.due(time.Now())
+		for _, be := range events {
+			dispatch(be)
+		}
+
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(next)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestScheduler(t *testing.T) {
+	s := newScheduler()
+	now := time.Now()
+
+	for id, delay := range map[string]time.Duration{
+		"e3": 300 * time.Millisecond,
+		"e1": 100 * time.Millisecond,
+		"e2": 200 * time.Millisecond,
+	} {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+		s.add(bufferedEvent{event: &ev, at: now.Add(delay)})
+	}
+
+	events, next := s.due(now)
+	assert.Empty(t, events)
+	assert.Equal(t, 100*time.Millisecond, next)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dispatched := make(chan string, 3)
+	go s.run(ctx, func(be bufferedEvent) {
+		dispatched <- be.event.ID()
+	})
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		select {
+		case got := <-dispatched:
+			require.Equal(t, id, got)
+			assert.False(t, time.Now().Before(now.Add(100*time.Millisecond)), "event dispatched before its time")
+		case <-time.After(2 * time.Second):
+			t.Fatalf("scheduled event %s was not dispatched", id)
+		}
+	}
+
+	assert.Equal(t, 0, s.len())
+}
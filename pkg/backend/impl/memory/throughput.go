@@ -0,0 +1,127 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Suffix added to the persistence path for the throughput counters file.
+const throughputFileSuffix = ".throughput"
+
+var _ backend.ThroughputStore = (*memory)(nil)
+
+// throughputCounters keeps the hourly counters, which are written to a
+// file when persistence is enabled.
+type throughputCounters struct {
+	hours map[int64]*throughputHour
+	path  string
+	m     sync.Mutex
+}
+
+type throughputHour struct {
+	Counts  map[string]int64 `json:"counts"`
+	Expires time.Time        `json:"expires"`
+}
+
+// load reads the counters file, if it exists.
+func (t *throughputCounters) load(path string) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.path = path
+	t.hours = make(map[int64]*throughputHour)
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("could not read throughput file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &t.hours); err != nil {
+		return fmt.Errorf("could not parse throughput file: %w", err)
+	}
+
+	return nil
+}
+
+// save writes the counters file, replacing the previous one.
+func (t *throughputCounters) save() error {
+	b, err := json.Marshal(t.hours)
+	if err != nil {
+		return err
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("could not write throughput file: %w", err)
+	}
+
+	return os.Rename(tmp, t.path)
+}
+
+func (s *memory) AddThroughput(ctx context.Context, hour time.Time, counts map[string]int64, retention time.Duration) error {
+	t := &s.throughput
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.hours == nil {
+		t.hours = make(map[int64]*throughputHour)
+	}
+
+	now := time.Now()
+	for k, h := range t.hours {
+		if now.After(h.Expires) {
+			delete(t.hours, k)
+		}
+	}
+
+	h, ok := t.hours[hour.Unix()]
+	if !ok {
+		h = &throughputHour{Counts: make(map[string]int64)}
+		t.hours[hour.Unix()] = h
+	}
+	for field, n := range counts {
+		h.Counts[field] += n
+	}
+	h.Expires = hour.Add(time.Hour + retention)
+
+	if t.path == "" {
+		return nil
+	}
+
+	return t.save()
+}
+
+func (s *memory) Throughput(ctx context.Context, from, to time.Time) (map[time.Time]map[string]int64, error) {
+	t := &s.throughput
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	res := make(map[time.Time]map[string]int64)
+	for k, h := range t.hours {
+		hour := time.Unix(k, 0).UTC()
+		if hour.Before(from) || hour.After(to) {
+			continue
+		}
+
+		counts := make(map[string]int64, len(h.Counts))
+		for field, n := range h.Counts {
+			counts[field] = n
+		}
+		res[hour] = counts
+	}
+
+	return res, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+)
+
+type walOperation string
+
+const (
+	walOperationAdd walOperation = "add"
+	walOperationAck walOperation = "ack"
+)
+
+type walRecord struct {
+	Operation walOperation       `json:"op"`
+	Sequence  uint64             `json:"seq"`
+	Event     *cloudevents.Event `json:"event,omitempty"`
+	// Encrypted event, informed instead of the event when encryption is
+	// enabled.
+	Encrypted []byte `json:"enc,omitempty"`
+	// Time when scheduled events must be delivered.
+	At *time.Time `json:"at,omitempty"`
+}
+
+// wal is a write ahead log that keeps track of the events added to
+// the memory buffer and those that have been already dispatched, so
+// that non dispatched events can be recovered after a restart.
+type wal struct {
+	path string
+	f    *os.File
+
+	// cipher encrypts the events at the log, nil if not enabled.
+	cipher *encryption.Cipher
+
+	seq     uint64
+	pending map[uint64]bufferedEvent
+
+	m sync.Mutex
+}
+
+// openWAL reads the write ahead log file, if it exists, and returns
+// the events pending to be dispatched ordered by arrival. Events are
+// encrypted with the cipher when informed.
+func openWAL(path string, c *encryption.Cipher) (*wal, []bufferedEvent, error) {
+	w := &wal{
+		path:    path,
+		cipher:  c,
+		pending: make(map[uint64]bufferedEvent),
+	}
+
+	if err := w.replay(); err != nil {
+		return nil, nil, err
+	}
+
+	// Compact the recovered contents before appending new records.
+	if err := w.snapshot(); err != nil {
+		return nil, nil, err
+	}
+
+	pending := make([]bufferedEvent, 0, len(w.pending))
+	for _, be := range w.pending {
+		pending = append(pending, be)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].seq < pending[j].seq
+	})
+
+	return w, pending, nil
+}
+
+func (w *wal) replay() error {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open write ahead log %q: %w", w.path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) != 0 {
+			rec := &walRecord{}
+			// A partially written last line is expected if the process
+			// was abruptly stopped, stop reading at that point.
+			if uerr := json.Unmarshal(line, rec); uerr != nil {
+				break
+			}
+
+			if rec.Encrypted != nil {
+				var derr error
+				if rec.Event, derr = w.decrypt(rec.Encrypted); derr != nil {
+					return fmt.Errorf("could not decrypt write ahead log record %d: %w", rec.Sequence, derr)
+				}
+			}
+
+			switch rec.Operation {
+			case walOperationAdd:
+				if rec.Event != nil {
+					be := bufferedEvent{seq: rec.Sequence, event: rec.Event}
+					if rec.At != nil {
+						be.at = *rec.At
+					}
+					w.pending[rec.Sequence] = be
+				}
+			case walOperationAck:
+				delete(w.pending, rec.Sequence)
+			}
+
+			if rec.Sequence > w.seq {
+				w.seq = rec.Sequence
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read write ahead log %q: %w", w.path, err)
+		}
+	}
+
+	return nil
+}
+
+// append adds the event to the log. Scheduled events inform the time
+// when they must be delivered, zero otherwise.
+func (w *wal) append(event *cloudevents.Event, at time.Time) (uint64, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.seq++
+	be := bufferedEvent{seq: w.seq, event: event, at: at}
+	if err := w.write(be.walRecord()); err != nil {
+		return 0, err
+	}
+
+	w.pending[w.seq] = be
+	return w.seq, nil
+}
+
+func (w *wal) ack(seq uint64) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	delete(w.pending, seq)
+	return w.write(&walRecord{Operation: walOperationAck, Sequence: seq})
+}
+
+// write is not thread safe, caller should acquire the object's lock.
+func (w *wal) write(rec *walRecord) error {
+	b, err := w.marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("could not write to write ahead log: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot rewrites the log file keeping only the pending events.
+func (w *wal) snapshot() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	tmp := w.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not create write ahead log snapshot %q: %w", tmp, err)
+	}
+
+	seqs := make([]uint64, 0, len(w.pending))
+	for seq := range w.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	bw := bufio.NewWriter(f)
+	for _, seq := range seqs {
+		b, err := w.marshal(w.pending[seq].walRecord())
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err = bw.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return fmt.Errorf("could not write write ahead log snapshot: %w", err)
+		}
+	}
+
+	if err = bw.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write write ahead log snapshot: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not sync write ahead log snapshot: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("could not close write ahead log snapshot: %w", err)
+	}
+
+	if err = os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("could not replace write ahead log with snapshot: %w", err)
+	}
+
+	if w.f != nil {
+		w.f.Close()
+	}
+
+	w.f, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open write ahead log %q: %w", w.path, err)
+	}
+
+	return nil
+}
+
+func (be bufferedEvent) walRecord() *walRecord {
+	rec := &walRecord{Operation: walOperationAdd, Sequence: be.seq, Event: be.event}
+	if !be.at.IsZero() {
+		rec.At = &be.at
+	}
+	return rec
+}
+
+// marshal serializes the record, encrypting its event when enabled.
+func (w *wal) marshal(rec *walRecord) ([]byte, error) {
+	if w.cipher != nil && rec.Event != nil {
+		b, err := json.Marshal(rec.Event)
+		if err != nil {
+			return nil, fmt.Errorf("could not serialize write ahead log event: %w", err)
+		}
+		enc, err := w.cipher.Encrypt(context.Background(), b)
+		if err != nil {
+			return nil, fmt.Errorf("could not encrypt write ahead log event: %w", err)
+		}
+		rec = &walRecord{Operation: rec.Operation, Sequence: rec.Sequence, Encrypted: enc, At: rec.At}
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize write ahead log record: %w", err)
+	}
+	return b, nil
+}
+
+func (w *wal) decrypt(enc []byte) (*cloudevents.Event, error) {
+	if w.cipher == nil {
+		return nil, errors.New("event is encrypted but encryption is not enabled")
+	}
+
+	b, err := w.cipher.Decrypt(context.Background(), enc)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &cloudevents.Event{}
+	if err := json.Unmarshal(b, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (w *wal) close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	return w.f.Close()
+}
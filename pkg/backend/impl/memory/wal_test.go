@@ -0,0 +1,99 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestWALRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.wal")
+
+	w, pending, err := openWAL(path, nil)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	ids := []string{"e1", "e2", "e3", "e4"}
+	seqs := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+		seq, err := w.append(&ev, time.Time{})
+		require.NoError(t, err)
+		seqs = append(seqs, seq)
+	}
+
+	require.NoError(t, w.ack(seqs[0]))
+	require.NoError(t, w.ack(seqs[2]))
+	require.NoError(t, w.close())
+
+	// Simulate a partially written record at the end of the file.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"add","seq":5,"ev`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, pending, err = openWAL(path, nil)
+	require.NoError(t, err)
+	defer w.close()
+
+	recovered := make([]string, 0, len(pending))
+	for _, be := range pending {
+		recovered = append(recovered, be.event.ID())
+	}
+	assert.Equal(t, []string{"e2", "e4"}, recovered)
+
+	// New sequences must not overlap with recovered ones.
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("e5"))
+	seq, err := w.append(&ev, time.Time{})
+	require.NoError(t, err)
+	assert.Greater(t, seq, seqs[len(seqs)-1])
+}
+
+func TestWALEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.wal")
+
+	kr, err := encryption.NewKeyring(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	c := encryption.NewCipher(kr)
+
+	w, _, err := openWAL(path, c)
+	require.NoError(t, err)
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("e1"),
+		lib.CloudEventWithDataOption("application/json", []byte(`{"secret":"value"}`)))
+	_, err = w.append(&ev, time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, w.close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	_, _, err = openWAL(path, nil)
+	assert.Error(t, err, "Encrypted events must not be recovered without cipher")
+
+	w, pending, err := openWAL(path, c)
+	require.NoError(t, err)
+	defer w.close()
+
+	require.Len(t, pending, 1)
+	assert.Equal(t, "e1", pending[0].event.ID())
+	assert.Equal(t, ev.Data(), pending[0].event.Data())
+
+	// Snapshots keep events encrypted.
+	b, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+}
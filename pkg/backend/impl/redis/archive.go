@@ -0,0 +1,93 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Suffix added to the stream name for the archive stream.
+	archiveStreamSuffix = ".archive"
+
+	// Number of archived events read from the stream at once.
+	archivePageSize = 100
+)
+
+var _ backend.EventArchive = (*redis)(nil)
+
+// ArchiveEvent adds the event to the archive stream, whose IDs are the
+// time events are archived at, trimming the events older than the
+// retention.
+func (s *redis) ArchiveEvent(ctx context.Context, event *cloudevents.Event, retention time.Duration) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("could not serialize CloudEvent: %w", err)
+	}
+
+	values, err := s.eventValues(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	minID := time.Now().Add(-retention).UnixMilli()
+	if err := s.client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: s.args.Stream + archiveStreamSuffix,
+		MinID:  strconv.FormatInt(minID, 10),
+		Approx: true,
+		Values: values,
+	}).Err(); err != nil {
+		return fmt.Errorf("could not archive CloudEvent: %w", err)
+	}
+
+	return nil
+}
+
+// QueryArchive reads the archive stream in pages within the query range,
+// until enough matching events are found.
+func (s *redis) QueryArchive(ctx context.Context, q *backend.ArchiveQuery) ([]cloudevents.Event, error) {
+	stream := s.args.Stream + archiveStreamSuffix
+	start := strconv.FormatInt(q.From.UnixMilli(), 10)
+	stop := strconv.FormatInt(q.To.UnixMilli(), 10)
+
+	events := []cloudevents.Event{}
+	for len(events) < q.Limit {
+		msgs, err := s.client.XRangeN(ctx, stream, start, stop, archivePageSize).Result()
+		if err != nil {
+			return nil, fmt.Errorf("could not read archived events: %w", err)
+		}
+
+		for _, msg := range msgs {
+			ce, ok, err := eventFromValues(ctx, s.cipher, msg.Values)
+			if !ok {
+				continue
+			}
+			if err != nil {
+				s.logger.Debugw("Skipping non CloudEvent message from the archive", zap.String("id", msg.ID), zap.Error(err))
+				continue
+			}
+			if q.Matches(ce) && len(events) < q.Limit {
+				events = append(events, *ce)
+			}
+		}
+
+		if len(msgs) < archivePageSize {
+			break
+		}
+		// Continue after the last read message.
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+
+	return events, nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+var _ backend.BacklogReporter = (*redis)(nil)
+
+// Backlog returns, for the consumer group of each subscription, the
+// messages not yet read plus the messages read but not acknowledged. The
+// number of messages not yet read is only reported by Redis 7 or newer.
+func (s *redis) Backlog(ctx context.Context, names []string) (map[string]int64, error) {
+	groups, err := s.client.XInfoGroups(ctx, s.args.Stream).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read consumer groups from Redis: %w", err)
+	}
+
+	byGroup := make(map[string]int64, len(groups))
+	for _, g := range groups {
+		byGroup[g.Name] = g.Lag + g.Pending
+	}
+
+	backlog := make(map[string]int64, len(names))
+	for _, name := range names {
+		if n, ok := byGroup[s.args.Group+"."+name]; ok {
+			backlog[name] = n
+		}
+	}
+	return backlog, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+var _ backend.BatchProducer = (*redis)(nil)
+
+// ProduceBatch adds the events to the stream using a single pipeline.
+// Scheduled events are produced one at a time.
+func (s *redis) ProduceBatch(ctx context.Context, events []*cloudevents.Event) []error {
+	errs := make([]error, len(events))
+	cmds := make(map[int]*goredis.StringCmd, len(events))
+
+	now := time.Now()
+	// Errors are informed by each command.
+	_, _ = s.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for i, event := range events {
+			if _, ok, _ := backend.ScheduledTime(event, now); ok {
+				continue
+			}
+
+			b, err := s.marshal(event)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			args, err := s.xaddArgs(ctx, b)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			cmds[i] = p.XAdd(ctx, args)
+		}
+		return nil
+	})
+
+	for i, event := range events {
+		if errs[i] != nil {
+			continue
+		}
+
+		cmd, ok := cmds[i]
+		if !ok {
+			errs[i] = s.Produce(ctx, event)
+			continue
+		}
+		if err := cmd.Err(); err != nil {
+			errs[i] = fmt.Errorf("could not produce CloudEvent to backend: %w", err)
+		}
+	}
+
+	return errs
+}
@@ -6,6 +6,9 @@ package redis
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/rickb777/date/period"
 )
 
 type RedisArgs struct {
@@ -18,12 +21,47 @@ type RedisArgs struct {
 	TLSEnabled    bool   `help:"TLS enablement for Redis connection." env:"TLS_ENABLED" default:"false"`
 	TLSSkipVerify bool   `help:"TLS skipping certificate verification." env:"TLS_SKIP_VERIFY" default:"false"`
 
+	// Credentials read from files are watched, new connections using
+	// their updated contents, so that they can be rotated.
+	UsernameFile string `help:"File containing the Redis ACL username, which is watched for rotating credentials." env:"USERNAME_FILE"`
+	PasswordFile string `help:"File containing the Redis password, which is watched for rotating credentials." env:"PASSWORD_FILE"`
+	TLSCAFile    string `help:"File containing the PEM encoded CA certificates that verify the Redis server certificate, instead of the system ones." env:"TLS_CA_FILE"`
+	TLSCertFile  string `help:"File containing the PEM encoded client certificate for mutual TLS, which is watched for rotating credentials." env:"TLS_CERT_FILE"`
+	TLSKeyFile   string `help:"File containing the PEM encoded client private key for mutual TLS, which is watched for rotating credentials." env:"TLS_KEY_FILE"`
+
 	Stream string `help:"Stream name that stores the broker's CloudEvents." env:"STREAM" default:"triggermesh"`
 	Group  string `help:"Redis stream consumer group name." env:"GROUP" default:"default"`
 	// Instance at the Redis stream consumer group. Copied from the InstanceName at the global args.
 	Instance string `kong:"-"`
 
 	StreamMaxLen int `help:"Limit the number of items in a stream by trimming it. Set to 0 for unlimited." env:"STREAM_MAX_LEN" default:"1000"`
+
+	// Compression of the CloudEvents stored at the stream.
+	Compression        string `help:"Compression of the serialized CloudEvents stored at the stream: none, gzip or zstd. Streams can contain CloudEvents stored with different settings." env:"COMPRESSION" default:"none"`
+	CompressionMinSize int    `help:"Minimum size in bytes of the serialized CloudEvents that are compressed." env:"COMPRESSION_MIN_SIZE" default:"1024"`
+
+	// Redis rejects values larger than its proto-max-bulk-len setting.
+	MaxEntrySize int `help:"Maximum size in bytes of the serialized CloudEvents stored at the stream, which must not exceed the Redis proto-max-bulk-len setting." env:"MAX_ENTRY_SIZE" default:"536870912"`
+
+	// Horizontal scaling lets multiple broker replicas join the same consumer groups.
+	ScalingEnabled   bool   `help:"Enables running multiple broker replicas that share the Redis consumer groups." env:"SCALING_ENABLED" default:"false"`
+	ConsumerName     string `help:"Consumer name for this replica at the Redis consumer groups, must be unique per replica. Only used when scaling is enabled." env:"CONSUMER_NAME" default:"${hostname}"`
+	ClaimMinIdleTime string `help:"Minimum idle time of a pending message before being claimed by a replica, or dispatched again when it was not acknowledged." env:"CLAIM_MIN_IDLE_TIME" default:"PT5M"`
+	ClaimPeriod      string `help:"Period for checking pending messages that can be claimed." env:"CLAIM_PERIOD" default:"PT1M"`
+
+	// Messages left pending are dispatched again when the broker restarts
+	// or when claimed, which never ends for messages that crash the broker.
+	MaxDeliveries int `help:"Number of times a message left pending is dispatched before moving it to the quarantine stream. Set to 0 for unlimited." env:"MAX_DELIVERIES" default:"0"`
+
+	// Messages left pending found when starting after a long outage can be
+	// too old for targets to expect them.
+	StartupClaimPolicy string `help:"Policy for the messages left pending found when starting: redeliver, park to move them to the parked stream, or skip to acknowledge them without dispatching." env:"STARTUP_CLAIM_POLICY" default:"redeliver"`
+	StartupClaimMaxAge string `help:"Age after being produced of the messages left pending the startup claim policy applies to, younger messages are dispatched again. Set to PT0S to apply the policy to all of them." env:"STARTUP_CLAIM_MAX_AGE" default:"PT0S"`
+
+	ClaimMinIdleTimeDuration time.Duration `kong:"-"`
+	ClaimPeriodDuration      time.Duration `kong:"-"`
+
+	StartupClaimMaxAgeDuration time.Duration `kong:"-"`
 }
 
 func (ra *RedisArgs) Validate() error {
@@ -36,9 +74,91 @@ func (ra *RedisArgs) Validate() error {
 		msg = append(msg, "Only one of address (standalone) or cluster addresses (cluster) arguments must be provided.")
 	}
 
+	if ra.Username != "" && ra.UsernameFile != "" {
+		msg = append(msg, "Only one of username or username file arguments must be provided.")
+	}
+	if ra.Password != "" && ra.PasswordFile != "" {
+		msg = append(msg, "Only one of password or password file arguments must be provided.")
+	}
+	if (ra.TLSCertFile == "") != (ra.TLSKeyFile == "") {
+		msg = append(msg, "TLS certificate and key files must be provided together.")
+	}
+	if !ra.TLSEnabled && (ra.TLSCAFile != "" || ra.TLSCertFile != "") {
+		msg = append(msg, "TLS must be enabled to use CA, certificate or key files.")
+	}
+
+	switch Compression(ra.Compression) {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		msg = append(msg, "Compression must be none, gzip or zstd.")
+	}
+
+	if ra.CompressionMinSize < 0 {
+		msg = append(msg, "Compression minimum size must not be negative.")
+	}
+
+	if ra.MaxEntrySize <= 0 {
+		msg = append(msg, "Max entry size must be greater than zero.")
+	}
+
+	if ra.MaxDeliveries < 0 {
+		msg = append(msg, "Max deliveries must not be negative.")
+	}
+
+	switch StartupClaimPolicy(ra.StartupClaimPolicy) {
+	case "", StartupClaimRedeliver, StartupClaimPark, StartupClaimSkip:
+	default:
+		msg = append(msg, "Startup claim policy must be redeliver, park or skip.")
+	}
+
+	d, err := parseDuration(ra.StartupClaimMaxAge)
+	switch {
+	case err != nil:
+		msg = append(msg, fmt.Sprintf("Startup claim max age is not an ISO8601 duration: %v", err))
+	case d < 0:
+		msg = append(msg, "Startup claim max age must not be negative.")
+	}
+	ra.StartupClaimMaxAgeDuration = d
+
+	if ra.ScalingEnabled && ra.ConsumerName == "" {
+		msg = append(msg, "Consumer name must be informed when scaling is enabled.")
+	}
+
+	// Messages that are not acknowledged are claimed even without scaling.
+	d, err = parseDuration(ra.ClaimMinIdleTime)
+	if err != nil {
+		msg = append(msg, fmt.Sprintf("Claim minimum idle time is not an ISO8601 duration: %v", err))
+	}
+	ra.ClaimMinIdleTimeDuration = d
+
+	d, err = parseDuration(ra.ClaimPeriod)
+	switch {
+	case err != nil:
+		msg = append(msg, fmt.Sprintf("Claim period is not an ISO8601 duration: %v", err))
+	case d <= 0 && ra.ClaimMinIdleTimeDuration > 0:
+		msg = append(msg, "Claim period must be greater than zero.")
+	}
+	ra.ClaimPeriodDuration = d
+
 	if len(msg) == 0 {
 		return nil
 	}
 
 	return fmt.Errorf(strings.Join(msg, " "))
 }
+
+func parseDuration(d string) (time.Duration, error) {
+	p, err := period.Parse(d)
+	if err != nil {
+		// try to parse go duration for backwards compatibility.
+		gd, gderr := time.ParseDuration(d)
+		if gderr != nil {
+			// go time parsing failed, we assume that the incoming parameter was ISO8601
+			// for the error message.
+			return 0, err
+		}
+		return gd, nil
+	}
+
+	return p.DurationApprox(), nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+)
+
+// Compression of the serialized CloudEvents stored at the stream.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Redis key at the message that informs the encoding of the CloudEvent,
+// which is not informed for uncompressed CloudEvents. Messages written
+// with different compression settings can be read from the same stream.
+const encodingKey = "ce-encoding"
+
+var (
+	// Encoder and decoder are safe for concurrent use when encoding and
+	// decoding whole buffers.
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// eventValues returns the values of the message that stores the serialized
+// CloudEvent, which is compressed when enabled and larger than the minimum
+// size. CloudEvents that cannot be compressed are stored as they are. When
+// encryption is enabled the CloudEvent is encrypted after compressing it.
+func (s *redis) eventValues(ctx context.Context, b []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	c := Compression(s.args.Compression)
+	if c != "" && c != CompressionNone && len(b) >= s.args.CompressionMinSize {
+		cb, err := compress(c, b)
+		if err != nil {
+			s.logger.Warnw("Could not compress CloudEvent, storing it uncompressed", zap.Error(err))
+		} else {
+			b = cb
+			values[encodingKey] = string(c)
+		}
+	}
+
+	if s.cipher != nil {
+		enc, err := s.encrypt(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		b = enc
+		values[encryptionKey] = encryptionAESGCM
+	}
+
+	values[ceKey] = b
+	return values, nil
+}
+
+func compress(c Compression, b []byte) ([]byte, error) {
+	switch c {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(b, make([]byte, 0, len(b)/2)), nil
+	}
+
+	return nil, fmt.Errorf("unknown compression %q", c)
+}
+
+func decompress(encoding string, b []byte) ([]byte, error) {
+	switch Compression(encoding) {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(b, nil)
+	}
+
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+// eventFromValues parses the CloudEvent stored at the message values,
+// decrypting and decompressing it if needed. False is returned if the
+// message does not contain a CloudEvent.
+func eventFromValues(ctx context.Context, c *encryption.Cipher, values map[string]interface{}) (*cloudevents.Event, bool, error) {
+	v, ok := values[ceKey].(string)
+	if !ok {
+		return nil, false, nil
+	}
+
+	b := []byte(v)
+	if _, ok := values[encryptionKey]; ok {
+		var err error
+		if b, err = decrypt(ctx, c, b); err != nil {
+			return nil, true, err
+		}
+	}
+
+	if enc, ok := values[encodingKey].(string); ok {
+		var err error
+		if b, err = decompress(enc, b); err != nil {
+			return nil, true, fmt.Errorf("could not decompress CloudEvent: %w", err)
+		}
+	}
+
+	ce := &cloudevents.Event{}
+	if err := ce.UnmarshalJSON(b); err != nil {
+		return nil, true, err
+	}
+	return ce, true, nil
+}
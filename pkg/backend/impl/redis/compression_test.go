@@ -0,0 +1,76 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+// readValues returns the values as they are read from Redis, as strings.
+func readValues(values map[string]interface{}) map[string]interface{} {
+	r := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		switch v := v.(type) {
+		case []byte:
+			r[k] = string(v)
+		default:
+			r[k] = v
+		}
+	}
+	return r
+}
+
+func TestCompression(t *testing.T) {
+	ctx := context.Background()
+	ev := lib.NewCloudEvent(lib.CloudEventWithDataOption("application/json",
+		[]byte(`{"items":["`+strings.Repeat("item", 500)+`"]}`)))
+	b, err := ev.MarshalJSON()
+	require.NoError(t, err)
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			s := &redis{
+				args:   &RedisArgs{Compression: string(c), CompressionMinSize: 1024},
+				logger: zap.NewNop().Sugar(),
+			}
+
+			values, err := s.eventValues(ctx, b)
+			require.NoError(t, err)
+			if c == CompressionNone {
+				assert.NotContains(t, values, encodingKey)
+			} else {
+				assert.Equal(t, string(c), values[encodingKey])
+				assert.Less(t, len(values[ceKey].([]byte)), len(b)/4)
+			}
+
+			got, ok, err := eventFromValues(ctx, nil, readValues(values))
+			require.True(t, ok)
+			require.NoError(t, err)
+			assert.Equal(t, ev.ID(), got.ID())
+			assert.Equal(t, ev.Data(), got.Data())
+
+			// Events smaller than the minimum size are not compressed.
+			small, err := lib.NewCloudEvent().MarshalJSON()
+			require.NoError(t, err)
+			values, err = s.eventValues(ctx, small)
+			require.NoError(t, err)
+			assert.NotContains(t, values, encodingKey)
+		})
+	}
+
+	_, ok, err := eventFromValues(ctx, nil, map[string]interface{}{ceKey: string(b), encodingKey: "br"})
+	assert.True(t, ok)
+	assert.Error(t, err, "Unknown encodings must not be parsed")
+
+	_, ok, _ = eventFromValues(ctx, nil, map[string]interface{}{"other": "value"})
+	assert.False(t, ok)
+}
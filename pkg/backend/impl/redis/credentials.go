@@ -0,0 +1,202 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/fs"
+)
+
+// credentials authenticate the connections to Redis. Those read from
+// files are updated when the files change, which only affects the
+// connections opened afterwards, so that credentials can be rotated
+// without restarting the broker.
+type credentials struct {
+	args *RedisArgs
+
+	username string
+	password string
+	// Client certificate for mutual TLS, nil if not configured.
+	cert *tls.Certificate
+
+	// Watcher of the files credentials are read from, nil if none.
+	cfw fs.CachedFileWatcher
+
+	logger *zap.SugaredLogger
+	m      sync.RWMutex
+}
+
+// newCredentials reads the credentials informed at the arguments, failing
+// if the files they are read from are not valid.
+func newCredentials(args *RedisArgs, logger *zap.SugaredLogger) (*credentials, error) {
+	c := &credentials{
+		args:     args,
+		username: args.Username,
+		password: args.Password,
+		logger:   logger,
+	}
+
+	if args.UsernameFile == "" && args.PasswordFile == "" && args.TLSCertFile == "" {
+		return c, nil
+	}
+
+	cfw, err := fs.NewCachedFileWatcher(logger.Named("credentials"))
+	if err != nil {
+		return nil, fmt.Errorf("could not create credentials watcher: %w", err)
+	}
+	c.cfw = cfw
+
+	if args.UsernameFile != "" {
+		if err := c.watch(args.UsernameFile, c.updateUsername); err != nil {
+			return nil, err
+		}
+	}
+	if args.PasswordFile != "" {
+		if err := c.watch(args.PasswordFile, c.updatePassword); err != nil {
+			return nil, err
+		}
+	}
+	if args.TLSCertFile != "" {
+		// Either file changing reloads the certificate with its key.
+		update := func([]byte) error { return c.updateCertificate() }
+		if err := c.watch(args.TLSCertFile, update); err != nil {
+			return nil, err
+		}
+		if err := c.watch(args.TLSKeyFile, update); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// watch reads the file, then updates the credentials every time it
+// changes. Contents that are not valid are logged, keeping the former
+// credentials.
+func (c *credentials) watch(path string, update func(content []byte) error) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error resolving to absolute path %q: %w", path, err)
+	}
+	path = abs
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read credentials file: %w", err)
+	}
+	if err := update(content); err != nil {
+		return fmt.Errorf("credentials file %q is not valid: %w", path, err)
+	}
+
+	return c.cfw.Add(path, func(content []byte) {
+		if err := update(content); err != nil {
+			c.logger.Errorw("Could not update Redis credentials", zap.String("file", path), zap.Error(err))
+			return
+		}
+		c.logger.Infow("Updated Redis credentials", zap.String("file", path))
+	})
+}
+
+// start watching the files credentials are read from, until the context is
+// done.
+func (c *credentials) start(ctx context.Context) {
+	if c.cfw != nil {
+		c.cfw.Start(ctx)
+	}
+}
+
+func (c *credentials) updateUsername(content []byte) error {
+	v := strings.TrimSpace(string(content))
+	if v == "" {
+		return errors.New("username is empty")
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.username = v
+	return nil
+}
+
+func (c *credentials) updatePassword(content []byte) error {
+	v := strings.TrimSpace(string(content))
+	if v == "" {
+		return errors.New("password is empty")
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.password = v
+	return nil
+}
+
+func (c *credentials) updateCertificate() error {
+	cert, err := tls.LoadX509KeyPair(c.args.TLSCertFile, c.args.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("could not load client certificate: %w", err)
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.cert = &cert
+	return nil
+}
+
+// provider returns the current username and password, for Redis 6 ACL
+// users or the default user when the username is empty.
+func (c *credentials) provider() (string, string) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return c.username, c.password
+}
+
+// clientCertificate returns the current client certificate, which is
+// empty when not configured.
+func (c *credentials) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if c.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+	return c.cert, nil
+}
+
+// tlsConfig returns the TLS configuration for connections to Redis,
+// verifying the server using the CA certificates and presenting the client
+// certificate when informed.
+func (c *credentials) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.args.TLSSkipVerify,
+	}
+
+	if c.args.TLSCAFile != "" {
+		b, err := os.ReadFile(c.args.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("CA file %q does not contain PEM encoded certificates", c.args.TLSCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.args.TLSCertFile != "" {
+		cfg.GetClientCertificate = c.clientCertificate
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	usernameFile := filepath.Join(dir, "username")
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(usernameFile, []byte("triggermesh1\n"), 0o600))
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+
+	c, err := newCredentials(&RedisArgs{UsernameFile: usernameFile, PasswordFile: passwordFile}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	username, password := c.provider()
+	assert.Equal(t, "triggermesh1", username)
+	assert.Equal(t, "first", password)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.start(ctx)
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		_, password := c.provider()
+		return password == "second"
+	}, 5*time.Second, 10*time.Millisecond, "Rotated password was not applied")
+
+	// Contents that are not valid keep the former credentials.
+	require.NoError(t, os.WriteFile(passwordFile, nil, 0o600))
+	time.Sleep(100 * time.Millisecond)
+	_, password = c.provider()
+	assert.Equal(t, "second", password)
+
+	_, err = newCredentials(&RedisArgs{PasswordFile: filepath.Join(dir, "missing")}, zap.NewNop().Sugar())
+	assert.Error(t, err)
+}
+
+func TestCredentialsTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "first")
+
+	args := &RedisArgs{
+		TLSEnabled:  true,
+		TLSCAFile:   certFile,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	}
+
+	c, err := newCredentials(args, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	cfg, err := c.tlsConfig()
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.Equal(t, "first", clientCommonName(t, c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.start(ctx)
+
+	writeCertificate(t, certFile, keyFile, "second")
+	assert.Eventually(t, func() bool {
+		return clientCommonName(t, c) == "second"
+	}, 5*time.Second, 10*time.Millisecond, "Rotated client certificate was not applied")
+
+	// CA files must contain certificates.
+	require.NoError(t, os.WriteFile(keyFile+".ca", []byte("not a certificate"), 0o600))
+	c, err = newCredentials(&RedisArgs{TLSEnabled: true, TLSCAFile: keyFile + ".ca"}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	_, err = c.tlsConfig()
+	assert.Error(t, err)
+}
+
+func clientCommonName(t *testing.T, c *credentials) string {
+	cert, err := c.clientCertificate(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, cert.Certificate)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+// writeCertificate writes a self-signed certificate and its key.
+func writeCertificate(t *testing.T, certFile, keyFile, cn string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	kb, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	// The key is written first, so that the certificate changing reloads
+	// a matching pair.
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0o600))
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+}
@@ -0,0 +1,34 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Prefix added to the stream name for deduplication keys.
+const dedupKeyInfix = ".dedup."
+
+var _ backend.Deduplicator = (*redis)(nil)
+
+func (s *redis) MarkProduced(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.args.Stream+dedupKeyInfix+key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not register event key at Redis: %w", err)
+	}
+
+	return ok, nil
+}
+
+func (s *redis) UnmarkProduced(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.args.Stream+dedupKeyInfix+key).Err(); err != nil {
+		return fmt.Errorf("could not remove event key from Redis: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+)
+
+// Redis key at the message that informs the CloudEvent is encrypted, which
+// happens after compressing it. Messages written before enabling
+// encryption can still be read.
+const (
+	encryptionKey    = "ce-encryption"
+	encryptionAESGCM = "aes-gcm"
+)
+
+// errDecryption is returned when an encrypted CloudEvent cannot be
+// decrypted, which might be temporary when the key encryption key is
+// external.
+var errDecryption = errors.New("could not decrypt CloudEvent")
+
+var _ backend.EventEncrypter = (*redis)(nil)
+
+// SetCipher encrypts the CloudEvents stored at the stream, the archive and
+// the scheduled events set.
+func (s *redis) SetCipher(c *encryption.Cipher) {
+	s.cipher = c
+}
+
+// encrypt returns the encrypted value, or the value as is when encryption
+// is not enabled.
+func (s *redis) encrypt(ctx context.Context, b []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return b, nil
+	}
+
+	enc, err := s.cipher.Encrypt(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt CloudEvent: %w", err)
+	}
+	return enc, nil
+}
+
+// decryptScheduled returns the serialized CloudEvent stored at the scheduled
+// events set, which is not encrypted if it was scheduled before enabling
+// encryption.
+func (s *redis) decryptScheduled(ctx context.Context, b []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return b, nil
+	}
+
+	dec, err := s.cipher.Decrypt(ctx, b)
+	if errors.Is(err, encryption.ErrNotEncrypted) {
+		return b, nil
+	}
+	return dec, err
+}
+
+func decrypt(ctx context.Context, c *encryption.Cipher, b []byte) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("%w: encryption is not enabled", errDecryption)
+	}
+
+	dec, err := c.Decrypt(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecryption, err)
+	}
+	return dec, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestEncryption(t *testing.T) {
+	ctx := context.Background()
+
+	kr, err := encryption.NewKeyring(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	c := encryption.NewCipher(kr)
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithDataOption("application/json", []byte(`{"secret":"value"}`)))
+	b, err := ev.MarshalJSON()
+	require.NoError(t, err)
+
+	for _, comp := range []Compression{CompressionNone, CompressionZstd} {
+		t.Run(string(comp), func(t *testing.T) {
+			s := &redis{
+				args:   &RedisArgs{Compression: string(comp)},
+				cipher: c,
+				logger: zap.NewNop().Sugar(),
+			}
+
+			values, err := s.eventValues(ctx, b)
+			require.NoError(t, err)
+			assert.Equal(t, encryptionAESGCM, values[encryptionKey])
+			assert.NotContains(t, string(values[ceKey].([]byte)), "secret")
+
+			got, ok, err := eventFromValues(ctx, c, readValues(values))
+			require.True(t, ok)
+			require.NoError(t, err)
+			assert.Equal(t, ev.ID(), got.ID())
+			assert.Equal(t, ev.Data(), got.Data())
+
+			_, ok, err = eventFromValues(ctx, nil, readValues(values))
+			assert.True(t, ok)
+			assert.True(t, errors.Is(err, errDecryption), "Encrypted events must not be parsed without cipher")
+		})
+	}
+
+	// Events stored before enabling encryption are still read.
+	got, ok, err := eventFromValues(ctx, c, map[string]interface{}{ceKey: string(b)})
+	require.True(t, ok)
+	require.NoError(t, err)
+	assert.Equal(t, ev.ID(), got.ID())
+
+	s := &redis{cipher: c}
+	enc, err := s.encrypt(ctx, b)
+	require.NoError(t, err)
+	dec, err := s.decryptScheduled(ctx, enc)
+	require.NoError(t, err)
+	assert.Equal(t, b, dec)
+
+	dec, err = s.decryptScheduled(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, b, dec, "Events scheduled before enabling encryption must be kept")
+}
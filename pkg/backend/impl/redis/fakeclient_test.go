@@ -0,0 +1,101 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+
+	goredis "github.com/go-redis/redis/v9"
+)
+
+// fakeClient keeps the Redis commands used by the tests in memory.
+// Commands that are not implemented panic.
+type fakeClient struct {
+	goredis.Cmdable
+
+	hashes  map[string]map[string]int64
+	zsets   map[string]map[string]float64
+	streams map[string][]map[string]interface{}
+	acked   []string
+
+	// err is returned by every command when set.
+	err error
+	// xaddErr is returned when adding to streams when set.
+	xaddErr error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		hashes:  map[string]map[string]int64{},
+		zsets:   map[string]map[string]float64{},
+		streams: map[string][]map[string]interface{}{},
+	}
+}
+
+func (c *fakeClient) HIncrBy(_ context.Context, key, field string, incr int64) *goredis.IntCmd {
+	if c.err != nil {
+		return goredis.NewIntResult(0, c.err)
+	}
+	if c.hashes[key] == nil {
+		c.hashes[key] = map[string]int64{}
+	}
+	c.hashes[key][field] += incr
+	return goredis.NewIntResult(c.hashes[key][field], nil)
+}
+
+func (c *fakeClient) HDel(_ context.Context, key string, fields ...string) *goredis.IntCmd {
+	if c.err != nil {
+		return goredis.NewIntResult(0, c.err)
+	}
+	for _, f := range fields {
+		delete(c.hashes[key], f)
+	}
+	return goredis.NewIntResult(int64(len(fields)), nil)
+}
+
+func (c *fakeClient) XAdd(_ context.Context, a *goredis.XAddArgs) *goredis.StringCmd {
+	if c.err != nil {
+		return goredis.NewStringResult("", c.err)
+	}
+	if c.xaddErr != nil {
+		return goredis.NewStringResult("", c.xaddErr)
+	}
+	c.streams[a.Stream] = append(c.streams[a.Stream], a.Values.(map[string]interface{}))
+	return goredis.NewStringResult("1-0", nil)
+}
+
+func (c *fakeClient) XAck(_ context.Context, _, _ string, ids ...string) *goredis.IntCmd {
+	if c.err != nil {
+		return goredis.NewIntResult(0, c.err)
+	}
+	c.acked = append(c.acked, ids...)
+	return goredis.NewIntResult(int64(len(ids)), nil)
+}
+
+func (c *fakeClient) ZAdd(_ context.Context, key string, members ...goredis.Z) *goredis.IntCmd {
+	if c.err != nil {
+		return goredis.NewIntResult(0, c.err)
+	}
+	if c.zsets[key] == nil {
+		c.zsets[key] = map[string]float64{}
+	}
+	for _, z := range members {
+		c.zsets[key][z.Member.(string)] = z.Score
+	}
+	return goredis.NewIntResult(int64(len(members)), nil)
+}
+
+func (c *fakeClient) ZRem(_ context.Context, key string, members ...interface{}) *goredis.IntCmd {
+	if c.err != nil {
+		return goredis.NewIntResult(0, c.err)
+	}
+	var n int64
+	for _, m := range members {
+		if _, ok := c.zsets[key][m.(string)]; ok {
+			delete(c.zsets[key], m.(string))
+			n++
+		}
+	}
+	return goredis.NewIntResult(n, nil)
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Infix added to the stream name for the hashes of recorded deliveries.
+const fixturesKeyInfix = ".fixtures."
+
+var _ backend.FixtureStore = (*redis)(nil)
+
+func (s *redis) fixturesKey(trigger string) string {
+	return s.args.Stream + fixturesKeyInfix + trigger
+}
+
+func (s *redis) RecordFixture(ctx context.Context, trigger, key string, delivery []byte) error {
+	if err := s.client.HSet(ctx, s.fixturesKey(trigger), key, delivery).Err(); err != nil {
+		return fmt.Errorf("could not record fixture at Redis: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redis) Fixture(ctx context.Context, trigger, key string) ([]byte, error) {
+	b, err := s.client.HGet(ctx, s.fixturesKey(trigger), key).Bytes()
+	switch {
+	case errors.Is(err, goredis.Nil):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("could not read fixture from Redis: %w", err)
+	}
+
+	return b, nil
+}
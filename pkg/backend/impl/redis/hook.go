@@ -0,0 +1,56 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend/metrics"
+)
+
+// metricsHook instruments the Redis client reporting latencies per
+// command, pipeline sizes and connections established.
+type metricsHook struct {
+	reporter metrics.Reporter
+}
+
+var _ goredis.Hook = (*metricsHook)(nil)
+
+func (h *metricsHook) DialHook(next goredis.DialHook) goredis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		h.reporter.ReportConnection(err == nil)
+		return conn, err
+	}
+}
+
+func (h *metricsHook) ProcessHook(next goredis.ProcessHook) goredis.ProcessHook {
+	return func(ctx context.Context, cmd goredis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.reporter.ReportOperation(cmd.Name(), isSuccess(err), float64(time.Since(start)/time.Millisecond))
+		return err
+	}
+}
+
+func (h *metricsHook) ProcessPipelineHook(next goredis.ProcessPipelineHook) goredis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []goredis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.reporter.ReportPipeline(len(cmds))
+		h.reporter.ReportOperation("pipeline", isSuccess(err), float64(time.Since(start)/time.Millisecond))
+		return err
+	}
+}
+
+// isSuccess considers empty results, like those returned when a blocking
+// read expires without data, as successful operations.
+func isSuccess(err error) bool {
+	return err == nil || errors.Is(err, goredis.Nil)
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	goredis "github.com/go-redis/redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Number of pending messages acknowledged at once when draining.
+const drainPageSize = 100
+
+var _ backend.SubscriptionInspector = (*redis)(nil)
+
+// Subscriptions returns the state of the consumer groups of the stream that
+// belong to subscriptions. The number of messages not yet read is only
+// reported by Redis 7 or newer.
+func (s *redis) Subscriptions(ctx context.Context) ([]backend.SubscriptionState, error) {
+	groups, err := s.client.XInfoGroups(ctx, s.args.Stream).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read consumer groups from Redis: %w", err)
+	}
+
+	prefix := s.args.Group + "."
+	states := make([]backend.SubscriptionState, 0, len(groups))
+	for _, g := range groups {
+		if !strings.HasPrefix(g.Name, prefix) {
+			continue
+		}
+		states = append(states, backend.SubscriptionState{
+			Name:      strings.TrimPrefix(g.Name, prefix),
+			Lag:       g.Lag,
+			Pending:   g.Pending,
+			Consumers: g.Consumers,
+		})
+	}
+	return states, nil
+}
+
+// PendingEvents returns the messages at the pending entries list of the
+// subscription consumer group, along with the CloudEvents they contain.
+func (s *redis) PendingEvents(ctx context.Context, name string, n int) ([]backend.PendingEvent, error) {
+	group := s.args.Group + "." + name
+	pending, err := s.client.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: s.args.Stream,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(n),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read pending messages for %q: %w", name, err)
+	}
+
+	events := make([]backend.PendingEvent, 0, len(pending))
+	for _, p := range pending {
+		pe := backend.PendingEvent{
+			BackendID:  p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			Deliveries: p.RetryCount,
+		}
+
+		// Messages trimmed from the stream stay pending until
+		// acknowledged.
+		msgs, err := s.client.XRangeN(ctx, s.args.Stream, p.ID, p.ID, 1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("could not read pending message %s: %w", p.ID, err)
+		}
+		if len(msgs) != 0 {
+			ce, _, err := eventFromValues(ctx, s.cipher, msgs[0].Values)
+			if err != nil {
+				s.logger.Debugw("Could not read CloudEvent from pending message", zap.String("id", p.ID), zap.Error(err))
+			}
+			pe.Event = ce
+		}
+
+		events = append(events, pe)
+	}
+	return events, nil
+}
+
+// DrainSubscription moves the last delivered ID of the subscription
+// consumer group to the end of the stream, and acknowledges the messages
+// left pending.
+func (s *redis) DrainSubscription(ctx context.Context, name string) (int64, error) {
+	group := s.args.Group + "." + name
+
+	states, err := s.Subscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64 = -1
+	for _, st := range states {
+		if st.Name == name {
+			n = st.Lag
+		}
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("subscription %q does not exist at the backend", name)
+	}
+
+	if err := s.client.XGroupSetID(ctx, s.args.Stream, group, "$").Err(); err != nil {
+		return 0, fmt.Errorf("could not move consumer group for %q to the end of the stream: %w", name, err)
+	}
+
+	for {
+		pending, err := s.client.XPendingExt(ctx, &goredis.XPendingExtArgs{
+			Stream: s.args.Stream,
+			Group:  group,
+			Start:  "-",
+			End:    "+",
+			Count:  drainPageSize,
+		}).Result()
+		if err != nil {
+			return n, fmt.Errorf("could not read pending messages for %q: %w", name, err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		ids := make([]string, 0, len(pending))
+		for _, p := range pending {
+			ids = append(ids, p.ID)
+		}
+		acked, err := s.client.XAck(ctx, s.args.Stream, group, ids...).Result()
+		if err != nil {
+			return n, fmt.Errorf("could not acknowledge pending messages for %q: %w", name, err)
+		}
+		n += acked
+		if acked == 0 {
+			break
+		}
+	}
+
+	if err := s.client.Del(ctx, deliveriesKey(s.args.Stream, group)).Err(); err != nil {
+		return n, fmt.Errorf("could not delete delivery counters for %q: %w", name, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Suffix added to the stream name for the keys that store the replica
+	// holding the lease of each trigger, followed by the trigger name.
+	triggerLeaseKeySuffix = ".lease."
+	// Suffix added to the stream name for the key that stores the leader
+	// replica.
+	leaderLeaseKeySuffix = ".leader"
+)
+
+var (
+	_ backend.TriggerLeaser = (*redis)(nil)
+	_ backend.LeaderElector = (*redis)(nil)
+)
+
+// Renews the lease when held by the replica, or grants it if not held.
+var acquireLeaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// Removes the lease only when held by the replica.
+var releaseLeaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (s *redis) AcquireTriggerLease(ctx context.Context, trigger, replica string, lease time.Duration) (bool, error) {
+	ok, err := s.acquireLease(ctx, s.args.Stream+triggerLeaseKeySuffix+trigger, replica, lease)
+	if err != nil {
+		return false, fmt.Errorf("could not acquire trigger lease at Redis: %w", err)
+	}
+	return ok, nil
+}
+
+func (s *redis) ReleaseTriggerLease(ctx context.Context, trigger, replica string) error {
+	if err := s.releaseLease(ctx, s.args.Stream+triggerLeaseKeySuffix+trigger, replica); err != nil {
+		return fmt.Errorf("could not release trigger lease at Redis: %w", err)
+	}
+	return nil
+}
+
+func (s *redis) AcquireLeaderLease(ctx context.Context, replica string, lease time.Duration) (bool, error) {
+	ok, err := s.acquireLease(ctx, s.args.Stream+leaderLeaseKeySuffix, replica, lease)
+	if err != nil {
+		return false, fmt.Errorf("could not acquire leader lease at Redis: %w", err)
+	}
+	return ok, nil
+}
+
+func (s *redis) ReleaseLeaderLease(ctx context.Context, replica string) error {
+	if err := s.releaseLease(ctx, s.args.Stream+leaderLeaseKeySuffix, replica); err != nil {
+		return fmt.Errorf("could not release leader lease at Redis: %w", err)
+	}
+	return nil
+}
+
+func (s *redis) acquireLease(ctx context.Context, key, replica string, lease time.Duration) (bool, error) {
+	// Replicas that stop dispatching leave their pending messages behind,
+	// which only scaling claims for other replicas.
+	if !s.args.ScalingEnabled {
+		return false, errors.New("leases require Redis scaling to be enabled")
+	}
+
+	n, err := acquireLeaseScript.Run(ctx, s.client, []string{key}, replica, lease.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (s *redis) releaseLease(ctx context.Context, key, replica string) error {
+	return releaseLeaseScript.Run(ctx, s.client, []string{key}, replica).Err()
+}
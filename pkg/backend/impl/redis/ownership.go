@@ -0,0 +1,87 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+	"go.uber.org/zap"
+)
+
+// When scaling, replicas coordinate the ownership of the consumers at each
+// trigger group: pending messages of replicas that are gone are claimed by
+// the live ones, then the consumers of those replicas, left without
+// messages, are deleted, so that groups do not accumulate a consumer per
+// replica ever started. Replicas that stop consuming a trigger delete their
+// own consumer when they leave no messages pending.
+
+// staleConsumers returns the consumers other than the instance that have no
+// pending messages and have been idle for at least the minimum time.
+func staleConsumers(consumers []goredis.XInfoConsumer, instance string, minIdle time.Duration) []string {
+	stale := []string{}
+	for _, c := range consumers {
+		if c.Name == instance || c.Pending != 0 || c.Idle < minIdle {
+			continue
+		}
+		stale = append(stale, c.Name)
+	}
+	return stale
+}
+
+// reapConsumers deletes the stale consumers of the group, once their
+// pending messages have been claimed.
+func (s *subscription) reapConsumers() {
+	consumers, err := s.client.XInfoConsumers(s.ctx, s.stream, s.group).Result()
+	if err != nil {
+		s.logger.Errorw("Error listing consumers of consumer group", zap.String("group", s.group), zap.Error(err))
+		return
+	}
+
+	for _, name := range staleConsumers(consumers, s.instance, s.claimMinIdle) {
+		if err := s.deleteConsumer(s.ctx, name); err != nil {
+			s.logger.Errorw("Error deleting stale consumer", zap.String("group", s.group),
+				zap.String("consumer", name), zap.Error(err))
+			continue
+		}
+		s.logger.Infow("Deleted stale consumer", zap.String("group", s.group), zap.String("consumer", name))
+	}
+}
+
+// leave deletes the consumer of the instance at the group if it has no
+// pending messages, which are otherwise left for other replicas to claim.
+func (s *subscription) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+
+	consumers, err := s.client.XInfoConsumers(ctx, s.stream, s.group).Result()
+	if err != nil {
+		s.logger.Errorw("Error listing consumers of consumer group", zap.String("group", s.group), zap.Error(err))
+		return
+	}
+
+	for _, c := range consumers {
+		if c.Name != s.instance || c.Pending != 0 {
+			continue
+		}
+		if err := s.deleteConsumer(ctx, c.Name); err != nil {
+			s.logger.Errorw("Error leaving consumer group", zap.String("group", s.group), zap.Error(err))
+		}
+		return
+	}
+}
+
+// Deletes the consumer only if it has no pending messages, which would be
+// lost otherwise.
+var deleteConsumerScript = goredis.NewScript(`
+if #redis.call("XPENDING", KEYS[1], ARGV[1], "-", "+", 1, ARGV[2]) == 0 then
+	return redis.call("XGROUP", "DELCONSUMER", KEYS[1], ARGV[1], ARGV[2])
+end
+return -1
+`)
+
+func (s *subscription) deleteConsumer(ctx context.Context, name string) error {
+	return deleteConsumerScript.Run(ctx, s.client, []string{s.stream}, s.group, name).Err()
+}
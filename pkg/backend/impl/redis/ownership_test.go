@@ -0,0 +1,55 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleConsumers(t *testing.T) {
+	tcs := map[string]struct {
+		consumers []goredis.XInfoConsumer
+		expected  []string
+	}{
+		"no consumers": {
+			expected: []string{},
+		},
+		"idle without pending messages": {
+			consumers: []goredis.XInfoConsumer{{Name: "gone", Idle: time.Hour}},
+			expected:  []string{"gone"},
+		},
+		"idle with pending messages": {
+			consumers: []goredis.XInfoConsumer{{Name: "gone", Pending: 3, Idle: time.Hour}},
+			expected:  []string{},
+		},
+		"recently active": {
+			consumers: []goredis.XInfoConsumer{{Name: "live", Idle: time.Second}},
+			expected:  []string{},
+		},
+		"own consumer": {
+			consumers: []goredis.XInfoConsumer{{Name: "self", Idle: time.Hour}},
+			expected:  []string{},
+		},
+		"several consumers": {
+			consumers: []goredis.XInfoConsumer{
+				{Name: "self", Idle: time.Hour},
+				{Name: "live", Idle: time.Second},
+				{Name: "gone-1", Idle: time.Hour},
+				{Name: "gone-2", Idle: 5 * time.Minute},
+				{Name: "claimable", Pending: 1, Idle: time.Hour},
+			},
+			expected: []string{"gone-1", "gone-2"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, staleConsumers(tc.consumers, "self", 5*time.Minute))
+		})
+	}
+}
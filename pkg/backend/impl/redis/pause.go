@@ -0,0 +1,51 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Period for checking whether paused subscriptions were resumed.
+const pausedCheckPeriod = time.Second
+
+// PauseSubscription stops reading messages for the subscription, which
+// accumulate at the stream, subject to trimming. Messages already read are
+// dispatched.
+func (s *redis) PauseSubscription(name string) error {
+	return s.setPaused(name, true)
+}
+
+// ResumeSubscription reads again messages for the subscription, starting
+// with those produced while paused.
+func (s *redis) ResumeSubscription(name string) error {
+	return s.setPaused(name, false)
+}
+
+func (s *redis) setPaused(name string, paused bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub, ok := s.subs[name]
+	if !ok {
+		return fmt.Errorf("subscription for %q does not exist", name)
+	}
+
+	if sub.paused.Swap(paused) != paused {
+		s.logger.Infow("Subscription pause changed", zap.String("group", sub.group), zap.Bool("paused", paused))
+	}
+	return nil
+}
+
+// waitPaused waits before checking again whether the subscription was
+// resumed, returning early when the subscription finishes.
+func (s *subscription) waitPaused() {
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(pausedCheckPeriod):
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	goredis "github.com/go-redis/redis/v9"
+)
+
+const (
+	// Suffix added to the stream name for the stream of messages that
+	// exceeded their maximum deliveries.
+	quarantineStreamSuffix = ".quarantine"
+
+	// Suffix added to the stream and consumer group names for the hash
+	// that counts the deliveries of messages left pending.
+	deliveriesKeySuffix = ".deliveries"
+)
+
+// deliveriesKey is the hash that counts the deliveries of the messages left
+// pending at the consumer group. Redis delivery counters are not used since
+// they are not incremented when consumers read their own pending messages
+// after restarting.
+func deliveriesKey(stream, group string) string {
+	return stream + "." + group + deliveriesKeySuffix
+}
+
+// quarantine counts a delivery of the message left pending, and moves it
+// to the quarantine stream when it exceeds the maximum deliveries, in which
+// case the message must not be dispatched. The first delivery, whose
+// message is read as new, is not counted at the hash.
+func (s *subscription) quarantine(msg goredis.XMessage) bool {
+	if s.maxDeliveries == 0 {
+		return false
+	}
+
+	// Messages being dispatched are skipped when dispatching.
+	if _, ok := s.inFlight.Load(msg.ID); ok {
+		return false
+	}
+
+	key := deliveriesKey(s.stream, s.group)
+	n, err := s.client.HIncrBy(s.ctx, key, msg.ID, 1).Result()
+	if err != nil {
+		s.logger.Errorw("Could not count the deliveries of a pending message",
+			zap.String("group", s.group), zap.String("id", msg.ID), zap.Error(err))
+		return false
+	}
+	if n < int64(s.maxDeliveries) {
+		return false
+	}
+
+	values := messageValues(msg)
+	values["trigger"] = s.name
+	values["id"] = msg.ID
+	values["deliveries"] = n
+
+	// Events moved while the subscription is stopping must still be
+	// acknowledged, hence the subscription context is not used.
+	ctx := context.Background()
+	if err := s.client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: s.stream + quarantineStreamSuffix,
+		Values: values,
+	}).Err(); err != nil {
+		s.logger.Errorw("Could not move a message to the quarantine stream",
+			zap.String("group", s.group), zap.String("id", msg.ID), zap.Error(err))
+		return false
+	}
+
+	if err := s.ack(msg.ID); err != nil {
+		s.logger.Errorw(fmt.Sprintf("could not ACK the quarantined Redis message %s", msg.ID), zap.Error(err))
+	}
+	s.forgetDeliveries(msg.ID)
+
+	s.logger.Warnw("Moved message that exceeded its maximum deliveries to the quarantine stream",
+		zap.String("group", s.group),
+		zap.String("id", msg.ID),
+		zap.Int64("deliveries", n),
+		zap.String("stream", s.stream+quarantineStreamSuffix))
+	if s.reporter != nil {
+		s.reporter.ReportQuarantined(s.name)
+	}
+
+	return true
+}
+
+// messageValues returns the CloudEvent of the message along with its
+// encoding, to be moved to another stream.
+func messageValues(msg goredis.XMessage) map[string]interface{} {
+	values := map[string]interface{}{}
+	if ce, ok := msg.Values[ceKey]; ok {
+		values[ceKey] = ce
+	}
+	if enc, ok := msg.Values[encodingKey]; ok {
+		values[encodingKey] = enc
+	}
+	if enc, ok := msg.Values[encryptionKey]; ok {
+		values[encryptionKey] = enc
+	}
+	return values
+}
+
+// forgetDeliveries removes the delivery counter of an acknowledged message.
+func (s *subscription) forgetDeliveries(id string) {
+	if s.maxDeliveries == 0 {
+		return
+	}
+	if err := s.client.HDel(context.Background(), deliveriesKey(s.stream, s.group), id).Err(); err != nil {
+		s.logger.Errorw("Could not remove the delivery counter of a message",
+			zap.String("group", s.group), zap.String("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	goredis "github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestQuarantine(t *testing.T) {
+	msg := goredis.XMessage{
+		ID: "1-0",
+		Values: map[string]interface{}{
+			ceKey:       `{"specversion":"1.0"}`,
+			encodingKey: "gzip",
+			"other":     "discarded",
+		},
+	}
+
+	tcs := map[string]struct {
+		maxDeliveries int
+		inFlight      bool
+		deliveries    int
+		err           error
+
+		quarantined bool
+		counted     int64
+	}{
+		"max deliveries not set": {
+			deliveries: 10,
+		},
+		"below max deliveries": {
+			maxDeliveries: 3,
+			deliveries:    2,
+			counted:       2,
+		},
+		"exceeds max deliveries": {
+			maxDeliveries: 3,
+			deliveries:    3,
+			quarantined:   true,
+		},
+		"message in flight": {
+			maxDeliveries: 1,
+			inFlight:      true,
+			deliveries:    5,
+		},
+		"redis failing": {
+			maxDeliveries: 1,
+			deliveries:    5,
+			err:           errors.New("connection refused"),
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := newFakeClient()
+			client.err = tc.err
+
+			s := &subscription{
+				name:          "trigger1",
+				stream:        "stream",
+				group:         "group",
+				maxDeliveries: tc.maxDeliveries,
+				inFlight:      &sync.Map{},
+				ctx:           context.Background(),
+				client:        client,
+				logger:        zap.NewNop().Sugar(),
+			}
+			if tc.inFlight {
+				s.inFlight.Store(msg.ID, struct{}{})
+			}
+
+			var quarantined bool
+			for i := 0; i < tc.deliveries; i++ {
+				quarantined = s.quarantine(msg)
+				if quarantined {
+					break
+				}
+			}
+
+			assert.Equal(t, tc.quarantined, quarantined)
+			assert.Equal(t, tc.counted, client.hashes[deliveriesKey("stream", "group")][msg.ID])
+
+			moved := client.streams["stream"+quarantineStreamSuffix]
+			if !tc.quarantined {
+				assert.Empty(t, moved)
+				assert.Empty(t, client.acked)
+				return
+			}
+
+			assert.Equal(t, []string{msg.ID}, client.acked)
+			assert.Equal(t, []map[string]interface{}{{
+				ceKey:        msg.Values[ceKey],
+				encodingKey:  "gzip",
+				"trigger":    "trigger1",
+				"id":         msg.ID,
+				"deliveries": int64(3),
+			}}, moved)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+)
+
+// LastEvents reads the newest events at the stream, which retains them
+// after being dispatched until trimmed.
+func (s *redis) LastEvents(ctx context.Context, n int) ([]cloudevents.Event, error) {
+	msgs, err := s.client.XRevRangeN(ctx, s.args.Stream, "+", "-", int64(n)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read events from the stream: %w", err)
+	}
+
+	events := make([]cloudevents.Event, 0, len(msgs))
+	for _, msg := range msgs {
+		ce, ok, err := eventFromValues(ctx, s.cipher, msg.Values)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			s.logger.Debugw("Skipping non CloudEvent message from the stream", zap.String("id", msg.ID), zap.Error(err))
+			continue
+		}
+		events = append(events, *ce)
+	}
+
+	return events, nil
+}
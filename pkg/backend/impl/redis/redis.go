@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -18,11 +19,15 @@ import (
 	goredis "github.com/go-redis/redis/v9"
 
 	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/metrics"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
 )
 
 const (
 	// Starting point for the consumer group.
 	groupStartID = "$"
+	// Starting point for consumer groups that replay the stream.
+	groupEarliestID = "0"
 
 	// Redis key at the message that contains the CloudEvent.
 	ceKey = "ce"
@@ -61,6 +66,16 @@ type redis struct {
 	// when the broker is shutting down.
 	disconnecting bool
 
+	// Events older than this age are trimmed from the stream.
+	maxAge time.Duration
+	// Events beyond the retention limits are trimmed from the stream.
+	retention backend.Retention
+
+	// cipher encrypts stored events, nil if not enabled.
+	cipher *encryption.Cipher
+
+	reporter metrics.Reporter
+
 	ctx    context.Context
 	logger *zap.SugaredLogger
 	mutex  sync.Mutex
@@ -68,39 +83,59 @@ type redis struct {
 
 func (s *redis) Info() *backend.Info {
 	return &backend.Info{
-		Name: "Redis",
+		Name:         "Redis",
+		Durable:      true,
+		MaxEventSize: s.args.MaxEntrySize,
 	}
 }
 
 func (s *redis) Init(ctx context.Context) error {
+	creds, err := newCredentials(s.args, s.logger)
+	if err != nil {
+		return fmt.Errorf("could not setup Redis credentials: %w", err)
+	}
+
 	var tlscfg *tls.Config
 	if s.args.TLSEnabled {
-		tlscfg = &tls.Config{
-			MinVersion:         tls.VersionTLS12,
-			InsecureSkipVerify: s.args.TLSSkipVerify,
+		if tlscfg, err = creds.tlsConfig(); err != nil {
+			return fmt.Errorf("could not setup Redis TLS: %w", err)
 		}
 	}
+	creds.start(ctx)
+
+	reporter, err := metrics.NewReporter(ctx, s.Info().Name, s.logger)
+	if err != nil {
+		return fmt.Errorf("could not setup backend stats reporter: %w", err)
+	}
+	s.reporter = reporter
 
 	if len(s.args.ClusterAddresses) != 0 {
 		s.logger.Info("Cluster client")
 		clusterclient := goredis.NewClusterClient(&goredis.ClusterOptions{
-			Addrs:     s.args.ClusterAddresses,
-			Username:  s.args.Username,
-			Password:  s.args.Password,
+			Addrs: s.args.ClusterAddresses,
+			// Cluster options do not support a credentials provider,
+			// which is set at each node client.
+			NewClient: func(opt *goredis.Options) *goredis.Client {
+				opt.CredentialsProvider = creds.provider
+				return goredis.NewClient(opt)
+			},
 			TLSConfig: tlscfg,
 		})
 
+		clusterclient.AddHook(&metricsHook{reporter: reporter})
+
 		s.clientClose = clusterclient.Close
 		s.client = clusterclient
 	} else {
 		client := goredis.NewClient(&goredis.Options{
-			Addr:      s.args.Address,
-			Username:  s.args.Username,
-			Password:  s.args.Password,
-			DB:        s.args.Database,
-			TLSConfig: tlscfg,
+			Addr:                s.args.Address,
+			CredentialsProvider: creds.provider,
+			DB:                  s.args.Database,
+			TLSConfig:           tlscfg,
 		})
 
+		client.AddHook(&metricsHook{reporter: reporter})
+
 		s.clientClose = client.Close
 		s.client = client
 	}
@@ -119,7 +154,24 @@ func (s *redis) Init(ctx context.Context) error {
 
 func (s *redis) Start(ctx context.Context) error {
 	s.ctx = ctx
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runScheduler(ctx)
+	}()
+
+	if s.maxAge > 0 || s.retention.Limited() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runTrimmer(ctx)
+		}()
+	}
+
 	<-ctx.Done()
+	wg.Wait()
 
 	// This prevents new subscriptions from being setup
 	s.disconnecting = true
@@ -151,26 +203,28 @@ func (s *redis) Start(ctx context.Context) error {
 }
 
 func (s *redis) Produce(ctx context.Context, event *cloudevents.Event) error {
-	b, err := event.MarshalJSON()
+	b, err := s.marshal(event)
 	if err != nil {
-		return fmt.Errorf("could not serialize CloudEvent: %w", err)
+		return err
 	}
 
-	args := &goredis.XAddArgs{
-		Stream: s.args.Stream,
-		Values: map[string]interface{}{ceKey: b},
-	}
+	// Invalid scheduling extensions are expected to be rejected at ingest,
+	// events that inform them are delivered right away.
+	if at, ok, _ := backend.ScheduledTime(event, time.Now()); ok {
+		if err := s.schedule(ctx, b, at); err != nil {
+			return fmt.Errorf("could not schedule CloudEvent at backend: %w", err)
+		}
 
-	if s.args.StreamMaxLen != 0 {
-		args.MaxLen = int64(s.args.StreamMaxLen)
-		args.Approx = true
+		s.logger.Debug(fmt.Sprintf("CloudEvent %s/%s scheduled for %s",
+			event.Context.GetSource(),
+			event.Context.GetID(),
+			at.Format(time.RFC3339)))
+		return nil
 	}
 
-	res := s.client.XAdd(ctx, args)
-
-	id, err := res.Result()
+	id, err := s.xadd(ctx, b)
 	if err != nil {
-		return fmt.Errorf("could not produce CloudEvent to backend: %w", err)
+		return err
 	}
 
 	s.logger.Debug(fmt.Sprintf("CloudEvent %s/%s produced to the backend as %s",
@@ -181,7 +235,57 @@ func (s *redis) Produce(ctx context.Context, event *cloudevents.Event) error {
 	return nil
 }
 
-func (s *redis) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
+// marshal serializes the event, which must not exceed the maximum entry
+// size.
+func (s *redis) marshal(event *cloudevents.Event) ([]byte, error) {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize CloudEvent: %w", err)
+	}
+
+	if s.args.MaxEntrySize > 0 && len(b) > s.args.MaxEntrySize {
+		return nil, fmt.Errorf("serialized CloudEvent of %d bytes exceeds the maximum entry size of %d bytes: %w",
+			len(b), s.args.MaxEntrySize, backend.ErrEventTooLarge)
+	}
+
+	return b, nil
+}
+
+// xadd adds the serialized event to the stream.
+func (s *redis) xadd(ctx context.Context, b []byte) (string, error) {
+	args, err := s.xaddArgs(ctx, b)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := s.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", fmt.Errorf("could not produce CloudEvent to backend: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *redis) xaddArgs(ctx context.Context, b []byte) (*goredis.XAddArgs, error) {
+	values, err := s.eventValues(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &goredis.XAddArgs{
+		Stream: s.args.Stream,
+		Values: values,
+	}
+
+	if s.args.StreamMaxLen != 0 {
+		args.MaxLen = int64(s.args.StreamMaxLen)
+		args.Approx = true
+	}
+
+	return args, nil
+}
+
+func (s *redis) Subscribe(name string, ccb backend.ConsumerDispatcher, opts ...backend.SubscribeOption) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -194,9 +298,16 @@ func (s *redis) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
 		return fmt.Errorf("subscription for %q alredy exists", name)
 	}
 
-	// Create the consumer group for this subscription.
+	so := backend.NewSubscribeOptions(opts...)
+
+	// Create the consumer group for this subscription. Groups that already
+	// exist keep their cursor regardless of the starting offset.
 	group := s.args.Group + "." + name
-	res := s.client.XGroupCreateMkStream(s.ctx, s.args.Stream, group, groupStartID)
+	startID := groupStartID
+	if so.StartingOffset == backend.StartingOffsetEarliest {
+		startID = groupEarliestID
+	}
+	res := s.client.XGroupCreateMkStream(s.ctx, s.args.Stream, group, startID)
 	_, err := res.Result()
 	if err != nil {
 		// Ignore errors when the group already exists.
@@ -217,8 +328,24 @@ func (s *redis) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
 		name:     name,
 		group:    group,
 
+		claimMinIdle: s.args.ClaimMinIdleTimeDuration,
+		claimPeriod:  s.args.ClaimPeriodDuration,
+		scaling:      s.args.ScalingEnabled,
+		inFlight:     &sync.Map{},
+		paused:       &atomic.Bool{},
+
+		maxDeliveries: s.args.MaxDeliveries,
+		reporter:      s.reporter,
+
+		startupClaimPolicy: StartupClaimPolicy(s.args.StartupClaimPolicy),
+		startupClaimMaxAge: s.args.StartupClaimMaxAgeDuration,
+
 		// caller's callback for dispatching events from Redis.
 		ccbDispatch: ccb,
+		cipher:      s.cipher,
+
+		orderingKey: so.OrderingKey,
+		sequencer:   backend.NewSequencer(),
 
 		// cancel function let us control when we want to exit the subscription loop.
 		ctx:    ctx,
@@ -230,6 +357,8 @@ func (s *redis) Subscribe(name string, ccb backend.ConsumerDispatcher) error {
 		logger: s.logger,
 	}
 
+	subs.paused.Store(so.Paused)
+
 	s.subs[name] = subs
 	s.wgSubs.Add(1)
 	subs.start()
@@ -262,6 +391,9 @@ func (s *redis) unsubscribe(name string) {
 		s.logger.Debugw("Graceful shutdown of subscription", zap.String("name", name))
 
 		// Clean exit.
+		if sub.scaling {
+			sub.leave()
+		}
 	case <-time.After(unsubscribeTimeout):
 		// Timed out, some events have not been delivered.
 		s.logger.Errorw(fmt.Sprintf("Unsubscribing from Redis timed out after %d", unsubscribeTimeout),
@@ -272,6 +404,21 @@ func (s *redis) unsubscribe(name string) {
 	s.wgSubs.Done()
 }
 
+var _ backend.SubscriptionPurger = (*redis)(nil)
+
+// PurgeSubscription destroys the consumer group of the subscription, which
+// is kept after unsubscribing.
+func (s *redis) PurgeSubscription(ctx context.Context, name string) error {
+	group := s.args.Group + "." + name
+	if err := s.client.XGroupDestroy(ctx, s.args.Stream, group).Err(); err != nil {
+		return fmt.Errorf("could not destroy consumer group for %q: %w", name, err)
+	}
+	if err := s.client.Del(ctx, deliveriesKey(s.args.Stream, group)).Err(); err != nil {
+		return fmt.Errorf("could not delete delivery counters for %q: %w", name, err)
+	}
+	return nil
+}
+
 func (s *redis) Probe(ctx context.Context) error {
 	res := s.client.ClientID(ctx)
 	id, err := res.Result()
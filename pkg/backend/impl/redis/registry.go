@@ -0,0 +1,46 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+)
+
+// Definition of the Redis backend at the registry.
+var Definition = registry.Definition{
+	Name:    "redis",
+	NewArgs: func() registry.Args { return &RedisArgs{} },
+	New:     newBackend,
+}
+
+func init() {
+	registry.Register(Definition)
+}
+
+func newBackend(a registry.Args, hosted string, o registry.Options) backend.Interface {
+	args := *a.(*RedisArgs)
+
+	// Use the broker name as Redis instance at the consumer group.
+	// TODO add namespace to instance name when running at kubernetes
+	args.Instance = o.BrokerName
+
+	// When scaling, each replica must use an unique consumer name at
+	// the consumer group.
+	if args.ScalingEnabled {
+		args.Instance = args.ConsumerName
+	}
+
+	logger := o.Logger.Named("redis")
+
+	// Hosted brokers use their own stream, suffixed with their name.
+	if hosted != "" {
+		args.Stream += "." + hosted
+		logger = logger.With(zap.String("broker", hosted))
+	}
+
+	return New(&args, logger)
+}
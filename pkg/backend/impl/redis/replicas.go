@@ -0,0 +1,53 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Suffix added to the stream name for the sorted set of replicas, scored
+// by the expiry of their lease in Unix milliseconds.
+const replicasKeySuffix = ".replicas"
+
+var _ backend.ReplicaRegistry = (*redis)(nil)
+
+func (s *redis) RenewReplicaLease(ctx context.Context, replica string, lease time.Duration) ([]string, error) {
+	// Replicas that stop dispatching a trigger leave their pending
+	// messages behind, which only scaling claims for other replicas.
+	if !s.args.ScalingEnabled {
+		return nil, errors.New("replica leases require Redis scaling to be enabled")
+	}
+
+	key := s.args.Stream + replicasKeySuffix
+	now := time.Now()
+
+	var replicas *goredis.StringSliceCmd
+	_, err := s.client.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		p.ZAdd(ctx, key, goredis.Z{Score: float64(now.Add(lease).UnixMilli()), Member: replica})
+		p.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		replicas = p.ZRange(ctx, key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not renew replica lease at Redis: %w", err)
+	}
+
+	return replicas.Val(), nil
+}
+
+func (s *redis) ReleaseReplicaLease(ctx context.Context, replica string) error {
+	if err := s.client.ZRem(ctx, s.args.Stream+replicasKeySuffix, replica).Err(); err != nil {
+		return fmt.Errorf("could not release replica lease at Redis: %w", err)
+	}
+	return nil
+}
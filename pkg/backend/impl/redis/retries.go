@@ -0,0 +1,86 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Infix added to the stream name for the hashes that keep the retry
+	// state of events, followed by the trigger name and the event key.
+	retriesKeyInfix = ".retries."
+
+	// Time retry states are kept once their retry is due, after which
+	// events dispatched again start their retries over.
+	retryStateRetention = time.Hour
+
+	retryStateAttemptsField  = "attempts"
+	retryStateNextRetryField = "next"
+)
+
+var _ backend.RetryStateStore = (*redis)(nil)
+
+func (s *redis) retriesKey(trigger, key string) string {
+	return s.args.Stream + retriesKeyInfix + trigger + "." + key
+}
+
+func (s *redis) SaveRetryState(ctx context.Context, trigger, key string, state backend.RetryState) error {
+	rkey := s.retriesKey(trigger, key)
+	ttl := time.Until(state.NextRetry)
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	_, err := s.client.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		p.HSet(ctx, rkey,
+			retryStateAttemptsField, state.Attempts,
+			retryStateNextRetryField, state.NextRetry.UnixMilli())
+		p.PExpire(ctx, rkey, ttl+retryStateRetention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not save retry state at Redis: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redis) RetryState(ctx context.Context, trigger, key string) (*backend.RetryState, error) {
+	fields, err := s.client.HGetAll(ctx, s.retriesKey(trigger, key)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read retry state from Redis: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	attempts, err := strconv.Atoi(fields[retryStateAttemptsField])
+	if err != nil {
+		return nil, fmt.Errorf("retry state attempts are not valid: %w", err)
+	}
+	next, err := strconv.ParseInt(fields[retryStateNextRetryField], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("retry state next retry is not valid: %w", err)
+	}
+
+	return &backend.RetryState{
+		Attempts:  attempts,
+		NextRetry: time.UnixMilli(next),
+	}, nil
+}
+
+func (s *redis) DeleteRetryState(ctx context.Context, trigger, key string) error {
+	if err := s.client.Del(ctx, s.retriesKey(trigger, key)).Err(); err != nil {
+		return fmt.Errorf("could not delete retry state at Redis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	goredis "github.com/go-redis/redis/v9"
+)
+
+const (
+	// Suffix for the sorted set key that holds scheduled events.
+	scheduledKeySuffix = ".scheduled"
+
+	// Period for checking scheduled events that are due.
+	schedulePollPeriod = time.Second
+
+	// Maximum number of scheduled events moved to the stream on each check.
+	scheduleBatchSize = 100
+)
+
+func (s *redis) scheduledKey() string {
+	return s.args.Stream + scheduledKeySuffix
+}
+
+// schedule adds the serialized event to the sorted set of scheduled events
+// using the delivery time as score. Events are encrypted when enabled, and
+// compressed only when moved to the stream.
+func (s *redis) schedule(ctx context.Context, b []byte, at time.Time) error {
+	b, err := s.encrypt(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	return s.client.ZAdd(ctx, s.scheduledKey(), goredis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: b,
+	}).Err()
+}
+
+// runScheduler moves due events from the sorted set to the stream until the
+// context is done.
+//
+// Each event is removed from the sorted set before being added to the stream,
+// only the instance that succeeds removing it will produce the event, which
+// makes it safe for multiple broker replicas to share the same sorted set.
+// Events that cannot be added to the stream are scheduled again with their
+// original delivery time. A transaction cannot be used instead since the
+// stream and the sorted set might be at different cluster slots.
+func (s *redis) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulePollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		members, err := s.client.ZRangeByScoreWithScores(ctx, s.scheduledKey(), &goredis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+			Count: scheduleBatchSize,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Errorw("Could not read scheduled events", zap.Error(err))
+			}
+			continue
+		}
+
+		for _, z := range members {
+			s.moveScheduled(ctx, z)
+		}
+	}
+}
+
+// moveScheduled moves a due event from the sorted set to the stream.
+func (s *redis) moveScheduled(ctx context.Context, z goredis.Z) {
+	m, ok := z.Member.(string)
+	if !ok {
+		return
+	}
+
+	// Events that cannot be decrypted are kept until the key
+	// encryption key is available.
+	b, err := s.decryptScheduled(ctx, []byte(m))
+	if err != nil {
+		s.logger.Errorw("Could not decrypt scheduled event", zap.Error(err))
+		return
+	}
+
+	n, err := s.client.ZRem(ctx, s.scheduledKey(), m).Result()
+	if err != nil {
+		s.logger.Errorw("Could not remove scheduled event", zap.Error(err))
+		return
+	}
+
+	// Another instance already took care of this event.
+	if n == 0 {
+		return
+	}
+
+	if _, err := s.xadd(ctx, b); err != nil {
+		s.logger.Errorw("Could not produce scheduled event to the stream", zap.Error(err))
+
+		// Events must be scheduled again even when the scheduler is
+		// stopping, hence the context is not used.
+		if err := s.client.ZAdd(context.Background(), s.scheduledKey(), z).Err(); err != nil {
+			s.logger.Errorw("Scheduled event lost, it could not be scheduled again", zap.Error(err))
+		}
+	}
+}
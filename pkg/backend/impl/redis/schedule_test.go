@@ -0,0 +1,64 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	goredis "github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMoveScheduled(t *testing.T) {
+	z := goredis.Z{Score: 1000, Member: `{"specversion":"1.0"}`}
+
+	tcs := map[string]struct {
+		scheduled bool
+		xaddErr   error
+
+		produced  int
+		remaining map[string]float64
+	}{
+		"moved to the stream": {
+			scheduled: true,
+			produced:  1,
+			remaining: map[string]float64{},
+		},
+		"moved by another instance": {
+			remaining: map[string]float64{},
+		},
+		"stream failing": {
+			scheduled: true,
+			xaddErr:   errors.New("OOM command not allowed"),
+			remaining: map[string]float64{z.Member.(string): z.Score},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := newFakeClient()
+			client.xaddErr = tc.xaddErr
+
+			s := &redis{
+				args:   &RedisArgs{Stream: "stream"},
+				client: client,
+				logger: zap.NewNop().Sugar(),
+			}
+
+			client.zsets[s.scheduledKey()] = map[string]float64{}
+			if tc.scheduled {
+				client.zsets[s.scheduledKey()][z.Member.(string)] = z.Score
+			}
+
+			s.moveScheduled(context.Background(), z)
+
+			assert.Len(t, client.streams["stream"], tc.produced)
+			assert.Equal(t, tc.remaining, client.zsets[s.scheduledKey()],
+				"Events that are not produced must be scheduled again at their delivery time")
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	goredis "github.com/go-redis/redis/v9"
+)
+
+// StartupClaimPolicy is what subscriptions do with the messages left pending
+// they find when starting.
+type StartupClaimPolicy string
+
+const (
+	// StartupClaimRedeliver dispatches messages left pending again.
+	StartupClaimRedeliver StartupClaimPolicy = "redeliver"
+	// StartupClaimPark moves messages left pending to the parked stream.
+	StartupClaimPark StartupClaimPolicy = "park"
+	// StartupClaimSkip acknowledges messages left pending without
+	// dispatching them.
+	StartupClaimSkip StartupClaimPolicy = "skip"
+)
+
+// Suffix added to the stream name for the stream of messages left pending
+// that were parked when starting.
+const parkedStreamSuffix = ".parked"
+
+// messageAge returns the time elapsed since the message was added to the
+// stream, which is informed by the first part of its ID.
+func messageAge(id string, now time.Time) (time.Duration, bool) {
+	ms, _, _ := strings.Cut(id, "-")
+	t, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(time.UnixMilli(t)), true
+}
+
+// startupClaim applies the startup claim policy to a message left pending
+// found when starting, returning whether the message must not be
+// dispatched. Messages younger than the maximum age are dispatched again
+// regardless of the policy.
+func (s *subscription) startupClaim(msg goredis.XMessage) bool {
+	if s.startupClaimPolicy == "" || s.startupClaimPolicy == StartupClaimRedeliver {
+		return false
+	}
+
+	// Messages being dispatched are skipped when dispatching.
+	if _, ok := s.inFlight.Load(msg.ID); ok {
+		return false
+	}
+
+	age, ok := messageAge(msg.ID, time.Now())
+	if s.startupClaimMaxAge > 0 && (!ok || age < s.startupClaimMaxAge) {
+		return false
+	}
+
+	// Events moved while the subscription is stopping must still be
+	// acknowledged, hence the subscription context is not used.
+	if s.startupClaimPolicy == StartupClaimPark {
+		values := messageValues(msg)
+		values["trigger"] = s.name
+		values["id"] = msg.ID
+
+		if err := s.client.XAdd(context.Background(), &goredis.XAddArgs{
+			Stream: s.stream + parkedStreamSuffix,
+			Values: values,
+		}).Err(); err != nil {
+			s.logger.Errorw("Could not move a message left pending to the parked stream",
+				zap.String("group", s.group), zap.String("id", msg.ID), zap.Error(err))
+			return false
+		}
+	}
+
+	if err := s.ack(msg.ID); err != nil {
+		s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s left pending", msg.ID), zap.Error(err))
+	}
+	s.forgetDeliveries(msg.ID)
+
+	s.logger.Warnw("Message left pending not dispatched again when starting",
+		zap.String("group", s.group),
+		zap.String("id", msg.ID),
+		zap.Duration("age", age),
+		zap.String("policy", string(s.startupClaimPolicy)))
+	if s.reporter != nil {
+		s.reporter.ReportStartupClaimed(s.name, string(s.startupClaimPolicy))
+	}
+
+	return true
+}
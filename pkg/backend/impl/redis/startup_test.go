@@ -0,0 +1,53 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMessageAge(t *testing.T) {
+	now := time.UnixMilli(1700000060000)
+
+	age, ok := messageAge("1700000000000-3", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, age)
+
+	_, ok = messageAge("not-an-id", now)
+	assert.False(t, ok)
+}
+
+func TestStartupClaimDispatchesYoungMessages(t *testing.T) {
+	msg := goredis.XMessage{ID: "0-1"}
+	young := goredis.XMessage{ID: strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10) + "-0"}
+
+	tcs := map[string]struct {
+		policy StartupClaimPolicy
+		maxAge time.Duration
+		msg    goredis.XMessage
+	}{
+		"no policy":        {msg: msg},
+		"redeliver":        {policy: StartupClaimRedeliver, msg: msg},
+		"younger than age": {policy: StartupClaimSkip, maxAge: time.Hour, msg: young},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			s := &subscription{
+				startupClaimPolicy: tc.policy,
+				startupClaimMaxAge: tc.maxAge,
+				inFlight:           &sync.Map{},
+				logger:             zap.NewNop().Sugar(),
+			}
+			assert.False(t, s.startupClaim(tc.msg), "Message must be dispatched again")
+		})
+	}
+}
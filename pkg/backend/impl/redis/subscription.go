@@ -8,10 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/metrics"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
 	"go.uber.org/zap"
 
 	goredis "github.com/go-redis/redis/v9"
@@ -27,9 +31,44 @@ type subscription struct {
 	name     string
 	group    string
 
+	// claimMinIdle is the minimum time a pending message from any consumer
+	// at the group should stay idle before claiming it. Zero disables claiming.
+	claimMinIdle time.Duration
+	claimPeriod  time.Duration
+
+	// scaling replicas share the group, coordinating the ownership of its
+	// consumers.
+	scaling bool
+
+	// inFlight keeps the message IDs being dispatched by this subscription,
+	// preventing claim operations from dispatching them twice.
+	inFlight *sync.Map
+
+	// paused subscriptions neither read nor claim messages, which are
+	// kept at the stream until resumed.
+	paused *atomic.Bool
+
+	// maxDeliveries of messages left pending before they are quarantined.
+	// Zero disables quarantine.
+	maxDeliveries int
+	reporter      metrics.Reporter
+
+	// startupClaimPolicy applies to the messages left pending read when
+	// starting and claimed by the first claim, when older than the
+	// maximum age. Zero maximum age applies the policy to all of them.
+	startupClaimPolicy StartupClaimPolicy
+	startupClaimMaxAge time.Duration
+
 	// caller's callback for dispatching events from Redis.
 	ccbDispatch backend.ConsumerDispatcher
 
+	// cipher decrypts the events at the stream, nil if not enabled.
+	cipher *encryption.Cipher
+
+	// Events sharing an ordering key are dispatched sequentially.
+	orderingKey backend.OrderingKeyFunc
+	sequencer   *backend.Sequencer
+
 	// cancel function let us control when the subscription loop should exit.
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -69,6 +108,11 @@ func (s *subscription) start() {
 				break
 			}
 
+			if s.paused.Load() {
+				s.waitPaused()
+				continue
+			}
+
 			// Although this call is blocking it will yield when the context is done,
 			// the exit loop flag above will be triggered almost immediately if no
 			// data has been read.
@@ -108,45 +152,12 @@ func (s *subscription) start() {
 			}
 
 			for _, msg := range streams[0].Messages {
-				ce := &cloudevents.Event{}
-				for k, v := range msg.Values {
-					if k != ceKey {
-						s.logger.Debug(fmt.Sprintf("Ignoring non expected key at message from backend: %s", k))
-						continue
-					}
-
-					if err = ce.UnmarshalJSON([]byte(v.(string))); err != nil {
-						s.logger.Errorw("Could not unmarshal CloudEvent from Redis", zap.Error(err))
-						continue
-					}
-				}
-
-				// If there was no valid CE in the message ACK so that we do not receive it again.
-				if err = ce.Validate(); err != nil {
-					s.logger.Warn(fmt.Sprintf("Removing non CloudEvent message from backend: %s", msg.ID))
-					if err = s.ack(msg.ID); err != nil {
-						s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing a non valid CloudEvent", id),
-							zap.Error(err))
-					}
-
-					continue
-				}
-
-				if err = ce.Context.SetExtension(BackendIDAttribute, msg.ID); err != nil {
-					s.logger.Errorw(fmt.Sprintf("could not set %s attributes for the Redis message %s. Tracking will not be possible.", BackendIDAttribute, msg.ID),
-						zap.Error(err))
+				// Messages read again after restarting were left pending.
+				redelivered := id != ">"
+				if !redelivered || (!s.startupClaim(msg) && !s.quarantine(msg)) {
+					s.dispatchMessage(msg, redelivered)
 				}
 
-				go func() {
-					s.ccbDispatch(ce)
-					id := ce.Extensions()[BackendIDAttribute].(string)
-
-					if err := s.ack(id); err != nil {
-						s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing CloudEvent %s", id, ce.Context.GetID()),
-							zap.Error(err))
-					}
-				}()
-
 				// If we are processing pending messages the ACK might take a
 				// while to be sent. We need to set the message ID so that the
 				// next requested element is not any of the pending being processed.
@@ -165,10 +176,177 @@ func (s *subscription) start() {
 		// subscription is no longer running.
 		close(s.stoppedCh)
 	}()
+
+	s.startClaiming()
+}
+
+// dispatchMessage parses the CloudEvent contained at the Redis message and
+// dispatches it asynchronously, acknowledging it to Redis when done.
+// Delivery counters of redelivered messages are removed when acknowledged.
+func (s *subscription) dispatchMessage(msg goredis.XMessage, redelivered bool) {
+	if _, loaded := s.inFlight.LoadOrStore(msg.ID, struct{}{}); loaded {
+		s.logger.Debugw("Skipping message already being dispatched", zap.String("id", msg.ID))
+		return
+	}
+
+	for k := range msg.Values {
+		if k != ceKey && k != encodingKey && k != encryptionKey {
+			s.logger.Debug(fmt.Sprintf("Ignoring non expected key at message from backend: %s", k))
+		}
+	}
+
+	ce, ok, err := eventFromValues(s.ctx, s.cipher, msg.Values)
+	switch {
+	case errors.Is(err, errDecryption):
+		// Messages that cannot be decrypted stay pending, and are
+		// dispatched again when claimed.
+		s.logger.Errorw("Could not decrypt CloudEvent from Redis", zap.String("id", msg.ID), zap.Error(err))
+		s.inFlight.Delete(msg.ID)
+		return
+	case err != nil:
+		s.logger.Errorw("Could not unmarshal CloudEvent from Redis", zap.Error(err))
+		ce = &cloudevents.Event{}
+	case !ok:
+		ce = &cloudevents.Event{}
+	}
+
+	// If there was no valid CE in the message ACK so that we do not receive it again.
+	if err := ce.Validate(); err != nil {
+		s.logger.Warn(fmt.Sprintf("Removing non CloudEvent message from backend: %s", msg.ID))
+		if err = s.ack(msg.ID); err != nil {
+			s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing a non valid CloudEvent", msg.ID),
+				zap.Error(err))
+		} else if redelivered {
+			s.forgetDeliveries(msg.ID)
+		}
+		s.inFlight.Delete(msg.ID)
+
+		return
+	}
+
+	if err := ce.Context.SetExtension(BackendIDAttribute, msg.ID); err != nil {
+		s.logger.Errorw(fmt.Sprintf("could not set %s attributes for the Redis message %s. Tracking will not be possible.", BackendIDAttribute, msg.ID),
+			zap.Error(err))
+	}
+
+	// Acquire the turn for ordered events before dispatching
+	// asynchronously, messages are read in the stream order.
+	var wait <-chan struct{}
+	release := func() {}
+	if s.orderingKey != nil {
+		if key, ok := s.orderingKey(ce); ok {
+			wait, release = s.sequencer.Acquire(key)
+		}
+	}
+
+	go func() {
+		defer s.inFlight.Delete(msg.ID)
+		defer release()
+		if wait != nil {
+			<-wait
+		}
+		if err := s.ccbDispatch(ce); err != nil {
+			// Messages not acknowledged stay pending, and are dispatched
+			// again when claimed.
+			s.logger.Debugw("Message not acknowledged, it will be dispatched again when claimed",
+				zap.String("group", s.group), zap.String("id", msg.ID), zap.Error(err))
+			return
+		}
+
+		if err := s.ack(msg.ID); err != nil {
+			s.logger.Errorw(fmt.Sprintf("could not ACK the Redis message %s containing CloudEvent %s", msg.ID, ce.Context.GetID()),
+				zap.Error(err))
+			return
+		}
+		if redelivered {
+			s.forgetDeliveries(msg.ID)
+		}
+	}()
+}
+
+// startClaiming periodically transfers to this consumer pending messages at
+// the group that have been idle for longer than the configured threshold,
+// which means that they were not acknowledged by the dispatcher, or that the
+// replica that read them is no longer running.
+func (s *subscription) startClaiming() {
+	if s.claimMinIdle == 0 {
+		return
+	}
+
+	s.logger.Infow("Starting Redis pending messages claimer",
+		zap.String("group", s.group),
+		zap.String("instance", s.instance),
+		zap.Duration("min_idle", s.claimMinIdle))
+
+	go func() {
+		ticker := time.NewTicker(s.claimPeriod)
+		defer ticker.Stop()
+
+		// Messages claimed the first time were left pending by replicas
+		// that stopped before this one started.
+		startup := true
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if s.paused.Load() {
+					continue
+				}
+				s.claim(startup)
+				startup = false
+			}
+		}
+	}()
+}
+
+func (s *subscription) claim(startup bool) {
+	start := "0-0"
+	for {
+		msgs, next, err := s.client.XAutoClaim(s.ctx, &goredis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			MinIdle:  s.claimMinIdle,
+			Start:    start,
+			Count:    100,
+			Consumer: s.instance,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, goredis.Nil) && !errors.Is(err, context.Canceled) {
+				s.logger.Errorw("Error claiming pending messages from consumer group", zap.String("group", s.group), zap.Error(err))
+			}
+			return
+		}
+
+		if len(msgs) != 0 {
+			s.logger.Infow("Claimed pending messages from consumer group",
+				zap.String("group", s.group), zap.Int("count", len(msgs)))
+		}
+
+		for _, msg := range msgs {
+			if startup && s.startupClaim(msg) {
+				continue
+			}
+			if !s.quarantine(msg) {
+				s.dispatchMessage(msg, true)
+			}
+		}
+
+		if next == "0-0" || next == "" {
+			break
+		}
+		start = next
+	}
+
+	if s.scaling {
+		s.reapConsumers()
+	}
 }
 
 func (s *subscription) ack(id string) error {
-	res := s.client.XAck(s.ctx, s.stream, s.group, id)
+	// Events dispatched while the subscription is stopping must still be
+	// acknowledged, hence the subscription context is not used.
+	res := s.client.XAck(context.Background(), s.stream, s.group, id)
 	_, err := res.Result()
 	return err
 }
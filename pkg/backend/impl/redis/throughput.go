@@ -0,0 +1,84 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	goredis "github.com/go-redis/redis/v9"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Prefix added to the stream name for hourly throughput hashes.
+const throughputKeyInfix = ".throughput."
+
+var _ backend.ThroughputStore = (*redis)(nil)
+
+func (s *redis) throughputKey(hour time.Time) string {
+	return s.args.Stream + throughputKeyInfix + strconv.FormatInt(hour.Unix(), 10)
+}
+
+func (s *redis) AddThroughput(ctx context.Context, hour time.Time, counts map[string]int64, retention time.Duration) error {
+	key := s.throughputKey(hour)
+
+	_, err := s.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for field, n := range counts {
+			p.HIncrBy(ctx, key, field, n)
+		}
+		p.ExpireAt(ctx, key, hour.Add(time.Hour+retention))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not add throughput counters to Redis: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redis) Throughput(ctx context.Context, from, to time.Time) (map[time.Time]map[string]int64, error) {
+	hours := []time.Time{}
+	for h := from.Truncate(time.Hour); !h.After(to); h = h.Add(time.Hour) {
+		if !h.Before(from) {
+			hours = append(hours, h)
+		}
+	}
+
+	cmds := make([]*goredis.MapStringStringCmd, len(hours))
+	_, err := s.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for i, h := range hours {
+			cmds[i] = p.HGetAll(ctx, s.throughputKey(h))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read throughput counters from Redis: %w", err)
+	}
+
+	res := make(map[time.Time]map[string]int64)
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+
+		counts := make(map[string]int64, len(cmd.Val()))
+		for field, v := range cmd.Val() {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				s.logger.Warnw("Ignoring invalid throughput counter",
+					zap.String("key", s.throughputKey(hours[i])), zap.String("field", field))
+				continue
+			}
+			counts[field] = n
+		}
+		res[hours[i]] = counts
+	}
+
+	return res, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Bounds for the period of stream trimming by age.
+	minTrimPeriod = time.Second
+	maxTrimPeriod = time.Minute
+
+	// Period of stream trimming by length and size.
+	retentionTrimPeriod = 10 * time.Second
+)
+
+// SetMaxAge discards events at the stream older than the informed age.
+func (s *redis) SetMaxAge(age time.Duration) {
+	s.maxAge = age
+}
+
+// SetRetention discards the oldest events at the stream beyond the
+// retention limits. The maximum age applies along with the one set using
+// SetMaxAge, the lowest taking precedence.
+func (s *redis) SetRetention(r backend.Retention) {
+	s.retention = r
+}
+
+// trimAge returns the age events are trimmed after, zero if not limited.
+func (s *redis) trimAge() time.Duration {
+	age := s.maxAge
+	if r := s.retention.MaxAge; r > 0 && (age == 0 || r < age) {
+		age = r
+	}
+	return age
+}
+
+// runTrimmer periodically removes events beyond the retention limits from
+// the stream until the context is done.
+//
+// Stream IDs are prefixed by the time in milliseconds when they were added,
+// which is used as the minimum ID to keep. The size of the stream is
+// estimated by Redis, and the number of events that fit the maximum size is
+// computed using the average size of the events. Trimming is approximate,
+// some events might be kept beyond the limits, subscribers are expected to
+// discard those expired.
+func (s *redis) runTrimmer(ctx context.Context) {
+	age := s.trimAge()
+
+	period := age / 10
+	switch {
+	case period < minTrimPeriod:
+		period = minTrimPeriod
+	case period > maxTrimPeriod:
+		period = maxTrimPeriod
+	}
+	if (s.retention.MaxEvents > 0 || s.retention.MaxBytes > 0) && (age == 0 || period > retentionTrimPeriod) {
+		period = retentionTrimPeriod
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if age > 0 {
+			minID := strconv.FormatInt(time.Now().Add(-age).UnixMilli(), 10)
+			n, err := s.client.XTrimMinIDApprox(ctx, s.args.Stream, minID, 0).Result()
+			s.trimmed(ctx, backend.TrimReasonAge, n, err)
+		}
+
+		if s.retention.MaxEvents > 0 {
+			n, err := s.client.XTrimMaxLenApprox(ctx, s.args.Stream, s.retention.MaxEvents, 0).Result()
+			s.trimmed(ctx, backend.TrimReasonCount, n, err)
+		}
+
+		if s.retention.MaxBytes > 0 {
+			n, err := s.trimBytes(ctx)
+			s.trimmed(ctx, backend.TrimReasonBytes, n, err)
+		}
+	}
+}
+
+// trimBytes trims the stream to the number of events whose average size
+// fits the maximum size.
+func (s *redis) trimBytes(ctx context.Context) (int64, error) {
+	size, err := s.client.MemoryUsage(ctx, s.args.Stream).Result()
+	if err != nil || size <= s.retention.MaxBytes {
+		return 0, err
+	}
+
+	length, err := s.client.XLen(ctx, s.args.Stream).Result()
+	if err != nil || length == 0 {
+		return 0, err
+	}
+
+	keep := length * s.retention.MaxBytes / size
+	return s.client.XTrimMaxLenApprox(ctx, s.args.Stream, keep, 0).Result()
+}
+
+// trimmed reports the events trimmed from the stream.
+func (s *redis) trimmed(ctx context.Context, reason string, n int64, err error) {
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Errorw("Could not trim events from the stream", zap.String("reason", reason), zap.Error(err))
+		}
+		return
+	}
+
+	if n != 0 {
+		s.logger.Debugw("Events trimmed from the stream", zap.String("reason", reason), zap.Int64("count", n))
+		s.reporter.ReportTrimmed(reason, n)
+	}
+}
@@ -5,33 +5,302 @@ package backend
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/common/encryption"
 )
 
+// ErrBackendBusy is returned by event producers when the backend cannot
+// temporarily accept more events. Callers might retry later.
+var ErrBackendBusy = errors.New("backend is busy")
+
+// ErrEventTooLarge is returned by event producers when the event exceeds
+// the maximum size the backend can store.
+var ErrEventTooLarge = errors.New("event is too large")
+
 type Info struct {
 	// Name of the backend implementation
 	Name string
+	// Durable informs whether produced events survive the backend
+	// restarting.
+	Durable bool
+	// MaxEventSize is the maximum size in bytes of the serialized events
+	// the backend can store, zero if unlimited.
+	MaxEventSize int
 }
 
 // ConsumerDispatcher receives CloudEvents to be delivered to subscribers.
 // The consumer dispatcher must process the event for all subscriptions,
 // including retries and dead leter queues.
-// When the function finishes executing the backend will consider
-// the event processed and will make sure it is not re-delivered.
-type ConsumerDispatcher func(event *cloudevents.Event)
+// When the function returns nil the backend will consider the event
+// processed and will make sure it is not re-delivered. When it returns an
+// error the event is not acknowledged, and the backend dispatches it again
+// to the subscription later.
+type ConsumerDispatcher func(event *cloudevents.Event) error
 
 type EventProducer interface {
 	// Ingest a new CloudEvents at the backend.
 	Produce(context.Context, *cloudevents.Event) error
 }
 
+// BatchProducer is an optional interface for backends that can ingest
+// several events using a single round-trip.
+type BatchProducer interface {
+	// ProduceBatch ingests the events in order, returning the error of
+	// each event, nil for those that were produced.
+	ProduceBatch(context.Context, []*cloudevents.Event) []error
+}
+
+// Deduplicator is an optional interface for backends that can keep track
+// of the events already produced.
+type Deduplicator interface {
+	// MarkProduced registers the event key for the TTL duration, returning
+	// false if the key was already registered.
+	MarkProduced(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// UnmarkProduced removes the event key registration.
+	UnmarkProduced(ctx context.Context, key string) error
+}
+
+// AgeTrimmer is an optional interface for backends that can discard
+// stored events older than a maximum age.
+type AgeTrimmer interface {
+	// SetMaxAge configures the age after which events are discarded. It
+	// must be called before starting the backend.
+	SetMaxAge(time.Duration)
+}
+
+// RetentionEnforcer is an optional interface for backends that can discard
+// the stored events beyond retention limits.
+type RetentionEnforcer interface {
+	// SetRetention configures the limits of the events retained, which
+	// the backend enforces by discarding the oldest events. It must be
+	// called before initializing the backend.
+	SetRetention(Retention)
+}
+
+// EventEncrypter is an optional interface for backends that can encrypt
+// the events they persist.
+type EventEncrypter interface {
+	// SetCipher configures the cipher for encrypting persisted events. It
+	// must be called before initializing the backend.
+	SetCipher(*encryption.Cipher)
+}
+
+// ThroughputStore is an optional interface for backends that can persist
+// hourly event counters.
+type ThroughputStore interface {
+	// AddThroughput increments the counters of the hour, which are kept
+	// for the retention duration since the hour is over.
+	AddThroughput(ctx context.Context, hour time.Time, counts map[string]int64, retention time.Duration) error
+
+	// Throughput returns the counters of the hours that start within the
+	// range, indexed by hour.
+	Throughput(ctx context.Context, from, to time.Time) (map[time.Time]map[string]int64, error)
+}
+
+// EventReader is an optional interface for backends that retain produced
+// events and can read them back.
+type EventReader interface {
+	// LastEvents returns up to n of the most recently produced events
+	// that are still retained, newest first.
+	LastEvents(ctx context.Context, n int) ([]cloudevents.Event, error)
+}
+
+// EventArchive is an optional interface for backends that can archive
+// ingested events apart from the events being dispatched, and query them.
+type EventArchive interface {
+	// ArchiveEvent stores the event, which is kept for the retention
+	// duration since it is archived.
+	ArchiveEvent(ctx context.Context, event *cloudevents.Event, retention time.Duration) error
+
+	// QueryArchive returns up to the query limit of the archived events
+	// that match the query, oldest first.
+	QueryArchive(ctx context.Context, q *ArchiveQuery) ([]cloudevents.Event, error)
+}
+
+// ArchiveQuery selects archived events. Empty attributes match any event.
+type ArchiveQuery struct {
+	// Range of the time events were archived at, both included.
+	From time.Time
+	To   time.Time
+
+	Type   string
+	Source string
+	ID     string
+
+	// Limit is the maximum number of events returned.
+	Limit int
+}
+
+// Matches returns true if the event attributes match the query.
+func (q *ArchiveQuery) Matches(event *cloudevents.Event) bool {
+	return (q.Type == "" || q.Type == event.Type()) &&
+		(q.Source == "" || q.Source == event.Source()) &&
+		(q.ID == "" || q.ID == event.ID())
+}
+
+// FixtureStore is an optional interface for backends that can keep the
+// deliveries recorded for Triggers, which later deliveries are asserted
+// against.
+type FixtureStore interface {
+	// RecordFixture stores the delivery recorded for the event key,
+	// replacing any previous one.
+	RecordFixture(ctx context.Context, trigger, key string, delivery []byte) error
+
+	// Fixture returns the delivery recorded for the event key, nil if
+	// there is none.
+	Fixture(ctx context.Context, trigger, key string) ([]byte, error)
+}
+
+// RetryStateStore is an optional interface for backends that can keep the
+// progress of the retries of events, so that the retries of events
+// dispatched again after a restart resume their schedule.
+type RetryStateStore interface {
+	// SaveRetryState stores the retry state of the event key, replacing
+	// any previous one.
+	SaveRetryState(ctx context.Context, trigger, key string, state RetryState) error
+
+	// RetryState returns the retry state of the event key, nil if there
+	// is none.
+	RetryState(ctx context.Context, trigger, key string) (*RetryState, error)
+
+	// DeleteRetryState removes the retry state of the event key.
+	DeleteRetryState(ctx context.Context, trigger, key string) error
+}
+
+// RetryState is the progress of the retries of an event to a trigger
+// target.
+type RetryState struct {
+	// Retries scheduled so far.
+	Attempts int
+	// Time the last scheduled retry is due.
+	NextRetry time.Time
+}
+
+// SubscriptionPurger is an optional interface for backends that keep the
+// position of subscriptions after unsubscribing, which is resumed when
+// subscribing again with the same name.
+type SubscriptionPurger interface {
+	// PurgeSubscription discards the position kept for the subscription.
+	PurgeSubscription(ctx context.Context, name string) error
+}
+
+// SubscriptionPauser is an optional interface for backends that can stop
+// dispatching events to a subscription while retaining those produced
+// meanwhile, which are dispatched when the subscription is resumed.
+type SubscriptionPauser interface {
+	// PauseSubscription stops dispatching events to the subscription.
+	// Events already being dispatched are not interrupted.
+	PauseSubscription(name string) error
+
+	// ResumeSubscription dispatches the events retained while paused,
+	// followed by those produced later on.
+	ResumeSubscription(name string) error
+}
+
+// ReplicaRegistry is an optional interface for backends shared by several
+// broker replicas, which can keep track of the replicas that are alive.
+type ReplicaRegistry interface {
+	// RenewReplicaLease registers the replica as alive for the lease
+	// duration, returning the names of the replicas whose lease did not
+	// expire, the informed one included.
+	RenewReplicaLease(ctx context.Context, replica string, lease time.Duration) ([]string, error)
+
+	// ReleaseReplicaLease removes the replica registration.
+	ReleaseReplicaLease(ctx context.Context, replica string) error
+}
+
+// TriggerLeaser is an optional interface for backends shared by several
+// broker replicas, which can grant a replica the exclusive ownership of a
+// trigger.
+type TriggerLeaser interface {
+	// AcquireTriggerLease grants the replica the lease of the trigger for
+	// the lease duration, renewing it if the replica already holds it. It
+	// returns false if the lease is held by another replica.
+	AcquireTriggerLease(ctx context.Context, trigger, replica string, lease time.Duration) (bool, error)
+
+	// ReleaseTriggerLease removes the lease of the trigger, if the replica
+	// holds it.
+	ReleaseTriggerLease(ctx context.Context, trigger, replica string) error
+}
+
+// LeaderElector is an optional interface for backends shared by several
+// broker replicas, which can elect a single replica to dispatch all the
+// triggers while the others stand by.
+type LeaderElector interface {
+	// AcquireLeaderLease makes the replica the leader for the lease
+	// duration, renewing the lease if the replica already is the leader.
+	// It returns false if another replica is the leader.
+	AcquireLeaderLease(ctx context.Context, replica string, lease time.Duration) (bool, error)
+
+	// ReleaseLeaderLease removes the leader lease, if the replica holds
+	// it.
+	ReleaseLeaderLease(ctx context.Context, replica string) error
+}
+
+// BacklogReporter is an optional interface for backends that can tell the
+// number of events pending to be dispatched, which autoscalers use to scale
+// broker replicas.
+type BacklogReporter interface {
+	// Backlog returns the number of events not yet dispatched by each of
+	// the informed subscriptions, indexed by name. Subscriptions that do
+	// not exist at the backend are not returned.
+	Backlog(ctx context.Context, names []string) (map[string]int64, error)
+}
+
+// SubscriptionInspector is an optional interface for backends that keep
+// the state of subscriptions apart from the broker process, which can be
+// inspected and changed by operators while the broker is not running.
+type SubscriptionInspector interface {
+	// Subscriptions returns the state of all subscriptions at the backend.
+	Subscriptions(ctx context.Context) ([]SubscriptionState, error)
+
+	// PendingEvents returns up to n of the events dispatched to the
+	// subscription that were not acknowledged, oldest first.
+	PendingEvents(ctx context.Context, name string, n int) ([]PendingEvent, error)
+
+	// DrainSubscription discards the events pending for the subscription,
+	// both those not yet dispatched and those not acknowledged, returning
+	// the number of discarded events.
+	DrainSubscription(ctx context.Context, name string) (int64, error)
+}
+
+// SubscriptionState informs the events pending for a subscription.
+type SubscriptionState struct {
+	Name string
+	// Lag is the number of events not yet dispatched.
+	Lag int64
+	// Pending is the number of events dispatched but not acknowledged.
+	Pending int64
+	// Consumers reading events for the subscription.
+	Consumers int64
+}
+
+// PendingEvent is an event dispatched to a subscription that was not
+// acknowledged.
+type PendingEvent struct {
+	// BackendID identifies the event at the backend.
+	BackendID string
+	// Consumer the event was dispatched by.
+	Consumer string
+	// Idle is the time since the event was last dispatched.
+	Idle time.Duration
+	// Deliveries is the number of times the event was dispatched.
+	Deliveries int64
+	// Event is nil when it is no longer stored or cannot be read.
+	Event *cloudevents.Event
+}
+
 type Subscribable interface {
 	// Subscribe is a method that sets up a reader that will retrieve
 	// events from the backend and pass them to the consumer dispatcher.
 	// When the consumer dispatcher returns, the message is marked as
 	// processed and won't be delivered anymore.
-	Subscribe(name string, ccb ConsumerDispatcher) error
+	Subscribe(name string, ccb ConsumerDispatcher, opts ...SubscribeOption) error
 
 	// Unsubscribe is a method that removes a subscription referencing
 	// it by name, returning when all pending (already read) messages
@@ -0,0 +1,261 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+
+	knmetrics "knative.dev/pkg/metrics"
+)
+
+const (
+	LabelBackend   = "backend"
+	LabelOperation = "operation"
+	LabelPolicy    = "policy"
+	LabelReason    = "reason"
+	LabelSuccess   = "success"
+	LabelTrigger   = "trigger_name"
+)
+
+var (
+	backendKey   = tag.MustNewKey(LabelBackend)
+	operationKey = tag.MustNewKey(LabelOperation)
+	policyKey    = tag.MustNewKey(LabelPolicy)
+	reasonKey    = tag.MustNewKey(LabelReason)
+	successKey   = tag.MustNewKey(LabelSuccess)
+	triggerKey   = tag.MustNewKey(LabelTrigger)
+
+	// operationCountM is a counter which records the number of operations
+	// executed against the backend.
+	operationCountM = stats.Int64(
+		"backend/operation_count",
+		"Number of operations executed by the broker backend client.",
+		stats.UnitDimensionless,
+	)
+
+	// operationLatencyMs measures the latency in milliseconds for the backend
+	// client operations.
+	operationLatencyMs = stats.Float64(
+		"backend/operation_latency",
+		"The latency in milliseconds for the broker backend client operations.",
+		"ms")
+
+	// connectionCountM is a counter which records the number of connections
+	// (including reconnections) established by the backend client.
+	connectionCountM = stats.Int64(
+		"backend/connection_count",
+		"Number of connections established by the broker backend client.",
+		stats.UnitDimensionless,
+	)
+
+	// pipelineSizeM measures the number of commands sent to the backend
+	// as a single pipeline.
+	pipelineSizeM = stats.Int64(
+		"backend/pipeline_size",
+		"Number of commands sent to the broker backend as a single pipeline.",
+		stats.UnitDimensionless,
+	)
+
+	// quarantinedCountM is a counter which records the number of events
+	// moved to quarantine after exceeding their maximum deliveries.
+	quarantinedCountM = stats.Int64(
+		"backend/quarantined_count",
+		"Number of events moved to quarantine after exceeding their maximum deliveries.",
+		stats.UnitDimensionless,
+	)
+
+	// startupClaimedCountM is a counter which records the number of events
+	// left pending that were parked or skipped instead of dispatched again
+	// when starting.
+	startupClaimedCountM = stats.Int64(
+		"backend/startup_claimed_count",
+		"Number of events left pending that were parked or skipped instead of dispatched again when starting.",
+		stats.UnitDimensionless,
+	)
+
+	// trimmedCountM is a counter which records the number of events
+	// discarded for exceeding the retention limits.
+	trimmedCountM = stats.Int64(
+		"backend/trimmed_count",
+		"Number of events discarded for exceeding the backend retention limits.",
+		stats.UnitDimensionless,
+	)
+)
+
+func registerStatViews() error {
+	tagKeys := []tag.Key{
+		backendKey,
+		operationKey,
+		successKey}
+
+	// Create view to see our measurements.
+	return knmetrics.RegisterResourceView(
+		&view.View{
+			Name:        operationLatencyMs.Name(),
+			Description: operationLatencyMs.Description(),
+			Measure:     operationLatencyMs,
+			Aggregation: view.Distribution(0, .01, .1, 1, 10, 100, 1000, 10000),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Name:        operationCountM.Name(),
+			Description: operationCountM.Description(),
+			Measure:     operationCountM,
+			Aggregation: view.Count(),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Name:        connectionCountM.Name(),
+			Description: connectionCountM.Description(),
+			Measure:     connectionCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{backendKey, successKey},
+		},
+		&view.View{
+			Name:        pipelineSizeM.Name(),
+			Description: pipelineSizeM.Description(),
+			Measure:     pipelineSizeM,
+			Aggregation: view.Distribution(1, 2, 5, 10, 20, 50, 100, 500),
+			TagKeys:     []tag.Key{backendKey},
+		},
+		&view.View{
+			Name:        quarantinedCountM.Name(),
+			Description: quarantinedCountM.Description(),
+			Measure:     quarantinedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{backendKey, triggerKey},
+		},
+		&view.View{
+			Name:        startupClaimedCountM.Name(),
+			Description: startupClaimedCountM.Description(),
+			Measure:     startupClaimedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{backendKey, triggerKey, policyKey},
+		},
+		&view.View{
+			Name:        trimmedCountM.Name(),
+			Description: trimmedCountM.Description(),
+			Measure:     trimmedCountM,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{backendKey, reasonKey},
+		},
+	)
+}
+
+func initContext(ctx context.Context, backendName string) (context.Context, error) {
+	return tag.New(ctx, tag.Insert(backendKey, backendName))
+}
+
+type Reporter interface {
+	ReportOperation(operation string, success bool, msLatency float64)
+	ReportConnection(success bool)
+	ReportPipeline(size int)
+	ReportQuarantined(trigger string)
+	ReportStartupClaimed(trigger, policy string)
+	ReportTrimmed(reason string, count int64)
+}
+
+// Reporter holds cached metric objects to report backend metrics.
+type reporter struct {
+	ctx    context.Context
+	logger *zap.SugaredLogger
+}
+
+var once sync.Once
+
+// NewReporter retuns a StatReporter for backend client operations.
+func NewReporter(ctx context.Context, backendName string, logger *zap.SugaredLogger) (Reporter, error) {
+	r := &reporter{
+		logger: logger,
+	}
+
+	var err error
+	once.Do(func() {
+		if err = registerStatViews(); err != nil {
+			err = fmt.Errorf("error registering OpenCensus stats view: %w", err)
+			return
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.ctx, err = initContext(ctx, backendName)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing OpenCensus context with tags: %w", err)
+	}
+
+	return r, nil
+}
+
+func (r *reporter) ReportOperation(operation string, success bool, msLatency float64) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(operationKey, operation),
+		tag.Insert(successKey, strconv.FormatBool(success)),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, operationLatencyMs.M(msLatency))
+	knmetrics.Record(ctx, operationCountM.M(1))
+}
+
+func (r *reporter) ReportConnection(success bool) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(successKey, strconv.FormatBool(success)),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, connectionCountM.M(1))
+}
+
+func (r *reporter) ReportPipeline(size int) {
+	knmetrics.Record(r.ctx, pipelineSizeM.M(int64(size)))
+}
+
+func (r *reporter) ReportQuarantined(trigger string) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(triggerKey, trigger),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, quarantinedCountM.M(1))
+}
+
+func (r *reporter) ReportStartupClaimed(trigger, policy string) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(triggerKey, trigger),
+		tag.Insert(policyKey, policy),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, startupClaimedCountM.M(1))
+}
+
+func (r *reporter) ReportTrimmed(reason string, count int64) {
+	ctx, err := tag.New(r.ctx,
+		tag.Insert(reasonKey, reason),
+	)
+	if err != nil {
+		r.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+	}
+
+	knmetrics.Record(ctx, trimmedCountM.M(count))
+}
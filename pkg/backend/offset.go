@@ -0,0 +1,25 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+// StartingOffset is the position at the backend where new subscriptions
+// start consuming events from. Subscriptions that already exist at the
+// backend resume from their own cursor.
+type StartingOffset string
+
+const (
+	// StartingOffsetLatest consumes the events produced after subscribing.
+	StartingOffsetLatest StartingOffset = "latest"
+	// StartingOffsetEarliest consumes the earliest event retained at the
+	// backend first.
+	StartingOffsetEarliest StartingOffset = "earliest"
+)
+
+// SubscribeWithStartingOffset sets the position new subscriptions start
+// consuming events from.
+func SubscribeWithStartingOffset(offset StartingOffset) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.StartingOffset = offset
+	}
+}
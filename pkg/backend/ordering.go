@@ -0,0 +1,81 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// OrderingKeyFunc returns the key of events that must be dispatched in
+// order, and false if the event can be dispatched in any order.
+type OrderingKeyFunc func(event *cloudevents.Event) (string, bool)
+
+// SubscribeOptions are optional parameters for subscriptions.
+type SubscribeOptions struct {
+	// OrderingKey makes events sharing a key to be dispatched
+	// sequentially, in the order they were read from the backend.
+	OrderingKey OrderingKeyFunc
+
+	// StartingOffset of new subscriptions, latest if empty.
+	StartingOffset StartingOffset
+
+	// Paused subscriptions do not dispatch events until resumed.
+	Paused bool
+}
+
+type SubscribeOption func(*SubscribeOptions)
+
+// SubscribeWithOrderingKey dispatches events sharing a key sequentially.
+func SubscribeWithOrderingKey(fn OrderingKeyFunc) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.OrderingKey = fn
+	}
+}
+
+// NewSubscribeOptions applies the subscription options.
+func NewSubscribeOptions(opts ...SubscribeOption) *SubscribeOptions {
+	o := &SubscribeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Sequencer serializes tasks that share a key. Tasks must acquire their
+// turn synchronously in the order they must be executed, and can then wait
+// for their turn concurrently.
+type Sequencer struct {
+	tails map[string]chan struct{}
+	m     sync.Mutex
+}
+
+func NewSequencer() *Sequencer {
+	return &Sequencer{
+		tails: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire returns a channel that is closed when the previous task for the
+// key finishes, nil if there is no previous task, and the function that
+// must be called when the task finishes.
+func (s *Sequencer) Acquire(key string) (<-chan struct{}, func()) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	prev := s.tails[key]
+	done := make(chan struct{})
+	s.tails[key] = done
+
+	return prev, func() {
+		close(done)
+
+		s.m.Lock()
+		defer s.m.Unlock()
+		if s.tails[key] == done {
+			delete(s.tails, key)
+		}
+	}
+}
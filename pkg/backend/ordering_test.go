@@ -0,0 +1,47 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer(t *testing.T) {
+	s := NewSequencer()
+
+	var m sync.Mutex
+	order := map[string][]int{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		key := []string{"a", "b"}[i%2]
+		wait, release := s.Acquire(key)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer release()
+			if wait != nil {
+				<-wait
+			}
+
+			// Earlier tasks take longer, which would reorder them
+			// if they were not serialized.
+			time.Sleep(time.Duration(20-i) * time.Millisecond)
+
+			m.Lock()
+			order[key] = append(order[key], i)
+			m.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}, order["a"])
+	assert.Equal(t, []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}, order["b"])
+	assert.Empty(t, s.tails)
+}
@@ -0,0 +1,12 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+// SubscribeWithPaused creates the subscription paused, for backends that
+// implement SubscriptionPauser, which is resumed using ResumeSubscription.
+func SubscribeWithPaused(paused bool) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Paused = paused
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package registry keeps the backend implementations that can be selected
+// when starting the broker. Implementations register themselves when their
+// package is imported, which allows compiling in backends not provided by
+// this repository:
+//
+//	import _ "example.com/mybackend"
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Args are the arguments of a backend implementation, which are informed as
+// command line flags prefixed with the backend name, and environment
+// variables prefixed with the upper cased backend name. Arguments are
+// declared using kong tags.
+type Args interface {
+	Validate() error
+}
+
+// Options for creating backends that are common to all implementations.
+type Options struct {
+	// BrokerName is the name of the broker instance.
+	BrokerName string
+	Logger     *zap.SugaredLogger
+}
+
+// Factory creates a backend using the validated arguments. Hosted is the
+// name of the hosted broker the backend is created for, empty for the
+// default broker. Backends for hosted brokers must store their events apart
+// from those of any other broker.
+type Factory func(args Args, hosted string, o Options) backend.Interface
+
+// Definition of a backend implementation.
+type Definition struct {
+	// Name used for selecting the backend and prefixing its arguments.
+	Name string
+	// NewArgs returns a pointer to the arguments structure of the backend,
+	// which is the schema of its configuration.
+	NewArgs func() Args
+	New     Factory
+}
+
+// Backends returns the backend for the default broker and the factory of
+// backends for hosted brokers.
+func (d Definition) Backends(args Args, o Options) (backend.Interface, func(hosted string) backend.Interface) {
+	factory := func(hosted string) backend.Interface {
+		return d.New(args, hosted, o)
+	}
+	return factory(""), factory
+}
+
+var (
+	definitions = make(map[string]Definition)
+	m           sync.RWMutex
+)
+
+// Register makes the backend available for selection. It panics if the
+// definition is not complete or a backend with the same name was already
+// registered.
+func Register(d Definition) {
+	if d.Name == "" || d.NewArgs == nil || d.New == nil {
+		panic("backend definition must inform the name, arguments and factory")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := definitions[d.Name]; ok {
+		panic(fmt.Sprintf("backend %q is already registered", d.Name))
+	}
+	definitions[d.Name] = d
+}
+
+// Lookup returns the backend registered with the name.
+func Lookup(name string) (Definition, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	d, ok := definitions[name]
+	return d, ok
+}
+
+// Names returns the names of the registered backends, sorted.
+func Names() []string {
+	m.RLock()
+	defer m.RUnlock()
+
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+type fakeArgs struct {
+	Address string `help:"Address of the fake backend." env:"ADDRESS" default:"localhost"`
+	Size    int    `help:"Size of the fake backend." env:"SIZE" default:"10"`
+}
+
+func (a *fakeArgs) Validate() error {
+	if a.Size < 0 {
+		return errors.New("size must not be negative")
+	}
+	return nil
+}
+
+// fakeBackend records the arguments it was created with.
+type fakeBackend struct {
+	backend.Interface
+	args   fakeArgs
+	hosted string
+}
+
+func registerFake(t *testing.T, name string) {
+	t.Cleanup(func() {
+		m.Lock()
+		defer m.Unlock()
+		delete(definitions, name)
+	})
+
+	Register(Definition{
+		Name:    name,
+		NewArgs: func() Args { return &fakeArgs{} },
+		New: func(args Args, hosted string, o Options) backend.Interface {
+			return &fakeBackend{args: *args.(*fakeArgs), hosted: hosted}
+		},
+	})
+}
+
+func TestRegister(t *testing.T) {
+	registerFake(t, "fake-b")
+	registerFake(t, "fake-a")
+
+	assert.Equal(t, []string{"fake-a", "fake-b"}, Names())
+
+	_, ok := Lookup("fake-a")
+	assert.True(t, ok)
+	_, ok = Lookup("fake-c")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register(Definition{Name: "fake-a", NewArgs: func() Args { return &fakeArgs{} }}) },
+		"Incomplete definitions must not be registered")
+	assert.Panics(t, func() {
+		Register(Definition{
+			Name:    "fake-a",
+			NewArgs: func() Args { return &fakeArgs{} },
+			New:     func(Args, string, Options) backend.Interface { return nil },
+		})
+	}, "Backends must not be registered twice")
+}
+
+func TestSelector(t *testing.T) {
+	registerFake(t, "fake-a")
+	registerFake(t, "fake-b")
+
+	testCases := map[string]struct {
+		args      []string
+		env       map[string]string
+		expectErr bool
+
+		expectBackend string
+		expectArgs    fakeArgs
+	}{
+		"default backend": {
+			expectBackend: "fake-b",
+			expectArgs:    fakeArgs{Address: "localhost", Size: 10},
+		},
+		"flags": {
+			args:          []string{"--backend", "fake-a", "--fake-a.size", "5", "--fake-b.size", "7"},
+			expectBackend: "fake-a",
+			expectArgs:    fakeArgs{Address: "localhost", Size: 5},
+		},
+		"environment": {
+			env:           map[string]string{"BACKEND": "fake-a", "FAKE_A_ADDRESS": "remote"},
+			expectBackend: "fake-a",
+			expectArgs:    fakeArgs{Address: "remote", Size: 10},
+		},
+		"not registered backend": {
+			args:      []string{"--backend", "fake-c"},
+			expectErr: true,
+		},
+		"not valid arguments": {
+			args:      []string{"--fake-b.size", "-1"},
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			cli := struct {
+				Selector `embed:""`
+			}{Selector: *NewSelector()}
+
+			p, err := kong.New(&cli, cli.Vars("fake-b"))
+			require.NoError(t, err)
+
+			_, err = p.Parse(tc.args)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			b, factory, err := cli.Backends(Options{Logger: zap.NewNop().Sugar()})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectBackend, cli.Backend)
+			assert.Equal(t, tc.expectArgs, b.(*fakeBackend).args)
+			assert.Equal(t, "", b.(*fakeBackend).hosted)
+			assert.Equal(t, "hosted", factory("hosted").(*fakeBackend).hosted)
+		})
+	}
+}
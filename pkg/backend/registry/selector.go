@@ -0,0 +1,101 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/kong"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// Selector is a kong command line structure that selects the backend among
+// the registered ones, and embeds the arguments of all of them. It must be
+// created using NewSelector after registering the backends and before
+// parsing the command line.
+type Selector struct {
+	Backend string `help:"Backend implementation that stores events." env:"BACKEND" default:"${default_backend}"`
+
+	Plugins kong.Plugins `embed:""`
+
+	args map[string]Args
+}
+
+// NewSelector returns a selector for the registered backends.
+func NewSelector() *Selector {
+	s := &Selector{args: make(map[string]Args)}
+
+	for _, name := range Names() {
+		d, _ := Lookup(name)
+		plugin, args := prefixedArgs(d)
+		s.Plugins = append(s.Plugins, plugin)
+		s.args[name] = args
+	}
+
+	return s
+}
+
+// Vars returns the kong variables the selector uses, the default backend
+// being the first one registered in alphabetical order if there is not a
+// preferred one.
+func (s *Selector) Vars(preferred string) kong.Vars {
+	if _, ok := s.args[preferred]; !ok {
+		preferred = ""
+		if names := Names(); len(names) != 0 {
+			preferred = names[0]
+		}
+	}
+	return kong.Vars{"default_backend": preferred}
+}
+
+// prefixedArgs wraps the backend arguments in a structure that prefixes
+// their flags and environment variables with the backend name, returning
+// the kong plugin and the arguments it is parsed into.
+func prefixedArgs(d Definition) (interface{}, Args) {
+	args := d.NewArgs()
+
+	t := reflect.StructOf([]reflect.StructField{{
+		Name: "Args",
+		Type: reflect.TypeOf(args).Elem(),
+		Tag: reflect.StructTag(fmt.Sprintf(`embed:"" prefix:"%s." envprefix:"%s_"`,
+			d.Name, strings.ToUpper(strings.ReplaceAll(d.Name, "-", "_")))),
+	}})
+
+	plugin := reflect.New(t)
+	return plugin.Interface(), plugin.Elem().Field(0).Addr().Interface().(Args)
+}
+
+// Validate the arguments of the selected backend.
+func (s *Selector) Validate() error {
+	args, ok := s.args[s.Backend]
+	if !ok {
+		return fmt.Errorf("backend %q is not registered, available backends are: %s",
+			s.Backend, strings.Join(Names(), ", "))
+	}
+	return args.Validate()
+}
+
+// Selected returns the definition and arguments of the selected backend.
+func (s *Selector) Selected() (Definition, Args, error) {
+	d, ok := Lookup(s.Backend)
+	if !ok {
+		return Definition{}, nil, fmt.Errorf("backend %q is not registered", s.Backend)
+	}
+	return d, s.args[s.Backend], nil
+}
+
+// Backends returns the backend for the default broker and the factory of
+// backends for hosted brokers.
+func (s *Selector) Backends(o Options) (backend.Interface, func(hosted string) backend.Interface, error) {
+	d, args, err := s.Selected()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, factory := d.Backends(args, o)
+	return b, factory, nil
+}
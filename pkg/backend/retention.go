@@ -0,0 +1,29 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import "time"
+
+// Reasons for discarding events retained at the backend.
+const (
+	TrimReasonCount = "count"
+	TrimReasonBytes = "bytes"
+	TrimReasonAge   = "age"
+)
+
+// Retention limits the events retained at the backend, the oldest events
+// being discarded beyond any of the limits. Zero values do not limit.
+type Retention struct {
+	// MaxEvents is the maximum number of events retained.
+	MaxEvents int64
+	// MaxBytes is the maximum size in bytes of the events retained.
+	MaxBytes int64
+	// MaxAge is the maximum time events are retained since produced.
+	MaxAge time.Duration
+}
+
+// Limited returns true if any of the limits is set.
+func (r Retention) Limited() bool {
+	return r.MaxEvents > 0 || r.MaxBytes > 0 || r.MaxAge > 0
+}
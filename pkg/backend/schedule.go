@@ -0,0 +1,81 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// DeliverAtExtension is the CloudEvents extension that informs the
+	// time, formatted as RFC3339, before which the event must not be
+	// delivered.
+	DeliverAtExtension = "deliverat"
+
+	// DelaySecondsExtension is the CloudEvents extension that informs the
+	// number of seconds since the event is produced before it can be
+	// delivered.
+	DelaySecondsExtension = "delayseconds"
+
+	// RequeueAtExtension is the CloudEvents extension used internally by
+	// the broker for events requeued after a failed delivery, which
+	// informs the time, formatted as RFC3339, when they are due.
+	RequeueAtExtension = "triggermeshrequeueat"
+)
+
+// ScheduledTime returns the time when the event must be delivered according
+// to its scheduling extensions, and true if that time is after now. When both
+// extensions are informed DeliverAtExtension takes precedence, and
+// RequeueAtExtension takes precedence over both.
+func ScheduledTime(event *cloudevents.Event, now time.Time) (time.Time, bool, error) {
+	exts := event.Extensions()
+
+	if v, ok := exts[RequeueAtExtension]; ok {
+		t, err := types.ToTime(v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("extension %q is not a valid timestamp: %w", RequeueAtExtension, err)
+		}
+		return t, t.After(now), nil
+	}
+
+	if v, ok := exts[DeliverAtExtension]; ok {
+		t, err := types.ToTime(v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("extension %q is not a valid timestamp: %w", DeliverAtExtension, err)
+		}
+		return t, t.After(now), nil
+	}
+
+	if v, ok := exts[DelaySecondsExtension]; ok {
+		var delay int64
+		switch tv := v.(type) {
+		case string:
+			d, err := strconv.ParseInt(tv, 10, 64)
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("extension %q is not a valid integer: %w", DelaySecondsExtension, err)
+			}
+			delay = d
+		default:
+			d, err := types.ToInteger(v)
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("extension %q is not a valid integer: %w", DelaySecondsExtension, err)
+			}
+			delay = int64(d)
+		}
+
+		if delay < 0 {
+			return time.Time{}, false, fmt.Errorf("extension %q must not be negative", DelaySecondsExtension)
+		}
+
+		t := now.Add(time.Duration(delay) * time.Second)
+		return t, delay > 0, nil
+	}
+
+	return now, false, nil
+}
@@ -10,22 +10,40 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/triggermesh/brokers/pkg/admin"
+	"github.com/triggermesh/brokers/pkg/archive"
+	"github.com/triggermesh/brokers/pkg/audit"
 	"github.com/triggermesh/brokers/pkg/backend"
 	"github.com/triggermesh/brokers/pkg/broker/cmd"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+	"github.com/triggermesh/brokers/pkg/common/eventid"
 	"github.com/triggermesh/brokers/pkg/common/fs"
 	"github.com/triggermesh/brokers/pkg/common/kubernetes/controller"
+	"github.com/triggermesh/brokers/pkg/common/secret"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
 	cfgbpoller "github.com/triggermesh/brokers/pkg/config/broker/poller"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
 	cfgbwatcher "github.com/triggermesh/brokers/pkg/config/broker/watcher"
 	cfgopoller "github.com/triggermesh/brokers/pkg/config/observability/poller"
 	cfgowatcher "github.com/triggermesh/brokers/pkg/config/observability/watcher"
+	"github.com/triggermesh/brokers/pkg/destination"
+	"github.com/triggermesh/brokers/pkg/features"
+	"github.com/triggermesh/brokers/pkg/firehose"
 	"github.com/triggermesh/brokers/pkg/ingest"
 	"github.com/triggermesh/brokers/pkg/ingest/metrics"
+	"github.com/triggermesh/brokers/pkg/scaling"
+	"github.com/triggermesh/brokers/pkg/simulation"
+	"github.com/triggermesh/brokers/pkg/sla"
+	"github.com/triggermesh/brokers/pkg/status"
+	"github.com/triggermesh/brokers/pkg/stream"
 	"github.com/triggermesh/brokers/pkg/subscriptions"
+	"github.com/triggermesh/brokers/pkg/tap"
+	"github.com/triggermesh/brokers/pkg/throughput"
 )
 
 type Status string
@@ -38,29 +56,194 @@ const (
 )
 
 type Instance struct {
-	backend      backend.Interface
-	ingest       *ingest.Instance
-	subscription *subscriptions.Manager
-	bcw          *cfgbwatcher.Watcher
-	ocw          *cfgowatcher.Watcher
-	bcp          *cfgbpoller.Poller
-	ocp          *cfgopoller.Poller
-	km           *controller.Manager
-	staticConfig *cfgbroker.Config
-	status       Status
+	backend        backend.Interface
+	ingest         *ingest.Instance
+	subscription   *subscriptions.Manager
+	bcw            *cfgbwatcher.Watcher
+	ocw            *cfgowatcher.Watcher
+	bcp            *cfgbpoller.Poller
+	ocp            *cfgopoller.Poller
+	km             *controller.Manager
+	staticConfig   *cfgbroker.Config
+	admin          *admin.Server
+	statusReporter *status.Reporter
+	throughput     *throughput.Recorder
+	sla            *sla.Reporter
+	scaling        *scaling.Monitor
+	features       *features.Set
+	status         Status
+
+	// Maximum time to wait for in-flight deliveries when shutting down.
+	shutdownGracePeriod time.Duration
+
+	// Brokers hosted along with the default broker, each using a backend
+	// created by the factory.
+	backendFactory BackendFactory
+	hosted         *hostedBrokers
 
 	logger *zap.SugaredLogger
 }
 
-func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
+func NewInstance(globals *cmd.Globals, b backend.Interface, opts ...InstanceOption) (*Instance, error) {
 	globals.Logger.Debug("Creating subscription manager")
 
+	flags := features.NewSet(globals.Logger.Named("features"))
+
+	smopts := []subscriptions.ManagerOption{
+		subscriptions.ManagerWithFeatures(flags),
+		subscriptions.ManagerWithIntegrity(globals.EventIntegrity),
+		subscriptions.ManagerWithQuarantinePath(globals.EventQuarantinePath),
+		subscriptions.ManagerWithDebug(globals.EventDebug),
+		subscriptions.ManagerWithStrictFilters(globals.TriggerStrictFilters),
+		subscriptions.ManagerWithEventTTL(globals.EventTTLDuration),
+		subscriptions.ManagerWithReplyBatching(globals.ReplyBatchSize, globals.ReplyBatchDelayDuration),
+		subscriptions.ManagerWithRetryAfter(globals.DeliveryRetryAfter, globals.DeliveryRetryAfterMaxDuration),
+		subscriptions.ManagerWithRetryBudget(globals.DeliveryRetryBudget, globals.DeliveryRetryBudgetMinRetries),
+		subscriptions.ManagerWithHostConcurrency(globals.DeliveryMaxInFlight, globals.DeliveryMaxInFlightPerHost),
+		subscriptions.ManagerWithRequeue(globals.DeliveryRequeueMinDelayDuration),
+		subscriptions.ManagerWithUpdateDrain(globals.TriggerUpdateDrainTimeoutDuration),
+		subscriptions.ManagerWithMaxHops(int32(globals.EventMaxHops)),
+		subscriptions.ManagerWithHTTPTransport(subscriptions.HTTPTransportConfig{
+			MaxIdleConns:        globals.DeliveryMaxIdleConns,
+			MaxIdleConnsPerHost: globals.DeliveryMaxIdleConnsPerHost,
+			MaxConnsPerHost:     globals.DeliveryMaxConnsPerHost,
+			IdleConnTimeout:     globals.DeliveryIdleConnTimeoutDuration,
+			KeepAlive:           globals.DeliveryKeepAliveDuration,
+			DisableKeepAlives:   globals.DeliveryDisableKeepAlives,
+			HTTP2:               globals.DeliveryHTTP2,
+		}),
+	}
+
+	// Hourly throughput counters are kept at the backend.
+	var tr *throughput.Recorder
+	if globals.ThroughputRetentionDuration > 0 {
+		ts, ok := b.(backend.ThroughputStore)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support throughput history", b.Info().Name)
+		}
+		tr = throughput.NewRecorder(ts, globals.ThroughputRetentionDuration, globals.Logger.Named("throughput"))
+		smopts = append(smopts, subscriptions.ManagerWithThroughput(tr))
+	}
+
+	// Dispatch decisions, the events of stream targets, and the ingested
+	// events that are tapped, are streamed through the admin API.
+	var hub *firehose.Hub
+	var streams *stream.Hub
+	var th *tap.Hub
+	if globals.AdminPort != 0 {
+		hub = firehose.NewHub()
+		streams = stream.NewHub()
+		th = tap.NewHub()
+		smopts = append(smopts, subscriptions.ManagerWithFirehose(hub), subscriptions.ManagerWithStreams(streams))
+	}
+
+	if globals.AuditSink != "" {
+		as, err := audit.NewSink(globals.Context, globals.AuditSink, "broker/"+globals.BrokerName, globals.Logger.Named("audit"))
+		if err != nil {
+			return nil, fmt.Errorf("error creating audit sink: %w", err)
+		}
+		smopts = append(smopts, subscriptions.ManagerWithAuditSink(as))
+	}
+
+	// Per trigger SLA reports are emitted for the default broker, whose
+	// trigger names are unique.
+	var slar *sla.Reporter
+	if globals.SLAReportPeriodDuration > 0 {
+		ss, err := sla.NewSink(globals.SLAReportSink, "broker/"+globals.BrokerName)
+		if err != nil {
+			return nil, fmt.Errorf("error creating SLA report sink: %w", err)
+		}
+		slar = sla.NewReporter(ss, globals.SLAReportPeriodDuration, globals.Logger.Named("sla"))
+	}
+
+	// Deliveries recorded as fixtures are kept at the backend.
+	if fs, ok := b.(backend.FixtureStore); ok {
+		smopts = append(smopts, subscriptions.ManagerWithFixtureStore(fs))
+	}
+
+	// Objects referenced by targets are resolved using the cluster DNS
+	// naming, or reading Addressables from the Kubernetes API.
+	namespace := globals.KubernetesNamespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	var dr destination.Resolver = &destination.DNS{Domain: globals.DestinationClusterDomain, Namespace: namespace}
+	if globals.DestinationResolver == "kubernetes" {
+		kr, err := destination.NewInClusterKubernetes(globals.DestinationClusterDomain, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error creating destination resolver: %w", err)
+		}
+		dr = kr
+	}
+	smopts = append(smopts, subscriptions.ManagerWithResolver(destination.NewCache(dr, globals.DestinationCacheTTLDuration)))
+
+	// Triggers deleted through the admin API keep their position at the
+	// default broker backend during the grace period.
+	dmopts := smopts
+	if p, ok := b.(backend.SubscriptionPurger); ok && globals.TriggerDeletionGracePeriodDuration > 0 {
+		dmopts = append(smopts[:len(smopts):len(smopts)], subscriptions.ManagerWithDeletedTriggers(p, globals.TriggerDeletionGracePeriodDuration))
+	}
+
+	// Retries of events dispatched again by the default broker backend
+	// resume their schedule.
+	if globals.DeliveryRetryState {
+		st, ok := b.(backend.RetryStateStore)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support retry state persistence", b.Info().Name)
+		}
+		dmopts = append(dmopts[:len(dmopts):len(dmopts)], subscriptions.ManagerWithRetryStates(st))
+	}
+
+	// Replicas sharing the default broker backend can split its triggers.
+	if globals.TriggerShardingLeaseDuration > 0 {
+		r, ok := b.(backend.ReplicaRegistry)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support trigger sharding", b.Info().Name)
+		}
+		dmopts = append(dmopts[:len(dmopts):len(dmopts)], subscriptions.ManagerWithSharding(r, globals.TriggerShardingReplica, globals.TriggerShardingLeaseDuration))
+
+		if globals.TriggerShardingExclusive {
+			l, ok := b.(backend.TriggerLeaser)
+			if !ok {
+				return nil, fmt.Errorf("backend %s does not support exclusive trigger sharding", b.Info().Name)
+			}
+			dmopts = append(dmopts, subscriptions.ManagerWithTriggerLeases(l))
+		}
+	}
+
+	// Replicas sharing the default broker backend can elect a single one to
+	// dispatch its triggers.
+	if globals.LeaderElectionLeaseDuration > 0 {
+		e, ok := b.(backend.LeaderElector)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support leader election", b.Info().Name)
+		}
+		dmopts = append(dmopts[:len(dmopts):len(dmopts)], subscriptions.ManagerWithLeaderElection(e, globals.LeaderElectionReplica, globals.LeaderElectionLeaseDuration))
+	}
+
+	if slar != nil {
+		dmopts = append(dmopts[:len(dmopts):len(dmopts)], subscriptions.ManagerWithSLAReporter(slar))
+	}
+
 	// Create subscription manager.
-	sm, err := subscriptions.New(globals.Context, globals.Logger.Named("subs"), b)
+	sm, err := subscriptions.New(globals.Context, globals.Logger.Named("subs"), b, dmopts...)
 	if err != nil {
 		return nil, err
 	}
 
+	// The backlog of the default broker triggers is exposed for autoscalers.
+	var sc *scaling.Monitor
+	if globals.ScalingMetricsPeriodDuration > 0 {
+		br, ok := b.(backend.BacklogReporter)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support autoscaling metrics", b.Info().Name)
+		}
+		sc, err = scaling.NewMonitor(br, sm.Triggers, globals.ScalingMetricsPeriodDuration, globals.Logger.Named("scaling"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	globals.Logger.Debug("Creating HTTP ingest server")
 	// Create metrics reporter.
 	ir, err := metrics.NewReporter(globals.Context)
@@ -68,20 +251,174 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 		return nil, err
 	}
 
-	i := ingest.NewInstance(ir, globals.Logger.Named("ingest"),
+	iopts := []ingest.InstanceOption{
 		ingest.InstanceWithPort(globals.Port),
-	)
+		ingest.InstanceWithMaxInFlight(globals.IngestMaxInFlight),
+		ingest.InstanceWithRetryAfter(globals.IngestRetryAfterDuration),
+		ingest.InstanceWithRateLimit(globals.IngestRateLimit, globals.IngestRateBurst),
+		ingest.InstanceWithIntegrity(globals.EventIntegrity),
+		ingest.InstanceWithDebug(globals.EventDebug),
+		ingest.InstanceWithEventTTL(globals.EventTTLDuration),
+		ingest.InstanceWithThroughput(tr),
+		ingest.InstanceWithMQTTPort(globals.IngestMQTTPort),
+	}
+
+	if globals.IngestAMQPURL != "" {
+		iopts = append(iopts, ingest.InstanceWithAMQP(globals.IngestAMQPURL, globals.IngestAMQPAddress))
+	}
+
+	// Events are limited to the backend maximum size, unless a lower
+	// size is configured.
+	maxEventSize := globals.IngestMaxEventSize
+	if bmax := b.Info().MaxEventSize; bmax > 0 && (maxEventSize == 0 || bmax < maxEventSize) {
+		maxEventSize = bmax
+	}
+	iopts = append(iopts, ingest.InstanceWithMaxEventSize(maxEventSize))
+
+	if globals.EventProvenance {
+		iopts = append(iopts, ingest.InstanceWithProvenance(globals.BrokerName, globals.EventProvenanceMaxLength))
+	}
+
+	if globals.IngestDeduplicationTTLDuration > 0 {
+		d, ok := b.(backend.Deduplicator)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support deduplication", b.Info().Name)
+		}
+		iopts = append(iopts, ingest.InstanceWithDeduplication(d, globals.IngestDeduplicationTTLDuration))
+	}
+
+	if globals.EventTTLDuration > 0 {
+		if t, ok := b.(backend.AgeTrimmer); ok {
+			t.SetMaxAge(globals.EventTTLDuration)
+		} else {
+			globals.Logger.Infow("Backend does not support trimming events by age, expired events are discarded at delivery",
+				zap.String("backend", b.Info().Name))
+		}
+	}
+
+	retention := backend.Retention{
+		MaxEvents: globals.RetentionMaxEvents,
+		MaxBytes:  globals.RetentionMaxBytes,
+		MaxAge:    globals.RetentionMaxAgeDuration,
+	}
+	if retention.Limited() {
+		r, ok := b.(backend.RetentionEnforcer)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support retention limits", b.Info().Name)
+		}
+		r.SetRetention(retention)
+	}
+
+	// Events are encrypted before being stored at the backend.
+	var ec *encryption.Cipher
+	if globals.EventEncryptionKey != "" {
+		ee, ok := b.(backend.EventEncrypter)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support encryption", b.Info().Name)
+		}
+		if ec, err = encryption.New(globals.EventEncryptionKey); err != nil {
+			return nil, fmt.Errorf("could not setup event encryption: %w", err)
+		}
+		ee.SetCipher(ec)
+	}
+
+	idGenerator := eventid.Default()
+	if globals.EventIDStrategy != "" {
+		idGenerator, err = eventid.New(eventid.Strategy(globals.EventIDStrategy), globals.EventIDInstance)
+		if err != nil {
+			return nil, err
+		}
+		iopts = append(iopts, ingest.InstanceWithIDGenerator(idGenerator))
+	}
+
+	iopts = append(iopts, ingest.InstanceWithConformanceMode(ingest.ConformanceMode(globals.IngestConformance)))
+
+	if globals.IngestSyncMode != "" {
+		iopts = append(iopts, ingest.InstanceWithSyncMode(ingest.SyncMode(globals.IngestSyncMode), globals.IngestSyncTimeoutDuration))
+	}
+
+	if globals.IngestDiscovery {
+		iopts = append(iopts, ingest.InstanceWithDiscovery(globals.BrokerName))
+	}
+
+	// Ingested events are archived at the backend.
+	var ar *archive.Archive
+	if globals.EventArchiveRetentionDuration > 0 {
+		ea, ok := b.(backend.EventArchive)
+		if !ok {
+			return nil, fmt.Errorf("backend %s does not support event archive", b.Info().Name)
+		}
+		ar = archive.New(ea, globals.EventArchiveRetentionDuration, globals.Logger.Named("archive"))
+		iopts = append(iopts, ingest.InstanceWithArchive(ar))
+	}
+
+	if th != nil {
+		iopts = append(iopts, ingest.InstanceWithTap(th))
+	}
+
+	i := ingest.NewInstance(ir, globals.Logger.Named("ingest"), iopts...)
 
 	globals.Logger.Debug("Creating broker instance")
 	broker := &Instance{
 		backend:      b,
 		ingest:       i,
 		subscription: sm,
+		throughput:   tr,
+		sla:          slar,
+		scaling:      sc,
+		features:     flags,
 		status:       StatusStopped,
 
+		shutdownGracePeriod: globals.ShutdownGracePeriodDuration,
+
 		logger: globals.Logger.Named("broker"),
 	}
 
+	for _, opt := range opts {
+		opt(broker)
+	}
+
+	// Hosted brokers share the cipher of the default broker.
+	if ec != nil && broker.backendFactory != nil {
+		factory := broker.backendFactory
+		broker.backendFactory = func(name string) backend.Interface {
+			hb := factory(name)
+			if ee, ok := hb.(backend.EventEncrypter); ok {
+				ee.SetCipher(ec)
+			}
+			return hb
+		}
+	}
+
+	broker.hosted = &hostedBrokers{
+		factory: broker.backendFactory,
+		newManager: func(ctx context.Context, name string, hb backend.Interface) (*subscriptions.Manager, error) {
+			opts := smopts
+			if fs, ok := hb.(backend.FixtureStore); ok {
+				opts = append(opts[:len(opts):len(opts)], subscriptions.ManagerWithFixtureStore(fs))
+			}
+			return subscriptions.New(ctx, globals.Logger.Named("subs").With(zap.String("broker", name)), hb, opts...)
+		},
+		ingest:      i,
+		brokers:     make(map[string]*hostedBroker),
+		gracePeriod: globals.ShutdownGracePeriodDuration,
+		logger:      globals.Logger.Named("hosted"),
+	}
+
+	// Store where changes done through the admin API are persisted.
+	var cs store.ConfigStore
+
+	// Strict mode fully validates configurations before applying them.
+	var validator cfgbroker.Validator
+	if globals.BrokerConfigStrict {
+		validator = func(c *cfgbroker.Config) error {
+			if err := subscriptions.ValidateConfig(globals.Context, c); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+
 	switch globals.ConfigMethod {
 
 	case cmd.ConfigMethodFileWatcher:
@@ -98,13 +435,22 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 			return nil, fmt.Errorf("error resolving to absolute path %q: %w", globals.BrokerConfigPath, err)
 		}
 
-		globals.Logger.Debugw("Creating watcher for broker configuration", zap.String("file", configPath))
-		bcfgw, err := cfgbwatcher.NewWatcher(cfw, configPath, globals.Logger.Named("cgfwatch"))
+		overlays, err := configOverlays(globals.BrokerConfigOverlays)
+		if err != nil {
+			return nil, err
+		}
+
+		globals.Logger.Debugw("Creating watcher for broker configuration", zap.String("file", configPath), zap.Strings("overlays", overlays))
+		bcfgw, err := cfgbwatcher.NewWatcher(cfw, configPath, globals.Logger.Named("cgfwatch"), overlays...)
 		if err != nil {
 			return nil, fmt.Errorf("error adding broker watcher for %q: %w", configPath, err)
 		}
 
+		if validator != nil {
+			bcfgw.Strict(validator)
+		}
 		broker.bcw = bcfgw
+		cs = configStore(configPath, overlays)
 
 		if globals.ObservabilityConfigPath != "" {
 			var ocfgw *cfgowatcher.Watcher
@@ -138,8 +484,18 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 			return nil, fmt.Errorf("error adding broker Secret reconciler to controller: %w", err)
 		}
 
+		if validator != nil {
+			km.StrictBrokerConfig(validator)
+		}
+		// Secrets referenced by the broker configuration are read through
+		// the manager cache.
+		secret.SetKubernetesReader(km.SecretReader())
+
 		km.AddSecretCallbackForBrokerConfig(i.UpdateFromConfig)
 		km.AddSecretCallbackForBrokerConfig(sm.UpdateFromConfig)
+		km.AddSecretCallbackForBrokerConfig(broker.hosted.UpdateFromConfig)
+		km.AddSecretCallbackForBrokerConfig(broker.features.UpdateFromConfig)
+		cs = km.BrokerConfigStore()
 
 		if globals.KubernetesObservabilityConfigMapName != "" {
 			if err = km.AddConfigMapControllerForObservability(globals.KubernetesObservabilityConfigMapName); err != nil {
@@ -162,13 +518,22 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 			return nil, fmt.Errorf("error resolving to absolute path %q: %w", globals.BrokerConfigPath, err)
 		}
 
-		globals.Logger.Debugw("Creating poller for broker configuration", zap.String("file", configPath))
-		bcfgp, err := cfgbpoller.NewPoller(p, configPath, globals.Logger.Named("cfgpoller"))
+		overlays, err := configOverlays(globals.BrokerConfigOverlays)
+		if err != nil {
+			return nil, err
+		}
+
+		globals.Logger.Debugw("Creating poller for broker configuration", zap.String("file", configPath), zap.Strings("overlays", overlays))
+		bcfgp, err := cfgbpoller.NewPoller(p, configPath, globals.Logger.Named("cfgpoller"), overlays...)
 		if err != nil {
 			return nil, fmt.Errorf("error adding broker poller for %q: %w", configPath, err)
 		}
 
+		if validator != nil {
+			bcfgp.Strict(validator)
+		}
 		broker.bcp = bcfgp
+		cs = configStore(configPath, overlays)
 
 		if globals.ObservabilityConfigPath != "" {
 			obsCfgPath, err := filepath.Abs(globals.ObservabilityConfigPath)
@@ -196,7 +561,76 @@ func NewInstance(globals *cmd.Globals, b backend.Interface) (*Instance, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error parsing inline broker configuration: %w", err)
 		}
+		if validator != nil {
+			if err := validator(cfg); err != nil {
+				return nil, fmt.Errorf("inline broker configuration is not valid: %w", err)
+			}
+		}
 		broker.staticConfig = cfg
+
+		// Inline configuration cannot be persisted, changes done through
+		// the admin API are lost when the broker restarts.
+		cs = store.NewMemory()
+	}
+
+	if globals.AdminPort != 0 {
+		broker.admin = admin.New(cs, globals.Logger.Named("admin"),
+			admin.ServerWithPort(globals.AdminPort),
+			admin.ServerWithToken(globals.AdminToken),
+			admin.ServerWithEventProducer(i),
+			admin.ServerWithIDGenerator(idGenerator),
+			admin.ServerWithFirehose(hub),
+			admin.ServerWithStreams(streams),
+			admin.ServerWithTap(th),
+			admin.ServerWithUI(globals.AdminUI),
+			admin.ServerWithSubscriptions(globals.AdminSubscriptions, globals.AdminSubscriptionsDeliveryOptions),
+			admin.ServerWithDeletionGracePeriod(globals.TriggerDeletionGracePeriodDuration),
+			admin.ServerWithValidator(validator))
+
+		// Changes done through the admin API are applied right away,
+		// configuration watchers will receive them later in the same
+		// form, which is a no-op for already applied configurations.
+		broker.admin.AddCallback(i.UpdateFromConfig)
+		broker.admin.AddCallback(sm.UpdateFromConfig)
+		broker.admin.AddCallback(broker.hosted.UpdateFromConfig)
+		broker.admin.AddCallback(broker.features.UpdateFromConfig)
+
+		if broker.km != nil {
+			broker.km.AddSecretCallbackForBrokerConfig(broker.admin.UpdateFromConfig)
+		}
+
+		broker.admin.Handle("/v1/status", status.Handler(sm.Status))
+		broker.admin.Handle("/v1/features", features.Handler(broker.features))
+		if tr != nil {
+			broker.admin.Handle("/v1/throughput", throughput.Handler(tr))
+		}
+		if slar != nil {
+			broker.admin.Handle("/v1/sla", sla.Handler(slar))
+		}
+		if sc != nil {
+			broker.admin.Handle("/v1/scaling", scaling.Handler(sc))
+		}
+		if ar != nil {
+			broker.admin.Handle("/v1/archive", archive.Handler(ar, i))
+		}
+		if er, ok := b.(backend.EventReader); ok {
+			broker.admin.Handle("/v1/simulations", simulation.Handler(er, globals.Logger.Named("simulation")))
+		}
+	}
+
+	if globals.StatusSink != "" {
+		var sw status.Writer
+		if globals.StatusSink == "secret" {
+			sw = broker.km.BrokerStatusWriter()
+		} else {
+			path, err := status.ParseFileSink(globals.StatusSink)
+			if err != nil {
+				return nil, err
+			}
+			sw = status.NewFileWriter(path)
+		}
+
+		broker.statusReporter = status.NewReporter(sm.Status, sw, globals.StatusPeriodDuration, globals.Logger.Named("status"))
 	}
 
 	return broker, nil
@@ -234,9 +668,24 @@ func (i *Instance) Start(inctx context.Context) error {
 	// Start is a blocking function that will read messages from the backend
 	// implementation and send them to the subscription manager dispatcher.
 	// When the dispatcher returns the message is marked as processed.
+	//
+	// When signaled the backend is not stopped until ingest has stopped and
+	// is then drained, hence its context is not canceled by signals.
 	i.logger.Debug("Starting backend routine")
+	bctx, stopBackend := context.WithCancel(inctx)
+	defer stopBackend()
+	backendDone := make(chan error, 1)
+	ingestDone := make(chan struct{})
+	go func() {
+		backendDone <- i.backend.Start(bctx)
+	}()
 	grp.Go(func() error {
-		return i.backend.Start(ctx)
+		select {
+		case err := <-backendDone:
+			return err
+		case <-ctx.Done():
+		}
+		return i.drain(stopBackend, ingestDone, backendDone)
 	})
 
 	// Setup broker config file watchers only if configured.
@@ -247,6 +696,11 @@ func (i *Instance) Start(inctx context.Context) error {
 		i.logger.Debug("Adding config watcher callbacks")
 		i.bcw.AddCallback(i.ingest.UpdateFromConfig)
 		i.bcw.AddCallback(i.subscription.UpdateFromConfig)
+		i.bcw.AddCallback(i.hosted.UpdateFromConfig)
+		i.bcw.AddCallback(i.features.UpdateFromConfig)
+		if i.admin != nil {
+			i.bcw.AddCallback(i.admin.UpdateFromConfig)
+		}
 
 		// Start the configuration watcher for brokers.
 		// There is no need to add it to the wait group
@@ -269,6 +723,11 @@ func (i *Instance) Start(inctx context.Context) error {
 		i.logger.Debug("Adding config poller callbacks")
 		i.bcp.AddCallback(i.ingest.UpdateFromConfig)
 		i.bcp.AddCallback(i.subscription.UpdateFromConfig)
+		i.bcp.AddCallback(i.hosted.UpdateFromConfig)
+		i.bcp.AddCallback(i.features.UpdateFromConfig)
+		if i.admin != nil {
+			i.bcp.AddCallback(i.admin.UpdateFromConfig)
+		}
 
 		// Start the configuration poller for brokers.
 		// There is no need to add it to the wait group
@@ -300,24 +759,116 @@ func (i *Instance) Start(inctx context.Context) error {
 	if i.staticConfig != nil {
 		i.ingest.UpdateFromConfig(i.staticConfig)
 		i.subscription.UpdateFromConfig(i.staticConfig)
+		i.hosted.UpdateFromConfig(i.staticConfig)
+		i.features.UpdateFromConfig(i.staticConfig)
+		if i.admin != nil {
+			i.admin.UpdateFromConfig(i.staticConfig)
+		}
 	}
 
 	// Register producer function for received events at ingest.
 	i.ingest.RegisterCloudEventHandler(i.backend.Produce)
+	i.ingest.RegisterSyncTarget("", ingest.SyncTarget{
+		Durable: i.backend.Info().Durable,
+		Track:   i.subscription.TrackDispatch,
+	})
 
 	// TODO register probes at ingest
 
 	// Start the server that ingests CloudEvents.
 	grp.Go(func() error {
+		defer close(ingestDone)
 		err := i.ingest.Start(ctx)
 		return err
 	})
 
+	// The subscription manager renews its lease when sharding triggers.
+	grp.Go(func() error {
+		return i.subscription.Start(ctx)
+	})
+
+	// Hosted brokers are started along with the configuration.
+	grp.Go(func() error {
+		return i.hosted.start(ctx)
+	})
+
+	// Start the status reporter only if configured.
+	if i.statusReporter != nil {
+		grp.Go(func() error {
+			return i.statusReporter.Start(ctx)
+		})
+	}
+
+	// Start the throughput recorder only if configured.
+	if i.throughput != nil {
+		grp.Go(func() error {
+			return i.throughput.Start(ctx)
+		})
+	}
+
+	// Start the SLA reporter only if configured.
+	if i.sla != nil {
+		grp.Go(func() error {
+			return i.sla.Start(ctx)
+		})
+	}
+
+	// Start the backlog monitor only if configured.
+	if i.scaling != nil {
+		grp.Go(func() error {
+			return i.scaling.Start(ctx)
+		})
+	}
+
+	// Start the admin API server only if configured.
+	if i.admin != nil {
+		grp.Go(func() error {
+			return i.admin.Start(ctx)
+		})
+	}
+
 	i.status = StatusRunning
 
 	return grp.Wait()
 }
 
+// drain stops the backend once ingest has stopped and waits for the events
+// being dispatched, up to the shutdown grace period. Events whose delivery
+// does not finish in time are not marked as processed at the backend, which
+// will dispatch them again.
+func (i *Instance) drain(stopBackend context.CancelFunc, ingestDone <-chan struct{}, backendDone <-chan error) error {
+	i.logger.Infow("Draining in-flight deliveries", zap.Duration("grace_period", i.shutdownGracePeriod))
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.shutdownGracePeriod)
+	defer cancel()
+
+	select {
+	case <-ingestDone:
+	case <-ctx.Done():
+	}
+
+	// Replies waiting to be batched are produced while the backend runs.
+	i.subscription.FlushReplies()
+	stopBackend()
+
+	// Backends might dispatch the events they already read before stopping.
+	select {
+	case err := <-backendDone:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	if err := i.subscription.Drain(ctx); err != nil {
+		i.logger.Warnw("Shutdown grace period expired before in-flight deliveries finished", zap.Error(err))
+		return nil
+	}
+
+	i.logger.Info("In-flight deliveries drained")
+	return nil
+}
+
 func (i *Instance) GetStatus() Status {
 	return i.status
 }
@@ -326,3 +877,25 @@ func (i *Instance) ProbeHandler() error {
 	// TODO check each service
 	return nil
 }
+
+// configOverlays resolves the configuration overlays to absolute paths.
+func configOverlays(paths []string) ([]string, error) {
+	overlays := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving to absolute path %q: %w", p, err)
+		}
+		overlays = append(overlays, abs)
+	}
+	return overlays, nil
+}
+
+// configStore returns the store for configurations read from files.
+// Configurations merged from overlays cannot be written back to them.
+func configStore(configPath string, overlays []string) store.ConfigStore {
+	if len(overlays) != 0 {
+		return store.NewReadOnly()
+	}
+	return store.NewFile(configPath)
+}
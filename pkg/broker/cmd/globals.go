@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"net/url"
 	"strings"
 	"time"
 
@@ -22,8 +24,12 @@ import (
 
 	knmetrics "knative.dev/pkg/metrics"
 
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+	"github.com/triggermesh/brokers/pkg/common/logging"
 	"github.com/triggermesh/brokers/pkg/common/metrics"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
 	"github.com/triggermesh/brokers/pkg/config/observability"
+	"github.com/triggermesh/brokers/pkg/ingest"
 )
 
 const (
@@ -51,6 +57,12 @@ type Globals struct {
 	// Config Polling is an alternative to the default file watcher for config files.
 	ConfigPollingPeriod string `help:"Period for polling the configuration files using ISO8601. A zero duration disables configuration by polling." env:"CONFIG_POLLING_PERIOD" default:"PT0S"`
 
+	// Strict configuration validation
+	BrokerConfigStrict bool `help:"Fully validate broker configurations before applying them, including trigger filter compilation, refusing to apply invalid ones. The broker does not start if the initial configuration is not valid." env:"BROKER_CONFIG_STRICT" default:"false"`
+
+	// Configuration overlays are merged over the broker configuration file.
+	BrokerConfigOverlays []string `help:"Comma separated list of files, or directories containing configuration files, merged in order over the broker configuration file. Later sources take precedence." env:"BROKER_CONFIG_OVERLAYS" sep:","`
+
 	// Inline Configuration
 	BrokerConfig        string `help:"JSON representation of broker configuration." env:"BROKER_CONFIG"`
 	ObservabilityConfig string `help:"JSON representation of observability configuration." env:"OBSERVABILITY_CONFIG"`
@@ -63,11 +75,184 @@ type Globals struct {
 
 	ObservabilityMetricsDomain string `help:"Domain to be used for some metrics reporters." env:"OBSERVABILITY_METRICS_DOMAIN" default:"triggermesh.io/eventing"`
 
-	Context       context.Context    `kong:"-"`
-	Logger        *zap.SugaredLogger `kong:"-"`
-	LogLevel      zap.AtomicLevel    `kong:"-"`
-	PollingPeriod time.Duration      `kong:"-"`
-	ConfigMethod  ConfigMethod       `kong:"-"`
+	// Ingest backpressure
+	IngestMaxInFlight int    `help:"Maximum number of events being concurrently ingested. Requests beyond the limit are rejected with 429 status code. Zero means unlimited." env:"INGEST_MAX_IN_FLIGHT" default:"0"`
+	IngestRetryAfter  string `help:"Wait time informed at the Retry-After header to producers when events are rejected due to backpressure, using ISO8601." env:"INGEST_RETRY_AFTER" default:"PT1S"`
+
+	// Ingest rate limiting
+	IngestRateLimit float64 `help:"Maximum number of events per second that can be ingested. Requests beyond the limit are rejected with 429 status code. Zero means unlimited." env:"INGEST_RATE_LIMIT" default:"0"`
+	IngestRateBurst int     `help:"Maximum number of events that can be ingested in a burst when rate limiting is enabled. Defaults to the rate limit if zero." env:"INGEST_RATE_BURST" default:"0"`
+
+	// Ingest size limit
+	IngestMaxEventSize int `help:"Maximum size in bytes of ingested events. Larger events are rejected with 413 status code. Zero means the backend limit, if any." env:"INGEST_MAX_EVENT_SIZE" default:"0"`
+
+	// Ingest deduplication
+	IngestDeduplicationTTL string `help:"Time window where events with the same source and id are considered duplicated and discarded at ingest, using ISO8601. Zero disables deduplication." env:"INGEST_DEDUPLICATION_TTL" default:"PT0S"`
+
+	// Ingest conformance
+	IngestConformance string `help:"Handling of ingested events that violate the CloudEvents specification: strict rejects them, lenient drops their non valid optional attributes with a warning, and repair fixes what it can." env:"INGEST_CONFORMANCE" default:"strict"`
+
+	// Synchronous ingest
+	IngestSyncMode    string `help:"Respond to producers once events are persisted at the backend, or also dispatched to all triggers, informing the outcome as JSON: persisted or dispatched. Disabled if empty." env:"INGEST_SYNC_MODE"`
+	IngestSyncTimeout string `help:"Maximum time to wait for events to be dispatched to all triggers in the dispatched synchronous ingest mode using ISO8601." env:"INGEST_SYNC_TIMEOUT" default:"PT10S"`
+
+	// MQTT ingest
+	IngestMQTTPort int `help:"TCP Port to listen for MQTT clients, whose published messages are ingested as CloudEvents. Zero disables the MQTT listener." env:"INGEST_MQTT_PORT" default:"0"`
+
+	// AMQP ingest
+	IngestAMQPURL     string `help:"URL of the AMQP 1.0 server to receive CloudEvents from, using the amqps scheme for TLS. Credentials informed at the URL authenticate using SASL PLAIN. Disabled if empty." env:"INGEST_AMQP_URL"`
+	IngestAMQPAddress string `help:"Address of the AMQP node to receive CloudEvents from, like a queue." env:"INGEST_AMQP_ADDRESS"`
+
+	// Ingest discovery
+	IngestDiscovery bool `help:"Inform the broker metadata and its triggers filters at the root and /triggers paths of the ingest port." env:"INGEST_DISCOVERY" default:"false"`
+
+	// Event integrity
+	EventIntegrity      bool   `help:"Compute a content hash for each ingested event and verify it before delivery." env:"EVENT_INTEGRITY" default:"false"`
+	EventQuarantinePath string `help:"Path to the file where events that fail verifications are stored. Those events are discarded if empty." env:"EVENT_QUARANTINE_PATH"`
+
+	// Event ID generation
+	EventIDStrategy string `help:"Strategy for generating the ID of ingested events that do not inform it: uuid, uuidv7, ksuid or snowflake. Those events are rejected if empty." env:"EVENT_ID_STRATEGY"`
+	EventIDInstance int    `help:"Instance ID from 0 to 1023 for the snowflake event ID strategy, which must be unique for each broker instance sharing the backend." env:"EVENT_ID_INSTANCE" default:"0"`
+
+	// Event expiry
+	EventTTL string `help:"Time to live for events since their time attribute or ingest time, using ISO8601. Expired events are sent to the dead letter sinks instead of delivered. Zero disables expiry unless informed per event." env:"EVENT_TTL" default:"PT0S"`
+
+	// Backend retention
+	RetentionMaxEvents int64  `help:"Maximum number of events retained at the backend, the oldest being discarded beyond it. Zero means unlimited." env:"RETENTION_MAX_EVENTS" default:"0"`
+	RetentionMaxBytes  int64  `help:"Maximum size in bytes of the events retained at the backend, the oldest being discarded beyond it. Zero means unlimited." env:"RETENTION_MAX_BYTES" default:"0"`
+	RetentionMaxAge    string `help:"Maximum time events are retained at the backend since produced using ISO8601, older events being discarded. Zero means unlimited." env:"RETENTION_MAX_AGE" default:"PT0S"`
+
+	// Delivery connections
+	DeliveryMaxIdleConns        int    `help:"Maximum number of idle connections to targets. Zero means unlimited." env:"DELIVERY_MAX_IDLE_CONNS" default:"100"`
+	DeliveryMaxIdleConnsPerHost int    `help:"Maximum number of idle connections kept per target host." env:"DELIVERY_MAX_IDLE_CONNS_PER_HOST" default:"2"`
+	DeliveryMaxConnsPerHost     int    `help:"Maximum number of connections per target host, including those in use. Zero means unlimited." env:"DELIVERY_MAX_CONNS_PER_HOST" default:"0"`
+	DeliveryIdleConnTimeout     string `help:"Time an idle connection to a target is kept using ISO8601." env:"DELIVERY_IDLE_CONN_TIMEOUT" default:"PT90S"`
+	DeliveryKeepAlive           string `help:"TCP keep-alive period for connections to targets using ISO8601." env:"DELIVERY_KEEP_ALIVE" default:"PT30S"`
+	DeliveryDisableKeepAlives   bool   `help:"Use a new connection for each request to targets." env:"DELIVERY_DISABLE_KEEP_ALIVES" default:"false"`
+	DeliveryHTTP2               bool   `help:"Enable HTTP/2 for TLS targets." env:"DELIVERY_HTTP2" default:"true"`
+
+	// Delivery retries
+	DeliveryRetryAfter      bool   `help:"Honor the Retry-After header of 429 and 503 target responses, which overrides the backoff before the next retry." env:"DELIVERY_RETRY_AFTER" default:"false"`
+	DeliveryRetryAfterMax   string `help:"Maximum time to wait as informed by the Retry-After header of target responses using ISO8601, which Triggers can override. Zero means no maximum." env:"DELIVERY_RETRY_AFTER_MAX" default:"PT1M"`
+	DeliveryRetryState      bool   `help:"Keep the retry attempts and next retry time of events at the backend, so that events dispatched again after a restart resume their retry schedule." env:"DELIVERY_RETRY_STATE" default:"false"`
+	DeliveryRequeueMinDelay string `help:"Retry backoffs of at least this time using ISO8601 requeue the event at the backend to be delivered again once due, instead of waiting at the dispatcher. Zero disables requeueing." env:"DELIVERY_REQUEUE_MIN_DELAY" default:"PT0S"`
+
+	// Delivery retry budget
+	DeliveryRetryBudget           float64 `help:"Maximum fraction of the requests sent to targets by all Triggers during the last 10 seconds that can be retries. Events whose retries exceed the budget are sent to the dead letter sinks. Zero means unlimited." env:"DELIVERY_RETRY_BUDGET" default:"0"`
+	DeliveryRetryBudgetMinRetries int     `help:"Number of retries per second allowed regardless of the retry budget." env:"DELIVERY_RETRY_BUDGET_MIN_RETRIES" default:"10"`
+
+	// Delivery concurrency
+	DeliveryMaxInFlight        int `help:"Maximum number of requests in flight to targets by all Triggers. Zero means unlimited." env:"DELIVERY_MAX_IN_FLIGHT" default:"0"`
+	DeliveryMaxInFlightPerHost int `help:"Maximum number of requests in flight to each target host by all Triggers. Zero means unlimited." env:"DELIVERY_MAX_IN_FLIGHT_PER_HOST" default:"0"`
+
+	// Destination resolution
+	DestinationResolver      string `help:"Resolver of the Kubernetes objects Trigger targets reference: dns resolves Services using the cluster DNS naming, and kubernetes also resolves Addressables reading their status from the Kubernetes API." env:"DESTINATION_RESOLVER" default:"dns"`
+	DestinationClusterDomain string `help:"Domain of the Kubernetes cluster used to resolve Services." env:"DESTINATION_CLUSTER_DOMAIN" default:"cluster.local"`
+	DestinationCacheTTL      string `help:"Time the addresses resolved for referenced objects are kept using ISO8601, which are also resolved again when targets cannot be reached. Zero keeps them until then." env:"DESTINATION_CACHE_TTL" default:"PT5M"`
+
+	// Reply batching
+	ReplyBatchSize  int    `help:"Maximum number of target replies produced to the backend at once. Zero or one disables batching." env:"REPLY_BATCH_SIZE" default:"0"`
+	ReplyBatchDelay string `help:"Maximum time a target reply waits for its batch to fill before being produced using ISO8601." env:"REPLY_BATCH_DELAY" default:"PT0.1S"`
+
+	// Trigger filters
+	TriggerStrictFilters bool `help:"Do not activate triggers whose filters fail to compile." env:"TRIGGER_STRICT_FILTERS" default:"false"`
+
+	// Trigger deletion
+	TriggerDeletionGracePeriod string `help:"Time triggers deleted through the admin API can be restored using ISO8601, keeping their backend position and dead letter files. Zero deletes triggers right away." env:"TRIGGER_DELETION_GRACE_PERIOD" default:"PT0S"`
+
+	// Trigger updates
+	TriggerUpdateDrainTimeout string `help:"Time deliveries of triggers whose filters or target changed wait for the deliveries in flight using the former definition using ISO8601, so that they are not interleaved. Zero does not wait." env:"TRIGGER_UPDATE_DRAIN_TIMEOUT" default:"PT30S"`
+
+	// Trigger sharding
+	TriggerShardingLease     string `help:"Time the lease each replica renews at the backend to be assigned a share of the triggers is kept using ISO8601. Zero disables sharding, every replica dispatching all triggers." env:"TRIGGER_SHARDING_LEASE" default:"PT0S"`
+	TriggerShardingReplica   string `help:"Name of the replica when sharding triggers, which must be unique per replica." env:"TRIGGER_SHARDING_REPLICA" default:"${hostname}"`
+	TriggerShardingExclusive bool   `help:"Dispatch the triggers assigned to the replica only while it holds their lease at the backend, so that reassigned triggers are never dispatched by two replicas at once." env:"TRIGGER_SHARDING_EXCLUSIVE" default:"false"`
+
+	// Leader election
+	LeaderElectionLease   string `help:"Time the leader lease that elects the single replica dispatching all triggers is kept at the backend using ISO8601. Zero disables leader election, every replica dispatching all triggers." env:"LEADER_ELECTION_LEASE" default:"PT0S"`
+	LeaderElectionReplica string `help:"Name of the replica in the leader election, which must be unique per replica." env:"LEADER_ELECTION_REPLICA" default:"${hostname}"`
+
+	// Per event debugging
+	EventDebug bool `help:"Honor the debug extension of events, which forces tracing, verbose logging and auditing of their delivery." env:"EVENT_DEBUG" default:"false"`
+
+	// Event provenance
+	EventProvenance          bool `help:"Append the broker name to the provenance chain extension of ingested events." env:"EVENT_PROVENANCE" default:"false"`
+	EventProvenanceMaxLength int  `help:"Maximum number of broker names kept at the provenance chain, dropping the oldest ones. Zero means unlimited." env:"EVENT_PROVENANCE_MAX_LENGTH" default:"10"`
+
+	// Event hops
+	EventMaxHops int `help:"Number of hops of the events that do not inform the knativebrokerttl extension, which decrements each time a reply is forwarded. Replies without hops remaining are sent to the dead letter sinks. Zero means 255." env:"EVENT_MAX_HOPS" default:"255"`
+
+	// Encryption at rest
+	EventEncryptionKey string `help:"Source of the key for encrypting events stored at the backend: a file path prefixed with file:// or an environment variable prefixed with env:// containing base64 encoded AES-256 keys, or a HashiCorp Vault transit key URL prefixed with vault+https://. Disabled if empty." env:"EVENT_ENCRYPTION_KEY"`
+
+	// Delivery audit
+	AuditSink string `help:"Destination for delivery audit records: stdout, a file path prefixed with file://, or an HTTP URL that receives records as CloudEvents. Disabled if empty." env:"AUDIT_SINK"`
+
+	// Trigger status reporting
+	StatusSink   string `help:"Destination for trigger status documents: a file path prefixed with file://, or secret to annotate the Kubernetes broker configuration Secret. Disabled if empty." env:"STATUS_SINK"`
+	StatusPeriod string `help:"Period for writing trigger status documents using ISO8601." env:"STATUS_PERIOD" default:"PT30S"`
+
+	// Throughput history
+	ThroughputRetention string `help:"Time hourly counters of ingested and dispatched events are kept at the backend using ISO8601. Zero disables throughput history." env:"THROUGHPUT_RETENTION" default:"PT0S"`
+
+	// SLA reports
+	SLAReportPeriod string `help:"Period for emitting per trigger SLA reports using ISO8601, like P1D for daily or P7D for weekly reports. Zero disables SLA reports." env:"SLA_REPORT_PERIOD" default:"PT0S"`
+	SLAReportSink   string `help:"Destination for SLA reports: an HTTP URL that receives reports as CloudEvents, or an s3:// or gs:// bucket URI with an optional prefix path." env:"SLA_REPORT_SINK"`
+
+	// Autoscaling metrics
+	ScalingMetricsPeriod string `help:"Period for reading the backlog of the broker and its triggers from the backend using ISO8601, which is exposed for autoscalers. Zero disables autoscaling metrics." env:"SCALING_METRICS_PERIOD" default:"PT0S"`
+
+	// Event archive
+	EventArchiveRetention string `help:"Time ingested events are archived at the backend using ISO8601, which can be queried and re-injected through the admin API. Zero disables the archive." env:"EVENT_ARCHIVE_RETENTION" default:"PT0S"`
+
+	// Admin API
+	AdminPort  int    `help:"HTTP Port for the admin API. Zero disables the admin API." env:"ADMIN_PORT" default:"0"`
+	AdminToken string `help:"Bearer token that requests to the admin API must inform." env:"ADMIN_TOKEN"`
+	AdminUI    bool   `help:"Serve the web UI from the admin port." env:"ADMIN_UI" default:"false"`
+
+	// CloudEvents Subscription API
+	AdminSubscriptions         bool   `help:"Serve the CloudEvents Subscription API from the admin port, creating a Trigger for each subscription." env:"ADMIN_SUBSCRIPTIONS" default:"false"`
+	AdminSubscriptionsDelivery string `help:"JSON representation of the delivery options of the Triggers created for subscriptions." env:"ADMIN_SUBSCRIPTIONS_DELIVERY"`
+
+	// Graceful shutdown
+	ShutdownGracePeriod string `help:"Maximum time to wait for in-flight deliveries when shutting down using ISO8601." env:"SHUTDOWN_GRACE_PERIOD" default:"PT20S"`
+
+	// Logging destinations
+	LogEncoding       string `help:"Encoding of log entries, json or console. Overrides the observability configuration if informed." env:"LOG_ENCODING"`
+	LogOutput         string `help:"Destination for log entries: stdout, stderr, a file path prefixed with file://, or a syslog:// URL. Overrides the observability configuration if informed." env:"LOG_OUTPUT"`
+	LogFileMaxSize    int    `help:"Size in megabytes at which log files are rotated. Zero disables rotation." env:"LOG_FILE_MAX_SIZE" default:"100"`
+	LogFileMaxBackups int    `help:"Number of rotated log files that are kept." env:"LOG_FILE_MAX_BACKUPS" default:"3"`
+
+	Context                            context.Context    `kong:"-"`
+	Logger                             *zap.SugaredLogger `kong:"-"`
+	LogLevel                           zap.AtomicLevel    `kong:"-"`
+	PollingPeriod                      time.Duration      `kong:"-"`
+	ConfigMethod                       ConfigMethod       `kong:"-"`
+	IngestRetryAfterDuration           time.Duration      `kong:"-"`
+	IngestDeduplicationTTLDuration     time.Duration      `kong:"-"`
+	IngestSyncTimeoutDuration          time.Duration      `kong:"-"`
+	EventTTLDuration                   time.Duration      `kong:"-"`
+	RetentionMaxAgeDuration            time.Duration      `kong:"-"`
+	DeliveryIdleConnTimeoutDuration    time.Duration      `kong:"-"`
+	DeliveryKeepAliveDuration          time.Duration      `kong:"-"`
+	DeliveryRetryAfterMaxDuration      time.Duration      `kong:"-"`
+	DestinationCacheTTLDuration        time.Duration      `kong:"-"`
+	StatusPeriodDuration               time.Duration      `kong:"-"`
+	ThroughputRetentionDuration        time.Duration      `kong:"-"`
+	SLAReportPeriodDuration            time.Duration      `kong:"-"`
+	ScalingMetricsPeriodDuration       time.Duration      `kong:"-"`
+	EventArchiveRetentionDuration      time.Duration      `kong:"-"`
+	TriggerDeletionGracePeriodDuration time.Duration      `kong:"-"`
+	TriggerShardingLeaseDuration       time.Duration      `kong:"-"`
+	TriggerUpdateDrainTimeoutDuration  time.Duration      `kong:"-"`
+	LeaderElectionLeaseDuration        time.Duration      `kong:"-"`
+	DeliveryRequeueMinDelayDuration    time.Duration      `kong:"-"`
+	ShutdownGracePeriodDuration        time.Duration      `kong:"-"`
+	ReplyBatchDelayDuration            time.Duration      `kong:"-"`
+	LogOutputPath                      string             `kong:"-"`
+
+	// Parsed from their JSON representation.
+	AdminSubscriptionsDeliveryOptions *cfgbroker.DeliveryOptions `kong:"-"`
 }
 
 func (s *Globals) Validate() error {
@@ -94,6 +279,386 @@ func (s *Globals) Validate() error {
 		}
 	}
 
+	if s.IngestMaxInFlight < 0 {
+		msg = append(msg, "Ingest max in flight events must not be negative.")
+	}
+
+	if s.IngestMaxEventSize < 0 {
+		msg = append(msg, "Ingest max event size must not be negative.")
+	}
+
+	if s.IngestRateLimit < 0 || s.IngestRateBurst < 0 {
+		msg = append(msg, "Ingest rate limit and burst must not be negative.")
+	}
+
+	if s.IngestMQTTPort < 0 {
+		msg = append(msg, "Ingest MQTT port must not be negative.")
+	}
+
+	if s.IngestAMQPURL != "" {
+		if u, err := url.Parse(s.IngestAMQPURL); err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+			msg = append(msg, "Ingest AMQP URL must be an amqp or amqps URL.")
+		}
+		if s.IngestAMQPAddress == "" {
+			msg = append(msg, "Ingest AMQP address must be informed along with the AMQP URL.")
+		}
+	}
+
+	if s.AdminPort != 0 && s.AdminToken == "" {
+		msg = append(msg, "Admin token must be informed when the admin API is enabled.")
+	}
+
+	if s.AdminSubscriptionsDelivery != "" {
+		d := &cfgbroker.DeliveryOptions{}
+		if err := json.Unmarshal([]byte(s.AdminSubscriptionsDelivery), d); err != nil {
+			msg = append(msg, fmt.Sprintf("Admin subscriptions delivery options could not be parsed: %v", err))
+		} else if err := d.Validate(context.Background()); err != nil {
+			msg = append(msg, fmt.Sprintf("Admin subscriptions delivery options are not valid: %v", err))
+		} else {
+			s.AdminSubscriptionsDeliveryOptions = d
+		}
+	}
+
+	switch ingest.ConformanceMode(s.IngestConformance) {
+	case "", ingest.ConformanceModeStrict, ingest.ConformanceModeLenient, ingest.ConformanceModeRepair:
+	default:
+		msg = append(msg, "Ingest conformance must be strict, lenient or repair.")
+	}
+
+	switch s.DestinationResolver {
+	case "", "dns", "kubernetes":
+	default:
+		msg = append(msg, "Destination resolver must be dns or kubernetes.")
+	}
+
+	if s.DestinationCacheTTL != "" {
+		p, err := period.Parse(s.DestinationCacheTTL)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Destination cache TTL is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Destination cache TTL cannot be negative.")
+		default:
+			s.DestinationCacheTTLDuration = p.DurationApprox()
+		}
+	}
+
+	switch ingest.SyncMode(s.IngestSyncMode) {
+	case "", ingest.SyncModePersisted, ingest.SyncModeDispatched:
+	default:
+		msg = append(msg, "Ingest sync mode must be persisted or dispatched.")
+	}
+
+	if s.IngestSyncTimeout != "" {
+		p, err := period.Parse(s.IngestSyncTimeout)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Ingest sync timeout is not an ISO8601 duration: %v", err))
+		} else {
+			s.IngestSyncTimeoutDuration = p.DurationApprox()
+		}
+	}
+
+	if s.IngestRetryAfter != "" {
+		p, err := period.Parse(s.IngestRetryAfter)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Ingest retry after is not an ISO8601 duration: %v", err))
+		} else {
+			s.IngestRetryAfterDuration = p.DurationApprox()
+		}
+	}
+
+	if s.IngestDeduplicationTTL != "" {
+		p, err := period.Parse(s.IngestDeduplicationTTL)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Ingest deduplication TTL is not an ISO8601 duration: %v", err))
+		} else {
+			s.IngestDeduplicationTTLDuration = p.DurationApprox()
+		}
+	}
+
+	if s.EventTTL != "" {
+		p, err := period.Parse(s.EventTTL)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Event TTL is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Event TTL must not be negative.")
+		default:
+			s.EventTTLDuration = p.DurationApprox()
+		}
+	}
+
+	if s.RetentionMaxEvents < 0 {
+		msg = append(msg, "Retention maximum events must not be negative.")
+	}
+	if s.RetentionMaxBytes < 0 {
+		msg = append(msg, "Retention maximum bytes must not be negative.")
+	}
+	if s.RetentionMaxAge != "" {
+		p, err := period.Parse(s.RetentionMaxAge)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Retention maximum age is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Retention maximum age must not be negative.")
+		default:
+			s.RetentionMaxAgeDuration = p.DurationApprox()
+		}
+	}
+
+	if s.EventIDStrategy != "" {
+		if _, err := eventid.New(eventid.Strategy(s.EventIDStrategy), s.EventIDInstance); err != nil {
+			msg = append(msg, fmt.Sprintf("Event ID generation is not valid: %v.", err))
+		}
+	}
+
+	if s.DeliveryMaxIdleConns < 0 || s.DeliveryMaxIdleConnsPerHost < 0 || s.DeliveryMaxConnsPerHost < 0 {
+		msg = append(msg, "Delivery connection limits must not be negative.")
+	}
+
+	if s.DeliveryIdleConnTimeout != "" {
+		p, err := period.Parse(s.DeliveryIdleConnTimeout)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Delivery idle connection timeout is not an ISO8601 duration: %v", err))
+		} else {
+			s.DeliveryIdleConnTimeoutDuration = p.DurationApprox()
+		}
+	}
+
+	if s.DeliveryKeepAlive != "" {
+		p, err := period.Parse(s.DeliveryKeepAlive)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Delivery keep-alive period is not an ISO8601 duration: %v", err))
+		} else {
+			s.DeliveryKeepAliveDuration = p.DurationApprox()
+		}
+	}
+
+	if s.DeliveryRetryAfterMax != "" {
+		p, err := period.Parse(s.DeliveryRetryAfterMax)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Delivery retry after max is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Delivery retry after max cannot be negative.")
+		default:
+			s.DeliveryRetryAfterMaxDuration = p.DurationApprox()
+		}
+	}
+
+	if s.DeliveryRequeueMinDelay != "" {
+		p, err := period.Parse(s.DeliveryRequeueMinDelay)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Delivery requeue min delay is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Delivery requeue min delay must not be negative.")
+		default:
+			s.DeliveryRequeueMinDelayDuration = p.DurationApprox()
+		}
+	}
+
+	if s.DeliveryRetryBudget < 0 || s.DeliveryRetryBudget >= 1 {
+		msg = append(msg, "Delivery retry budget must be a fraction from 0 to less than 1.")
+	}
+	if s.DeliveryRetryBudgetMinRetries < 0 {
+		msg = append(msg, "Delivery retry budget min retries must not be negative.")
+	}
+
+	if s.DeliveryMaxInFlight < 0 {
+		msg = append(msg, "Delivery max in flight must not be negative.")
+	}
+	if s.DeliveryMaxInFlightPerHost < 0 {
+		msg = append(msg, "Delivery max in flight per host must not be negative.")
+	}
+
+	if s.ThroughputRetention != "" {
+		p, err := period.Parse(s.ThroughputRetention)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Throughput retention is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Throughput retention must not be negative.")
+		default:
+			s.ThroughputRetentionDuration = p.DurationApprox()
+		}
+	}
+
+	if s.SLAReportPeriod != "" {
+		p, err := period.Parse(s.SLAReportPeriod)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("SLA report period is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "SLA report period must not be negative.")
+		default:
+			s.SLAReportPeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if s.SLAReportPeriodDuration > 0 && s.SLAReportSink == "" {
+		msg = append(msg, "SLA report sink must be informed when SLA reports are enabled.")
+	}
+
+	if s.ScalingMetricsPeriod != "" {
+		p, err := period.Parse(s.ScalingMetricsPeriod)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Scaling metrics period is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Scaling metrics period must not be negative.")
+		default:
+			s.ScalingMetricsPeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if s.EventArchiveRetention != "" {
+		p, err := period.Parse(s.EventArchiveRetention)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Event archive retention is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Event archive retention must not be negative.")
+		default:
+			s.EventArchiveRetentionDuration = p.DurationApprox()
+		}
+	}
+
+	if s.TriggerDeletionGracePeriod != "" {
+		p, err := period.Parse(s.TriggerDeletionGracePeriod)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Trigger deletion grace period is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Trigger deletion grace period must not be negative.")
+		default:
+			s.TriggerDeletionGracePeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if s.TriggerUpdateDrainTimeout != "" {
+		p, err := period.Parse(s.TriggerUpdateDrainTimeout)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Trigger update drain timeout is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Trigger update drain timeout must not be negative.")
+		default:
+			s.TriggerUpdateDrainTimeoutDuration = p.DurationApprox()
+		}
+	}
+
+	if s.TriggerShardingLease != "" {
+		p, err := period.Parse(s.TriggerShardingLease)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Trigger sharding lease is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Trigger sharding lease must not be negative.")
+		case p.DurationApprox() > 0 && s.TriggerShardingReplica == "":
+			msg = append(msg, "Trigger sharding replica must be informed when sharding is enabled.")
+		default:
+			s.TriggerShardingLeaseDuration = p.DurationApprox()
+		}
+	}
+
+	if s.TriggerShardingExclusive && s.TriggerShardingLeaseDuration == 0 {
+		msg = append(msg, "Exclusive trigger sharding requires the trigger sharding lease.")
+	}
+
+	if s.LeaderElectionLease != "" {
+		p, err := period.Parse(s.LeaderElectionLease)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Leader election lease is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Leader election lease must not be negative.")
+		case p.DurationApprox() > 0 && s.LeaderElectionReplica == "":
+			msg = append(msg, "Leader election replica must be informed when leader election is enabled.")
+		case p.DurationApprox() > 0 && s.TriggerShardingLeaseDuration > 0:
+			msg = append(msg, "Leader election and trigger sharding cannot be enabled at once.")
+		default:
+			s.LeaderElectionLeaseDuration = p.DurationApprox()
+		}
+	}
+
+	if s.ShutdownGracePeriod != "" {
+		p, err := period.Parse(s.ShutdownGracePeriod)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Shutdown grace period is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() < 0:
+			msg = append(msg, "Shutdown grace period must not be negative.")
+		default:
+			s.ShutdownGracePeriodDuration = p.DurationApprox()
+		}
+	}
+
+	if s.ReplyBatchSize < 0 {
+		msg = append(msg, "Reply batch size must not be negative.")
+	}
+
+	if s.ReplyBatchDelay != "" {
+		p, err := period.Parse(s.ReplyBatchDelay)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Reply batch delay is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() <= 0 && s.ReplyBatchSize > 1:
+			msg = append(msg, "Reply batch delay must be greater than zero when batching replies.")
+		default:
+			s.ReplyBatchDelayDuration = p.DurationApprox()
+		}
+	}
+
+	if s.EventProvenanceMaxLength < 0 {
+		msg = append(msg, "Event provenance max length must not be negative.")
+	}
+
+	if s.EventMaxHops < 0 || s.EventMaxHops > math.MaxInt32 {
+		msg = append(msg, "Event max hops must be between 0 and 2147483647.")
+	}
+
+	if s.LogEncoding != "" && s.LogEncoding != "json" && s.LogEncoding != "console" {
+		msg = append(msg, "Log encoding must be json or console.")
+	}
+
+	if s.LogFileMaxSize < 0 || s.LogFileMaxBackups < 0 {
+		msg = append(msg, "Log file max size and backups must not be negative.")
+	}
+
+	if s.LogOutput != "" {
+		p, err := logging.OutputPath(s.LogOutput, s.LogFileMaxSize, s.LogFileMaxBackups)
+		if err != nil {
+			msg = append(msg, fmt.Sprintf("Log output is not valid: %v.", err))
+		} else {
+			s.LogOutputPath = p
+		}
+	}
+
+	if s.EventEncryptionKey != "" &&
+		!strings.HasPrefix(s.EventEncryptionKey, "file://") &&
+		!strings.HasPrefix(s.EventEncryptionKey, "env://") &&
+		!strings.HasPrefix(s.EventEncryptionKey, "vault+https://") &&
+		!strings.HasPrefix(s.EventEncryptionKey, "vault+http://") {
+		msg = append(msg, "Event encryption key must be prefixed with file://, env://, vault+https:// or vault+http://.")
+	}
+
+	if s.StatusSink != "" {
+		p, err := period.Parse(s.StatusPeriod)
+		switch {
+		case err != nil:
+			msg = append(msg, fmt.Sprintf("Status period is not an ISO8601 duration: %v", err))
+		case p.DurationApprox() <= 0:
+			msg = append(msg, "Status period must be greater than zero.")
+		default:
+			s.StatusPeriodDuration = p.DurationApprox()
+		}
+
+		if s.StatusSink == "secret" && (s.KubernetesBrokerConfigSecretName == "" || s.KubernetesBrokerConfigSecretKey == "") {
+			msg = append(msg, "Status sink secret can only be used along with the Kubernetes Secret configuration.")
+		}
+	}
+
 	// Broker config must be configured
 	if s.BrokerConfigPath == "" &&
 		(s.KubernetesBrokerConfigSecretName == "" || s.KubernetesBrokerConfigSecretKey == "") &&
@@ -166,6 +731,11 @@ func (s *Globals) Validate() error {
 		msg = append(msg, "Either Kubernetes Secret or local file configuration must be informed.")
 	}
 
+	if len(s.BrokerConfigOverlays) != 0 &&
+		s.ConfigMethod != ConfigMethodFileWatcher && s.ConfigMethod != ConfigMethodFilePoller {
+		msg = append(msg, "Broker configuration overlays can only be used along with local file configuration.")
+	}
+
 	if len(msg) != 0 {
 		s.ConfigMethod = ConfigMethodUnknown
 		return fmt.Errorf(strings.Join(msg, " "))
@@ -243,8 +813,12 @@ func (s *Globals) Initialize() error {
 		cfg = observability.DefaultConfig()
 	}
 
+	if err := logging.RegisterSinks(); err != nil {
+		return fmt.Errorf("could not register log destinations: %w", err)
+	}
+
 	// Call build to perform validation of zap configuration.
-	l, err = cfg.LoggerCfg.Build()
+	l, err = s.buildLogger(cfg.LoggerCfg)
 	for {
 		if err == nil {
 			break
@@ -255,7 +829,7 @@ func (s *Globals) Initialize() error {
 
 		defaultConfigApplied = true
 		cfg = observability.DefaultConfig()
-		l, err = cfg.LoggerCfg.Build()
+		l, err = s.buildLogger(cfg.LoggerCfg)
 	}
 
 	s.LogLevel = cfg.LoggerCfg.Level
@@ -271,6 +845,19 @@ func (s *Globals) Initialize() error {
 	return nil
 }
 
+// buildLogger builds the logger overriding the encoding and destination of
+// the configuration with the ones informed as parameters.
+func (s *Globals) buildLogger(cfg *zap.Config) (*zap.Logger, error) {
+	if s.LogEncoding != "" {
+		cfg.Encoding = s.LogEncoding
+	}
+	if s.LogOutputPath != "" {
+		cfg.OutputPaths = []string{s.LogOutputPath}
+	}
+
+	return cfg.Build()
+}
+
 func (s *Globals) Flush() {
 	if s.Logger != nil {
 		_ = s.Logger.Sync()
@@ -77,6 +77,29 @@ func TestGlobalsValidation(t *testing.T) {
 			expectedErr:          "Cannot use Broker file for configuration when a Kubernetes Secret is used for the broker.",
 			expectedConfigMethod: ConfigMethodUnknown,
 		},
+		"file watcher with overlays": {
+			globals: Globals{
+				BrokerConfigPath:     brokerConfigPath,
+				BrokerConfigOverlays: []string{"/etc/triggermesh/triggers.d"},
+			},
+			expectedConfigMethod: ConfigMethodFileWatcher,
+		},
+		"inline configuration with overlays": {
+			globals: Globals{
+				BrokerConfig:         `{"yada":"yada"}`,
+				BrokerConfigOverlays: []string{"/etc/triggermesh/triggers.d"},
+			},
+			expectedErr:          "Broker configuration overlays can only be used along with local file configuration.",
+			expectedConfigMethod: ConfigMethodUnknown,
+		},
+		"negative retention": {
+			globals: Globals{
+				BrokerConfigPath:   brokerConfigPath,
+				RetentionMaxEvents: -1,
+			},
+			expectedErr:          "Retention maximum events must not be negative.",
+			expectedConfigMethod: ConfigMethodUnknown,
+		},
 		"mixed poller and environment": {
 			globals: Globals{
 				BrokerConfigPath:    brokerConfigPath,
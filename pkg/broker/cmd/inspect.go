@@ -0,0 +1,241 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/registry"
+	"github.com/triggermesh/brokers/pkg/common/encryption"
+)
+
+// BackendConnector connects to the backend the broker is configured with,
+// for commands that inspect or change its state apart from the broker.
+type BackendConnector func(globals *Globals) (backend.Interface, error)
+
+// NewBackendConnector returns a connector for the backend created with the
+// arguments. Events are decrypted when the broker encrypts them.
+func NewBackendConnector(d registry.Definition, args registry.Args) BackendConnector {
+	return func(globals *Globals) (backend.Interface, error) {
+		b := d.New(args, "", registry.Options{
+			BrokerName: globals.BrokerName,
+			Logger:     globals.Logger,
+		})
+
+		if globals.EventEncryptionKey != "" {
+			if ee, ok := b.(backend.EventEncrypter); ok {
+				c, err := encryption.New(globals.EventEncryptionKey)
+				if err != nil {
+					return nil, fmt.Errorf("could not setup event encryption: %w", err)
+				}
+				ee.SetCipher(c)
+			}
+		}
+
+		if err := b.Init(globals.Context); err != nil {
+			return nil, fmt.Errorf("could not connect to the backend: %w", err)
+		}
+		return b, nil
+	}
+}
+
+func connectInspector(globals *Globals, connect BackendConnector) (backend.SubscriptionInspector, error) {
+	b, err := connect(globals)
+	if err != nil {
+		return nil, err
+	}
+
+	si, ok := b.(backend.SubscriptionInspector)
+	if !ok {
+		return nil, fmt.Errorf("backend %s does not support inspection", b.Info().Name)
+	}
+	return si, nil
+}
+
+// InspectCmd reports the state of the backend.
+type InspectCmd struct {
+	Backlog InspectBacklogCmd `cmd:"" help:"Reports the events pending to be dispatched to each trigger."`
+	Pending InspectPendingCmd `cmd:"" help:"Lists the events dispatched to a trigger that were not acknowledged."`
+}
+
+// InspectBacklogCmd reports the events pending for each trigger.
+type InspectBacklogCmd struct {
+	Output string `help:"Format of the report: text or json." enum:"text,json" default:"text"`
+}
+
+func (c *InspectBacklogCmd) Run(globals *Globals, connect BackendConnector) error {
+	si, err := connectInspector(globals, connect)
+	if err != nil {
+		return err
+	}
+	return inspectBacklog(globals.Context, os.Stdout, si, c.Output)
+}
+
+type backlogEntry struct {
+	Trigger   string `json:"trigger"`
+	Lag       int64  `json:"lag"`
+	Pending   int64  `json:"pending"`
+	Consumers int64  `json:"consumers"`
+}
+
+func inspectBacklog(ctx context.Context, w io.Writer, si backend.SubscriptionInspector, format string) error {
+	states, err := si.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+
+	entries := make([]backlogEntry, 0, len(states))
+	for _, st := range states {
+		entries = append(entries, backlogEntry{
+			Trigger:   st.Name,
+			Lag:       st.Lag,
+			Pending:   st.Pending,
+			Consumers: st.Consumers,
+		})
+	}
+
+	if format == "json" {
+		return json.NewEncoder(w).Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRIGGER\tLAG\tPENDING\tCONSUMERS")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", e.Trigger, e.Lag, e.Pending, e.Consumers)
+	}
+	return tw.Flush()
+}
+
+// InspectPendingCmd lists the events not acknowledged for a trigger.
+type InspectPendingCmd struct {
+	Trigger string `help:"Name of the trigger." required:""`
+	Limit   int    `help:"Maximum number of events listed." default:"20"`
+	Output  string `help:"Format of the report: text or json." enum:"text,json" default:"text"`
+}
+
+func (c *InspectPendingCmd) Validate() error {
+	if c.Limit <= 0 {
+		return errors.New("Limit must be greater than zero.")
+	}
+	return nil
+}
+
+func (c *InspectPendingCmd) Run(globals *Globals, connect BackendConnector) error {
+	si, err := connectInspector(globals, connect)
+	if err != nil {
+		return err
+	}
+	return inspectPending(globals.Context, os.Stdout, si, c.Trigger, c.Limit, c.Output)
+}
+
+type pendingEntry struct {
+	BackendID  string `json:"backendID"`
+	Consumer   string `json:"consumer"`
+	Idle       string `json:"idle"`
+	Deliveries int64  `json:"deliveries"`
+	ID         string `json:"id,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Type       string `json:"type,omitempty"`
+}
+
+func inspectPending(ctx context.Context, w io.Writer, si backend.SubscriptionInspector, trigger string, limit int, format string) error {
+	pending, err := si.PendingEvents(ctx, trigger, limit)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]pendingEntry, 0, len(pending))
+	for _, p := range pending {
+		e := pendingEntry{
+			BackendID:  p.BackendID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle.Truncate(time.Second).String(),
+			Deliveries: p.Deliveries,
+		}
+		if p.Event != nil {
+			e.ID, e.Source, e.Type = p.Event.ID(), p.Event.Source(), p.Event.Type()
+		}
+		entries = append(entries, e)
+	}
+
+	if format == "json" {
+		return json.NewEncoder(w).Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKEND ID\tCONSUMER\tIDLE\tDELIVERIES\tID\tSOURCE\tTYPE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			e.BackendID, e.Consumer, e.Idle, e.Deliveries, orNone(e.ID), orNone(e.Source), orNone(e.Type))
+	}
+	return tw.Flush()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// DrainCmd discards the events pending for a trigger.
+type DrainCmd struct {
+	Trigger string `help:"Name of the trigger." required:""`
+	Yes     bool   `help:"Do not ask for confirmation." short:"y"`
+}
+
+func (c *DrainCmd) Run(globals *Globals, connect BackendConnector) error {
+	si, err := connectInspector(globals, connect)
+	if err != nil {
+		return err
+	}
+	return drain(globals.Context, os.Stdin, os.Stdout, si, c.Trigger, c.Yes)
+}
+
+func drain(ctx context.Context, r io.Reader, w io.Writer, si backend.SubscriptionInspector, trigger string, yes bool) error {
+	if !yes {
+		states, err := si.Subscriptions(ctx)
+		if err != nil {
+			return err
+		}
+
+		var state *backend.SubscriptionState
+		for i := range states {
+			if states[i].Name == trigger {
+				state = &states[i]
+			}
+		}
+		if state == nil {
+			return fmt.Errorf("trigger %q does not exist at the backend", trigger)
+		}
+
+		fmt.Fprintf(w, "Discard %d events not dispatched and %d not acknowledged for trigger %q? [y/N] ",
+			state.Lag, state.Pending, trigger)
+		answer, _ := bufio.NewReader(r).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Fprintln(w, "Drain cancelled.")
+			return nil
+		}
+	}
+
+	n, err := si.DrainSubscription(ctx, trigger)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Discarded %d events for trigger %q.\n", n, trigger)
+	return err
+}
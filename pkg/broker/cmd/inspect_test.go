@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fakeInspector struct {
+	states  []backend.SubscriptionState
+	pending []backend.PendingEvent
+	drained []string
+}
+
+func (f *fakeInspector) Subscriptions(context.Context) ([]backend.SubscriptionState, error) {
+	return f.states, nil
+}
+
+func (f *fakeInspector) PendingEvents(_ context.Context, _ string, n int) ([]backend.PendingEvent, error) {
+	if n < len(f.pending) {
+		return f.pending[:n], nil
+	}
+	return f.pending, nil
+}
+
+func (f *fakeInspector) DrainSubscription(_ context.Context, name string) (int64, error) {
+	f.drained = append(f.drained, name)
+	return 7, nil
+}
+
+func TestInspect(t *testing.T) {
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("e1"))
+	si := &fakeInspector{
+		states: []backend.SubscriptionState{
+			{Name: "trigger2", Lag: 3, Pending: 1, Consumers: 1},
+			{Name: "trigger1", Lag: 0, Pending: 2, Consumers: 2},
+		},
+		pending: []backend.PendingEvent{
+			{BackendID: "1-0", Consumer: "replica1", Idle: 90500 * time.Millisecond, Deliveries: 2, Event: &ev},
+			{BackendID: "2-0", Consumer: "replica2", Idle: time.Second, Deliveries: 1},
+		},
+	}
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	require.NoError(t, inspectBacklog(ctx, &out, si, "text"))
+	assert.Equal(t, ""+
+		"TRIGGER   LAG  PENDING  CONSUMERS\n"+
+		"trigger1  0    2        2\n"+
+		"trigger2  3    1        1\n", out.String())
+
+	out.Reset()
+	require.NoError(t, inspectBacklog(ctx, &out, si, "json"))
+	assert.JSONEq(t, `[
+		{"trigger":"trigger1","lag":0,"pending":2,"consumers":2},
+		{"trigger":"trigger2","lag":3,"pending":1,"consumers":1}
+	]`, out.String())
+
+	out.Reset()
+	require.NoError(t, inspectPending(ctx, &out, si, "trigger1", 10, "text"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"1-0", "replica1", "1m30s", "2", "e1", ev.Source(), ev.Type()}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"2-0", "replica2", "1s", "1", "<none>", "<none>", "<none>"}, strings.Fields(lines[2]))
+
+	out.Reset()
+	require.NoError(t, inspectPending(ctx, &out, si, "trigger1", 1, "json"))
+	assert.JSONEq(t, `[{"backendID":"1-0","consumer":"replica1","idle":"1m30s","deliveries":2,
+		"id":"e1","source":"`+ev.Source()+`","type":"`+ev.Type()+`"}]`, out.String())
+}
+
+func TestDrain(t *testing.T) {
+	ctx := context.Background()
+	si := &fakeInspector{
+		states: []backend.SubscriptionState{{Name: "trigger1", Lag: 3, Pending: 1}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, drain(ctx, strings.NewReader("n\n"), &out, si, "trigger1", false))
+	assert.Empty(t, si.drained, "Drain must not happen unless confirmed")
+	assert.Contains(t, out.String(), "Discard 3 events not dispatched and 1 not acknowledged")
+
+	out.Reset()
+	require.NoError(t, drain(ctx, strings.NewReader("y\n"), &out, si, "trigger1", false))
+	assert.Equal(t, []string{"trigger1"}, si.drained)
+	assert.Contains(t, out.String(), `Discarded 7 events for trigger "trigger1".`)
+
+	out.Reset()
+	require.NoError(t, drain(ctx, strings.NewReader(""), &out, si, "trigger2", true))
+	assert.Equal(t, []string{"trigger1", "trigger2"}, si.drained, "Confirmation is skipped")
+
+	assert.Error(t, drain(ctx, strings.NewReader("y\n"), &out, si, "trigger3", false),
+		"Triggers that do not exist must not be drained")
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+// ValidateCmd fully validates a broker configuration without starting the
+// broker, using the same checks as the strict configuration mode.
+type ValidateCmd struct {
+	Path   string `arg:"" optional:"" type:"path" help:"Path to the broker configuration file. Defaults to the inline broker configuration if informed, or the broker configuration path."`
+	Output string `help:"Format for reporting errors: text or json." enum:"text,json" default:"text"`
+}
+
+func (c *ValidateCmd) Run(globals *Globals) error {
+	filename, content := c.Path, ""
+	switch {
+	case filename != "":
+	case globals.BrokerConfig != "":
+		content = globals.BrokerConfig
+	default:
+		filename = globals.BrokerConfigPath
+	}
+
+	if filename != "" {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("could not read broker configuration: %w", err)
+		}
+		content = string(b)
+	}
+
+	errs := ValidateConfig(globals.Context, filename, content)
+	if err := writeValidationErrors(os.Stdout, c.Output, errs); err != nil {
+		return err
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("broker configuration is not valid: %d errors found", len(errs))
+	}
+	return nil
+}
+
+// ValidateConfig parses and fully validates the broker configuration
+// content, returning the errors found.
+func ValidateConfig(ctx context.Context, filename, content string) []cfgbroker.ValidationError {
+	cfg, err := cfgbroker.ParseFile(filename, content)
+	if err != nil {
+		return cfgbroker.ValidationErrors(err)
+	}
+
+	if err := subscriptions.ValidateConfig(ctx, cfg); err != nil {
+		return cfgbroker.ValidationErrors(err)
+	}
+	return nil
+}
+
+func writeValidationErrors(w io.Writer, format string, errs []cfgbroker.ValidationError) error {
+	if format == "json" {
+		if errs == nil {
+			errs = []cfgbroker.ValidationError{}
+		}
+		return json.NewEncoder(w).Encode(errs)
+	}
+
+	if len(errs) == 0 {
+		_, err := fmt.Fprintln(w, "Broker configuration is valid.")
+		return err
+	}
+
+	for _, e := range errs {
+		line := e.Message
+		if e.Field != "" {
+			line = e.Field + ": " + line
+		}
+		if e.Details != "" {
+			line += " (" + e.Details + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		text   string
+		json   string
+	}{
+		"valid": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - cesql: "type = 'test.type'"
+    target:
+      url: http://target
+`,
+			text: "Broker configuration is valid.\n",
+			json: "[]\n",
+		},
+		"not parseable": {
+			config: `triggers: [`,
+			text:   "error converting YAML to JSON: yaml: line 1: did not find expected node content\n",
+			json:   `[{"message":"error converting YAML to JSON: yaml: line 1: did not find expected node content"}]` + "\n",
+		},
+		"filter not compiling": {
+			config: `
+triggers:
+  trigger1:
+    filters:
+    - cesql: "type = "
+    target:
+      url: http://target
+      deliveryOptions:
+        backoffDelay: 1s
+`,
+			text: "triggers[trigger1].filters[0]: Filter cannot be compiled (error while parsing expression type = : interface conversion: interface is nil, not v2.Expression)\n" +
+				"triggers[trigger1].target.deliveryOptions.backoffDelay: Backoff delay is not an ISO8601 duration (1s: expected 'P' period mark at the start)\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			errs := ValidateConfig(context.Background(), "", tc.config)
+
+			var text bytes.Buffer
+			require.NoError(t, writeValidationErrors(&text, "text", errs))
+			assert.Equal(t, tc.text, text.String())
+
+			if tc.json != "" {
+				var json bytes.Buffer
+				require.NoError(t, writeValidationErrors(&json, "json", errs))
+				assert.Equal(t, tc.json, json.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/stream"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+var (
+	// ErrNotStarted is returned when producing events to an embedded
+	// broker that is not running.
+	ErrNotStarted = errors.New("embedded broker is not started")
+	// ErrAlreadyStarted is returned when starting an embedded broker more
+	// than once.
+	ErrAlreadyStarted = errors.New("embedded broker was already started")
+)
+
+// EventHandler receives the events dispatched to a trigger of an embedded
+// broker.
+type EventHandler func(ctx context.Context, event cloudevents.Event)
+
+type EmbeddedOption func(*Embedded)
+
+// EmbeddedWithLogger sets the logger of the embedded broker, which does not
+// log if not informed.
+func EmbeddedWithLogger(logger *zap.SugaredLogger) EmbeddedOption {
+	return func(e *Embedded) {
+		e.logger = logger
+	}
+}
+
+// EmbeddedWithManagerOptions sets the options of the subscription manager
+// that dispatches the events to triggers.
+func EmbeddedWithManagerOptions(opts ...subscriptions.ManagerOption) EmbeddedOption {
+	return func(e *Embedded) {
+		e.managerOptions = append(e.managerOptions, opts...)
+	}
+}
+
+// Embedded runs the routing engine of the broker in-process, without the
+// ingest and admin servers nor configuration files. Events are produced
+// calling Produce, and triggers are set programmatically, delivering events
+// to their targets or to Go functions.
+type Embedded struct {
+	backend        backend.Interface
+	managerOptions []subscriptions.ManagerOption
+
+	// Streams the events of the triggers handled by Go functions are
+	// pushed to.
+	streams *stream.Hub
+
+	// Triggers set so far, applied when starting.
+	triggers map[string]cfgbroker.Trigger
+	// Cancels the consumers of the triggers handled by Go functions,
+	// indexed by trigger name.
+	handlers map[string]context.CancelFunc
+
+	// Set when starting.
+	subscription *subscriptions.Manager
+	ctx          context.Context
+	cancel       context.CancelFunc
+	// done is closed when the backend finishes.
+	done chan struct{}
+
+	logger *zap.SugaredLogger
+	m      sync.RWMutex
+}
+
+// NewEmbedded returns an embedded broker that stores events at the backend,
+// which must not be initialized.
+func NewEmbedded(b backend.Interface, opts ...EmbeddedOption) *Embedded {
+	e := &Embedded{
+		backend:  b,
+		streams:  stream.NewHub(),
+		triggers: make(map[string]cfgbroker.Trigger),
+		handlers: make(map[string]context.CancelFunc),
+		logger:   zap.NewNop().Sugar(),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Start initializes the backend and dispatches events to the triggers in
+// the background, until the context is done or the broker is stopped.
+func (e *Embedded) Start(ctx context.Context) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.ctx != nil {
+		return ErrAlreadyStarted
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	if err := e.backend.Init(ctx); err != nil {
+		cancel()
+		return fmt.Errorf("could not initialize backend: %w", err)
+	}
+
+	sm, err := subscriptions.New(ctx, e.logger, e.backend,
+		append([]subscriptions.ManagerOption{subscriptions.ManagerWithStreams(e.streams)}, e.managerOptions...)...)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("could not create subscription manager: %w", err)
+	}
+
+	e.subscription = sm
+	e.ctx = ctx
+	e.cancel = cancel
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+		if err := e.backend.Start(ctx); err != nil {
+			e.logger.Errorw("Embedded broker backend failed", zap.Error(err))
+		}
+	}()
+	go func() {
+		if err := sm.Start(ctx); err != nil {
+			e.logger.Errorw("Embedded broker subscription manager failed", zap.Error(err))
+		}
+	}()
+
+	e.apply()
+	return nil
+}
+
+// Stop stops dispatching events and waits for the in-flight deliveries
+// until the context is done. Events whose delivery does not finish in time
+// are not marked as processed at the backend.
+func (e *Embedded) Stop(ctx context.Context) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.ctx == nil {
+		return ErrNotStarted
+	}
+
+	e.subscription.FlushReplies()
+	e.cancel()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+	err := e.subscription.Drain(ctx)
+
+	e.subscription.UpdateFromConfig(&cfgbroker.Config{})
+	for name, cancel := range e.handlers {
+		cancel()
+		delete(e.handlers, name)
+	}
+
+	return err
+}
+
+// Produce stores the event at the backend, which dispatches it to the
+// triggers whose filters it passes.
+func (e *Embedded) Produce(ctx context.Context, event cloudevents.Event) error {
+	e.m.RLock()
+	started := e.ctx != nil && e.ctx.Err() == nil
+	e.m.RUnlock()
+
+	if !started {
+		return ErrNotStarted
+	}
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("event is not valid: %w", err)
+	}
+
+	return e.backend.Produce(ctx, &event)
+}
+
+// SetTrigger adds the trigger, or replaces the trigger with the same name.
+func (e *Embedded) SetTrigger(name string, trigger cfgbroker.Trigger) error {
+	if err := trigger.Validate(context.Background()); err != nil {
+		return fmt.Errorf("trigger %q is not valid: %w", name, err)
+	}
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.stopHandler(name)
+	e.triggers[name] = trigger
+	e.apply()
+
+	return nil
+}
+
+// SetTriggerHandler adds the trigger, or replaces the trigger with the same
+// name, whose events are delivered to the handler instead of the trigger
+// target. Events are handled in order, one at a time, and are marked as
+// processed at the backend once buffered for the handler, hence the handler
+// must take care of errors.
+func (e *Embedded) SetTriggerHandler(name string, trigger cfgbroker.Trigger, h EventHandler) error {
+	trigger.Target = cfgbroker.Target{Stream: &cfgbroker.StreamTarget{}}
+	if err := trigger.Validate(context.Background()); err != nil {
+		return fmt.Errorf("trigger %q is not valid: %w", name, err)
+	}
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.stopHandler(name)
+
+	// The consumer must be connected before delivering events, which are
+	// not accepted by streams without consumers.
+	c, err := e.streams.Stream(name, stream.DefaultBufferSize).Subscribe("")
+	if err != nil {
+		return fmt.Errorf("could not connect trigger %q handler: %w", name, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.handlers[name] = cancel
+	go func() {
+		defer c.Close()
+		for {
+			msg, err := c.Next(ctx)
+			if err != nil {
+				return
+			}
+			h(ctx, msg.Event)
+		}
+	}()
+
+	e.triggers[name] = trigger
+	e.apply()
+
+	return nil
+}
+
+// DeleteTrigger removes the trigger, if it exists.
+func (e *Embedded) DeleteTrigger(name string) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.stopHandler(name)
+	delete(e.triggers, name)
+	e.apply()
+}
+
+// Triggers returns the names of the triggers sorted.
+func (e *Embedded) Triggers() []string {
+	e.m.RLock()
+	defer e.m.RUnlock()
+
+	names := make([]string, 0, len(e.triggers))
+	for name := range e.triggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stopHandler stops consuming the events of the trigger for its handler,
+// if any. The lock must be held.
+func (e *Embedded) stopHandler(name string) {
+	if cancel, ok := e.handlers[name]; ok {
+		cancel()
+		delete(e.handlers, name)
+	}
+}
+
+// apply configures the subscription manager with the triggers, if started.
+// The lock must be held.
+func (e *Embedded) apply() {
+	if e.subscription == nil || e.ctx.Err() != nil {
+		return
+	}
+
+	triggers := make(map[string]cfgbroker.Trigger, len(e.triggers))
+	for name, t := range e.triggers {
+		triggers[name] = t
+	}
+	e.subscription.UpdateFromConfig(&cfgbroker.Config{Triggers: triggers})
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestEmbedded(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Ce-Id")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	e := NewEmbedded(
+		memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S", ProduceTimeoutDuration: time.Second}, logger),
+		EmbeddedWithLogger(logger))
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"), lib.CloudEventWithTypeOption("order.created"))
+	assert.ErrorIs(t, e.Produce(context.Background(), ev), ErrNotStarted)
+
+	// Triggers set before starting are applied when starting.
+	require.NoError(t, e.SetTrigger("http", cfgbroker.Trigger{Target: cfgbroker.Target{URL: &srv.URL}}))
+
+	handled := make(chan cloudevents.Event, 10)
+	require.NoError(t, e.SetTriggerHandler("func", cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.created"}}},
+	}, func(_ context.Context, event cloudevents.Event) {
+		handled <- event
+	}))
+
+	assert.Equal(t, []string{"func", "http"}, e.Triggers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, e.Start(ctx))
+	assert.ErrorIs(t, e.Start(ctx), ErrAlreadyStarted)
+
+	require.NoError(t, e.Produce(ctx, ev))
+	other := lib.NewCloudEvent(lib.CloudEventWithIDOption("2"), lib.CloudEventWithTypeOption("order.deleted"))
+	require.NoError(t, e.Produce(ctx, other))
+
+	select {
+	case event := <-handled:
+		assert.Equal(t, "1", event.ID())
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Event was not handled")
+	}
+
+	ids := map[string]bool{}
+	for len(ids) < 2 {
+		select {
+		case id := <-received:
+			ids[id] = true
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "Events were not delivered to the target")
+		}
+	}
+
+	// Events that do not pass the filters are not handled.
+	select {
+	case event := <-handled:
+		assert.Fail(t, "Event was not filtered", event.ID())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	e.DeleteTrigger("http")
+	assert.Equal(t, []string{"func"}, e.Triggers())
+
+	assert.Error(t, e.Produce(ctx, cloudevents.NewEvent()), "Events must be valid")
+
+	sctx, scancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer scancel()
+	assert.NoError(t, e.Stop(sctx))
+	assert.ErrorIs(t, e.Produce(ctx, ev), ErrNotStarted)
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/ingest"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+// BackendFactory creates the backend for a hosted broker, which must store
+// its events apart from those of any other broker.
+type BackendFactory func(name string) backend.Interface
+
+type InstanceOption func(*Instance)
+
+// InstanceWithBackendFactory enables hosting the brokers informed at the
+// configuration along with the default broker.
+func InstanceWithBackendFactory(f BackendFactory) InstanceOption {
+	return func(i *Instance) {
+		i.backendFactory = f
+	}
+}
+
+type hostedBroker struct {
+	backend      backend.Interface
+	subscription *subscriptions.Manager
+
+	cancel context.CancelFunc
+	// done is closed when the backend finishes.
+	done chan struct{}
+}
+
+// hostedBrokers runs a backend and subscription manager for each broker
+// informed at the configuration, which share the ingest server.
+type hostedBrokers struct {
+	factory    BackendFactory
+	newManager func(ctx context.Context, name string, b backend.Interface) (*subscriptions.Manager, error)
+	ingest     *ingest.Instance
+
+	// Maximum time to wait for in-flight deliveries when removing brokers.
+	gracePeriod time.Duration
+
+	brokers map[string]*hostedBroker
+
+	// Context for running the backends, set when starting. Configurations
+	// received before are applied when starting.
+	ctx     context.Context
+	pending *cfgbroker.Config
+
+	logger *zap.SugaredLogger
+	m      sync.Mutex
+}
+
+// start applies the configuration received so far and blocks until the
+// context is done, stopping all hosted brokers before returning.
+func (h *hostedBrokers) start(ctx context.Context) error {
+	h.m.Lock()
+	h.ctx = ctx
+	pending := h.pending
+	h.pending = nil
+	h.m.Unlock()
+
+	if pending != nil {
+		h.UpdateFromConfig(pending)
+	}
+
+	<-ctx.Done()
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	for name, hb := range h.brokers {
+		h.remove(name, hb)
+	}
+
+	return nil
+}
+
+func (h *hostedBrokers) UpdateFromConfig(c *cfgbroker.Config) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.ctx == nil {
+		h.pending = c
+		return
+	}
+	if h.ctx.Err() != nil {
+		return
+	}
+
+	for name, hb := range h.brokers {
+		if _, ok := c.Brokers[name]; !ok {
+			h.logger.Infow("Removing hosted broker", zap.String("broker", name))
+			h.remove(name, hb)
+		}
+	}
+
+	for name, bc := range c.Brokers {
+		hb, ok := h.brokers[name]
+		if !ok {
+			if h.factory == nil {
+				h.logger.Errorw("Hosted brokers are not supported by this backend", zap.String("broker", name))
+				continue
+			}
+
+			h.logger.Infow("Adding hosted broker", zap.String("broker", name))
+			var err error
+			if hb, err = h.add(name); err != nil {
+				h.logger.Errorw("Could not start hosted broker", zap.String("broker", name), zap.Error(err))
+				continue
+			}
+		}
+
+		hb.subscription.UpdateFromConfig(&cfgbroker.Config{Triggers: bc.Triggers})
+	}
+}
+
+func (h *hostedBrokers) add(name string) (*hostedBroker, error) {
+	ctx, cancel := context.WithCancel(h.ctx)
+
+	b := h.factory(name)
+	if err := b.Init(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	sm, err := h.newManager(ctx, name, b)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	hb := &hostedBroker{
+		backend:      b,
+		subscription: sm,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	go func() {
+		defer close(hb.done)
+		if err := b.Start(ctx); err != nil {
+			h.logger.Errorw("Hosted broker backend failed", zap.String("broker", name), zap.Error(err))
+		}
+	}()
+
+	h.ingest.RegisterSyncTarget(name, ingest.SyncTarget{
+		Durable: b.Info().Durable,
+		Track:   sm.TrackDispatch,
+	})
+	h.ingest.RegisterBrokerHandler(name, b.Produce)
+	h.brokers[name] = hb
+
+	return hb, nil
+}
+
+// remove stops ingesting events for the broker, stops the backend and waits
+// for the in-flight deliveries up to the grace period.
+func (h *hostedBrokers) remove(name string, hb *hostedBroker) {
+	h.ingest.UnregisterBrokerHandler(name)
+	hb.subscription.FlushReplies()
+	hb.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.gracePeriod)
+	defer cancel()
+
+	select {
+	case <-hb.done:
+	case <-ctx.Done():
+	}
+	if err := hb.subscription.Drain(ctx); err != nil {
+		h.logger.Warnw("Grace period expired before hosted broker deliveries finished",
+			zap.String("broker", name), zap.Error(err))
+	}
+
+	hb.subscription.UpdateFromConfig(&cfgbroker.Config{})
+	delete(h.brokers, name)
+}
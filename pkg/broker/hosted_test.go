@@ -0,0 +1,91 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/ingest"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestHostedBrokers(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Ce-Id")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	created := map[string]int{}
+	h := &hostedBrokers{
+		factory: func(name string) backend.Interface {
+			created[name]++
+			return memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S", ProduceTimeoutDuration: time.Second}, logger)
+		},
+		newManager: func(ctx context.Context, name string, b backend.Interface) (*subscriptions.Manager, error) {
+			return subscriptions.New(ctx, logger, b)
+		},
+		ingest:      ingest.NewInstance(nil, logger),
+		brokers:     make(map[string]*hostedBroker),
+		gracePeriod: 5 * time.Second,
+		logger:      logger,
+	}
+
+	// Configuration received before starting is applied when starting.
+	h.UpdateFromConfig(&cfgbroker.Config{Brokers: map[string]cfgbroker.Broker{
+		"orders": {Triggers: map[string]cfgbroker.Trigger{
+			"t1": {Target: cfgbroker.Target{URL: &srv.URL}},
+		}},
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- h.start(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		h.m.Lock()
+		defer h.m.Unlock()
+		return len(h.brokers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	h.m.Lock()
+	hb := h.brokers["orders"]
+	h.m.Unlock()
+
+	ev := lib.NewCloudEvent()
+	require.NoError(t, hb.backend.Produce(ctx, &ev))
+	select {
+	case id := <-received:
+		assert.Equal(t, ev.ID(), id)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Event was not delivered by the hosted broker")
+	}
+
+	// Updating triggers keeps the broker running.
+	h.UpdateFromConfig(&cfgbroker.Config{Brokers: map[string]cfgbroker.Broker{"orders": {}}})
+	assert.Equal(t, 1, created["orders"])
+
+	h.UpdateFromConfig(&cfgbroker.Config{})
+	assert.Empty(t, h.brokers)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package client manages the triggers of a running broker through its admin
+// API, so that controllers can reconcile triggers without rewriting the
+// broker configuration files.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	triggersPath        = "/v1/triggers"
+	deletedTriggersPath = "/v1/deletedtriggers"
+
+	// Maximum size for response bodies.
+	maxBodySize = 10 << 20
+)
+
+// Error is returned when the admin API responds with an error status code.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("admin API responded with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("admin API responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error informs that the trigger does not
+// exist.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsInvalid returns true if the error informs that the resulting broker
+// configuration is not valid.
+func IsInvalid(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
+
+// IsConflict returns true if the error informs that the change cannot be
+// applied, like when the broker configuration is read only or when
+// restoring a trigger that already exists.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func hasStatus(err error, status int) bool {
+	var aerr *Error
+	return errors.As(err, &aerr) && aerr.StatusCode == status
+}
+
+// Client of the admin API of a broker.
+type Client struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+type ClientOption func(*Client)
+
+// ClientWithHTTPClient sets the HTTP client for requests to the admin API,
+// which defaults to http.DefaultClient.
+func ClientWithHTTPClient(c *http.Client) ClientOption {
+	return func(cl *Client) {
+		cl.client = c
+	}
+}
+
+// New returns a client for the admin API at the address, as in
+// http://broker:9090, authenticating with the token.
+func New(address, token string, opts ...ClientOption) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("admin API address is not valid: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("admin API address %q must be an HTTP URL", address)
+	}
+
+	c := &Client{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		client:  http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// ListTriggers returns the triggers of the broker indexed by name.
+func (c *Client) ListTriggers(ctx context.Context) (map[string]cfgbroker.Trigger, error) {
+	triggers := map[string]cfgbroker.Trigger{}
+	if _, err := c.do(ctx, http.MethodGet, triggersPath, nil, &triggers); err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// GetTrigger returns the trigger.
+func (c *Client) GetTrigger(ctx context.Context, name string) (*cfgbroker.Trigger, error) {
+	t := &cfgbroker.Trigger{}
+	if _, err := c.do(ctx, http.MethodGet, triggerPath(triggersPath, name), nil, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PutTrigger creates the trigger, or replaces the trigger with the same
+// name, returning true if it was created.
+func (c *Client) PutTrigger(ctx context.Context, name string, t cfgbroker.Trigger) (bool, error) {
+	status, err := c.do(ctx, http.MethodPut, triggerPath(triggersPath, name), &t, nil)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusCreated, nil
+}
+
+// DeleteTrigger deletes the trigger, which can be restored until its grace
+// period expires when the broker keeps deleted triggers.
+func (c *Client) DeleteTrigger(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, triggerPath(triggersPath, name), nil, nil)
+	return err
+}
+
+// ListDeletedTriggers returns the deleted triggers that can be restored
+// indexed by name.
+func (c *Client) ListDeletedTriggers(ctx context.Context) (map[string]cfgbroker.DeletedTrigger, error) {
+	deleted := map[string]cfgbroker.DeletedTrigger{}
+	if _, err := c.do(ctx, http.MethodGet, deletedTriggersPath, nil, &deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// RestoreTrigger restores the deleted trigger, returning it.
+func (c *Client) RestoreTrigger(ctx context.Context, name string) (*cfgbroker.Trigger, error) {
+	t := &cfgbroker.Trigger{}
+	if _, err := c.do(ctx, http.MethodPost, triggerPath(deletedTriggersPath, name), nil, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PurgeDeletedTrigger deletes the deleted trigger for good.
+func (c *Client) PurgeDeletedTrigger(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, triggerPath(deletedTriggersPath, name), nil, nil)
+	return err
+}
+
+func triggerPath(base, name string) string {
+	return base + "/" + url.PathEscape(name)
+}
+
+// do sends the request, decoding the response body into out when informed,
+// and returns the response status code.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("could not serialize request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.address+path, body)
+	if err != nil {
+		return 0, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("could not reach the admin API: %w", err)
+	}
+	defer res.Body.Close()
+
+	lr := io.LimitReader(res.Body, maxBodySize)
+	if res.StatusCode >= http.StatusBadRequest {
+		aerr := &Error{StatusCode: res.StatusCode}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(lr).Decode(&e); err == nil {
+			aerr.Message = e.Error
+		}
+		return res.StatusCode, aerr
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(lr).Decode(out); err != nil {
+			return res.StatusCode, fmt.Errorf("could not parse admin API response: %w", err)
+		}
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/admin"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+)
+
+func newTestClient(t *testing.T, opts ...admin.ServerOption) *Client {
+	s := admin.New(store.NewMemory(), zap.NewNop().Sugar(), append(opts, admin.ServerWithToken("secret"))...)
+	s.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{}})
+
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, "secret")
+	require.NoError(t, err)
+	return c
+}
+
+func target(url string) cfgbroker.Trigger {
+	return cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url}}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, admin.ServerWithDeletionGracePeriod(time.Hour))
+
+	created, err := c.PutTrigger(ctx, "t1", target("http://localhost:8888"))
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = c.PutTrigger(ctx, "t1", target("http://localhost:9999"))
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	tr, err := c.GetTrigger(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9999", *tr.Target.URL)
+
+	triggers, err := c.ListTriggers(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, triggers, "t1")
+
+	_, err = c.PutTrigger(ctx, "t2", target("http://[bad"))
+	assert.True(t, IsInvalid(err), "Invalid triggers must be rejected")
+
+	_, err = c.GetTrigger(ctx, "t2")
+	assert.True(t, IsNotFound(err))
+
+	require.NoError(t, c.DeleteTrigger(ctx, "t1"))
+	assert.True(t, IsNotFound(c.DeleteTrigger(ctx, "t1")))
+
+	deleted, err := c.ListDeletedTriggers(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, deleted, "t1")
+
+	tr, err = c.RestoreTrigger(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9999", *tr.Target.URL)
+
+	require.NoError(t, c.DeleteTrigger(ctx, "t1"))
+	require.NoError(t, c.PurgeDeletedTrigger(ctx, "t1"))
+	_, err = c.RestoreTrigger(ctx, "t1")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	c := newTestClient(t)
+	c.token = "wrong"
+
+	_, err := c.ListTriggers(context.Background())
+	var aerr *Error
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, 401, aerr.StatusCode)
+	assert.Equal(t, "unauthorized", aerr.Message)
+}
+
+func TestReconcileTriggers(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	for _, name := range []string{"ctrl-a", "ctrl-b", "other"} {
+		_, err := c.PutTrigger(ctx, name, target("http://localhost:8888"))
+		require.NoError(t, err)
+	}
+
+	owns := func(name string) bool { return strings.HasPrefix(name, "ctrl-") }
+	res, err := c.ReconcileTriggers(ctx, map[string]cfgbroker.Trigger{
+		"ctrl-a": target("http://localhost:8888"),
+		"ctrl-c": target("http://localhost:9999"),
+		"other":  target("http://localhost:7777"),
+	}, owns)
+	require.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{
+		Created: []string{"ctrl-c"},
+		Updated: []string{"other"},
+		Deleted: []string{"ctrl-b"},
+	}, res)
+
+	// Reconciling again does not change anything.
+	res, err = c.ReconcileTriggers(ctx, map[string]cfgbroker.Trigger{
+		"ctrl-a": target("http://localhost:8888"),
+		"ctrl-c": target("http://localhost:9999"),
+	}, owns)
+	require.NoError(t, err)
+	assert.Equal(t, &ReconcileResult{}, res)
+
+	triggers, err := c.ListTriggers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, triggers, 3, "Triggers not owned must be kept")
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := New("broker:9090", "secret")
+	assert.Error(t, err)
+
+	c, err := New("http://broker:9090/", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "http://broker:9090", c.address)
+}
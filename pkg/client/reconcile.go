@@ -0,0 +1,95 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// ReconcileResult informs the names of the triggers changed when
+// reconciling, sorted.
+type ReconcileResult struct {
+	Created []string
+	Updated []string
+	Deleted []string
+}
+
+// ReconcileTriggers makes the triggers of the broker match the desired ones,
+// creating and updating those that differ, and deleting those not desired
+// that the controller owns. All triggers are owned when owns is nil, which
+// lets controllers sharing a broker only manage their own triggers.
+// Reconciling stops at the first error, returning the changes done so far.
+func (c *Client) ReconcileTriggers(ctx context.Context, desired map[string]cfgbroker.Trigger, owns func(name string) bool) (*ReconcileResult, error) {
+	current, err := c.ListTriggers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ReconcileResult{}
+
+	for _, name := range sortedNames(desired) {
+		t := desired[name]
+		if ct, ok := current[name]; ok {
+			equal, err := sameTrigger(t, ct)
+			if err != nil {
+				return res, fmt.Errorf("could not compare trigger %q: %w", name, err)
+			}
+			if equal {
+				continue
+			}
+		}
+
+		created, err := c.PutTrigger(ctx, name, t)
+		if err != nil {
+			return res, fmt.Errorf("could not apply trigger %q: %w", name, err)
+		}
+		if created {
+			res.Created = append(res.Created, name)
+		} else {
+			res.Updated = append(res.Updated, name)
+		}
+	}
+
+	for _, name := range sortedNames(current) {
+		if _, ok := desired[name]; ok || (owns != nil && !owns(name)) {
+			continue
+		}
+
+		if err := c.DeleteTrigger(ctx, name); err != nil && !IsNotFound(err) {
+			return res, fmt.Errorf("could not delete trigger %q: %w", name, err)
+		}
+		res.Deleted = append(res.Deleted, name)
+	}
+
+	return res, nil
+}
+
+// sameTrigger compares the desired trigger with the one returned by the
+// admin API, serializing the desired trigger as the API does.
+func sameTrigger(desired, current cfgbroker.Trigger) (bool, error) {
+	b, err := json.Marshal(desired)
+	if err != nil {
+		return false, err
+	}
+	var t cfgbroker.Trigger
+	if err := json.Unmarshal(b, &t); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(t, current), nil
+}
+
+func sortedNames(triggers map[string]cfgbroker.Trigger) []string {
+	names := make([]string, 0, len(triggers))
+	for name := range triggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package debug identifies events that producers flagged for debugging,
+// which are fully traced, logged and audited through the broker.
+package debug
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// Extension is the CloudEvents extension that flags an event for debugging.
+	Extension = "debug"
+
+	// Header is the HTTP header for the extension when using binary mode.
+	Header = "Ce-Debug"
+)
+
+type debugKey struct{}
+
+// Enabled returns true when the event is flagged for debugging.
+func Enabled(event *cloudevents.Event) bool {
+	v, ok := event.Extensions()[Extension]
+	if !ok {
+		return false
+	}
+
+	b, err := types.ToBool(v)
+	if err == nil {
+		return b
+	}
+
+	s, err := types.ToString(v)
+	return err == nil && strings.EqualFold(s, "true")
+}
+
+// RequestEnabled returns true when the request headers flag the event for
+// debugging. Events sent in structured mode cannot be identified before
+// reading the request body.
+func RequestEnabled(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(Header), "true")
+}
+
+// ContextWithEnabled flags the context for debugging.
+func ContextWithEnabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// FromContext returns true when the context is flagged for debugging.
+func FromContext(ctx context.Context) bool {
+	v, ok := ctx.Value(debugKey{}).(bool)
+	return ok && v
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package debug
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestEnabled(t *testing.T) {
+	tcs := map[string]struct {
+		value   interface{}
+		enabled bool
+	}{
+		"missing":           {},
+		"boolean true":      {value: true, enabled: true},
+		"boolean false":     {value: false},
+		"string true":       {value: "true", enabled: true},
+		"string mixed case": {value: "True", enabled: true},
+		"string false":      {value: "false"},
+		"not boolean":       {value: "yes"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			event := lib.NewCloudEvent()
+			if tc.value != nil {
+				event.SetExtension(Extension, tc.value)
+			}
+			assert.Equal(t, tc.enabled, Enabled(&event))
+		})
+	}
+}
+
+func TestRequestEnabled(t *testing.T) {
+	tcs := map[string]struct {
+		header  string
+		enabled bool
+	}{
+		"missing":     {},
+		"true":        {header: "true", enabled: true},
+		"mixed case":  {header: "TRUE", enabled: true},
+		"false":       {header: "false"},
+		"not boolean": {header: "1"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.header != "" {
+				r.Header.Set(Header, tc.header)
+			}
+			assert.Equal(t, tc.enabled, RequestEnabled(r))
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, FromContext(ctx))
+	assert.True(t, FromContext(ContextWithEnabled(ctx)))
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package encryption encrypts the events stored at backends using envelope
+// encryption: payloads are encrypted with AES-GCM using a data key, which
+// is stored along with them encrypted by a key encryption key.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// Version of the envelope format, informed at its first byte.
+	envelopeVersion = 1
+
+	// Size of the AES-256 data keys.
+	dataKeySize = 32
+
+	// Data keys are rotated after encrypting this number of payloads,
+	// well below the limit for random nonces.
+	dataKeyMaxUses = 1 << 20
+
+	// Unwrapped data keys kept to avoid unwrapping them for each payload.
+	maxCachedDataKeys = 1024
+)
+
+var ErrNotEncrypted = errors.New("payload is not encrypted")
+
+// KeyWrapper encrypts and decrypts data keys using a key encryption key.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+type dataKey struct {
+	aead    cipher.AEAD
+	wrapped []byte
+	uses    int
+}
+
+// Cipher encrypts payloads using data keys wrapped by the key wrapper.
+type Cipher struct {
+	wrapper KeyWrapper
+
+	current *dataKey
+	// Unwrapped data keys indexed by their wrapped value.
+	cache map[string]cipher.AEAD
+
+	m sync.Mutex
+}
+
+// New returns a cipher whose key encryption key is read from the source,
+// which can be a file path prefixed with file://, an environment variable
+// prefixed with env://, or a HashiCorp Vault transit key URL prefixed with
+// vault+https:// or vault+http://.
+func New(source string) (*Cipher, error) {
+	var w KeyWrapper
+	var err error
+
+	switch {
+	case strings.HasPrefix(source, "file://"):
+		w, err = newFileKeyWrapper(strings.TrimPrefix(source, "file://"))
+	case strings.HasPrefix(source, "env://"):
+		w, err = newEnvKeyWrapper(strings.TrimPrefix(source, "env://"))
+	case strings.HasPrefix(source, "vault+https://"), strings.HasPrefix(source, "vault+http://"):
+		w, err = newVaultKeyWrapper(strings.TrimPrefix(source, "vault+"))
+	default:
+		return nil, fmt.Errorf("unknown encryption key source %q", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCipher(w), nil
+}
+
+// NewCipher returns a cipher whose data keys are wrapped by the wrapper.
+func NewCipher(w KeyWrapper) *Cipher {
+	return &Cipher{
+		wrapper: w,
+		cache:   make(map[string]cipher.AEAD),
+	}
+}
+
+// Encrypt returns the envelope that contains the encrypted payload along
+// with its wrapped data key.
+func (c *Cipher) Encrypt(ctx context.Context, payload []byte) ([]byte, error) {
+	dk, err := c.dataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := dk.aead.NonceSize()
+	env := make([]byte, 0, 3+len(dk.wrapped)+nonceSize+len(payload)+dk.aead.Overhead())
+	env = append(env, envelopeVersion)
+	env = binary.BigEndian.AppendUint16(env, uint16(len(dk.wrapped)))
+	env = append(env, dk.wrapped...)
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	env = append(env, nonce...)
+
+	return dk.aead.Seal(env, nonce, payload, nil), nil
+}
+
+// Decrypt returns the payload contained at the envelope.
+func (c *Cipher) Decrypt(ctx context.Context, env []byte) ([]byte, error) {
+	if len(env) < 3 || env[0] != envelopeVersion {
+		return nil, ErrNotEncrypted
+	}
+
+	n := int(binary.BigEndian.Uint16(env[1:3]))
+	if len(env) < 3+n {
+		return nil, errors.New("envelope is truncated")
+	}
+	wrapped, rest := env[3:3+n], env[3+n:]
+
+	aead, err := c.unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("envelope is truncated")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	payload, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt payload: %w", err)
+	}
+	return payload, nil
+}
+
+// dataKey returns the data key for encrypting a payload, generating a new
+// one when the current key was used too many times.
+func (c *Cipher) dataKey(ctx context.Context) (*dataKey, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.current != nil && c.current.uses < dataKeyMaxUses {
+		c.current.uses++
+		return c.current, nil
+	}
+
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("could not generate data key: %w", err)
+	}
+
+	wrapped, err := c.wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not wrap data key: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.current = &dataKey{aead: aead, wrapped: wrapped, uses: 1}
+	c.cacheKey(wrapped, aead)
+	return c.current, nil
+}
+
+func (c *Cipher) unwrap(ctx context.Context, wrapped []byte) (cipher.AEAD, error) {
+	c.m.Lock()
+	aead, ok := c.cache[string(wrapped)]
+	c.m.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	key, err := c.wrapper.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap data key: %w", err)
+	}
+	if aead, err = newAEAD(key); err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.cacheKey(wrapped, aead)
+	return aead, nil
+}
+
+// cacheKey keeps the unwrapped data key, discarding all of them when the
+// cache is full. The cipher lock must be held.
+func (c *Cipher) cacheKey(wrapped []byte, aead cipher.AEAD) {
+	if len(c.cache) >= maxCachedDataKeys {
+		c.cache = make(map[string]cipher.AEAD)
+	}
+	c.cache[string(wrapped)] = aead
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher(t *testing.T) {
+	ctx := context.Background()
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	kr, err := NewKeyring(key1)
+	require.NoError(t, err)
+	c := NewCipher(kr)
+
+	payload := []byte(`{"secret":"value"}`)
+	env, err := c.Encrypt(ctx, payload)
+	require.NoError(t, err)
+	assert.NotContains(t, string(env), "secret")
+
+	got, err := c.Decrypt(ctx, env)
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	env2, err := c.Encrypt(ctx, payload)
+	require.NoError(t, err)
+	assert.NotEqual(t, env, env2, "Nonces must not be reused")
+
+	_, err = c.Decrypt(ctx, payload)
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	tampered := append([]byte(nil), env...)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = c.Decrypt(ctx, tampered)
+	assert.Error(t, err, "Tampered envelopes must not be decrypted")
+
+	// After rotating, envelopes using the previous key are decrypted,
+	// while new ones use the new key.
+	kr, err = NewKeyring(key2, key1)
+	require.NoError(t, err)
+	rotated := NewCipher(kr)
+
+	got, err = rotated.Decrypt(ctx, env)
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	env, err = rotated.Encrypt(ctx, payload)
+	require.NoError(t, err)
+	_, err = c.Decrypt(ctx, env)
+	assert.Error(t, err, "Envelopes using keys not at the keyring must not be decrypted")
+}
+
+func TestKeySources(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte(key1+"\n"+key2+"\n"), 0o600))
+	t.Setenv("TEST_ENCRYPTION_KEYS", key2+","+key1)
+	t.Setenv("TEST_ENCRYPTION_SHORT_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+
+	testCases := map[string]struct {
+		source    string
+		expectErr bool
+	}{
+		"file":           {source: "file://" + path},
+		"env":            {source: "env://TEST_ENCRYPTION_KEYS"},
+		"missing file":   {source: "file://" + path + ".missing", expectErr: true},
+		"missing env":    {source: "env://TEST_ENCRYPTION_MISSING", expectErr: true},
+		"short key":      {source: "env://TEST_ENCRYPTION_SHORT_KEY", expectErr: true},
+		"unknown source": {source: "s3://bucket/keys", expectErr: true},
+		"vault no token": {source: "vault+https://vault:8200/transit/events", expectErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(tc.source)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			env, err := c.Encrypt(context.Background(), []byte("payload"))
+			require.NoError(t, err)
+			got, err := c.Decrypt(context.Background(), env)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(got))
+		})
+	}
+}
+
+func TestVault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		req := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		// Fake transit engine that prefixes the plaintext.
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/events":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"ciphertext": "vault:v1:" + req["plaintext"]},
+			})
+		case "/v1/transit/decrypt/events":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"plaintext": strings.TrimPrefix(req["ciphertext"], "vault:v1:")},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv(vaultTokenEnv, "token")
+	c, err := New("vault+" + srv.URL + "/transit/events")
+	require.NoError(t, err)
+
+	env, err := c.Encrypt(context.Background(), []byte("payload"))
+	require.NoError(t, err)
+
+	// A different cipher must unwrap the data key through Vault.
+	c2, err := New("vault+" + srv.URL + "/transit/events")
+	require.NoError(t, err)
+	got, err := c2.Decrypt(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(got))
+
+	t.Setenv(vaultTokenEnv, "other")
+	c3, err := New("vault+" + srv.URL + "/transit/events")
+	require.NoError(t, err)
+	_, err = c3.Decrypt(context.Background(), env)
+	assert.Error(t, err)
+}
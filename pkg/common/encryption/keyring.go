@@ -0,0 +1,131 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Size of the identifier of each key at the keyring, which is stored
+// along with the wrapped data keys.
+const keyIDSize = 8
+
+type keyringKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// keyring wraps data keys with local AES-256 keys. The first key wraps new
+// data keys, while the rest are kept for unwrapping data keys wrapped
+// before a rotation.
+type keyring struct {
+	keys []keyringKey
+}
+
+var _ KeyWrapper = (*keyring)(nil)
+
+// newFileKeyWrapper reads base64 encoded keys from the file, one per line.
+func newFileKeyWrapper(path string) (*keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read encryption keys file: %w", err)
+	}
+	return newKeyring(strings.Split(string(b), "\n"))
+}
+
+// newEnvKeyWrapper reads base64 encoded keys from the environment
+// variable, separated by commas.
+func newEnvKeyWrapper(name string) (*keyring, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %q for encryption keys is not set", name)
+	}
+	return newKeyring(strings.Split(v, ","))
+}
+
+// NewKeyring returns a key wrapper for the AES-256 keys, the first one
+// being used for wrapping new data keys.
+func NewKeyring(keys ...[]byte) (KeyWrapper, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("encryption keyring is empty")
+	}
+
+	kr := &keyring{keys: make([]keyringKey, 0, len(keys))}
+	for i, key := range keys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("encryption key #%d must be %d bytes long, is %d", i, dataKeySize, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		id := sha256.Sum256(key)
+		kr.keys = append(kr.keys, keyringKey{id: id[:keyIDSize], aead: aead})
+	}
+	return kr, nil
+}
+
+func newKeyring(encoded []string) (*keyring, error) {
+	keys := make([][]byte, 0, len(encoded))
+	for _, e := range encoded {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(e)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key #%d is not base64 encoded: %w", len(keys), err)
+		}
+		keys = append(keys, key)
+	}
+
+	kr, err := NewKeyring(keys...)
+	if err != nil {
+		return nil, err
+	}
+	return kr.(*keyring), nil
+}
+
+func (kr *keyring) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	k := kr.keys[0]
+
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	wrapped := make([]byte, 0, keyIDSize+len(nonce)+len(key)+k.aead.Overhead())
+	wrapped = append(wrapped, k.id...)
+	wrapped = append(wrapped, nonce...)
+	return k.aead.Seal(wrapped, nonce, key, k.id), nil
+}
+
+func (kr *keyring) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < keyIDSize {
+		return nil, errors.New("wrapped data key is truncated")
+	}
+	id, rest := wrapped[:keyIDSize], wrapped[keyIDSize:]
+
+	for _, k := range kr.keys {
+		if !bytes.Equal(k.id, id) {
+			continue
+		}
+		if len(rest) < k.aead.NonceSize() {
+			return nil, errors.New("wrapped data key is truncated")
+		}
+		return k.aead.Open(nil, rest[:k.aead.NonceSize()], rest[k.aead.NonceSize():], k.id)
+	}
+
+	return nil, errors.New("data key was wrapped with a key that is not at the keyring")
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variable that contains the token for authenticating with
+// HashiCorp Vault.
+const vaultTokenEnv = "VAULT_TOKEN"
+
+// vault wraps data keys using a HashiCorp Vault transit secrets engine key,
+// so that the key encryption key never leaves Vault.
+type vault struct {
+	// Address of the Vault server, mount path of the transit engine and
+	// name of the key.
+	address string
+	mount   string
+	key     string
+	token   string
+
+	client *http.Client
+}
+
+var _ KeyWrapper = (*vault)(nil)
+
+// newVaultKeyWrapper parses the URL of the transit key, which must have the
+// form https://host:port/<mount>/<key>.
+func newVaultKeyWrapper(rawURL string) (*vault, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Vault transit key URL: %w", err)
+	}
+
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("Vault transit key URL must have the form %s://host:port/<mount>/<key>", u.Scheme)
+	}
+
+	token := os.Getenv(vaultTokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("environment variable %s for Vault authentication is not set", vaultTokenEnv)
+	}
+
+	return &vault{
+		address: u.Scheme + "://" + u.Host,
+		mount:   path[:i],
+		key:     path[i+1:],
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (v *vault) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	res := struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}{}
+
+	if err := v.do(ctx, "encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(key),
+	}, &res); err != nil {
+		return nil, err
+	}
+
+	if res.Data.Ciphertext == "" {
+		return nil, errors.New("Vault response does not contain the ciphertext")
+	}
+	return []byte(res.Data.Ciphertext), nil
+}
+
+func (v *vault) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	res := struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}{}
+
+	if err := v.do(ctx, "decrypt", map[string]string{
+		"ciphertext": string(wrapped),
+	}, &res); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(res.Data.Plaintext)
+}
+
+// do sends the request to the transit engine operation, parsing the
+// response into res.
+func (v *vault) do(ctx context.Context, operation string, req, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/v1/%s/%s/%s", v.address, v.mount, operation, v.key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("X-Vault-Token", v.token)
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("could not reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read Vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Vault %s operation failed with status %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	return json.Unmarshal(b, res)
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package eventid generates identifiers for events that do not inform them.
+package eventid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Strategy for generating event identifiers.
+type Strategy string
+
+const (
+	// StrategyUUID generates random UUIDs (version 4).
+	StrategyUUID Strategy = "uuid"
+	// StrategyUUIDv7 generates UUIDs whose first bits are the
+	// generation time in milliseconds (version 7).
+	StrategyUUIDv7 Strategy = "uuidv7"
+	// StrategyKSUID generates K-Sortable Unique IDentifiers, whose first
+	// bytes are the generation time in seconds.
+	StrategyKSUID Strategy = "ksuid"
+	// StrategySnowflake generates 64 bit numbers composed by the
+	// generation time in milliseconds, the instance ID and a sequence.
+	StrategySnowflake Strategy = "snowflake"
+)
+
+const (
+	// Epoch for KSUID timestamps.
+	ksuidEpoch = 1400000000
+	// Length of base62 encoded KSUIDs.
+	ksuidLength  = 27
+	base62Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// Epoch for snowflake timestamps in unix milliseconds.
+	snowflakeEpoch = 1288834974657
+	// MaxInstance is the maximum instance ID for the snowflake strategy.
+	MaxInstance = 1<<10 - 1
+	maxSequence = 1<<12 - 1
+)
+
+// Generator creates event identifiers.
+type Generator interface {
+	NewID() string
+}
+
+// New returns the generator for the strategy. The instance ID, up to
+// MaxInstance, is only used by the snowflake strategy and must be unique
+// for each broker instance sharing the backend.
+func New(strategy Strategy, instance int) (Generator, error) {
+	switch strategy {
+	case StrategyUUID:
+		return uuidGenerator{}, nil
+	case StrategyUUIDv7:
+		return &uuidv7Generator{now: time.Now}, nil
+	case StrategyKSUID:
+		return ksuidGenerator{now: time.Now}, nil
+	case StrategySnowflake:
+		if instance < 0 || instance > MaxInstance {
+			return nil, fmt.Errorf("snowflake instance ID must be between 0 and %d", MaxInstance)
+		}
+		return &snowflakeGenerator{instance: int64(instance), now: time.Now}, nil
+	}
+
+	return nil, fmt.Errorf("unknown event ID strategy %q", strategy)
+}
+
+// Default returns the generator of random UUIDs.
+func Default() Generator {
+	return uuidGenerator{}
+}
+
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// uuidv7Generator uses the 12 bits following the timestamp as a counter
+// for identifiers generated within the same millisecond, which keeps them
+// sorted.
+type uuidv7Generator struct {
+	lastMs int64
+	seq    uint16
+	now    func() time.Time
+	m      sync.Mutex
+}
+
+func (g *uuidv7Generator) NewID() string {
+	var u uuid.UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("could not read random bytes: %v", err))
+	}
+
+	g.m.Lock()
+	ms := g.now().UnixMilli()
+	switch {
+	case ms > g.lastMs:
+		g.lastMs = ms
+		g.seq = 0
+	case g.seq < maxSequence:
+		g.seq++
+	default:
+		// Borrow the next millisecond when the counter is exhausted.
+		g.lastMs++
+		g.seq = 0
+	}
+	ms, seq := g.lastMs, g.seq
+	g.m.Unlock()
+
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	u[6] = 0x70 | byte(seq>>8)
+	u[7] = byte(seq)
+	u[8] = 0x80 | (u[8] & 0x3f)
+
+	return u.String()
+}
+
+type ksuidGenerator struct {
+	now func() time.Time
+}
+
+func (g ksuidGenerator) NewID() string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(g.now().Unix()-ksuidEpoch))
+	if _, err := rand.Read(b[4:]); err != nil {
+		panic(fmt.Sprintf("could not read random bytes: %v", err))
+	}
+
+	n := new(big.Int).SetBytes(b[:])
+	base := big.NewInt(62)
+	mod := new(big.Int)
+
+	out := make([]byte, ksuidLength)
+	for i := ksuidLength - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = base62Digits[mod.Int64()]
+	}
+
+	return string(out)
+}
+
+type snowflakeGenerator struct {
+	instance int64
+	lastMs   int64
+	seq      int64
+	now      func() time.Time
+	m        sync.Mutex
+}
+
+func (g *snowflakeGenerator) NewID() string {
+	g.m.Lock()
+	ms := g.now().UnixMilli() - snowflakeEpoch
+	switch {
+	case ms > g.lastMs:
+		g.lastMs = ms
+		g.seq = 0
+	case g.seq < maxSequence:
+		g.seq++
+	default:
+		g.lastMs++
+		g.seq = 0
+	}
+	id := g.lastMs<<22 | g.instance<<12 | g.seq
+	g.m.Unlock()
+
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package eventid
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedByTime(t *testing.T) {
+	now := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	tc := map[string]Generator{
+		"uuidv7":    &uuidv7Generator{now: clock},
+		"ksuid":     ksuidGenerator{now: clock},
+		"snowflake": &snowflakeGenerator{instance: 7, now: clock},
+	}
+
+	for name, g := range tc {
+		t.Run(name, func(t *testing.T) {
+			ids := []string{}
+			for i := 0; i < 10; i++ {
+				ids = append(ids, g.NewID())
+				now = now.Add(time.Second)
+			}
+			assert.True(t, sort.StringsAreSorted(ids), "IDs must sort by time: %v", ids)
+		})
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	now := time.UnixMilli(1677664800123)
+	g := &uuidv7Generator{now: func() time.Time { return now }}
+
+	// IDs within the same millisecond keep their order.
+	prev := ""
+	for i := 0; i < maxSequence+10; i++ {
+		id := g.NewID()
+		require.Greater(t, id, prev)
+		prev = id
+	}
+
+	u, err := uuid.Parse(prev)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), u.Version())
+	assert.Equal(t, uuid.RFC4122, u.Variant())
+}
+
+func TestSnowflake(t *testing.T) {
+	_, err := New(StrategySnowflake, MaxInstance+1)
+	assert.Error(t, err)
+
+	now := time.UnixMilli(snowflakeEpoch + 1000)
+	g, err := New(StrategySnowflake, 5)
+	require.NoError(t, err)
+	g.(*snowflakeGenerator).now = func() time.Time { return now }
+
+	id, err := strconv.ParseInt(g.NewID(), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), id>>22)
+	assert.Equal(t, int64(5), id>>12&MaxInstance)
+}
+
+func TestKSUIDLength(t *testing.T) {
+	g, err := New(StrategyKSUID, 0)
+	require.NoError(t, err)
+	assert.Len(t, g.NewID(), ksuidLength)
+
+	_, err = New("unknown", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package expiry identifies events that are too old to be delivered.
+package expiry
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// Extension is the CloudEvents extension that informs the number of
+	// seconds the event can be delivered since it was produced.
+	Extension = "ttlseconds"
+
+	// IngestTimeExtension is the CloudEvents extension set by the broker
+	// with the time the event was ingested. It is only set for events that
+	// do not inform the time attribute.
+	IngestTimeExtension = "triggermeshingesttime"
+)
+
+// SetIngestTime informs at the event the time it was ingested, when the
+// event does not inform the time attribute.
+func SetIngestTime(event *cloudevents.Event, t time.Time) error {
+	if !event.Time().IsZero() {
+		return nil
+	}
+	return event.Context.SetExtension(IngestTimeExtension, types.Timestamp{Time: t})
+}
+
+// TTL returns the time to live informed at the event extension, and false
+// if the extension is not informed.
+func TTL(event *cloudevents.Event) (time.Duration, bool, error) {
+	v, ok := event.Extensions()[Extension]
+	if !ok {
+		return 0, false, nil
+	}
+
+	var ttl int64
+	switch tv := v.(type) {
+	case string:
+		d, err := strconv.ParseInt(tv, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("extension %q is not a valid integer: %w", Extension, err)
+		}
+		ttl = d
+	default:
+		d, err := types.ToInteger(v)
+		if err != nil {
+			return 0, false, fmt.Errorf("extension %q is not a valid integer: %w", Extension, err)
+		}
+		ttl = int64(d)
+	}
+
+	if ttl <= 0 {
+		return 0, false, fmt.Errorf("extension %q must be greater than zero", Extension)
+	}
+
+	return time.Duration(ttl) * time.Second, true, nil
+}
+
+// Expired returns true if the event is older than its time to live. The
+// event TTL extension can only shorten the broker TTL, which disables the
+// broker TTL when zero.
+//
+// The age of the event is calculated from its time attribute, or from the
+// ingest time when the time attribute is not informed. Events that inform
+// none of them never expire.
+func Expired(event *cloudevents.Event, brokerTTL time.Duration, now time.Time) bool {
+	ttl := brokerTTL
+	if ettl, ok, err := TTL(event); err == nil && ok && (ttl <= 0 || ettl < ttl) {
+		ttl = ettl
+	}
+
+	if ttl <= 0 {
+		return false
+	}
+
+	t := event.Time()
+	if t.IsZero() {
+		v, ok := event.Extensions()[IngestTimeExtension]
+		if !ok {
+			return false
+		}
+		it, err := types.ToTime(v)
+		if err != nil {
+			return false
+		}
+		t = it
+	}
+
+	return now.Sub(t) > ttl
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+
+	tcs := map[string]struct {
+		time       time.Time
+		ingestTime time.Time
+		ttl        interface{}
+		brokerTTL  time.Duration
+
+		expected bool
+	}{
+		"no TTL": {
+			time: now.Add(-time.Hour),
+		},
+		"broker TTL not expired": {
+			time:      now.Add(-time.Minute),
+			brokerTTL: time.Hour,
+		},
+		"broker TTL expired": {
+			time:      now.Add(-time.Hour),
+			brokerTTL: time.Minute,
+			expected:  true,
+		},
+		"event TTL expired": {
+			time:     now.Add(-time.Minute),
+			ttl:      "30",
+			expected: true,
+		},
+		"event TTL cannot extend broker TTL": {
+			time:      now.Add(-time.Hour),
+			ttl:       int32(7200),
+			brokerTTL: time.Minute,
+			expected:  true,
+		},
+		"ingest time expired": {
+			ingestTime: now.Add(-time.Hour),
+			brokerTTL:  time.Minute,
+			expected:   true,
+		},
+		"no time": {
+			brokerTTL: time.Minute,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			event := cloudevents.NewEvent()
+			event.SetID("1")
+			event.SetType("test.type")
+			event.SetSource("test.source")
+			if !tc.time.IsZero() {
+				event.SetTime(tc.time)
+			}
+			if !tc.ingestTime.IsZero() {
+				require.NoError(t, SetIngestTime(&event, tc.ingestTime))
+			}
+			if tc.ttl != nil {
+				event.SetExtension(Extension, tc.ttl)
+			}
+
+			assert.Equal(t, tc.expected, Expired(&event, tc.brokerTTL, now))
+		})
+	}
+}
@@ -45,6 +45,10 @@ func (ccw *fakeCachedFileWatcher) Add(path string, cb fs.CachedWatchCallback) er
 	return nil
 }
 
+func (ccw *fakeCachedFileWatcher) AddDir(_ string, _ fs.WatchCallback) error {
+	return nil
+}
+
 func (ccw *fakeCachedFileWatcher) GetContent(path string) ([]byte, error) {
 	ccw.m.RLock()
 	defer ccw.m.RUnlock()
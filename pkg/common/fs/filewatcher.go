@@ -18,7 +18,8 @@ import (
 // is updated.
 type WatchCallback func()
 
-// FileWatcher object tracks changes to files.
+// FileWatcher object tracks changes to files. Watched directories are
+// called back when their entries are created, modified or removed.
 type FileWatcher interface {
 	Add(path string, cb WatchCallback) error
 	Start(ctx context.Context)
@@ -89,27 +90,29 @@ func (cw *fileWatcher) Start(ctx context.Context) {
 						return
 					}
 
-					if e.Op&fsnotify.Remove == fsnotify.Remove {
-						if fileExist(e.Name) {
-							if err := cw.watcher.Add(e.Name); err != nil {
-								cw.logger.Errorw(
-									fmt.Sprintf("could not add the path %q back to the watcher", e.Name),
-									zap.Error(err))
-							}
+					cw.m.RLock()
+					fcbs, ok := cw.watchedFiles[e.Name]
+					// Events for the entries of watched directories are
+					// notified to the directory callbacks.
+					dcbs, dok := cw.watchedFiles[filepath.Dir(e.Name)]
+					cbs := append(append([]WatchCallback{}, fcbs...), dcbs...)
+					cw.m.RUnlock()
+
+					if e.Op&fsnotify.Remove == fsnotify.Remove && ok && fileExist(e.Name) {
+						if err := cw.watcher.Add(e.Name); err != nil {
+							cw.logger.Errorw(
+								fmt.Sprintf("could not add the path %q back to the watcher", e.Name),
+								zap.Error(err))
 						}
-						continue
 					}
 
-					cw.m.RLock()
-					cbs, ok := cw.watchedFiles[e.Name]
-					if !ok {
+					if !ok && !dok {
 						cw.logger.Warnw("Received a notification for a non watched file", zap.String("file", e.Name))
 					}
 
 					for _, cb := range cbs {
 						cb()
 					}
-					cw.m.RUnlock()
 
 				case err, ok := <-cw.watcher.Errors:
 					if !ok {
@@ -5,6 +5,7 @@ package fs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -19,6 +20,9 @@ type CachedFileWatcher interface {
 	Start(ctx context.Context)
 	// Add a file path to be watched.
 	Add(path string, cb CachedWatchCallback) error
+	// AddDir adds a directory path to be watched, calling back when its
+	// entries change. Directory contents are not cached.
+	AddDir(path string, cb WatchCallback) error
 	// GetContent of watched file.
 	GetContent(path string) ([]byte, error)
 }
@@ -69,6 +73,10 @@ func (ccw *cachedFileWatcher) callback(path string, cb CachedWatchCallback) Watc
 		defer ccw.m.Unlock()
 		if err := ccw.updateContentFromFile(path); err != nil {
 			ccw.logger.Errorw("Could not read watched file", zap.Error(err))
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed files inform no content.
+				ccw.watchedFiles[path] = nil
+			}
 		}
 
 		// Call user's callback
@@ -98,6 +106,11 @@ func (ccw *cachedFileWatcher) Add(path string, cb CachedWatchCallback) error {
 	return nil
 }
 
+// AddDir adds a directory path to be watched.
+func (ccw *cachedFileWatcher) AddDir(path string, cb WatchCallback) error {
+	return ccw.cw.Add(path, cb)
+}
+
 // GetContent of watched file.
 func (ccw *cachedFileWatcher) GetContent(path string) ([]byte, error) {
 	ccw.m.RLock()
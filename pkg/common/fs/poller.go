@@ -16,6 +16,9 @@ type PollerCallback func(content []byte)
 
 type Poller interface {
 	Add(path string, cb PollerCallback) error
+	// AddDir polls a directory path, calling back when its entries are
+	// created, modified or removed.
+	AddDir(path string, cb PollerCallback) error
 	Start(ctx context.Context)
 	GetContent(path string) ([]byte, error)
 }
@@ -23,6 +26,9 @@ type Poller interface {
 type pollFile struct {
 	cbs            []PollerCallback
 	cachedContents []byte
+	// Directories are polled for the names, sizes and modification
+	// times of their entries.
+	dir bool
 }
 
 type poller struct {
@@ -55,6 +61,14 @@ func (p *poller) GetContent(path string) ([]byte, error) {
 }
 
 func (p *poller) Add(path string, cb PollerCallback) error {
+	return p.add(path, false, cb)
+}
+
+func (p *poller) AddDir(path string, cb PollerCallback) error {
+	return p.add(path, true, cb)
+}
+
+func (p *poller) add(path string, dir bool, cb PollerCallback) error {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("error resolving to absolute path %q: %w", path, err)
@@ -70,7 +84,7 @@ func (p *poller) Add(path string, cb PollerCallback) error {
 	p.logger.Infow("Adding file to poller", zap.String("file", path))
 	if _, ok := p.polledFiles[path]; !ok {
 
-		p.polledFiles[path] = &pollFile{cbs: []PollerCallback{cb}}
+		p.polledFiles[path] = &pollFile{cbs: []PollerCallback{cb}, dir: dir}
 		return nil
 	}
 
@@ -111,7 +125,13 @@ func (p *poller) poll() {
 	defer p.m.RUnlock()
 
 	for file, pf := range p.polledFiles {
-		b, err := os.ReadFile(file)
+		var b []byte
+		var err error
+		if pf.dir {
+			b, err = readDirEntries(file)
+		} else {
+			b, err = os.ReadFile(file)
+		}
 		if err != nil {
 			p.logger.Errorw("cannot poll file", zap.String("filed", file), zap.Error(err))
 		}
@@ -126,3 +146,26 @@ func (p *poller) poll() {
 		}
 	}
 }
+
+// readDirEntries returns the names, sizes and modification times of the
+// files at a directory.
+func readDirEntries(path string) ([]byte, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// Entries removed while listing.
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hops limits the number of times events can be forwarded by
+// brokers, which prevents events from looping indefinitely between brokers
+// and targets.
+package hops
+
+import (
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// Extension is the CloudEvents extension that informs the number of hops
+// remaining for the event, named as the one Knative brokers use.
+const Extension = "knativebrokerttl"
+
+// DefaultMax is the number of hops of events that do not inform them, as
+// Knative brokers do.
+const DefaultMax = 255
+
+// Remaining returns the number of hops remaining for the event, false if it
+// does not inform a valid one.
+func Remaining(event *cloudevents.Event) (int32, bool) {
+	v, ok := event.Extensions()[Extension]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := types.ToInteger(v)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Set informs the number of hops remaining for the event.
+func Set(event *cloudevents.Event, n int32) error {
+	return event.Context.SetExtension(Extension, n)
+}
+
+// Inherit sets the hops remaining for the reply as those of the event it
+// replies to minus one, starting from max when the event does not inform
+// them. Returns false if no hops remain for the reply, which must then be
+// discarded.
+func Inherit(event, reply *cloudevents.Event, max int32) (bool, error) {
+	n, ok := Remaining(event)
+	if !ok || n > max {
+		n = max
+	}
+	n--
+
+	if err := Set(reply, n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package hops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestInherit(t *testing.T) {
+	ev := lib.NewCloudEvent()
+	_, ok := Remaining(&ev)
+	assert.False(t, ok)
+
+	// Events that do not inform hops start from the maximum.
+	reply := lib.NewCloudEvent()
+	ok, err := Inherit(&ev, &reply, 3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	n, _ := Remaining(&reply)
+	assert.Equal(t, int32(2), n)
+
+	next := lib.NewCloudEvent()
+	ok, err = Inherit(&reply, &next, 3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	last := lib.NewCloudEvent()
+	ok, err = Inherit(&next, &last, 3)
+	require.NoError(t, err)
+	assert.False(t, ok, "Replies without hops remaining must be discarded")
+
+	// Events cannot inform more hops than the maximum.
+	require.NoError(t, ev.Context.SetExtension(Extension, "1000"))
+	ok, err = Inherit(&ev, &reply, 3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	n, _ = Remaining(&reply)
+	assert.Equal(t, int32(2), n)
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package integrity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// HashAttribute is the CloudEvents extension that contains the
+	// content hash of the event.
+	HashAttribute = "triggermeshhash"
+
+	// Extensions with this prefix are used internally by the broker
+	// and are not part of the hashed content.
+	internalExtensionPrefix = "triggermesh"
+
+	hashPrefix = "sha256:"
+)
+
+var (
+	ErrMissingHash  = errors.New("event does not contain a content hash")
+	ErrHashMismatch = errors.New("event content hash does not match")
+)
+
+// Hash computes a deterministic hash of the event attributes, extensions and
+// data. Extensions used internally by the broker are not considered.
+func Hash(event *cloudevents.Event) (string, error) {
+	h := sha256.New()
+
+	writeField(h, event.SpecVersion())
+	writeField(h, event.ID())
+	writeField(h, event.Source())
+	writeField(h, event.Type())
+	writeField(h, event.Subject())
+	writeField(h, event.DataContentType())
+	writeField(h, event.DataSchema())
+	if t := event.Time(); !t.IsZero() {
+		writeField(h, t.UTC().Format(time.RFC3339Nano))
+	} else {
+		writeField(h, "")
+	}
+
+	exts := event.Extensions()
+	names := make([]string, 0, len(exts))
+	for name := range exts {
+		if strings.HasPrefix(name, internalExtensionPrefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v, err := types.Format(exts[name])
+		if err != nil {
+			return "", fmt.Errorf("could not format extension %q: %w", name, err)
+		}
+		writeField(h, name)
+		writeField(h, v)
+	}
+
+	data := event.Data()
+	// JSON data might be re-encoded by backends, compact it to make the hash
+	// independent of formatting.
+	if isJSON(event.DataContentType()) && len(data) != 0 {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, data); err == nil {
+			data = buf.Bytes()
+		}
+	}
+	writeBytes(h, data)
+
+	return hashPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Sign sets the content hash extension at the event.
+func Sign(event *cloudevents.Event) error {
+	hs, err := Hash(event)
+	if err != nil {
+		return err
+	}
+
+	return event.Context.SetExtension(HashAttribute, hs)
+}
+
+// Verify checks that the content hash extension at the event matches
+// the event contents.
+func Verify(event *cloudevents.Event) error {
+	v, ok := event.Extensions()[HashAttribute]
+	if !ok {
+		return ErrMissingHash
+	}
+
+	expected, err := types.ToString(v)
+	if err != nil {
+		return fmt.Errorf("could not read content hash: %w", err)
+	}
+
+	hs, err := Hash(event)
+	if err != nil {
+		return err
+	}
+
+	if hs != expected {
+		return ErrHashMismatch
+	}
+
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "" ||
+		strings.HasPrefix(contentType, cloudevents.ApplicationJSON) ||
+		strings.HasPrefix(contentType, "text/json") ||
+		strings.Contains(contentType, "+json")
+}
+
+// writeField writes a length prefixed field to avoid ambiguity between
+// concatenated values.
+func writeField(h hash.Hash, v string) {
+	writeBytes(h, []byte(v))
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(b)))
+	_, _ = h.Write(l[:])
+	_, _ = h.Write(b)
+}
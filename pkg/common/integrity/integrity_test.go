@@ -0,0 +1,57 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package integrity
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestVerify(t *testing.T) {
+	newEvent := func() *cloudevents.Event {
+		e := lib.NewCloudEvent(
+			lib.CloudEventWithIDOption("id1"),
+			lib.CloudEventWithExtensionOption("ext1", "val1"))
+		require.NoError(t, e.SetData(cloudevents.ApplicationJSON, map[string]interface{}{"hello": "world"}))
+		return &e
+	}
+
+	t.Run("missing hash", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(newEvent()), ErrMissingHash)
+	})
+
+	t.Run("serialization roundtrip", func(t *testing.T) {
+		e := newEvent()
+		require.NoError(t, Sign(e))
+
+		b, err := e.MarshalJSON()
+		require.NoError(t, err)
+
+		re := &cloudevents.Event{}
+		require.NoError(t, re.UnmarshalJSON(b))
+
+		// Internal extensions are not part of the hash.
+		re.SetExtension("triggermeshbackendid", "1-0")
+		assert.NoError(t, Verify(re))
+	})
+
+	t.Run("tampered data", func(t *testing.T) {
+		e := newEvent()
+		require.NoError(t, Sign(e))
+		require.NoError(t, e.SetData(cloudevents.ApplicationJSON, map[string]interface{}{"hello": "broker"}))
+		assert.ErrorIs(t, Verify(e), ErrHashMismatch)
+	})
+
+	t.Run("tampered extension", func(t *testing.T) {
+		e := newEvent()
+		require.NoError(t, Sign(e))
+		e.SetExtension("ext1", "val2")
+		assert.ErrorIs(t, Verify(e), ErrHashMismatch)
+	})
+}
@@ -22,15 +22,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/triggermesh/brokers/pkg/common/secret"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+	"github.com/triggermesh/brokers/pkg/status"
 )
 
 type SecretBrokerConfigCallback func(*cfgbroker.Config)
 
 type Manager struct {
-	manager manager.Manager
-	rs      *reconcileBrokerConfigSecret
-	rcm     *reconcileObservabilityConfigMap
+	manager   manager.Manager
+	namespace string
+	rs        *reconcileBrokerConfigSecret
+	rcm       *reconcileObservabilityConfigMap
 
 	logger *zap.SugaredLogger
 }
@@ -49,8 +53,9 @@ func NewManager(namespace string, logger *zap.SugaredLogger) (*Manager, error) {
 	}
 
 	return &Manager{
-		manager: mgr,
-		logger:  logger,
+		manager:   mgr,
+		namespace: namespace,
+		logger:    logger,
 	}, nil
 }
 
@@ -85,6 +90,43 @@ func (m *Manager) AddSecretCallbackForBrokerConfig(cb SecretBrokerConfigCallback
 	m.rs.cbs = append(m.rs.cbs, cb)
 }
 
+// StrictBrokerConfig validates broker configurations before applying them,
+// invalid configurations are not applied.
+func (m *Manager) StrictBrokerConfig(v cfgbroker.Validator) {
+	m.rs.validator = v
+}
+
+// BrokerConfigStore returns a store that writes the broker configuration
+// to the Secret set up for the broker configuration controller.
+func (m *Manager) BrokerConfigStore() store.ConfigStore {
+	return &secretConfigStore{
+		namespace: m.namespace,
+		name:      m.rs.name,
+		key:       m.rs.key,
+		client:    m.manager.GetClient(),
+	}
+}
+
+// BrokerStatusWriter returns a writer that annotates the Secret set up for
+// the broker configuration controller with the status document.
+func (m *Manager) BrokerStatusWriter() status.Writer {
+	return &secretStatusWriter{
+		namespace: m.namespace,
+		name:      m.rs.name,
+		client:    m.manager.GetClient(),
+	}
+}
+
+// SecretReader returns a reader for keys of Secrets at the broker
+// namespace, which are served from the manager cache and kept up to date
+// as Secrets change.
+func (m *Manager) SecretReader() secret.KubernetesReader {
+	return &secretKeyReader{
+		namespace: m.namespace,
+		client:    m.manager.GetClient(),
+	}
+}
+
 func (m *Manager) AddConfigMapControllerForObservability(name string) error {
 	m.logger.Info("Setting up ConfigMap controller for observability")
 	m.rcm = &reconcileObservabilityConfigMap{
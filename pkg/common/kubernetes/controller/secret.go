@@ -5,7 +5,9 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 
 	"go.uber.org/zap"
 
@@ -15,16 +17,26 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+	"github.com/triggermesh/brokers/pkg/status"
 )
 
+// StatusAnnotation is the annotation at the broker configuration Secret
+// that contains the status document.
+const StatusAnnotation = "eventing.triggermesh.io/broker-status"
+
 // reconcileSecret reconciles the Secret.
 type reconcileBrokerConfigSecret struct {
 	name string
 	key  string
 	cbs  []SecretBrokerConfigCallback
 
+	// Configurations must pass the validator to be applied when strict.
+	validator cfgbroker.Validator
+
 	client client.Client
 	logger *zap.SugaredLogger
 }
@@ -57,14 +69,116 @@ func (r *reconcileBrokerConfigSecret) Reconcile(ctx context.Context, request rec
 		return reconcile.Result{}, nil
 	}
 
-	cfg, err := cfgbroker.Parse(string(content))
+	cfg, err := cfgbroker.ParseFile(r.key, string(content))
 	if err != nil {
 		return reconcile.Result{}, fmt.Errorf("error parsing config from secret %q: %w", s.Name, err)
 	}
 
+	if r.validator != nil {
+		if err := r.validator(cfg); err != nil {
+			// Requeuing would not fix the configuration, which is applied
+			// when the Secret is updated.
+			r.logger.Errorw("Config from Secret not applied", zap.String("name", s.Name), zap.Error(err),
+				zap.Any("errors", cfgbroker.ValidationErrors(err)))
+			return reconcile.Result{}, nil
+		}
+	}
+
 	for _, cb := range r.cbs {
 		cb(cfg)
 	}
 
 	return reconcile.Result{}, nil
 }
+
+// secretConfigStore writes the broker configuration to the Secret key.
+type secretConfigStore struct {
+	namespace string
+	name      string
+	key       string
+
+	client client.Client
+}
+
+func (s *secretConfigStore) Write(cfg *cfgbroker.Config) error {
+	// Configuration scripts cannot be generated from the configuration.
+	if filepath.Ext(s.key) == cfgbroker.StarlarkExtension {
+		return store.ErrReadOnly
+	}
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("could not serialize broker configuration: %w", err)
+	}
+
+	ctx := context.Background()
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: s.name}, secret); err != nil {
+		return fmt.Errorf("could not fetch Secret: %w", err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[s.key] = b
+
+	if err := s.client.Update(ctx, secret); err != nil {
+		return fmt.Errorf("could not update Secret: %w", err)
+	}
+
+	return nil
+}
+
+// secretStatusWriter writes the status document as an annotation
+// of the broker configuration Secret.
+type secretStatusWriter struct {
+	namespace string
+	name      string
+
+	client client.Client
+}
+
+func (s *secretStatusWriter) Write(ctx context.Context, st *status.Status) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("could not serialize status: %w", err)
+	}
+
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: s.name}, secret); err != nil {
+		return fmt.Errorf("could not fetch Secret: %w", err)
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[StatusAnnotation] = string(b)
+
+	if err := s.client.Patch(ctx, secret, patch); err != nil {
+		return fmt.Errorf("could not annotate Secret: %w", err)
+	}
+
+	return nil
+}
+
+// secretKeyReader reads keys of Secrets referenced by the broker
+// configuration.
+type secretKeyReader struct {
+	namespace string
+
+	client client.Client
+}
+
+func (s *secretKeyReader) Read(ctx context.Context, name, key string) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("could not fetch Secret: %w", err)
+	}
+
+	v, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found at Secret", key)
+	}
+	return v, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/config/broker/store"
+)
+
+func TestSecretConfigStore(t *testing.T) {
+	tcs := map[string]struct {
+		key     string
+		isError error
+	}{
+		"yaml key": {
+			key: "config.yaml",
+		},
+		"starlark key": {
+			key:     "config.star",
+			isError: store.ErrReadOnly,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "broker"},
+				Data:       map[string][]byte{tc.key: []byte("original")},
+			}
+			c := fake.NewClientBuilder().WithObjects(secret).Build()
+
+			s := &secretConfigStore{namespace: "ns", name: "broker", key: tc.key, client: c}
+			err := s.Write(&cfgbroker.Config{})
+
+			updated := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "broker"}, updated))
+
+			if tc.isError != nil {
+				assert.ErrorIs(t, err, tc.isError)
+				assert.Equal(t, "original", string(updated.Data[tc.key]), "Configuration scripts must not be overwritten")
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEqual(t, "original", string(updated.Data[tc.key]))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logging adds log destinations to zap, which can be informed as
+// output paths at the logger configuration.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// RotateFileScheme is the scheme of output paths for files that are
+	// rotated when reaching a size, as in
+	// rotatefile:///var/log/broker.log?maxsize=100&maxbackups=3
+	RotateFileScheme = "rotatefile"
+
+	// SyslogScheme is the scheme of output paths for syslog, either local
+	// as in syslog:// or remote as in syslog://host:514?network=tcp.
+	// Facility and tag can be informed as query parameters.
+	SyslogScheme = "syslog"
+)
+
+var (
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// RegisterSinks registers the log destinations of this package at zap. It
+// must be called before building loggers that use them.
+func RegisterSinks() error {
+	registerOnce.Do(func() {
+		if err := zap.RegisterSink(RotateFileScheme, newRotateFileSink); err != nil {
+			registerErr = err
+			return
+		}
+		registerErr = zap.RegisterSink(SyslogScheme, newSyslogSink)
+	})
+
+	return registerErr
+}
+
+// OutputPath returns the zap output path for the log output informed to the
+// broker, which can be stdout, stderr, a file path prefixed with file://,
+// which is rotated when exceeding maxSize megabytes keeping maxBackups, or
+// a syslog:// URL.
+func OutputPath(output string, maxSize, maxBackups int) (string, error) {
+	if output == "stdout" || output == "stderr" {
+		return output, nil
+	}
+
+	u, err := url.Parse(output)
+	if err != nil {
+		return "", fmt.Errorf("log output %q cannot be parsed: %w", output, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" || u.Host != "" {
+			return "", errors.New("log output files must be informed as absolute paths prefixed with file://")
+		}
+
+		q := url.Values{}
+		q.Set("maxsize", strconv.Itoa(maxSize))
+		q.Set("maxbackups", strconv.Itoa(maxBackups))
+		return (&url.URL{Scheme: RotateFileScheme, Path: u.Path, RawQuery: q.Encode()}).String(), nil
+
+	case SyslogScheme:
+		return output, nil
+	}
+
+	return "", errors.New("log output must be stdout, stderr, a file:// path or a syslog:// URL")
+}
+
+// intParam returns the integer query parameter, or the default if not
+// informed.
+func intParam(q url.Values, name string, def int) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s must be a non negative integer: %q", name, v)
+	}
+	return i, nil
+}
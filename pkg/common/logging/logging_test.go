@@ -0,0 +1,103 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOutputPath(t *testing.T) {
+	testCases := map[string]struct {
+		output string
+		path   string
+		err    bool
+	}{
+		"stdout": {
+			output: "stdout",
+			path:   "stdout",
+		},
+		"file": {
+			output: "file:///var/log/broker.log",
+			path:   "rotatefile:///var/log/broker.log?maxbackups=3&maxsize=10",
+		},
+		"relative file": {
+			output: "file://broker.log",
+			err:    true,
+		},
+		"syslog": {
+			output: "syslog://localhost:514?facility=local0",
+			path:   "syslog://localhost:514?facility=local0",
+		},
+		"unknown": {
+			output: "/var/log/broker.log",
+			err:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path, err := OutputPath(tc.output, 10, 3)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestRotateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.log")
+
+	r, err := openRotateFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"entry 1\n", "entry 2\n", "entry 3\n", "entry 4\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// Each entry exceeds the remaining size, older backups are dropped.
+	assert.Equal(t, "entry 4\n", read(path))
+	assert.Equal(t, "entry 3\n", read(path+".1"))
+	assert.Equal(t, "entry 2\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestRegisterSinks(t *testing.T) {
+	require.NoError(t, RegisterSinks())
+	require.NoError(t, RegisterSinks(), "Registering sinks more than once must succeed")
+
+	dir := t.TempDir()
+	output, err := OutputPath("file://"+filepath.Join(dir, "broker.log"), 1, 1)
+	require.NoError(t, err)
+
+	cfg := zap.NewProductionConfig()
+	cfg.Encoding = "console"
+	cfg.OutputPaths = []string{output}
+	l, err := cfg.Build()
+	require.NoError(t, err)
+
+	l.Info("hello")
+	require.NoError(t, l.Sync())
+
+	b, err := os.ReadFile(filepath.Join(dir, "broker.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "\tinfo\t")
+	assert.Contains(t, string(b), "\thello\n")
+}
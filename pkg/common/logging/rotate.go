@@ -0,0 +1,137 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 3
+)
+
+// rotateFile writes to a file that is renamed to <path>.1 when exceeding
+// its maximum size, shifting previous backups up to the maximum number of
+// backups. A zero maximum size disables rotation.
+type rotateFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+	m    sync.Mutex
+}
+
+func newRotateFileSink(u *url.URL) (zap.Sink, error) {
+	if u.Host != "" || u.Path == "" {
+		return nil, fmt.Errorf("rotated log files must be informed as absolute paths: %q", u.String())
+	}
+
+	q := u.Query()
+	maxSize, err := intParam(q, "maxsize", defaultMaxSizeMB)
+	if err != nil {
+		return nil, err
+	}
+	maxBackups, err := intParam(q, "maxbackups", defaultMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return openRotateFile(u.Path, int64(maxSize)<<20, maxBackups)
+}
+
+func openRotateFile(path string, maxSize int64, maxBackups int) (*rotateFile, error) {
+	r := &rotateFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotateFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not read log file size: %w", err)
+	}
+
+	r.f = f
+	r.size = fi.Size()
+	return nil
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate must be called holding the lock.
+func (r *rotateFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("could not close log file: %w", err)
+	}
+
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not remove log file: %w", err)
+		}
+		return r.open()
+	}
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(backupPath(r.path, i), backupPath(r.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, backupPath(r.path, 1)); err != nil {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+
+	return r.open()
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func (r *rotateFile) Sync() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.f.Sync()
+}
+
+func (r *rotateFile) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.f.Close()
+}
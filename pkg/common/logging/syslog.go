@@ -0,0 +1,80 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var facilities = map[string]syslog.Priority{
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// syslogSink sends each log entry as a syslog message. Entries are sent
+// with informational severity, their level being part of the entry.
+type syslogSink struct {
+	*syslog.Writer
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	// The syslog writer adds the trailing new line when missing.
+	if _, err := s.Writer.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *syslogSink) Sync() error {
+	return nil
+}
+
+func newSyslogSink(u *url.URL) (zap.Sink, error) {
+	q := u.Query()
+
+	facility := syslog.LOG_USER
+	if f := q.Get("facility"); f != "" {
+		var ok bool
+		if facility, ok = facilities[strings.ToLower(f)]; !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", f)
+		}
+	}
+
+	tag := q.Get("tag")
+	if tag == "" {
+		tag = "broker"
+	}
+
+	// Local syslog is used when no host is informed.
+	network := ""
+	if u.Host != "" {
+		network = q.Get("network")
+		if network == "" {
+			network = "udp"
+		}
+	}
+
+	w, err := syslog.Dial(network, u.Host, facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to syslog: %w", err)
+	}
+
+	return &syslogSink{Writer: w}, nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+func newSyslogSink(*url.URL) (zap.Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package provenance keeps the chain of broker instances events traverse.
+package provenance
+
+import (
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// Extension is the CloudEvents extension that lists the identities of the
+// broker instances the event was ingested by, separated by commas, from
+// the oldest to the most recent.
+const Extension = "triggermeshprovenance"
+
+const separator = ","
+
+// Chain returns the identities of the broker instances the event was
+// ingested by, from the oldest to the most recent.
+func Chain(event *cloudevents.Event) []string {
+	v, ok := event.Extensions()[Extension]
+	if !ok {
+		return nil
+	}
+
+	s, err := types.Format(v)
+	if err != nil || s == "" {
+		return nil
+	}
+
+	return strings.Split(s, separator)
+}
+
+// Append adds the broker identity to the event chain, keeping only the
+// most recent maxLength identities when maxLength is greater than zero.
+// Returns true if the identity was already part of the chain, which means
+// the event is looping between brokers.
+func Append(event *cloudevents.Event, identity string, maxLength int) (bool, error) {
+	identity = strings.ReplaceAll(identity, separator, "_")
+
+	chain := Chain(event)
+	looped := false
+	for _, id := range chain {
+		if id == identity {
+			looped = true
+			break
+		}
+	}
+
+	chain = append(chain, identity)
+	if maxLength > 0 && len(chain) > maxLength {
+		chain = chain[len(chain)-maxLength:]
+	}
+
+	return looped, event.Context.SetExtension(Extension, strings.Join(chain, separator))
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestAppend(t *testing.T) {
+	ev := lib.NewCloudEvent()
+	assert.Nil(t, Chain(&ev))
+
+	for _, id := range []string{"edge-1", "region-a", "central"} {
+		looped, err := Append(&ev, id, 0)
+		require.NoError(t, err)
+		assert.False(t, looped)
+	}
+	assert.Equal(t, []string{"edge-1", "region-a", "central"}, Chain(&ev))
+
+	// Events coming back to a broker are reported, and only the most
+	// recent identities are kept.
+	looped, err := Append(&ev, "region-a", 3)
+	require.NoError(t, err)
+	assert.True(t, looped)
+	assert.Equal(t, []string{"region-a", "central", "region-a"}, Chain(&ev))
+
+	_, err = Append(&ev, "a,b", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "region-a,central,region-a,a_b", ev.Extensions()[Extension])
+}
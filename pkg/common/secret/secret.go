@@ -0,0 +1,140 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package secret reads the secrets the broker configuration references
+// instead of informing their values.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Time to wait for the value of Kubernetes Secret keys.
+const kubernetesReadTimeout = 5 * time.Second
+
+// KubernetesReader reads keys of Kubernetes Secrets at the broker
+// namespace.
+type KubernetesReader interface {
+	Read(ctx context.Context, name, key string) ([]byte, error)
+}
+
+var (
+	kubernetes KubernetesReader
+	km         sync.RWMutex
+)
+
+// SetKubernetesReader sets the reader for keys of Kubernetes Secrets, which
+// cannot be referenced until it is set.
+func SetKubernetesReader(r KubernetesReader) {
+	km.Lock()
+	defer km.Unlock()
+	kubernetes = r
+}
+
+func kubernetesReader() KubernetesReader {
+	km.RLock()
+	defer km.RUnlock()
+	return kubernetes
+}
+
+// Secret reads its value from an environment variable once, from a file
+// whenever its modification time changes, or from a Kubernetes Secret
+// every time, relying on the reader to cache Secrets.
+type Secret struct {
+	file string
+	ref  *cfgbroker.SecretKeySelector
+	kr   KubernetesReader
+
+	v       string
+	modTime time.Time
+	m       sync.Mutex
+}
+
+// New returns the secret for the source, failing if its value cannot be
+// read.
+func New(src *cfgbroker.SecretSource) (*Secret, error) {
+	switch {
+	case src.Env != nil && *src.Env != "":
+		v, ok := os.LookupEnv(*src.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", *src.Env)
+		}
+		return &Secret{v: v}, nil
+
+	case src.File != nil && *src.File != "":
+		s := &Secret{file: *src.File}
+		if _, err := s.Value(); err != nil {
+			return nil, err
+		}
+		return s, nil
+
+	case src.Secret != nil:
+		kr := kubernetesReader()
+		if kr == nil {
+			return nil, errors.New("kubernetes secrets can only be referenced when the broker is configured using a Kubernetes Secret")
+		}
+		s := &Secret{ref: src.Secret, kr: kr}
+		if _, err := s.Value(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	return nil, errors.New("secret source is not informed")
+}
+
+// Read returns the current value of the source.
+func Read(src *cfgbroker.SecretSource) (string, error) {
+	s, err := New(src)
+	if err != nil {
+		return "", err
+	}
+	return s.Value()
+}
+
+// Value returns the current value of the secret. Surrounding whitespace is
+// trimmed from files and Kubernetes Secrets.
+func (s *Secret) Value() (string, error) {
+	switch {
+	case s.file != "":
+		return s.fileValue()
+	case s.ref != nil:
+		ctx, cancel := context.WithTimeout(context.Background(), kubernetesReadTimeout)
+		defer cancel()
+
+		b, err := s.kr.Read(ctx, s.ref.Name, s.ref.Key)
+		if err != nil {
+			return "", fmt.Errorf("could not read key %s of Kubernetes Secret %s: %w", s.ref.Key, s.ref.Name, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return s.v, nil
+}
+
+func (s *Secret) fileValue() (string, error) {
+	fi, err := os.Stat(s.file)
+	if err != nil {
+		return "", fmt.Errorf("could not read secret file: %w", err)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !fi.ModTime().Equal(s.modTime) {
+		b, err := os.ReadFile(s.file)
+		if err != nil {
+			return "", fmt.Errorf("could not read secret file: %w", err)
+		}
+		s.v = strings.TrimSpace(string(b))
+		s.modTime = fi.ModTime()
+	}
+	return s.v, nil
+}
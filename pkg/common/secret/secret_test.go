@@ -0,0 +1,92 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakeKubernetesReader map[string]string
+
+func (f fakeKubernetesReader) Read(_ context.Context, name, key string) ([]byte, error) {
+	v, ok := f[name+"/"+key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(v), nil
+}
+
+func TestSecret(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		env := "TEST_SECRET_ENV"
+		t.Setenv(env, "token")
+
+		v, err := Read(&cfgbroker.SecretSource{Env: &env})
+		require.NoError(t, err)
+		assert.Equal(t, "token", v)
+
+		missing := "TEST_SECRET_MISSING"
+		_, err = Read(&cfgbroker.SecretSource{Env: &missing})
+		assert.ErrorContains(t, err, "environment variable TEST_SECRET_MISSING is not set")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(file, []byte("first\n"), 0o600))
+
+		s, err := New(&cfgbroker.SecretSource{File: &file})
+		require.NoError(t, err)
+		v, err := s.Value()
+		require.NoError(t, err)
+		assert.Equal(t, "first", v)
+
+		// Rotated files are read again.
+		require.NoError(t, os.WriteFile(file, []byte("second\n"), 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(file, later, later))
+		v, err = s.Value()
+		require.NoError(t, err)
+		assert.Equal(t, "second", v)
+
+		require.NoError(t, os.Remove(file))
+		_, err = s.Value()
+		assert.Error(t, err)
+	})
+
+	t.Run("kubernetes", func(t *testing.T) {
+		ref := &cfgbroker.SecretKeySelector{Name: "credentials", Key: "token"}
+
+		SetKubernetesReader(nil)
+		_, err := New(&cfgbroker.SecretSource{Secret: ref})
+		assert.ErrorContains(t, err, "kubernetes secrets can only be referenced")
+
+		kr := fakeKubernetesReader{"credentials/token": "first\n"}
+		SetKubernetesReader(kr)
+		t.Cleanup(func() { SetKubernetesReader(nil) })
+
+		s, err := New(&cfgbroker.SecretSource{Secret: ref})
+		require.NoError(t, err)
+		v, err := s.Value()
+		require.NoError(t, err)
+		assert.Equal(t, "first", v)
+
+		kr["credentials/token"] = "second"
+		v, err = s.Value()
+		require.NoError(t, err)
+		assert.Equal(t, "second", v, "Secrets must be read again")
+
+		_, err = Read(&cfgbroker.SecretSource{Secret: &cfgbroker.SecretKeySelector{Name: "credentials", Key: "missing"}})
+		assert.ErrorContains(t, err, "could not read key missing of Kubernetes Secret credentials")
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package urltemplate resolves URLs that contain segments named after
+// CloudEvent attributes, as in https://svc/{type}/{subject}.
+package urltemplate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type segment struct {
+	literal   string
+	attribute string
+	query     bool
+}
+
+// Template is a parsed URL template.
+type Template struct {
+	segments []segment
+}
+
+// IsTemplate returns true if the URL contains templated segments.
+func IsTemplate(u string) bool {
+	return strings.ContainsAny(u, "{}")
+}
+
+// Parse the URL template. Templated segments are attribute names, which
+// consist of lowercase letters and digits, between curly braces.
+func Parse(u string) (*Template, error) {
+	t := &Template{}
+	query := false
+	rest := u
+
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open == -1 {
+			t.segments = append(t.segments, segment{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, errors.New("templated segment closed without being opened")
+		}
+
+		if open > 0 {
+			literal := rest[:open]
+			t.segments = append(t.segments, segment{literal: literal})
+			query = query || strings.Contains(literal, "?")
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end == -1 {
+			return nil, errors.New("templated segment is not closed")
+		}
+
+		name := rest[open+1 : open+end]
+		if !isAttributeName(name) {
+			return nil, fmt.Errorf("templated segment %q is not a CloudEvent attribute name", name)
+		}
+		t.segments = append(t.segments, segment{attribute: name, query: query})
+
+		rest = rest[open+end+1:]
+	}
+
+	// Attributes are replaced with a placeholder to validate the URL.
+	sample := t.Resolve(func(string) (string, bool) { return "x", true })
+	pu, err := url.Parse(sample)
+	if err != nil {
+		return nil, err
+	}
+	if pu.Scheme == "" || pu.Host == "" {
+		return nil, errors.New("templated URL must inform scheme and host")
+	}
+
+	return t, nil
+}
+
+// Attributes returns the names of the attributes referenced by the template.
+func (t *Template) Attributes() []string {
+	attrs := []string{}
+	for _, s := range t.segments {
+		if s.attribute != "" {
+			attrs = append(attrs, s.attribute)
+		}
+	}
+	return attrs
+}
+
+// Resolve the URL escaping the attribute values returned by the lookup
+// function, which reports whether the attribute is informed. Returns an
+// empty string if any attribute is missing or empty.
+func (t *Template) Resolve(lookup func(attribute string) (string, bool)) string {
+	var sb strings.Builder
+	for _, s := range t.segments {
+		if s.attribute == "" {
+			sb.WriteString(s.literal)
+			continue
+		}
+
+		v, ok := lookup(s.attribute)
+		if !ok || v == "" {
+			return ""
+		}
+
+		if s.query {
+			sb.WriteString(url.QueryEscape(v))
+		} else {
+			sb.WriteString(url.PathEscape(v))
+		}
+	}
+
+	return sb.String()
+}
+
+func isAttributeName(name string) bool {
+	if name == "" || len(name) > 20 {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
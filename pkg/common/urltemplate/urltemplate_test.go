@@ -0,0 +1,63 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package urltemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		url string
+		err bool
+	}{
+		"path segments":      {url: "https://svc/{type}/{subject}"},
+		"host and query":     {url: "https://{tenant}.example.com/events?source={source}"},
+		"not closed":         {url: "https://svc/{type", err: true},
+		"not opened":         {url: "https://svc/type}", err: true},
+		"not an attribute":   {url: "https://svc/{Type}", err: true},
+		"empty attribute":    {url: "https://svc/{}", err: true},
+		"missing host":       {url: "/{type}", err: true},
+		"templated the port": {url: "https://svc:{port}/", err: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.url)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tpl, err := Parse("https://{tenant}.example.com/{type}/{subject}?source={source}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant", "type", "subject", "source"}, tpl.Attributes())
+
+	attrs := map[string]string{
+		"tenant":  "acme",
+		"type":    "order.created",
+		"subject": "orders/1 2",
+		"source":  "https://shop/a&b",
+	}
+	lookup := func(a string) (string, bool) {
+		v, ok := attrs[a]
+		return v, ok
+	}
+
+	assert.Equal(t, "https://acme.example.com/order.created/orders%2F1%202?source=https%3A%2F%2Fshop%2Fa%26b", tpl.Resolve(lookup))
+
+	attrs["subject"] = ""
+	assert.Empty(t, tpl.Resolve(lookup), "Empty attributes are considered missing")
+
+	delete(attrs, "subject")
+	assert.Empty(t, tpl.Resolve(lookup))
+}
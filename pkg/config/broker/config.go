@@ -5,7 +5,10 @@ package broker
 
 import (
 	"context"
+	"errors"
+	"sort"
 
+	"knative.dev/pkg/apis"
 	"sigs.k8s.io/yaml"
 )
 
@@ -21,3 +24,46 @@ func Parse(config string) (*Config, error) {
 
 	return c, nil
 }
+
+// Validator checks a parsed configuration before it is applied, returning
+// an error if it must not be.
+type Validator func(*Config) error
+
+// ValidationError is an error found at a field of the configuration.
+type ValidationError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+// ValidationErrors returns the error found at each field for validation
+// errors sorted by field, or a single error without field otherwise, like
+// those returned when the configuration cannot be parsed.
+func ValidationErrors(err error) []ValidationError {
+	if err == nil {
+		return nil
+	}
+
+	var fe *apis.FieldError
+	if !errors.As(err, &fe) {
+		return []ValidationError{{Message: err.Error()}}
+	}
+
+	var errs []ValidationError
+	for _, e := range fe.WrappedErrors() {
+		if len(e.Paths) == 0 {
+			errs = append(errs, ValidationError{Message: e.Message, Details: e.Details})
+		}
+
+		// Equal errors found at different fields are merged.
+		for _, p := range e.Paths {
+			errs = append(errs, ValidationError{
+				Field:   p,
+				Message: e.Message,
+				Details: e.Details,
+			})
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
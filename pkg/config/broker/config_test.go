@@ -4,6 +4,8 @@
 package broker
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -34,3 +36,95 @@ triggers:
 		})
 	}
 }
+
+func TestParseStarlark(t *testing.T) {
+	script := `
+def trigger(team):
+    return {
+        "filters": [{"exact": {"type": team + ".event"}}],
+        "target": {
+            "url": "http://" + team + ".svc",
+            "deliveryOptions": {"retry": 3, "backoffDelay": "PT1S", "backoffPolicy": "linear"},
+        },
+    }
+
+config = {"triggers": {"trigger-" + t: trigger(t) for t in ["sales", "billing"]}}
+`
+
+	c, err := ParseFile("broker.star", script)
+	require.NoError(t, err)
+	require.Len(t, c.Triggers, 2)
+
+	tr, ok := c.Triggers["trigger-billing"]
+	require.True(t, ok)
+	require.Equal(t, "http://billing.svc", *tr.Target.URL)
+	require.Equal(t, "billing.event", tr.Filters[0].Exact["type"])
+	require.Equal(t, int32(3), *tr.Target.DeliveryOptions.Retry)
+
+	_, err = ParseFile("broker.star", `triggers = {}`)
+	require.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	base, err := Parse(`
+ingest:
+  user: base
+triggers:
+  orders:
+    target:
+      url: http://orders.base
+  billing:
+    target:
+      url: http://billing.base
+features:
+  tracing: true
+`)
+	require.NoError(t, err)
+
+	team, err := Parse(`
+triggers:
+  billing:
+    target:
+      url: http://billing.team
+  shipping:
+    target:
+      url: http://shipping.team
+features:
+  tracing: false
+`)
+	require.NoError(t, err)
+
+	c, overridden := Merge(base, nil, team)
+	require.Equal(t, []string{"features[tracing]", "triggers[billing]"}, overridden)
+	require.Equal(t, "base", c.Ingest.User, "Sections not informed by later sources must be kept")
+	require.Len(t, c.Triggers, 3)
+	require.Equal(t, "http://orders.base", *c.Triggers["orders"].Target.URL)
+	require.Equal(t, "http://billing.team", *c.Triggers["billing"].Target.URL, "Later sources must take precedence")
+	require.False(t, c.Features["tracing"])
+
+	require.Len(t, base.Triggers, 2, "Merged configurations must not be modified")
+
+	c, overridden = Merge(team)
+	require.Same(t, team, c)
+	require.Empty(t, overridden)
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"20-team.yaml", "10-team.star", ".hidden.yaml", "README.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), nil, 0o600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "30-nested.yaml"), 0o700))
+
+	files, err := ConfigFiles(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "10-team.star"), filepath.Join(dir, "20-team.yaml")}, files)
+
+	file := filepath.Join(dir, "README.md")
+	files, err = ConfigFiles(file)
+	require.NoError(t, err)
+	require.Equal(t, []string{file}, files, "Files must be returned regardless of their extension")
+
+	_, err = ConfigFiles(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
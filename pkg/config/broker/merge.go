@@ -0,0 +1,129 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configExtensions are the extensions of files read as configuration
+// sources when a directory is informed.
+var configExtensions = map[string]struct{}{
+	".yaml":           {},
+	".yml":            {},
+	".json":           {},
+	".conf":           {},
+	StarlarkExtension: {},
+}
+
+// ConfigFiles returns the configuration files for the path, which is the
+// path itself for files, and the configuration files in it sorted by name
+// for directories. Hidden files are skipped.
+func ConfigFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Directory entries are sorted by name.
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+		if _, ok := configExtensions[filepath.Ext(e.Name())]; ok {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// Merge merges the configurations in order, later configurations taking
+// precedence. Triggers, hosted brokers, feature flags and deleted triggers
+// are merged by name, while the ingest and registration sections are
+// replaced as a whole. The elements informed by more than one configuration
+// are returned sorted, using their field path.
+func Merge(configs ...*Config) (*Config, []string) {
+	if len(configs) == 1 {
+		return configs[0], nil
+	}
+
+	c := &Config{}
+	var overridden []string
+	for _, src := range configs {
+		if src == nil {
+			continue
+		}
+
+		if src.Ingest != nil {
+			if c.Ingest != nil {
+				overridden = append(overridden, "ingest")
+			}
+			c.Ingest = src.Ingest
+		}
+		if src.Registration != nil {
+			if c.Registration != nil {
+				overridden = append(overridden, "registration")
+			}
+			c.Registration = src.Registration
+		}
+
+		for k, t := range src.Triggers {
+			if _, ok := c.Triggers[k]; ok {
+				overridden = append(overridden, fieldKey("triggers", k))
+			}
+			if c.Triggers == nil {
+				c.Triggers = make(map[string]Trigger, len(src.Triggers))
+			}
+			c.Triggers[k] = t
+		}
+		for k, b := range src.Brokers {
+			if _, ok := c.Brokers[k]; ok {
+				overridden = append(overridden, fieldKey("brokers", k))
+			}
+			if c.Brokers == nil {
+				c.Brokers = make(map[string]Broker, len(src.Brokers))
+			}
+			c.Brokers[k] = b
+		}
+		for k, f := range src.Features {
+			if _, ok := c.Features[k]; ok {
+				overridden = append(overridden, fieldKey("features", k))
+			}
+			if c.Features == nil {
+				c.Features = make(map[string]bool, len(src.Features))
+			}
+			c.Features[k] = f
+		}
+		for k, d := range src.DeletedTriggers {
+			if _, ok := c.DeletedTriggers[k]; ok {
+				overridden = append(overridden, fieldKey("deletedTriggers", k))
+			}
+			if c.DeletedTriggers == nil {
+				c.DeletedTriggers = make(map[string]DeletedTrigger, len(src.DeletedTriggers))
+			}
+			c.DeletedTriggers[k] = d
+		}
+	}
+
+	sort.Strings(overridden)
+	return c, overridden
+}
+
+// fieldKey returns the path of the element of a configuration map, as
+// informed by validation errors.
+func fieldKey(field, key string) string {
+	return fmt.Sprintf("%s[%s]", field, key)
+}
@@ -5,8 +5,13 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 
@@ -21,64 +26,284 @@ type Poller struct {
 	path   string
 	logger *zap.SugaredLogger
 
+	// Overlay files and directories, merged in order over the
+	// configuration file.
+	overlays []string
+	// Configuration files found at each overlay directory.
+	entries map[string][]string
+
+	// Configuration parsed from each path, merged following the order of
+	// paths into the applied configuration.
+	configs map[string]*cfgbroker.Config
+	m       sync.Mutex
+
 	config *cfgbroker.Config
 	cbs    []PollerCallback
+
+	// Configurations must pass the validator to be applied when strict.
+	validator cfgbroker.Validator
+}
+
+// NewPoller polls the configuration file at path. Overlays are files,
+// or directories whose configuration files are polled, merged in order
+// over the configuration file. Files added to or removed from overlay
+// directories are merged or dropped when the directory changes.
+func NewPoller(fsp fs.Poller, path string, logger *zap.SugaredLogger, overlays ...string) (*Poller, error) {
+	cw := &Poller{
+		fsp:      fsp,
+		path:     path,
+		logger:   logger,
+		overlays: overlays,
+		entries:  make(map[string][]string),
+		configs:  make(map[string]*cfgbroker.Config),
+	}
+
+	for _, o := range overlays {
+		if err := checkAbsolute(o); err != nil {
+			return nil, err
+		}
+		files, err := cfgbroker.ConfigFiles(o)
+		if err != nil {
+			return nil, fmt.Errorf("error reading configuration overlay %q: %w", o, err)
+		}
+		if len(files) != 1 || files[0] != o {
+			cw.entries[o] = files
+		}
+	}
+
+	paths := cw.paths()
+	for i, p := range paths {
+		if err := checkAbsolute(p); err != nil {
+			return nil, err
+		}
+		for _, prev := range paths[:i] {
+			if prev == p {
+				return nil, fmt.Errorf("configuration path %q is informed more than once", p)
+			}
+		}
+	}
+
+	return cw, nil
 }
 
-func NewPoller(fsp fs.Poller, path string, logger *zap.SugaredLogger) (*Poller, error) {
+// paths returns the configuration file followed by the overlay files, in
+// the order they are merged.
+func (cw *Poller) paths() []string {
+	paths := []string{cw.path}
+	for _, o := range cw.overlays {
+		if files, ok := cw.entries[o]; ok {
+			paths = append(paths, files...)
+			continue
+		}
+		paths = append(paths, o)
+	}
+	return paths
+}
+
+func checkAbsolute(path string) error {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return nil, fmt.Errorf("error resolving to absolute path %q: %w", path, err)
+		return fmt.Errorf("error resolving to absolute path %q: %w", path, err)
 	}
 
 	if absPath != path {
-		return nil, fmt.Errorf("configuration path %q needs to be abstolute", path)
+		return fmt.Errorf("configuration path %q needs to be abstolute", path)
 	}
+	return nil
+}
 
-	return &Poller{
-		fsp:    fsp,
-		path:   path,
-		logger: logger,
-	}, nil
+// sortedConfigs returns the paths of the applied configurations.
+func (cw *Poller) sortedConfigs() []string {
+	paths := make([]string, 0, len(cw.configs))
+	for p := range cw.configs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 func (cw *Poller) AddCallback(cb PollerCallback) {
 	cw.cbs = append(cw.cbs, cb)
 }
 
+// Strict validates configurations before applying them. Invalid
+// configurations are not applied, and fail to start the poller when
+// found at start.
+func (cw *Poller) Strict(v cfgbroker.Validator) {
+	cw.validator = v
+}
+
 func (cw *Poller) GetConfig() *cfgbroker.Config {
 	return cw.config
 }
 
 func (cw *Poller) Start(ctx context.Context) error {
-	err := cw.fsp.Add(cw.path, cw.update)
-	if err != nil {
-		return err
+	sources := append([]string{cw.path}, cw.overlays...)
+	for _, p := range sources {
+		if _, ok := cw.entries[p]; ok {
+			// Files at overlay directories are read when the directory
+			// changes.
+			if err := cw.fsp.AddDir(p, cw.dirUpdater(p)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cw.fsp.Add(p, cw.updater(p)); err != nil {
+			return err
+		}
+	}
+
+	cw.m.Lock()
+	contents := make(map[string][]byte)
+	for _, p := range sources {
+		if _, ok := cw.entries[p]; ok {
+			for f, content := range cw.readDir(p) {
+				contents[f] = content
+			}
+			continue
+		}
+		if cfg, err := cw.fsp.GetContent(p); cfg != nil && err == nil {
+			contents[p] = cfg
+		}
 	}
 
-	if cfg, err := cw.fsp.GetContent(cw.path); cfg != nil && err == nil {
-		cw.update(cfg)
+	// Perform a first call to the callback with the contents of the config
+	// files. Otherwise the callback won't be called until a modification
+	// occurs.
+	err := cw.apply(contents)
+	cw.m.Unlock()
+	if err != nil && cw.validator != nil {
+		return err
 	}
 
 	cw.fsp.Start(ctx)
 	return nil
 }
 
-func (cw *Poller) update(content []byte) {
-	if len(content) == 0 {
-		// Discard file events that do not inform content.
-		cw.logger.Debug(fmt.Sprintf("Received event with empty contents for %s", cw.path))
-		return
+func (cw *Poller) updater(path string) fs.PollerCallback {
+	return func(content []byte) {
+		cw.m.Lock()
+		defer cw.m.Unlock()
+		_ = cw.apply(map[string][]byte{path: content})
+	}
+}
+
+func (cw *Poller) dirUpdater(dir string) fs.PollerCallback {
+	return func([]byte) {
+		cw.m.Lock()
+		defer cw.m.Unlock()
+		_ = cw.apply(cw.readDir(dir))
+	}
+}
+
+// readDir lists the configuration files at the overlay directory and
+// returns their contents. Files that cannot be read are not returned,
+// keeping their former configuration.
+func (cw *Poller) readDir(dir string) map[string][]byte {
+	files, err := cfgbroker.ConfigFiles(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		cw.logger.Errorw(fmt.Sprintf("Could not read configuration overlay %s", dir), zap.Error(err))
+		return nil
+	}
+	cw.entries[dir] = files
+
+	contents := make(map[string][]byte, len(files))
+	for _, p := range files {
+		content, err := os.ReadFile(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			cw.logger.Errorw(fmt.Sprintf("Could not read configuration overlay %s", p), zap.Error(err))
+			continue
+		}
+		contents[p] = content
+	}
+	return contents
+}
+
+// apply parses the contents of each path and merges them along with the
+// configurations of the rest of paths, notifying the callbacks when the
+// resulting configuration is valid and applied. Overlays with empty
+// contents, or no longer found at their directory, are dropped, while
+// those that cannot be parsed are logged, keeping their former
+// configuration. Callers must hold the lock.
+func (cw *Poller) apply(contents map[string][]byte) error {
+	paths := cw.paths()
+	configs := make(map[string]*cfgbroker.Config, len(paths))
+	for _, p := range paths {
+		if cfg, ok := cw.configs[p]; ok {
+			configs[p] = cfg
+		}
 	}
 
-	cfg, err := cfgbroker.Parse(string(content))
+	var updated []string
+	for _, p := range paths {
+		content, ok := contents[p]
+		if !ok {
+			continue
+		}
+		if len(content) == 0 {
+			if p == cw.path {
+				// Discard file events that do not inform content.
+				cw.logger.Debug(fmt.Sprintf("Received event with empty contents for %s", p))
+				continue
+			}
+			// Removed or emptied overlays inform no configuration.
+			delete(configs, p)
+			continue
+		}
+
+		cfg, err := cfgbroker.ParseFile(p, string(content))
+		if err != nil {
+			cw.logger.Errorw(fmt.Sprintf("Config from %s not applied", p), zap.Error(err),
+				zap.Any("errors", cfgbroker.ValidationErrors(err)))
+			if p == cw.path {
+				return fmt.Errorf("configuration at %s is not valid: %w", p, err)
+			}
+			continue
+		}
+		configs[p] = cfg
+		updated = append(updated, p)
+	}
+	for _, p := range cw.sortedConfigs() {
+		if _, ok := configs[p]; !ok {
+			updated = append(updated, p)
+		}
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	source := strings.Join(updated, ", ")
+
+	var sources []*cfgbroker.Config
+	for _, p := range paths {
+		if cfg, ok := configs[p]; ok {
+			sources = append(sources, cfg)
+		}
+	}
+	cfg, overridden := cfgbroker.Merge(sources...)
+	if len(overridden) != 0 {
+		cw.logger.Warnw("Configuration elements informed by several sources, later sources take precedence",
+			zap.Strings("fields", overridden))
+	}
+
+	var err error
+	if len(sources) > 1 {
+		if verr := cfg.Validate(context.Background()); verr != nil {
+			err = verr
+		}
+	}
+	if err == nil && cw.validator != nil {
+		err = cw.validator(cfg)
+	}
 	if err != nil {
-		cw.logger.Errorw(fmt.Sprintf("Error parsing config from %s", cw.path), zap.Error(err))
-		return
+		cw.logger.Errorw(fmt.Sprintf("Config from %s not applied", source), zap.Error(err),
+			zap.Any("errors", cfgbroker.ValidationErrors(err)))
+		return fmt.Errorf("configuration at %s is not valid: %w", source, err)
 	}
 
+	cw.configs = configs
 	cw.config = cfg
 	for _, cb := range cw.cbs {
 		cb(cfg)
 	}
+	return nil
 }
@@ -0,0 +1,137 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"go.starlark.net/starlark"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// StarlarkExtension is the file extension for configuration files that
+	// are evaluated as Starlark scripts.
+	StarlarkExtension = ".star"
+
+	// starlarkConfigVar is the Starlark global variable that must contain
+	// the broker configuration after evaluating the script.
+	starlarkConfigVar = "config"
+)
+
+// ParseFile parses the broker configuration choosing the format depending on
+// the file name extension. Starlark scripts are evaluated, any other file is
+// considered YAML or JSON.
+func ParseFile(filename, content string) (*Config, error) {
+	if filepath.Ext(filename) == StarlarkExtension {
+		return ParseStarlark(filename, content)
+	}
+
+	return Parse(content)
+}
+
+// ParseStarlark evaluates a Starlark script that must declare a global
+// config variable containing the broker configuration structure.
+func ParseStarlark(filename, script string) (*Config, error) {
+	thread := &starlark.Thread{Name: filename}
+	globals, err := starlark.ExecFile(thread, filename, script, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not evaluate Starlark configuration: %w", err)
+	}
+
+	v, ok := globals[starlarkConfigVar]
+	if !ok {
+		return nil, fmt.Errorf("starlark configuration must declare the %q global variable", starlarkConfigVar)
+	}
+
+	gv, err := starlarkToGo(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert Starlark configuration: %w", err)
+	}
+
+	// Use JSON as intermediate representation to reuse configuration
+	// parsing rules.
+	b, err := json.Marshal(gv)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize Starlark configuration: %w", err)
+	}
+
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func starlarkToGo(v starlark.Value) (interface{}, error) {
+	switch t := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+
+	case starlark.Bool:
+		return bool(t), nil
+
+	case starlark.Int:
+		i, ok := t.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s out of range", t.String())
+		}
+		return i, nil
+
+	case starlark.Float:
+		return float64(t), nil
+
+	case starlark.String:
+		return string(t), nil
+
+	case *starlark.List:
+		return starlarkIterableToGo(t)
+
+	case starlark.Tuple:
+		return starlarkIterableToGo(t)
+
+	case *starlark.Dict:
+		m := make(map[string]interface{}, t.Len())
+		for _, item := range t.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dictionary keys must be strings, found %s", item[0].Type())
+			}
+
+			gv, err := starlarkToGo(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(k)] = gv
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unsupported Starlark type %s", v.Type())
+}
+
+func starlarkIterableToGo(it starlark.Iterable) ([]interface{}, error) {
+	l := []interface{}{}
+	iter := it.Iterate()
+	defer iter.Done()
+
+	var item starlark.Value
+	for iter.Next(&item) {
+		gv, err := starlarkToGo(item)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, gv)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// ErrReadOnly is returned when the configuration store cannot be written.
+var ErrReadOnly = errors.New("configuration store is read only")
+
+// ConfigStore persists broker configurations.
+type ConfigStore interface {
+	// Write persists the broker configuration.
+	Write(*cfgbroker.Config) error
+}
+
+// NewFile returns a store that writes the configuration as
+// YAML to the informed file.
+func NewFile(path string) ConfigStore {
+	return &file{path: path}
+}
+
+type file struct {
+	path string
+	m    sync.Mutex
+}
+
+func (f *file) Write(c *cfgbroker.Config) error {
+	// Configuration scripts cannot be generated from the configuration.
+	if filepath.Ext(f.path) == cfgbroker.StarlarkExtension {
+		return ErrReadOnly
+	}
+
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("could not serialize broker configuration: %w", err)
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// Write to a temporary file and rename to avoid watchers
+	// reading partially written contents.
+	tmp := filepath.Join(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("could not write broker configuration: %w", err)
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("could not replace broker configuration file: %w", err)
+	}
+
+	return nil
+}
+
+// NewMemory returns a store that does not persist the configuration,
+// for configuration methods that cannot be written.
+func NewMemory() ConfigStore {
+	return &memory{}
+}
+
+type memory struct{}
+
+func (m *memory) Write(c *cfgbroker.Config) error {
+	return nil
+}
+
+// NewReadOnly returns a store that refuses to write configurations, for
+// configurations merged from several sources that cannot be written back
+// to any of them.
+func NewReadOnly() ConfigStore {
+	return &readOnly{}
+}
+
+type readOnly struct{}
+
+func (r *readOnly) Write(c *cfgbroker.Config) error {
+	return ErrReadOnly
+}
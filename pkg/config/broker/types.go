@@ -6,13 +6,36 @@ package broker
 import (
 	"context"
 	"net/url"
+	"path"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/rickb777/date/period"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
+
+	"github.com/triggermesh/brokers/pkg/common/urltemplate"
 )
 
 type Ingest struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
+
+	// PasswordFrom reads the password from a secret instead.
+	PasswordFrom *SecretSource `json:"passwordFrom,omitempty"`
+
+	// TraceSampling configures the sampling of traces for ingested events.
+	TraceSampling *TraceSampling `json:"traceSampling,omitempty"`
+
+	// AccessLog configures logging ingest requests.
+	AccessLog *AccessLog `json:"accessLog,omitempty"`
+
+	// Validation of the data of ingested events against JSON Schemas.
+	Validation *SchemaValidation `json:"validation,omitempty"`
+
+	// MQTT topics accepted by the MQTT listener.
+	MQTT *MQTTIngest `json:"mqtt,omitempty"`
 }
 
 func (i *Ingest) Validate(ctx context.Context) *apis.FieldError {
@@ -20,14 +43,230 @@ func (i *Ingest) Validate(ctx context.Context) *apis.FieldError {
 		return nil
 	}
 
-	if i.Password != "" && i.User == "" {
+	if (i.Password != "" || i.PasswordFrom != nil) && i.User == "" {
 		return &apis.FieldError{
 			Message: "user must be provided when password is informed",
 			Paths:   []string{"user"},
 		}
 	}
 
-	return nil
+	var errs *apis.FieldError
+	if i.PasswordFrom != nil {
+		errs = validateSecretValue(i.Password, i.PasswordFrom, "password", "passwordFrom")
+	}
+
+	return errs.Also(i.TraceSampling.Validate(ctx).ViaField("traceSampling").
+		Also(i.AccessLog.Validate(ctx).ViaField("accessLog")).
+		Also(i.Validation.Validate(ctx).ViaField("validation")).
+		Also(i.MQTT.Validate(ctx).ViaField("mqtt")))
+}
+
+// MQTTIngest sets the topics the MQTT listener accepts messages for, along
+// with the CloudEvents attributes of the events they are converted to. When
+// no topics are informed messages are accepted for any topic.
+type MQTTIngest struct {
+	Topics []MQTTTopic `json:"topics,omitempty"`
+}
+
+func (m *MQTTIngest) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if m == nil {
+		return
+	}
+
+	for i, t := range m.Topics {
+		errs = errs.Also(t.Validate(ctx).ViaFieldIndex("topics", i))
+	}
+
+	return
+}
+
+// MQTTTopic maps the messages published to matching topics to CloudEvents.
+// Topics are evaluated in order, the first one that matches is used.
+type MQTTTopic struct {
+	// Filter for the topic of messages, where "+" matches a single level
+	// and a trailing "#" matches any number of levels.
+	Filter string `json:"filter"`
+
+	// Type of the events. Defaults to the topic of the message, replacing
+	// its "/" level separators with ".".
+	Type *string `json:"type,omitempty"`
+
+	// Source of the events. Defaults to the MQTT client identifier of the
+	// publisher.
+	Source *string `json:"source,omitempty"`
+}
+
+func (t *MQTTTopic) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if t.Filter == "" {
+		errs = errs.Also(apis.ErrMissingField("filter"))
+	} else {
+		levels := strings.Split(t.Filter, "/")
+		for i, l := range levels {
+			if (strings.Contains(l, "#") && (l != "#" || i != len(levels)-1)) ||
+				(strings.Contains(l, "+") && l != "+") {
+				errs = errs.Also(apis.ErrInvalidValue(t.Filter, "filter"))
+				break
+			}
+		}
+	}
+
+	if t.Type != nil && *t.Type == "" {
+		errs = errs.Also(apis.ErrInvalidValue(*t.Type, "type"))
+	}
+
+	if t.Source != nil && *t.Source == "" {
+		errs = errs.Also(apis.ErrInvalidValue(*t.Source, "source"))
+	}
+
+	return
+}
+
+// SchemaValidation validates the data of ingested events against the JSON
+// Schema of their type, looked up at a local directory or at a schema
+// registry. Events that do not conform to their schema are rejected.
+type SchemaValidation struct {
+	// Directory that contains the schema of each event type at a file
+	// named after the type, <type>.json.
+	Directory *string `json:"directory,omitempty"`
+
+	// RegistryURL of a schema registry that returns the schema of each
+	// event type at <registryURL>/<type>.
+	RegistryURL *string `json:"registryURL,omitempty"`
+
+	// CacheTTL is the time schemas are cached using ISO8601. Defaults to
+	// 5 minutes.
+	CacheTTL *string `json:"cacheTTL,omitempty"`
+
+	// RequireSchema rejects events whose type has no schema, which are
+	// accepted otherwise.
+	RequireSchema bool `json:"requireSchema,omitempty"`
+}
+
+func (v *SchemaValidation) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if v == nil {
+		return
+	}
+
+	switch {
+	case v.Directory == nil && v.RegistryURL == nil:
+		errs = errs.Also(apis.ErrMissingOneOf("directory", "registryURL"))
+	case v.Directory != nil && v.RegistryURL != nil:
+		errs = errs.Also(apis.ErrMultipleOneOf("directory", "registryURL"))
+	}
+
+	if v.Directory != nil && *v.Directory == "" {
+		errs = errs.Also(apis.ErrInvalidValue(*v.Directory, "directory"))
+	}
+
+	if v.RegistryURL != nil {
+		if u, err := url.Parse(*v.RegistryURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fe := &apis.FieldError{
+				Message: "Registry URL cannot be parsed",
+				Paths:   []string{"registryURL"},
+			}
+			if err != nil {
+				fe.Details = err.Error()
+			}
+			errs = errs.Also(fe)
+		}
+	}
+
+	if v.CacheTTL != nil {
+		p, err := period.Parse(*v.CacheTTL)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Cache TTL is not an ISO8601 duration",
+				Paths:   []string{"cacheTTL"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() < 0:
+			errs = errs.Also(apis.ErrInvalidValue(*v.CacheTTL, "cacheTTL"))
+		}
+	}
+
+	return
+}
+
+// TraceSampling decides which ingested events are traced. Rules are evaluated
+// first, then the parent trace decision if ParentBased is set, and finally
+// Ratio. When none of them apply the default tracing sampler is used.
+type TraceSampling struct {
+	// Ratio of events to be sampled, from 0 to 1.
+	Ratio *float64 `json:"ratio,omitempty"`
+
+	// ParentBased honors the sampling decision of the incoming trace.
+	ParentBased *bool `json:"parentBased,omitempty"`
+
+	// Rules set the ratio for specific event types.
+	Rules []TraceSamplingRule `json:"rules,omitempty"`
+}
+
+func (t *TraceSampling) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if t == nil {
+		return
+	}
+
+	if t.Ratio != nil && (*t.Ratio < 0 || *t.Ratio > 1) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*t.Ratio, 0, 1, "ratio"))
+	}
+
+	for i, r := range t.Rules {
+		errs = errs.Also(r.Validate(ctx).ViaFieldIndex("rules", i))
+	}
+
+	return
+}
+
+// TraceSamplingRule sets the sampling ratio for events whose type matches.
+type TraceSamplingRule struct {
+	// Type of the event. A trailing "*" matches any type with that prefix.
+	Type string `json:"type"`
+
+	// Ratio of events to be sampled, from 0 to 1.
+	Ratio float64 `json:"ratio"`
+}
+
+func (r *TraceSamplingRule) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r.Type == "" {
+		errs = errs.Also(apis.ErrMissingField("type"))
+	}
+
+	if r.Ratio < 0 || r.Ratio > 1 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(r.Ratio, 0, 1, "ratio"))
+	}
+
+	return
+}
+
+// AccessLog logs a sample of ingest requests along with the attributes and
+// extensions of their events, but never their data.
+type AccessLog struct {
+	// Ratio of requests to be logged, from 0 to 1. All requests are logged
+	// if not informed.
+	Ratio *float64 `json:"ratio,omitempty"`
+
+	// Redact lists the attributes and extensions whose values are not
+	// logged.
+	Redact []string `json:"redact,omitempty"`
+}
+
+func (a *AccessLog) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
+		return
+	}
+
+	if a.Ratio != nil && (*a.Ratio < 0 || *a.Ratio > 1) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*a.Ratio, 0, 1, "ratio"))
+	}
+
+	for i, r := range a.Redact {
+		if r == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(r, "redact", i))
+		}
+	}
+
+	return
 }
 
 type BackoffPolicyType string
@@ -48,6 +287,29 @@ type DeliveryOptions struct {
 	//  - https://en.wikipedia.org/wiki/ISO_8601
 	BackoffDelay  *string `json:"backoffDelay,omitempty"`
 	DeadLetterURL *string `json:"deadLetterURL,omitempty"`
+
+	// DeadLetterTarget receives the events that could not be delivered
+	// to the target, using its own delivery settings. Only one of
+	// DeadLetterURL or DeadLetterTarget must be informed.
+	DeadLetterTarget *DeadLetterTarget `json:"deadLetterTarget,omitempty"`
+
+	// DeadLetterSinks is an escalation chain of sinks that are tried in
+	// order when the event cannot be delivered to the target nor to the
+	// DeadLetterURL.
+	DeadLetterSinks []DeadLetterSink `json:"deadLetterSinks,omitempty"`
+
+	// CircuitBreaker stops sending events to a failing target.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// AdaptiveConcurrency limits the deliveries in flight to the target,
+	// adapting the limit to the target latency and failures.
+	AdaptiveConcurrency *AdaptiveConcurrency `json:"adaptiveConcurrency,omitempty"`
+
+	// RetryAfterMax is the maximum time using ISO8601 to wait before
+	// retrying as informed by the Retry-After header of 429 and 503 target
+	// responses, when the broker honors it. Overrides the broker maximum,
+	// zero ignoring the header for the target.
+	RetryAfterMax *string `json:"retryAfterMax,omitempty"`
 }
 
 func (d *DeliveryOptions) Validate(ctx context.Context) (errs *apis.FieldError) {
@@ -65,112 +327,1926 @@ func (d *DeliveryOptions) Validate(ctx context.Context) (errs *apis.FieldError)
 		}
 	}
 
-	return
+	if d.DeadLetterTarget != nil {
+		if d.DeadLetterURL != nil && *d.DeadLetterURL != "" {
+			errs = errs.Also(apis.ErrMultipleOneOf("deadLetterURL", "deadLetterTarget"))
+		}
+		errs = errs.Also(d.DeadLetterTarget.Validate(ctx).ViaField("deadLetterTarget"))
+	}
+
+	for i, dls := range d.DeadLetterSinks {
+		errs = errs.Also(dls.Validate(ctx).ViaFieldIndex("deadLetterSinks", i))
+	}
+
+	if d.RetryAfterMax != nil {
+		p, err := period.Parse(*d.RetryAfterMax)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Retry after max is not an ISO8601 duration",
+				Paths:   []string{"retryAfterMax"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() < 0:
+			errs = errs.Also(apis.ErrInvalidValue(*d.RetryAfterMax, "retryAfterMax"))
+		}
+	}
+
+	errs = errs.Also(d.CircuitBreaker.Validate(ctx).ViaField("circuitBreaker"))
+	return errs.Also(d.AdaptiveConcurrency.Validate(ctx).ViaField("adaptiveConcurrency"))
 }
 
-type Target struct {
-	URL             *string          `json:"url,,omitempty"`
-	DeliveryOptions *DeliveryOptions `json:"deliveryOptions,omitempty"`
+// AdaptiveConcurrency grows the limit of deliveries in flight additively
+// while deliveries succeed within the latency threshold, and shrinks it
+// multiplicatively when they fail or exceed the threshold (AIMD).
+type AdaptiveConcurrency struct {
+	// InitialLimit of deliveries in flight. Defaults to 10, or to the
+	// closest bound when out of the limits.
+	InitialLimit *int32 `json:"initialLimit,omitempty"`
+
+	// MinLimit of deliveries in flight. Defaults to 1.
+	MinLimit *int32 `json:"minLimit,omitempty"`
+
+	// MaxLimit of deliveries in flight. Defaults to 100.
+	MaxLimit *int32 `json:"maxLimit,omitempty"`
+
+	// LatencyThreshold is the delivery latency using ISO8601 above which
+	// the target is considered congested. Only failed deliveries shrink
+	// the limit if not informed.
+	LatencyThreshold *string `json:"latencyThreshold,omitempty"`
+
+	// BackoffRatio multiplies the limit when shrinking it, between 0 and
+	// 1 exclusive. Defaults to 0.9.
+	BackoffRatio *float64 `json:"backoffRatio,omitempty"`
 }
 
-func (i *Target) Validate(ctx context.Context) (errs *apis.FieldError) {
-	if i == nil {
+func (a *AdaptiveConcurrency) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
 		return
 	}
 
-	if i.URL != nil && *i.URL != "" {
-		if _, err := url.Parse(*i.URL); err != nil {
+	if a.MinLimit != nil && *a.MinLimit < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*a.MinLimit, "minLimit"))
+	}
+
+	if a.MaxLimit != nil && *a.MaxLimit < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*a.MaxLimit, "maxLimit"))
+	}
+
+	if a.MinLimit != nil && a.MaxLimit != nil && *a.MaxLimit < *a.MinLimit {
+		errs = errs.Also(apis.ErrGeneric("Maximum limit must not be lower than the minimum limit", "minLimit", "maxLimit"))
+	}
+
+	if a.InitialLimit != nil && *a.InitialLimit < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*a.InitialLimit, "initialLimit"))
+	}
+
+	if a.LatencyThreshold != nil {
+		p, err := period.Parse(*a.LatencyThreshold)
+		switch {
+		case err != nil:
 			errs = errs.Also(&apis.FieldError{
-				Message: "Target URL cannot be parsed",
-				Paths:   []string{"url"},
+				Message: "Latency threshold is not an ISO8601 duration",
+				Paths:   []string{"latencyThreshold"},
 				Details: err.Error(),
 			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*a.LatencyThreshold, "latencyThreshold"))
 		}
 	}
 
-	return errs.Also(i.DeliveryOptions.Validate(ctx))
+	if a.BackoffRatio != nil && (*a.BackoffRatio <= 0 || *a.BackoffRatio >= 1) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*a.BackoffRatio, 0, 1, "backoffRatio"))
+	}
+
+	return
 }
 
-type Filter struct {
-	// All evaluates to true if all the nested expressions evaluate to true.
-	// It must contain at least one filter expression.
-	//
-	// +optional
-	All []Filter `json:"all,omitempty"`
+// CircuitBreaker opens after a number of consecutive delivery failures,
+// sending events straight to the dead letter sinks during the cool down
+// period. After that period a single event is sent to the target, closing
+// the circuit if delivered, or opening it again otherwise.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the circuit.
+	FailureThreshold int32 `json:"failureThreshold"`
 
-	// Any evaluates to true if at least one of the nested expressions evaluates
-	// to true. It must contain at least one filter expression.
-	//
-	// +optional
-	Any []Filter `json:"any,omitempty"`
+	// CoolDown is the time the circuit stays open using ISO8601.
+	// Defaults to 30 seconds.
+	CoolDown *string `json:"coolDown,omitempty"`
+}
 
-	// Not evaluates to true if the nested expression evaluates to false.
-	//
-	// +optional
-	Not *Filter `json:"not,omitempty"`
+func (c *CircuitBreaker) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if c == nil {
+		return
+	}
 
-	// Exact evaluates to true if the value of the matching CloudEvents
-	// attribute matches exactly the String value specified (case-sensitive).
-	// Exact must contain exactly one property, where the key is the name of the
-	// CloudEvents attribute to be matched, and its value is the String value to
-	// use in the comparison. The attribute name and value specified in the filter
-	// expression cannot be empty strings.
-	//
-	// +optional
-	Exact map[string]string `json:"exact,omitempty"`
+	if c.FailureThreshold < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(c.FailureThreshold, "failureThreshold"))
+	}
 
-	// Prefix evaluates to true if the value of the matching CloudEvents
-	// attribute starts with the String value specified (case-sensitive). Prefix
-	// must contain exactly one property, where the key is the name of the
-	// CloudEvents attribute to be matched, and its value is the String value to
-	// use in the comparison. The attribute name and value specified in the filter
-	// expression cannot be empty strings.
-	//
-	// +optional
-	Prefix map[string]string `json:"prefix,omitempty"`
+	if c.CoolDown != nil {
+		p, err := period.Parse(*c.CoolDown)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Cool down is not an ISO8601 duration",
+				Paths:   []string{"coolDown"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*c.CoolDown, "coolDown"))
+		}
+	}
 
-	// Suffix evaluates to true if the value of the matching CloudEvents
-	// attribute ends with the String value specified (case-sensitive). Suffix
-	// must contain exactly one property, where the key is the name of the
-	// CloudEvents attribute to be matched, and its value is the String value to
-	// use in the comparison. The attribute name and value specified in the filter
-	// expression cannot be empty strings.
-	//
-	// +optional
-	Suffix map[string]string `json:"suffix,omitempty"`
+	return
 }
 
-type Trigger struct {
-	Filters []Filter `json:"filters,omitempty"`
-	Target  Target   `json:"target"`
+// DeadLetterSink is a destination for events that could not be delivered.
+// Only one of URL or File must be informed.
+type DeadLetterSink struct {
+	// URL of the sink where events are sent.
+	URL *string `json:"url,omitempty"`
+	// File path where events are appended as JSON lines.
+	File *string `json:"file,omitempty"`
 }
 
-func (t *Trigger) Validate(ctx context.Context) *apis.FieldError {
-	var errs *apis.FieldError
+func (d *DeadLetterSink) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if d == nil {
+		return
+	}
 
-	if t == nil {
-		return nil
+	hasURL := d.URL != nil && *d.URL != ""
+	hasFile := d.File != nil && *d.File != ""
+
+	switch {
+	case hasURL && hasFile:
+		errs = errs.Also(apis.ErrMultipleOneOf("url", "file"))
+	case !hasURL && !hasFile:
+		errs = errs.Also(apis.ErrMissingOneOf("url", "file"))
+	case hasURL:
+		if _, err := url.Parse(*d.URL); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "DLS URL cannot be parsed",
+				Paths:   []string{"url"},
+				Details: err.Error(),
+			})
+		}
 	}
-	errs = errs.Also(t.Target.Validate(ctx)).ViaField("target")
 
-	return errs.Also(ValidateSubscriptionAPIFiltersList(ctx, t.Filters).ViaField("filters"))
+	return
 }
 
-type Config struct {
-	Ingest   *Ingest            `json:"ingest,omitempty"`
-	Triggers map[string]Trigger `json:"triggers"`
+// DeadLetterTarget is a dead letter sink with its own delivery settings,
+// since sinks can also fail.
+type DeadLetterTarget struct {
+	// URL of the sink where events are sent.
+	URL string `json:"url"`
+
+	// Retry is the number of attempts after the first one fails, using
+	// the backoff policy and delay as the target delivery options do.
+	Retry         *int32             `json:"retry,omitempty"`
+	BackoffPolicy *BackoffPolicyType `json:"backoffPolicy,omitempty"`
+	BackoffDelay  *string            `json:"backoffDelay,omitempty"`
+
+	// Timeout for each attempt using ISO8601. No timeout if not informed.
+	Timeout *string `json:"timeout,omitempty"`
+
+	// Headers added to the requests sent to the sink.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
-func (c *Config) Validate(ctx context.Context) *apis.FieldError {
-	if c == nil {
-		return nil
+func (d *DeadLetterTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if d == nil {
+		return
 	}
 
-	errs := c.Ingest.Validate(ctx).ViaField("ingest")
+	if u, err := url.Parse(d.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = errs.Also(&apis.FieldError{
+			Message: "DLS URL must be absolute",
+			Paths:   []string{"url"},
+			Details: d.URL,
+		})
+	}
 
-	for k, t := range c.Triggers {
-		errs = errs.Also(t.Validate(ctx).ViaFieldKey("triggers", k))
+	if d.Retry != nil && *d.Retry < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*d.Retry, "retry"))
 	}
 
-	return errs
+	if d.BackoffPolicy != nil {
+		switch *d.BackoffPolicy {
+		case BackoffPolicyConstant, BackoffPolicyLinear, BackoffPolicyExponential:
+		default:
+			errs = errs.Also(apis.ErrInvalidValue(*d.BackoffPolicy, "backoffPolicy"))
+		}
+	}
+
+	check := func(v *string, field, name string) {
+		if v == nil {
+			return
+		}
+		p, err := period.Parse(*v)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: name + " is not an ISO8601 duration",
+				Paths:   []string{field},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() < 0:
+			errs = errs.Also(apis.ErrInvalidValue(*v, field))
+		}
+	}
+	check(d.BackoffDelay, "backoffDelay", "Backoff delay")
+	check(d.Timeout, "timeout", "Timeout")
+
+	return errs.Also(validateHeaders(d.Headers, "headers"))
+}
+
+// validateHeaders checks that header names can be sent.
+func validateHeaders(headers map[string]string, field string) (errs *apis.FieldError) {
+	for k, v := range headers {
+		if k == "" || strings.ContainsAny(k, " :\r\n") {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, field))
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			errs = errs.Also(apis.ErrInvalidValue(v, k).ViaField(field))
+		}
+	}
+	return
+}
+
+type Target struct {
+	// URL of the target, which can contain templated segments named after
+	// CloudEvent attributes, as in https://svc/{type}/{subject}, that are
+	// replaced with the URL escaped attribute values of each event.
+	URL             *string          `json:"url,,omitempty"`
+	DeliveryOptions *DeliveryOptions `json:"deliveryOptions,omitempty"`
+
+	// Ref to the Kubernetes object the events are delivered to, whose
+	// address is resolved by the broker. When informed, the URL must be
+	// relative, and is resolved against the object address.
+	Ref *Reference `json:"ref,omitempty"`
+
+	// DefaultURL receives the events that do not inform an attribute
+	// referenced by a templated target URL. Those events are sent to the
+	// dead letter sinks if not informed.
+	DefaultURL *string `json:"defaultURL,omitempty"`
+
+	// FallbackURLs are tried in order when the delivery to the target URL
+	// fails, before retrying.
+	FallbackURLs []string `json:"fallbackURLs,omitempty"`
+
+	// ReplicaURLs are replicas of the target URL. Each event is delivered
+	// to one of the replicas, the target URL included, by consistent
+	// hashing of the trigger ordering key, so that events sharing a key
+	// are delivered to the same replica, and only a fraction of the keys
+	// move when replicas are added or removed. Events without ordering key
+	// are distributed by their ID.
+	ReplicaURLs []string `json:"replicaURLs,omitempty"`
+
+	// LoadBalancer distributes deliveries across the endpoints the target
+	// URL host resolves to.
+	LoadBalancer *LoadBalancer `json:"loadBalancer,omitempty"`
+
+	// HTTPClient overrides the broker connection settings for this target.
+	HTTPClient *HTTPClient `json:"httpClient,omitempty"`
+
+	// Headers added to the requests sent to the target, fallback and
+	// replica URLs, but not to dead letter sinks.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Auth authenticates the requests sent to the target, fallback and
+	// replica URLs.
+	Auth *TargetAuth `json:"auth,omitempty"`
+
+	// Kafka publishes events to a Kafka topic instead of delivering them
+	// to the target URL.
+	Kafka *KafkaTarget `json:"kafka,omitempty"`
+
+	// ObjectStore archives events to an S3 or GCS bucket instead of
+	// delivering them to the target URL.
+	ObjectStore *ObjectStoreTarget `json:"objectStore,omitempty"`
+
+	// Stream pushes events to the consumers connected to the trigger
+	// stream instead of delivering them to the target URL.
+	Stream *StreamTarget `json:"stream,omitempty"`
+
+	// AMQP sends events to an AMQP 1.0 node instead of delivering them to
+	// the target URL.
+	AMQP *AMQPTarget `json:"amqp,omitempty"`
+}
+
+func (i *Target) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if i == nil {
+		return
+	}
+
+	templated := i.URL != nil && urltemplate.IsTemplate(*i.URL)
+	switch {
+	case i.Ref != nil:
+		errs = errs.Also(i.Ref.Validate(ctx).ViaField("ref"))
+		if i.URL != nil && *i.URL != "" {
+			if u, err := url.Parse(*i.URL); err != nil || u.IsAbs() || u.Host != "" || templated {
+				errs = errs.Also(apis.ErrGeneric("Target URL must be a relative URL when the ref is informed", "url"))
+			}
+		}
+	case templated:
+		if _, err := urltemplate.Parse(*i.URL); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Target URL template cannot be parsed",
+				Paths:   []string{"url"},
+				Details: err.Error(),
+			})
+		}
+	case i.URL != nil && *i.URL != "":
+		if _, err := url.Parse(*i.URL); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Target URL cannot be parsed",
+				Paths:   []string{"url"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	if i.DefaultURL != nil {
+		if !templated {
+			errs = errs.Also(apis.ErrGeneric("Default URL can only be informed for templated target URLs", "defaultURL"))
+		} else if _, err := url.Parse(*i.DefaultURL); err != nil || *i.DefaultURL == "" {
+			fe := &apis.FieldError{
+				Message: "Default URL cannot be parsed",
+				Paths:   []string{"defaultURL"},
+			}
+			if err != nil {
+				fe.Details = err.Error()
+			}
+			errs = errs.Also(fe)
+		}
+	}
+
+	// Replicas and endpoints are derived from a single target URL.
+	if templated && len(i.ReplicaURLs) != 0 {
+		errs = errs.Also(apis.ErrGeneric("Replica URLs cannot be informed for templated target URLs", "replicaURLs"))
+	}
+	if templated && i.LoadBalancer != nil {
+		errs = errs.Also(apis.ErrGeneric("Load balancer cannot be informed for templated target URLs", "loadBalancer"))
+	}
+
+	for j, u := range i.FallbackURLs {
+		if _, err := url.Parse(u); err != nil || u == "" {
+			fe := &apis.FieldError{
+				Message: "Fallback URL cannot be parsed",
+				Paths:   []string{apis.CurrentField},
+			}
+			if err != nil {
+				fe.Details = err.Error()
+			}
+			errs = errs.Also(fe.ViaFieldIndex("fallbackURLs", j))
+		}
+	}
+
+	for j, u := range i.ReplicaURLs {
+		if _, err := url.Parse(u); err != nil || u == "" {
+			fe := &apis.FieldError{
+				Message: "Replica URL cannot be parsed",
+				Paths:   []string{apis.CurrentField},
+			}
+			if err != nil {
+				fe.Details = err.Error()
+			}
+			errs = errs.Also(fe.ViaFieldIndex("replicaURLs", j))
+		}
+	}
+
+	if len(i.ReplicaURLs) != 0 && i.LoadBalancer != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("replicaURLs", "loadBalancer"))
+	}
+
+	// The address of referenced objects might change between deliveries.
+	if i.Ref != nil && len(i.ReplicaURLs) != 0 {
+		errs = errs.Also(apis.ErrMultipleOneOf("ref", "replicaURLs"))
+	}
+	if i.Ref != nil && i.LoadBalancer != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("ref", "loadBalancer"))
+	}
+
+	// Kafka, object store, stream and AMQP targets do not deliver events
+	// over HTTP.
+	kinds := []string{}
+	switch {
+	case i.URL != nil && *i.URL != "":
+		kinds = append(kinds, "url")
+	case i.Ref != nil:
+		kinds = append(kinds, "ref")
+	}
+	kind := ""
+	if i.Kafka != nil {
+		kinds = append(kinds, "kafka")
+		kind = "Kafka"
+	}
+	if i.ObjectStore != nil {
+		kinds = append(kinds, "objectStore")
+		kind = "object store"
+	}
+	if i.Stream != nil {
+		kinds = append(kinds, "stream")
+		kind = "stream"
+	}
+	if i.AMQP != nil {
+		kinds = append(kinds, "amqp")
+		kind = "AMQP"
+	}
+	if len(kinds) > 1 {
+		errs = errs.Also(apis.ErrMultipleOneOf(kinds...))
+	}
+
+	if kind != "" {
+		// Settings that only apply to HTTP targets.
+		for _, f := range []struct {
+			name     string
+			informed bool
+		}{
+			{"fallbackURLs", len(i.FallbackURLs) != 0},
+			{"replicaURLs", len(i.ReplicaURLs) != 0},
+			{"loadBalancer", i.LoadBalancer != nil},
+			{"httpClient", i.HTTPClient != nil},
+			{"headers", len(i.Headers) != 0},
+			{"auth", i.Auth != nil},
+		} {
+			if f.informed {
+				errs = errs.Also(apis.ErrGeneric("Field cannot be informed for "+kind+" targets", f.name))
+			}
+		}
+	}
+
+	errs = errs.Also(validateHeaders(i.Headers, "headers"))
+	if i.Auth != nil {
+		for k := range i.Headers {
+			if strings.EqualFold(k, "Authorization") {
+				errs = errs.Also(apis.ErrGeneric("Authorization header cannot be informed along with auth", "headers"))
+			}
+		}
+	}
+
+	return errs.Also(i.DeliveryOptions.Validate(ctx)).
+		Also(i.LoadBalancer.Validate(ctx).ViaField("loadBalancer")).
+		Also(i.HTTPClient.Validate(ctx).ViaField("httpClient")).
+		Also(i.Auth.Validate(ctx).ViaField("auth")).
+		Also(i.Kafka.Validate(ctx).ViaField("kafka")).
+		Also(i.ObjectStore.Validate(ctx).ViaField("objectStore")).
+		Also(i.Stream.Validate(ctx).ViaField("stream")).
+		Also(i.AMQP.Validate(ctx).ViaField("amqp"))
+}
+
+// TargetAuth authenticates the requests sent to a target. Only one of the
+// methods must be informed.
+type TargetAuth struct {
+	// Bearer informs a token at the Authorization header.
+	Bearer *BearerAuth `json:"bearer,omitempty"`
+
+	// Basic informs a username and password at the Authorization header.
+	Basic *BasicAuth `json:"basic,omitempty"`
+
+	// OAuth2 obtains tokens using the client credentials flow, which are
+	// cached until they expire.
+	OAuth2 *OAuth2Auth `json:"oauth2,omitempty"`
+}
+
+func (a *TargetAuth) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
+		return
+	}
+
+	methods := []string{}
+	if a.Bearer != nil {
+		methods = append(methods, "bearer")
+	}
+	if a.Basic != nil {
+		methods = append(methods, "basic")
+	}
+	if a.OAuth2 != nil {
+		methods = append(methods, "oauth2")
+	}
+	switch {
+	case len(methods) == 0:
+		errs = errs.Also(apis.ErrMissingOneOf("bearer", "basic", "oauth2"))
+	case len(methods) > 1:
+		errs = errs.Also(apis.ErrMultipleOneOf(methods...))
+	}
+
+	return errs.Also(a.Bearer.Validate(ctx).ViaField("bearer")).
+		Also(a.Basic.Validate(ctx).ViaField("basic")).
+		Also(a.OAuth2.Validate(ctx).ViaField("oauth2"))
+}
+
+// BearerAuth informs a static token.
+type BearerAuth struct {
+	Token SecretSource `json:"token"`
+}
+
+func (b *BearerAuth) Validate(ctx context.Context) *apis.FieldError {
+	if b == nil {
+		return nil
+	}
+	return b.Token.Validate(ctx).ViaField("token")
+}
+
+// BasicAuth informs a username and password.
+type BasicAuth struct {
+	Username string       `json:"username"`
+	Password SecretSource `json:"password"`
+}
+
+func (b *BasicAuth) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if b == nil {
+		return
+	}
+	if b.Username == "" {
+		errs = errs.Also(apis.ErrMissingField("username"))
+	}
+	return errs.Also(b.Password.Validate(ctx).ViaField("password"))
+}
+
+// OAuth2Auth obtains tokens from the token URL using the client
+// credentials flow.
+type OAuth2Auth struct {
+	TokenURL     string       `json:"tokenURL"`
+	ClientID     string       `json:"clientID"`
+	ClientSecret SecretSource `json:"clientSecret"`
+
+	// Scopes requested for the tokens.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// EndpointParams are added to the token requests, as the audience
+	// some providers require.
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
+func (o *OAuth2Auth) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if o == nil {
+		return
+	}
+
+	if u, err := url.Parse(o.TokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = errs.Also(&apis.FieldError{
+			Message: "Token URL must be absolute",
+			Paths:   []string{"tokenURL"},
+			Details: o.TokenURL,
+		})
+	}
+	if o.ClientID == "" {
+		errs = errs.Also(apis.ErrMissingField("clientID"))
+	}
+	return errs.Also(o.ClientSecret.Validate(ctx).ViaField("clientSecret"))
+}
+
+// SecretSource reads a secret from an environment variable, a file or a
+// Kubernetes Secret, so that it is not informed at the broker
+// configuration. Only one of them must be informed.
+type SecretSource struct {
+	// Env is the name of the environment variable.
+	Env *string `json:"env,omitempty"`
+
+	// File is the path of the file, which is read again when it changes,
+	// as mounted Kubernetes secrets do. Surrounding whitespace is trimmed.
+	File *string `json:"file,omitempty"`
+
+	// Secret is a key of a Kubernetes Secret at the broker namespace,
+	// which can only be referenced when the broker configuration is read
+	// from a Kubernetes Secret. Surrounding whitespace is trimmed.
+	Secret *SecretKeySelector `json:"secret,omitempty"`
+}
+
+func (s *SecretSource) Validate(ctx context.Context) (errs *apis.FieldError) {
+	sources := []string{}
+	if s.Env != nil && *s.Env != "" {
+		sources = append(sources, "env")
+	}
+	if s.File != nil && *s.File != "" {
+		sources = append(sources, "file")
+	}
+	if s.Secret != nil {
+		sources = append(sources, "secret")
+	}
+
+	switch {
+	case len(sources) == 0:
+		errs = errs.Also(apis.ErrMissingOneOf("env", "file", "secret"))
+	case len(sources) > 1:
+		errs = errs.Also(apis.ErrMultipleOneOf(sources...))
+	}
+	return errs.Also(s.Secret.Validate(ctx).ViaField("secret"))
+}
+
+// SecretKeySelector is a key of a Kubernetes Secret.
+type SecretKeySelector struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+func (s *SecretKeySelector) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s == nil {
+		return
+	}
+	if s.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	}
+	if s.Key == "" {
+		errs = errs.Also(apis.ErrMissingField("key"))
+	}
+	return
+}
+
+// validateSecretValue checks that a setting is informed either as a value
+// or as a secret source, but not both.
+func validateSecretValue(value string, src *SecretSource, field, srcField string) (errs *apis.FieldError) {
+	switch {
+	case value != "" && src != nil:
+		errs = errs.Also(apis.ErrMultipleOneOf(field, srcField))
+	case value == "" && src == nil:
+		errs = errs.Also(apis.ErrMissingOneOf(field, srcField))
+	}
+	return errs.Also(src.Validate(context.Background()).ViaField(srcField))
+}
+
+// KafkaTarget publishes events to a Kafka topic using the CloudEvents Kafka
+// binding in binary content mode. Events are partitioned by the trigger
+// ordering key, or by the partitionkey extension when not informed.
+type KafkaTarget struct {
+	// Brokers are the addresses of the Kafka bootstrap brokers.
+	Brokers []string `json:"brokers"`
+
+	// Topic events are published to.
+	Topic string `json:"topic"`
+
+	// TLS enables TLS for the connections to the Kafka brokers.
+	TLS bool `json:"tls,omitempty"`
+
+	// SASL authenticates to the Kafka brokers using the PLAIN mechanism.
+	SASL *KafkaSASL `json:"sasl,omitempty"`
+}
+
+func (k *KafkaTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if k == nil {
+		return
+	}
+
+	if len(k.Brokers) == 0 {
+		errs = errs.Also(apis.ErrMissingField("brokers"))
+	}
+	for i, b := range k.Brokers {
+		if b == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(b, "brokers", i))
+		}
+	}
+
+	if k.Topic == "" {
+		errs = errs.Also(apis.ErrMissingField("topic"))
+	}
+
+	if k.SASL != nil {
+		if k.SASL.User == "" {
+			errs = errs.Also(apis.ErrMissingField("sasl.user"))
+		}
+		errs = errs.Also(validateSecretValue(k.SASL.Password, k.SASL.PasswordFrom, "password", "passwordFrom").ViaField("sasl"))
+	}
+
+	return
+}
+
+// KafkaSASL are the credentials for the Kafka brokers.
+type KafkaSASL struct {
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+
+	// PasswordFrom reads the password from a secret instead.
+	PasswordFrom *SecretSource `json:"passwordFrom,omitempty"`
+}
+
+// AMQPTarget sends events to an AMQP 1.0 node, like a queue or a topic,
+// using the CloudEvents AMQP binding in binary content mode.
+type AMQPTarget struct {
+	// URL of the AMQP server, using the amqps scheme for TLS connections.
+	URL string `json:"url"`
+
+	// Address of the node events are sent to.
+	Address string `json:"address"`
+
+	// SASL authenticates to the AMQP server using the PLAIN mechanism.
+	SASL *AMQPSASL `json:"sasl,omitempty"`
+}
+
+func (a *AMQPTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
+		return
+	}
+
+	if a.URL == "" {
+		errs = errs.Also(apis.ErrMissingField("url"))
+	} else if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+		fe := &apis.FieldError{
+			Message: "AMQP URL must be an amqp or amqps URL",
+			Paths:   []string{"url"},
+		}
+		if err != nil {
+			fe.Details = err.Error()
+		}
+		errs = errs.Also(fe)
+	}
+
+	if a.Address == "" {
+		errs = errs.Also(apis.ErrMissingField("address"))
+	}
+
+	if a.SASL != nil {
+		if a.SASL.User == "" {
+			errs = errs.Also(apis.ErrMissingField("sasl.user"))
+		}
+		errs = errs.Also(validateSecretValue(a.SASL.Password, a.SASL.PasswordFrom, "password", "passwordFrom").ViaField("sasl"))
+	}
+
+	return
+}
+
+// NodeURL identifies the node events are sent to, excluding the credentials
+// the server URL might inform.
+func (a *AMQPTarget) NodeURL() string {
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return a.Address
+	}
+	return u.Scheme + "://" + u.Host + "/" + strings.TrimPrefix(a.Address, "/")
+}
+
+// AMQPSASL are the credentials for the AMQP server.
+type AMQPSASL struct {
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+
+	// PasswordFrom reads the password from a secret instead.
+	PasswordFrom *SecretSource `json:"passwordFrom,omitempty"`
+}
+
+// Object store providers.
+const (
+	ObjectStoreProviderS3  = "s3"
+	ObjectStoreProviderGCS = "gcs"
+)
+
+// Object store partitions, which are derived from the event time and type.
+const (
+	ObjectStorePartitionDate = "date"
+	ObjectStorePartitionHour = "hour"
+	ObjectStorePartitionType = "type"
+)
+
+// StreamTarget buffers events for the consumers connected to the trigger
+// stream through the admin API. Events are not delivered while no consumer
+// is connected, and their delivery waits up to the timeout while the buffer
+// is full of events not sent yet.
+type StreamTarget struct {
+	// BufferSize is the number of events buffered, which consumers can
+	// resume from after reconnecting. Defaults to 100.
+	BufferSize *int32 `json:"bufferSize,omitempty"`
+}
+
+func (s *StreamTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s == nil {
+		return
+	}
+
+	if s.BufferSize != nil && *s.BufferSize < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*s.BufferSize, "bufferSize"))
+	}
+
+	return
+}
+
+// ObjectStoreTarget writes batches of events as newline delimited JSON
+// objects to an S3 or GCS bucket. GCS buckets are written through their
+// S3 compatible API.
+type ObjectStoreTarget struct {
+	// Provider of the bucket, s3 or gcs.
+	Provider string `json:"provider"`
+
+	// Bucket objects are written to.
+	Bucket string `json:"bucket"`
+
+	// Region of the bucket. Defaults to us-east-1.
+	Region *string `json:"region,omitempty"`
+
+	// Endpoint overrides the provider endpoint, for instance for S3
+	// compatible stores.
+	Endpoint *string `json:"endpoint,omitempty"`
+
+	// Prefix of the object keys.
+	Prefix *string `json:"prefix,omitempty"`
+
+	// Partitioning of the objects under the prefix, in order, which can
+	// be date, hour and type.
+	Partitioning []string `json:"partitioning,omitempty"`
+
+	// Credentials for the bucket, which are HMAC keys for GCS. When not
+	// informed they are read from the environment.
+	Credentials *ObjectStoreCredentials `json:"credentials,omitempty"`
+}
+
+func (o *ObjectStoreTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if o == nil {
+		return
+	}
+
+	switch o.Provider {
+	case ObjectStoreProviderS3, ObjectStoreProviderGCS:
+	case "":
+		errs = errs.Also(apis.ErrMissingField("provider"))
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(o.Provider, "provider"))
+	}
+
+	if o.Bucket == "" {
+		errs = errs.Also(apis.ErrMissingField("bucket"))
+	}
+
+	if o.Endpoint != nil {
+		u, err := url.Parse(*o.Endpoint)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Endpoint cannot be parsed",
+				Paths:   []string{"endpoint"},
+				Details: err.Error(),
+			})
+		case (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			errs = errs.Also(apis.ErrInvalidValue(*o.Endpoint, "endpoint"))
+		}
+	}
+
+	seen := make(map[string]struct{}, len(o.Partitioning))
+	for i, p := range o.Partitioning {
+		_, dup := seen[p]
+		switch {
+		case dup:
+			errs = errs.Also(apis.ErrGeneric("Partition is informed more than once", apis.CurrentField).ViaFieldIndex("partitioning", i))
+		case p != ObjectStorePartitionDate && p != ObjectStorePartitionHour && p != ObjectStorePartitionType:
+			errs = errs.Also(apis.ErrInvalidArrayValue(p, "partitioning", i))
+		}
+		seen[p] = struct{}{}
+	}
+
+	if o.Credentials != nil {
+		if o.Credentials.AccessKeyID == "" {
+			errs = errs.Also(apis.ErrMissingField("credentials.accessKeyID"))
+		}
+		errs = errs.Also(validateSecretValue(o.Credentials.SecretAccessKey, o.Credentials.SecretAccessKeyFrom,
+			"secretAccessKey", "secretAccessKeyFrom").ViaField("credentials"))
+	}
+
+	return
+}
+
+// ObjectStoreCredentials are the access keys for the bucket.
+type ObjectStoreCredentials struct {
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+
+	// SecretAccessKeyFrom reads the secret access key from a secret
+	// instead.
+	SecretAccessKeyFrom *SecretSource `json:"secretAccessKeyFrom,omitempty"`
+}
+
+// Reference to a Kubernetes object, either a Service or an Addressable that
+// informs its URL at the status, as in Knative destinations.
+type Reference struct {
+	// APIVersion of the object, v1 if not informed.
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Namespace of the object, the broker namespace if not informed.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (r *Reference) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	if r.Kind == "" {
+		errs = errs.Also(apis.ErrMissingField("kind"))
+	}
+	if r.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	}
+	return
+}
+
+// String returns a human readable representation of the reference.
+func (r *Reference) String() string {
+	kind := strings.ToLower(r.Kind)
+	if group, _, ok := strings.Cut(r.APIVersion, "/"); ok {
+		kind += "." + group
+	}
+	if r.Namespace == "" {
+		return kind + "/" + r.Name
+	}
+	return kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// LoadBalancer resolves the endpoints of the target URL host, for instance
+// a headless service, and sends each delivery to one of them, ejecting
+// endpoints that fail consecutively.
+type LoadBalancer struct {
+	// SRVService is the service name used to look up the endpoints as SRV
+	// records, _<service>._tcp.<host>, using the port of each record. When
+	// not informed the endpoints are the addresses of the host using the
+	// target URL port.
+	SRVService *string `json:"srvService,omitempty"`
+
+	// ResolveInterval is the time the resolved endpoints are used before
+	// resolving them again using ISO8601. Defaults to 30 seconds.
+	ResolveInterval *string `json:"resolveInterval,omitempty"`
+
+	// EjectionThreshold is the number of consecutive failures that ejects
+	// an endpoint. Defaults to 3.
+	EjectionThreshold *int32 `json:"ejectionThreshold,omitempty"`
+
+	// EjectionTime is the time an endpoint stays ejected using ISO8601.
+	// Defaults to 30 seconds.
+	EjectionTime *string `json:"ejectionTime,omitempty"`
+}
+
+func (l *LoadBalancer) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if l == nil {
+		return
+	}
+
+	if l.SRVService != nil && *l.SRVService == "" {
+		errs = errs.Also(apis.ErrInvalidValue(*l.SRVService, "srvService"))
+	}
+
+	if l.EjectionThreshold != nil && *l.EjectionThreshold < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*l.EjectionThreshold, "ejectionThreshold"))
+	}
+
+	if l.ResolveInterval != nil {
+		p, err := period.Parse(*l.ResolveInterval)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Resolve interval is not an ISO8601 duration",
+				Paths:   []string{"resolveInterval"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*l.ResolveInterval, "resolveInterval"))
+		}
+	}
+
+	if l.EjectionTime != nil {
+		p, err := period.Parse(*l.EjectionTime)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Ejection time is not an ISO8601 duration",
+				Paths:   []string{"ejectionTime"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*l.EjectionTime, "ejectionTime"))
+		}
+	}
+
+	return
+}
+
+// HTTPClient tunes the connections used to deliver events. Non informed
+// fields use the broker settings.
+type HTTPClient struct {
+	// MaxIdleConns is the maximum number of idle connections. Zero means
+	// no limit.
+	MaxIdleConns *int32 `json:"maxIdleConns,omitempty"`
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept
+	// per host.
+	MaxIdleConnsPerHost *int32 `json:"maxIdleConnsPerHost,omitempty"`
+
+	// MaxConnsPerHost limits the number of connections per host, including
+	// those in use. Zero means no limit.
+	MaxConnsPerHost *int32 `json:"maxConnsPerHost,omitempty"`
+
+	// IdleConnTimeout is the time an idle connection is kept using ISO8601.
+	IdleConnTimeout *string `json:"idleConnTimeout,omitempty"`
+
+	// KeepAlive is the TCP keep-alive period using ISO8601.
+	KeepAlive *string `json:"keepAlive,omitempty"`
+
+	// DisableKeepAlives uses a new connection for each request.
+	DisableKeepAlives *bool `json:"disableKeepAlives,omitempty"`
+
+	// HTTP2 enables HTTP/2 for TLS targets.
+	HTTP2 *bool `json:"http2,omitempty"`
+}
+
+func (h *HTTPClient) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if h == nil {
+		return
+	}
+
+	if h.MaxIdleConns != nil && *h.MaxIdleConns < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*h.MaxIdleConns, "maxIdleConns"))
+	}
+
+	if h.MaxIdleConnsPerHost != nil && *h.MaxIdleConnsPerHost < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*h.MaxIdleConnsPerHost, "maxIdleConnsPerHost"))
+	}
+
+	if h.MaxConnsPerHost != nil && *h.MaxConnsPerHost < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*h.MaxConnsPerHost, "maxConnsPerHost"))
+	}
+
+	if h.IdleConnTimeout != nil {
+		if _, err := period.Parse(*h.IdleConnTimeout); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Idle connection timeout is not an ISO8601 duration",
+				Paths:   []string{"idleConnTimeout"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	if h.KeepAlive != nil {
+		if _, err := period.Parse(*h.KeepAlive); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Keep-alive period is not an ISO8601 duration",
+				Paths:   []string{"keepAlive"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	return
+}
+
+type Filter struct {
+	// All evaluates to true if all the nested expressions evaluate to true.
+	// It must contain at least one filter expression.
+	//
+	// +optional
+	All []Filter `json:"all,omitempty"`
+
+	// Any evaluates to true if at least one of the nested expressions evaluates
+	// to true. It must contain at least one filter expression.
+	//
+	// +optional
+	Any []Filter `json:"any,omitempty"`
+
+	// Not evaluates to true if the nested expression evaluates to false.
+	//
+	// +optional
+	Not *Filter `json:"not,omitempty"`
+
+	// Exact evaluates to true if the value of the matching CloudEvents
+	// attribute matches exactly the String value specified (case-sensitive).
+	// Exact must contain exactly one property, where the key is the name of the
+	// CloudEvents attribute to be matched, and its value is the String value to
+	// use in the comparison. The attribute name and value specified in the filter
+	// expression cannot be empty strings.
+	//
+	// +optional
+	Exact map[string]string `json:"exact,omitempty"`
+
+	// Prefix evaluates to true if the value of the matching CloudEvents
+	// attribute starts with the String value specified (case-sensitive). Prefix
+	// must contain exactly one property, where the key is the name of the
+	// CloudEvents attribute to be matched, and its value is the String value to
+	// use in the comparison. The attribute name and value specified in the filter
+	// expression cannot be empty strings.
+	//
+	// +optional
+	Prefix map[string]string `json:"prefix,omitempty"`
+
+	// Suffix evaluates to true if the value of the matching CloudEvents
+	// attribute ends with the String value specified (case-sensitive). Suffix
+	// must contain exactly one property, where the key is the name of the
+	// CloudEvents attribute to be matched, and its value is the String value to
+	// use in the comparison. The attribute name and value specified in the filter
+	// expression cannot be empty strings.
+	//
+	// +optional
+	Suffix map[string]string `json:"suffix,omitempty"`
+
+	// CESQL is a CloudEvents SQL expression that will be evaluated to true
+	// or false against each CloudEvent.
+	//
+	// +optional
+	CESQL string `json:"cesql,omitempty"`
+
+	// WASM evaluates to true if the WebAssembly module filter function
+	// returns a non zero value for the event.
+	//
+	// +optional
+	WASM *WASMFilter `json:"wasm,omitempty"`
+}
+
+// WASMFilter references a WebAssembly module that implements a filter.
+type WASMFilter struct {
+	// Module is the path to the .wasm file.
+	Module string `json:"module"`
+}
+
+func (w *WASMFilter) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if w == nil {
+		return
+	}
+
+	if w.Module == "" {
+		errs = errs.Also(apis.ErrMissingField("module"))
+	}
+
+	return
+}
+
+type Trigger struct {
+	Filters []Filter `json:"filters,omitempty"`
+	Target  Target   `json:"target"`
+
+	// Activation conditions for the Trigger to deliver events.
+	Activation *Activation `json:"activation,omitempty"`
+
+	// Ordering of the delivery of events to the target.
+	Ordering *Ordering `json:"ordering,omitempty"`
+
+	// Batching of the events delivered to the target.
+	Batching *Batching `json:"batching,omitempty"`
+
+	// Fixtures records the deliveries to the target, or asserts them
+	// against those recorded before.
+	Fixtures *Fixtures `json:"fixtures,omitempty"`
+
+	// Guards that events must conform to for being delivered to the
+	// target. Non conforming events are sent to the dead letter sinks.
+	Guards *Guards `json:"guards,omitempty"`
+
+	// Sampling delivers a subset of the events that pass the filters.
+	Sampling *Sampling `json:"sampling,omitempty"`
+
+	// Reply rewrites the events the target replies with before they are
+	// produced to the broker.
+	Reply *Reply `json:"reply,omitempty"`
+
+	// ReplyTarget receives the events the target replies with instead of
+	// the broker.
+	ReplyTarget *ReplyTarget `json:"replyTarget,omitempty"`
+
+	// Sequence of steps that the events the target replies with go
+	// through, each step receiving the event the previous one replied with.
+	Sequence []SequenceStep `json:"sequence,omitempty"`
+
+	// Owner is the consumer that registered the trigger, empty for
+	// triggers not registered by consumers.
+	Owner string `json:"owner,omitempty"`
+
+	// DeliveryGuarantee for the events dispatched to the trigger, best
+	// effort if not informed.
+	DeliveryGuarantee *DeliveryGuaranteeType `json:"deliveryGuarantee,omitempty"`
+
+	// StartingOffset is the position at the backend where the trigger
+	// starts consuming events from when created, latest if not informed.
+	// Triggers already known to the backend resume from their own cursor.
+	StartingOffset *StartingOffsetType `json:"startingOffset,omitempty"`
+
+	// Paused triggers do not dispatch events, which the backend retains
+	// until the trigger is resumed.
+	Paused bool `json:"paused,omitempty"`
+
+	// DryRun triggers evaluate their filters against the events without
+	// delivering them to the target, recording the events that matched.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// StartingOffsetType is the position at the backend where new triggers
+// start consuming events from.
+type StartingOffsetType string
+
+const (
+	// Consume the events ingested after the trigger is created.
+	StartingOffsetLatest StartingOffsetType = "latest"
+	// Consume the earliest event retained at the backend first.
+	StartingOffsetEarliest StartingOffsetType = "earliest"
+)
+
+// DeliveryGuaranteeType tells whether events that could not be delivered
+// to the target nor to the dead letter sinks are acknowledged to the
+// backend.
+type DeliveryGuaranteeType string
+
+const (
+	// Events are acknowledged to the backend regardless of the outcome of
+	// their delivery.
+	DeliveryGuaranteeBestEffort DeliveryGuaranteeType = "bestEffort"
+	// Events are only acknowledged when delivered to the target or to a
+	// dead letter sink, and dispatched again by the backend otherwise.
+	DeliveryGuaranteeAtLeastOnce DeliveryGuaranteeType = "atLeastOnce"
+)
+
+func (t *Trigger) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+
+	if t == nil {
+		return nil
+	}
+	errs = errs.Also(t.Target.Validate(ctx)).ViaField("target")
+	errs = errs.Also(t.Activation.Validate(ctx).ViaField("activation"))
+	errs = errs.Also(t.Ordering.Validate(ctx).ViaField("ordering"))
+	errs = errs.Also(t.Batching.Validate(ctx).ViaField("batching"))
+	errs = errs.Also(t.Fixtures.Validate(ctx).ViaField("fixtures"))
+	errs = errs.Also(t.Guards.Validate(ctx).ViaField("guards"))
+	errs = errs.Also(t.Sampling.Validate(ctx).ViaField("sampling"))
+	errs = errs.Also(t.Reply.Validate(ctx).ViaField("reply"))
+	errs = errs.Also(t.ReplyTarget.Validate(ctx).ViaField("replyTarget"))
+	for i := range t.Sequence {
+		errs = errs.Also(t.Sequence[i].Validate(ctx).ViaFieldIndex("sequence", i))
+	}
+
+	if t.DeliveryGuarantee != nil {
+		switch *t.DeliveryGuarantee {
+		case DeliveryGuaranteeBestEffort, DeliveryGuaranteeAtLeastOnce:
+		default:
+			errs = errs.Also(apis.ErrInvalidValue(*t.DeliveryGuarantee, "deliveryGuarantee"))
+		}
+	}
+
+	if t.StartingOffset != nil {
+		switch *t.StartingOffset {
+		case StartingOffsetLatest, StartingOffsetEarliest:
+		default:
+			errs = errs.Also(apis.ErrInvalidValue(*t.StartingOffset, "startingOffset"))
+		}
+	}
+
+	// Batches mix events that could belong to different replicas.
+	if t.Batching != nil && len(t.Target.ReplicaURLs) != 0 {
+		errs = errs.Also(apis.ErrMultipleOneOf("batching", "target.replicaURLs"))
+	}
+
+	// Events are published to Kafka, streams and AMQP one at a time.
+	if t.Batching != nil && t.Target.Kafka != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("batching", "target.kafka"))
+	}
+	if t.Batching != nil && t.Target.Stream != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("batching", "target.stream"))
+	}
+	if t.Batching != nil && t.Target.AMQP != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("batching", "target.amqp"))
+	}
+
+	// Batches mix events that could be routed to different URLs.
+	if t.Batching != nil && t.Target.URL != nil && urltemplate.IsTemplate(*t.Target.URL) {
+		errs = errs.Also(apis.ErrGeneric("Batching cannot be informed for templated target URLs", "batching"))
+	}
+	if t.Batching != nil && t.Target.Ref != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf("batching", "target.ref"))
+	}
+
+	// Sequences start with the reply of the target, which batched, Kafka,
+	// object store, stream and AMQP deliveries do not have.
+	if len(t.Sequence) != 0 {
+		switch {
+		case t.Batching != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("sequence", "batching"))
+		case t.Target.Kafka != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("sequence", "target.kafka"))
+		case t.Target.ObjectStore != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("sequence", "target.objectStore"))
+		case t.Target.Stream != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("sequence", "target.stream"))
+		case t.Target.AMQP != nil:
+			errs = errs.Also(apis.ErrMultipleOneOf("sequence", "target.amqp"))
+		}
+	}
+
+	return errs.Also(ValidateSubscriptionAPIFiltersList(ctx, t.Filters).ViaField("filters"))
+}
+
+// Placeholders that reply attribute rewrites can reference.
+var replyPlaceholders = strings.NewReplacer("{trigger}", "", "{source}", "", "{type}", "")
+
+// Reply rewrites the attributes of reply events, so that they can be told
+// apart from the events they reply to. Rewrites can reference the trigger
+// name, the original source and the original type as {trigger}, {source}
+// and {type}, for example {trigger}.{type} prefixes the type with the
+// trigger name.
+type Reply struct {
+	// Source replaces the source of reply events.
+	Source *string `json:"source,omitempty"`
+
+	// Type replaces the type of reply events.
+	Type *string `json:"type,omitempty"`
+}
+
+func (r *Reply) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	if r.Source == nil && r.Type == nil {
+		return apis.ErrMissingOneOf("source", "type")
+	}
+
+	check := func(v *string, field string) {
+		switch {
+		case v == nil:
+		case *v == "":
+			errs = errs.Also(apis.ErrInvalidValue(*v, field))
+		case strings.ContainsAny(replyPlaceholders.Replace(*v), "{}"):
+			errs = errs.Also(&apis.FieldError{
+				Message: "Only {trigger}, {source} and {type} can be referenced",
+				Paths:   []string{field},
+			})
+		}
+	}
+	check(r.Source, "source")
+	check(r.Type, "type")
+
+	return
+}
+
+// ReplyTarget is the destination of the events a target replies with. Each
+// reply forwarded decrements the hops remaining for the event, and replies
+// without hops remaining are dead lettered, which prevents events from
+// looping between targets.
+type ReplyTarget struct {
+	// URL of the destination, which must be relative when the ref is
+	// informed.
+	URL *string `json:"url,omitempty"`
+
+	// Ref to the Kubernetes object replies are sent to, whose address is
+	// resolved by the broker.
+	Ref *Reference `json:"ref,omitempty"`
+
+	// Filters that replies must match for being sent to the destination.
+	// Replies that do not match are produced to the broker.
+	Filters []Filter `json:"filters,omitempty"`
+}
+
+func (r *ReplyTarget) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if r == nil {
+		return
+	}
+
+	errs = validateDestination(ctx, r.URL, r.Ref, "Reply target")
+	return errs.Also(ValidateSubscriptionAPIFiltersList(ctx, r.Filters).ViaField("filters"))
+}
+
+// validateDestination validates destinations informed as an absolute URL, or
+// as a reference to a Kubernetes object along with an optional relative URL.
+func validateDestination(ctx context.Context, u *string, ref *Reference, name string) (errs *apis.FieldError) {
+	informed := u != nil && *u != ""
+	switch {
+	case ref != nil:
+		errs = errs.Also(ref.Validate(ctx).ViaField("ref"))
+		if informed {
+			if pu, err := url.Parse(*u); err != nil || pu.IsAbs() || pu.Host != "" {
+				errs = errs.Also(apis.ErrGeneric(name+" URL must be a relative URL when the ref is informed", "url"))
+			}
+		}
+	case !informed:
+		errs = errs.Also(apis.ErrMissingOneOf("url", "ref"))
+	default:
+		if pu, err := url.Parse(*u); err != nil || !pu.IsAbs() {
+			fe := &apis.FieldError{
+				Message: name + " URL must be an absolute URL",
+				Paths:   []string{"url"},
+			}
+			if err != nil {
+				fe.Details = err.Error()
+			}
+			errs = errs.Also(fe)
+		}
+	}
+
+	return errs
+}
+
+// SequenceStep is a destination that the event the previous step replied
+// with is sent to, the trigger target being the first step. The event the
+// last step replies with is handled as the trigger target replies are.
+type SequenceStep struct {
+	// URL of the step, which must be relative when the ref is informed.
+	URL *string `json:"url,omitempty"`
+
+	// Ref to the Kubernetes object of the step, whose address is resolved
+	// by the broker.
+	Ref *Reference `json:"ref,omitempty"`
+
+	// Filters that events must match for being sent to the step. Events
+	// that do not match skip the step.
+	Filters []Filter `json:"filters,omitempty"`
+
+	// OnError receives the events the step does not accept, ending the
+	// sequence. The delivery fails if not informed.
+	OnError *SequenceDestination `json:"onError,omitempty"`
+}
+
+func (s *SequenceStep) Validate(ctx context.Context) (errs *apis.FieldError) {
+	errs = validateDestination(ctx, s.URL, s.Ref, "Sequence step")
+	if s.OnError != nil {
+		errs = errs.Also(validateDestination(ctx, s.OnError.URL, s.OnError.Ref, "Sequence error").ViaField("onError"))
+	}
+	return errs.Also(ValidateSubscriptionAPIFiltersList(ctx, s.Filters).ViaField("filters"))
+}
+
+// SequenceDestination is the destination of the events that a sequence step
+// does not accept.
+type SequenceDestination struct {
+	// URL of the destination, which must be relative when the ref is
+	// informed.
+	URL *string `json:"url,omitempty"`
+
+	// Ref to the Kubernetes object events are sent to, whose address is
+	// resolved by the broker.
+	Ref *Reference `json:"ref,omitempty"`
+}
+
+// Guards protect targets from events they cannot handle.
+type Guards struct {
+	// MaxPayloadSize is the maximum size in bytes of the event data.
+	MaxPayloadSize *int32 `json:"maxPayloadSize,omitempty"`
+
+	// RequiredFields are the fields that the JSON event data must inform,
+	// nested fields being separated by dots, for example order.id.
+	RequiredFields []string `json:"requiredFields,omitempty"`
+}
+
+// Sampling delivers a subset of the events, decided on their ID so that
+// every broker replica delivers the same events. Either a percentage or a
+// number of events to deliver one of must be informed.
+type Sampling struct {
+	// Percentage of the events delivered, from 0 to 100.
+	Percentage *float64 `json:"percentage,omitempty"`
+
+	// Every delivers one of every number of events, on average.
+	Every *int32 `json:"every,omitempty"`
+}
+
+func (s *Sampling) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if s == nil {
+		return
+	}
+
+	switch {
+	case s.Percentage == nil && s.Every == nil:
+		errs = errs.Also(apis.ErrMissingOneOf("percentage", "every"))
+	case s.Percentage != nil && s.Every != nil:
+		errs = errs.Also(apis.ErrMultipleOneOf("percentage", "every"))
+	}
+
+	if s.Percentage != nil && (*s.Percentage < 0 || *s.Percentage > 100) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*s.Percentage, 0, 100, "percentage"))
+	}
+	if s.Every != nil && *s.Every < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*s.Every, "every"))
+	}
+
+	return
+}
+
+func (g *Guards) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if g == nil {
+		return
+	}
+
+	if g.MaxPayloadSize != nil && *g.MaxPayloadSize < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*g.MaxPayloadSize, "maxPayloadSize"))
+	}
+
+	for i, f := range g.RequiredFields {
+		for _, segment := range strings.Split(f, ".") {
+			if segment == "" {
+				errs = errs.Also(apis.ErrInvalidArrayValue(f, "requiredFields", i))
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// FixturesMode is the way deliveries are handled as fixtures.
+type FixturesMode string
+
+const (
+	// FixturesModeRecord stores each delivery at the backend as the
+	// expected output for the event.
+	FixturesModeRecord FixturesMode = "record"
+	// FixturesModeAssert compares each delivery with the one recorded for
+	// the event.
+	FixturesModeAssert FixturesMode = "assert"
+)
+
+// Fixtures keep the deliveries of events to the target, the event sent and
+// the target response, indexed by the ID of the event. Replaying the same
+// events after changing the broker configuration or the target asserts the
+// deliveries against those recorded, which supports regression testing.
+type Fixtures struct {
+	Mode FixturesMode `json:"mode"`
+
+	// IgnoreAttributes are the CloudEvents attributes and extensions that
+	// are not compared when asserting, like those generated on each run.
+	IgnoreAttributes []string `json:"ignoreAttributes,omitempty"`
+}
+
+func (f *Fixtures) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if f == nil {
+		return
+	}
+
+	switch f.Mode {
+	case FixturesModeRecord, FixturesModeAssert:
+	case "":
+		errs = errs.Also(apis.ErrMissingField("mode"))
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(f.Mode, "mode"))
+	}
+
+	return
+}
+
+// Batching accumulates events that are delivered to the target in a single
+// request using the CloudEvents batched content mode. When the target does
+// not accept the batch each event is delivered on its own.
+type Batching struct {
+	// MaxCount is the maximum number of events in a batch.
+	MaxCount int32 `json:"maxCount"`
+
+	// MaxLatency is the maximum time events wait for the batch to be
+	// completed using ISO8601. Defaults to 1 second.
+	MaxLatency *string `json:"maxLatency,omitempty"`
+}
+
+func (b *Batching) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if b == nil {
+		return
+	}
+
+	if b.MaxCount < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(b.MaxCount, "maxCount"))
+	}
+
+	if b.MaxLatency != nil {
+		p, err := period.Parse(*b.MaxLatency)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Max latency is not an ISO8601 duration",
+				Paths:   []string{"maxLatency"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*b.MaxLatency, "maxLatency"))
+		}
+	}
+
+	return
+}
+
+// Ordering makes events that share a partition key to be delivered to the
+// target strictly in order, each event waiting for the previous one with the
+// same key to be delivered, retried and sent to dead letter sinks.
+type Ordering struct {
+	// Key is the CloudEvents attribute or extension name used as partition
+	// key. Events that do not inform it are delivered in any order.
+	Key string `json:"key,omitempty"`
+
+	// Expression is a Go template that computes the partition key from the
+	// event attributes and extensions, and the JSON payload at the data
+	// field. Events for which the template fails or renders an empty key
+	// are delivered in any order. Only one of Key or Expression must be
+	// informed.
+	Expression string `json:"expression,omitempty"`
+}
+
+func (o *Ordering) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if o == nil {
+		return
+	}
+
+	switch {
+	case o.Key == "" && o.Expression == "":
+		errs = errs.Also(apis.ErrMissingOneOf("key", "expression"))
+	case o.Key != "" && o.Expression != "":
+		errs = errs.Also(apis.ErrMultipleOneOf("key", "expression"))
+	case o.Expression != "":
+		if _, err := template.New("ordering").Parse(o.Expression); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Expression is not a valid template",
+				Paths:   []string{"expression"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	return
+}
+
+// Activation makes a Trigger active only when external signals allow it,
+// events received while the Trigger is inactive are not delivered. All
+// informed conditions must be met for the Trigger to be active.
+type Activation struct {
+	// Period for evaluating the conditions using ISO8601. Defaults to 10 seconds.
+	Period *string `json:"period,omitempty"`
+
+	// TimeWindows where the Trigger is active. Any of them must contain
+	// the current time.
+	TimeWindows []TimeWindow `json:"timeWindows,omitempty"`
+
+	// File whose content must be "true", like a mounted ConfigMap key.
+	File *string `json:"file,omitempty"`
+
+	// URL, like a feature flag service endpoint, that must respond to GET
+	// requests with a 2xx status code.
+	URL *string `json:"url,omitempty"`
+}
+
+func (a *Activation) Validate(ctx context.Context) (errs *apis.FieldError) {
+	if a == nil {
+		return
+	}
+
+	if a.Period != nil {
+		p, err := period.Parse(*a.Period)
+		switch {
+		case err != nil:
+			errs = errs.Also(&apis.FieldError{
+				Message: "Period is not an ISO8601 duration",
+				Paths:   []string{"period"},
+				Details: err.Error(),
+			})
+		case p.DurationApprox() <= 0:
+			errs = errs.Also(apis.ErrInvalidValue(*a.Period, "period"))
+		}
+	}
+
+	for i, tw := range a.TimeWindows {
+		errs = errs.Also(tw.Validate(ctx).ViaFieldIndex("timeWindows", i))
+	}
+
+	if a.File != nil && *a.File == "" {
+		errs = errs.Also(apis.ErrInvalidValue(*a.File, "file"))
+	}
+
+	if a.URL != nil {
+		if _, err := url.ParseRequestURI(*a.URL); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Activation URL cannot be parsed",
+				Paths:   []string{"url"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	return
+}
+
+// TimeWindow is a daily time range, which ends the next day if End is
+// earlier than Start.
+type TimeWindow struct {
+	// Days of the week where the window applies, using their three letter
+	// lowercase English name. Empty means every day.
+	Days []string `json:"days,omitempty"`
+
+	// Start and End of the window using the 24 hour HH:MM format.
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// TimeZone as an IANA name. Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// TimeWindowLayout is the format for the start and end of time windows.
+const TimeWindowLayout = "15:04"
+
+// WeekDays maps the names used at time windows to week days.
+var WeekDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func (w *TimeWindow) Validate(ctx context.Context) (errs *apis.FieldError) {
+	for i, d := range w.Days {
+		if _, ok := WeekDays[d]; !ok {
+			errs = errs.Also(apis.ErrInvalidArrayValue(d, "days", i))
+		}
+	}
+
+	if _, err := time.Parse(TimeWindowLayout, w.Start); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(w.Start, "start"))
+	}
+
+	if _, err := time.Parse(TimeWindowLayout, w.End); err != nil {
+		errs = errs.Also(apis.ErrInvalidValue(w.End, "end"))
+	}
+
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		errs = errs.Also(&apis.FieldError{
+			Message: "Unknown time zone",
+			Paths:   []string{"timeZone"},
+			Details: err.Error(),
+		})
+	}
+
+	return
+}
+
+type Config struct {
+	Ingest   *Ingest            `json:"ingest,omitempty"`
+	Triggers map[string]Trigger `json:"triggers"`
+
+	// Brokers hosted by the same process along with the default broker,
+	// indexed by the name that identifies their ingest path.
+	Brokers map[string]Broker `json:"brokers,omitempty"`
+
+	// Features enables or disables experimental behaviors, indexed by
+	// the feature flag name.
+	Features map[string]bool `json:"features,omitempty"`
+
+	// DeletedTriggers are triggers deleted through the admin API that can
+	// be restored until their deletion grace period expires, indexed by
+	// name.
+	DeletedTriggers map[string]DeletedTrigger `json:"deletedTriggers,omitempty"`
+
+	// Registration allows consumers to register their own triggers.
+	Registration *Registration `json:"registration,omitempty"`
+}
+
+func (c *Config) Validate(ctx context.Context) *apis.FieldError {
+	if c == nil {
+		return nil
+	}
+
+	errs := c.Ingest.Validate(ctx).ViaField("ingest")
+
+	for k, t := range c.Triggers {
+		errs = errs.Also(t.Validate(ctx).ViaFieldKey("triggers", k))
+	}
+
+	for k, b := range c.Brokers {
+		if msgs := validation.IsDNS1123Label(k); len(msgs) != 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Broker name must be a DNS label",
+				Paths:   []string{apis.CurrentField},
+				Details: strings.Join(msgs, ", "),
+			}).ViaFieldKey("brokers", k)
+		}
+		errs = errs.Also(b.Validate(ctx).ViaFieldKey("brokers", k))
+	}
+
+	for k, d := range c.DeletedTriggers {
+		if _, ok := c.Triggers[k]; ok {
+			errs = errs.Also(apis.ErrGeneric("Deleted trigger name is used by an existing trigger", apis.CurrentField).
+				ViaFieldKey("deletedTriggers", k))
+		}
+		errs = errs.Also(d.Validate(ctx).ViaFieldKey("deletedTriggers", k))
+	}
+
+	errs = errs.Also(c.Registration.Validate(ctx).ViaField("registration"))
+
+	for k := range c.Features {
+		if msgs := validation.IsDNS1123Label(k); len(msgs) != 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Feature flag name must be a DNS label",
+				Paths:   []string{apis.CurrentField},
+				Details: strings.Join(msgs, ", "),
+			}).ViaFieldKey("features", k)
+		}
+	}
+
+	return errs
+}
+
+// DeletedTrigger is a trigger retained after being deleted, along with
+// its position at the backend.
+type DeletedTrigger struct {
+	Trigger   Trigger   `json:"trigger"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+func (d *DeletedTrigger) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+	if d.DeletedAt.IsZero() {
+		errs = errs.Also(apis.ErrMissingField("deletedAt"))
+	}
+	return errs.Also(d.Trigger.Validate(ctx).ViaField("trigger"))
+}
+
+// Broker is a logical broker that ingests events at its own path and
+// stores them apart from other brokers.
+type Broker struct {
+	Triggers map[string]Trigger `json:"triggers"`
+}
+
+func (b *Broker) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+	for k, t := range b.Triggers {
+		errs = errs.Also(t.Validate(ctx).ViaFieldKey("triggers", k))
+	}
+	return errs
+}
+
+// Registration allows consumers to register their own triggers through the
+// admin API, proving their identity with a token. Registered triggers are
+// named after the consumer identity and the name informed by the consumer,
+// separated by a dot.
+type Registration struct {
+	// Consumers allowed to register triggers, indexed by their identity.
+	Consumers map[string]Consumer `json:"consumers"`
+}
+
+func (r *Registration) Validate(ctx context.Context) *apis.FieldError {
+	if r == nil {
+		return nil
+	}
+
+	var errs *apis.FieldError
+	for k, c := range r.Consumers {
+		if msgs := validation.IsDNS1123Label(k); len(msgs) != 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Consumer identity must be a DNS label",
+				Paths:   []string{apis.CurrentField},
+				Details: strings.Join(msgs, ", "),
+			}).ViaFieldKey("consumers", k)
+		}
+		errs = errs.Also(c.Validate(ctx).ViaFieldKey("consumers", k))
+	}
+	return errs
+}
+
+// Consumer that registers its own triggers.
+type Consumer struct {
+	// Token the consumer informs as a bearer token to prove its identity.
+	Token SecretSource `json:"token"`
+
+	// MaxTriggers is the number of triggers the consumer can register.
+	MaxTriggers int `json:"maxTriggers"`
+
+	// TargetURLPrefixes restrict the URLs registered triggers deliver to,
+	// which must have the scheme and host of one of them, and a path under
+	// its path. Any URL is allowed if empty.
+	TargetURLPrefixes []string `json:"targetURLPrefixes,omitempty"`
+
+	// DeliveryOptions for the targets of registered triggers.
+	DeliveryOptions *DeliveryOptions `json:"deliveryOptions,omitempty"`
+}
+
+func (c *Consumer) Validate(ctx context.Context) *apis.FieldError {
+	errs := c.Token.Validate(ctx).ViaField("token")
+
+	if c.MaxTriggers < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(c.MaxTriggers, "maxTriggers", "must be greater than zero"))
+	}
+
+	for i, p := range c.TargetURLPrefixes {
+		if u, err := url.Parse(p); err != nil || !u.IsAbs() || u.Host == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(p, "targetURLPrefixes", i))
+		}
+	}
+
+	return errs.Also(c.DeliveryOptions.Validate(ctx).ViaField("deliveryOptions"))
+}
+
+// AllowsURL returns true if registered triggers can deliver to the URL.
+// Prefixes are compared per URL component, so that neither hosts nor path
+// segments that only start like the prefix are allowed.
+func (c *Consumer) AllowsURL(u string) bool {
+	if len(c.TargetURLPrefixes) == 0 {
+		return true
+	}
+
+	target, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	p := target.EscapedPath()
+	if p != "" {
+		p = path.Clean(p)
+	}
+
+	for _, prefix := range c.TargetURLPrefixes {
+		pu, err := url.Parse(prefix)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(target.Scheme, pu.Scheme) || !strings.EqualFold(target.Host, pu.Host) {
+			continue
+		}
+		if pp := strings.TrimSuffix(pu.EscapedPath(), "/"); pp == "" || p == pp || strings.HasPrefix(p, pp+"/") {
+			return true
+		}
+	}
+	return false
 }
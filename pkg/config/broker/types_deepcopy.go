@@ -53,6 +53,11 @@ func (in *Filter) DeepCopyInto(out *Filter) {
 			(*out)[key] = val
 		}
 	}
+	if in.WASM != nil {
+		in, out := &in.WASM, &out.WASM
+		*out = new(WASMFilter)
+		**out = **in
+	}
 	return
 }
 
@@ -71,6 +71,8 @@ func ValidateSubscriptionAPIFilter(ctx context.Context, filter *Filter) (errs *a
 		ValidateSubscriptionAPIFiltersList(ctx, filter.Any).ViaField("any"),
 	).Also(
 		ValidateSubscriptionAPIFilter(ctx, filter.Not).ViaField("not"),
+	).Also(
+		filter.WASM.Validate(ctx).ViaField("wasm"),
 	)
 	return errs
 }
@@ -115,7 +117,21 @@ func hasMultipleDialects(filter *Filter) bool {
 			dialectFound = true
 		}
 	}
-	if filter.Not != nil && dialectFound {
+	if filter.Not != nil {
+		if dialectFound {
+			return true
+		} else {
+			dialectFound = true
+		}
+	}
+	if filter.CESQL != "" {
+		if dialectFound {
+			return true
+		} else {
+			dialectFound = true
+		}
+	}
+	if filter.WASM != nil && dialectFound {
 		return true
 	}
 
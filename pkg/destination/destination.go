@@ -0,0 +1,131 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package destination resolves the address of the Kubernetes objects that
+// Trigger targets reference, so that configurations do not need to inform
+// cluster URLs. Resolvers are pluggable, the broker providing one that
+// uses the cluster DNS naming for Services and one that reads the address
+// of Addressables from the Kubernetes API.
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// ErrNotAddressable is returned when the referenced object does not inform
+// an address.
+var ErrNotAddressable = errors.New("object is not addressable")
+
+// Resolver returns the URL of referenced objects.
+type Resolver interface {
+	Resolve(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error)
+}
+
+// ResolverFunc is a function that implements Resolver.
+type ResolverFunc func(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+	return f(ctx, ref)
+}
+
+// Invalidator is an optional interface for resolvers that keep resolved
+// addresses, which are discarded when deliveries to them fail.
+type Invalidator interface {
+	Invalidate(ref *cfgbroker.Reference)
+}
+
+type cacheEntry struct {
+	url     *url.URL
+	expires time.Time
+}
+
+// Cache keeps the addresses resolved by other resolver for the TTL, or
+// until invalidated when zero. Failed resolutions are not kept.
+type Cache struct {
+	resolver Resolver
+	ttl      time.Duration
+
+	entries map[cfgbroker.Reference]cacheEntry
+	m       sync.Mutex
+}
+
+var _ Invalidator = (*Cache)(nil)
+
+// NewCache returns a resolver that caches the addresses resolved.
+func NewCache(r Resolver, ttl time.Duration) *Cache {
+	return &Cache{
+		resolver: r,
+		ttl:      ttl,
+		entries:  make(map[cfgbroker.Reference]cacheEntry),
+	}
+}
+
+func (c *Cache) Resolve(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+	now := time.Now()
+
+	c.m.Lock()
+	e, ok := c.entries[*ref]
+	c.m.Unlock()
+	if ok && (c.ttl == 0 || now.Before(e.expires)) {
+		return e.url, nil
+	}
+
+	u, err := c.resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	c.entries[*ref] = cacheEntry{url: u, expires: now.Add(c.ttl)}
+	c.m.Unlock()
+
+	return u, nil
+}
+
+// Invalidate discards the address kept for the reference, which is resolved
+// again next time.
+func (c *Cache) Invalidate(ref *cfgbroker.Reference) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	delete(c.entries, *ref)
+}
+
+// namespaceOf returns the namespace of the reference, or the default one.
+func namespaceOf(ref *cfgbroker.Reference, def string) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return def
+}
+
+// isService returns whether the reference is a core Service.
+func isService(ref *cfgbroker.Reference) bool {
+	return ref.Kind == "Service" && (ref.APIVersion == "" || ref.APIVersion == "v1")
+}
+
+// DNS resolves references to Services using the cluster DNS naming, which
+// does not require access to the Kubernetes API.
+type DNS struct {
+	// Domain of the cluster, usually cluster.local.
+	Domain string
+	// Namespace of the references that do not inform it.
+	Namespace string
+}
+
+func (d *DNS) Resolve(_ context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+	if !isService(ref) {
+		return nil, fmt.Errorf("%s: only Services can be resolved using DNS", ref)
+	}
+
+	return &url.URL{
+		Scheme: "http",
+		Host:   ref.Name + "." + namespaceOf(ref, d.Namespace) + ".svc." + d.Domain,
+	}, nil
+}
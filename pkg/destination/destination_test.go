@@ -0,0 +1,142 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package destination
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestDNS(t *testing.T) {
+	d := &DNS{Domain: "cluster.local", Namespace: "brokers"}
+
+	u, err := d.Resolve(context.Background(), &cfgbroker.Reference{Kind: "Service", Name: "display"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://display.brokers.svc.cluster.local", u.String())
+
+	u, err = d.Resolve(context.Background(), &cfgbroker.Reference{APIVersion: "v1", Kind: "Service", Name: "display", Namespace: "apps"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://display.apps.svc.cluster.local", u.String())
+
+	_, err = d.Resolve(context.Background(), &cfgbroker.Reference{APIVersion: "serving.knative.dev/v1", Kind: "Service", Name: "display"})
+	assert.Error(t, err, "Only core Services can be resolved")
+}
+
+func TestCache(t *testing.T) {
+	resolved := 0
+	var fail bool
+	r := ResolverFunc(func(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+		resolved++
+		if fail {
+			return nil, errors.New("resolution failed")
+		}
+		return &url.URL{Scheme: "http", Host: ref.Name}, nil
+	})
+
+	ref := &cfgbroker.Reference{Kind: "Service", Name: "display"}
+
+	t.Run("kept until invalidated", func(t *testing.T) {
+		resolved = 0
+		c := NewCache(r, 0)
+
+		for i := 0; i < 3; i++ {
+			u, err := c.Resolve(context.Background(), ref)
+			require.NoError(t, err)
+			assert.Equal(t, "http://display", u.String())
+		}
+		assert.Equal(t, 1, resolved)
+
+		c.Invalidate(ref)
+		_, err := c.Resolve(context.Background(), ref)
+		require.NoError(t, err)
+		assert.Equal(t, 2, resolved)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		resolved = 0
+		c := NewCache(r, time.Millisecond)
+
+		_, err := c.Resolve(context.Background(), ref)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = c.Resolve(context.Background(), ref)
+		require.NoError(t, err)
+		assert.Equal(t, 2, resolved)
+	})
+
+	t.Run("failures not kept", func(t *testing.T) {
+		resolved = 0
+		fail = true
+		c := NewCache(r, 0)
+
+		_, err := c.Resolve(context.Background(), ref)
+		assert.Error(t, err)
+
+		fail = false
+		_, err = c.Resolve(context.Background(), ref)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resolved)
+	})
+}
+
+// fakeReader returns the objects indexed by kind, namespace and name.
+type fakeReader map[string]map[string]interface{}
+
+func (f fakeReader) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	u := obj.(*unstructured.Unstructured)
+	o, ok := f[u.GetKind()+"/"+key.Namespace+"/"+key.Name]
+	if !ok {
+		return errors.New("not found")
+	}
+	u.Object = o
+	return nil
+}
+
+func (f fakeReader) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("not implemented")
+}
+
+func TestKubernetes(t *testing.T) {
+	objects := fakeReader{
+		"Service/brokers/display": {
+			"status": map[string]interface{}{
+				"address": map[string]interface{}{"url": "http://display.brokers.svc.cluster.local"},
+			},
+		},
+		"Broker/brokers/default": {
+			"status": map[string]interface{}{},
+		},
+	}
+	k := NewKubernetes(objects, "cluster.local", "brokers")
+
+	u, err := k.Resolve(context.Background(), &cfgbroker.Reference{
+		APIVersion: "serving.knative.dev/v1", Kind: "Service", Name: "display",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://display.brokers.svc.cluster.local", u.String())
+
+	u, err = k.Resolve(context.Background(), &cfgbroker.Reference{Kind: "Service", Name: "other", Namespace: "apps"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://other.apps.svc.cluster.local", u.String(), "Core Services must be resolved using DNS")
+
+	_, err = k.Resolve(context.Background(), &cfgbroker.Reference{
+		APIVersion: "eventing.knative.dev/v1", Kind: "Broker", Name: "default",
+	})
+	assert.ErrorIs(t, err, ErrNotAddressable)
+
+	_, err = k.Resolve(context.Background(), &cfgbroker.Reference{
+		APIVersion: "eventing.knative.dev/v1", Kind: "Broker", Name: "missing",
+	})
+	assert.Error(t, err)
+}
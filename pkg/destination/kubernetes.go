@@ -0,0 +1,77 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package destination
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Kubernetes resolves references to Addressables reading the URL informed
+// at their status, as Knative does. Services are resolved using DNS.
+type Kubernetes struct {
+	client client.Reader
+	dns    *DNS
+}
+
+// NewKubernetes returns a resolver that reads objects using the client.
+func NewKubernetes(c client.Reader, domain, namespace string) *Kubernetes {
+	return &Kubernetes{
+		client: c,
+		dns:    &DNS{Domain: domain, Namespace: namespace},
+	}
+}
+
+// NewInClusterKubernetes returns a resolver that reads objects using the
+// credentials of the broker pod or the local kubeconfig.
+func NewInClusterKubernetes(domain, namespace string) (*Kubernetes, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not read Kubernetes client configuration: %w", err)
+	}
+
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create Kubernetes client: %w", err)
+	}
+
+	return NewKubernetes(c, domain, namespace), nil
+}
+
+func (k *Kubernetes) Resolve(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+	if isService(ref) {
+		return k.dns.Resolve(ctx, ref)
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%s: API version is not valid: %w", ref, err)
+	}
+
+	o := &unstructured.Unstructured{}
+	o.SetGroupVersionKind(gv.WithKind(ref.Kind))
+	key := client.ObjectKey{Namespace: namespaceOf(ref, k.dns.Namespace), Name: ref.Name}
+	if err := k.client.Get(ctx, key, o); err != nil {
+		return nil, fmt.Errorf("%s: could not read object: %w", ref, err)
+	}
+
+	s, _, err := unstructured.NestedString(o.Object, "status", "address", "url")
+	if err != nil || s == "" {
+		return nil, fmt.Errorf("%s: %w", ref, ErrNotAddressable)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: address cannot be parsed: %w", ref, err)
+	}
+	return u, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package features gates experimental behaviors that can be enabled or
+// disabled per broker at runtime through the broker configuration, so
+// that redesigned components can be rolled out incrementally.
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Flag describes a gated behavior.
+type Flag struct {
+	Name        string
+	Description string
+
+	// Default state when the configuration does not inform the flag.
+	Default bool
+}
+
+var (
+	registry = map[string]Flag{}
+	rm       sync.RWMutex
+)
+
+// Register a flag that can be informed at the broker configuration.
+// Components should register their flags at package initialization.
+func Register(f Flag) {
+	rm.Lock()
+	defer rm.Unlock()
+
+	if msgs := validation.IsDNS1123Label(f.Name); len(msgs) != 0 {
+		panic(fmt.Sprintf("feature flag name %q is not valid: %v", f.Name, msgs))
+	}
+	if _, ok := registry[f.Name]; ok {
+		panic(fmt.Sprintf("feature flag %q is already registered", f.Name))
+	}
+	registry[f.Name] = f
+}
+
+func lookup(name string) (Flag, bool) {
+	rm.RLock()
+	defer rm.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// FlagStatus informs the state of a flag.
+type FlagStatus struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// Set keeps the state of the registered flags, which is updated from
+// the broker configuration.
+type Set struct {
+	// map[string]bool with the flags informed at the configuration.
+	configured atomic.Value
+
+	logger *zap.SugaredLogger
+}
+
+// NewSet returns a set where all flags are at their default state.
+func NewSet(logger *zap.SugaredLogger) *Set {
+	s := &Set{logger: logger}
+	s.configured.Store(map[string]bool{})
+	return s
+}
+
+// Enabled returns whether the flag is enabled. Flags that are not
+// registered are never enabled, and a nil set keeps every flag at its
+// default state.
+func (s *Set) Enabled(name string) bool {
+	f, ok := lookup(name)
+	if !ok {
+		return false
+	}
+	if s == nil {
+		return f.Default
+	}
+	if v, ok := s.configured.Load().(map[string]bool)[name]; ok {
+		return v
+	}
+	return f.Default
+}
+
+// UpdateFromConfig applies the flags informed at the configuration.
+// Flags that are not registered are ignored, which lets configurations
+// be shared by brokers running different versions.
+func (s *Set) UpdateFromConfig(c *cfgbroker.Config) {
+	configured := make(map[string]bool, len(c.Features))
+	for name, v := range c.Features {
+		if _, ok := lookup(name); !ok {
+			s.logger.Warnw("Ignoring unknown feature flag", zap.String("flag", name))
+			continue
+		}
+		configured[name] = v
+	}
+
+	for _, fs := range s.statusWith(configured) {
+		if was := s.Enabled(fs.Name); was != fs.Enabled {
+			s.logger.Infow("Feature flag updated", zap.String("flag", fs.Name), zap.Bool("enabled", fs.Enabled))
+		}
+	}
+
+	s.configured.Store(configured)
+}
+
+// Status returns the state of every registered flag, sorted by name.
+func (s *Set) Status() []FlagStatus {
+	return s.statusWith(s.configured.Load().(map[string]bool))
+}
+
+func (s *Set) statusWith(configured map[string]bool) []FlagStatus {
+	rm.RLock()
+	defer rm.RUnlock()
+
+	st := make([]FlagStatus, 0, len(registry))
+	for _, f := range registry {
+		enabled := f.Default
+		if v, ok := configured[f.Name]; ok {
+			enabled = v
+		}
+		st = append(st, FlagStatus{
+			Name:        f.Name,
+			Description: f.Description,
+			Default:     f.Default,
+			Enabled:     enabled,
+		})
+	}
+
+	sort.Slice(st, func(i, j int) bool { return st[i].Name < st[j].Name })
+	return st
+}
+
+// Handler serves the state of the flags.
+func Handler(s *Set) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Status())
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func init() {
+	Register(Flag{Name: "test-opt-in", Description: "Disabled unless informed."})
+	Register(Flag{Name: "test-opt-out", Description: "Enabled unless informed.", Default: true})
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet(zap.NewNop().Sugar())
+
+	assert.False(t, s.Enabled("test-opt-in"))
+	assert.True(t, s.Enabled("test-opt-out"))
+	assert.False(t, s.Enabled("test-unknown"))
+
+	s.UpdateFromConfig(&cfgbroker.Config{Features: map[string]bool{
+		"test-opt-in":  true,
+		"test-opt-out": false,
+		"test-unknown": true,
+	}})
+
+	assert.True(t, s.Enabled("test-opt-in"))
+	assert.False(t, s.Enabled("test-opt-out"))
+	assert.False(t, s.Enabled("test-unknown"), "Unknown flags must be ignored")
+
+	// Flags not informed anymore go back to their default state.
+	s.UpdateFromConfig(&cfgbroker.Config{})
+
+	assert.False(t, s.Enabled("test-opt-in"))
+	assert.True(t, s.Enabled("test-opt-out"))
+
+	// A nil set keeps the default state.
+	s = nil
+	assert.False(t, s.Enabled("test-opt-in"))
+	assert.True(t, s.Enabled("test-opt-out"))
+}
+
+func TestRegister(t *testing.T) {
+	assert.Panics(t, func() { Register(Flag{Name: "test-opt-in"}) })
+	assert.Panics(t, func() { Register(Flag{Name: "Not_Valid"}) })
+}
+
+func TestHandler(t *testing.T) {
+	s := NewSet(zap.NewNop().Sugar())
+	s.UpdateFromConfig(&cfgbroker.Config{Features: map[string]bool{"test-opt-in": true}})
+
+	rec := httptest.NewRecorder()
+	Handler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/features", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var st []FlagStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	assert.Equal(t, []FlagStatus{
+		{Name: "test-opt-in", Description: "Disabled unless informed.", Enabled: true},
+		{Name: "test-opt-out", Description: "Enabled unless informed.", Default: true, Enabled: true},
+	}, st)
+
+	rec = httptest.NewRecorder()
+	Handler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/features", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
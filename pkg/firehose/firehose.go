@@ -0,0 +1,224 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package firehose streams dispatch decisions and delivery outcomes to
+// real time observers.
+package firehose
+
+import (
+	"errors"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Decision taken by a trigger for an event.
+type Decision string
+
+const (
+	// DecisionInactive is informed when the trigger activation conditions are not met.
+	DecisionInactive Decision = "inactive"
+	// DecisionFiltered is informed when the event does not pass the trigger filters.
+	DecisionFiltered Decision = "filtered"
+	// DecisionExpired is informed when the event exceeded its time to live.
+	DecisionExpired Decision = "expired"
+	// DecisionGuarded is informed when the event does not conform to the trigger guards.
+	DecisionGuarded Decision = "guarded"
+	// DecisionSampledOut is informed when the event is not part of the trigger sample.
+	DecisionSampledOut Decision = "sampled-out"
+	// DecisionDryRun is informed when the event matched a dry run trigger, which does not deliver it.
+	DecisionDryRun Decision = "dry-run"
+	// DecisionCircuitOpen is informed when the target circuit breaker is open.
+	DecisionCircuitOpen Decision = "circuit-open"
+	// DecisionDelivery is informed for each delivery to a target or dead letter sink.
+	DecisionDelivery Decision = "delivery"
+)
+
+const (
+	// Records buffered per observer, further records are dropped if the
+	// observer cannot keep up.
+	observerBufferSize = 256
+
+	// Default maximum number of records per second sent to an observer.
+	defaultMaxRate = 100
+)
+
+// Record of a dispatch decision.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Trigger     string    `json:"trigger"`
+	Decision    Decision  `json:"decision"`
+	EventID     string    `json:"eventId"`
+	EventSource string    `json:"eventSource"`
+	EventType   string    `json:"eventType"`
+
+	// Delivery outcome fields.
+	Target    string  `json:"target,omitempty"`
+	Outcome   string  `json:"outcome,omitempty"`
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Filter selects the records sent to an observer.
+type Filter struct {
+	// Trigger name, any trigger if empty.
+	Trigger string
+	// Event type prefix, any type if empty.
+	TypePrefix string
+	// Decisions to be informed, all if empty.
+	Decisions map[Decision]struct{}
+	// Sample ratio of the matching records, from 0 to 1.
+	Sample float64
+	// MaxRate is the maximum number of records per second.
+	MaxRate float64
+}
+
+// ParseFilter reads the filter from the query parameters trigger, type,
+// decision (comma separated), sample and rate.
+func ParseFilter(q url.Values) (*Filter, error) {
+	f := &Filter{
+		Trigger:    q.Get("trigger"),
+		TypePrefix: q.Get("type"),
+		Sample:     1,
+		MaxRate:    defaultMaxRate,
+	}
+
+	if d := q.Get("decision"); d != "" {
+		f.Decisions = make(map[Decision]struct{})
+		for _, v := range strings.Split(d, ",") {
+			f.Decisions[Decision(strings.TrimSpace(v))] = struct{}{}
+		}
+	}
+
+	if s := q.Get("sample"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > 1 {
+			return nil, errors.New("sample must be a number from 0 to 1")
+		}
+		f.Sample = v
+	}
+
+	if r := q.Get("rate"); r != "" {
+		v, err := strconv.ParseFloat(r, 64)
+		if err != nil || v <= 0 || v > defaultMaxRate {
+			return nil, errors.New("rate must be a positive number up to " + strconv.Itoa(defaultMaxRate))
+		}
+		f.MaxRate = v
+	}
+
+	return f, nil
+}
+
+func (f *Filter) matches(r *Record) bool {
+	if f.Trigger != "" && f.Trigger != r.Trigger {
+		return false
+	}
+	if f.TypePrefix != "" && !strings.HasPrefix(r.EventType, f.TypePrefix) {
+		return false
+	}
+	if len(f.Decisions) != 0 {
+		if _, ok := f.Decisions[r.Decision]; !ok {
+			return false
+		}
+	}
+	return f.Sample >= 1 || rand.Float64() < f.Sample
+}
+
+// Observer receives the records that match its filter.
+type Observer struct {
+	C <-chan *Record
+
+	c       chan *Record
+	filter  *Filter
+	limiter *rate.Limiter
+	dropped uint64
+}
+
+// Dropped returns the number of records that were not sent to the observer
+// because it could not keep up or exceeded its rate.
+func (o *Observer) Dropped() uint64 {
+	return atomic.LoadUint64(&o.dropped)
+}
+
+// Hub distributes records to observers.
+type Hub struct {
+	observers map[*Observer]struct{}
+	count     int32
+	m         sync.RWMutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		observers: make(map[*Observer]struct{}),
+	}
+}
+
+// Active returns true if there are observers, which allows skipping
+// building records.
+func (h *Hub) Active() bool {
+	return h != nil && atomic.LoadInt32(&h.count) != 0
+}
+
+// Subscribe registers an observer for the filtered records.
+func (h *Hub) Subscribe(f *Filter) *Observer {
+	c := make(chan *Record, observerBufferSize)
+	o := &Observer{
+		C:       c,
+		c:       c,
+		filter:  f,
+		limiter: rate.NewLimiter(rate.Limit(f.MaxRate), int(f.MaxRate)+1),
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.observers[o] = struct{}{}
+	atomic.StoreInt32(&h.count, int32(len(h.observers)))
+
+	return o
+}
+
+// Unsubscribe removes the observer and closes its channel.
+func (h *Hub) Unsubscribe(o *Observer) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, ok := h.observers[o]; !ok {
+		return
+	}
+	delete(h.observers, o)
+	atomic.StoreInt32(&h.count, int32(len(h.observers)))
+	close(o.c)
+}
+
+// Publish sends the record to the matching observers without blocking.
+func (h *Hub) Publish(r *Record) {
+	if !h.Active() {
+		return
+	}
+
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for o := range h.observers {
+		if !o.filter.matches(r) {
+			continue
+		}
+
+		if !o.limiter.Allow() {
+			atomic.AddUint64(&o.dropped, 1)
+			continue
+		}
+
+		select {
+		case o.c <- r:
+		default:
+			atomic.AddUint64(&o.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package firehose
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	assert.False(t, h.Active())
+
+	f, err := ParseFilter(url.Values{"trigger": {"t1"}, "decision": {"delivery,expired"}, "rate": {"2"}})
+	require.NoError(t, err)
+
+	o := h.Subscribe(f)
+	assert.True(t, h.Active())
+
+	h.Publish(&Record{Trigger: "t2", Decision: DecisionDelivery})
+	h.Publish(&Record{Trigger: "t1", Decision: DecisionFiltered})
+	for i := 0; i < 5; i++ {
+		h.Publish(&Record{Trigger: "t1", Decision: DecisionDelivery, EventID: "matching"})
+	}
+
+	// The limiter allows a burst of rate + 1 records.
+	assert.Len(t, o.C, 3)
+	assert.Equal(t, uint64(2), o.Dropped())
+	r := <-o.C
+	assert.Equal(t, "matching", r.EventID)
+
+	h.Unsubscribe(o)
+	assert.False(t, h.Active())
+
+	_, err = ParseFilter(url.Values{"sample": {"2"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fixtures compares the deliveries of Triggers with those recorded
+// in previous runs, supporting regression testing of routing and target
+// changes.
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// Extensions with this prefix are reserved for the broker, and differ on
+// each run.
+const reservedExtensionPrefix = "triggermesh"
+
+// Outcome of handling a delivery as a fixture.
+type Outcome string
+
+const (
+	// OutcomeRecorded is informed when the delivery was recorded.
+	OutcomeRecorded Outcome = "recorded"
+	// OutcomeMatched is informed when the delivery matched the recorded one.
+	OutcomeMatched Outcome = "matched"
+	// OutcomeMismatched is informed when the delivery differs from the
+	// recorded one.
+	OutcomeMismatched Outcome = "mismatched"
+	// OutcomeUnexpected is informed when the event was delivered but no
+	// delivery was recorded for it.
+	OutcomeUnexpected Outcome = "unexpected"
+	// OutcomeMissing is informed when the event was not delivered but a
+	// delivery was recorded for it.
+	OutcomeMissing Outcome = "missing"
+)
+
+// Delivery of an event to a Trigger target.
+type Delivery struct {
+	Target string            `json:"target"`
+	Event  cloudevents.Event `json:"event"`
+	// Response is the event replied by the target, if any.
+	Response *cloudevents.Event `json:"response,omitempty"`
+}
+
+// Compare returns the differences between the recorded and the actual
+// delivery, not comparing the ignored attributes nor the extensions
+// reserved for the broker.
+func Compare(recorded, actual *Delivery, ignore []string) []string {
+	ignored := make(map[string]struct{}, len(ignore))
+	for _, a := range ignore {
+		ignored[a] = struct{}{}
+	}
+
+	diffs := []string{}
+	if recorded.Target != actual.Target {
+		diffs = append(diffs, fmt.Sprintf("target: recorded %q, got %q", recorded.Target, actual.Target))
+	}
+
+	diffs = append(diffs, compareEvents("event", &recorded.Event, &actual.Event, ignored)...)
+
+	switch {
+	case recorded.Response == nil && actual.Response == nil:
+	case recorded.Response == nil:
+		diffs = append(diffs, "response: recorded none, got an event")
+	case actual.Response == nil:
+		diffs = append(diffs, "response: recorded an event, got none")
+	default:
+		diffs = append(diffs, compareEvents("response", recorded.Response, actual.Response, ignored)...)
+	}
+
+	return diffs
+}
+
+func compareEvents(field string, recorded, actual *cloudevents.Event, ignored map[string]struct{}) []string {
+	ra, aa := attributes(recorded), attributes(actual)
+
+	names := make([]string, 0, len(ra)+len(aa))
+	for n := range ra {
+		names = append(names, n)
+	}
+	for n := range aa {
+		if _, ok := ra[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := []string{}
+	for _, n := range names {
+		if _, ok := ignored[n]; ok || strings.HasPrefix(n, reservedExtensionPrefix) {
+			continue
+		}
+
+		r, rok := ra[n]
+		a, aok := aa[n]
+		switch {
+		case !rok:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: recorded none, got %q", field, n, a))
+		case !aok:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: recorded %q, got none", field, n, r))
+		case r != a:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: recorded %q, got %q", field, n, r, a))
+		}
+	}
+
+	if _, ok := ignored["data"]; !ok && !equalData(recorded.Data(), actual.Data()) {
+		diffs = append(diffs, fmt.Sprintf("%s.data: recorded %q, got %q", field, recorded.Data(), actual.Data()))
+	}
+
+	return diffs
+}
+
+// attributes returns the string representation of the informed event
+// attributes and extensions.
+func attributes(e *cloudevents.Event) map[string]string {
+	attrs := map[string]string{
+		"specversion":     e.SpecVersion(),
+		"id":              e.ID(),
+		"source":          e.Source(),
+		"type":            e.Type(),
+		"subject":         e.Subject(),
+		"datacontenttype": e.DataContentType(),
+		"dataschema":      e.DataSchema(),
+	}
+	if t := e.Time(); !t.IsZero() {
+		attrs["time"] = types.FormatTime(t)
+	}
+
+	for n, v := range e.Extensions() {
+		if s, err := types.Format(v); err == nil {
+			attrs[n] = s
+		}
+	}
+
+	for n, v := range attrs {
+		if v == "" {
+			delete(attrs, n)
+		}
+	}
+
+	return attrs
+}
+
+// equalData compares JSON payloads regardless of their formatting.
+func equalData(recorded, actual []byte) bool {
+	if bytes.Equal(recorded, actual) {
+		return true
+	}
+
+	var r, a interface{}
+	if json.Unmarshal(recorded, &r) != nil || json.Unmarshal(actual, &a) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(r, a)
+}
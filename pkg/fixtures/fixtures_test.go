@@ -0,0 +1,44 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fixtures
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	newDelivery := func(data string, ext map[string]string) *Delivery {
+		e := cloudevents.NewEvent()
+		e.SetID("1")
+		e.SetType("test.type")
+		e.SetSource("test")
+		require.NoError(t, e.SetData(cloudevents.ApplicationJSON, []byte(data)))
+		for k, v := range ext {
+			e.SetExtension(k, v)
+		}
+		return &Delivery{Target: "http://target", Event: e}
+	}
+
+	recorded := newDelivery(`{"a":1,"b":[1,2]}`, map[string]string{"triggermeshbackendid": "1-0", "tenant": "t1"})
+
+	// JSON payloads are compared regardless of formatting, and reserved
+	// extensions are not compared.
+	actual := newDelivery(`{ "b": [1, 2], "a": 1 }`, map[string]string{"triggermeshbackendid": "2-0", "tenant": "t1"})
+	assert.Empty(t, Compare(recorded, actual, nil))
+
+	actual = newDelivery(`{"a":2,"b":[1,2]}`, map[string]string{"tenant": "t2"})
+	actual.Response = &actual.Event
+	assert.Equal(t, []string{
+		`event.tenant: recorded "t1", got "t2"`,
+		`event.data: recorded "{\"a\":1,\"b\":[1,2]}", got "{\"a\":2,\"b\":[1,2]}"`,
+		"response: recorded none, got an event",
+	}, Compare(recorded, actual, nil))
+
+	assert.Equal(t, []string{"response: recorded none, got an event"},
+		Compare(recorded, actual, []string{"tenant", "data"}))
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Value logged instead of the value of redacted attributes and extensions.
+const redactedValue = "[REDACTED]"
+
+// accessLogger logs a sample of ingest requests.
+type accessLogger struct {
+	ratio  float64
+	redact map[string]struct{}
+	logger *zap.SugaredLogger
+}
+
+// newAccessLogger returns the access logger for the configuration, nil if
+// requests are not logged.
+func newAccessLogger(cfg *cfgbroker.AccessLog, logger *zap.SugaredLogger) *accessLogger {
+	if cfg == nil {
+		return nil
+	}
+
+	al := &accessLogger{
+		ratio:  1,
+		redact: make(map[string]struct{}, len(cfg.Redact)),
+		logger: logger,
+	}
+	if cfg.Ratio != nil {
+		al.ratio = *cfg.Ratio
+	}
+	for _, r := range cfg.Redact {
+		al.redact[strings.ToLower(r)] = struct{}{}
+	}
+
+	return al
+}
+
+func (al *accessLogger) sampled() bool {
+	return al.ratio >= 1 || rand.Float64() < al.ratio
+}
+
+// attributes returns the attributes and extensions of the event, replacing
+// the values of those redacted.
+func (al *accessLogger) attributes(event *cloudevents.Event) map[string]interface{} {
+	attrs := map[string]interface{}{
+		"specversion": event.SpecVersion(),
+		"id":          event.ID(),
+		"source":      event.Source(),
+		"type":        event.Type(),
+	}
+	if v := event.Subject(); v != "" {
+		attrs["subject"] = v
+	}
+	if v := event.DataContentType(); v != "" {
+		attrs["datacontenttype"] = v
+	}
+	if v := event.DataSchema(); v != "" {
+		attrs["dataschema"] = v
+	}
+	if v := event.Time(); !v.IsZero() {
+		attrs["time"] = v.Format(time.RFC3339Nano)
+	}
+	for k, v := range event.Extensions() {
+		attrs[k] = v
+	}
+
+	for k := range attrs {
+		if _, ok := al.redact[k]; ok {
+			attrs[k] = redactedValue
+		}
+	}
+
+	return attrs
+}
+
+type accessLogKey struct{}
+
+// accessLogEvents are the attributes of the events of a logged request,
+// informed by the CloudEvents handler for each event of batches.
+type accessLogEvents struct {
+	al     *accessLogger
+	events []map[string]interface{}
+}
+
+// logAccessEvent adds the event to the access log entry of the request, if
+// the request is logged.
+func logAccessEvent(ctx context.Context, event *cloudevents.Event) {
+	if ae, ok := ctx.Value(accessLogKey{}).(*accessLogEvents); ok {
+		ae.events = append(ae.events, ae.al.attributes(event))
+	}
+}
+
+// accessLogMiddleware logs ingest requests along with their response
+// status, using the access logger configured from the broker configuration.
+func (i *Instance) accessLogMiddleware() cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			al := i.accessLog.Load().(*accessLogger)
+			if al == nil || r.Method != http.MethodPost || !al.sampled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ae := &accessLogEvents{al: al}
+			sw := &statusResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, ae)))
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			al.logger.Infow("Ingest request",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remoteAddr", r.RemoteAddr),
+				zap.String("userAgent", r.UserAgent()),
+				zap.String("contentType", r.Header.Get("Content-Type")),
+				zap.Int64("contentLength", r.ContentLength),
+				zap.Int("status", status),
+				zap.Duration("duration", time.Since(start)),
+				zap.Any("events", ae.events))
+		})
+	}
+}
+
+// statusResponseWriter records the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestAccessLogAttributes(t *testing.T) {
+	al := newAccessLogger(&cfgbroker.AccessLog{Redact: []string{"Subject", "customerid"}}, zap.NewNop().Sugar())
+
+	e := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"), lib.CloudEventWithTypeOption("order.created"))
+	e.SetSubject("jane@example.com")
+	e.SetExtension("customerid", "1234")
+	e.SetExtension("region", "eu")
+
+	attrs := al.attributes(&e)
+	assert.Equal(t, "1", attrs["id"])
+	assert.Equal(t, "order.created", attrs["type"])
+	assert.Equal(t, "eu", attrs["region"])
+	assert.Equal(t, redactedValue, attrs["subject"])
+	assert.Equal(t, redactedValue, attrs["customerid"])
+	assert.NotContains(t, attrs, "data")
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	i := NewInstance(nil, zap.New(core).Sugar())
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error { return nil })
+
+	ingest := func(method string) {
+		h := i.accessLogMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = i.cloudEventsHandler(r.Context(), lib.NewCloudEvent(lib.CloudEventWithIDOption("1")))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+	accessLogs := func() []observer.LoggedEntry {
+		entries := logs.FilterMessage("Ingest request").All()
+		logs.TakeAll()
+		return entries
+	}
+
+	// Requests are not logged until configured.
+	ingest(http.MethodPost)
+	assert.Empty(t, accessLogs())
+
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{AccessLog: &cfgbroker.AccessLog{}}})
+
+	ingest(http.MethodPost)
+	entries := accessLogs()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(http.StatusAccepted), fields["status"])
+	events, ok := fields["events"].([]map[string]interface{})
+	require.True(t, ok, "Events must be logged")
+	require.Len(t, events, 1)
+	assert.Equal(t, "1", events[0]["id"])
+
+	// Probes are not logged.
+	ingest(http.MethodGet)
+	assert.Empty(t, accessLogs())
+
+	ratio := 0.0
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{AccessLog: &cfgbroker.AccessLog{Ratio: &ratio}}})
+	ingest(http.MethodPost)
+	assert.Empty(t, accessLogs())
+}
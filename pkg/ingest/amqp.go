@@ -0,0 +1,147 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	ceamqp "github.com/cloudevents/sdk-go/protocol/amqp/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"go.uber.org/zap"
+)
+
+const (
+	// Time to wait for the connection to the AMQP server, and before
+	// connecting again after the connection is lost.
+	amqpConnectTimeout = 10 * time.Second
+	amqpReconnectDelay = 5 * time.Second
+
+	// Messages received from the AMQP server before they are settled.
+	amqpLinkCredit = 10
+)
+
+// amqpOutcome is how messages received through AMQP are settled.
+type amqpOutcome int
+
+const (
+	// The event was ingested.
+	amqpAccept amqpOutcome = iota
+	// The event was rejected and must not be delivered again.
+	amqpReject
+	// The event could not be ingested and must be delivered again.
+	amqpModify
+)
+
+// InstanceWithAMQP receives CloudEvents from the node at the address of the
+// AMQP server, which are ingested as if they were received through HTTP.
+// Credentials informed at the server URL authenticate using SASL PLAIN.
+func InstanceWithAMQP(url, address string) InstanceOption {
+	return func(i *Instance) {
+		i.amqpURL = url
+		i.amqpAddress = address
+	}
+}
+
+// receiveAMQP ingests the messages of the AMQP node until the context is
+// done, connecting again when the connection is lost.
+func (i *Instance) receiveAMQP(ctx context.Context) {
+	logger := i.logger.With(zap.String("address", i.amqpAddress))
+
+	for {
+		p, err := ceamqp.NewReceiverProtocol(i.amqpURL, i.amqpAddress,
+			[]amqp.ConnOption{amqp.ConnConnectTimeout(amqpConnectTimeout)}, nil,
+			ceamqp.WithReceiverLinkOption(amqp.LinkCredit(amqpLinkCredit)))
+		if err != nil {
+			logger.Errorw("Could not connect to AMQP", zap.Error(err))
+		} else {
+			logger.Info("Receiving CloudEvents from AMQP")
+			err = i.receiveAMQPMessages(ctx, p)
+			_ = p.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorw("AMQP connection was lost", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(amqpReconnectDelay):
+		}
+	}
+}
+
+// receiveAMQPMessages ingests the messages of the receiver one at a time,
+// settling each of them once ingested.
+func (i *Instance) receiveAMQPMessages(ctx context.Context, r protocol.Receiver) error {
+	for {
+		m, err := r.Receive(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("receiver closed")
+			}
+			return err
+		}
+
+		outcome, res := i.ingestAMQP(ctx, m)
+
+		var serr error
+		am, ok := m.(*ceamqp.Message)
+		switch {
+		case outcome == amqpAccept:
+			serr = m.Finish(nil)
+		case outcome == amqpModify && ok:
+			// Flag the delivery as failed so that servers can dead
+			// letter messages after a number of deliveries.
+			serr = am.AMQPrcv.ModifyMessage(context.Background(), am.AMQP, true, false, nil)
+		default:
+			serr = m.Finish(res)
+		}
+		if serr != nil {
+			return fmt.Errorf("settling message: %w", serr)
+		}
+	}
+}
+
+// ingestAMQP converts the message to a CloudEvent and ingests it, returning
+// how the message must be settled along with the ingest result.
+func (i *Instance) ingestAMQP(ctx context.Context, m binding.Message) (amqpOutcome, protocol.Result) {
+	start := time.Now()
+
+	event, err := binding.ToEvent(ctx, m)
+	if err != nil {
+		i.logger.Debugw("Rejecting AMQP message that is not a valid CloudEvent", zap.Error(err))
+		if i.reporter != nil {
+			i.reporter.ReportNonValidEvent()
+		}
+		return amqpReject, err
+	}
+
+	if i.limiter != nil {
+		if err := i.limiter.Wait(ctx); err != nil {
+			return amqpModify, err
+		}
+	}
+
+	_, res := i.cloudEventsHandler(ctx, *event)
+	if i.reporter != nil {
+		i.reporter.ReportProcessedEvent(protocol.IsACK(res), event.Type(), float64(time.Since(start)/time.Millisecond))
+	}
+
+	switch {
+	case protocol.IsACK(res):
+		return amqpAccept, res
+	case isRejection(res):
+		i.logger.Warnw("Rejecting AMQP message", zap.String("type", event.Type()), zap.String("id", event.ID()), zap.Error(res))
+		return amqpReject, res
+	default:
+		return amqpModify, res
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestIngestAMQP(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar())
+
+	var produceErr error
+	produced := 0
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error {
+		if produceErr != nil {
+			return produceErr
+		}
+		produced++
+		return nil
+	})
+
+	ev := lib.NewCloudEvent()
+	ingest := func() amqpOutcome {
+		outcome, _ := i.ingestAMQP(context.Background(), binding.ToMessage(&ev))
+		return outcome
+	}
+
+	assert.Equal(t, amqpAccept, ingest())
+	assert.Equal(t, 1, produced)
+
+	produceErr = errors.New("backend is down")
+	assert.Equal(t, amqpModify, ingest(), "Events that could not be produced must be delivered again")
+
+	produceErr = fmt.Errorf("%w: event is 2MB", backend.ErrEventTooLarge)
+	assert.Equal(t, amqpReject, ingest(), "Events rejected due to their contents must not be delivered again")
+}
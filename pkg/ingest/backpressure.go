@@ -0,0 +1,85 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// backpressureMiddleware bounds the number of events being produced
+// concurrently to the backend, rejecting requests beyond that limit with
+// a 429 status code and a Retry-After header.
+//
+// Responses with 429 status code written by the CloudEvents handler, which
+// happen when the backend reports it is busy, are also informed the
+// Retry-After header.
+//
+// Batches are not bounded as a whole, each of their events taking a slot
+// of the semaphore when ingested.
+func backpressureMiddleware(sem chan struct{}, retryAfter time.Duration) cehttp.Middleware {
+	retryAfterSeconds := retryAfterHeader(retryAfter)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Only event ingestion is limited, probes and other
+			// requests are always served.
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			brw := &backpressureResponseWriter{
+				ResponseWriter: w,
+				retryAfter:     retryAfterSeconds,
+			}
+
+			if sem == nil || isBatchRequest(r) {
+				next.ServeHTTP(brw, r)
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(brw, r)
+
+			default:
+				brw.Header().Set("Retry-After", retryAfterSeconds)
+				http.Error(w, "too many events being ingested", http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+// newInFlightSemaphore returns the semaphore that bounds the number of
+// events being ingested, nil when not bounded.
+func newInFlightSemaphore(maxInFlight int) chan struct{} {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return make(chan struct{}, maxInFlight)
+}
+
+func retryAfterHeader(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// backpressureResponseWriter adds the Retry-After header to responses
+// that inform a 429 status code.
+type backpressureResponseWriter struct {
+	http.ResponseWriter
+	retryAfter string
+}
+
+func (w *backpressureResponseWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", w.retryAfter)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackpressureMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := backpressureMiddleware(newInFlightSemaphore(1), 1500*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	slow := make(chan *httptest.ResponseRecorder)
+	go func() { slow <- serve(http.MethodPost, "/slow") }()
+	<-entered
+
+	tcs := map[string]struct {
+		method     string
+		path       string
+		code       int
+		retryAfter string
+	}{
+		"beyond the limit": {method: http.MethodPost, path: "/", code: http.StatusTooManyRequests, retryAfter: "2"},
+		"probes":           {method: http.MethodGet, path: "/healthz", code: http.StatusAccepted},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rr := serve(tc.method, tc.path)
+			assert.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.retryAfter, rr.Header().Get("Retry-After"))
+		})
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusAccepted, (<-slow).Code)
+
+	// Busy backends are informed the Retry-After header.
+	rr := serve(http.MethodPost, "/busy")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+
+	// Without limit only the busy responses are informed.
+	h = backpressureMiddleware(newInFlightSemaphore(0), time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	rr = serve(http.MethodPost, "/")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Empty(t, rr.Header().Get("Retry-After"))
+}
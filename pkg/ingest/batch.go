@@ -0,0 +1,247 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+)
+
+// batchResponse informs the outcome of each event of an ingested batch.
+type batchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []batchResult `json:"results"`
+}
+
+// batchResult is the outcome of the event at the index of the batch, using
+// the status code that would have been returned if it was ingested alone.
+// Events rejected due to rate limiting or backpressure inform the seconds
+// to wait before retrying them.
+type batchResult struct {
+	Index      int    `json:"index"`
+	ID         string `json:"id,omitempty"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+}
+
+// isBatchRequest returns whether the request ingests a CloudEvents batch.
+func isBatchRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsBatchJSON)
+}
+
+// batchMiddleware ingests requests using the CloudEvents batched content
+// mode, which the CloudEvents SDK does not support, producing each event
+// in order. Responses use the 200 status code when all events are
+// accepted, 207 when only some are, and the highest status code of the
+// results when none is. Batches larger than the maximum batch size are
+// rejected as a whole with 413.
+//
+// Each event is charged to the rate limiter and takes a slot of the
+// in-flight semaphore, which is held until the response is written. Events
+// beyond either limit are rejected with 429, and the response informs the
+// highest Retry-After of them.
+func (i *Instance) batchMiddleware() cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isBatchRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			maxSize := i.maxBatchSize()
+			if r.ContentLength > maxSize {
+				http.Error(w, batchTooLargeMessage(maxSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+			if err != nil {
+				var mbErr *http.MaxBytesError
+				if errors.As(err, &mbErr) {
+					http.Error(w, batchTooLargeMessage(maxSize), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "could not read CloudEvents batch: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var batch []json.RawMessage
+			if err := json.Unmarshal(body, &batch); err != nil {
+				http.Error(w, "could not parse CloudEvents batch: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			held := 0
+			defer func() {
+				for ; held > 0; held-- {
+					<-i.inFlight
+				}
+			}()
+
+			res := batchResponse{Results: make([]batchResult, 0, len(batch))}
+			status, retryAfter := 0, 0
+			for idx, raw := range batch {
+				br, acquired := i.limitBatchItem()
+				if acquired {
+					held++
+				}
+				if br.Status == 0 {
+					br = i.ingestBatchItem(r, raw)
+				}
+				br.Index = idx
+				res.Results = append(res.Results, br)
+
+				if br.RetryAfter > retryAfter {
+					retryAfter = br.RetryAfter
+				}
+
+				if br.Status < http.StatusMultipleChoices {
+					res.Accepted++
+					continue
+				}
+				res.Rejected++
+				if br.Status > status {
+					status = br.Status
+				}
+			}
+
+			switch {
+			case res.Rejected == 0:
+				status = http.StatusOK
+			case res.Accepted != 0:
+				status = http.StatusMultiStatus
+			}
+
+			if i.limiter != nil {
+				w.Header().Set(headerRateLimitLimit, strconv.FormatFloat(float64(i.limiter.Limit()), 'f', -1, 64))
+				w.Header().Set(headerRateLimitRemaining, strconv.Itoa(rateLimitRemaining(i.limiter, time.Now())))
+			}
+			if retryAfter > 0 {
+				w.Header().Set(headerRetryAfter, strconv.Itoa(retryAfter))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if err := json.NewEncoder(w).Encode(res); err != nil {
+				i.logger.Errorw("Could not write CloudEvents batch response", zap.Error(err))
+			}
+		})
+	}
+}
+
+func batchTooLargeMessage(maxSize int64) string {
+	return fmt.Sprintf("batch exceeds the maximum size of %d bytes", maxSize)
+}
+
+// limitBatchItem charges an event of a batch to the rate limiter and takes
+// a slot of the in-flight semaphore, returning whether the slot was taken.
+// Events that cannot be ingested due to those limits are informed a 429
+// result.
+func (i *Instance) limitBatchItem() (batchResult, bool) {
+	acquired := false
+	if i.inFlight != nil {
+		select {
+		case i.inFlight <- struct{}{}:
+			acquired = true
+		default:
+			return batchResult{
+				Status:     http.StatusTooManyRequests,
+				Error:      "too many events being ingested",
+				RetryAfter: int(math.Ceil(i.retryAfter.Seconds())),
+			}, false
+		}
+	}
+
+	if i.limiter != nil {
+		now := time.Now()
+		res := i.limiter.ReserveN(now, 1)
+		if delay := res.DelayFrom(now); !res.OK() || delay > 0 {
+			res.CancelAt(now)
+			return batchResult{
+				Status:     http.StatusTooManyRequests,
+				Error:      "ingest rate limit exceeded",
+				RetryAfter: int(math.Ceil(delay.Seconds())),
+			}, acquired
+		}
+	}
+
+	return batchResult{}, acquired
+}
+
+// ingestBatchItem goes through the same steps events ingested alone do:
+// generating missing IDs, conforming to the specification, validating and
+// handling the event.
+func (i *Instance) ingestBatchItem(r *http.Request, raw json.RawMessage) batchResult {
+	if i.maxEventSize > 0 && len(raw) > i.maxEventSize {
+		return batchResult{Status: http.StatusRequestEntityTooLarge, Error: eventTooLargeMessage(i.maxEventSize)}
+	}
+
+	attrs, err := newStructuredAttributes(raw)
+	if err != nil {
+		i.reportNonValidEvent()
+		return batchResult{Status: http.StatusBadRequest, Error: "could not parse structured CloudEvent: " + err.Error()}
+	}
+
+	if i.idGenerator != nil {
+		if id, _ := attrs.get("id"); id == "" {
+			attrs.set("id", i.idGenerator.NewID())
+		}
+	}
+
+	if i.conformance == ConformanceModeLenient || i.conformance == ConformanceModeRepair {
+		if vs := conform(attrs, i.conformance, i.conformanceIDGenerator(), time.Now()); len(vs) != 0 {
+			logViolations(i.logger, i.conformance, attrs, vs)
+		}
+	}
+
+	id, _ := attrs.get("id")
+
+	b, err := json.Marshal(attrs.attrs)
+	if err != nil {
+		return batchResult{ID: id, Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	event := cloudevents.NewEvent()
+	if err := event.UnmarshalJSON(b); err != nil {
+		i.reportNonValidEvent()
+		return batchResult{ID: id, Status: http.StatusBadRequest, Error: "could not parse structured CloudEvent: " + err.Error()}
+	}
+	if err := event.Validate(); err != nil {
+		i.reportNonValidEvent()
+		return batchResult{ID: id, Status: http.StatusBadRequest, Error: strings.TrimSpace(err.Error())}
+	}
+
+	start := time.Now()
+	_, pres := i.cloudEventsHandler(r.Context(), event)
+	if i.reporter != nil {
+		i.reporter.ReportProcessedEvent(protocol.IsACK(pres), event.Type(), float64(time.Since(start)/time.Millisecond))
+	}
+
+	br := batchResult{ID: id, Status: resultStatus(pres)}
+	if br.Status >= http.StatusMultipleChoices {
+		br.Error = pres.Error()
+	}
+
+	return br
+}
+
+func (i *Instance) reportNonValidEvent() {
+	if i.reporter != nil {
+		i.reporter.ReportNonValidEvent()
+	}
+}
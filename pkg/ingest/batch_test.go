@@ -0,0 +1,174 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+func TestBatchMiddleware(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithIDGenerator(fixedID("generated")))
+
+	var produced []string
+	i.RegisterCloudEventHandler(func(_ context.Context, e *cloudevents.Event) error {
+		if e.Type() == "busy" {
+			return backend.ErrBackendBusy
+		}
+		produced = append(produced, e.ID())
+		return nil
+	})
+
+	var nextCalled bool
+	h := i.batchMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+	}))
+
+	ingest := func(body string) (*httptest.ResponseRecorder, batchResponse) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/cloudevents-batch+json; charset=utf-8")
+		r = r.WithContext(cehttp.WithRequestDataAtContext(r.Context(), r))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		var res batchResponse
+		if rec.Code != http.StatusBadRequest {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec, res
+	}
+
+	t.Run("partial failure", func(t *testing.T) {
+		produced = nil
+		rec, res := ingest(`[
+			{"specversion":"1.0","id":"1","type":"t","source":"s","data":{"a":1}},
+			{"specversion":"1.0","id":"2","source":"s"},
+			{"specversion":"1.0","type":"t","source":"s"},
+			{"specversion":"1.0","id":"4","type":"busy","source":"s"}
+		]`)
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, 2, res.Accepted)
+		assert.Equal(t, 2, res.Rejected)
+		require.Len(t, res.Results, 4)
+		assert.Equal(t, batchResult{Index: 0, ID: "1", Status: http.StatusOK}, res.Results[0])
+		assert.Equal(t, http.StatusBadRequest, res.Results[1].Status)
+		assert.NotEmpty(t, res.Results[1].Error)
+		assert.Equal(t, batchResult{Index: 2, ID: "generated", Status: http.StatusOK}, res.Results[2])
+		assert.Equal(t, http.StatusTooManyRequests, res.Results[3].Status)
+
+		assert.Equal(t, []string{"1", "generated"}, produced, "Events must be produced in order")
+	})
+
+	t.Run("all accepted", func(t *testing.T) {
+		rec, res := ingest(`[{"specversion":"1.0","id":"1","type":"t","source":"s"}]`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, res.Accepted)
+	})
+
+	t.Run("all rejected", func(t *testing.T) {
+		rec, res := ingest(`[
+			{"specversion":"1.0","id":"1","source":"s"},
+			{"specversion":"1.0","id":"2","type":"busy","source":"s"}
+		]`)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 2, res.Rejected)
+	})
+
+	t.Run("not a batch", func(t *testing.T) {
+		rec, _ := ingest(`{"specversion":"1.0"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		nextCalled = false
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		r.Header.Set("Content-Type", "application/cloudevents+json")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		assert.True(t, nextCalled)
+	})
+}
+
+func TestBatchMiddlewareLimits(t *testing.T) {
+	batch := `[
+		{"specversion":"1.0","id":"1","type":"t","source":"s"},
+		{"specversion":"1.0","id":"2","type":"t","source":"s"},
+		{"specversion":"1.0","id":"3","type":"t","source":"s"}
+	]`
+
+	tcs := map[string]struct {
+		opts       []InstanceOption
+		error      string
+		retryAfter int
+	}{
+		"rate limit": {
+			opts:       []InstanceOption{InstanceWithRateLimit(0.5, 2)},
+			error:      "ingest rate limit exceeded",
+			retryAfter: 2,
+		},
+		"max in flight": {
+			opts:       []InstanceOption{InstanceWithMaxInFlight(2), InstanceWithRetryAfter(1500 * time.Millisecond)},
+			error:      "too many events being ingested",
+			retryAfter: 2,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			i := NewInstance(nil, zap.NewNop().Sugar(), tc.opts...)
+
+			var produced []string
+			i.RegisterCloudEventHandler(func(_ context.Context, e *cloudevents.Event) error {
+				produced = append(produced, e.ID())
+				return nil
+			})
+
+			// Batches go through the same middlewares the instance uses.
+			var h http.Handler = i.batchMiddleware()(http.NotFoundHandler())
+			if i.limiter != nil {
+				h = rateLimitMiddleware(i.limiter)(h)
+			}
+			h = backpressureMiddleware(i.inFlight, i.retryAfter)(h)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(batch))
+			r.Header.Set("Content-Type", "application/cloudevents-batch+json")
+			r = r.WithContext(cehttp.WithRequestDataAtContext(r.Context(), r))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			var res batchResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+			assert.Equal(t, http.StatusMultiStatus, rec.Code)
+			assert.Equal(t, 2, res.Accepted, "Each event must be counted towards the limits")
+			require.Len(t, res.Results, 3)
+			assert.Equal(t, batchResult{
+				Index:      2,
+				Status:     http.StatusTooManyRequests,
+				Error:      tc.error,
+				RetryAfter: tc.retryAfter,
+			}, res.Results[2])
+			assert.Equal(t, strconv.Itoa(tc.retryAfter), rec.Header().Get("Retry-After"))
+			assert.Equal(t, []string{"1", "2"}, produced)
+
+			if i.inFlight != nil {
+				assert.Empty(t, i.inFlight, "Slots must be released once the batch is ingested")
+			}
+		})
+	}
+}
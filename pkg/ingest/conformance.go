@@ -0,0 +1,373 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+)
+
+// ConformanceMode sets how ingest handles events that violate the
+// CloudEvents specification.
+type ConformanceMode string
+
+const (
+	// ConformanceModeStrict rejects events that violate the specification,
+	// as the CloudEvents SDK does.
+	ConformanceModeStrict ConformanceMode = "strict"
+	// ConformanceModeLenient accepts events whose optional attributes
+	// violate the specification, dropping those attributes with a warning.
+	ConformanceModeLenient ConformanceMode = "lenient"
+	// ConformanceModeRepair fixes the attributes that violate the
+	// specification when possible, generating missing IDs and times,
+	// escaping sources and normalizing extension names.
+	ConformanceModeRepair ConformanceMode = "repair"
+)
+
+// Members of structured events that are not attributes.
+var structuredDataMembers = map[string]struct{}{
+	"data":        {},
+	"data_base64": {},
+}
+
+// violation of the specification found at an ingested event.
+type violation struct {
+	Attribute string `json:"attribute"`
+	Problem   string `json:"problem"`
+	Action    string `json:"action"`
+}
+
+// eventAttributes are the context attributes of ingested events, read from
+// headers for binary content mode or the body for structured content mode.
+type eventAttributes interface {
+	names() []string
+	get(name string) (string, bool)
+	set(name, value string)
+	del(name string)
+	rename(from, to string)
+}
+
+// conformanceMiddleware makes ingested events conform to the
+// specification, which the CloudEvents SDK validates afterwards, rejecting
+// those that still violate it.
+func conformanceMiddleware(mode ConformanceMode, gen eventid.Generator, logger *zap.SugaredLogger) cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var attrs eventAttributes
+			var sa *structuredAttributes
+			switch {
+			case r.Header.Get("Ce-Specversion") != "":
+				attrs = binaryAttributes(r.Header)
+
+			case strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsJSON):
+				var err error
+				if sa, err = readStructuredAttributes(r); err != nil {
+					http.Error(w, "could not parse structured CloudEvent: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				attrs = sa
+
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			vs := conform(attrs, mode, gen, time.Now())
+			if len(vs) != 0 {
+				logViolations(logger, mode, attrs, vs)
+
+				if sa != nil {
+					if err := sa.write(r); err != nil {
+						http.Error(w, "could not serialize structured CloudEvent: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logViolations logs the violations found at the event, as warnings for
+// the lenient mode.
+func logViolations(logger *zap.SugaredLogger, mode ConformanceMode, attrs eventAttributes, vs []violation) {
+	id, _ := attrs.get("id")
+	source, _ := attrs.get("source")
+	if mode == ConformanceModeLenient {
+		logger.Warnw("Accepting CloudEvent that violates the specification",
+			zap.String("id", id), zap.String("source", source), zap.Any("violations", vs))
+	} else {
+		logger.Debugw("Repaired CloudEvent that violates the specification",
+			zap.String("id", id), zap.String("source", source), zap.Any("violations", vs))
+	}
+}
+
+// conform drops or repairs the attributes that violate the specification
+// according to the mode, returning the violations found. Violations that
+// cannot be handled in the mode are left for the SDK to reject.
+func conform(attrs eventAttributes, mode ConformanceMode, gen eventid.Generator, now time.Time) []violation {
+	if mode != ConformanceModeLenient && mode != ConformanceModeRepair {
+		return nil
+	}
+	repair := mode == ConformanceModeRepair
+
+	var vs []violation
+	drop := func(name, problem string) {
+		attrs.del(name)
+		vs = append(vs, violation{Attribute: name, Problem: problem, Action: "dropped"})
+	}
+	repaired := func(name, problem string) {
+		vs = append(vs, violation{Attribute: name, Problem: problem, Action: "repaired"})
+	}
+
+	if id, _ := attrs.get("id"); strings.TrimSpace(id) == "" && repair {
+		attrs.set("id", gen.NewID())
+		repaired("id", "missing")
+	}
+
+	if source, ok := attrs.get("source"); ok && strings.TrimSpace(source) != "" {
+		if _, err := url.Parse(source); err != nil && repair {
+			attrs.set("source", escapeURIReference(source))
+			repaired("source", "not a URI-reference")
+		}
+	}
+
+	if t, ok := attrs.get("time"); ok {
+		if _, err := time.Parse(time.RFC3339Nano, t); err != nil {
+			if repair {
+				attrs.set("time", now.UTC().Format(time.RFC3339Nano))
+				repaired("time", "not an RFC3339 timestamp")
+			} else {
+				drop("time", "not an RFC3339 timestamp")
+			}
+		}
+	} else if repair {
+		attrs.set("time", now.UTC().Format(time.RFC3339Nano))
+		repaired("time", "missing")
+	}
+
+	if ct, ok := attrs.get("datacontenttype"); ok {
+		if _, _, err := mime.ParseMediaType(ct); err != nil {
+			drop("datacontenttype", "not an RFC2046 media type")
+		}
+	}
+
+	if ds, ok := attrs.get("dataschema"); ok {
+		if u, err := url.Parse(ds); err != nil || !u.IsAbs() {
+			drop("dataschema", "not an absolute URI")
+		}
+	}
+
+	if s, ok := attrs.get("subject"); ok && strings.TrimSpace(s) == "" {
+		drop("subject", "empty")
+	}
+
+	for _, name := range attrs.names() {
+		if isAttributeName(name) {
+			continue
+		}
+
+		normalized := normalizeAttributeName(name)
+		_, exists := attrs.get(normalized)
+		if !repair || normalized == "" || exists {
+			drop(name, "not a valid attribute name")
+			continue
+		}
+
+		attrs.rename(name, normalized)
+		repaired(name, "not a valid attribute name, renamed to "+normalized)
+	}
+
+	return vs
+}
+
+// isAttributeName returns whether the name only contains lowercase letters
+// and digits.
+func isAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeAttributeName(name string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// escapeURIReference escapes the characters that cannot be informed at a
+// URI-reference.
+func escapeURIReference(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte(c)
+		case c > ' ' && c < 0x7f && c != '%' && !strings.ContainsRune(`"<>\^`+"`{|}", rune(c)):
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// binaryAttributes reads attributes from the ce- prefixed headers, and the
+// data content type from the Content-Type header.
+type binaryAttributes http.Header
+
+func (b binaryAttributes) header(name string) string {
+	if name == "datacontenttype" {
+		return "Content-Type"
+	}
+	return "Ce-" + name
+}
+
+func (b binaryAttributes) names() []string {
+	var names []string
+	for k := range b {
+		if len(k) > 3 && strings.EqualFold(k[:3], "ce-") {
+			names = append(names, strings.ToLower(k[3:]))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (b binaryAttributes) get(name string) (string, bool) {
+	vs, ok := http.Header(b)[http.CanonicalHeaderKey(b.header(name))]
+	if !ok || len(vs) == 0 {
+		return "", false
+	}
+	return vs[0], true
+}
+
+func (b binaryAttributes) set(name, value string) {
+	http.Header(b).Set(b.header(name), value)
+}
+
+func (b binaryAttributes) del(name string) {
+	http.Header(b).Del(b.header(name))
+}
+
+func (b binaryAttributes) rename(from, to string) {
+	vs := http.Header(b).Values(b.header(from))
+	b.del(from)
+	for _, v := range vs {
+		http.Header(b).Add(b.header(to), v)
+	}
+}
+
+// structuredAttributes reads attributes from the JSON body of the request.
+type structuredAttributes struct {
+	attrs map[string]json.RawMessage
+}
+
+func readStructuredAttributes(r *http.Request) (*structuredAttributes, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return newStructuredAttributes(body)
+}
+
+func newStructuredAttributes(body []byte) (*structuredAttributes, error) {
+	s := &structuredAttributes{}
+	if err := json.Unmarshal(body, &s.attrs); err != nil {
+		return nil, err
+	}
+	if s.attrs == nil {
+		return nil, errors.New("structured CloudEvent must be a JSON object")
+	}
+	return s, nil
+}
+
+func (s *structuredAttributes) write(r *http.Request) error {
+	body, err := json.Marshal(s.attrs)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+func (s *structuredAttributes) names() []string {
+	var names []string
+	for k := range s.attrs {
+		if _, ok := structuredDataMembers[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// get returns the value of string attributes, and the JSON value of
+// extensions of other types.
+func (s *structuredAttributes) get(name string) (string, bool) {
+	raw, ok := s.attrs[name]
+	if !ok {
+		return "", false
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw), true
+	}
+	return v, true
+}
+
+func (s *structuredAttributes) set(name, value string) {
+	raw, _ := json.Marshal(value)
+	s.attrs[name] = raw
+}
+
+func (s *structuredAttributes) del(name string) {
+	delete(s.attrs, name)
+}
+
+// rename keeps the value of extensions of any type.
+func (s *structuredAttributes) rename(from, to string) {
+	s.attrs[to] = s.attrs[from]
+	delete(s.attrs, from)
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestConformanceMiddleware(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	handler := func(mode ConformanceMode) http.Handler {
+		return conformanceMiddleware(mode, fixedID("generated"), zaptest.NewLogger(t).Sugar())(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				body, _ = io.ReadAll(r.Body)
+			}))
+	}
+
+	binary := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		r.Header.Set("Ce-Specversion", "1.0")
+		r.Header.Set("Ce-Type", "t")
+		r.Header.Set("Ce-Source", "a%zzb")
+		r.Header.Set("Ce-Time", "yesterday")
+		r.Header.Set("Ce-My-Ext", "v")
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		handler(ConformanceModeStrict).ServeHTTP(httptest.NewRecorder(), binary())
+		assert.Equal(t, "yesterday", req.Header.Get("Ce-Time"))
+		assert.Equal(t, "a%zzb", req.Header.Get("Ce-Source"))
+		assert.Equal(t, "v", req.Header.Get("Ce-My-Ext"))
+	})
+
+	t.Run("binary lenient", func(t *testing.T) {
+		handler(ConformanceModeLenient).ServeHTTP(httptest.NewRecorder(), binary())
+		assert.Empty(t, req.Header.Get("Ce-Id"), "Lenient mode must not generate IDs")
+		assert.Empty(t, req.Header.Get("Ce-Time"))
+		assert.Empty(t, req.Header.Get("Ce-My-Ext"))
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("binary repair", func(t *testing.T) {
+		handler(ConformanceModeRepair).ServeHTTP(httptest.NewRecorder(), binary())
+		assert.Equal(t, "generated", req.Header.Get("Ce-Id"))
+		assert.Equal(t, "a%25zzb", req.Header.Get("Ce-Source"))
+		_, err := time.Parse(time.RFC3339Nano, req.Header.Get("Ce-Time"))
+		assert.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Ce-My-Ext"))
+		assert.Equal(t, "v", req.Header.Get("Ce-Myext"))
+	})
+
+	t.Run("structured repair", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+			`{"specversion":"1.0","id":"1","type":"t","source":"s","dataschema":"relative",`+
+				`"Retries":3,"data":{"a":1}}`))
+		r.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+		handler(ConformanceModeRepair).ServeHTTP(httptest.NewRecorder(), r)
+
+		var attrs map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &attrs))
+		assert.Equal(t, "1", attrs["id"])
+		assert.NotContains(t, attrs, "dataschema")
+		assert.NotContains(t, attrs, "Retries")
+		assert.Equal(t, float64(3), attrs["retries"], "Renamed extensions must keep their type")
+		assert.Contains(t, attrs, "time")
+		assert.Equal(t, map[string]interface{}{"a": float64(1)}, attrs["data"])
+		assert.Equal(t, int64(len(body)), req.ContentLength)
+	})
+}
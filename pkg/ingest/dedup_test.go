@@ -0,0 +1,127 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/archive"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+// keysDeduplicator keeps the produced keys without expiring them.
+type keysDeduplicator struct {
+	keys map[string]bool
+	err  error
+}
+
+func (d *keysDeduplicator) MarkProduced(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if d.err != nil {
+		return false, d.err
+	}
+	if d.keys[key] {
+		return false, nil
+	}
+	d.keys[key] = true
+	return true, nil
+}
+
+func (d *keysDeduplicator) UnmarkProduced(_ context.Context, key string) error {
+	delete(d.keys, key)
+	return nil
+}
+
+func TestDeduplication(t *testing.T) {
+	dup := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"))
+
+	tcs := map[string]struct {
+		dedupErr   error
+		produceErr error
+		reinjected bool
+		events     []cloudevents.Event
+		produced   int
+	}{
+		"duplicated events": {
+			events:   []cloudevents.Event{dup, dup},
+			produced: 1,
+		},
+		"different ids": {
+			events: []cloudevents.Event{
+				lib.NewCloudEvent(lib.CloudEventWithIDOption("1")),
+				lib.NewCloudEvent(lib.CloudEventWithIDOption("2")),
+			},
+			produced: 2,
+		},
+		"failed produce can be retried": {
+			produceErr: errors.New("backend failed"),
+			events:     []cloudevents.Event{dup, dup},
+			produced:   2,
+		},
+		"deduplicator failing": {
+			dedupErr: errors.New("deduplicator failed"),
+			events:   []cloudevents.Event{dup, dup},
+			produced: 2,
+		},
+		"re-injected from the archive": {
+			reinjected: true,
+			events:     []cloudevents.Event{dup, dup},
+			produced:   2,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			d := &keysDeduplicator{keys: map[string]bool{}, err: tc.dedupErr}
+			i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithDeduplication(d, time.Hour))
+
+			produced := 0
+			i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error {
+				produced++
+				return tc.produceErr
+			})
+
+			ctx := context.Background()
+			if tc.reinjected {
+				ctx = archive.ContextWithReinjected(ctx)
+			}
+
+			for _, e := range tc.events {
+				_, res := i.cloudEventsHandler(ctx, e)
+				assert.Equal(t, tc.produceErr == nil, protocol.IsACK(res))
+			}
+			assert.Equal(t, tc.produced, produced)
+		})
+	}
+}
+
+func TestDeduplicationPerBroker(t *testing.T) {
+	d := &keysDeduplicator{keys: map[string]bool{}}
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithDeduplication(d, time.Hour))
+
+	produced := 0
+	handler := func(context.Context, *cloudevents.Event) error {
+		produced++
+		return nil
+	}
+	i.RegisterCloudEventHandler(handler)
+	i.RegisterBrokerHandler("orders", handler)
+
+	for _, path := range []string{"/", "/brokers/orders", "/brokers/orders"} {
+		ctx := cehttp.WithRequestDataAtContext(context.Background(), httptest.NewRequest(http.MethodPost, path, nil))
+		_, res := i.cloudEventsHandler(ctx, lib.NewCloudEvent(lib.CloudEventWithIDOption("1")))
+		assert.True(t, protocol.IsACK(res))
+	}
+	assert.Equal(t, 2, produced, "Events are deduplicated per hosted broker")
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// DiscoveryPath is the path where ingest informs the broker metadata
+	// when discovery is enabled.
+	DiscoveryPath = "/"
+	// DiscoveryTriggersPath is the path where ingest informs the triggers
+	// of the broker when discovery is enabled.
+	DiscoveryTriggersPath = "/triggers"
+)
+
+// Content modes of ingested events.
+var contentModes = []string{"binary", "structured", "batched"}
+
+// InstanceWithDiscovery informs the broker metadata and its triggers at the
+// discovery paths for tooling to introspect the broker. Disabled if the
+// broker name is empty.
+func InstanceWithDiscovery(brokerName string) InstanceOption {
+	return func(i *Instance) {
+		i.discoveryName = brokerName
+	}
+}
+
+// discovery informs about the broker.
+type discovery struct {
+	Name         string   `json:"name"`
+	ContentModes []string `json:"contentModes"`
+	SyncMode     SyncMode `json:"syncMode,omitempty"`
+	Limits       info     `json:"limits"`
+	Triggers     int      `json:"triggers"`
+}
+
+// discoveredTrigger informs about a trigger, without its target, which
+// might contain credentials.
+type discoveredTrigger struct {
+	Filters []cfgbroker.Filter `json:"filters,omitempty"`
+}
+
+// updateDiscovery keeps the triggers informed at the discovery paths.
+func (i *Instance) updateDiscovery(c *cfgbroker.Config) {
+	triggers := make(map[string]discoveredTrigger, len(c.Triggers))
+	for name, t := range c.Triggers {
+		triggers[name] = discoveredTrigger{Filters: t.Filters}
+	}
+	i.discoveryTriggers.Store(triggers)
+}
+
+// serveDiscovery writes the response for the discovery paths, returning
+// false if the request path is not one of them.
+func (i *Instance) serveDiscovery(w http.ResponseWriter, r *http.Request) bool {
+	if i.discoveryName == "" {
+		return false
+	}
+
+	triggers, _ := i.discoveryTriggers.Load().(map[string]discoveredTrigger)
+
+	var res interface{}
+	switch r.URL.Path {
+	case DiscoveryPath:
+		res = discovery{
+			Name:         i.discoveryName,
+			ContentModes: contentModes,
+			SyncMode:     i.syncMode,
+			Limits:       i.info(),
+			Triggers:     len(triggers),
+		}
+	case DiscoveryTriggersPath:
+		if triggers == nil {
+			triggers = map[string]discoveredTrigger{}
+		}
+		res = triggers
+	default:
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		i.logger.Errorw("Could not write ingest discovery response", zap.Error(err))
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+)
+
+// missingIDMiddleware informs a generated ID to ingested events that do
+// not inform it, for both binary and structured content modes.
+func missingIDMiddleware(gen eventid.Generator) cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch {
+			case r.Header.Get("Ce-Specversion") != "":
+				if r.Header.Get("Ce-Id") == "" {
+					r.Header.Set("Ce-Id", gen.NewID())
+				}
+
+			case strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsJSON):
+				if err := setStructuredID(r, gen); err != nil {
+					http.Error(w, "could not parse structured CloudEvent: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setStructuredID adds the ID to the structured event at the request body
+// if it is not informed.
+func setStructuredID(r *http.Request, gen eventid.Generator) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	_ = r.Body.Close()
+
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(body, &attrs); err != nil {
+		return err
+	}
+
+	var id string
+	if raw, ok := attrs["id"]; ok {
+		_ = json.Unmarshal(raw, &id)
+	}
+
+	if id == "" {
+		raw, err := json.Marshal(gen.NewID())
+		if err != nil {
+			return err
+		}
+		attrs["id"] = raw
+
+		if body, err = json.Marshal(attrs); err != nil {
+			return err
+		}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedID string
+
+func (f fixedID) NewID() string {
+	return string(f)
+}
+
+func TestMissingIDMiddleware(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	h := missingIDMiddleware(fixedID("generated"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+
+	// Binary content mode.
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	r.Header.Set("Ce-Specversion", "1.0")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "generated", req.Header.Get("Ce-Id"))
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	r.Header.Set("Ce-Specversion", "1.0")
+	r.Header.Set("Ce-Id", "informed")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "informed", req.Header.Get("Ce-Id"))
+
+	// Structured content mode.
+	r = httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(`{"specversion":"1.0","type":"t","source":"s","data":{"a":1}}`))
+	r.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var attrs map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &attrs))
+	assert.Equal(t, "generated", attrs["id"])
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, attrs["data"])
+	assert.Equal(t, int64(len(body)), req.ContentLength)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`not json`))
+	r.Header.Set("Content-Type", "application/cloudevents+json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -5,30 +5,157 @@ package ingest
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	obshttp "github.com/cloudevents/sdk-go/observability/opencensus/v2/http"
 	ceclient "github.com/cloudevents/sdk-go/v2/client"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
+	"github.com/triggermesh/brokers/pkg/archive"
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	"github.com/triggermesh/brokers/pkg/common/eventid"
+	"github.com/triggermesh/brokers/pkg/common/expiry"
+	"github.com/triggermesh/brokers/pkg/common/integrity"
+	"github.com/triggermesh/brokers/pkg/common/provenance"
+	"github.com/triggermesh/brokers/pkg/common/secret"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
 	"github.com/triggermesh/brokers/pkg/ingest/metrics"
+	"github.com/triggermesh/brokers/pkg/schema"
+	"github.com/triggermesh/brokers/pkg/tap"
+	"github.com/triggermesh/brokers/pkg/throughput"
 )
 
 type CloudEventHandler func(context.Context, *cloudevents.Event) error
 type ProbeHandler func() error
 
+// BrokersPath prefixes the ingest path of the brokers hosted along with the
+// default broker, followed by their name.
+const BrokersPath = "/brokers/"
+
+// reservedExtensionPrefix prefixes the CloudEvents extensions reserved for
+// the broker, which are removed from ingested events so that producers
+// cannot drive how the broker handles them, like requeued events being only
+// dispatched to a single trigger.
+const reservedExtensionPrefix = "triggermesh"
+
+// Reserved extensions kept at ingested events, which are informed by other
+// brokers and to the dead letter sinks of triggers.
+var forwardedExtensions = map[string]struct{}{
+	provenance.Extension:      {},
+	"triggermeshsequencestep": {},
+	"triggermeshguardreason":  {},
+}
+
 type Instance struct {
 	port int
 
+	// Backpressure parameters.
+	maxInFlight int
+	retryAfter  time.Duration
+	// Semaphore bounding the events being ingested, nil if not bounded.
+	inFlight chan struct{}
+
+	// Rate limiting is disabled if nil.
+	limiter *rate.Limiter
+
+	// Maximum size in bytes of ingested events, zero means no limit.
+	maxEventSize int
+
+	// Add content hash to ingested events.
+	integrity bool
+
+	// Honor the debug extension of ingested events.
+	debug bool
+
+	// Identity appended to the provenance chain of ingested events, which
+	// is disabled if empty, and maximum length of the chain.
+	provenance          string
+	provenanceMaxLength int
+
+	// Broker time to live for events.
+	eventTTL time.Duration
+
+	// Generator for the ID of events that do not inform it. If nil
+	// those events are rejected.
+	idGenerator eventid.Generator
+
+	// Handling of events that violate the CloudEvents specification.
+	conformance ConformanceMode
+
+	// Trace sampler configured from the broker configuration.
+	traceSampling *cfgbroker.TraceSampling
+	sampler       atomic.Value
+
+	// Access logger configured from the broker configuration, which
+	// stores a nil logger when requests are not logged.
+	accessLogging *cfgbroker.AccessLog
+	accessLog     atomic.Value
+
+	// Schema validator configured from the broker configuration, which
+	// stores a nil validator when validation is disabled.
+	validation *cfgbroker.SchemaValidation
+	validator  atomic.Value
+
+	m sync.Mutex
+
+	// Deduplication of events with the same source and id.
+	deduplicator backend.Deduplicator
+	dedupTTL     time.Duration
+
+	// Recorder for hourly ingest counters, disabled if nil.
+	throughput *throughput.Recorder
+
+	// Archive for ingested events, disabled if nil.
+	archive *archive.Archive
+
+	// Hub for tapping ingested events, disabled if nil.
+	tap *tap.Hub
+
+	// MQTT listener port, disabled if zero, and the configuration it
+	// uses from the broker configuration.
+	mqttPort int
+	mqtt     atomic.Value
+	// Slots of the MQTT clients that did not connect yet.
+	mqttConnecting chan struct{}
+
+	// AMQP server URL and node address events are received from,
+	// disabled if the URL is empty.
+	amqpURL     string
+	amqpAddress string
+
 	ceHandler    CloudEventHandler
 	probeHandler ProbeHandler
 
+	// Broker name informed at the discovery paths, disabled if empty, and
+	// the triggers informed.
+	discoveryName     string
+	discoveryTriggers atomic.Value
+
+	// Handlers of the hosted brokers indexed by name.
+	brokerHandlers map[string]CloudEventHandler
+	hm             sync.RWMutex
+
+	// Synchronous ingest, disabled if the mode is empty, and the brokers
+	// it informs about indexed by name, empty for the default broker.
+	syncMode    SyncMode
+	syncTimeout time.Duration
+	syncTargets map[string]SyncTarget
+
 	reporter metrics.Reporter
 	logger   *zap.SugaredLogger
 }
@@ -37,14 +164,23 @@ type InstanceOption func(*Instance)
 
 func NewInstance(reporter metrics.Reporter, logger *zap.SugaredLogger, opts ...InstanceOption) *Instance {
 	i := &Instance{
-		port:     8080,
-		logger:   logger,
-		reporter: reporter,
+		port:           8080,
+		retryAfter:     time.Second,
+		brokerHandlers: make(map[string]CloudEventHandler),
+		syncTargets:    make(map[string]SyncTarget),
+		mqttConnecting: make(chan struct{}, mqttMaxConnecting),
+		logger:         logger,
+		reporter:       reporter,
 	}
+	i.sampler.Store(newTraceSampler(nil))
+	i.validator.Store((*schema.Validator)(nil))
+	i.accessLog.Store((*accessLogger)(nil))
+	i.mqtt.Store(&mqttConfig{})
 
 	for _, opt := range opts {
 		opt(i)
 	}
+	i.inFlight = newInFlightSemaphore(i.maxInFlight)
 
 	return i
 }
@@ -55,15 +191,176 @@ func InstanceWithPort(port int) InstanceOption {
 	}
 }
 
+// InstanceWithMaxInFlight limits the number of events being concurrently
+// ingested. Zero means no limit.
+func InstanceWithMaxInFlight(maxInFlight int) InstanceOption {
+	return func(i *Instance) {
+		i.maxInFlight = maxInFlight
+	}
+}
+
+// InstanceWithRetryAfter sets the wait time informed to producers when
+// events are rejected due to backpressure.
+func InstanceWithRetryAfter(retryAfter time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.retryAfter = retryAfter
+	}
+}
+
+// InstanceWithRateLimit limits the number of events per second that
+// can be ingested, allowing bursts up to the informed size.
+func InstanceWithRateLimit(eventsPerSecond float64, burst int) InstanceOption {
+	return func(i *Instance) {
+		if eventsPerSecond <= 0 {
+			return
+		}
+		if burst <= 0 {
+			burst = int(math.Max(1, math.Ceil(eventsPerSecond)))
+		}
+		i.limiter = rate.NewLimiter(rate.Limit(eventsPerSecond), burst)
+	}
+}
+
+// InstanceWithIntegrity adds a content hash to each ingested event that
+// can be verified before delivery.
+func InstanceWithIntegrity(enabled bool) InstanceOption {
+	return func(i *Instance) {
+		i.integrity = enabled
+	}
+}
+
+// InstanceWithDebug honors the debug extension of ingested events, which
+// forces tracing and verbose logging for them.
+func InstanceWithDebug(enabled bool) InstanceOption {
+	return func(i *Instance) {
+		i.debug = enabled
+	}
+}
+
+// InstanceWithProvenance appends the broker identity to the provenance
+// chain of ingested events, keeping up to maxLength identities.
+func InstanceWithProvenance(identity string, maxLength int) InstanceOption {
+	return func(i *Instance) {
+		i.provenance = identity
+		i.provenanceMaxLength = maxLength
+	}
+}
+
+// InstanceWithEventTTL informs the broker time to live for events, which
+// makes ingest record the time of events that do not inform it.
+func InstanceWithEventTTL(ttl time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.eventTTL = ttl
+	}
+}
+
+// InstanceWithIDGenerator generates the ID of ingested events that do not
+// inform it.
+func InstanceWithIDGenerator(gen eventid.Generator) InstanceOption {
+	return func(i *Instance) {
+		i.idGenerator = gen
+	}
+}
+
+// InstanceWithConformanceMode sets how events that violate the CloudEvents
+// specification are handled, which are rejected by default.
+func InstanceWithConformanceMode(mode ConformanceMode) InstanceOption {
+	return func(i *Instance) {
+		i.conformance = mode
+	}
+}
+
+// InstanceWithDeduplication discards events whose source and id were
+// already ingested during the TTL window.
+func InstanceWithDeduplication(d backend.Deduplicator, ttl time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.deduplicator = d
+		i.dedupTTL = ttl
+	}
+}
+
+// InstanceWithThroughput counts ingested events at the recorder.
+func InstanceWithThroughput(r *throughput.Recorder) InstanceOption {
+	return func(i *Instance) {
+		i.throughput = r
+	}
+}
+
+// InstanceWithArchive archives the events ingested for the default broker.
+func InstanceWithArchive(a *archive.Archive) InstanceOption {
+	return func(i *Instance) {
+		i.archive = a
+	}
+}
+
+// InstanceWithTap publishes the events ingested for the default broker to
+// the taps attached to the hub.
+func InstanceWithTap(h *tap.Hub) InstanceOption {
+	return func(i *Instance) {
+		i.tap = h
+	}
+}
+
 func (i *Instance) Start(ctx context.Context) error {
 	if i.logger == nil {
 		panic("logger is nil!")
 	}
 
-	p, err := obshttp.NewObservedHTTP(
+	popts := []cehttp.Option{
+		cehttp.WithRequestDataAtContextMiddleware(),
+		cloudevents.WithMiddleware(tracingMiddleware(&i.sampler, i.debug)),
 		cloudevents.WithPort(i.port),
-		cloudevents.WithShutdownTimeout(10*time.Second),
+		cloudevents.WithShutdownTimeout(10 * time.Second),
+		cloudevents.WithMiddleware(backpressureMiddleware(i.inFlight, i.retryAfter)),
+	}
+
+	if i.maxEventSize > 0 {
+		popts = append(popts, cloudevents.WithMiddleware(eventSizeMiddleware(i.maxEventSize)))
+	}
+
+	// Middlewares wrap the previous ones, rate limit is applied first.
+	if i.limiter != nil {
+		popts = append(popts, cloudevents.WithMiddleware(rateLimitMiddleware(i.limiter)))
+	}
+
+	// Generating IDs is applied last, only for accepted events.
+	if i.idGenerator != nil {
+		popts = append([]cehttp.Option{cloudevents.WithMiddleware(missingIDMiddleware(i.idGenerator))}, popts...)
+	}
+
+	// Conforming to the specification is applied right before the SDK
+	// validates events.
+	if i.conformance == ConformanceModeLenient || i.conformance == ConformanceModeRepair {
+		popts = append([]cehttp.Option{cloudevents.WithMiddleware(conformanceMiddleware(i.conformance, i.conformanceIDGenerator(), i.logger))}, popts...)
+	}
+
+	// Synchronous ingest replaces the CloudEvents handler response, for
+	// events that went through the rest of middlewares.
+	if i.syncMode != "" {
+		popts = append([]cehttp.Option{cloudevents.WithMiddleware(i.syncMiddleware())}, popts...)
+	}
+
+	// Batches are decomposed after the request data is set at the context,
+	// generating IDs and conforming each event to the specification.
+	popts = append([]cehttp.Option{cloudevents.WithMiddleware(i.batchMiddleware())}, popts...)
+
+	// Error responses of every middleware and the CloudEvents handler are
+	// written as problem details.
+	popts = append(popts, cloudevents.WithMiddleware(problemMiddleware()))
+
+	// Requests are logged along with the response written to producers.
+	popts = append(popts, cloudevents.WithMiddleware(i.accessLogMiddleware()))
+
+	p, err := cehttp.New(append(popts,
 		cloudevents.WithGetHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == InfoPath {
+				i.serveInfo(w)
+				return
+			}
+			if i.serveDiscovery(w, r) {
+				return
+			}
+
 			// Use common health paths.
 			if r.URL.Path != "/healthz" && r.URL.Path != "/_ah/health" {
 				w.WriteHeader(http.StatusNotFound)
@@ -81,7 +378,7 @@ func (i *Instance) Start(ctx context.Context) error {
 			if _, err := w.Write([]byte(`{"ok": "true"}`)); err != nil {
 				i.logger.Errorw("Could not write HTTP response (healthy)", zap.Error(err))
 			}
-		}),
+		}))...,
 	)
 	if err != nil {
 		return fmt.Errorf("could not create a CloudEvents HTTP client protocol: %w", err)
@@ -93,6 +390,16 @@ func (i *Instance) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create CloudEvents client: %w", err)
 	}
 
+	if i.mqttPort != 0 {
+		if err := i.startMQTT(ctx); err != nil {
+			return err
+		}
+	}
+
+	if i.amqpURL != "" {
+		go i.receiveAMQP(ctx)
+	}
+
 	i.logger.Infof("Listening on %d", i.port)
 	if err := c.StartReceiver(ctx, i.cloudEventsHandler); err != nil {
 		return fmt.Errorf("unable to start HTTP server: %w", err)
@@ -101,8 +408,77 @@ func (i *Instance) Start(ctx context.Context) error {
 	return nil
 }
 
+// conformanceIDGenerator returns the generator for the ID of events that
+// are repaired.
+func (i *Instance) conformanceIDGenerator() eventid.Generator {
+	if i.idGenerator != nil {
+		return i.idGenerator
+	}
+	return eventid.Default()
+}
+
 func (i *Instance) UpdateFromConfig(c *cfgbroker.Config) {
 	i.logger.Info("Ingest Server UpdateFromConfig ...")
+
+	var ts *cfgbroker.TraceSampling
+	var al *cfgbroker.AccessLog
+	var sv *cfgbroker.SchemaValidation
+	mc := &mqttConfig{}
+	if c.Ingest != nil {
+		ts = c.Ingest.TraceSampling
+		al = c.Ingest.AccessLog
+		sv = c.Ingest.Validation
+		mc.user = c.Ingest.User
+		mc.password = c.Ingest.Password
+		if c.Ingest.PasswordFrom != nil {
+			mc.passwordFromSecret = true
+			s, err := secret.New(c.Ingest.PasswordFrom)
+			if err != nil {
+				i.logger.Errorw("Could not read ingest password, MQTT connections will be refused", zap.Error(err))
+			}
+			mc.passwordFrom = s
+		}
+		if c.Ingest.MQTT != nil {
+			mc.topics = c.Ingest.MQTT.Topics
+		}
+	}
+	i.mqtt.Store(mc)
+
+	if i.discoveryName != "" {
+		i.updateDiscovery(c)
+	}
+
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if !reflect.DeepEqual(ts, i.traceSampling) {
+		i.logger.Infow("Updating ingest trace sampling")
+		i.traceSampling = ts
+		i.sampler.Store(newTraceSampler(ts))
+	}
+
+	if !reflect.DeepEqual(al, i.accessLogging) {
+		i.logger.Infow("Updating ingest access log")
+		i.accessLogging = al
+		i.accessLog.Store(newAccessLogger(al, i.logger.Named("access")))
+	}
+
+	if !reflect.DeepEqual(sv, i.validation) {
+		i.logger.Infow("Updating ingest schema validation")
+
+		var v *schema.Validator
+		if sv != nil {
+			var err error
+			if v, err = schema.New(sv, &http.Client{Timeout: schemaRegistryTimeout}); err != nil {
+				// Keep validating with the previous configuration.
+				i.logger.Errorw("Could not configure ingest schema validation", zap.Error(err))
+				return
+			}
+		}
+
+		i.validation = sv
+		i.validator.Store(v)
+	}
 }
 
 func (i *Instance) RegisterCloudEventHandler(h CloudEventHandler) {
@@ -113,18 +489,207 @@ func (i *Instance) RegisterProbeHandler(h ProbeHandler) {
 	i.probeHandler = h
 }
 
-func (i *Instance) cloudEventsHandler(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, protocol.Result) {
-	i.logger.Debug(fmt.Sprintf("Received CloudEvent: %v", event.String()))
+// RegisterBrokerHandler sets the handler for events ingested at the path of
+// the hosted broker.
+func (i *Instance) RegisterBrokerHandler(name string, h CloudEventHandler) {
+	i.hm.Lock()
+	defer i.hm.Unlock()
+	i.brokerHandlers[name] = h
+}
+
+// UnregisterBrokerHandler stops ingesting events for the hosted broker.
+func (i *Instance) UnregisterBrokerHandler(name string) {
+	i.hm.Lock()
+	defer i.hm.Unlock()
+	delete(i.brokerHandlers, name)
+	delete(i.syncTargets, name)
+}
+
+// handlerFor returns the handler for the request path along with the name
+// of the hosted broker, empty for the default broker.
+func (i *Instance) handlerFor(ctx context.Context) (CloudEventHandler, string, bool) {
+	rd := cehttp.RequestDataFromContext(ctx)
+	if rd == nil || rd.URL == nil || !strings.HasPrefix(rd.URL.Path, BrokersPath) {
+		return i.ceHandler, "", true
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(rd.URL.Path, BrokersPath), "/")
 
-	if i.ceHandler == nil {
+	i.hm.RLock()
+	defer i.hm.RUnlock()
+	h, ok := i.brokerHandlers[name]
+	return h, name, ok
+}
+
+// Produce ingests an event that was not received at the ingest endpoints,
+// such as those sent through the admin API, going through the same checks
+// and enrichments as received events. Rejected events return the result,
+// which informs the HTTP status code.
+func (i *Instance) Produce(ctx context.Context, event *cloudevents.Event) error {
+	if _, res := i.cloudEventsHandler(ctx, *event); !protocol.IsACK(res) {
+		return res
+	}
+	return nil
+}
+
+// stripReservedExtensions removes the extensions reserved for the broker
+// from the ingested event.
+func stripReservedExtensions(event *cloudevents.Event) {
+	for name := range event.Extensions() {
+		if _, ok := forwardedExtensions[name]; ok || !strings.HasPrefix(name, reservedExtensionPrefix) {
+			continue
+		}
+		_ = event.Context.SetExtension(name, nil)
+	}
+}
+
+func (i *Instance) cloudEventsHandler(ctx context.Context, event cloudevents.Event) (_ *cloudevents.Event, res protocol.Result) {
+	if i.debug && debug.Enabled(&event) {
+		i.logger.Infow("Received debug CloudEvent", zap.Bool("debug", true), zap.Any("event", event))
+		defer func() {
+			i.logger.Infow("Debug CloudEvent ingested", zap.Bool("debug", true), zap.String("id", event.ID()), zap.Any("result", res))
+		}()
+	} else {
+		i.logger.Debug(fmt.Sprintf("Received CloudEvent: %v", event.String()))
+	}
+	logAccessEvent(ctx, &event)
+
+	ceHandler, broker, ok := i.handlerFor(ctx)
+	if !ok {
+		i.logger.Debugw("Rejecting CloudEvent for unknown broker", zap.String("broker", broker))
+		return nil, cehttp.NewResult(http.StatusNotFound, "broker %q not found", broker)
+	}
+
+	if ceHandler == nil {
 		i.logger.Errorw("CloudEvent lost due to no ingest handler configured")
 		return nil, protocol.ResultNACK
 	}
 
-	if err := i.ceHandler(ctx, &event); err != nil {
+	stripReservedExtensions(&event)
+
+	if _, _, err := backend.ScheduledTime(&event, time.Now()); err != nil {
+		i.logger.Debugw("Rejecting CloudEvent with invalid scheduling extensions", zap.Error(err))
+		return nil, cehttp.NewResult(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	_, hasTTL, err := expiry.TTL(&event)
+	if err != nil {
+		i.logger.Debugw("Rejecting CloudEvent with invalid TTL extension", zap.Error(err))
+		return nil, cehttp.NewResult(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	if v := i.validator.Load().(*schema.Validator); v != nil {
+		var verr *schema.ValidationError
+		err := v.Validate(ctx, &event)
+		switch {
+		case errors.As(err, &verr):
+			i.logger.Debugw("Rejecting CloudEvent that does not conform to its schema",
+				zap.String("type", event.Type()), zap.String("id", event.ID()), zap.Strings("errors", verr.Errors))
+			return nil, schemaRejection(ctx, verr)
+		case err != nil:
+			// Favor availability over validation.
+			i.logger.Warnw("Could not validate CloudEvent against its schema", zap.Error(err))
+		}
+	}
+
+	// Record the ingest time to calculate the age of events that do not
+	// inform the time attribute.
+	if hasTTL || i.eventTTL > 0 {
+		if err := expiry.SetIngestTime(&event, time.Now()); err != nil {
+			i.logger.Errorw("Could not set CloudEvent ingest time", zap.Error(err))
+			return nil, protocol.ResultNACK
+		}
+	}
+
+	// Events re-injected from the archive are duplicates on purpose.
+	if i.deduplicator != nil && !archive.IsReinjected(ctx) {
+		key := deduplicationKey(broker, &event)
+		ok, err := i.deduplicator.MarkProduced(ctx, key, i.dedupTTL)
+		switch {
+		case err != nil:
+			// Favor availability over deduplication.
+			i.logger.Warnw("Could not check CloudEvent for duplicates", zap.Error(err))
+		case !ok:
+			i.logger.Debugw("Discarding duplicated CloudEvent",
+				zap.String("source", event.Source()), zap.String("id", event.ID()))
+			return nil, protocol.ResultACK
+		default:
+			// If the event is not produced allow producers to retry.
+			defer func() {
+				if !protocol.IsACK(res) {
+					if err := i.deduplicator.UnmarkProduced(ctx, key); err != nil {
+						i.logger.Warnw("Could not unregister CloudEvent for duplicates", zap.Error(err))
+					}
+				}
+			}()
+		}
+	}
+
+	if i.provenance != "" {
+		identity := i.provenance
+		if broker != "" {
+			identity += "/" + broker
+		}
+
+		looped, err := provenance.Append(&event, identity, i.provenanceMaxLength)
+		if err != nil {
+			i.logger.Errorw("Could not set CloudEvent provenance", zap.Error(err))
+			return nil, protocol.ResultNACK
+		}
+		if looped {
+			i.logger.Warnw("CloudEvent was already ingested by this broker, which might be a loop between brokers",
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()),
+				zap.Strings("provenance", provenance.Chain(&event)))
+		}
+	}
+
+	if i.integrity {
+		if err := integrity.Sign(&event); err != nil {
+			i.logger.Errorw("Could not compute CloudEvent content hash", zap.Error(err))
+			return nil, protocol.ResultNACK
+		}
+	}
+
+	if err := ceHandler(ctx, &event); err != nil {
+		if errors.Is(err, backend.ErrBackendBusy) {
+			i.logger.Warnw("CloudEvent rejected due to backend backpressure", zap.Error(err))
+			setProblem(ctx, ProblemBackendUnavailable, nil)
+			return nil, cehttp.NewResult(http.StatusTooManyRequests, "backend is busy")
+		}
+		if errors.Is(err, backend.ErrEventTooLarge) {
+			i.logger.Debugw("CloudEvent rejected due to backend size limit", zap.Error(err))
+			return nil, cehttp.NewResult(http.StatusRequestEntityTooLarge, "%s", err.Error())
+		}
+
 		i.logger.Errorw("Could not produce CloudEvent to broker", zap.Error(err))
+		setProblem(ctx, ProblemBackendUnavailable, nil)
 		return nil, protocol.ResultNACK
 	}
 
+	i.throughput.Ingested()
+	if broker == "" {
+		i.archive.Add(ctx, &event)
+		i.tap.Publish(ctx, &event)
+	}
+
 	return nil, protocol.ResultACK
 }
+
+// isRejection returns true if the event was rejected due to its contents,
+// which means that ingesting it again would get the same result.
+func isRejection(res protocol.Result) bool {
+	var httpResult *cehttp.Result
+	return errors.As(res, &httpResult) && httpResult.StatusCode >= 400 && httpResult.StatusCode < 500 &&
+		httpResult.StatusCode != http.StatusTooManyRequests
+}
+
+// deduplicationKey identifies events by their source and id, along with
+// the hosted broker they are ingested for.
+func deduplicationKey(broker string, event *cloudevents.Event) string {
+	k := event.Source() + "\x00" + event.ID()
+	if broker != "" {
+		k = broker + "\x00" + k
+	}
+	h := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(h[:])
+}
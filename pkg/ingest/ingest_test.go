@@ -0,0 +1,272 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	"github.com/triggermesh/brokers/pkg/common/expiry"
+	"github.com/triggermesh/brokers/pkg/common/integrity"
+	"github.com/triggermesh/brokers/pkg/common/provenance"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestBrokerRouting(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar())
+
+	received := map[string]int{}
+	handler := func(name string) CloudEventHandler {
+		return func(context.Context, *cloudevents.Event) error {
+			received[name]++
+			return nil
+		}
+	}
+	i.RegisterCloudEventHandler(handler("default"))
+	i.RegisterBrokerHandler("orders", handler("orders"))
+
+	ingest := func(path string) protocol.Result {
+		ctx := cehttp.WithRequestDataAtContext(context.Background(), httptest.NewRequest(http.MethodPost, path, nil))
+		_, res := i.cloudEventsHandler(ctx, lib.NewCloudEvent())
+		return res
+	}
+
+	assert.True(t, protocol.IsACK(ingest("/")))
+	assert.True(t, protocol.IsACK(ingest("/brokers/orders")))
+	assert.True(t, protocol.IsACK(ingest("/brokers/orders/")))
+
+	res := ingest("/brokers/payments")
+	var httpResult *cehttp.Result
+	require.True(t, errors.As(res, &httpResult))
+	assert.Equal(t, http.StatusNotFound, httpResult.StatusCode)
+
+	i.UnregisterBrokerHandler("orders")
+	assert.False(t, protocol.IsACK(ingest("/brokers/orders")))
+
+	assert.Equal(t, map[string]int{"default": 1, "orders": 2}, received)
+}
+
+func TestSchemaValidation(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "order.created.json"),
+		[]byte(`{"type":"object","required":["id"]}`), 0o600))
+
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	produced := 0
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error {
+		produced++
+		return nil
+	})
+
+	ingest := func(data string) *httptest.ResponseRecorder {
+		e := lib.NewCloudEvent(lib.CloudEventWithDataOption(cloudevents.ApplicationJSON, []byte(data)))
+		e.SetType("order.created")
+
+		rec := httptest.NewRecorder()
+		problemMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, res := i.cloudEventsHandler(r.Context(), e)
+			var httpResult *cehttp.Result
+			if errors.As(res, &httpResult) {
+				w.WriteHeader(httpResult.StatusCode)
+				_, _ = w.Write([]byte(fmt.Sprintf(httpResult.Format, httpResult.Args...)))
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		return rec
+	}
+
+	// Validation is disabled until configured.
+	assert.Equal(t, http.StatusAccepted, ingest(`{}`).Code)
+
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{Validation: &cfgbroker.SchemaValidation{Directory: &dir}}})
+
+	assert.Equal(t, http.StatusAccepted, ingest(`{"id":"1"}`).Code)
+
+	rec := ingest(`{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"code": "invalid_cloudevent",
+		"detail": "event data does not conform to the schema of type \"order.created\": .id in body is required",
+		"eventType": "order.created",
+		"errors": [".id in body is required"]
+	}`, rec.Body.String())
+
+	i.UpdateFromConfig(&cfgbroker.Config{})
+	assert.Equal(t, http.StatusAccepted, ingest(`{}`).Code)
+
+	assert.Equal(t, 3, produced)
+}
+
+func TestProvenance(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithProvenance("edge-1", 2))
+
+	var chains [][]string
+	handler := func(_ context.Context, e *cloudevents.Event) error {
+		chains = append(chains, provenance.Chain(e))
+		return nil
+	}
+	i.RegisterCloudEventHandler(handler)
+	i.RegisterBrokerHandler("orders", handler)
+
+	ingest := func(path string, e cloudevents.Event) {
+		ctx := cehttp.WithRequestDataAtContext(context.Background(), httptest.NewRequest(http.MethodPost, path, nil))
+		_, res := i.cloudEventsHandler(ctx, e)
+		require.True(t, protocol.IsACK(res))
+	}
+
+	ingest("/", lib.NewCloudEvent())
+	ingest("/brokers/orders", lib.NewCloudEvent(lib.CloudEventWithExtensionOption(provenance.Extension, "central,region-a")))
+
+	assert.Equal(t, [][]string{{"edge-1"}, {"region-a", "edge-1/orders"}}, chains)
+}
+
+func TestReservedExtensions(t *testing.T) {
+	var ingested *cloudevents.Event
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	i.RegisterCloudEventHandler(func(_ context.Context, e *cloudevents.Event) error {
+		ingested = e
+		return nil
+	})
+
+	e := lib.NewCloudEvent(
+		lib.CloudEventWithExtensionOption("triggermeshrequeuetrigger", "orders"),
+		lib.CloudEventWithExtensionOption("triggermeshrequeueattempts", "10"),
+		lib.CloudEventWithExtensionOption(backend.RequeueAtExtension, "2020-01-01T00:00:00Z"),
+		lib.CloudEventWithExtensionOption(provenance.Extension, "central"),
+		lib.CloudEventWithExtensionOption("triggermeshsequencestep", "1"),
+		lib.CloudEventWithExtensionOption("region", "eu"),
+	)
+	_, res := i.cloudEventsHandler(context.Background(), e)
+	require.True(t, protocol.IsACK(res))
+	require.NotNil(t, ingested)
+
+	exts := ingested.Extensions()
+	for _, name := range []string{"triggermeshrequeuetrigger", "triggermeshrequeueattempts", backend.RequeueAtExtension} {
+		assert.NotContains(t, exts, name, "Internal extensions must be removed from ingested events")
+	}
+	for _, name := range []string{provenance.Extension, "triggermeshsequencestep", "region"} {
+		assert.Contains(t, exts, name)
+	}
+}
+
+func TestDebugEvents(t *testing.T) {
+	tcs := map[string]struct {
+		honorDebug bool
+		flagged    bool
+		logged     bool
+	}{
+		"debug event": {
+			honorDebug: true,
+			flagged:    true,
+			logged:     true,
+		},
+		"debug not honored": {
+			flagged: true,
+		},
+		"not a debug event": {
+			honorDebug: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			i := NewInstance(nil, zap.New(core).Sugar(), InstanceWithDebug(tc.honorDebug))
+			i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error { return nil })
+
+			event := lib.NewCloudEvent()
+			if tc.flagged {
+				event.SetExtension(debug.Extension, true)
+			}
+			_, res := i.cloudEventsHandler(context.Background(), event)
+			require.True(t, cloudevents.IsACK(res))
+
+			debugLogs := logs.FilterField(zap.Bool("debug", true)).All()
+			if !tc.logged {
+				assert.Empty(t, debugLogs)
+				return
+			}
+			require.Len(t, debugLogs, 2, "Reception and result must be logged")
+			assert.Equal(t, "Received debug CloudEvent", debugLogs[0].Message)
+			assert.Equal(t, "Debug CloudEvent ingested", debugLogs[1].Message)
+		})
+	}
+}
+
+func TestProduce(t *testing.T) {
+	tcs := map[string]struct {
+		extensions map[string]interface{}
+		produceErr error
+		code       int
+		isError    bool
+	}{
+		"ingested": {},
+		"rejected": {
+			extensions: map[string]interface{}{expiry.Extension: "not a number"},
+			code:       http.StatusBadRequest,
+			isError:    true,
+		},
+		"backend failing": {
+			produceErr: errors.New("connection refused"),
+			isError:    true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var produced int
+			i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithIntegrity(true))
+			i.RegisterCloudEventHandler(func(_ context.Context, event *cloudevents.Event) error {
+				if tc.produceErr != nil {
+					return tc.produceErr
+				}
+				assert.Contains(t, event.Extensions(), integrity.HashAttribute, "Events must be enriched by ingest")
+				produced++
+				return nil
+			})
+
+			event := lib.NewCloudEvent()
+			for k, v := range tc.extensions {
+				event.SetExtension(k, v)
+			}
+
+			err := i.Produce(context.Background(), &event)
+			if !tc.isError {
+				require.NoError(t, err)
+				assert.Equal(t, 1, produced)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Zero(t, produced)
+			if tc.code != 0 {
+				var res *cehttp.Result
+				require.ErrorAs(t, err, &res)
+				assert.Equal(t, tc.code, res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// MQTTTopicExtension informs the topic of events ingested through MQTT.
+const MQTTTopicExtension = "mqtttopic"
+
+// MQTT control packet types, as defined by MQTT 3.1.1.
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttPubrec      = 5
+	mqttPubrel      = 6
+	mqttPubcomp     = 7
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttUnsubscribe = 10
+	mqttUnsuback    = 11
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+)
+
+// CONNACK return codes, and SUBACK failure code.
+const (
+	mqttAccepted              = 0
+	mqttUnacceptableProtocol  = 1
+	mqttBadUsernameOrPassword = 4
+	mqttSubscribeFailure      = 0x80
+)
+
+const (
+	// MQTT 3.1.1 is the only protocol level supported.
+	mqttProtocolLevel = 4
+
+	// Time clients have to send the CONNECT packet.
+	mqttConnectTimeout = 10 * time.Second
+
+	// Maximum size of CONNECT packets, which are read before the client
+	// is authenticated.
+	mqttMaxConnectPacketSize = 4 << 10
+
+	// Maximum number of clients that did not connect yet, further
+	// connections being closed right away.
+	mqttMaxConnecting = 256
+
+	// Maximum size of packets when the size of events is not limited.
+	mqttDefaultMaxPacketSize = 16 << 20
+
+	mqttMaxRemainingLengthBytes = 4
+)
+
+var errMQTTMalformed = errors.New("malformed MQTT packet")
+
+// mqttConfig is the part of the broker configuration the MQTT listener
+// uses, replaced as a whole when the configuration is updated.
+type mqttConfig struct {
+	user     string
+	password string
+	topics   []cfgbroker.MQTTTopic
+
+	// Password read from a secret for every connection instead, nil when
+	// the secret could not be read.
+	passwordFromSecret bool
+	passwordFrom       *secret.Secret
+}
+
+// checkPassword returns whether the password is the ingest one. Passwords
+// are refused when the ingest password secret cannot be read.
+func (c *mqttConfig) checkPassword(password string) bool {
+	if !c.passwordFromSecret {
+		return equalSecret(password, c.password)
+	}
+	if c.passwordFrom == nil {
+		return false
+	}
+	v, err := c.passwordFrom.Value()
+	return err == nil && equalSecret(password, v)
+}
+
+// equalSecret compares credentials in constant time.
+func equalSecret(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// InstanceWithMQTTPort starts an MQTT listener at the port, which ingests
+// the messages published by MQTT clients as CloudEvents. Zero disables the
+// listener.
+func InstanceWithMQTTPort(port int) InstanceOption {
+	return func(i *Instance) {
+		i.mqttPort = port
+	}
+}
+
+// startMQTT listens for MQTT connections until the context is done.
+func (i *Instance) startMQTT(ctx context.Context) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", i.mqttPort))
+	if err != nil {
+		return fmt.Errorf("unable to start MQTT listener: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	i.logger.Infof("Listening for MQTT on %d", i.mqttPort)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					i.logger.Errorw("MQTT listener stopped", zap.Error(err))
+				}
+				return
+			}
+			go i.serveMQTT(ctx, conn)
+		}
+	}()
+
+	return nil
+}
+
+// serveMQTT handles an MQTT connection. Messages are ingested one at a time
+// in the order they are published, which applies the backpressure of the
+// backend to the client, since further packets are not read meanwhile.
+//
+// Messages published with QoS 1 and 2 are acknowledged once produced to the
+// backend. When they cannot be produced the connection is closed without
+// acknowledging them, so that the client publishes them again after
+// reconnecting.
+//
+// Clients are not authenticated until their CONNECT packet is read, which
+// is why its size and the number of clients connecting are limited.
+func (i *Instance) serveMQTT(ctx context.Context, conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	logger := i.logger.With(zap.String("remote", conn.RemoteAddr().String()))
+
+	select {
+	case i.mqttConnecting <- struct{}{}:
+	default:
+		logger.Debug("Closing MQTT connection, too many clients are connecting")
+		return
+	}
+	connecting := true
+	connected := func() {
+		if connecting {
+			connecting = false
+			<-i.mqttConnecting
+		}
+	}
+	defer connected()
+
+	maxSize := mqttDefaultMaxPacketSize
+	if i.maxEventSize > 0 {
+		// Topic and packet identifier overhead.
+		maxSize = i.maxEventSize + 2 + 65535 + 2
+	}
+	r := bufio.NewReader(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(mqttConnectTimeout))
+	p, err := readMQTTPacket(r, mqttMaxConnectPacketSize)
+	if err != nil || p.kind != mqttConnect {
+		logger.Debugw("Closing MQTT connection that did not connect", zap.Error(err))
+		return
+	}
+
+	clientID, keepAlive, code, err := i.mqttConnect(p)
+	if err != nil {
+		logger.Debugw("Closing MQTT connection with malformed CONNECT packet", zap.Error(err))
+		return
+	}
+	if err := writeMQTTPacket(conn, mqttConnack<<4, []byte{0, code}); err != nil || code != mqttAccepted {
+		logger.Debugw("MQTT connection refused", zap.Uint8("code", code))
+		return
+	}
+	connected()
+	logger = logger.With(zap.String("client", clientID))
+	logger.Debug("MQTT client connected")
+
+	// Identifiers of QoS 2 messages already ingested whose release was
+	// not received yet.
+	received := make(map[uint16]struct{})
+
+	for {
+		// Clients are disconnected if they do not send any packet during
+		// one and a half times the keep alive.
+		var deadline time.Time
+		if keepAlive > 0 {
+			deadline = time.Now().Add(keepAlive * 3 / 2)
+		}
+		_ = conn.SetReadDeadline(deadline)
+
+		p, err := readMQTTPacket(r, maxSize)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				logger.Debugw("Closing MQTT connection", zap.Error(err))
+			}
+			return
+		}
+
+		var werr error
+		switch p.kind {
+		case mqttPublish:
+			qos := (p.flags >> 1) & 3
+			topic, id, payload, err := parseMQTTPublish(p, qos)
+			if err != nil {
+				logger.Debugw("Closing MQTT connection with malformed PUBLISH packet", zap.Error(err))
+				return
+			}
+
+			if _, dup := received[id]; qos == 2 && dup {
+				werr = writeMQTTPacket(conn, mqttPubrec<<4, mqttPacketID(id))
+				break
+			}
+
+			if err := i.ingestMQTT(ctx, clientID, topic, payload); err != nil {
+				if qos > 0 {
+					logger.Warnw("Closing MQTT connection, the message will be published again", zap.Error(err))
+					return
+				}
+				logger.Errorw("MQTT message was lost", zap.Bool("lost", true), zap.String("topic", topic), zap.Error(err))
+			}
+
+			switch qos {
+			case 1:
+				werr = writeMQTTPacket(conn, mqttPuback<<4, mqttPacketID(id))
+			case 2:
+				received[id] = struct{}{}
+				werr = writeMQTTPacket(conn, mqttPubrec<<4, mqttPacketID(id))
+			}
+
+		case mqttPubrel:
+			if len(p.body) < 2 {
+				return
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			delete(received, id)
+			werr = writeMQTTPacket(conn, mqttPubcomp<<4, mqttPacketID(id))
+
+		case mqttSubscribe:
+			// The listener only ingests messages, subscriptions are
+			// refused for each informed topic filter.
+			if len(p.body) < 2 {
+				return
+			}
+			body := append([]byte{}, p.body[:2]...)
+			for rest := p.body[2:]; len(rest) > 0; {
+				var err error
+				if _, rest, err = readMQTTString(rest); err != nil || len(rest) == 0 {
+					return
+				}
+				rest = rest[1:]
+				body = append(body, mqttSubscribeFailure)
+			}
+			werr = writeMQTTPacket(conn, mqttSuback<<4, body)
+
+		case mqttUnsubscribe:
+			if len(p.body) < 2 {
+				return
+			}
+			werr = writeMQTTPacket(conn, mqttUnsuback<<4, p.body[:2])
+
+		case mqttPingreq:
+			werr = writeMQTTPacket(conn, mqttPingresp<<4, nil)
+
+		case mqttDisconnect:
+			logger.Debug("MQTT client disconnected")
+			return
+
+		default:
+			logger.Debugw("Closing MQTT connection with unexpected packet", zap.Uint8("type", p.kind))
+			return
+		}
+
+		if werr != nil {
+			logger.Debugw("Could not write to MQTT connection", zap.Error(werr))
+			return
+		}
+	}
+}
+
+// mqttConnect parses the CONNECT packet and returns the client identifier,
+// the keep alive and the return code for the CONNACK packet.
+func (i *Instance) mqttConnect(p *mqttPacket) (string, time.Duration, byte, error) {
+	protocolName, rest, err := readMQTTString(p.body)
+	if err != nil || len(rest) < 4 {
+		return "", 0, 0, errMQTTMalformed
+	}
+	if protocolName != "MQTT" || rest[0] != mqttProtocolLevel {
+		return "", 0, mqttUnacceptableProtocol, nil
+	}
+	flags := rest[1]
+	keepAlive := time.Duration(binary.BigEndian.Uint16(rest[2:4])) * time.Second
+
+	clientID, rest, err := readMQTTString(rest[4:])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if clientID == "" {
+		clientID = uuid.New().String()
+	}
+
+	// Will messages are not published by the listener.
+	if flags&0x04 != 0 {
+		if _, rest, err = readMQTTString(rest); err != nil {
+			return "", 0, 0, err
+		}
+		if _, rest, err = readMQTTString(rest); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	var user, password string
+	if flags&0x80 != 0 {
+		if user, rest, err = readMQTTString(rest); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	if flags&0x40 != 0 {
+		if password, _, err = readMQTTString(rest); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	cfg := i.mqtt.Load().(*mqttConfig)
+	if cfg.user != "" && (!equalSecret(user, cfg.user) || !cfg.checkPassword(password)) {
+		return clientID, keepAlive, mqttBadUsernameOrPassword, nil
+	}
+
+	return clientID, keepAlive, mqttAccepted, nil
+}
+
+// ingestMQTT converts the message to a CloudEvent and ingests it as if it
+// was received through HTTP. Messages that are discarded do not return an
+// error, since publishing them again would not change the outcome.
+func (i *Instance) ingestMQTT(ctx context.Context, clientID, topic string, payload []byte) error {
+	start := time.Now()
+
+	t, ok := matchMQTTTopic(i.mqtt.Load().(*mqttConfig).topics, topic)
+	if !ok {
+		i.logger.Debugw("Discarding MQTT message for a topic that is not accepted", zap.String("topic", topic))
+		return nil
+	}
+
+	if i.maxEventSize > 0 && len(payload) > i.maxEventSize {
+		i.logger.Warnw("Discarding MQTT message that exceeds the maximum event size",
+			zap.String("topic", topic), zap.Int("size", len(payload)))
+		return nil
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(i.conformanceIDGenerator().NewID())
+	event.SetTime(start)
+	event.SetExtension(MQTTTopicExtension, topic)
+
+	if t.Type != nil {
+		event.SetType(*t.Type)
+	} else {
+		event.SetType(strings.ReplaceAll(topic, "/", "."))
+	}
+	if t.Source != nil {
+		event.SetSource(*t.Source)
+	} else {
+		event.SetSource(clientID)
+	}
+
+	contentType := "application/octet-stream"
+	if json.Valid(payload) {
+		contentType = cloudevents.ApplicationJSON
+	}
+	if err := event.SetData(contentType, payload); err != nil {
+		return fmt.Errorf("setting event data: %w", err)
+	}
+
+	if err := event.Validate(); err != nil {
+		i.logger.Warnw("Discarding MQTT message that does not convert to a valid CloudEvent",
+			zap.String("topic", topic), zap.Error(err))
+		return nil
+	}
+
+	if i.limiter != nil {
+		if err := i.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	_, res := i.cloudEventsHandler(ctx, event)
+	if i.reporter != nil {
+		i.reporter.ReportProcessedEvent(protocol.IsACK(res), event.Type(), float64(time.Since(start)/time.Millisecond))
+	}
+	if protocol.IsACK(res) {
+		return nil
+	}
+
+	if isRejection(res) {
+		i.logger.Warnw("Discarding rejected MQTT message", zap.String("topic", topic), zap.Error(res))
+		return nil
+	}
+
+	return res
+}
+
+// matchMQTTTopic returns the first topic whose filter matches, any topic
+// with the default attributes when no topics are configured.
+func matchMQTTTopic(topics []cfgbroker.MQTTTopic, topic string) (cfgbroker.MQTTTopic, bool) {
+	if len(topics) == 0 {
+		return cfgbroker.MQTTTopic{}, true
+	}
+
+	for _, t := range topics {
+		if matchMQTTTopicFilter(t.Filter, topic) {
+			return t, true
+		}
+	}
+	return cfgbroker.MQTTTopic{}, false
+}
+
+// matchMQTTTopicFilter returns true if the filter matches the topic. As
+// required by MQTT, wildcards at the first level do not match topics that
+// start with "$".
+func matchMQTTTopicFilter(filter, topic string) bool {
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+
+	if strings.HasPrefix(topic, "$") && (fl[0] == "+" || fl[0] == "#") {
+		return false
+	}
+
+	for i, f := range fl {
+		switch {
+		case f == "#":
+			return true
+		case i >= len(tl):
+			return false
+		case f != "+" && f != tl[i]:
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
+
+type mqttPacket struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readMQTTPacket reads a control packet, returning an error if its size
+// exceeds the maximum.
+func readMQTTPacket(r *bufio.Reader, maxSize int) (*mqttPacket, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var size, shift int
+	for n := 0; ; n++ {
+		if n == mqttMaxRemainingLengthBytes {
+			return nil, errMQTTMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("MQTT packet of %d bytes exceeds the maximum of %d", size, maxSize)
+	}
+
+	p := &mqttPacket{kind: h >> 4, flags: h & 0x0f, body: make([]byte, size)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// writeMQTTPacket writes a control packet with the fixed header byte.
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	b := []byte{header}
+	size := len(body)
+	for {
+		d := byte(size & 0x7f)
+		size >>= 7
+		if size > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if size == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+// parseMQTTPublish returns the topic, packet identifier and payload of
+// a PUBLISH packet.
+func parseMQTTPublish(p *mqttPacket, qos byte) (string, uint16, []byte, error) {
+	if qos > 2 {
+		return "", 0, nil, errMQTTMalformed
+	}
+
+	topic, rest, err := readMQTTString(p.body)
+	if err != nil || topic == "" || strings.ContainsAny(topic, "+#") {
+		return "", 0, nil, errMQTTMalformed
+	}
+
+	var id uint16
+	if qos > 0 {
+		if len(rest) < 2 {
+			return "", 0, nil, errMQTTMalformed
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+
+	return topic, id, rest, nil
+}
+
+// readMQTTString reads a length prefixed UTF-8 string.
+func readMQTTString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errMQTTMalformed
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errMQTTMalformed
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func mqttPacketID(id uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, id)
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestMatchMQTTTopicFilter(t *testing.T) {
+	testCases := map[string]struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		"exact":                {"sensors/room1", "sensors/room1", true},
+		"exact mismatch":       {"sensors/room1", "sensors/room2", false},
+		"single level":         {"sensors/+/temperature", "sensors/room1/temperature", true},
+		"single level missing": {"sensors/+/temperature", "sensors/temperature", false},
+		"multi level":          {"sensors/#", "sensors/room1/temperature", true},
+		"multi level parent":   {"sensors/#", "sensors", true},
+		"longer topic":         {"sensors/+", "sensors/room1/temperature", false},
+		"system topic":         {"#", "$SYS/uptime", false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.match, matchMQTTTopicFilter(tc.filter, tc.topic))
+		})
+	}
+}
+
+func TestMQTTIngest(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	temperature := "sensor.temperature"
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{
+		User:     "device",
+		Password: "secret",
+		MQTT: &cfgbroker.MQTTIngest{Topics: []cfgbroker.MQTTTopic{
+			{Filter: "sensors/+/temperature", Type: &temperature},
+			{Filter: "alerts/#"},
+		}},
+	}})
+
+	var produced []cloudevents.Event
+	var produceErr error
+	i.RegisterCloudEventHandler(func(_ context.Context, e *cloudevents.Event) error {
+		if produceErr != nil {
+			return produceErr
+		}
+		produced = append(produced, *e)
+		return nil
+	})
+
+	c := connectMQTT(t, i, "device", "wrong")
+	assert.Equal(t, []byte{0, mqttBadUsernameOrPassword}, c.expect(mqttConnack).body)
+	c.expectClosed()
+
+	c = connectMQTT(t, i, "device", "secret")
+	assert.Equal(t, []byte{0, mqttAccepted}, c.expect(mqttConnack).body)
+
+	c.publish(1, 1, "sensors/room1/temperature", `{"celsius":21}`)
+	assert.Equal(t, mqttPacketID(1), c.expect(mqttPuback).body)
+	c.publish(0, 0, "sensors/room1/humidity", `{"percent":40}`)
+	c.publish(0, 0, "alerts/room1/smoke", `smoke`)
+
+	c.send(mqttSubscribe<<4|0x02, append(mqttPacketID(2), mqttString("sensors/#")...), []byte{0})
+	assert.Equal(t, append(mqttPacketID(2), mqttSubscribeFailure), c.expect(mqttSuback).body)
+
+	// QoS 2 messages published again before being released are ingested
+	// once.
+	c.publish(2, 3, "alerts/room2/flood", `flood`)
+	assert.Equal(t, mqttPacketID(3), c.expect(mqttPubrec).body)
+	c.publish(2, 3, "alerts/room2/flood", `flood`)
+	assert.Equal(t, mqttPacketID(3), c.expect(mqttPubrec).body)
+	c.send(mqttPubrel<<4|0x02, mqttPacketID(3))
+	assert.Equal(t, mqttPacketID(3), c.expect(mqttPubcomp).body)
+
+	require.Len(t, produced, 3, "Messages for topics that are not accepted must be discarded")
+	e := produced[0]
+	assert.Equal(t, "sensor.temperature", e.Type())
+	assert.Equal(t, "client", e.Source())
+	assert.Equal(t, cloudevents.ApplicationJSON, e.DataContentType())
+	assert.JSONEq(t, `{"celsius":21}`, string(e.Data()))
+	assert.Equal(t, "sensors/room1/temperature", e.Extensions()[MQTTTopicExtension])
+
+	e = produced[1]
+	assert.Equal(t, "alerts.room1.smoke", e.Type())
+	assert.Equal(t, "application/octet-stream", e.DataContentType())
+	assert.Equal(t, "smoke", string(e.Data()))
+
+	// Messages that cannot be produced are not acknowledged.
+	produceErr = errors.New("backend is down")
+	c.publish(1, 4, "sensors/room1/temperature", `{"celsius":22}`)
+	c.expectClosed()
+}
+
+func TestMQTTIngestPasswordFromSecret(t *testing.T) {
+	env := "TEST_MQTT_PASSWORD"
+	t.Setenv(env, "secret")
+
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{
+		User:         "device",
+		PasswordFrom: &cfgbroker.SecretSource{Env: &env},
+	}})
+
+	c := connectMQTT(t, i, "device", "secret")
+	assert.Equal(t, []byte{0, mqttAccepted}, c.expect(mqttConnack).body)
+
+	// Clients are refused when the password cannot be read.
+	missing := "TEST_MQTT_MISSING_PASSWORD"
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{
+		User:         "device",
+		PasswordFrom: &cfgbroker.SecretSource{Env: &missing},
+	}})
+
+	c = connectMQTT(t, i, "device", "")
+	assert.Equal(t, []byte{0, mqttBadUsernameOrPassword}, c.expect(mqttConnack).body)
+}
+
+func TestMQTTIngestUnauthenticatedLimits(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	i.UpdateFromConfig(&cfgbroker.Config{Ingest: &cfgbroker.Ingest{
+		User:     "device",
+		Password: "secret",
+	}})
+
+	// CONNECT packets are limited regardless of the maximum event size,
+	// and are refused before being read.
+	server, client := net.Pipe()
+	defer client.Close()
+	go i.serveMQTT(context.Background(), server)
+	require.NoError(t, client.SetWriteDeadline(time.Now().Add(time.Second)))
+	assert.Error(t, writeMQTTPacket(client, mqttConnect<<4, make([]byte, mqttMaxConnectPacketSize+1)))
+	_, err := readMQTTPacket(bufio.NewReader(client), mqttDefaultMaxPacketSize)
+	assert.ErrorIs(t, err, io.EOF, "Connection must be closed")
+
+	// Clients that connect make room for others.
+	c := connectMQTT(t, i, "device", "secret")
+	assert.Equal(t, []byte{0, mqttAccepted}, c.expect(mqttConnack).body)
+	assert.Eventually(t, func() bool { return len(i.mqttConnecting) == 0 }, time.Second, 10*time.Millisecond)
+
+	for n := 0; n < mqttMaxConnecting; n++ {
+		i.mqttConnecting <- struct{}{}
+	}
+	server, client = net.Pipe()
+	defer client.Close()
+	go i.serveMQTT(context.Background(), server)
+	_, err = readMQTTPacket(bufio.NewReader(client), mqttDefaultMaxPacketSize)
+	assert.ErrorIs(t, err, io.EOF, "Connections beyond the limit must be closed")
+}
+
+type mqttTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func connectMQTT(t *testing.T, i *Instance, user, password string) *mqttTestClient {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go i.serveMQTT(context.Background(), server)
+
+	c := &mqttTestClient{t: t, conn: client, r: bufio.NewReader(client)}
+
+	body := append(mqttString("MQTT"), mqttProtocolLevel, 0x80|0x40|0x02, 0, 60)
+	body = append(body, mqttString("client")...)
+	body = append(body, mqttString(user)...)
+	body = append(body, mqttString(password)...)
+	c.send(mqttConnect<<4, body)
+
+	return c
+}
+
+func (c *mqttTestClient) send(header byte, parts ...[]byte) {
+	var body []byte
+	for _, p := range parts {
+		body = append(body, p...)
+	}
+	require.NoError(c.t, c.conn.SetWriteDeadline(time.Now().Add(time.Second)))
+	require.NoError(c.t, writeMQTTPacket(c.conn, header, body))
+}
+
+func (c *mqttTestClient) publish(qos byte, id uint16, topic, payload string) {
+	body := mqttString(topic)
+	if qos > 0 {
+		body = append(body, mqttPacketID(id)...)
+	}
+	c.send(mqttPublish<<4|qos<<1, body, []byte(payload))
+}
+
+func (c *mqttTestClient) expect(kind byte) *mqttPacket {
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(time.Second)))
+	p, err := readMQTTPacket(c.r, mqttDefaultMaxPacketSize)
+	require.NoError(c.t, err)
+	require.Equal(c.t, kind, p.kind)
+	return p
+}
+
+func (c *mqttTestClient) expectClosed() {
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err := readMQTTPacket(c.r, mqttDefaultMaxPacketSize)
+	require.Error(c.t, err)
+	var nerr net.Error
+	assert.False(c.t, errors.As(err, &nerr) && nerr.Timeout(), "Connection must be closed")
+}
+
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
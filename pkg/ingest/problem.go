@@ -0,0 +1,176 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// Media type of the bodies of ingest error responses, as defined by RFC 7807.
+const problemContentType = "application/problem+json"
+
+// Codes that identify the problem of ingest error responses, which
+// producers can rely on to react to failures.
+const (
+	// ProblemInvalidCloudEvent is the code of requests that are not a valid
+	// CloudEvent, or whose event was rejected due to its contents.
+	ProblemInvalidCloudEvent = "invalid_cloudevent"
+	// ProblemEventTooLarge is the code of events that exceed the maximum
+	// size of the broker or the backend.
+	ProblemEventTooLarge = "event_too_large"
+	// ProblemBackendUnavailable is the code of events that could not be
+	// produced to the backend, which might succeed when retried.
+	ProblemBackendUnavailable = "backend_unavailable"
+	// ProblemRateLimited is the code of events rejected due to the ingest
+	// rate limit or the number of events being ingested.
+	ProblemRateLimited = "rate_limited"
+	// ProblemBrokerNotFound is the code of events ingested for a hosted
+	// broker that does not exist.
+	ProblemBrokerNotFound = "broker_not_found"
+	// ProblemUnauthorized is the code of requests that are not authorized
+	// to ingest events.
+	ProblemUnauthorized = "unauthorized"
+	// ProblemInternalError is the code of any other error.
+	ProblemInternalError = "internal_error"
+)
+
+// problem is the body of ingest error responses. Members other than the
+// standard ones are extensions.
+type problem map[string]interface{}
+
+type problemKey struct{}
+
+// problemDetails are informed by the CloudEvents handler, which does not
+// have access to the response.
+type problemDetails struct {
+	code       string
+	extensions map[string]interface{}
+}
+
+// setProblem informs the code of the problem of the response, along with
+// extension members added to its body.
+func setProblem(ctx context.Context, code string, extensions map[string]interface{}) {
+	if pd, ok := ctx.Value(problemKey{}).(*problemDetails); ok {
+		pd.code = code
+		pd.extensions = extensions
+	}
+}
+
+// problemCode returns the code of the problem for responses whose code was
+// not informed.
+func problemCode(status int) string {
+	switch {
+	case status == http.StatusRequestEntityTooLarge:
+		return ProblemEventTooLarge
+	case status == http.StatusTooManyRequests:
+		return ProblemRateLimited
+	case status == http.StatusNotFound:
+		return ProblemBrokerNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ProblemUnauthorized
+	case status == http.StatusServiceUnavailable:
+		return ProblemBackendUnavailable
+	case status < http.StatusInternalServerError:
+		return ProblemInvalidCloudEvent
+	}
+	return ProblemInternalError
+}
+
+// problemMiddleware replaces the plain text bodies of ingest error responses
+// with problem details. Responses that inform a structured body, like those
+// of batches and synchronous ingest, are not modified.
+func problemMiddleware() cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			pd := &problemDetails{}
+			pw := &problemResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(pw, r.WithContext(context.WithValue(r.Context(), problemKey{}, pd)))
+
+			if pw.status != 0 {
+				pw.writeProblem(pd)
+			}
+		})
+	}
+}
+
+// problemResponseWriter buffers the body of error responses that inform
+// plain text, which are written as problem details once the handler
+// returns.
+type problemResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+
+	// status of the buffered error response, zero when the response is
+	// written as is.
+	status int
+	body   bytes.Buffer
+}
+
+func (w *problemResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	ct := w.Header().Get("Content-Type")
+	if statusCode >= http.StatusBadRequest && (ct == "" || strings.HasPrefix(ct, "text/plain")) {
+		w.status = statusCode
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *problemResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *problemResponseWriter) writeProblem(pd *problemDetails) {
+	p := make(problem, len(pd.extensions)+5)
+	for k, v := range pd.extensions {
+		p[k] = v
+	}
+
+	code := pd.code
+	if code == "" {
+		code = problemCode(w.status)
+	}
+
+	p["type"] = "about:blank"
+	p["title"] = http.StatusText(w.status)
+	p["status"] = w.status
+	p["code"] = code
+	if detail := strings.TrimSpace(w.body.String()); detail != "" {
+		p["detail"] = detail
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		// Extensions are informed by the broker, hence this is not expected.
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemMiddleware(t *testing.T) {
+	tcs := map[string]struct {
+		method  string
+		handler http.HandlerFunc
+		status  int
+		ct      string
+		body    string
+	}{
+		"plain text error": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "ingest rate limit exceeded", http.StatusTooManyRequests)
+			},
+			status: http.StatusTooManyRequests,
+			ct:     problemContentType,
+			body: `{"type":"about:blank","title":"Too Many Requests","status":429,
+				"code":"rate_limited","detail":"ingest rate limit exceeded"}`,
+		},
+		"code informed by the handler": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				setProblem(r.Context(), ProblemBackendUnavailable, map[string]interface{}{"backend": "memory"})
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			status: http.StatusInternalServerError,
+			ct:     problemContentType,
+			body: `{"type":"about:blank","title":"Internal Server Error","status":500,
+				"code":"backend_unavailable","backend":"memory"}`,
+		},
+		"event too large": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				_, _ = w.Write([]byte("event is larger than 10 bytes"))
+			},
+			status: http.StatusRequestEntityTooLarge,
+			ct:     problemContentType,
+			body: `{"type":"about:blank","title":"Request Entity Too Large","status":413,
+				"code":"event_too_large","detail":"event is larger than 10 bytes"}`,
+		},
+		"structured error": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"accepted":0}`))
+			},
+			status: http.StatusBadRequest,
+			ct:     "application/json",
+			body:   `{"accepted":0}`,
+		},
+		"accepted": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			},
+			status: http.StatusAccepted,
+		},
+		"probe": {
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			status: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			rr := httptest.NewRecorder()
+			problemMiddleware()(tc.handler).ServeHTTP(rr, httptest.NewRequest(method, "/", nil))
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.ct, rr.Header().Get("Content-Type"))
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, rr.Body.String())
+			} else {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestProblemMiddlewareKeepsHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+	problemMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRetryAfter, "2")
+		http.Error(w, "too many events being ingested", http.StatusTooManyRequests)
+	})).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.Equal(t, "2", rr.Header().Get(headerRetryAfter))
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"golang.org/x/time/rate"
+)
+
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRetryAfter         = "Retry-After"
+)
+
+// rateLimitMiddleware limits the rate of ingested events, informing
+// producers about the remaining quota at every response so that they
+// can throttle proactively.
+//
+// Batches are not limited as a whole, each of their events being charged
+// to the limiter when ingested.
+func rateLimitMiddleware(limiter *rate.Limiter) cehttp.Middleware {
+	limit := strconv.FormatFloat(float64(limiter.Limit()), 'f', -1, 64)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Only event ingestion is limited, probes and other
+			// requests are always served.
+			if r.Method != http.MethodPost || isBatchRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+			res := limiter.ReserveN(now, 1)
+			delay := res.DelayFrom(now)
+
+			w.Header().Set(headerRateLimitLimit, limit)
+
+			if !res.OK() || delay > 0 {
+				res.CancelAt(now)
+				w.Header().Set(headerRateLimitRemaining, "0")
+				w.Header().Set(headerRetryAfter, retryAfterHeader(delay))
+				http.Error(w, "ingest rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			w.Header().Set(headerRateLimitRemaining, strconv.Itoa(rateLimitRemaining(limiter, now)))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitRemaining returns the number of events that can be ingested
+// right away.
+func rateLimitRemaining(limiter *rate.Limiter, now time.Time) int {
+	remaining := int(math.Floor(limiter.TokensAt(now)))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining
+}
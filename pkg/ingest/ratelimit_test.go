@@ -0,0 +1,44 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	h := rateLimitMiddleware(rate.NewLimiter(0.5, 2))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	post := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+		return rr
+	}
+
+	rr := post()
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "0.5", rr.Header().Get(headerRateLimitLimit))
+	assert.Equal(t, "1", rr.Header().Get(headerRateLimitRemaining))
+
+	rr = post()
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "0", rr.Header().Get(headerRateLimitRemaining))
+
+	rr = post()
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "0", rr.Header().Get(headerRateLimitRemaining))
+	assert.Equal(t, "2", rr.Header().Get(headerRetryAfter))
+
+	// Probes are not limited.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+}
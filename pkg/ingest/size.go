@@ -0,0 +1,117 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+)
+
+// InfoPath is the path where ingest informs its limits to producers.
+const InfoPath = "/info"
+
+const (
+	// Batches can be as large as this number of events of the maximum
+	// size.
+	maxBatchEvents = 100
+	// Maximum size of batches when the size of events is not limited.
+	defaultMaxBatchSize = 32 << 20
+)
+
+// InstanceWithMaxEventSize rejects events whose request body is larger than
+// the informed size in bytes with a 413 status code. Zero means no limit.
+func InstanceWithMaxEventSize(size int) InstanceOption {
+	return func(i *Instance) {
+		i.maxEventSize = size
+	}
+}
+
+// eventSizeMiddleware rejects requests whose body exceeds the maximum event
+// size, which for binary content mode is the event data and for structured
+// content mode the whole event. Batches are limited by the batch middleware
+// instead.
+func eventSizeMiddleware(maxSize int) cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost ||
+				strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsBatchJSON) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > int64(maxSize) {
+				http.Error(w, eventTooLargeMessage(maxSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			// Requests that do not inform their length are read up to
+			// the limit.
+			if r.ContentLength < 0 {
+				b, err := io.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+				if err != nil {
+					http.Error(w, "could not read CloudEvent: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				if len(b) > maxSize {
+					http.Error(w, eventTooLargeMessage(maxSize), http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(b))
+				r.ContentLength = int64(len(b))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func eventTooLargeMessage(maxSize int) string {
+	return fmt.Sprintf("event exceeds the maximum size of %d bytes", maxSize)
+}
+
+// maxBatchSize returns the maximum size in bytes of batch request bodies.
+func (i *Instance) maxBatchSize() int64 {
+	if i.maxEventSize == 0 {
+		return defaultMaxBatchSize
+	}
+	return int64(i.maxEventSize) * maxBatchEvents
+}
+
+// info informs producers about the limits of ingest.
+type info struct {
+	MaxEventSize int     `json:"maxEventSize,omitempty"`
+	MaxBatchSize int64   `json:"maxBatchSize"`
+	MaxInFlight  int     `json:"maxInFlight,omitempty"`
+	RateLimit    float64 `json:"rateLimit,omitempty"`
+	RateBurst    int     `json:"rateBurst,omitempty"`
+}
+
+func (i *Instance) serveInfo(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(i.info()); err != nil {
+		i.logger.Errorw("Could not write ingest info response", zap.Error(err))
+	}
+}
+
+// info returns the limits of ingest.
+func (i *Instance) info() info {
+	inf := info{
+		MaxEventSize: i.maxEventSize,
+		MaxBatchSize: i.maxBatchSize(),
+		MaxInFlight:  i.maxInFlight,
+	}
+	if i.limiter != nil {
+		inf.RateLimit = float64(i.limiter.Limit())
+		inf.RateBurst = i.limiter.Burst()
+	}
+	return inf
+}
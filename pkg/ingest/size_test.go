@@ -0,0 +1,110 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestEventSizeMiddleware(t *testing.T) {
+	var received string
+	h := eventSizeMiddleware(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+	}))
+
+	ingest := func(body string, chunked bool) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if chunked {
+			r.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, ingest("0123456789", false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ingest("0123456789a", false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ingest("0123456789a", true))
+
+	received = ""
+	assert.Equal(t, http.StatusOK, ingest("012345", true))
+	assert.Equal(t, "012345", received, "Bodies read to check their size must be passed on")
+}
+
+func TestBatchMaxEventSize(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithMaxEventSize(100))
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error { return nil })
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	br := i.ingestBatchItem(r, json.RawMessage(`{"specversion":"1.0","id":"1","type":"t","source":"s"}`))
+	assert.Equal(t, http.StatusOK, br.Status)
+
+	br = i.ingestBatchItem(r, json.RawMessage(fmt.Sprintf(`{"specversion":"1.0","id":"1","type":"t","source":"s","data":%q}`, strings.Repeat("x", 100))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, br.Status)
+}
+
+func TestBatchMaxSize(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithMaxEventSize(10))
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error { return nil })
+	h := i.batchMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	ingest := func(body string, chunked bool) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
+		if chunked {
+			r.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Batches are limited to the size of 100 events of the maximum size.
+	tooLarge := `[` + strings.Repeat(" ", 1000) + `]`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ingest(tooLarge, false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ingest(tooLarge, true))
+	assert.Equal(t, http.StatusOK, ingest(`[]`, true))
+
+	assert.Equal(t, int64(defaultMaxBatchSize), NewInstance(nil, zap.NewNop().Sugar()).maxBatchSize())
+}
+
+func TestBackendEventTooLarge(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar())
+	i.RegisterCloudEventHandler(func(context.Context, *cloudevents.Event) error {
+		return fmt.Errorf("serialized CloudEvent exceeds the maximum entry size: %w", backend.ErrEventTooLarge)
+	})
+
+	_, res := i.cloudEventsHandler(context.Background(), lib.NewCloudEvent())
+	var httpResult *cehttp.Result
+	require.True(t, errors.As(res, &httpResult))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpResult.StatusCode)
+}
+
+func TestInfo(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(),
+		InstanceWithMaxEventSize(1024), InstanceWithRateLimit(50, 0))
+
+	rec := httptest.NewRecorder()
+	i.serveInfo(rec)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"maxEventSize":1024,"maxBatchSize":102400,"rateLimit":50,"rateBurst":50}`, rec.Body.String())
+}
@@ -0,0 +1,210 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+)
+
+// SyncMode sets when ingest responds to producers in synchronous mode.
+type SyncMode string
+
+const (
+	// SyncModePersisted responds once the event was persisted at the
+	// backend.
+	SyncModePersisted SyncMode = "persisted"
+	// SyncModeDispatched responds once the event was also dispatched to
+	// all triggers, or the synchronous timeout expired.
+	SyncModeDispatched SyncMode = "dispatched"
+)
+
+// Dispatch status of each trigger informed in synchronous mode.
+const (
+	syncStatusDispatched = "dispatched"
+	syncStatusFailed     = "failed"
+	syncStatusPending    = "pending"
+)
+
+// DispatchTracker starts tracking the dispatch of an event to the triggers
+// of a broker before it is produced. The returned function waits until all
+// triggers dispatched the event or the context is done, informing the
+// outcome for each trigger, nil if dispatched, and the triggers that did
+// not dispatch it yet.
+type DispatchTracker func(*cloudevents.Event) func(context.Context) (map[string]error, []string)
+
+// SyncTarget describes the broker events are ingested for to producers
+// using the synchronous mode.
+type SyncTarget struct {
+	// Durable informs whether the backend persists events to survive
+	// restarts.
+	Durable bool
+	// Track the dispatch of events, nil if not supported.
+	Track DispatchTracker
+}
+
+// syncResponse informs the outcome of an event ingested in synchronous
+// mode.
+type syncResponse struct {
+	ID        string              `json:"id,omitempty"`
+	Source    string              `json:"source,omitempty"`
+	Persisted bool                `json:"persisted"`
+	Durable   bool                `json:"durable"`
+	Error     string              `json:"error,omitempty"`
+	Triggers  []syncTriggerResult `json:"triggers,omitempty"`
+}
+
+// syncTriggerResult is the dispatch status of the event for a trigger.
+type syncTriggerResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// InstanceWithSyncMode responds to producers once events are persisted, or
+// dispatched to all triggers up to the timeout, informing the outcome at
+// the response body.
+func InstanceWithSyncMode(mode SyncMode, timeout time.Duration) InstanceOption {
+	return func(i *Instance) {
+		i.syncMode = mode
+		i.syncTimeout = timeout
+	}
+}
+
+// RegisterSyncTarget describes the broker to producers using the
+// synchronous mode, empty name being the default broker.
+func (i *Instance) RegisterSyncTarget(broker string, t SyncTarget) {
+	i.hm.Lock()
+	defer i.hm.Unlock()
+	i.syncTargets[broker] = t
+}
+
+func (i *Instance) syncTarget(broker string) SyncTarget {
+	i.hm.RLock()
+	defer i.hm.RUnlock()
+	return i.syncTargets[broker]
+}
+
+// syncMiddleware ingests events going through the same steps the
+// CloudEvents handler does, but responds with a JSON body that informs
+// whether the event was persisted, and when dispatching it to all triggers
+// is waited for, the dispatch status for each of them. Batches are not
+// handled, since their response already informs each event outcome.
+func (i *Instance) syncMiddleware() cehttp.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost ||
+				strings.HasPrefix(r.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsBatchJSON) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			msg := cehttp.NewMessageFromHttpRequest(r)
+			defer msg.Finish(nil)
+
+			event, err := binding.ToEvent(ctx, msg)
+			if err == nil {
+				err = event.Validate()
+			}
+			if err != nil {
+				i.reportNonValidEvent()
+				i.writeSyncResponse(w, http.StatusBadRequest, &syncResponse{Error: strings.TrimSpace(err.Error())})
+				return
+			}
+
+			_, broker, _ := i.handlerFor(ctx)
+			target := i.syncTarget(broker)
+			res := &syncResponse{ID: event.ID(), Source: event.Source(), Durable: target.Durable}
+
+			var wait func(context.Context) (map[string]error, []string)
+			if i.syncMode == SyncModeDispatched && target.Track != nil {
+				wait = target.Track(event)
+			}
+
+			start := time.Now()
+			_, pres := i.cloudEventsHandler(ctx, *event)
+			if i.reporter != nil {
+				i.reporter.ReportProcessedEvent(protocol.IsACK(pres), event.Type(), float64(time.Since(start)/time.Millisecond))
+			}
+
+			status := resultStatus(pres)
+			if status >= http.StatusMultipleChoices {
+				if wait != nil {
+					// Stop tracking the event that will not be dispatched.
+					cctx, cancel := context.WithCancel(ctx)
+					cancel()
+					wait(cctx)
+				}
+				res.Error = pres.Error()
+				i.writeSyncResponse(w, status, res)
+				return
+			}
+			res.Persisted = true
+
+			if wait != nil {
+				wctx, cancel := context.WithTimeout(ctx, i.syncTimeout)
+				results, pending := wait(wctx)
+				cancel()
+				res.Triggers = syncTriggerResults(results, pending)
+			}
+
+			i.writeSyncResponse(w, status, res)
+		})
+	}
+}
+
+func (i *Instance) writeSyncResponse(w http.ResponseWriter, status int, res *syncResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		i.logger.Errorw("Could not write synchronous ingest response", zap.Error(err))
+	}
+}
+
+// syncTriggerResults returns the dispatch status of each trigger sorted
+// by name.
+func syncTriggerResults(results map[string]error, pending []string) []syncTriggerResult {
+	trs := make([]syncTriggerResult, 0, len(results)+len(pending))
+	for name, err := range results {
+		tr := syncTriggerResult{Name: name, Status: syncStatusDispatched}
+		if err != nil {
+			tr.Status = syncStatusFailed
+			tr.Error = err.Error()
+		}
+		trs = append(trs, tr)
+	}
+	for _, name := range pending {
+		trs = append(trs, syncTriggerResult{Name: name, Status: syncStatusPending})
+	}
+
+	sort.Slice(trs, func(a, b int) bool { return trs[a].Name < trs[b].Name })
+	return trs
+}
+
+// resultStatus returns the HTTP status code for the outcome of the
+// CloudEvents handler.
+func resultStatus(res protocol.Result) int {
+	var hres *cehttp.Result
+	switch {
+	case errors.As(res, &hres):
+		if hres.StatusCode > 100 && hres.StatusCode < 600 {
+			return hres.StatusCode
+		}
+	case !protocol.IsACK(res):
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
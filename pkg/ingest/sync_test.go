@@ -0,0 +1,94 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+func TestSyncIngest(t *testing.T) {
+	i := NewInstance(nil, zap.NewNop().Sugar(), InstanceWithSyncMode(SyncModeDispatched, 10*time.Millisecond))
+	i.RegisterCloudEventHandler(func(_ context.Context, e *cloudevents.Event) error {
+		if e.Type() == "busy" {
+			return fmt.Errorf("buffer is full: %w", backend.ErrBackendBusy)
+		}
+		return nil
+	})
+
+	tracked := 0
+	i.RegisterSyncTarget("", SyncTarget{
+		Durable: true,
+		Track: func(*cloudevents.Event) func(context.Context) (map[string]error, []string) {
+			tracked++
+			return func(ctx context.Context) (map[string]error, []string) {
+				<-ctx.Done()
+				return map[string]error{"t1": nil, "t3": errors.New("rejected")}, []string{"t2"}
+			}
+		},
+	})
+
+	ingest := func(typ string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"ok":true}`))
+		r.Header.Set("Ce-Specversion", "1.0")
+		r.Header.Set("Ce-Id", "1")
+		r.Header.Set("Ce-Source", "test")
+		r.Header.Set("Ce-Type", typ)
+		r.Header.Set("Content-Type", "application/json")
+		r = r.WithContext(cehttp.WithRequestDataAtContext(r.Context(), r))
+
+		rec := httptest.NewRecorder()
+		i.syncMiddleware()(http.NotFoundHandler()).ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := ingest("test.type")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"id": "1",
+		"source": "test",
+		"persisted": true,
+		"durable": true,
+		"triggers": [
+			{"name": "t1", "status": "dispatched"},
+			{"name": "t2", "status": "pending"},
+			{"name": "t3", "status": "failed", "error": "rejected"}
+		]
+	}`, rec.Body.String())
+
+	rec = ingest("busy")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.JSONEq(t, `{
+		"id": "1",
+		"source": "test",
+		"persisted": false,
+		"durable": true,
+		"error": "429: backend is busy"
+	}`, rec.Body.String())
+
+	rec = ingest("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 2, tracked, "Non valid events must not be tracked")
+
+	// Batches are handled by the next handler.
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[]`))
+	r.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
+	rec = httptest.NewRecorder()
+	i.syncMiddleware()(http.NotFoundHandler()).ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
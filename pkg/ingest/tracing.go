@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+var traceFormat = &tracecontext.HTTPFormat{}
+
+type traceSamplingRule struct {
+	eventType string
+	prefix    bool
+	sampler   trace.Sampler
+}
+
+// traceSampler chooses the trace sampler for each ingested request.
+type traceSampler struct {
+	rules       []traceSamplingRule
+	parentBased bool
+	// Use the default sampler if nil.
+	sampler trace.Sampler
+}
+
+func newTraceSampler(cfg *cfgbroker.TraceSampling) *traceSampler {
+	ts := &traceSampler{}
+	if cfg == nil {
+		return ts
+	}
+
+	if cfg.Ratio != nil {
+		ts.sampler = trace.ProbabilitySampler(*cfg.Ratio)
+	}
+
+	ts.parentBased = cfg.ParentBased != nil && *cfg.ParentBased
+
+	for _, r := range cfg.Rules {
+		rule := traceSamplingRule{
+			eventType: r.Type,
+			sampler:   trace.ProbabilitySampler(r.Ratio),
+		}
+		if strings.HasSuffix(r.Type, "*") {
+			rule.eventType = strings.TrimSuffix(r.Type, "*")
+			rule.prefix = true
+		}
+		ts.rules = append(ts.rules, rule)
+	}
+
+	return ts
+}
+
+// startOptions returns the span options for the request. The event type is
+// only known before reading the body for binary mode requests, structured
+// requests are not matched against rules.
+func (ts *traceSampler) startOptions(r *http.Request) trace.StartOptions {
+	if t := r.Header.Get("Ce-Type"); t != "" {
+		for _, rule := range ts.rules {
+			if t == rule.eventType || (rule.prefix && strings.HasPrefix(t, rule.eventType)) {
+				return trace.StartOptions{Sampler: rule.sampler}
+			}
+		}
+	}
+
+	if ts.parentBased {
+		if sc, ok := traceFormat.SpanContextFromRequest(r); ok {
+			if sc.IsSampled() {
+				return trace.StartOptions{Sampler: trace.AlwaysSample()}
+			}
+			return trace.StartOptions{Sampler: trace.NeverSample()}
+		}
+	}
+
+	return trace.StartOptions{Sampler: ts.sampler}
+}
+
+// tracingMiddleware propagates trace context from ingested requests, using
+// the sampler that is currently configured. When honoring debug, requests
+// flagged for debugging are always traced.
+func tracingMiddleware(sampler *atomic.Value, honorDebug bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return &ochttp.Handler{
+			Propagation: traceFormat,
+			Handler:     next,
+			FormatSpanName: func(r *http.Request) string {
+				return "cloudevents.http." + r.URL.Path
+			},
+			GetStartOptions: func(r *http.Request) trace.StartOptions {
+				if honorDebug && debug.RequestEnabled(r) {
+					return trace.StartOptions{Sampler: trace.AlwaysSample()}
+				}
+				return sampler.Load().(*traceSampler).startOptions(r)
+			},
+		}
+	}
+}
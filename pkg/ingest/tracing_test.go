@@ -0,0 +1,91 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/trace"
+
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestTraceSampler(t *testing.T) {
+	ratio := 0.0
+	parentBased := true
+	ts := newTraceSampler(&cfgbroker.TraceSampling{
+		Ratio:       &ratio,
+		ParentBased: &parentBased,
+		Rules: []cfgbroker.TraceSamplingRule{
+			{Type: "critical.*", Ratio: 1},
+		},
+	})
+
+	sampled := func(eventType, traceparent string) bool {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if eventType != "" {
+			r.Header.Set("Ce-Type", eventType)
+		}
+		if traceparent != "" {
+			r.Header.Set("traceparent", traceparent)
+		}
+		return ts.startOptions(r).Sampler(trace.SamplingParameters{}).Sample
+	}
+
+	notSampledParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	sampledParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	assert.True(t, sampled("critical.payment", notSampledParent), "rules take precedence over parent")
+	assert.True(t, sampled("example.type", sampledParent), "parent decision is honored")
+	assert.False(t, sampled("example.type", notSampledParent), "parent decision is honored")
+	assert.False(t, sampled("example.type", ""), "ratio applies without parent")
+
+	assert.Nil(t, newTraceSampler(nil).startOptions(httptest.NewRequest(http.MethodPost, "/", nil)).Sampler)
+}
+
+func TestTracingMiddlewareDebug(t *testing.T) {
+	ratio := 0.0
+	sampler := &atomic.Value{}
+	sampler.Store(newTraceSampler(&cfgbroker.TraceSampling{Ratio: &ratio}))
+
+	tcs := map[string]struct {
+		honorDebug bool
+		header     string
+		sampled    bool
+	}{
+		"debug request": {
+			honorDebug: true,
+			header:     "true",
+			sampled:    true,
+		},
+		"debug not honored": {
+			header: "true",
+		},
+		"not a debug request": {
+			honorDebug: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var sampled bool
+			h := tracingMiddleware(sampler, tc.honorDebug)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sampled = trace.FromContext(r.Context()).SpanContext().IsSampled()
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tc.header != "" {
+				r.Header.Set(debug.Header, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tc.sampled, sampled)
+		})
+	}
+}
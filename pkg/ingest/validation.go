@@ -0,0 +1,28 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+
+	"github.com/triggermesh/brokers/pkg/schema"
+)
+
+// Timeout for retrieving schemas from registries.
+const schemaRegistryTimeout = 10 * time.Second
+
+// schemaRejection returns the result for events that do not conform to the
+// schema of their type, whose problem details inform the validation errors.
+func schemaRejection(ctx context.Context, verr *schema.ValidationError) protocol.Result {
+	setProblem(ctx, ProblemInvalidCloudEvent, map[string]interface{}{
+		"eventType": verr.Type,
+		"errors":    verr.Errors,
+	})
+	return cehttp.NewResult(http.StatusBadRequest, "%s", verr.Error())
+}
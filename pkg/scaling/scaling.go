@@ -0,0 +1,214 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package scaling exposes the backlog of the broker and its triggers, which
+// autoscalers like KEDA or an HPA external metrics adapter use to scale
+// broker replicas.
+package scaling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+
+	knmetrics "knative.dev/pkg/metrics"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const LabelTrigger = "trigger_name"
+
+var (
+	triggerKey = tag.MustNewKey(LabelTrigger)
+
+	// brokerBacklogM is a gauge with the number of events not yet
+	// dispatched by all triggers.
+	brokerBacklogM = stats.Int64(
+		"broker/backlog",
+		"Number of events pending to be dispatched by the slowest Trigger.",
+		stats.UnitDimensionless,
+	)
+
+	// triggerBacklogM is a gauge with the number of events not yet
+	// dispatched by each trigger.
+	triggerBacklogM = stats.Int64(
+		"trigger/backlog",
+		"Number of events pending to be dispatched by the Trigger.",
+		stats.UnitDimensionless,
+	)
+
+	once sync.Once
+)
+
+func registerStatViews() error {
+	return knmetrics.RegisterResourceView(
+		&view.View{
+			Name:        brokerBacklogM.Name(),
+			Description: brokerBacklogM.Description(),
+			Measure:     brokerBacklogM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Name:        triggerBacklogM.Name(),
+			Description: triggerBacklogM.Description(),
+			Measure:     triggerBacklogM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+	)
+}
+
+// Backlog contains the number of events pending to be dispatched.
+type Backlog struct {
+	Time time.Time `json:"time"`
+	// Backlog of the broker, which is the backlog of the trigger that is
+	// furthest behind.
+	Backlog  int64            `json:"backlog"`
+	Triggers map[string]int64 `json:"triggers,omitempty"`
+}
+
+// Monitor periodically reads the backlog of the triggers from the backend,
+// keeping the last values read for autoscalers.
+type Monitor struct {
+	reporter backend.BacklogReporter
+	triggers func() []string
+	period   time.Duration
+
+	last *Backlog
+	m    sync.RWMutex
+
+	logger *zap.SugaredLogger
+}
+
+// NewMonitor creates a monitor that reads the backlog of the triggers
+// returned by the function every period.
+func NewMonitor(reporter backend.BacklogReporter, triggers func() []string, period time.Duration, logger *zap.SugaredLogger) (*Monitor, error) {
+	var err error
+	once.Do(func() {
+		if err = registerStatViews(); err != nil {
+			err = fmt.Errorf("error registering OpenCensus stats view: %w", err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		reporter: reporter,
+		triggers: triggers,
+		period:   period,
+		last:     &Backlog{Triggers: map[string]int64{}},
+		logger:   logger,
+	}, nil
+}
+
+// Start reads the backlog every period until the context is done.
+func (m *Monitor) Start(ctx context.Context) error {
+	t := time.NewTicker(m.period)
+	defer t.Stop()
+
+	for {
+		if err := m.poll(ctx); err != nil {
+			m.logger.Errorw("Could not read the backlog from the backend", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (m *Monitor) poll(ctx context.Context) error {
+	triggers, err := m.reporter.Backlog(ctx, m.triggers())
+	if err != nil {
+		return err
+	}
+
+	b := &Backlog{
+		Time:     time.Now().UTC(),
+		Triggers: triggers,
+	}
+	for _, n := range triggers {
+		if n > b.Backlog {
+			b.Backlog = n
+		}
+	}
+
+	m.m.Lock()
+	previous := m.last
+	m.last = b
+	m.m.Unlock()
+
+	knmetrics.Record(ctx, brokerBacklogM.M(b.Backlog))
+	for name, n := range triggers {
+		m.recordTrigger(ctx, name, n)
+	}
+	// Gauges of triggers that no longer exist are reset.
+	for name := range previous.Triggers {
+		if _, ok := triggers[name]; !ok {
+			m.recordTrigger(ctx, name, 0)
+		}
+	}
+
+	return nil
+}
+
+func (m *Monitor) recordTrigger(ctx context.Context, name string, n int64) {
+	tctx, err := tag.New(ctx, tag.Insert(triggerKey, name))
+	if err != nil {
+		m.logger.Errorw("error setting tags to OpenCensus context", zap.Error(err))
+		return
+	}
+	knmetrics.Record(tctx, triggerBacklogM.M(n))
+}
+
+// Current returns the last backlog read.
+func (m *Monitor) Current() *Backlog {
+	m.m.RLock()
+	defer m.m.RUnlock()
+	return m.last
+}
+
+// Handler serves the last backlog read. The trigger query parameter
+// restricts the response to the backlog of a single trigger, informed at
+// the backlog field, which suits the KEDA metrics API scaler.
+func Handler(m *Monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		b := m.Current()
+		if trigger := req.URL.Query().Get("trigger"); trigger != "" {
+			n, ok := b.Triggers[trigger]
+			if !ok {
+				writeError(w, http.StatusNotFound, "trigger "+trigger+" not found")
+				return
+			}
+			b = &Backlog{Time: b.Time, Backlog: n}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(b); err != nil {
+			m.logger.Errorw("Could not write backlog", zap.Error(err))
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
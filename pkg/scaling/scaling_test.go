@@ -0,0 +1,64 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package scaling
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeBacklog map[string]int64
+
+func (f fakeBacklog) Backlog(_ context.Context, names []string) (map[string]int64, error) {
+	b := make(map[string]int64)
+	for _, name := range names {
+		if n, ok := f[name]; ok {
+			b[name] = n
+		}
+	}
+	return b, nil
+}
+
+func TestMonitor(t *testing.T) {
+	backlog := fakeBacklog{"t1": 3, "t2": 12}
+	triggers := []string{"t1", "t2", "unsubscribed"}
+	m, err := NewMonitor(backlog, func() []string { return triggers }, time.Minute, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	require.NoError(t, m.poll(context.Background()))
+	b := m.Current()
+	assert.Equal(t, int64(12), b.Backlog, "The broker backlog must be the largest trigger backlog")
+	assert.Equal(t, map[string]int64{"t1": 3, "t2": 12}, b.Triggers)
+
+	rec := httptest.NewRecorder()
+	Handler(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/scaling?trigger=t1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tb Backlog
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tb))
+	assert.Equal(t, int64(3), tb.Backlog)
+	assert.Empty(t, tb.Triggers)
+
+	rec = httptest.NewRecorder()
+	Handler(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/scaling?trigger=unsubscribed", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Removed triggers are not reported anymore.
+	triggers = []string{"t1"}
+	require.NoError(t, m.poll(context.Background()))
+	b = m.Current()
+	assert.Equal(t, int64(3), b.Backlog)
+	assert.Equal(t, map[string]int64{"t1": 3}, b.Triggers)
+
+	rec = httptest.NewRecorder()
+	Handler(m).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/scaling", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
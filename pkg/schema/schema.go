@@ -0,0 +1,263 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package schema validates the data of events against the JSON Schema of
+// their type.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/golang/groupcache/lru"
+	"github.com/rickb777/date/period"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Default time schemas are cached.
+	defaultCacheTTL = 5 * time.Minute
+	// Time failures to retrieve a schema are cached, so that an unavailable
+	// registry is not queried for each event.
+	failureCacheTTL = 10 * time.Second
+	// Maximum number of event types cached, the least recently used being
+	// evicted.
+	maxCachedSchemas = 1000
+
+	// Maximum size of the schemas returned by registries.
+	maxSchemaSize = 1 << 20
+)
+
+// ErrNotFound is returned by registries that do not have a schema for the
+// event type.
+var ErrNotFound = errors.New("schema not found")
+
+// Registry returns the JSON Schema of event types.
+type Registry interface {
+	Schema(ctx context.Context, eventType string) ([]byte, error)
+}
+
+// ValidationError is returned for events that do not conform to the schema
+// of their type.
+type ValidationError struct {
+	Type   string   `json:"type"`
+	Errors []string `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("event data does not conform to the schema of type %q: %s", e.Type, strings.Join(e.Errors, "; "))
+}
+
+type cachedSchema struct {
+	// schema is nil when the type has no schema.
+	schema *spec.Schema
+	// err is the failure to retrieve the schema.
+	err     error
+	expires time.Time
+}
+
+// Validator validates events using the schemas of the registry, which are
+// cached for a TTL. Concurrent events of a type not cached yet share the
+// request to the registry.
+type Validator struct {
+	registry      Registry
+	requireSchema bool
+	ttl           time.Duration
+
+	cache    *lru.Cache
+	requests singleflight.Group
+	now      func() time.Time
+	m        sync.Mutex
+}
+
+// New creates the validator for the configuration.
+func New(cfg *cfgbroker.SchemaValidation, client *http.Client) (*Validator, error) {
+	v := &Validator{
+		requireSchema: cfg.RequireSchema,
+		ttl:           defaultCacheTTL,
+		cache:         lru.New(maxCachedSchemas),
+		now:           time.Now,
+	}
+
+	switch {
+	case cfg.Directory != nil:
+		v.registry = &directoryRegistry{dir: *cfg.Directory}
+	case cfg.RegistryURL != nil:
+		v.registry = &httpRegistry{url: strings.TrimSuffix(*cfg.RegistryURL, "/"), client: client}
+	default:
+		return nil, errors.New("either a schema directory or a registry URL must be informed")
+	}
+
+	if cfg.CacheTTL != nil {
+		p, err := period.Parse(*cfg.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("schema cache TTL cannot be parsed: %w", err)
+		}
+		v.ttl = p.DurationApprox()
+	}
+
+	return v, nil
+}
+
+// Validate returns a ValidationError if the event data does not conform to
+// the schema of its type, or any other error if the schema could not be
+// retrieved.
+func (v *Validator) Validate(ctx context.Context, event *cloudevents.Event) error {
+	s, err := v.schema(ctx, event.Type())
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		if v.requireSchema {
+			return &ValidationError{Type: event.Type(), Errors: []string{"no schema is registered for the event type"}}
+		}
+		return nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(event.Data(), &data); err != nil {
+		return &ValidationError{Type: event.Type(), Errors: []string{"event data is not JSON"}}
+	}
+
+	res := validate.NewSchemaValidator(s, nil, "", strfmt.Default).Validate(data)
+	if res.IsValid() {
+		return nil
+	}
+
+	verr := &ValidationError{Type: event.Type(), Errors: make([]string, 0, len(res.Errors))}
+	for _, e := range res.Errors {
+		verr.Errors = append(verr.Errors, e.Error())
+	}
+	return verr
+}
+
+// schema returns the parsed schema of the event type, nil if there is none.
+func (v *Validator) schema(ctx context.Context, eventType string) (*spec.Schema, error) {
+	if c, ok := v.cached(eventType); ok {
+		return c.schema, c.err
+	}
+
+	res, _, _ := v.requests.Do(eventType, func() (interface{}, error) {
+		// Another request might have completed meanwhile.
+		if c, ok := v.cached(eventType); ok {
+			return c, nil
+		}
+
+		c := v.retrieve(ctx, eventType)
+		// Failures due to the event request being canceled are not
+		// failures of the registry.
+		if c.err == nil || ctx.Err() == nil {
+			v.m.Lock()
+			v.cache.Add(eventType, c)
+			v.m.Unlock()
+		}
+		return c, nil
+	})
+
+	c := res.(cachedSchema)
+	return c.schema, c.err
+}
+
+// cached returns the cached schema of the event type if not expired.
+func (v *Validator) cached(eventType string) (cachedSchema, bool) {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	e, ok := v.cache.Get(eventType)
+	if !ok {
+		return cachedSchema{}, false
+	}
+	c := e.(cachedSchema)
+	return c, v.now().Before(c.expires)
+}
+
+// retrieve requests the schema of the event type to the registry. Types
+// without a schema are cached too, so that registries are not queried for
+// each event.
+func (v *Validator) retrieve(ctx context.Context, eventType string) cachedSchema {
+	now := v.now()
+
+	b, err := v.registry.Schema(ctx, eventType)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return cachedSchema{expires: now.Add(v.ttl)}
+	case err != nil:
+		return cachedSchema{
+			err:     fmt.Errorf("could not retrieve the schema of type %q: %w", eventType, err),
+			expires: now.Add(failureCacheTTL),
+		}
+	}
+
+	s := &spec.Schema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return cachedSchema{
+			err:     fmt.Errorf("could not parse the schema of type %q: %w", eventType, err),
+			expires: now.Add(failureCacheTTL),
+		}
+	}
+	return cachedSchema{schema: s, expires: now.Add(v.ttl)}
+}
+
+// directoryRegistry reads schemas from files named after the event type.
+type directoryRegistry struct {
+	dir string
+}
+
+func (r *directoryRegistry) Schema(_ context.Context, eventType string) ([]byte, error) {
+	// Types are not allowed to reference files out of the directory.
+	if eventType == "" || strings.ContainsAny(eventType, `/\`) || strings.Contains(eventType, "..") {
+		return nil, ErrNotFound
+	}
+
+	b, err := os.ReadFile(filepath.Join(r.dir, eventType+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// httpRegistry retrieves schemas from <url>/<type>.
+type httpRegistry struct {
+	url    string
+	client *http.Client
+}
+
+func (r *httpRegistry) Schema(ctx context.Context, eventType string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/"+url.PathEscape(eventType), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/schema+json, application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode/100 != 2:
+		return nil, fmt.Errorf("schema registry responded with status %d", res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxSchemaSize))
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/golang/groupcache/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+const orderSchema = `{
+  "type": "object",
+  "required": ["id", "amount"],
+  "properties": {
+    "id": {"type": "string"},
+    "amount": {"type": "number", "minimum": 0}
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "order.created.json"), []byte(orderSchema), 0o600))
+
+	v, err := New(&cfgbroker.SchemaValidation{Directory: &dir}, nil)
+	require.NoError(t, err)
+
+	newEvent := func(eventType, data string) *cloudevents.Event {
+		e := lib.NewCloudEvent(lib.CloudEventWithDataOption(cloudevents.ApplicationJSON, []byte(data)))
+		e.SetType(eventType)
+		return &e
+	}
+
+	testCases := map[string]struct {
+		event  *cloudevents.Event
+		errors []string
+	}{
+		"conforming": {
+			event: newEvent("order.created", `{"id":"1","amount":10}`),
+		},
+		"not conforming": {
+			event:  newEvent("order.created", `{"id":1}`),
+			errors: []string{"id in body must be of type string: \"number\"", ".amount in body is required"},
+		},
+		"not JSON": {
+			event:  newEvent("order.created", `id=1`),
+			errors: []string{"event data is not JSON"},
+		},
+		"no schema": {
+			event: newEvent("order.deleted", `{}`),
+		},
+		"out of the directory": {
+			event: newEvent("../order.created", `{}`),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := v.Validate(context.Background(), tc.event)
+			if tc.errors == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr), "Expected a validation error, got %v", err)
+			assert.ElementsMatch(t, tc.errors, verr.Errors)
+		})
+	}
+
+	v.requireSchema = true
+	var verr *ValidationError
+	assert.True(t, errors.As(v.Validate(context.Background(), newEvent("order.deleted", `{}`)), &verr))
+}
+
+func TestRegistryCache(t *testing.T) {
+	requests := map[string]int{}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/schemas/order.created":
+			_, _ = w.Write([]byte(orderSchema))
+		case "/schemas/order.failed":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+
+	url := registry.URL + "/schemas/"
+	ttl := "PT1M"
+	v, err := New(&cfgbroker.SchemaValidation{RegistryURL: &url, CacheTTL: &ttl}, registry.Client())
+	require.NoError(t, err)
+
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	validate := func(eventType string) error {
+		e := lib.NewCloudEvent(lib.CloudEventWithDataOption(cloudevents.ApplicationJSON, []byte(`{"id":"1","amount":1}`)))
+		e.SetType(eventType)
+		return v.Validate(context.Background(), &e)
+	}
+
+	for n := 0; n < 2; n++ {
+		assert.NoError(t, validate("order.created"))
+		assert.NoError(t, validate("order.deleted"))
+
+		err := validate("order.failed")
+		var verr *ValidationError
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &verr), "Registry errors are not validation errors")
+	}
+
+	// Schemas are requested again once expired, registry errors being
+	// cached for a shorter time.
+	now = now.Add(failureCacheTTL)
+	assert.Error(t, validate("order.failed"))
+	now = now.Add(time.Minute)
+	assert.NoError(t, validate("order.created"))
+
+	assert.Equal(t, map[string]int{
+		"/schemas/order.created": 2,
+		"/schemas/order.deleted": 1,
+		"/schemas/order.failed":  2,
+	}, requests)
+}
+
+func TestRegistryConcurrentRequests(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write([]byte(orderSchema))
+	}))
+	defer registry.Close()
+
+	url := registry.URL
+	v, err := New(&cfgbroker.SchemaValidation{RegistryURL: &url}, registry.Client())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := lib.NewCloudEvent(lib.CloudEventWithDataOption(cloudevents.ApplicationJSON, []byte(`{"id":"1","amount":1}`)))
+			e.SetType("order.created")
+			assert.NoError(t, v.Validate(context.Background(), &e))
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "Concurrent events must share the registry request")
+}
+
+func TestRegistryCacheEviction(t *testing.T) {
+	requests := map[string]int{}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer registry.Close()
+
+	url := registry.URL
+	v, err := New(&cfgbroker.SchemaValidation{RegistryURL: &url}, registry.Client())
+	require.NoError(t, err)
+	v.cache = lru.New(1)
+
+	for _, eventType := range []string{"order.created", "order.deleted", "order.created"} {
+		e := lib.NewCloudEvent()
+		e.SetType(eventType)
+		assert.NoError(t, v.Validate(context.Background(), &e))
+	}
+
+	assert.Equal(t, map[string]int{
+		"/order.created": 2,
+		"/order.deleted": 1,
+	}, requests, "The least recently used types must be evicted")
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package simulation evaluates proposed Triggers against the events
+// retained at the backend, predicting their volume before activating them.
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+	"knative.dev/eventing/pkg/eventfilter"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions"
+)
+
+const (
+	// Default and maximum number of retained events evaluated.
+	defaultEvents = 1000
+	maxEvents     = 10000
+
+	// Default number of matching events returned.
+	defaultSamples = 10
+
+	maxBodySize = 1 << 20
+)
+
+// Result of evaluating a Trigger.
+type Result struct {
+	// Evaluated is the number of retained events evaluated.
+	Evaluated int `json:"evaluated"`
+	// Matched is the number of evaluated events that pass the filters.
+	Matched int `json:"matched"`
+
+	// Time range of the evaluated events, informed when they have the
+	// time attribute.
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// MatchedPerHour extrapolates the matched events to an hour of
+	// traffic like the one evaluated.
+	MatchedPerHour *float64 `json:"matchedPerHour,omitempty"`
+
+	// Samples are the newest matching events.
+	Samples []cloudevents.Event `json:"samples"`
+}
+
+// Simulate evaluates the Trigger filter against the last n events retained
+// at the backend, returning up to samples matching events.
+func Simulate(ctx context.Context, reader backend.EventReader, filter eventfilter.Filter, n, samples int) (*Result, error) {
+	events, err := reader.LastEvents(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Evaluated: len(events),
+		Samples:   []cloudevents.Event{},
+	}
+
+	var from, to time.Time
+	for _, e := range events {
+		if t := e.Time(); !t.IsZero() {
+			if from.IsZero() || t.Before(from) {
+				from = t
+			}
+			if t.After(to) {
+				to = t
+			}
+		}
+
+		if filter.Filter(ctx, e) == eventfilter.FailFilter {
+			continue
+		}
+		res.Matched++
+		if len(res.Samples) < samples {
+			res.Samples = append(res.Samples, e)
+		}
+	}
+
+	if !from.IsZero() {
+		res.From, res.To = &from, &to
+		if span := to.Sub(from); span > 0 {
+			perHour := float64(res.Matched) / span.Hours()
+			res.MatchedPerHour = &perHour
+		}
+	}
+
+	return res, nil
+}
+
+// Handler evaluates the Trigger informed at the request body. The number
+// of evaluated events and returned samples can be informed at the events
+// and samples query parameters.
+func Handler(reader backend.EventReader, logger *zap.SugaredLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		n, err := intParam(q.Get("events"), defaultEvents)
+		if err != nil || n > maxEvents {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("events parameter must be a positive integer up to %d", maxEvents))
+			return
+		}
+
+		samples, err := intParam(q.Get("samples"), defaultSamples)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "samples parameter must be a positive integer")
+			return
+		}
+
+		t := cfgbroker.Trigger{}
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&t); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse trigger: %v", err))
+			return
+		}
+
+		if err := t.Validate(r.Context()); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		filter, err := subscriptions.NewFilter(r.Context(), t.Filters)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		res, err := Simulate(r.Context(), reader, filter, n, samples)
+		if err != nil {
+			logger.Errorw("Could not simulate trigger", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "could not read retained events")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("%d is not positive", n)
+	}
+	return n, nil
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
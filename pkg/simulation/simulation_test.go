@@ -0,0 +1,73 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fakeReader struct {
+	events []cloudevents.Event
+}
+
+func (f *fakeReader) LastEvents(_ context.Context, n int) ([]cloudevents.Event, error) {
+	if n > len(f.events) {
+		n = len(f.events)
+	}
+	return f.events[:n], nil
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	reader := &fakeReader{}
+	for i, typ := range []string{"order.created", "order.paid", "order.created", "order.created"} {
+		e := lib.NewCloudEvent(lib.CloudEventWithTypeOption(typ))
+		e.SetTime(now.Add(-time.Duration(i) * 20 * time.Minute))
+		reader.events = append(reader.events, e)
+	}
+	h := Handler(reader, zap.NewNop().Sugar())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/simulations?samples=1",
+		strings.NewReader(`{"filters":[{"exact":{"type":"order.created"}}]}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	res := &Result{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	assert.Equal(t, 4, res.Evaluated)
+	assert.Equal(t, 3, res.Matched)
+	require.Len(t, res.Samples, 1)
+	assert.Equal(t, reader.events[0].ID(), res.Samples[0].ID())
+	require.NotNil(t, res.MatchedPerHour)
+	assert.InDelta(t, 3.0, *res.MatchedPerHour, 0.001)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/simulations?events=2", strings.NewReader(`{}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	assert.Equal(t, 2, res.Evaluated)
+	assert.Equal(t, 2, res.Matched, "Triggers without filters must match all events")
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/simulations",
+		strings.NewReader(`{"filters":[{"cesql":"type = = 'a'"}]}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/simulations?events=0", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package sla
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// CloudEvents type for reports sent to a CloudEvents sink.
+	ReportEventType = "io.triggermesh.broker.sla.report"
+
+	defaultObjectStoreRegion = "us-east-1"
+)
+
+var objectStoreEndpoints = map[string]string{
+	"s3": "https://s3.amazonaws.com",
+	"gs": "https://storage.googleapis.com",
+}
+
+// Sink receives reports.
+type Sink interface {
+	Write(context.Context, *Report) error
+}
+
+// NewSink creates a report sink from its URI. Supported values are HTTP(S)
+// URLs that will receive reports as CloudEvents, and s3://bucket/prefix or
+// gs://bucket/prefix URIs that will receive a JSON object per report. Object
+// store URIs can inform the endpoint and region query parameters.
+func NewSink(uri string, source string) (Sink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("SLA report sink %q cannot be parsed: %w", uri, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		c, err := cloudevents.NewClientHTTP(cloudevents.WithTarget(uri))
+		if err != nil {
+			return nil, fmt.Errorf("could not create CloudEvents client for SLA reports: %w", err)
+		}
+		return &cloudEventsSink{client: c, source: source}, nil
+
+	case "s3", "gs":
+		return newObjectStoreSink(u)
+	}
+
+	return nil, errors.New("SLA report sink must be an HTTP URL, or an s3:// or gs:// URI")
+}
+
+// cloudEventsSink sends reports as CloudEvents.
+type cloudEventsSink struct {
+	client cloudevents.Client
+	source string
+}
+
+func (s *cloudEventsSink) Write(ctx context.Context, r *Report) error {
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetType(ReportEventType)
+	event.SetSource(s.source)
+	event.SetTime(r.End)
+	if err := event.SetData(cloudevents.ApplicationJSON, r); err != nil {
+		return fmt.Errorf("could not serialize SLA report: %w", err)
+	}
+
+	if res := s.client.Send(ctx, event); !cloudevents.IsACK(res) {
+		return fmt.Errorf("could not send SLA report: %w", res)
+	}
+	return nil
+}
+
+// objectWriter writes objects to the bucket.
+type objectWriter interface {
+	PutObject(ctx context.Context, key string, body []byte) error
+}
+
+type minioWriter struct {
+	client *minio.Client
+	bucket string
+}
+
+func (w *minioWriter) PutObject(ctx context.Context, key string, body []byte) error {
+	_, err := w.client.PutObject(ctx, w.bucket, key, bytes.NewReader(body), int64(len(body)),
+		minio.PutObjectOptions{ContentType: cloudevents.ApplicationJSON})
+	return err
+}
+
+// objectStoreSink writes a JSON object per report, keyed by the start of
+// its period and a random suffix so that replicas do not overwrite each
+// other's reports.
+type objectStoreSink struct {
+	writer objectWriter
+	prefix string
+}
+
+func newObjectStoreSink(u *url.URL) (*objectStoreSink, error) {
+	q := u.Query()
+
+	endpoint := objectStoreEndpoints[u.Scheme]
+	if v := q.Get("endpoint"); v != "" {
+		endpoint = v
+	}
+	eu, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("SLA report object store endpoint cannot be parsed: %w", err)
+	}
+
+	region := defaultObjectStoreRegion
+	if v := q.Get("region"); v != "" {
+		region = v
+	}
+
+	c, err := minio.New(eu.Host, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure: eu.Scheme == "https",
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create object store client for SLA reports: %w", err)
+	}
+
+	return &objectStoreSink{
+		writer: &minioWriter{client: c, bucket: u.Host},
+		prefix: strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func (s *objectStoreSink) Write(ctx context.Context, r *Report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("could not serialize SLA report: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("could not generate SLA report object key: %w", err)
+	}
+	key := s.prefix + r.Start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix) + ".json"
+
+	if err := s.writer.PutObject(ctx, key, body); err != nil {
+		return fmt.Errorf("could not write SLA report to %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sla aggregates the delivery outcomes of each trigger over report
+// periods, emitting a report when each period ends.
+package sla
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Timeout for emitting the report of the ongoing period when stopping.
+	emitTimeout = 5 * time.Second
+
+	// Latency histogram buckets per power of two milliseconds, which
+	// bounds the error of the percentiles at about 9%. Latencies above
+	// the last bucket, about 4.6 hours, are counted in it.
+	bucketsPerOctave = 8
+	maxBucket        = 24 * bucketsPerOctave
+)
+
+// Report contains the outcomes of each trigger for a period.
+type Report struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Partial is set when the broker did not run the whole period.
+	Partial  bool                     `json:"partial,omitempty"`
+	Triggers map[string]TriggerReport `json:"triggers"`
+}
+
+// TriggerReport contains the outcomes of a trigger for a period.
+type TriggerReport struct {
+	// Events delivered to and not accepted by the target.
+	Delivered   uint64 `json:"delivered"`
+	Undelivered uint64 `json:"undelivered"`
+	// SuccessRate is the ratio of delivered events, 1 when no event was
+	// dispatched to the target.
+	SuccessRate float64 `json:"successRate"`
+	// LatencyP95Ms is the 95th percentile of the delivered events latency,
+	// including retries.
+	LatencyP95Ms float64 `json:"latencyP95Ms"`
+	// Events accepted by a dead letter sink, and events that no dead
+	// letter sink accepted.
+	DeadLettered uint64 `json:"deadLettered"`
+	Lost         uint64 `json:"lost"`
+}
+
+type triggerCounters struct {
+	delivered    uint64
+	undelivered  uint64
+	deadLettered uint64
+	lost         uint64
+	latencies    [maxBucket + 1]uint64
+}
+
+// Reporter counts the outcomes of each trigger, emitting a report to the
+// sink for each period. A nil reporter does not count outcomes.
+type Reporter struct {
+	sink   Sink
+	period time.Duration
+
+	// Start of the ongoing period, and whether the reporter started
+	// after it.
+	start    time.Time
+	partial  bool
+	triggers map[string]*triggerCounters
+	m        sync.Mutex
+
+	now    func() time.Time
+	logger *zap.SugaredLogger
+}
+
+// NewReporter creates a reporter that emits a report to the sink for each
+// period. Periods are aligned to multiples of their duration since the
+// zero time in UTC, which makes daily periods start at midnight and weekly
+// periods on Mondays.
+func NewReporter(sink Sink, period time.Duration, logger *zap.SugaredLogger) *Reporter {
+	r := &Reporter{
+		sink:     sink,
+		period:   period,
+		triggers: make(map[string]*triggerCounters),
+		now:      time.Now,
+		logger:   logger,
+	}
+	r.start = r.periodStart(r.now())
+	r.partial = true
+	return r
+}
+
+func (r *Reporter) periodStart(t time.Time) time.Time {
+	return t.UTC().Truncate(r.period)
+}
+
+// Delivered counts an event delivered to the trigger target along with the
+// time it took.
+func (r *Reporter) Delivered(trigger string, latency time.Duration) {
+	r.add(trigger, func(c *triggerCounters) {
+		c.delivered++
+		c.latencies[latencyBucket(latency)]++
+	})
+}
+
+// Undelivered counts an event that the trigger target did not accept.
+func (r *Reporter) Undelivered(trigger string) {
+	r.add(trigger, func(c *triggerCounters) { c.undelivered++ })
+}
+
+// DeadLettered counts an event accepted by a trigger dead letter sink.
+func (r *Reporter) DeadLettered(trigger string) {
+	r.add(trigger, func(c *triggerCounters) { c.deadLettered++ })
+}
+
+// Lost counts an event that neither the trigger target nor its dead letter
+// sinks accepted.
+func (r *Reporter) Lost(trigger string) {
+	r.add(trigger, func(c *triggerCounters) { c.lost++ })
+}
+
+func (r *Reporter) add(trigger string, f func(*triggerCounters)) {
+	if r == nil {
+		return
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	c, ok := r.triggers[trigger]
+	if !ok {
+		c = &triggerCounters{}
+		r.triggers[trigger] = c
+	}
+	f(c)
+}
+
+// Start emits the report of each period when it ends, until the context is
+// done, when the report of the ongoing period is emitted as partial.
+func (r *Reporter) Start(ctx context.Context) error {
+	for {
+		r.m.Lock()
+		end := r.start.Add(r.period)
+		r.m.Unlock()
+
+		t := time.NewTimer(end.Sub(r.now()))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			ectx, cancel := context.WithTimeout(context.Background(), emitTimeout)
+			defer cancel()
+			r.emit(ectx, r.rotate(r.now().UTC(), true))
+			return nil
+
+		case <-t.C:
+			r.emit(ctx, r.rotate(end, false))
+		}
+	}
+}
+
+// rotate returns the report of the ongoing period, which ends at the
+// informed time, and starts the next period.
+func (r *Reporter) rotate(end time.Time, partial bool) *Report {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rep := r.report(end)
+	rep.Partial = rep.Partial || partial
+
+	r.start = r.periodStart(end)
+	r.partial = false
+	r.triggers = make(map[string]*triggerCounters)
+
+	return rep
+}
+
+// report returns the report of the ongoing period. The lock must be held.
+func (r *Reporter) report(end time.Time) *Report {
+	rep := &Report{
+		Start:    r.start,
+		End:      end,
+		Partial:  r.partial,
+		Triggers: make(map[string]TriggerReport, len(r.triggers)),
+	}
+	for name, c := range r.triggers {
+		rep.Triggers[name] = c.report()
+	}
+	return rep
+}
+
+func (c *triggerCounters) report() TriggerReport {
+	tr := TriggerReport{
+		Delivered:    c.delivered,
+		Undelivered:  c.undelivered,
+		SuccessRate:  1,
+		LatencyP95Ms: c.latencyPercentile(0.95),
+		DeadLettered: c.deadLettered,
+		Lost:         c.lost,
+	}
+	if n := c.delivered + c.undelivered; n != 0 {
+		tr.SuccessRate = float64(c.delivered) / float64(n)
+	}
+	return tr
+}
+
+// latencyPercentile returns the upper bound of the bucket that contains
+// the percentile, zero if no latency was recorded.
+func (c *triggerCounters) latencyPercentile(p float64) float64 {
+	if c.delivered == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p * float64(c.delivered)))
+	var n uint64
+	for i, count := range c.latencies {
+		n += count
+		if n >= rank {
+			return bucketUpperBound(i)
+		}
+	}
+	return bucketUpperBound(maxBucket)
+}
+
+func latencyBucket(d time.Duration) int {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms <= 1 {
+		return 0
+	}
+	b := int(math.Ceil(math.Log2(ms) * bucketsPerOctave))
+	if b > maxBucket {
+		return maxBucket
+	}
+	return b
+}
+
+func bucketUpperBound(b int) float64 {
+	// Rounded to microseconds for readability.
+	return math.Round(math.Exp2(float64(b)/bucketsPerOctave)*1000) / 1000
+}
+
+func (r *Reporter) emit(ctx context.Context, rep *Report) {
+	if err := r.sink.Write(ctx, rep); err != nil {
+		// The report is logged so that it can be recovered.
+		r.logger.Errorw("Could not emit SLA report", zap.Error(err), zap.Any("report", rep))
+		return
+	}
+	r.logger.Infow("SLA report emitted", zap.Time("start", rep.Start), zap.Time("end", rep.End))
+}
+
+// Current returns the report of the ongoing period up to now.
+func (r *Reporter) Current() *Report {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.report(r.now().UTC())
+}
+
+// Handler serves the report of the ongoing period up to now.
+func Handler(r *Reporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(r.Current()); err != nil {
+			r.logger.Errorw("Could not write SLA report", zap.Error(err))
+		}
+	})
+}
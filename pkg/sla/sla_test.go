@@ -0,0 +1,152 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package sla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeSink struct {
+	reports []*Report
+	m       sync.Mutex
+}
+
+func (f *fakeSink) Write(_ context.Context, r *Report) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.reports = append(f.reports, r)
+	return nil
+}
+
+type fakeWriter struct {
+	objects map[string][]byte
+}
+
+func (f *fakeWriter) PutObject(_ context.Context, key string, body []byte) error {
+	f.objects[key] = body
+	return nil
+}
+
+func TestReporter(t *testing.T) {
+	now := time.Date(2023, 3, 1, 10, 30, 0, 0, time.UTC)
+	sink := &fakeSink{}
+	r := NewReporter(sink, 24*time.Hour, zap.NewNop().Sugar())
+	r.now = func() time.Time { return now }
+	r.start = r.periodStart(now)
+
+	for i := 1; i <= 100; i++ {
+		r.Delivered("t1", time.Duration(i)*time.Millisecond)
+	}
+	r.Undelivered("t1")
+	r.DeadLettered("t1")
+	r.Undelivered("t2")
+	r.Lost("t2")
+
+	rep := r.rotate(time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC), false)
+	assert.Equal(t, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), rep.Start)
+	assert.True(t, rep.Partial, "The first period must be partial")
+
+	t1 := rep.Triggers["t1"]
+	assert.Equal(t, uint64(100), t1.Delivered)
+	assert.Equal(t, uint64(1), t1.Undelivered)
+	assert.Equal(t, uint64(1), t1.DeadLettered)
+	assert.InDelta(t, 100.0/101, t1.SuccessRate, 0.0001)
+	// The 95th latency is 95ms, within the histogram error.
+	assert.InDelta(t, 95, t1.LatencyP95Ms, 95*0.1)
+
+	t2 := rep.Triggers["t2"]
+	assert.Equal(t, 0.0, t2.SuccessRate)
+	assert.Equal(t, 0.0, t2.LatencyP95Ms)
+	assert.Equal(t, uint64(1), t2.Lost)
+
+	// Counters are reset for the next period.
+	next := r.Current()
+	assert.Equal(t, time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC), next.Start)
+	assert.False(t, next.Partial)
+	assert.Empty(t, next.Triggers)
+
+	// Nil reporters do not count.
+	var nr *Reporter
+	nr.Delivered("t1", time.Second)
+}
+
+func TestReporterStart(t *testing.T) {
+	sink := &fakeSink{}
+	r := NewReporter(sink, 50*time.Millisecond, zap.NewNop().Sugar())
+	r.Delivered("t1", time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Start(ctx) }()
+
+	require.Eventually(t, func() bool {
+		sink.m.Lock()
+		defer sink.m.Unlock()
+		return len(sink.reports) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	sink.m.Lock()
+	defer sink.m.Unlock()
+	assert.Equal(t, uint64(1), sink.reports[0].Triggers["t1"].Delivered)
+	assert.Equal(t, sink.reports[0].End, sink.reports[1].Start, "Periods must be contiguous")
+	assert.True(t, sink.reports[len(sink.reports)-1].Partial, "The report emitted when stopping must be partial")
+}
+
+func TestObjectStoreSink(t *testing.T) {
+	w := &fakeWriter{objects: make(map[string][]byte)}
+	s := &objectStoreSink{writer: w, prefix: "reports/"}
+
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.Write(context.Background(), &Report{
+		Start:    start,
+		End:      start.Add(24 * time.Hour),
+		Triggers: map[string]TriggerReport{"t1": {Delivered: 1, SuccessRate: 1}},
+	}))
+
+	require.Len(t, w.objects, 1)
+	for key, body := range w.objects {
+		assert.True(t, strings.HasPrefix(key, "reports/20230301T000000Z-"), key)
+		var rep Report
+		require.NoError(t, json.Unmarshal(body, &rep))
+		assert.Equal(t, uint64(1), rep.Triggers["t1"].Delivered)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	_, err := NewSink("s3://bucket/prefix/?endpoint=http://minio:9000", "broker/test")
+	assert.NoError(t, err)
+	_, err = NewSink("file:///tmp/reports", "broker/test")
+	assert.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	r := NewReporter(&fakeSink{}, time.Hour, zap.NewNop().Sugar())
+	r.Delivered("t1", time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/sla", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rep Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rep))
+	assert.Equal(t, uint64(1), rep.Triggers["t1"].Delivered)
+
+	rec = httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/sla", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TriggerStatus informs the health of a Trigger.
+type TriggerStatus struct {
+	// Ready is false when the Trigger cannot deliver events to its target.
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+
+	// Number of events delivered and failed to be delivered to the target.
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`
+
+	LastDeliveryTime *time.Time `json:"lastDeliveryTime,omitempty"`
+	LastError        string     `json:"lastError,omitempty"`
+	LastErrorTime    *time.Time `json:"lastErrorTime,omitempty"`
+
+	// Errors found compiling the Trigger filters.
+	FilterErrors []string `json:"filterErrors,omitempty"`
+
+	// Number of events evaluated by the Trigger filters and those that
+	// matched, along with the fraction of evaluated events that matched,
+	// which is not informed until an event is evaluated.
+	Evaluated uint64   `json:"evaluated,omitempty"`
+	Matched   uint64   `json:"matched,omitempty"`
+	MatchRate *float64 `json:"matchRate,omitempty"`
+
+	// Active informs if the Trigger activation conditions are met, when
+	// the Trigger has activation conditions.
+	Active *bool `json:"active,omitempty"`
+
+	// Paused informs if dispatching events to the Trigger is paused.
+	Paused bool `json:"paused,omitempty"`
+
+	// DryRun informs if the Trigger only records the events that match its
+	// filters, without delivering them.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Number of deliveries recorded or asserted as fixtures, indexed by
+	// outcome, when the Trigger has fixtures configured.
+	Fixtures map[string]uint64 `json:"fixtures,omitempty"`
+}
+
+// Status of the broker.
+type Status struct {
+	Time     time.Time                `json:"time"`
+	Triggers map[string]TriggerStatus `json:"triggers"`
+}
+
+// Source returns the current status.
+type Source func() *Status
+
+// Writer persists status documents.
+type Writer interface {
+	Write(ctx context.Context, s *Status) error
+}
+
+// NewFileWriter returns a writer that replaces the contents of the
+// file with the latest status document.
+func NewFileWriter(path string) Writer {
+	return &fileWriter{path: path}
+}
+
+type fileWriter struct {
+	path string
+}
+
+func (w *fileWriter) Write(_ context.Context, s *Status) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not serialize status: %w", err)
+	}
+
+	// Write to a temporary file and rename to avoid readers
+	// finding partially written contents.
+	tmp := filepath.Join(filepath.Dir(w.path), "."+filepath.Base(w.path)+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("could not write status: %w", err)
+	}
+
+	return os.Rename(tmp, w.path)
+}
+
+// ParseFileSink returns the path for file:// status sinks.
+func ParseFileSink(sink string) (string, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return "", fmt.Errorf("status sink %q cannot be parsed: %w", sink, err)
+	}
+
+	if u.Scheme != "file" || u.Path == "" {
+		return "", errors.New("status sink must be a file:// path")
+	}
+
+	return u.Path, nil
+}
+
+// Reporter periodically writes the status document.
+type Reporter struct {
+	source Source
+	writer Writer
+	period time.Duration
+
+	logger *zap.SugaredLogger
+}
+
+func NewReporter(source Source, writer Writer, period time.Duration, logger *zap.SugaredLogger) *Reporter {
+	return &Reporter{
+		source: source,
+		writer: writer,
+		period: period,
+		logger: logger,
+	}
+}
+
+func (r *Reporter) Start(ctx context.Context) error {
+	t := time.NewTicker(r.period)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			if err := r.writer.Write(ctx, r.source()); err != nil {
+				r.logger.Errorw("Could not write status", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Handler serves the current status document.
+func Handler(source Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(source())
+	})
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseFileSink(t *testing.T) {
+	tcs := map[string]struct {
+		sink    string
+		path    string
+		isError bool
+	}{
+		"file path": {
+			sink: "file:///var/run/broker/status.json",
+			path: "/var/run/broker/status.json",
+		},
+		"no path": {
+			sink:    "file://",
+			isError: true,
+		},
+		"not a file": {
+			sink:    "http://status.example.com",
+			isError: true,
+		},
+		"not parseable": {
+			sink:    "file://local host:\n",
+			isError: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParseFileSink(tc.sink)
+			if tc.isError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.json")
+	w := NewFileWriter(path)
+
+	for _, delivered := range []uint64{1, 2} {
+		s := &Status{Triggers: map[string]TriggerStatus{"t1": {Ready: true, Delivered: delivered}}}
+		require.NoError(t, w.Write(context.Background(), s))
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		read := &Status{}
+		require.NoError(t, json.Unmarshal(b, read))
+		assert.Equal(t, delivered, read.Triggers["t1"].Delivered, "Status must be replaced")
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "Temporary files must not be left")
+
+	assert.Error(t, NewFileWriter(filepath.Join(dir, "missing", "status.json")).Write(context.Background(), &Status{}))
+}
+
+type statusRecorder struct {
+	written chan *Status
+}
+
+func (r *statusRecorder) Write(ctx context.Context, s *Status) error {
+	select {
+	case r.written <- s:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestReporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &statusRecorder{written: make(chan *Status, 1)}
+	source := func() *Status {
+		return &Status{Triggers: map[string]TriggerStatus{"t1": {Ready: true}}}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- NewReporter(source, w, 10*time.Millisecond, zap.NewNop().Sugar()).Start(ctx)
+	}()
+
+	select {
+	case s := <-w.written:
+		assert.True(t, s.Triggers["t1"].Ready)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Status was not written")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestHandler(t *testing.T) {
+	source := func() *Status {
+		return &Status{Triggers: map[string]TriggerStatus{"t1": {Ready: false, Reason: "DeliveryFailed"}}}
+	}
+
+	tcs := map[string]struct {
+		method string
+		code   int
+	}{
+		"get": {
+			method: http.MethodGet,
+			code:   http.StatusOK,
+		},
+		"post": {
+			method: http.MethodPost,
+			code:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler(source).ServeHTTP(rec, httptest.NewRequest(tc.method, "/status", nil))
+			require.Equal(t, tc.code, rec.Code)
+			if tc.code != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			s := &Status{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(s))
+			assert.Equal(t, "DeliveryFailed", s.Triggers["t1"].Reason)
+		})
+	}
+}
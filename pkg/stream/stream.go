@@ -0,0 +1,274 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package stream pushes the events dispatched to triggers to the consumers
+// connected to them, for consumers that cannot expose an HTTP endpoint.
+package stream
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// DefaultBufferSize is the number of events buffered by streams that do not
+// inform it.
+const DefaultBufferSize = 100
+
+var (
+	// ErrNoConsumers is returned when publishing to a stream no consumer
+	// is connected to.
+	ErrNoConsumers = errors.New("no consumers are connected to the stream")
+	// ErrClosed is returned when using a stream that was closed.
+	ErrClosed = errors.New("stream is closed")
+	// ErrFull is returned when publishing to a stream whose buffer is full
+	// of events not sent yet.
+	ErrFull = errors.New("stream buffer is full of events not sent to consumers")
+)
+
+// Hub of the streams of triggers, indexed by trigger name.
+type Hub struct {
+	streams map[string]*Stream
+	m       sync.Mutex
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{streams: make(map[string]*Stream)}
+}
+
+// Stream returns the stream of the trigger, creating it if it does not
+// exist, buffering up to size events.
+func (h *Hub) Stream(trigger string, size int) *Stream {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	s, ok := h.streams[trigger]
+	if !ok {
+		s = &Stream{
+			hub:     h,
+			name:    trigger,
+			epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+			next:    1,
+			changed: make(chan struct{}),
+		}
+		h.streams[trigger] = s
+	}
+
+	s.m.Lock()
+	s.size = size
+	s.m.Unlock()
+
+	return s
+}
+
+// Get returns the stream of the trigger, nil if it does not exist.
+func (h *Hub) Get(trigger string) *Stream {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.streams[trigger]
+}
+
+// Stream buffers the events published for a trigger until they are sent to
+// one of its consumers, keeping the last events sent so that consumers can
+// resume from them after reconnecting. Publishing fails right away while the
+// buffer is full of events not sent yet, so that slow consumers do not hold
+// the dispatch of the trigger.
+type Stream struct {
+	hub  *Hub
+	name string
+
+	// Tells apart the resume tokens of the streams created for a trigger,
+	// whose sequences start over.
+	epoch string
+
+	size   int
+	events []entry
+	// Sequence of the next event published, events start at 1.
+	next uint64
+	// Sequence of the most recent event sent to a consumer.
+	sent uint64
+
+	consumers int
+	closed    bool
+
+	// Closed and replaced when events are published or sent, or the stream
+	// is closed.
+	changed chan struct{}
+	m       sync.Mutex
+}
+
+type entry struct {
+	seq   uint64
+	event cloudevents.Event
+}
+
+// Message is an event sent to a consumer, along with the token consumers
+// inform to resume the stream after it.
+type Message struct {
+	Token string
+	Event cloudevents.Event
+}
+
+// Publish buffers the event for consumers, returning an error if no
+// consumer is connected or the buffer is full of events not sent yet.
+func (s *Stream) Publish(event *cloudevents.Event) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	switch {
+	case s.closed:
+		return ErrClosed
+	case s.consumers == 0:
+		return ErrNoConsumers
+	case s.pending() >= uint64(s.size):
+		return ErrFull
+	}
+
+	s.events = append(s.events, entry{seq: s.next, event: *event})
+	s.next++
+
+	// Events not sent yet fit in the buffer, only sent events are
+	// discarded.
+	if len(s.events) > s.size {
+		s.events = append(s.events[:0:0], s.events[len(s.events)-s.size:]...)
+	}
+
+	s.notify()
+	return nil
+}
+
+// pending returns the number of events published but not sent yet.
+func (s *Stream) pending() uint64 {
+	return s.next - 1 - s.sent
+}
+
+// notify wakes up the consumers waiting for changes.
+func (s *Stream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Subscribe connects a consumer to the stream, which receives the events
+// after the one the resume token informs, when still buffered, or the events
+// not sent to any consumer yet otherwise.
+func (s *Stream) Subscribe(resume string) (*Consumer, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	c := &Consumer{stream: s, cursor: s.sent + 1}
+	if seq, ok := s.parseToken(resume); ok && seq < s.next {
+		c.cursor = seq + 1
+	}
+	s.consumers++
+
+	return c, nil
+}
+
+// parseToken returns the sequence of the event the token informs, false if
+// the token belongs to other stream.
+func (s *Stream) parseToken(token string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(token, "-")
+	if !ok || epoch != s.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Close disconnects the consumers and rejects further events.
+func (s *Stream) Close() {
+	s.hub.m.Lock()
+	if s.hub.streams[s.name] == s {
+		delete(s.hub.streams, s.name)
+	}
+	s.hub.m.Unlock()
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.notify()
+	}
+}
+
+// Consumer receives the events of a stream in order.
+type Consumer struct {
+	stream *Stream
+	// Sequence of the next event to receive.
+	cursor uint64
+	closed bool
+}
+
+// Next waits for the next event of the stream.
+func (c *Consumer) Next(ctx context.Context) (*Message, error) {
+	s := c.stream
+	s.m.Lock()
+	for {
+		if s.closed || c.closed {
+			s.m.Unlock()
+			return nil, ErrClosed
+		}
+
+		if len(s.events) != 0 {
+			// Events no longer buffered are skipped.
+			if oldest := s.events[0].seq; c.cursor < oldest {
+				c.cursor = oldest
+			}
+			if c.cursor < s.next {
+				break
+			}
+		}
+
+		ch := s.changed
+		s.m.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ch:
+		}
+		s.m.Lock()
+	}
+	defer s.m.Unlock()
+
+	e := s.events[c.cursor-s.events[0].seq]
+	c.cursor++
+	if e.seq > s.sent {
+		s.sent = e.seq
+		s.notify()
+	}
+
+	return &Message{
+		Token: s.epoch + "-" + strconv.FormatUint(e.seq, 10),
+		Event: e.event,
+	}, nil
+}
+
+// Close disconnects the consumer from the stream.
+func (c *Consumer) Close() {
+	s := c.stream
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		s.consumers--
+		s.notify()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	h := NewHub()
+	s := h.Stream("t1", 2)
+	assert.Same(t, s, h.Get("t1"))
+
+	ev := lib.NewCloudEvent()
+	assert.ErrorIs(t, s.Publish(&ev), ErrNoConsumers)
+
+	c, err := s.Subscribe("")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish(&ev))
+	require.NoError(t, s.Publish(&ev))
+
+	// The buffer is full of events not sent yet.
+	assert.ErrorIs(t, s.Publish(&ev), ErrFull)
+
+	m1, err := c.Next(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(&ev), "Sending events must make room for publishers")
+
+	m2, err := c.Next(ctx)
+	require.NoError(t, err)
+	m3, err := c.Next(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, m1.Token, m2.Token)
+	assert.NotEqual(t, m2.Token, m3.Token)
+	c.Close()
+
+	// Resuming replays the events still buffered after the token.
+	c, err = s.Subscribe(m2.Token)
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(&ev))
+	m, err := c.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, m3.Token, m.Token)
+	m, err = c.Next(ctx)
+	require.NoError(t, err)
+	c.Close()
+
+	// Tokens of events no longer buffered resume from the oldest one.
+	c, err = s.Subscribe(m1.Token)
+	require.NoError(t, err)
+	m, err = c.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, m3.Token, m.Token)
+
+	s.Close()
+	assert.Nil(t, h.Get("t1"))
+	_, err = c.Next(ctx)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, s.Publish(&ev), ErrClosed)
+
+	// Tokens of other streams are ignored.
+	s = h.Stream("t1", 2)
+	c, err = s.Subscribe(m3.Token)
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(&ev))
+	m, err = c.Next(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, m3.Token, m.Token)
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Default period for evaluating activation conditions.
+	defaultActivationPeriod = 10 * time.Second
+
+	// Timeout for requests to activation URLs.
+	activationRequestTimeout = 5 * time.Second
+)
+
+// timeWindow is a parsed activation time window, with start and end
+// expressed as minutes since midnight.
+type timeWindow struct {
+	days       map[time.Weekday]struct{}
+	start, end int
+	location   *time.Location
+}
+
+func (w *timeWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+
+	day := t.Weekday()
+	switch {
+	case w.start <= w.end:
+		if minute < w.start || minute >= w.end {
+			return false
+		}
+	case minute >= w.start:
+		// Window that ends the next day, the day informed is the start day.
+	case minute < w.end:
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(w.days) == 0 {
+		return true
+	}
+	_, ok := w.days[day]
+	return ok
+}
+
+// activationGate periodically evaluates the activation conditions of a
+// trigger. Conditions that cannot be evaluated are considered not met.
+type activationGate struct {
+	period  time.Duration
+	windows []timeWindow
+	file    string
+	url     string
+
+	client *http.Client
+	active atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	logger *zap.SugaredLogger
+}
+
+func newActivationGate(a *cfgbroker.Activation, logger *zap.SugaredLogger) (*activationGate, error) {
+	g := &activationGate{
+		period: defaultActivationPeriod,
+		client: &http.Client{Timeout: activationRequestTimeout},
+		logger: logger,
+	}
+
+	if a.Period != nil {
+		p, err := period.Parse(*a.Period)
+		if err != nil {
+			return nil, fmt.Errorf("activation period cannot be parsed: %w", err)
+		}
+		g.period = p.DurationApprox()
+	}
+
+	for _, tw := range a.TimeWindows {
+		w := timeWindow{days: make(map[time.Weekday]struct{}, len(tw.Days))}
+
+		for _, d := range tw.Days {
+			wd, ok := cfgbroker.WeekDays[d]
+			if !ok {
+				return nil, fmt.Errorf("unknown week day %q", d)
+			}
+			w.days[wd] = struct{}{}
+		}
+
+		start, err := time.Parse(cfgbroker.TimeWindowLayout, tw.Start)
+		if err != nil {
+			return nil, fmt.Errorf("time window start cannot be parsed: %w", err)
+		}
+		w.start = start.Hour()*60 + start.Minute()
+
+		end, err := time.Parse(cfgbroker.TimeWindowLayout, tw.End)
+		if err != nil {
+			return nil, fmt.Errorf("time window end cannot be parsed: %w", err)
+		}
+		w.end = end.Hour()*60 + end.Minute()
+
+		if w.location, err = time.LoadLocation(tw.TimeZone); err != nil {
+			return nil, fmt.Errorf("time window time zone cannot be loaded: %w", err)
+		}
+
+		g.windows = append(g.windows, w)
+	}
+
+	if a.File != nil {
+		g.file = *a.File
+	}
+	if a.URL != nil {
+		g.url = *a.URL
+	}
+
+	return g, nil
+}
+
+// start evaluates the conditions and keeps evaluating them periodically
+// until stopped.
+func (g *activationGate) start(ctx context.Context) {
+	ctx, g.cancel = context.WithCancel(ctx)
+	g.update(ctx)
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		ticker := time.NewTicker(g.period)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				g.update(ctx)
+			}
+		}
+	}()
+}
+
+func (g *activationGate) stop() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+	g.wg.Wait()
+}
+
+func (g *activationGate) isActive() bool {
+	return g.active.Load()
+}
+
+func (g *activationGate) update(ctx context.Context) {
+	active, err := g.evaluate(ctx, time.Now())
+	if err != nil {
+		g.logger.Warnw("Could not evaluate trigger activation conditions", zap.Error(err))
+	}
+
+	if g.active.Swap(active) != active {
+		g.logger.Infow("Trigger activation changed", zap.Bool("active", active))
+	}
+}
+
+func (g *activationGate) evaluate(ctx context.Context, now time.Time) (bool, error) {
+	if len(g.windows) != 0 {
+		in := false
+		for i := range g.windows {
+			if g.windows[i].contains(now) {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false, nil
+		}
+	}
+
+	if g.file != "" {
+		b, err := os.ReadFile(g.file)
+		if err != nil {
+			return false, fmt.Errorf("could not read activation file: %w", err)
+		}
+		if !bytes.EqualFold(bytes.TrimSpace(b), []byte("true")) {
+			return false, nil
+		}
+	}
+
+	if g.url != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url, nil)
+		if err != nil {
+			return false, fmt.Errorf("could not build activation request: %w", err)
+		}
+
+		res, err := g.client.Do(req)
+		if err != nil {
+			return false, fmt.Errorf("could not request activation URL: %w", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestActivationGate(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2023, 3, 15, 22, 30, 0, 0, time.UTC)
+
+	flagFile := filepath.Join(t.TempDir(), "flag")
+	require.NoError(t, os.WriteFile(flagFile, []byte("true\n"), 0o600))
+
+	flagService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/enabled" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer flagService.Close()
+
+	tcs := map[string]struct {
+		activation cfgbroker.Activation
+		expected   bool
+	}{
+		"in time window": {
+			activation: cfgbroker.Activation{TimeWindows: []cfgbroker.TimeWindow{{Start: "22:00", End: "23:00"}}},
+			expected:   true,
+		},
+		"out of time window": {
+			activation: cfgbroker.Activation{TimeWindows: []cfgbroker.TimeWindow{{Start: "09:00", End: "17:00"}}},
+		},
+		"time window in other time zone": {
+			activation: cfgbroker.Activation{TimeWindows: []cfgbroker.TimeWindow{{Start: "07:00", End: "08:00", TimeZone: "Asia/Tokyo"}}},
+			expected:   true,
+		},
+		"time window ending next day": {
+			activation: cfgbroker.Activation{TimeWindows: []cfgbroker.TimeWindow{{Days: []string{"wed"}, Start: "22:00", End: "02:00"}}},
+			expected:   true,
+		},
+		"time window other day": {
+			activation: cfgbroker.Activation{TimeWindows: []cfgbroker.TimeWindow{{Days: []string{"sat", "sun"}, Start: "00:00", End: "23:59"}}},
+		},
+		"file enabled": {
+			activation: cfgbroker.Activation{File: &flagFile},
+			expected:   true,
+		},
+		"file missing": {
+			activation: cfgbroker.Activation{File: strPtr(filepath.Join(t.TempDir(), "missing"))},
+		},
+		"url enabled": {
+			activation: cfgbroker.Activation{URL: strPtr(flagService.URL + "/enabled")},
+			expected:   true,
+		},
+		"url disabled": {
+			activation: cfgbroker.Activation{URL: strPtr(flagService.URL + "/disabled")},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			g, err := newActivationGate(&tc.activation, zap.NewNop().Sugar())
+			require.NoError(t, err)
+
+			active, _ := g.evaluate(context.Background(), now)
+			assert.Equal(t, tc.expected, active)
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	ceamqp "github.com/cloudevents/sdk-go/protocol/amqp/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Time to wait for the connection to the AMQP server.
+const amqpConnectTimeout = 10 * time.Second
+
+// amqpSender sends messages to the target node.
+type amqpSender interface {
+	Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error
+	Close(ctx context.Context) error
+}
+
+// amqpTarget sends events to an AMQP node. The sender connects to the AMQP
+// server on the first delivery, and again after a delivery fails, since
+// failures usually mean the connection was lost.
+type amqpTarget struct {
+	cfg cfgbroker.AMQPTarget
+
+	// Attempts to send each event after the first one fails.
+	retries int
+	backoff time.Duration
+
+	newSender func() (amqpSender, error)
+	s         amqpSender
+	m         sync.Mutex
+}
+
+func newAMQPTarget(cfg *cfgbroker.AMQPTarget, do *cfgbroker.DeliveryOptions) (*amqpTarget, error) {
+	a := &amqpTarget{cfg: *cfg}
+
+	if do != nil && do.Retry != nil {
+		a.retries = int(*do.Retry)
+		if do.BackoffDelay != nil {
+			p, err := period.Parse(*do.BackoffDelay)
+			if err != nil {
+				return nil, fmt.Errorf("backoff delay cannot be parsed: %w", err)
+			}
+			a.backoff = p.DurationApprox()
+		}
+	}
+
+	// Passwords read from secrets are read again for every connection, so
+	// that connections after a failure use rotated credentials.
+	var password *secret.Secret
+	if cfg.SASL != nil && cfg.SASL.PasswordFrom != nil {
+		s, err := secret.New(cfg.SASL.PasswordFrom)
+		if err != nil {
+			return nil, fmt.Errorf("SASL password: %w", err)
+		}
+		password = s
+	}
+
+	a.newSender = func() (amqpSender, error) {
+		opts := []amqp.ConnOption{amqp.ConnConnectTimeout(amqpConnectTimeout)}
+		if sasl := a.cfg.SASL; sasl != nil {
+			p := sasl.Password
+			if password != nil {
+				v, err := password.Value()
+				if err != nil {
+					return nil, fmt.Errorf("could not read SASL password: %w", err)
+				}
+				p = v
+			}
+			opts = append(opts, amqp.ConnSASLPlain(sasl.User, p))
+		}
+		return ceamqp.NewSenderProtocol(a.cfg.URL, a.cfg.Address, opts, nil)
+	}
+
+	return a, nil
+}
+
+// url identifies the target at logs, audit records and status.
+func (a *amqpTarget) url() string {
+	return a.cfg.NodeURL()
+}
+
+func (a *amqpTarget) send(ctx context.Context, event *cloudevents.Event) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = a.sendOnce(ctx, event); err == nil || attempt == a.retries {
+			return err
+		}
+
+		select {
+		case <-time.After(a.backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (a *amqpTarget) sendOnce(ctx context.Context, event *cloudevents.Event) error {
+	a.m.Lock()
+	if a.s == nil {
+		s, err := a.newSender()
+		if err != nil {
+			a.m.Unlock()
+			return fmt.Errorf("could not connect to AMQP: %w", err)
+		}
+		a.s = s
+	}
+	s := a.s
+	a.m.Unlock()
+
+	err := s.Send(ctx, binding.ToMessage(event))
+	if err != nil {
+		a.m.Lock()
+		if a.s == s {
+			_ = s.Close(context.Background())
+			a.s = nil
+		}
+		a.m.Unlock()
+	}
+	return err
+}
+
+func (a *amqpTarget) close() {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.s != nil {
+		_ = a.s.Close(context.Background())
+		a.s = nil
+	}
+}
+
+// deliverToAMQP sends the event to the AMQP target.
+func (s delivery) deliverToAMQP(ctx context.Context, event *cloudevents.Event) error {
+	start := time.Now()
+
+	err := s.amqp.send(ctx, event)
+
+	var result protocol.Result = protocol.ResultACK
+	if err != nil {
+		result = err
+	}
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
+
+	if err != nil {
+		s.logger.Errorw(fmt.Sprintf("Failed to send event to %s", s.amqp.url()),
+			zap.Error(err), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return err
+	}
+
+	s.debugw(ctx, fmt.Sprintf("Event sent to %s", s.amqp.url()), zap.String("id", event.ID()))
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/audit"
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestAudit(t *testing.T) {
+	target, err := url.Parse("http://target.example.com")
+	require.NoError(t, err)
+
+	tcs := map[string]struct {
+		result   protocol.Result
+		sink     bool
+		debug    bool
+		outcome  audit.Outcome
+		attempts int
+		hasError bool
+		recorded bool
+	}{
+		"delivered": {
+			result:   cehttp.NewResult(http.StatusAccepted, "%w", protocol.ResultACK),
+			sink:     true,
+			outcome:  audit.OutcomeDelivered,
+			attempts: 1,
+			recorded: true,
+		},
+		"rejected after retries": {
+			result: cehttp.NewRetriesResult(
+				cehttp.NewResult(http.StatusInternalServerError, "%w", protocol.ResultNACK),
+				2, time.Now(), nil),
+			sink:     true,
+			outcome:  audit.OutcomeRejected,
+			attempts: 3,
+			hasError: true,
+			recorded: true,
+		},
+		"undelivered": {
+			result:   errors.New("connection refused"),
+			sink:     true,
+			outcome:  audit.OutcomeUndelivered,
+			attempts: 1,
+			hasError: true,
+			recorded: true,
+		},
+		"no sink": {
+			result: protocol.ResultACK,
+		},
+		"no sink for debug events": {
+			result: protocol.ResultACK,
+			debug:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			records := &auditRecords{}
+			s := subscriber{
+				name:   "test-trigger",
+				logger: zaptest.NewLogger(t).Sugar(),
+			}
+			if tc.sink {
+				s.auditSink = records
+			}
+
+			ctx := cloudevents.ContextWithTarget(context.Background(), target.String())
+			if tc.debug {
+				ctx = debug.ContextWithEnabled(ctx)
+			}
+
+			event := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"))
+			s.audit(ctx, &event, tc.result, time.Now())
+
+			if !tc.recorded {
+				assert.Empty(t, records.records)
+				return
+			}
+
+			require.Len(t, records.records, 1)
+			r := records.records[0]
+			assert.Equal(t, "1", r.EventID)
+			assert.Equal(t, "test-trigger", r.Trigger)
+			assert.Equal(t, target.String(), r.Target)
+			assert.Equal(t, tc.outcome, r.Outcome)
+			assert.Equal(t, tc.attempts, r.Attempts)
+			assert.Equal(t, tc.hasError, r.Error != "")
+		})
+	}
+}
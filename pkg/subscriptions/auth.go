@@ -0,0 +1,181 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type targetAuthKey struct{}
+
+// targetAuth adds the headers and credentials of the trigger target to
+// the requests whose context carries it, so that dead letter sinks, which
+// are sent using the same client, do not receive them.
+type targetAuth struct {
+	headers   http.Header
+	authorize func(*http.Request) error
+}
+
+func contextWithTargetAuth(ctx context.Context, a *targetAuth) context.Context {
+	if a == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, targetAuthKey{}, a)
+}
+
+func targetAuthFromContext(ctx context.Context) *targetAuth {
+	a, _ := ctx.Value(targetAuthKey{}).(*targetAuth)
+	return a
+}
+
+// newTargetAuth returns the headers and credentials for the target, nil
+// if none are informed. OAuth2 tokens are requested using the context.
+func newTargetAuth(ctx context.Context, headers map[string]string, auth *cfgbroker.TargetAuth) (*targetAuth, error) {
+	if len(headers) == 0 && auth == nil {
+		return nil, nil
+	}
+
+	a := &targetAuth{headers: make(http.Header, len(headers))}
+	for k, v := range headers {
+		a.headers.Set(k, v)
+	}
+
+	if auth == nil {
+		return a, nil
+	}
+
+	switch {
+	case auth.Bearer != nil:
+		token, err := secret.New(&auth.Bearer.Token)
+		if err != nil {
+			return nil, fmt.Errorf("bearer token: %w", err)
+		}
+		a.authorize = func(req *http.Request) error {
+			v, err := token.Value()
+			if err != nil {
+				return fmt.Errorf("could not read bearer token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+v)
+			return nil
+		}
+
+	case auth.Basic != nil:
+		password, err := secret.New(&auth.Basic.Password)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		username := auth.Basic.Username
+		a.authorize = func(req *http.Request) error {
+			v, err := password.Value()
+			if err != nil {
+				return fmt.Errorf("could not read basic auth password: %w", err)
+			}
+			req.SetBasicAuth(username, v)
+			return nil
+		}
+
+	case auth.OAuth2 != nil:
+		cs, err := secret.New(&auth.OAuth2.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("OAuth2 client secret: %w", err)
+		}
+		ts := &oauth2TokenSource{ctx: ctx, cfg: auth.OAuth2, secret: cs}
+		a.authorize = func(req *http.Request) error {
+			t, err := ts.token()
+			if err != nil {
+				return fmt.Errorf("could not obtain OAuth2 token: %w", err)
+			}
+			t.SetAuthHeader(req)
+			return nil
+		}
+	}
+
+	return a, nil
+}
+
+// oauth2TokenSource caches tokens until they expire, creating a new token
+// source when the client secret changes.
+type oauth2TokenSource struct {
+	ctx    context.Context
+	cfg    *cfgbroker.OAuth2Auth
+	secret *secret.Secret
+
+	source       oauth2.TokenSource
+	sourceSecret string
+	m            sync.Mutex
+}
+
+func (o *oauth2TokenSource) token() (*oauth2.Token, error) {
+	s, err := o.secret.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	o.m.Lock()
+	if o.source == nil || s != o.sourceSecret {
+		cc := &clientcredentials.Config{
+			ClientID:     o.cfg.ClientID,
+			ClientSecret: s,
+			TokenURL:     o.cfg.TokenURL,
+			Scopes:       o.cfg.Scopes,
+		}
+		if len(o.cfg.EndpointParams) != 0 {
+			cc.EndpointParams = make(url.Values, len(o.cfg.EndpointParams))
+			for k, v := range o.cfg.EndpointParams {
+				cc.EndpointParams.Set(k, v)
+			}
+		}
+		o.source = cc.TokenSource(o.ctx)
+		o.sourceSecret = s
+	}
+	source := o.source
+	o.m.Unlock()
+
+	return source.Token()
+}
+
+// authRoundTripper applies the target headers and credentials informed at
+// the request context.
+type authRoundTripper struct {
+	base http.RoundTripper
+}
+
+func withTargetAuth(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &authRoundTripper{base: rt}
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	a := targetAuthFromContext(req.Context())
+	if a == nil {
+		return rt.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for k, v := range a.headers {
+		req.Header[k] = v
+	}
+	if a.authorize != nil {
+		if err := a.authorize(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+
+	return rt.base.RoundTrip(req)
+}
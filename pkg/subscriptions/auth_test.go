@@ -0,0 +1,159 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+// headerRecorder keeps the headers of the last request received.
+type headerRecorder struct {
+	header http.Header
+	m      sync.Mutex
+}
+
+func (h *headerRecorder) server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.m.Lock()
+		h.header = r.Header.Clone()
+		h.m.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func (h *headerRecorder) get(name string) string {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.header.Get(name)
+}
+
+func TestTargetAuth(t *testing.T) {
+	var target, dls headerRecorder
+	ts := target.server()
+	defer ts.Close()
+	ds := dls.server()
+	defer ds.Close()
+
+	var tokenRequests int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		user, pass, _ := r.BasicAuth()
+		if user != "client" || pass != "s3cr3t" || r.FormValue("audience") != "target" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "oauth-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer idp.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+	t.Setenv("TEST_TARGET_PASSWORD", "pass")
+	t.Setenv("TEST_TARGET_CLIENT_SECRET", "s3cr3t")
+
+	client, err := cloudevents.NewClientHTTP(cloudevents.WithRoundTripper(withTargetAuth(nil)))
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	deliver := func(auth *cfgbroker.TargetAuth) {
+		t.Helper()
+		dlsURL := ds.URL
+		trigger := cfgbroker.Trigger{
+			Target: cfgbroker.Target{
+				URL:             &ts.URL,
+				Headers:         map[string]string{"X-Tenant": "acme"},
+				Auth:            auth,
+				DeliveryOptions: &cfgbroker.DeliveryOptions{DeadLetterURL: &dlsURL},
+			},
+		}
+		require.NoError(t, s.updateTrigger(trigger))
+
+		ev := lib.NewCloudEvent()
+		d := s.view()
+		require.NoError(t, d.deliverToTarget(d.ctx, &d.trigger.Target, &ev))
+		require.True(t, d.sendToDeadLetterSinks(s.parentCtx, &d.trigger.Target, &ev))
+	}
+
+	envPassword, clientSecret := "TEST_TARGET_PASSWORD", "TEST_TARGET_CLIENT_SECRET"
+
+	t.Run("bearer token from file", func(t *testing.T) {
+		deliver(&cfgbroker.TargetAuth{Bearer: &cfgbroker.BearerAuth{Token: cfgbroker.SecretSource{File: &tokenFile}}})
+		assert.Equal(t, "acme", target.get("X-Tenant"))
+		assert.Equal(t, "Bearer file-token", target.get("Authorization"))
+		assert.Empty(t, dls.get("X-Tenant"), "Dead letter sinks must not receive target headers")
+		assert.Empty(t, dls.get("Authorization"), "Dead letter sinks must not receive target credentials")
+
+		// Rotated tokens are read again.
+		require.NoError(t, os.WriteFile(tokenFile, []byte("rotated-token"), 0o600))
+		require.NoError(t, os.Chtimes(tokenFile, time.Now(), time.Now().Add(time.Second)))
+		ev := lib.NewCloudEvent()
+		d := s.view()
+		require.NoError(t, d.deliverToTarget(d.ctx, &d.trigger.Target, &ev))
+		assert.Equal(t, "Bearer rotated-token", target.get("Authorization"))
+	})
+
+	t.Run("basic auth from env", func(t *testing.T) {
+		deliver(&cfgbroker.TargetAuth{Basic: &cfgbroker.BasicAuth{
+			Username: "user",
+			Password: cfgbroker.SecretSource{Env: &envPassword},
+		}})
+		req := &http.Request{Header: http.Header{"Authorization": []string{target.get("Authorization")}}}
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+	})
+
+	t.Run("OAuth2 client credentials", func(t *testing.T) {
+		auth := &cfgbroker.TargetAuth{OAuth2: &cfgbroker.OAuth2Auth{
+			TokenURL:       idp.URL,
+			ClientID:       "client",
+			ClientSecret:   cfgbroker.SecretSource{Env: &clientSecret},
+			EndpointParams: map[string]string{"audience": "target"},
+		}}
+		deliver(auth)
+		assert.Equal(t, "Bearer oauth-token", target.get("Authorization"))
+
+		// Tokens are cached.
+		deliver(auth)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		missing := "TEST_TARGET_MISSING"
+		err := s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{
+			URL:  &ts.URL,
+			Auth: &cfgbroker.TargetAuth{Bearer: &cfgbroker.BearerAuth{Token: cfgbroker.SecretSource{Env: &missing}}},
+		}})
+		assert.ErrorContains(t, err, "environment variable TEST_TARGET_MISSING is not set")
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Content type for the CloudEvents batched content mode.
+	batchContentType = "application/cloudevents-batch+json"
+
+	// Default time events wait for a batch to be completed.
+	defaultBatchMaxLatency = time.Second
+)
+
+// batchSender delivers a batch of events to the target.
+type batchSender func(ctx context.Context, events []*cloudevents.Event) protocol.Result
+
+// batcher accumulates events until the batch is full or the maximum
+// latency expires, then sends them all at once.
+type batcher struct {
+	maxCount   int
+	maxLatency time.Duration
+	send       batchSender
+
+	// Batch accumulating events, nil if there are none.
+	current *pendingBatch
+	m       sync.Mutex
+}
+
+type pendingBatch struct {
+	// Context of the first event added to the batch.
+	ctx    context.Context
+	events []*cloudevents.Event
+	timer  *time.Timer
+
+	// done is closed when the result of the delivery is set.
+	done   chan struct{}
+	result protocol.Result
+}
+
+func newBatcher(cfg *cfgbroker.Batching, send batchSender) (*batcher, error) {
+	b := &batcher{
+		maxCount:   int(cfg.MaxCount),
+		maxLatency: defaultBatchMaxLatency,
+		send:       send,
+	}
+
+	if cfg.MaxLatency != nil {
+		p, err := period.Parse(*cfg.MaxLatency)
+		if err != nil {
+			return nil, fmt.Errorf("batching max latency cannot be parsed: %w", err)
+		}
+		b.maxLatency = p.DurationApprox()
+	}
+
+	return b, nil
+}
+
+// add appends the event to the current batch, blocking until the batch
+// is sent and returning its result.
+func (b *batcher) add(ctx context.Context, event *cloudevents.Event) protocol.Result {
+	b.m.Lock()
+	pb := b.current
+	if pb == nil {
+		pb = &pendingBatch{
+			ctx:  ctx,
+			done: make(chan struct{}),
+		}
+		pb.timer = time.AfterFunc(b.maxLatency, func() {
+			b.expire(pb)
+		})
+		b.current = pb
+	}
+
+	pb.events = append(pb.events, event)
+	full := len(pb.events) >= b.maxCount
+	if full {
+		b.current = nil
+	}
+	b.m.Unlock()
+
+	if full {
+		pb.timer.Stop()
+		b.deliver(pb)
+	}
+
+	<-pb.done
+	return pb.result
+}
+
+// expire sends the batch when the maximum latency is reached, unless it
+// was already sent because it was full.
+func (b *batcher) expire(pb *pendingBatch) {
+	b.m.Lock()
+	if b.current != pb {
+		b.m.Unlock()
+		return
+	}
+	b.current = nil
+	b.m.Unlock()
+
+	b.deliver(pb)
+}
+
+func (b *batcher) deliver(pb *pendingBatch) {
+	pb.result = b.send(pb.ctx, pb.events)
+	close(pb.done)
+}
+
+// sendBatch delivers the events to the target informed at the context in
+// a single request using the batched content mode.
+func sendBatch(ctx context.Context, client *http.Client, events []*cloudevents.Event) protocol.Result {
+	target := cloudevents.TargetFromContext(ctx)
+	if target == nil {
+		return errors.New("target URL is not informed")
+	}
+
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("could not serialize batch of events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create batch request: %w", err)
+	}
+	req.Header.Set("Content-Type", batchContentType)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Responses to batches are not produced to the broker.
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < http.StatusMultipleChoices {
+		return cehttp.NewResult(res.StatusCode, "%w", protocol.ResultACK)
+	}
+	return cehttp.NewResult(res.StatusCode, "%w", protocol.ResultNACK)
+}
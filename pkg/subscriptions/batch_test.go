@@ -0,0 +1,164 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestBatcher(t *testing.T) {
+	latency := "PT0.1S"
+	sent := make(chan []string, 2)
+	b, err := newBatcher(&cfgbroker.Batching{MaxCount: 3, MaxLatency: &latency},
+		func(_ context.Context, events []*cloudevents.Event) protocol.Result {
+			ids := []string{}
+			for _, e := range events {
+				ids = append(ids, e.ID())
+			}
+			sent <- ids
+			return protocol.ResultACK
+		})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"e1", "e2", "e3", "e4"} {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, protocol.IsACK(b.add(context.Background(), &ev)))
+		}()
+	}
+
+	// The first batch is sent when full, the second when the latency expires.
+	full := <-sent
+	assert.Len(t, full, 3)
+
+	start := time.Now()
+	partial := <-sent
+	assert.Len(t, partial, 1)
+	assert.NotContains(t, full, partial[0])
+	assert.WithinDuration(t, start.Add(100*time.Millisecond), time.Now(), 90*time.Millisecond)
+
+	wg.Wait()
+}
+
+func TestSendBatch(t *testing.T) {
+	status := http.StatusAccepted
+	var received []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, batchContentType, r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	e1 := lib.NewCloudEvent(lib.CloudEventWithIDOption("e1"))
+	e2 := lib.NewCloudEvent(lib.CloudEventWithIDOption("e2"))
+	ctx := cloudevents.ContextWithTarget(context.Background(), srv.URL)
+
+	res := sendBatch(ctx, srv.Client(), []*cloudevents.Event{&e1, &e2})
+	assert.True(t, protocol.IsACK(res))
+	require.Len(t, received, 2)
+	assert.Equal(t, "e1", received[0]["id"])
+	assert.Equal(t, "1.0", received[1]["specversion"])
+
+	status = http.StatusInternalServerError
+	res = sendBatch(ctx, srv.Client(), []*cloudevents.Event{&e1})
+	assert.True(t, protocol.IsNACK(res))
+}
+
+func TestBatchedDispatch(t *testing.T) {
+	atLeastOnce := cfgbroker.DeliveryGuaranteeAtLeastOnce
+
+	tcs := map[string]struct {
+		guarantee *cfgbroker.DeliveryGuaranteeType
+		ordering  *cfgbroker.Ordering
+		// Dispatching returns before the batch is sent.
+		async bool
+	}{
+		"acknowledged once batched": {
+			async: true,
+		},
+		"at least once waits for the batch": {
+			guarantee: &atLeastOnce,
+		},
+		"ordering waits for the batch": {
+			ordering: &cfgbroker.Ordering{},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			batches := make(chan int, 3)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var events []json.RawMessage
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&events))
+				batches <- len(events)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+			require.NoError(t, err)
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+
+			s := subscriber{
+				name:      "test-subscriber",
+				reporter:  r,
+				ceClient:  client,
+				inFlight:  &inFlight{},
+				parentCtx: context.Background(),
+				logger:    zap.NewNop().Sugar(),
+			}
+
+			latency := "PT0.2S"
+			require.NoError(t, s.updateTrigger(cfgbroker.Trigger{
+				Target:            cfgbroker.Target{URL: &srv.URL},
+				Batching:          &cfgbroker.Batching{MaxCount: 3, MaxLatency: &latency},
+				DeliveryGuarantee: tc.guarantee,
+				Ordering:          tc.ordering,
+			}))
+
+			// Events are dispatched one at a time, as the memory backend does.
+			start := time.Now()
+			for _, id := range []string{"e1", "e2", "e3"} {
+				ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+				require.NoError(t, s.dispatchCloudEvent(&ev))
+				if !tc.async {
+					break
+				}
+			}
+
+			if !tc.async {
+				assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "Dispatch must wait for the batch")
+				assert.Equal(t, 1, <-batches)
+				return
+			}
+
+			assert.Less(t, time.Since(start), 200*time.Millisecond, "Dispatch must not wait for the batch")
+			assert.Equal(t, 3, <-batches, "Events dispatched one at a time must complete the batch")
+
+			_, err = s.inFlight.wait(context.Background())
+			assert.NoError(t, err)
+		})
+	}
+}
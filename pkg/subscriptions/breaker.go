@@ -0,0 +1,116 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Default time the circuit stays open.
+const defaultCircuitCoolDown = 30 * time.Second
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half-open"
+)
+
+// circuitBreaker tracks consecutive delivery failures to a target.
+type circuitBreaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// trial is set while the single delivery allowed in half-open
+	// state is in progress.
+	trial bool
+
+	m sync.Mutex
+}
+
+func newCircuitBreaker(cfg *cfgbroker.CircuitBreaker) (*circuitBreaker, error) {
+	cb := &circuitBreaker{
+		threshold: int(cfg.FailureThreshold),
+		coolDown:  defaultCircuitCoolDown,
+		state:     circuitClosed,
+	}
+
+	if cfg.CoolDown != nil {
+		p, err := period.Parse(*cfg.CoolDown)
+		if err != nil {
+			return nil, fmt.Errorf("circuit breaker cool down cannot be parsed: %w", err)
+		}
+		cb.coolDown = p.DurationApprox()
+	}
+
+	return cb, nil
+}
+
+// allow returns true if the event can be sent to the target.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if now.Sub(cb.openedAt) < cb.coolDown {
+			return false
+		}
+		cb.state = circuitHalfOpen
+		cb.trial = true
+		return true
+
+	case circuitHalfOpen:
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	}
+
+	return true
+}
+
+// record informs the outcome of a delivery to the target, returning the new
+// state if the circuit was opened or closed.
+func (cb *circuitBreaker) record(err error, now time.Time) (circuitState, bool) {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+
+	cb.trial = false
+
+	if err == nil {
+		cb.failures = 0
+		if cb.state == circuitClosed {
+			return cb.state, false
+		}
+		cb.state = circuitClosed
+		return cb.state, true
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || (cb.state == circuitClosed && cb.failures >= cb.threshold) {
+		cb.state = circuitOpen
+		cb.openedAt = now
+		return cb.state, true
+	}
+
+	return cb.state, false
+}
+
+func (cb *circuitBreaker) isOpen() bool {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+	return cb.state != circuitClosed
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb, err := newCircuitBreaker(&cfgbroker.CircuitBreaker{FailureThreshold: 2, CoolDown: strPtr("PT10S")})
+	require.NoError(t, err)
+
+	now := time.Now()
+	errDelivery := errors.New("delivery failed")
+
+	assert.True(t, cb.allow(now))
+	_, changed := cb.record(errDelivery, now)
+	assert.False(t, changed)
+
+	state, changed := cb.record(errDelivery, now)
+	assert.True(t, changed)
+	assert.Equal(t, circuitOpen, state)
+	assert.False(t, cb.allow(now.Add(5*time.Second)), "circuit must stay open during the cool down")
+
+	// Only one trial is allowed after the cool down.
+	now = now.Add(11 * time.Second)
+	assert.True(t, cb.allow(now))
+	assert.False(t, cb.allow(now))
+
+	state, changed = cb.record(errDelivery, now)
+	assert.True(t, changed)
+	assert.Equal(t, circuitOpen, state, "failed trial must open the circuit again")
+	assert.False(t, cb.allow(now.Add(5*time.Second)))
+
+	now = now.Add(11 * time.Second)
+	assert.True(t, cb.allow(now))
+	state, changed = cb.record(nil, now)
+	assert.True(t, changed)
+	assert.Equal(t, circuitClosed, state)
+	assert.True(t, cb.allow(now))
+	assert.False(t, cb.isOpen())
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Default adaptive concurrency settings.
+const (
+	defaultConcurrencyInitialLimit = 10
+	defaultConcurrencyMinLimit     = 1
+	defaultConcurrencyMaxLimit     = 100
+	defaultConcurrencyBackoffRatio = 0.9
+)
+
+// concurrencyLimiter adapts the number of deliveries in flight to a target
+// using additive increase and multiplicative decrease.
+type concurrencyLimiter struct {
+	min       float64
+	max       float64
+	threshold time.Duration
+	ratio     float64
+
+	limit    float64
+	inFlight int
+	// Deliveries that started before the last decrease do not decrease
+	// the limit again, which would otherwise collapse when all deliveries
+	// in flight fail at once.
+	decreasedAt time.Time
+	// changed is closed when deliveries can be acquired again.
+	changed chan struct{}
+
+	m sync.Mutex
+}
+
+func newConcurrencyLimiter(cfg *cfgbroker.AdaptiveConcurrency) (*concurrencyLimiter, error) {
+	l := &concurrencyLimiter{
+		min:     defaultConcurrencyMinLimit,
+		max:     defaultConcurrencyMaxLimit,
+		ratio:   defaultConcurrencyBackoffRatio,
+		limit:   defaultConcurrencyInitialLimit,
+		changed: make(chan struct{}),
+	}
+
+	if cfg.MinLimit != nil {
+		l.min = float64(*cfg.MinLimit)
+	}
+	if cfg.MaxLimit != nil {
+		l.max = float64(*cfg.MaxLimit)
+	}
+	if l.max < l.min {
+		return nil, fmt.Errorf("adaptive concurrency maximum limit %v is lower than the minimum limit %v", l.max, l.min)
+	}
+	if cfg.InitialLimit != nil {
+		l.limit = float64(*cfg.InitialLimit)
+	}
+	l.limit = math.Min(l.max, math.Max(l.min, l.limit))
+
+	if cfg.BackoffRatio != nil {
+		l.ratio = *cfg.BackoffRatio
+	}
+
+	if cfg.LatencyThreshold != nil {
+		p, err := period.Parse(*cfg.LatencyThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("adaptive concurrency latency threshold cannot be parsed: %w", err)
+		}
+		l.threshold = p.DurationApprox()
+	}
+
+	return l, nil
+}
+
+// acquire waits until a delivery can be sent to the target.
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	for {
+		l.m.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.m.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.m.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release informs the outcome of a delivery started at the given time,
+// returning the new limit if its integer value changed. Failures that the
+// target could recover from, and deliveries slower than the threshold,
+// decrease the limit. Successful deliveries increase it by one for each
+// limit worth of deliveries, as long as they use at least half of it.
+func (l *concurrencyLimiter) release(err error, start, now time.Time) (int, bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	prev := int(l.limit)
+	utilized := l.inFlight*2 >= prev
+	l.inFlight--
+
+	switch {
+	case (err != nil && isRetriable(err)) || (l.threshold > 0 && now.Sub(start) > l.threshold):
+		if start.Before(l.decreasedAt) {
+			break
+		}
+		l.limit = math.Max(l.min, l.limit*l.ratio)
+		l.decreasedAt = now
+	case err == nil && utilized:
+		l.limit = math.Min(l.max, l.limit+1/l.limit)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+
+	return int(l.limit), int(l.limit) != prev
+}
+
+// currentLimit returns the integer value of the limit.
+func (l *concurrencyLimiter) currentLimit() int {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return int(l.limit)
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	initial, min, max := int32(2), int32(1), int32(4)
+	ratio := 0.5
+	l, err := newConcurrencyLimiter(&cfgbroker.AdaptiveConcurrency{
+		InitialLimit:     &initial,
+		MinLimit:         &min,
+		MaxLimit:         &max,
+		LatencyThreshold: strPtr("PT1S"),
+		BackoffRatio:     &ratio,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, l.acquire(ctx))
+	require.NoError(t, l.acquire(ctx))
+
+	// Deliveries beyond the limit wait for a delivery to finish.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, l.acquire(waitCtx), "acquire must wait while the limit is reached")
+
+	now := time.Now()
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(ctx) }()
+	_, changed := l.release(nil, now, now)
+	assert.False(t, changed, "limit must grow by one per limit worth of deliveries")
+	require.NoError(t, <-acquired)
+
+	_, changed = l.release(nil, now, now)
+	assert.False(t, changed)
+
+	require.NoError(t, l.acquire(ctx))
+	limit, changed := l.release(nil, now, now)
+	assert.True(t, changed)
+	assert.Equal(t, 3, limit)
+
+	// Slow deliveries decrease the limit, but only once for the
+	// deliveries that started before the decrease.
+	limit, changed = l.release(nil, now, now.Add(2*time.Second))
+	assert.True(t, changed)
+	assert.Equal(t, 1, limit)
+
+	require.NoError(t, l.acquire(ctx))
+	limit, changed = l.release(nil, now, now.Add(3*time.Second))
+	assert.False(t, changed)
+	assert.Equal(t, 1, limit)
+}
+
+func TestConcurrencyLimiterFailures(t *testing.T) {
+	l, err := newConcurrencyLimiter(&cfgbroker.AdaptiveConcurrency{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultConcurrencyInitialLimit, l.currentLimit())
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Failures the target could not recover from do not decrease the limit.
+	require.NoError(t, l.acquire(ctx))
+	_, changed := l.release(errors.New("rejected"), now, now)
+	assert.False(t, changed)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, l.acquire(ctx))
+	}
+	limit, changed := l.release(&url.Error{Op: "Post", Err: errors.New("connection refused")}, now, now.Add(time.Millisecond))
+	assert.True(t, changed)
+	assert.Equal(t, 9, limit)
+
+	limit, changed = l.release(&url.Error{Op: "Post", Err: errors.New("connection refused")}, now, now)
+	assert.False(t, changed, "deliveries started before a decrease must not decrease the limit again")
+	assert.Equal(t, 9, limit)
+}
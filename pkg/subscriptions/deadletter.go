@@ -0,0 +1,97 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+var backoffStrategies = map[cfgbroker.BackoffPolicyType]cecontext.BackoffStrategy{
+	cfgbroker.BackoffPolicyConstant:    cecontext.BackoffStrategyConstant,
+	cfgbroker.BackoffPolicyLinear:      cecontext.BackoffStrategyLinear,
+	cfgbroker.BackoffPolicyExponential: cecontext.BackoffStrategyExponential,
+}
+
+// sendToDeadLetterTarget sends the event to the dead letter target, timing
+// out each attempt and retrying as its delivery settings inform. It
+// returns true if the event was accepted.
+func (s delivery) sendToDeadLetterTarget(ctx context.Context, dlt *cfgbroker.DeadLetterTarget, event *cloudevents.Event) bool {
+	ctx = cloudevents.ContextWithTarget(ctx, dlt.URL)
+	if len(dlt.Headers) != 0 {
+		h := make(http.Header, len(dlt.Headers))
+		for k, v := range dlt.Headers {
+			h.Set(k, v)
+		}
+		ctx = cehttp.WithCustomHeader(ctx, h)
+	}
+
+	// Retries are managed here to time out each attempt.
+	rp := deadLetterRetryParams(dlt)
+	onceCtx := cecontext.WithRetryParams(ctx, &cecontext.RetryParams{Strategy: cecontext.BackoffStrategyNone})
+
+	timeout := parsePeriod(dlt.Timeout)
+	for tries := 0; ; tries++ {
+		actx, cancel := onceCtx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			actx, cancel = context.WithTimeout(onceCtx, timeout)
+		}
+		err := s.deliver(actx, event)
+		cancel()
+
+		if err == nil {
+			return true
+		}
+		if !isRetriable(err) {
+			return false
+		}
+		if tries >= rp.MaxTries {
+			s.debugw(ctx, "Dead letter target retries exhausted", zap.String("id", event.ID()), zap.Int("retries", tries))
+			return false
+		}
+		if rp.Period > 0 && rp.Backoff(ctx, tries+1) != nil {
+			return false
+		}
+	}
+}
+
+// deadLetterRetryParams returns the retry parameters for the dead letter
+// target, which defaults to the constant backoff policy.
+func deadLetterRetryParams(dlt *cfgbroker.DeadLetterTarget) *cecontext.RetryParams {
+	rp := &cecontext.RetryParams{
+		Strategy: cecontext.BackoffStrategyConstant,
+		Period:   parsePeriod(dlt.BackoffDelay),
+	}
+	if dlt.Retry != nil {
+		rp.MaxTries = int(*dlt.Retry)
+	}
+	if dlt.BackoffPolicy != nil {
+		if st, ok := backoffStrategies[*dlt.BackoffPolicy]; ok {
+			rp.Strategy = st
+		}
+	}
+	return rp
+}
+
+// parsePeriod returns the duration of the ISO8601 period, zero if it is not
+// informed or cannot be parsed, which validation prevents.
+func parsePeriod(p *string) time.Duration {
+	if p == nil {
+		return 0
+	}
+	d, err := period.Parse(*p)
+	if err != nil {
+		return 0
+	}
+	return d.DurationApprox()
+}
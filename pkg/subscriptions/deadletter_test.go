@@ -0,0 +1,122 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestSendToDeadLetterTarget(t *testing.T) {
+	var hits int32
+	var header atomic.Value
+	dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header.Store(r.Header.Get("X-Dls-Token"))
+		switch atomic.AddInt32(&hits, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			// Longer than the attempt timeout.
+			time.Sleep(500 * time.Millisecond)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer dls.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	retry := int32(2)
+	delay := "PT0.05S"
+	timeout := "PT0.1S"
+	dlt := &cfgbroker.DeadLetterTarget{
+		URL:          dls.URL,
+		Retry:        &retry,
+		BackoffDelay: &delay,
+		Timeout:      &timeout,
+		Headers:      map[string]string{"X-Dls-Token": "secret"},
+	}
+
+	ev := lib.NewCloudEvent()
+	assert.True(t, s.view().sendToDeadLetterTarget(context.Background(), dlt, &ev))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits), "Failed and timed out attempts must be retried")
+	assert.Equal(t, "secret", header.Load())
+
+	// Retries exhausted.
+	atomic.StoreInt32(&hits, 0)
+	none := int32(0)
+	dlt.Retry = &none
+	assert.False(t, s.view().sendToDeadLetterTarget(context.Background(), dlt, &ev))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestDeadLetterTargetValidate(t *testing.T) {
+	dlsURL := "http://dls"
+	negative := int32(-1)
+	badDelay := "5s"
+	badPolicy := cfgbroker.BackoffPolicyType("random")
+
+	tcs := map[string]struct {
+		do       cfgbroker.DeliveryOptions
+		expected string
+	}{
+		"valid": {
+			do: cfgbroker.DeliveryOptions{DeadLetterTarget: &cfgbroker.DeadLetterTarget{
+				URL:     "http://dls",
+				Headers: map[string]string{"Authorization": "Bearer x"},
+			}},
+		},
+		"dead letter URL and target": {
+			do: cfgbroker.DeliveryOptions{
+				DeadLetterURL:    &dlsURL,
+				DeadLetterTarget: &cfgbroker.DeadLetterTarget{URL: "http://dls"},
+			},
+			expected: "expected exactly one, got both",
+		},
+		"not valid target": {
+			do: cfgbroker.DeliveryOptions{DeadLetterTarget: &cfgbroker.DeadLetterTarget{
+				URL:           "dls",
+				Retry:         &negative,
+				BackoffPolicy: &badPolicy,
+				BackoffDelay:  &badDelay,
+				Timeout:       &badDelay,
+				Headers:       map[string]string{"Bad Header": "x"},
+			}},
+			expected: "deadLetterTarget.url",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.do.Validate(context.Background())
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// dlsFileMutex serializes writes to dead letter files, which might be
+// shared among triggers.
+var dlsFileMutex sync.Mutex
+
+// appendEventToFile writes the event as a JSON line at the end of the file,
+// creating it if it does not exist.
+func appendEventToFile(path string, event *cloudevents.Event) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("could not serialize CloudEvent: %w", err)
+	}
+
+	dlsFileMutex.Lock()
+	defer dlsFileMutex.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write to file: %w", err)
+	}
+
+	return f.Close()
+}
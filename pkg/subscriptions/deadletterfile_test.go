@@ -0,0 +1,139 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestDeadLetterSinks(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "dls.jsonl")
+	notWritable := filepath.Join(dir, "missing", "dls.jsonl")
+
+	tcs := map[string]struct {
+		sinks    []cfgbroker.DeadLetterSink
+		accepted bool
+		lines    int
+	}{
+		"no sinks": {},
+		"escalates to file": {
+			sinks:    []cfgbroker.DeadLetterSink{{URL: &failing.URL}, {File: &file}},
+			accepted: true,
+			lines:    1,
+		},
+		"stops at the first accepting sink": {
+			sinks:    []cfgbroker.DeadLetterSink{{File: &file}, {File: &file}},
+			accepted: true,
+			lines:    1,
+		},
+		"all sinks failing": {
+			sinks: []cfgbroker.DeadLetterSink{{URL: &failing.URL}, {File: &notWritable}},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			os.Remove(file)
+
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+			s := subscriber{
+				name:      "test-subscriber",
+				ceClient:  client,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			target := &cfgbroker.Target{DeliveryOptions: &cfgbroker.DeliveryOptions{DeadLetterSinks: tc.sinks}}
+			ev := lib.NewCloudEvent()
+			assert.Equal(t, tc.accepted, s.view().sendToDeadLetterSinks(context.Background(), target, &ev))
+			assert.Equal(t, tc.lines, countLines(t, file))
+		})
+	}
+}
+
+func TestAppendEventToFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dls.jsonl")
+
+	for _, id := range []string{"1", "2"} {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(id))
+		require.NoError(t, appendEventToFile(file, &ev))
+	}
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	ids := []string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ev := cloudevents.NewEvent()
+		require.NoError(t, ev.UnmarshalJSON(sc.Bytes()))
+		ids = append(ids, ev.ID())
+	}
+	assert.Equal(t, []string{"1", "2"}, ids, "Events must be appended as JSON lines")
+}
+
+func TestDeadLetterSinkValidate(t *testing.T) {
+	url, badURL, file, empty := "http://dls", "http://[bad", "/tmp/dls.jsonl", ""
+
+	tcs := map[string]struct {
+		dls      cfgbroker.DeadLetterSink
+		expected string
+	}{
+		"url":           {dls: cfgbroker.DeadLetterSink{URL: &url}},
+		"file":          {dls: cfgbroker.DeadLetterSink{File: &file}},
+		"url and file":  {dls: cfgbroker.DeadLetterSink{URL: &url, File: &file}, expected: "expected exactly one, got both"},
+		"none":          {dls: cfgbroker.DeadLetterSink{URL: &empty}, expected: "expected exactly one, got neither"},
+		"not valid url": {dls: cfgbroker.DeadLetterSink{URL: &badURL}, expected: "DLS URL cannot be parsed"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.dls.Validate(context.Background())
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	require.NoError(t, err)
+
+	n := 0
+	for _, c := range b {
+		if c == '\n' {
+			n++
+		}
+	}
+	return n
+}
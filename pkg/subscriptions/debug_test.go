@@ -0,0 +1,95 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestDebugEvents(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	tcs := map[string]struct {
+		honorDebug bool
+		flagged    bool
+		eventType  string
+		messages   []string
+	}{
+		"delivered debug event": {
+			honorDebug: true,
+			flagged:    true,
+			eventType:  "order.created",
+			messages:   []string{"Dispatching debug event", "Delivery audit record", "Event delivered to " + target.URL},
+		},
+		"filtered debug event": {
+			honorDebug: true,
+			flagged:    true,
+			eventType:  "order.deleted",
+			messages:   []string{"Dispatching debug event", "Skipped delivery due to filter"},
+		},
+		"debug not honored": {
+			flagged:   true,
+			eventType: "order.created",
+		},
+		"not a debug event": {
+			honorDebug: true,
+			eventType:  "order.created",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client, err := cloudevents.NewClientHTTP()
+			require.NoError(t, err)
+
+			core, logs := observer.New(zap.InfoLevel)
+			s := subscriber{
+				name:      "test-subscriber",
+				reporter:  r,
+				ceClient:  client,
+				debug:     tc.honorDebug,
+				parentCtx: context.Background(),
+				logger:    zap.New(core).Sugar(),
+			}
+
+			trigger := cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.created"}}},
+				Target:  cfgbroker.Target{URL: &target.URL},
+			}
+			require.NoError(t, s.updateTrigger(trigger))
+
+			event := lib.NewCloudEvent(lib.CloudEventWithTypeOption(tc.eventType))
+			if tc.flagged {
+				event.SetExtension(debug.Extension, true)
+			}
+			require.NoError(t, s.dispatchCloudEvent(&event))
+
+			var messages []string
+			for _, e := range logs.FilterField(zap.Bool("debug", true)).All() {
+				messages = append(messages, e.Message)
+			}
+			assert.Equal(t, tc.messages, messages)
+		})
+	}
+}
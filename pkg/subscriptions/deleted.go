@@ -0,0 +1,71 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// deletedTrigger is a trigger whose backend position is kept until its
+// deletion grace period expires.
+type deletedTrigger struct {
+	timer  *time.Timer
+	purged bool
+}
+
+// updateDeletedTriggers schedules purging the backend position of the
+// deleted triggers, and purges right away those deleted for good. The
+// manager lock must be held.
+func (m *Manager) updateDeletedTriggers(c *cfgbroker.Config) {
+	if m.purger == nil {
+		return
+	}
+
+	for name, d := range c.DeletedTriggers {
+		if _, ok := m.deleted[name]; ok {
+			continue
+		}
+
+		name := name
+		dt := &deletedTrigger{}
+		dt.timer = time.AfterFunc(time.Until(d.DeletedAt.Add(m.deletionGracePeriod)), func() {
+			m.m.Lock()
+			defer m.m.Unlock()
+			if m.deleted[name] == dt {
+				m.purge(name, dt)
+			}
+		})
+		m.deleted[name] = dt
+	}
+
+	for name, dt := range m.deleted {
+		if _, ok := c.DeletedTriggers[name]; ok {
+			continue
+		}
+
+		dt.timer.Stop()
+		delete(m.deleted, name)
+
+		// Restored triggers resume from the kept position.
+		if _, ok := c.Triggers[name]; !ok {
+			m.purge(name, dt)
+		}
+	}
+}
+
+func (m *Manager) purge(name string, dt *deletedTrigger) {
+	if dt.purged {
+		return
+	}
+	dt.purged = true
+
+	m.logger.Infow("Purging backend position of deleted trigger", zap.String("trigger", name))
+	if err := m.purger.PurgeSubscription(m.ctx, name); err != nil {
+		m.logger.Errorw("Could not purge backend position of deleted trigger", zap.String("trigger", name), zap.Error(err))
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakePurger struct {
+	purged []string
+	m      sync.Mutex
+}
+
+func (p *fakePurger) PurgeSubscription(_ context.Context, name string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.purged = append(p.purged, name)
+	return nil
+}
+
+func (p *fakePurger) get() []string {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return append([]string{}, p.purged...)
+}
+
+func TestDeletedTriggers(t *testing.T) {
+	p := &fakePurger{}
+	m, err := New(context.Background(), zaptest.NewLogger(t).Sugar(), nil, ManagerWithDeletedTriggers(p, time.Hour))
+	require.NoError(t, err)
+
+	update := func(c *cfgbroker.Config) {
+		m.m.Lock()
+		defer m.m.Unlock()
+		m.updateDeletedTriggers(c)
+	}
+
+	now := time.Now()
+	update(&cfgbroker.Config{DeletedTriggers: map[string]cfgbroker.DeletedTrigger{
+		// Expires right away.
+		"expired":  {DeletedAt: now.Add(-time.Hour)},
+		"restored": {DeletedAt: now},
+		"purged":   {DeletedAt: now},
+	}})
+	require.Eventually(t, func() bool { return len(p.get()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"expired"}, p.get())
+
+	// Restored triggers keep their position, triggers deleted for good do not.
+	update(&cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{"restored": {}},
+		DeletedTriggers: map[string]cfgbroker.DeletedTrigger{
+			"expired": {DeletedAt: now.Add(-time.Hour)},
+		},
+	})
+	assert.Equal(t, []string{"expired", "purged"}, p.get())
+
+	// Expired triggers are purged once.
+	update(&cfgbroker.Config{})
+	assert.Equal(t, []string{"expired", "purged"}, p.get())
+	assert.Empty(t, m.deleted)
+}
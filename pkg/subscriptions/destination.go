@@ -0,0 +1,68 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/destination"
+)
+
+// resolveDestination returns the context targeting the address of the
+// object the target references, resolved against the relative target URL
+// when informed. Events are targeted to none when the address cannot be
+// resolved.
+func (s delivery) resolveDestination(ctx context.Context, target *cfgbroker.Target) context.Context {
+	u, err := s.resolveReference(ctx, target.Ref, target.URL)
+	if err != nil {
+		s.logger.Errorw("Could not resolve the target reference", zap.String("trigger", s.name),
+			zap.String("ref", target.Ref.String()), zap.Error(err))
+		return cloudevents.ContextWithTarget(ctx, "")
+	}
+	return cloudevents.ContextWithTarget(ctx, u)
+}
+
+// resolveReference returns the address of the referenced object, resolved
+// against the relative URL when informed.
+func (s delivery) resolveReference(ctx context.Context, ref *cfgbroker.Reference, relative *string) (string, error) {
+	if s.resolver == nil {
+		return "", errors.New("no resolver is configured")
+	}
+
+	u, err := s.resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	if relative != nil && *relative != "" {
+		rel, err := url.Parse(*relative)
+		if err != nil {
+			return "", fmt.Errorf("relative URL cannot be parsed: %w", err)
+		}
+		u = u.ResolveReference(rel)
+	}
+
+	return u.String(), nil
+}
+
+// invalidateReference discards the address resolved for the reference when
+// the object could not be reached, so that it is resolved again for the
+// next delivery.
+func (s delivery) invalidateReference(ref *cfgbroker.Reference, err error) {
+	var uErr *url.Error
+	if ref == nil || !errors.As(err, &uErr) {
+		return
+	}
+
+	if i, ok := s.resolver.(destination.Invalidator); ok {
+		i.Invalidate(ref)
+	}
+}
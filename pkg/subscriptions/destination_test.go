@@ -0,0 +1,75 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/destination"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestTargetReference(t *testing.T) {
+	paths := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	// The first resolution returns an address that cannot be reached.
+	addresses := []string{"http://127.0.0.1:1", srv.URL}
+	resolved := 0
+	r := destination.NewCache(destination.ResolverFunc(func(ctx context.Context, ref *cfgbroker.Reference) (*url.URL, error) {
+		u, err := url.Parse(addresses[resolved])
+		resolved++
+		return u, err
+	}), 0)
+
+	rep, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  rep,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: strPtr("/events"),
+			Ref: &cfgbroker.Reference{Kind: "Service", Name: "display"},
+		},
+	}
+	assert.Error(t, s.updateTrigger(trigger), "Triggers referencing objects require a resolver")
+
+	s.resolver = r
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	assert.Empty(t, paths)
+
+	// Targets that cannot be reached are resolved again.
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	assert.Equal(t, []string{"/events", "/events"}, paths)
+	assert.Equal(t, 2, resolved, "Reachable addresses must be kept")
+}
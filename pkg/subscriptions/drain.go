@@ -0,0 +1,92 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// inFlight counts the events being dispatched by the backend.
+type inFlight struct {
+	n int
+	// idle is closed when there are no events being dispatched, nil
+	// meaning idle before any dispatch.
+	idle chan struct{}
+	m    sync.Mutex
+}
+
+func (f *inFlight) begin() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n++
+}
+
+func (f *inFlight) end() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.n--
+	if f.n == 0 {
+		close(f.idle)
+	}
+}
+
+// wait blocks until no events are being dispatched or the context is done,
+// returning the number of events still being dispatched.
+func (f *inFlight) wait(ctx context.Context) (int, error) {
+	for {
+		f.m.Lock()
+		n, idle := f.n, f.idle
+		f.m.Unlock()
+
+		if n == 0 {
+			return 0, nil
+		}
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			f.m.Lock()
+			defer f.m.Unlock()
+			return f.n, ctx.Err()
+		}
+	}
+}
+
+// trackDispatch accounts the events dispatched to the subscriber as in
+// flight until the dispatch returns, informing its outcome to those
+// waiting for the event to be dispatched.
+func (m *Manager) trackDispatch(name string, dispatch backend.ConsumerDispatcher) backend.ConsumerDispatcher {
+	return func(event *cloudevents.Event) error {
+		m.inFlight.begin()
+		defer m.inFlight.end()
+
+		err := dispatch(event)
+		m.fanOut.dispatched(name, event, err)
+		return err
+	}
+}
+
+// Drain waits for the events being dispatched to be delivered, retried and
+// sent to dead letter sinks, until the context is done. The backend should
+// have stopped reading events before, otherwise new dispatches might keep
+// coming.
+func (m *Manager) Drain(ctx context.Context) error {
+	n, err := m.inFlight.wait(ctx)
+	if err != nil {
+		return fmt.Errorf("%d events were still being dispatched: %w", n, err)
+	}
+
+	return nil
+}
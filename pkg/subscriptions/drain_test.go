@@ -0,0 +1,63 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestDrain(t *testing.T) {
+	m := &Manager{}
+
+	// Nothing to wait for before any dispatch.
+	require.NoError(t, m.Drain(context.Background()))
+
+	release := make(chan struct{})
+	dispatched := make(chan struct{})
+	dispatch := m.trackDispatch("test", func(*cloudevents.Event) error {
+		dispatched <- struct{}{}
+		<-release
+		return nil
+	})
+
+	ev := lib.NewCloudEvent()
+	for i := 0; i < 2; i++ {
+		go dispatch(&ev)
+		<-dispatched
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := m.Drain(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 events were still being dispatched")
+
+	drained := make(chan error)
+	go func() {
+		drained <- m.Drain(context.Background())
+	}()
+
+	release <- struct{}{}
+	select {
+	case <-drained:
+		t.Fatal("Drain returned with an event still being dispatched")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after dispatches finished")
+	}
+}
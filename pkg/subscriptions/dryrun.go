@@ -0,0 +1,38 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/audit"
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	"github.com/triggermesh/brokers/pkg/common/provenance"
+)
+
+// auditDryRun records that the event matched the filters of the dry run
+// trigger, informing the target it would have been delivered to.
+func (s delivery) auditDryRun(ctx context.Context, event *cloudevents.Event) {
+	if s.auditSink == nil && !debug.FromContext(ctx) {
+		return
+	}
+
+	r := &audit.Record{
+		Time:        time.Now(),
+		EventID:     event.ID(),
+		EventSource: event.Source(),
+		EventType:   event.Type(),
+		Trigger:     s.name,
+		Outcome:     audit.OutcomeDryRun,
+		Provenance:  provenance.Chain(event),
+	}
+	if u := s.trigger.Target.URL; u != nil {
+		r.Target = *u
+	}
+
+	s.writeAudit(r)
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/audit"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type auditRecords struct {
+	records []*audit.Record
+	m       sync.Mutex
+}
+
+func (a *auditRecords) Write(r *audit.Record) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.records = append(a.records, r)
+}
+
+func TestDryRun(t *testing.T) {
+	var delivered int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	records := &auditRecords{}
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		auditSink: records,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.created"}}},
+		Target:  cfgbroker.Target{URL: &target.URL},
+		DryRun:  true,
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	matched := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"), lib.CloudEventWithTypeOption("order.created"))
+	filtered := lib.NewCloudEvent(lib.CloudEventWithIDOption("2"), lib.CloudEventWithTypeOption("order.deleted"))
+	require.NoError(t, s.dispatchCloudEvent(&matched))
+	require.NoError(t, s.dispatchCloudEvent(&filtered))
+
+	assert.Zero(t, atomic.LoadInt32(&delivered), "Dry run triggers must not deliver events")
+	require.Len(t, records.records, 1, "Only matched events must be recorded")
+	assert.Equal(t, "1", records.records[0].EventID)
+	assert.Equal(t, audit.OutcomeDryRun, records.records[0].Outcome)
+	assert.Equal(t, target.URL, records.records[0].Target)
+
+	ts := s.status()
+	assert.True(t, ts.DryRun)
+	assert.Equal(t, uint64(2), ts.Evaluated)
+	assert.Equal(t, uint64(1), ts.Matched)
+
+	// Enabling delivery delivers to the target.
+	trigger.DryRun = false
+	require.NoError(t, s.updateTrigger(trigger))
+	require.NoError(t, s.dispatchCloudEvent(&matched))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&delivered))
+}
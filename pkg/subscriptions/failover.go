@@ -0,0 +1,192 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/features"
+)
+
+// FeatureBrokerRetries retries all deliveries at the broker instead of at
+// the CloudEvents client, so that every attempt is audited, published to
+// the firehose and accounted by the target host limiter.
+const FeatureBrokerRetries = "broker-retries"
+
+func init() {
+	features.Register(features.Flag{
+		Name:        FeatureBrokerRetries,
+		Description: "Retry all deliveries at the broker, auditing each attempt.",
+	})
+}
+
+// Status codes retried by the CloudEvents HTTP protocol.
+var retriableStatusCodes = map[int]struct{}{
+	http.StatusNotFound:              {},
+	http.StatusRequestEntityTooLarge: {},
+	http.StatusTooEarly:              {},
+	http.StatusTooManyRequests:       {},
+	http.StatusBadGateway:            {},
+	http.StatusServiceUnavailable:    {},
+	http.StatusGatewayTimeout:        {},
+}
+
+// deliverToTarget sends the event to the target URL at the context. When
+// the target informs fallback URLs they are tried in order on each attempt,
+// backing off between attempts as configured by the delivery options, or as
+// informed by the target Retry-After header when honored. When the target
+// is load balanced each attempt might use a different endpoint. Retries
+// beyond the retry budget are not attempted. When retry states are kept, the
+// retries of events dispatched again after a restart resume their schedule.
+// When requests in flight to target hosts are limited, retries are also
+// managed here, so that the host is only held while each attempt is sent.
+func (s delivery) deliverToTarget(ctx context.Context, target *cfgbroker.Target, event *cloudevents.Event) error {
+	if len(target.FallbackURLs) == 0 && s.balancer == nil && !s.retryAfter && s.retryBudget == nil && s.retryStates == nil && s.requeueMinDelay == 0 &&
+		s.hostLimiter == nil && !s.features.Enabled(FeatureBrokerRetries) {
+		return s.deliver(ctx, event)
+	}
+
+	urls := append([]string{cloudevents.TargetFromContext(ctx).String()}, target.FallbackURLs...)
+
+	// Retries are managed here instead of by the CloudEvents client so
+	// that every attempt goes through all URLs, and the backoff can be
+	// overridden by the target.
+	rp := cecontext.RetriesFrom(ctx)
+	onceCtx := cecontext.WithRetryParams(ctx, &cecontext.RetryParams{Strategy: cecontext.BackoffStrategyNone})
+
+	if s.retryBudget != nil {
+		s.retryBudget.request(time.Now())
+	}
+
+	tries, err := s.resumeRetries(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	for ; ; tries++ {
+		actx := onceCtx
+		var ra *retryAfterCapture
+		if s.retryAfter {
+			actx, ra = withRetryAfterCapture(onceCtx)
+		}
+
+		var err error
+		retry := false
+		for i, u := range urls {
+			uctx := cloudevents.ContextWithTarget(actx, u)
+			if i == 0 {
+				err = s.deliverBalanced(uctx, event)
+			} else {
+				err = s.deliver(uctx, event)
+			}
+			if err == nil {
+				if i != 0 {
+					s.debugw(ctx, "Event delivered to fallback URL", zap.String("id", event.ID()), zap.String("url", u))
+				}
+				s.forgetRetries(ctx, tries, event)
+				return nil
+			}
+			retry = retry || isRetriable(err)
+		}
+
+		if !retry {
+			s.forgetRetries(ctx, tries, event)
+			return err
+		}
+		wait, berr := s.retryWait(rp, tries+1, ra)
+		if berr != nil {
+			s.forgetRetries(ctx, tries, event)
+			return err
+		}
+
+		// Long backoffs free the dispatcher, budgeting the retry when
+		// requeueing instead of when it is attempted. Ordered triggers
+		// wait to keep their order.
+		if s.requeueMinDelay > 0 && wait >= s.requeueMinDelay && s.trigger.Ordering == nil {
+			if s.retryShed(event) {
+				s.forgetRetries(ctx, tries, event)
+				return err
+			}
+			if s.requeueEvent(ctx, event, tries+1, time.Now().Add(wait)) {
+				s.forgetRetries(ctx, tries, event)
+				return errRequeued
+			}
+		}
+
+		// Retry states are kept when the context is done, so that the
+		// schedule is resumed if the event is dispatched again.
+		s.saveRetries(ctx, tries+1, time.Now().Add(wait), event)
+		if berr := sleepContext(ctx, wait); berr != nil {
+			return err
+		}
+		if s.retryShed(event) {
+			s.forgetRetries(ctx, tries+1, event)
+			return err
+		}
+	}
+}
+
+// retryShed returns true if the retry does not fit in the retry budget.
+func (s delivery) retryShed(event *cloudevents.Event) bool {
+	if s.retryBudget == nil || s.retryBudget.allowRetry(time.Now()) {
+		return false
+	}
+
+	s.reporter.ReportRetryShed()
+	s.logger.Warnw("Retry not attempted due to the exhausted retry budget", zap.String("trigger", s.name),
+		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+	return true
+}
+
+// deliverBalanced sends the event to one of the target endpoints when the
+// load balancer is configured.
+func (s delivery) deliverBalanced(ctx context.Context, event *cloudevents.Event) error {
+	if s.balancer == nil {
+		return s.deliver(ctx, event)
+	}
+
+	ep, err := s.balancer.pick(ctx, time.Now())
+	if err != nil {
+		// Let the HTTP client resolve the target when there are no
+		// known endpoints.
+		s.logger.Warnw("Could not resolve target endpoints", zap.String("trigger", s.name), zap.Error(err))
+		return s.deliver(ctx, event)
+	}
+
+	err = s.deliver(contextWithEndpoint(ctx, s.balancer.target.Host, ep), event)
+
+	// Only failures that could succeed at other endpoints count for ejection.
+	if s.balancer.record(ep, err != nil && isRetriable(err), time.Now()) {
+		s.logger.Warnw("Target endpoint ejected", zap.String("trigger", s.name),
+			zap.String("endpoint", ep.addr))
+	}
+
+	return err
+}
+
+// isRetriable returns whether a failed delivery should be attempted again.
+func isRetriable(err error) bool {
+	var uErr *url.Error
+	if errors.As(err, &uErr) {
+		return true
+	}
+
+	var httpResult *cehttp.Result
+	if errors.As(err, &httpResult) {
+		_, ok := retriableStatusCodes[httpResult.StatusCode]
+		return ok
+	}
+
+	return false
+}
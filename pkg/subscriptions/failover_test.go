@@ -0,0 +1,194 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/audit"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/features"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestDeliverToTargetFallbacks(t *testing.T) {
+	newServer := func(status *int32, hits *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(hits, 1)
+			w.WriteHeader(int(atomic.LoadInt32(status)))
+		}))
+	}
+
+	var primaryStatus, fallbackStatus int32 = http.StatusServiceUnavailable, http.StatusAccepted
+	var primaryHits, fallbackHits int32
+	primary := newServer(&primaryStatus, &primaryHits)
+	defer primary.Close()
+	fallback := newServer(&fallbackStatus, &fallbackHits)
+	defer fallback.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	retry := int32(2)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT0.1S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL:          &primary.URL,
+			FallbackURLs: []string{fallback.URL},
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	assert.NoError(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(1), primaryHits, "Primary URL must be tried once before failing over")
+	assert.Equal(t, int32(1), fallbackHits)
+
+	// Every attempt goes through all URLs.
+	atomic.StoreInt32(&fallbackStatus, http.StatusBadGateway)
+	assert.Error(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&fallbackHits))
+
+	// Permanent errors are not retried.
+	atomic.StoreInt32(&primaryStatus, http.StatusBadRequest)
+	atomic.StoreInt32(&fallbackStatus, http.StatusBadRequest)
+	assert.Error(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&fallbackHits))
+}
+
+func TestDeliverToTargetBrokerRetries(t *testing.T) {
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	flags := features.NewSet(zap.NewNop().Sugar())
+	records := &auditRecords{}
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		auditSink: records,
+		features:  flags,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	retry := int32(1)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT0.1S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &target.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	// The CloudEvents client retries within a single delivery.
+	ev := lib.NewCloudEvent()
+	require.NoError(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Len(t, records.records, 1)
+
+	// Broker retries deliver each attempt on its own.
+	flags.UpdateFromConfig(&cfgbroker.Config{Features: map[string]bool{FeatureBrokerRetries: true}})
+	require.NoError(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+	require.Len(t, records.records, 3)
+	assert.Equal(t, audit.OutcomeRejected, records.records[1].Outcome)
+	assert.Equal(t, audit.OutcomeDelivered, records.records[2].Outcome)
+}
+
+func TestDeliverToTargetHostLimiter(t *testing.T) {
+	var hits int32
+	failed := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			close(failed)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	limiter := newHostLimiter(0, 1)
+	s := subscriber{
+		name:        "test-subscriber",
+		ceClient:    client,
+		hostLimiter: limiter,
+		parentCtx:   context.Background(),
+		logger:      zaptest.NewLogger(t).Sugar(),
+	}
+
+	retry := int32(1)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT0.2S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &target.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	delivered := make(chan error)
+	ev := lib.NewCloudEvent()
+	go func() { delivered <- s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev) }()
+
+	// The target host is not held while backing off between attempts.
+	<-failed
+	host := strings.TrimPrefix(target.URL, "http://")
+	assert.Eventually(t, func() bool { return limiter.hostInFlight(host) == 0 },
+		100*time.Millisecond, 5*time.Millisecond, "Host must not be held while backing off")
+
+	require.NoError(t, <-delivered)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// dispatchWaiter collects the outcome of dispatching an event to the
+// triggers that were subscribed when tracking started.
+type dispatchWaiter struct {
+	pending map[string]struct{}
+	results map[string]error
+	// done is closed when no triggers are pending.
+	done chan struct{}
+}
+
+// fanOut tracks the dispatch of events to all triggers, indexed by the
+// event source and id.
+type fanOut struct {
+	waiters map[string][]*dispatchWaiter
+	// Number of waiters, which allows skipping events when none is.
+	count atomic.Int32
+	m     sync.Mutex
+}
+
+func fanOutKey(event *cloudevents.Event) string {
+	return event.Source() + "\x00" + event.ID()
+}
+
+func (f *fanOut) add(key string, w *dispatchWaiter) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.waiters == nil {
+		f.waiters = make(map[string][]*dispatchWaiter)
+	}
+	f.waiters[key] = append(f.waiters[key], w)
+	f.count.Add(1)
+}
+
+func (f *fanOut) remove(key string, w *dispatchWaiter) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	ws := f.waiters[key]
+	for i := range ws {
+		if ws[i] == w {
+			ws = append(ws[:i], ws[i+1:]...)
+			f.count.Add(-1)
+			break
+		}
+	}
+	if len(ws) == 0 {
+		delete(f.waiters, key)
+		return
+	}
+	f.waiters[key] = ws
+}
+
+// dispatched informs the outcome of dispatching the event to the trigger.
+// Only the first dispatch is accounted, events dispatched again after
+// failing keep the first error.
+func (f *fanOut) dispatched(trigger string, event *cloudevents.Event, err error) {
+	if f.count.Load() == 0 {
+		return
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	for _, w := range f.waiters[fanOutKey(event)] {
+		if _, ok := w.pending[trigger]; !ok {
+			continue
+		}
+		delete(w.pending, trigger)
+		w.results[trigger] = err
+		if len(w.pending) == 0 {
+			close(w.done)
+		}
+	}
+}
+
+// TrackDispatch starts tracking the dispatch of the event to the triggers
+// subscribed at this replica, which must happen before producing it. The
+// returned function waits until all of them dispatched the event or the
+// context is done, informing the outcome for each trigger, nil if it was
+// dispatched, and the triggers that did not dispatch it yet.
+//
+// Events are considered dispatched once delivered, sent to a dead letter
+// sink, or discarded by the trigger filters or conditions.
+func (m *Manager) TrackDispatch(event *cloudevents.Event) func(context.Context) (map[string]error, []string) {
+	m.m.RLock()
+	w := &dispatchWaiter{
+		pending: make(map[string]struct{}, len(m.subscribers)),
+		results: make(map[string]error, len(m.subscribers)),
+		done:    make(chan struct{}),
+	}
+	for name := range m.subscribers {
+		w.pending[name] = struct{}{}
+	}
+	m.m.RUnlock()
+
+	if len(w.pending) == 0 {
+		close(w.done)
+	}
+
+	key := fanOutKey(event)
+	m.fanOut.add(key, w)
+
+	return func(ctx context.Context) (map[string]error, []string) {
+		defer m.fanOut.remove(key, w)
+
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+		}
+
+		m.fanOut.m.Lock()
+		defer m.fanOut.m.Unlock()
+
+		results := make(map[string]error, len(w.results))
+		for name, err := range w.results {
+			results[name] = err
+		}
+		pending := make([]string, 0, len(w.pending))
+		for name := range w.pending {
+			pending = append(pending, name)
+		}
+		sort.Strings(pending)
+
+		return results, pending
+	}
+}
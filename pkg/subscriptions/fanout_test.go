@@ -0,0 +1,47 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestTrackDispatch(t *testing.T) {
+	m := &Manager{subscribers: map[string]*subscriber{"t1": {}, "t2": {}, "t3": {}}}
+
+	ok := m.trackDispatch("t1", func(*cloudevents.Event) error { return nil })
+	failed := m.trackDispatch("t2", func(*cloudevents.Event) error { return errNotDelivered })
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"))
+	other := lib.NewCloudEvent(lib.CloudEventWithIDOption("2"))
+
+	wait := m.TrackDispatch(&ev)
+	_ = ok(&other)
+	_ = ok(&ev)
+	_ = failed(&ev)
+	// Only the first outcome is informed.
+	_ = ok(&ev)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results, pending := wait(ctx)
+	assert.Equal(t, map[string]error{"t1": nil, "t2": errNotDelivered}, results)
+	assert.Equal(t, []string{"t3"}, pending)
+	assert.Zero(t, m.fanOut.count.Load(), "Waiters must be removed once done")
+
+	// Waits finish once all triggers dispatched the event.
+	m.subscribers = map[string]*subscriber{"t1": {}}
+	wait = m.TrackDispatch(&ev)
+	go func() { _ = ok(&ev) }()
+	results, pending = wait(context.Background())
+	assert.Equal(t, map[string]error{"t1": nil}, results)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"knative.dev/eventing/pkg/eventfilter"
+	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/wasm"
+)
+
+// NewFilter returns the filter that matches events as a Trigger with the
+// informed filters does, or an error if any of them cannot be compiled.
+func NewFilter(ctx context.Context, filters []cfgbroker.Filter) (eventfilter.Filter, error) {
+	if errs := compileFilters(ctx, filters, "filters"); len(errs) != 0 {
+		return nil, fmt.Errorf("invalid filters: %s", strings.Join(errs, "; "))
+	}
+	return subscriptionsapi.NewAllFilter(materializeFiltersList(ctx, filters)...), nil
+}
+
+// compileFilters returns the errors found when compiling the filters, which
+// would cause them to be skipped when dispatching events. Each error informs
+// the path to the failing filter.
+func compileFilters(ctx context.Context, filters []cfgbroker.Filter, path string) []string {
+	ferrs := filterErrors(ctx, filters, path)
+	errs := make([]string, 0, len(ferrs))
+	for _, e := range ferrs {
+		errs = append(errs, e.Error())
+	}
+	return errs
+}
+
+// filterError is a filter that failed to compile.
+type filterError struct {
+	path string
+	err  error
+}
+
+func (e filterError) Error() string {
+	return fmt.Sprintf("%s: %v", e.path, e.err)
+}
+
+func filterErrors(ctx context.Context, filters []cfgbroker.Filter, path string) []filterError {
+	var errs []filterError
+	for i, f := range filters {
+		errs = append(errs, compileFilter(ctx, f, fmt.Sprintf("%s[%d]", path, i))...)
+	}
+	return errs
+}
+
+func compileFilter(ctx context.Context, filter cfgbroker.Filter, path string) []filterError {
+	var err error
+	switch {
+	case len(filter.Exact) > 0:
+		_, err = subscriptionsapi.NewExactFilter(filter.Exact)
+	case len(filter.Prefix) > 0:
+		_, err = subscriptionsapi.NewPrefixFilter(filter.Prefix)
+	case len(filter.Suffix) > 0:
+		_, err = subscriptionsapi.NewSuffixFilter(filter.Suffix)
+	case len(filter.All) > 0:
+		return filterErrors(ctx, filter.All, path+".all")
+	case len(filter.Any) > 0:
+		return filterErrors(ctx, filter.Any, path+".any")
+	case filter.Not != nil:
+		return compileFilter(ctx, *filter.Not, path+".not")
+	case filter.CESQL != "":
+		_, err = newCESQLFilter(filter.CESQL)
+	case filter.WASM != nil:
+		_, err = wasm.NewFilter(ctx, filter.WASM.Module)
+	}
+
+	if err != nil {
+		return []filterError{{path: path, err: err}}
+	}
+	return nil
+}
+
+// cesqlFilters caches the outcome of parsing CESQL expressions, which are
+// stateless once parsed and can be shared by all triggers using them.
+var cesqlFilters sync.Map
+
+type cesqlResult struct {
+	filter eventfilter.Filter
+	err    error
+}
+
+// newCESQLFilter returns the filter for the CESQL expression, parsing it
+// only the first time it is used.
+func newCESQLFilter(expr string) (eventfilter.Filter, error) {
+	if r, ok := cesqlFilters.Load(expr); ok {
+		return r.(cesqlResult).filter, r.(cesqlResult).err
+	}
+
+	f, err := parseCESQLFilter(expr)
+	cesqlFilters.Store(expr, cesqlResult{filter: f, err: err})
+	return f, err
+}
+
+// parseCESQLFilter parses the CESQL expression. The parser might panic when
+// reporting some syntax errors, which are recovered as errors.
+func parseCESQLFilter(expr string) (f eventfilter.Filter, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("error while parsing expression %s: %v", expr, r)
+		}
+	}()
+
+	return subscriptionsapi.NewCESQLFilter(expr)
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"knative.dev/eventing/pkg/eventfilter"
+	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestCESQLFilterCache(t *testing.T) {
+	f1, err := newCESQLFilter("type = 'cached.type'")
+	require.NoError(t, err)
+	f2, err := newCESQLFilter("type = 'cached.type'")
+	require.NoError(t, err)
+	assert.Same(t, f1, f2, "Expressions must be parsed once")
+
+	_, err = newCESQLFilter("type = = 'cached.type'")
+	assert.Error(t, err)
+	_, err = newCESQLFilter("type = = 'cached.type'")
+	assert.Error(t, err, "Parsing errors must be cached")
+}
+
+func TestTriggerFilterCompilation(t *testing.T) {
+	url := "http://test"
+	s := subscriber{
+		name:      "test-subscriber",
+		parentCtx: context.Background(),
+		logger:    zap.NewNop().Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "type1"}}},
+		Target:  cfgbroker.Target{URL: &url},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+	filter := s.view().filter
+
+	// Updates that do not change the filters keep the compiled filter.
+	trigger.Target.Headers = map[string]string{"X-Tenant": "a"}
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Equal(t, filter, s.view().filter)
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithTypeOption("type2"))
+	assert.Equal(t, eventfilter.FailFilter, s.view().filter.Filter(context.Background(), ev))
+
+	trigger.Filters = []cfgbroker.Filter{{Exact: map[string]string{"type": "type2"}}}
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Equal(t, eventfilter.PassFilter, s.view().filter.Filter(context.Background(), ev))
+}
+
+func TestFilterMatchRate(t *testing.T) {
+	d := deliveryStats{}
+	assert.Nil(t, d.status().MatchRate, "Match rate must not be informed before evaluating events")
+
+	d.recordFilter(true)
+	d.recordFilter(false)
+	d.recordFilter(false)
+	d.recordFilter(true)
+
+	ts := d.status()
+	assert.Equal(t, uint64(4), ts.Evaluated)
+	assert.Equal(t, uint64(2), ts.Matched)
+	require.NotNil(t, ts.MatchRate)
+	assert.Equal(t, 0.5, *ts.MatchRate)
+}
+
+// BenchmarkFilter compares building the filters for each event, as they
+// were before being compiled when applying the trigger, with evaluating
+// the compiled filter.
+func BenchmarkFilter(b *testing.B) {
+	filters := []cfgbroker.Filter{
+		{Prefix: map[string]string{"type": "com.example."}},
+		{Any: []cfgbroker.Filter{
+			{Exact: map[string]string{"source": "source1"}},
+			{CESQL: "priority > 5 AND region IN ('eu', 'us')"},
+		}},
+		{Not: &cfgbroker.Filter{Suffix: map[string]string{"subject": ".tmp"}}},
+	}
+
+	ctx := context.Background()
+	ev := lib.NewCloudEvent(
+		lib.CloudEventWithTypeOption("com.example.order"),
+		lib.CloudEventWithSourceOption("source2"),
+		lib.CloudEventWithExtensionOption("priority", "7"),
+		lib.CloudEventWithExtensionOption("region", "eu"))
+
+	b.Run("per event", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cesqlFilters.Delete("priority > 5 AND region IN ('eu', 'us')")
+			if subscriptionsapi.NewAllFilter(materializeFiltersList(ctx, filters)...).Filter(ctx, ev) != eventfilter.PassFilter {
+				b.Fatal("event must pass the filter")
+			}
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		f := subscriptionsapi.NewAllFilter(materializeFiltersList(ctx, filters)...)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if f.Filter(ctx, ev) != eventfilter.PassFilter {
+				b.Fatal("event must pass the filter")
+			}
+		}
+	})
+}
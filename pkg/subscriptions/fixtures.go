@@ -0,0 +1,124 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/fixtures"
+)
+
+type responseCaptureKey struct{}
+
+// responseCapture keeps the response of the target, if any.
+type responseCapture struct {
+	event *cloudevents.Event
+}
+
+func withResponseCapture(ctx context.Context) (context.Context, *responseCapture) {
+	c := &responseCapture{}
+	return context.WithValue(ctx, responseCaptureKey{}, c), c
+}
+
+// captureResponse keeps the target response when the context captures it.
+func captureResponse(ctx context.Context, response *cloudevents.Event) {
+	if c, ok := ctx.Value(responseCaptureKey{}).(*responseCapture); ok {
+		c.event = response
+	}
+}
+
+// handleFixture records the delivery of the event, or asserts it against
+// the recorded one, depending on the trigger fixtures mode.
+func (s delivery) handleFixture(ctx context.Context, event, response *cloudevents.Event) {
+	d := &fixtures.Delivery{
+		Target:   cloudevents.TargetFromContext(ctx).String(),
+		Event:    *event,
+		Response: response,
+	}
+
+	if s.trigger.Fixtures.Mode == cfgbroker.FixturesModeRecord {
+		b, err := json.Marshal(d)
+		if err != nil {
+			s.logger.Errorw("Could not serialize delivery fixture", zap.String("trigger", s.name),
+				zap.String("id", event.ID()), zap.Error(err))
+			return
+		}
+
+		if err := s.fixtureStore.RecordFixture(s.parentCtx, s.name, event.ID(), b); err != nil {
+			s.logger.Errorw("Could not record delivery fixture", zap.String("trigger", s.name),
+				zap.String("id", event.ID()), zap.Error(err))
+			return
+		}
+		s.reportFixture(fixtures.OutcomeRecorded)
+		return
+	}
+
+	recorded, ok := s.recordedFixture(event)
+	if !ok {
+		return
+	}
+	if recorded == nil {
+		s.logger.Warnw("Event delivered without recorded fixture", zap.String("trigger", s.name),
+			zap.String("id", event.ID()))
+		s.reportFixture(fixtures.OutcomeUnexpected)
+		return
+	}
+
+	if diffs := fixtures.Compare(recorded, d, s.trigger.Fixtures.IgnoreAttributes); len(diffs) != 0 {
+		s.logger.Warnw("Delivery does not match the recorded fixture", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Strings("differences", diffs))
+		s.reportFixture(fixtures.OutcomeMismatched)
+		return
+	}
+
+	s.debugw(ctx, "Delivery matches the recorded fixture", zap.String("id", event.ID()))
+	s.reportFixture(fixtures.OutcomeMatched)
+}
+
+// assertNotDelivered checks that no delivery was recorded for an event that
+// is not delivered to the target when asserting fixtures.
+func (s delivery) assertNotDelivered(ctx context.Context, event *cloudevents.Event) {
+	if s.trigger.Fixtures == nil || s.trigger.Fixtures.Mode != cfgbroker.FixturesModeAssert {
+		return
+	}
+
+	if recorded, ok := s.recordedFixture(event); ok && recorded != nil {
+		s.logger.Warnw("Event not delivered but a fixture was recorded", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.String("target", recorded.Target))
+		s.reportFixture(fixtures.OutcomeMissing)
+	}
+}
+
+// recordedFixture returns the delivery recorded for the event, nil if there
+// is none, and false if it could not be read.
+func (s *subscriber) recordedFixture(event *cloudevents.Event) (*fixtures.Delivery, bool) {
+	b, err := s.fixtureStore.Fixture(s.parentCtx, s.name, event.ID())
+	if err != nil {
+		s.logger.Errorw("Could not read delivery fixture", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+		return nil, false
+	}
+	if b == nil {
+		return nil, true
+	}
+
+	d := &fixtures.Delivery{}
+	if err := json.Unmarshal(b, d); err != nil {
+		s.logger.Errorw("Could not parse delivery fixture", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+		return nil, false
+	}
+
+	return d, true
+}
+
+func (s *subscriber) reportFixture(outcome fixtures.Outcome) {
+	s.reporter.ReportFixture(string(outcome))
+	s.stats.recordFixture(outcome)
+}
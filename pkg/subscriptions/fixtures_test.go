@@ -0,0 +1,85 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fixtureStore map[string][]byte
+
+func (f fixtureStore) RecordFixture(_ context.Context, trigger, key string, delivery []byte) error {
+	f[trigger+"/"+key] = delivery
+	return nil
+}
+
+func (f fixtureStore) Fixture(_ context.Context, trigger, key string) ([]byte, error) {
+	return f[trigger+"/"+key], nil
+}
+
+func TestFixtures(t *testing.T) {
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	store := fixtureStore{}
+	s := subscriber{
+		name:         "test-subscriber",
+		reporter:     r,
+		fixtureStore: store,
+		parentCtx:    context.Background(),
+		logger:       zaptest.NewLogger(t).Sugar(),
+	}
+
+	url := "http://target"
+	trigger := cfgbroker.Trigger{
+		Target:   cfgbroker.Target{URL: &url},
+		Fixtures: &cfgbroker.Fixtures{Mode: cfgbroker.FixturesModeRecord},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	e1 := lib.NewCloudEvent(lib.CloudEventWithIDOption("e1"))
+	e2 := lib.NewCloudEvent(lib.CloudEventWithIDOption("e2"))
+	response := lib.NewCloudEvent(lib.CloudEventWithIDOption("r1"))
+
+	s.view().handleFixture(s.view().ctx, &e1, &response)
+	assert.Contains(t, store, "test-subscriber/e1")
+
+	trigger.Fixtures = &cfgbroker.Fixtures{Mode: cfgbroker.FixturesModeAssert, IgnoreAttributes: []string{"time"}}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	// Replayed deliveries match regardless of the ignored attributes.
+	replayed := response.Clone()
+	replayed.SetTime(response.Time().Add(1))
+	s.view().handleFixture(s.view().ctx, &e1, &replayed)
+
+	// The target response changed.
+	replayed.SetID("r2")
+	s.view().handleFixture(s.view().ctx, &e1, &replayed)
+
+	// The event was routed to another target.
+	s.view().handleFixture(cloudevents.ContextWithTarget(s.view().ctx, "http://other"), &e1, &response)
+
+	// Events that were not recorded, or not delivered.
+	s.view().handleFixture(s.view().ctx, &e2, nil)
+	s.view().assertNotDelivered(s.view().ctx, &e1)
+	s.view().assertNotDelivered(s.view().ctx, &e2)
+
+	assert.Equal(t, map[string]uint64{
+		"recorded":   1,
+		"matched":    1,
+		"mismatched": 2,
+		"unexpected": 1,
+		"missing":    1,
+	}, s.status().Fixtures)
+}
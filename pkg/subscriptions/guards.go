@@ -0,0 +1,86 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// GuardReasonExtension informs dead letter sinks why the event did not
+// conform to the trigger guards.
+const GuardReasonExtension = "triggermeshguardreason"
+
+// Names of the guards reported at metrics.
+const (
+	guardMaxPayloadSize = "max_payload_size"
+	guardRequiredFields = "required_fields"
+)
+
+// checkGuards returns the guard the event does not conform to along with
+// the reason, empty if the event conforms to all guards.
+func checkGuards(g *cfgbroker.Guards, event *cloudevents.Event) (string, string) {
+	if g == nil {
+		return "", ""
+	}
+
+	data := event.Data()
+	if g.MaxPayloadSize != nil && len(data) > int(*g.MaxPayloadSize) {
+		return guardMaxPayloadSize, fmt.Sprintf("payload size %d exceeds the maximum of %d bytes", len(data), *g.MaxPayloadSize)
+	}
+
+	if len(g.RequiredFields) == 0 {
+		return "", ""
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return guardRequiredFields, "payload is not JSON"
+	}
+
+	for _, f := range g.RequiredFields {
+		if !hasField(payload, strings.Split(f, ".")) {
+			return guardRequiredFields, fmt.Sprintf("required field %s is missing", f)
+		}
+	}
+
+	return "", ""
+}
+
+// hasField returns true if the nested field is informed and not null.
+func hasField(v interface{}, path []string) bool {
+	for _, segment := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = obj[segment]; !ok {
+			return false
+		}
+	}
+
+	return v != nil
+}
+
+// divertGuarded sends the event that does not conform to the trigger guards
+// to the dead letter sinks, informing the reason at an extension.
+func (s delivery) divertGuarded(ctx, parentCtx context.Context, target *cfgbroker.Target, event *cloudevents.Event, reason string) {
+	s.debugw(ctx, "Skipped delivery due to guards", zap.String("id", event.ID()), zap.String("reason", reason))
+
+	diverted := event.Clone()
+	diverted.SetExtension(GuardReasonExtension, reason)
+	if s.sendToDeadLetterSinks(parentCtx, target, &diverted) {
+		return
+	}
+
+	s.logger.Errorw("Event was lost due to trigger guards", zap.Bool("lost", true), zap.String("reason", reason),
+		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestCheckGuards(t *testing.T) {
+	maxSize := int32(34)
+	guards := &cfgbroker.Guards{
+		MaxPayloadSize: &maxSize,
+		RequiredFields: []string{"order.id", "amount"},
+	}
+
+	testCases := map[string]struct {
+		data   string
+		guard  string
+		reason string
+	}{
+		"conforming": {
+			data: `{"order":{"id":"1"},"amount":0}`,
+		},
+		"too large": {
+			data:   `{"order":{"id":"1"},"amount":10000}`,
+			guard:  guardMaxPayloadSize,
+			reason: "payload size 35 exceeds the maximum of 34 bytes",
+		},
+		"missing nested field": {
+			data:   `{"order":{},"amount":1}`,
+			guard:  guardRequiredFields,
+			reason: "required field order.id is missing",
+		},
+		"null field": {
+			data:   `{"order":{"id":"1"},"amount":null}`,
+			guard:  guardRequiredFields,
+			reason: "required field amount is missing",
+		},
+		"not JSON": {
+			data:   `order=1`,
+			guard:  guardRequiredFields,
+			reason: "payload is not JSON",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ev := lib.NewCloudEvent(lib.CloudEventWithDataOption(cloudevents.ApplicationJSON, []byte(tc.data)))
+			guard, reason := checkGuards(guards, &ev)
+			assert.Equal(t, tc.guard, guard)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestDivertGuarded(t *testing.T) {
+	received := make(chan cloudevents.Event, 1)
+	dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev, err := cloudevents.NewEventFromHTTPRequest(r)
+		require.NoError(t, err)
+		received <- *ev
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer dls.Close()
+
+	client, err := cloudevents.NewClientHTTP(cehttp.WithClient(*dls.Client()))
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	target := &cfgbroker.Target{
+		DeliveryOptions: &cfgbroker.DeliveryOptions{DeadLetterURL: &dls.URL},
+	}
+	ev := lib.NewCloudEvent()
+	s.view().divertGuarded(context.Background(), context.Background(), target, &ev, "required field order.id is missing")
+
+	diverted := <-received
+	assert.Equal(t, ev.ID(), diverted.ID())
+	assert.Equal(t, "required field order.id is missing", diverted.Extensions()[GuardReasonExtension])
+	assert.NotContains(t, ev.Extensions(), GuardReasonExtension, "The dispatched event must not be modified")
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Points each replica is placed at on the ring, which evens out the share
+// of keys each replica receives.
+const virtualNodes = 160
+
+type ringPoint struct {
+	hash    uint64
+	replica int
+}
+
+// hashRing assigns keys to replicas using consistent hashing. The ring only
+// depends on the replica URLs, so that every broker instance sharing the
+// configuration assigns keys the same way.
+type hashRing struct {
+	replicas []string
+	points   []ringPoint
+}
+
+func newHashRing(replicas []string) *hashRing {
+	r := &hashRing{
+		replicas: replicas,
+		points:   make([]ringPoint, 0, len(replicas)*virtualNodes),
+	}
+
+	for i, u := range replicas {
+		for v := 0; v < virtualNodes; v++ {
+			r.points = append(r.points, ringPoint{
+				hash:    hashKey(u + "#" + strconv.Itoa(v)),
+				replica: i,
+			})
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+
+	return r
+}
+
+// get returns the replica that owns the key, which is the one at the first
+// point of the ring clockwise from the key hash.
+func (r *hashRing) get(key string) string {
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.replicas[r.points[i].replica]
+}
+
+// hashKey returns the FNV-1a hash of the key mixed with the splitmix64
+// finalizer, since FNV alone spreads similar keys poorly.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
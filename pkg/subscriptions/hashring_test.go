@@ -0,0 +1,44 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	replicas := []string{"http://r0", "http://r1", "http://r2"}
+	r := newHashRing(replicas)
+
+	const keys = 3000
+	owners := make(map[string]string, keys)
+	counts := map[string]int{}
+	for i := 0; i < keys; i++ {
+		k := "key-" + strconv.Itoa(i)
+		owners[k] = r.get(k)
+		counts[owners[k]]++
+	}
+
+	// Keys are spread across all replicas.
+	for _, u := range replicas {
+		assert.InDelta(t, keys/len(replicas), counts[u], keys/10, "keys owned by %s", u)
+	}
+
+	// The assignment only depends on the replicas.
+	assert.Equal(t, owners["key-1"], newHashRing(replicas).get("key-1"))
+
+	// Adding a replica only moves keys to the new replica.
+	grown := newHashRing(append(replicas, "http://r3"))
+	moved := 0
+	for k, owner := range owners {
+		if o := grown.get(k); o != owner {
+			assert.Equal(t, "http://r3", o)
+			moved++
+		}
+	}
+	assert.InDelta(t, keys/4, moved, keys/10)
+}
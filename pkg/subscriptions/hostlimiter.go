@@ -0,0 +1,102 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+)
+
+// hostLimiter caps the requests in flight to targets, in total and per
+// target host. It is shared by all triggers, so that triggers pointing at
+// the same service do not overload it together.
+type hostLimiter struct {
+	// Maximum requests in flight, in total and per host. Zero means
+	// unlimited.
+	max        int
+	maxPerHost int
+
+	inFlight int
+	hosts    map[string]int
+	// changed is closed when requests can be acquired again.
+	changed chan struct{}
+
+	// reporter is optional and records the requests in flight per host.
+	reporter metrics.HostReporter
+
+	m sync.Mutex
+}
+
+func newHostLimiter(max, maxPerHost int) *hostLimiter {
+	return &hostLimiter{
+		max:        max,
+		maxPerHost: maxPerHost,
+		hosts:      make(map[string]int),
+		changed:    make(chan struct{}),
+	}
+}
+
+// acquire waits until a request can be sent to the host.
+func (l *hostLimiter) acquire(ctx context.Context, host string) error {
+	for {
+		l.m.Lock()
+		if (l.max == 0 || l.inFlight < l.max) && (l.maxPerHost == 0 || l.hosts[host] < l.maxPerHost) {
+			l.inFlight++
+			l.hosts[host]++
+			l.report(host)
+			l.m.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.m.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release informs that a request to the host finished.
+func (l *hostLimiter) release(host string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.inFlight--
+	if l.hosts[host]--; l.hosts[host] == 0 {
+		delete(l.hosts, host)
+	}
+	l.report(host)
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// hostInFlight returns the requests in flight to the host.
+func (l *hostLimiter) hostInFlight(host string) int {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.hosts[host]
+}
+
+// report records the requests in flight to the host. The lock must be
+// held.
+func (l *hostLimiter) report(host string) {
+	if l.reporter != nil {
+		l.reporter.ReportHostInFlight(host, l.hosts[host])
+	}
+}
+
+// targetHost returns the host of the target URL at the context.
+func targetHost(ctx context.Context) string {
+	if u := cloudevents.TargetFromContext(ctx); u != nil {
+		return u.Host
+	}
+	return ""
+}
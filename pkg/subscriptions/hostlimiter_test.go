@@ -0,0 +1,48 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(3, 2)
+	ctx := context.Background()
+
+	require.NoError(t, l.acquire(ctx, "a:8080"))
+	require.NoError(t, l.acquire(ctx, "a:8080"))
+	assert.Equal(t, 2, l.hostInFlight("a:8080"))
+
+	// Requests beyond the host limit wait, while other hosts are not
+	// limited until the total limit is reached.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, l.acquire(waitCtx, "a:8080"), "acquire must wait while the host limit is reached")
+
+	require.NoError(t, l.acquire(ctx, "b:8080"))
+
+	waitCtx, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, l.acquire(waitCtx, "c:8080"), "acquire must wait while the total limit is reached")
+
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(ctx, "a:8080") }()
+	l.release("b:8080")
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire must wait for the host: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.release("a:8080")
+	require.NoError(t, <-acquired)
+	assert.Equal(t, 2, l.hostInFlight("a:8080"))
+	assert.Zero(t, l.hostInFlight("b:8080"))
+}
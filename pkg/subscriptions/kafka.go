@@ -0,0 +1,180 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// kafkaSender publishes messages to the target topic.
+type kafkaSender interface {
+	Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error
+	Close(ctx context.Context) error
+}
+
+// kafkaTarget publishes events to a Kafka topic. The producer connects to
+// the Kafka brokers on the first delivery, and again after failing to
+// connect, so that triggers can be configured while Kafka is unavailable.
+type kafkaTarget struct {
+	cfg cfgbroker.KafkaTarget
+
+	// Password read from a secret, along with the value the sender
+	// connected with, so that rotated passwords connect again.
+	password     *secret.Secret
+	saslPassword string
+
+	newSender func() (kafkaSender, error)
+	s         kafkaSender
+	m         sync.Mutex
+}
+
+func newKafkaTarget(cfg *cfgbroker.KafkaTarget, do *cfgbroker.DeliveryOptions) (*kafkaTarget, error) {
+	sc, err := saramaConfig(cfg, do)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &kafkaTarget{cfg: *cfg}
+	if cfg.SASL != nil && cfg.SASL.PasswordFrom != nil {
+		if k.password, err = secret.New(cfg.SASL.PasswordFrom); err != nil {
+			return nil, fmt.Errorf("SASL password: %w", err)
+		}
+	}
+
+	k.newSender = func() (kafkaSender, error) {
+		// The sender modifies the configuration, which is copied for
+		// each connection.
+		c := *sc
+		if k.password != nil {
+			v, err := k.password.Value()
+			if err != nil {
+				return nil, fmt.Errorf("could not read SASL password: %w", err)
+			}
+			c.Net.SASL.Password = v
+			k.saslPassword = v
+		}
+		return kafka_sarama.NewSender(k.cfg.Brokers, &c, k.cfg.Topic)
+	}
+
+	return k, nil
+}
+
+// saramaConfig returns the producer configuration for the target, which
+// retries using the delivery options.
+func saramaConfig(cfg *cfgbroker.KafkaTarget, do *cfgbroker.DeliveryOptions) (*sarama.Config, error) {
+	sc := sarama.NewConfig()
+	sc.Version = sarama.V2_0_0_0
+	sc.Producer.RequiredAcks = sarama.WaitForAll
+
+	if do != nil && do.Retry != nil {
+		sc.Producer.Retry.Max = int(*do.Retry)
+		if do.BackoffDelay != nil {
+			p, err := period.Parse(*do.BackoffDelay)
+			if err != nil {
+				return nil, fmt.Errorf("backoff delay cannot be parsed: %w", err)
+			}
+			sc.Producer.Retry.Backoff = p.DurationApprox()
+		}
+	}
+
+	if cfg.TLS {
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.SASL != nil {
+		sc.Net.SASL.Enable = true
+		sc.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		sc.Net.SASL.User = cfg.SASL.User
+		sc.Net.SASL.Password = cfg.SASL.Password
+		if cfg.SASL.PasswordFrom != nil {
+			// Settings are validated with a placeholder, the password
+			// is read from the secret when connecting.
+			sc.Net.SASL.Password = "-"
+		}
+	}
+
+	return sc, sc.Validate()
+}
+
+// url identifies the target at logs, audit records and status.
+func (k *kafkaTarget) url() string {
+	return "kafka://" + k.cfg.Brokers[0] + "/" + k.cfg.Topic
+}
+
+func (k *kafkaTarget) send(ctx context.Context, event *cloudevents.Event) error {
+	k.m.Lock()
+	if k.s != nil && k.password != nil {
+		if v, err := k.password.Value(); err == nil && v != k.saslPassword {
+			_ = k.s.Close(context.Background())
+			k.s = nil
+		}
+	}
+	if k.s == nil {
+		s, err := k.newSender()
+		if err != nil {
+			k.m.Unlock()
+			return fmt.Errorf("could not connect to Kafka: %w", err)
+		}
+		k.s = s
+	}
+	s := k.s
+	k.m.Unlock()
+
+	return s.Send(ctx, binding.ToMessage(event))
+}
+
+func (k *kafkaTarget) close() {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	if k.s != nil {
+		_ = k.s.Close(context.Background())
+		k.s = nil
+	}
+}
+
+// deliverToKafka publishes the event to the Kafka target, keyed by the
+// trigger ordering key when informed.
+func (s delivery) deliverToKafka(ctx context.Context, event *cloudevents.Event) error {
+	start := time.Now()
+
+	kctx := ctx
+	if key, ok := eventOrderingKey(s.trigger.Ordering, s.orderingExpression, event); ok {
+		kctx = kafka_sarama.WithMessageKey(ctx, sarama.StringEncoder(key))
+	}
+
+	err := s.kafka.send(kctx, event)
+
+	var result protocol.Result = protocol.ResultACK
+	if err != nil {
+		result = err
+	}
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
+
+	if err != nil {
+		s.logger.Errorw(fmt.Sprintf("Failed to publish event to %s", s.kafka.url()),
+			zap.Error(err), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return err
+	}
+
+	s.debugw(ctx, fmt.Sprintf("Event published to %s", s.kafka.url()), zap.String("id", event.ID()))
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestKafkaTarget(t *testing.T) {
+	dlsHits := 0
+	dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dlsHits++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer dls.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Ordering: &cfgbroker.Ordering{Key: "orderid"},
+		Target: cfgbroker.Target{
+			Kafka: &cfgbroker.KafkaTarget{Brokers: []string{"kafka:9092"}, Topic: "orders"},
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				DeadLetterURL: &dls.URL,
+			},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Equal(t, "kafka://kafka:9092/orders", cloudevents.TargetFromContext(s.view().ctx).String())
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	s.view().kafka.newSender = func() (kafkaSender, error) {
+		return kafka_sarama.NewSenderFromSyncProducer("orders", producer)
+	}
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithExtensionOption("orderid", "1234"))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(m *sarama.ProducerMessage) error {
+		assert.Equal(t, "orders", m.Topic)
+
+		key, err := m.Key.Encode()
+		require.NoError(t, err)
+		assert.Equal(t, "1234", string(key), "Messages are keyed by the ordering key")
+
+		headers := map[string]string{}
+		for _, h := range m.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		assert.Equal(t, ev.ID(), headers["ce_id"])
+		assert.Equal(t, ev.Type(), headers["ce_type"])
+		return nil
+	})
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	assert.Equal(t, 0, dlsHits)
+
+	// Events that cannot be published are sent to the dead letter sink.
+	producer.ExpectSendMessageAndFail(errors.New("not enough replicas"))
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	assert.Equal(t, 1, dlsHits)
+
+	// The producer is kept when the trigger configuration does not change.
+	sender := s.view().kafka
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Same(t, sender, s.view().kafka)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// leadership makes a single replica among those sharing the backend
+// dispatch all the triggers, while the others stand by to take over once
+// the leader lease expires.
+type leadership struct {
+	elector backend.LeaderElector
+	replica string
+	lease   time.Duration
+
+	// Whether the replica held the leader lease at the last renewal, and
+	// when it was last renewed. No trigger is owned until the replica is
+	// elected.
+	leading bool
+	renewed time.Time
+}
+
+// ManagerWithLeaderElection only dispatches the triggers while the replica
+// is elected leader at the elector.
+func ManagerWithLeaderElection(e backend.LeaderElector, replica string, lease time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.leadership = &leadership{
+			elector: e,
+			replica: replica,
+			lease:   lease,
+		}
+	}
+}
+
+// owned returns the triggers if the replica is the leader. The manager lock
+// must be held.
+func (l *leadership) owned(triggers map[string]cfgbroker.Trigger) map[string]cfgbroker.Trigger {
+	if l == nil || l.leading {
+		return triggers
+	}
+	return map[string]cfgbroker.Trigger{}
+}
+
+// startLeaderElection renews the leader lease each third of its duration,
+// releasing it once the context is done. It returns an error if the first
+// election fails.
+func (m *Manager) startLeaderElection(ctx context.Context) error {
+	if err := m.renewLeaderLease(ctx); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(m.leadership.lease / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			rctx, cancel := context.WithTimeout(context.Background(), releaseLeaseTimeout)
+			defer cancel()
+			if err := m.leadership.elector.ReleaseLeaderLease(rctx, m.leadership.replica); err != nil {
+				m.logger.Warnw("Could not release leader lease", zap.Error(err))
+			}
+			return nil
+
+		case <-t.C:
+			if err := m.renewLeaderLease(ctx); err != nil {
+				m.logger.Errorw("Could not renew leader lease", zap.Error(err))
+			}
+		}
+	}
+}
+
+// renewLeaderLease tries to acquire or renew the leader lease, dispatching
+// the triggers when elected and stopping when another replica is the
+// leader. A leader that cannot renew its lease keeps dispatching until the
+// lease is about to expire, stepping down before another replica might be
+// elected.
+func (m *Manager) renewLeaderLease(ctx context.Context) error {
+	now := time.Now()
+	leading, err := m.leadership.elector.AcquireLeaderLease(ctx, m.leadership.replica, m.leadership.lease)
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	if err != nil {
+		if m.leadership.leading && leaseExpired(m.leadership.renewed, now, m.leadership.lease) {
+			m.logger.Warnw("Leader lease expired, stepping down", zap.String("replica", m.leadership.replica))
+			m.setLeading(false)
+		}
+		return fmt.Errorf("could not renew leader lease: %w", err)
+	}
+
+	if leading {
+		m.leadership.renewed = now
+	}
+	if leading != m.leadership.leading {
+		if leading {
+			m.logger.Infow("Replica elected leader", zap.String("replica", m.leadership.replica))
+		} else {
+			m.logger.Infow("Replica is no longer the leader", zap.String("replica", m.leadership.replica))
+		}
+		m.setLeading(leading)
+	}
+
+	return nil
+}
+
+// setLeading applies the last configuration after the leadership of the
+// replica changes. The manager lock must be held.
+func (m *Manager) setLeading(leading bool) {
+	m.leadership.leading = leading
+	if m.config != nil {
+		m.updateFromConfig(m.config)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakeElector struct {
+	leader string
+	err    error
+	m      sync.Mutex
+}
+
+func (e *fakeElector) AcquireLeaderLease(_ context.Context, replica string, _ time.Duration) (bool, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.err != nil {
+		return false, e.err
+	}
+	if e.leader == "" {
+		e.leader = replica
+	}
+	return e.leader == replica, nil
+}
+
+func (e *fakeElector) ReleaseLeaderLease(_ context.Context, replica string) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if e.leader == replica {
+		e.leader = ""
+	}
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	ctx := context.Background()
+	e := &fakeElector{}
+
+	c := &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{}}
+	for i := 0; i < 5; i++ {
+		c.Triggers[fmt.Sprintf("trigger-%d", i)] = cfgbroker.Trigger{}
+	}
+
+	newManager := func(replica string, lease time.Duration) *Manager {
+		b := memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger)
+		m, err := New(ctx, logger, b, ManagerWithLeaderElection(e, replica, lease))
+		require.NoError(t, err)
+		m.UpdateFromConfig(c)
+		return m
+	}
+	owned := func(m *Manager) int {
+		m.m.RLock()
+		defer m.m.RUnlock()
+		return len(m.subscribers)
+	}
+
+	m1 := newManager("replica-1", 100*time.Millisecond)
+	m2 := newManager("replica-2", time.Minute)
+	assert.Zero(t, owned(m1), "Triggers must not be owned before the election")
+
+	require.NoError(t, m1.renewLeaderLease(ctx))
+	require.NoError(t, m2.renewLeaderLease(ctx))
+	assert.Equal(t, len(c.Triggers), owned(m1))
+	assert.Zero(t, owned(m2), "Standby replicas must not dispatch triggers")
+
+	// Leaders that cannot renew their lease step down before it expires.
+	e.err = errors.New("backend unavailable")
+	assert.Error(t, m1.renewLeaderLease(ctx))
+	assert.Equal(t, len(c.Triggers), owned(m1), "Leaders must keep dispatching while the lease is recent")
+	time.Sleep(50 * time.Millisecond)
+	assert.Error(t, m1.renewLeaderLease(ctx))
+	assert.Zero(t, owned(m1))
+
+	// Standby replicas take over once the leader releases the lease.
+	e.err = nil
+	require.NoError(t, e.ReleaseLeaderLease(ctx, "replica-1"))
+	require.NoError(t, m2.renewLeaderLease(ctx))
+	assert.Equal(t, len(c.Triggers), owned(m2))
+
+	require.NoError(t, m1.renewLeaderLease(ctx))
+	assert.Zero(t, owned(m1))
+}
@@ -0,0 +1,321 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rickb777/date/period"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Default time resolved endpoints are used.
+	defaultResolveInterval = 30 * time.Second
+	// Default number of consecutive failures that ejects an endpoint.
+	defaultEjectionThreshold = 3
+	// Default time an endpoint stays ejected.
+	defaultEjectionTime = 30 * time.Second
+)
+
+// resolver looks up the endpoints of a host.
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// endpoint is a host:port the target resolves to. Requests keep the target
+// URL, so that the Host header and the TLS server name are those of the
+// target, and are sent through a transport that dials the endpoint.
+type endpoint struct {
+	addr      string
+	transport *http.Transport
+
+	failures     int
+	ejectedUntil time.Time
+}
+
+type endpointKey struct{}
+
+// contextWithEndpoint sends the requests to the target host carried at the
+// context to the endpoint.
+func contextWithEndpoint(ctx context.Context, host string, ep *endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpointValue{host: host, ep: ep})
+}
+
+type endpointValue struct {
+	host string
+	ep   *endpoint
+}
+
+// endpointRoundTripper sends requests through the transport of the endpoint
+// informed at the request context. Requests to other hosts, like dead
+// letter sinks, use the base round tripper.
+type endpointRoundTripper struct {
+	base http.RoundTripper
+}
+
+func withEndpoint(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &endpointRoundTripper{base: rt}
+}
+
+func (rt *endpointRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	v, ok := req.Context().Value(endpointKey{}).(endpointValue)
+	if !ok || req.URL.Host != v.host {
+		return rt.base.RoundTrip(req)
+	}
+	return v.ep.transport.RoundTrip(req)
+}
+
+// loadBalancer picks the endpoints of the target URL host in round robin,
+// skipping those ejected after consecutive failures.
+type loadBalancer struct {
+	target     *url.URL
+	srvService string
+	// Transport the endpoint transports are cloned from.
+	base *http.Transport
+
+	resolveInterval   time.Duration
+	ejectionThreshold int
+	ejectionTime      time.Duration
+
+	resolver   resolver
+	endpoints  []*endpoint
+	resolvedAt time.Time
+	next       int
+
+	// m guards the endpoints, never held while resolving them.
+	m sync.Mutex
+}
+
+// newLoadBalancer creates the load balancer for the target URL, whose
+// endpoints are connected to using clones of the base transport.
+func newLoadBalancer(target string, cfg *cfgbroker.LoadBalancer, base *http.Transport) (*loadBalancer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("target URL cannot be parsed: %w", err)
+	}
+
+	lb := &loadBalancer{
+		target:            u,
+		base:              base,
+		resolveInterval:   defaultResolveInterval,
+		ejectionThreshold: defaultEjectionThreshold,
+		ejectionTime:      defaultEjectionTime,
+		resolver:          net.DefaultResolver,
+	}
+
+	if cfg.SRVService != nil {
+		lb.srvService = *cfg.SRVService
+	}
+
+	if cfg.ResolveInterval != nil {
+		p, err := period.Parse(*cfg.ResolveInterval)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer resolve interval cannot be parsed: %w", err)
+		}
+		lb.resolveInterval = p.DurationApprox()
+	}
+
+	if cfg.EjectionThreshold != nil {
+		lb.ejectionThreshold = int(*cfg.EjectionThreshold)
+	}
+
+	if cfg.EjectionTime != nil {
+		p, err := period.Parse(*cfg.EjectionTime)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer ejection time cannot be parsed: %w", err)
+		}
+		lb.ejectionTime = p.DurationApprox()
+	}
+
+	return lb, nil
+}
+
+// pick returns the endpoint for the next delivery. When every endpoint is
+// ejected they are all used, since failing deliveries are preferred over
+// not delivering at all.
+func (lb *loadBalancer) pick(ctx context.Context, now time.Time) (*endpoint, error) {
+	var err error
+	if lb.resolveDue(now) {
+		err = lb.resolve(ctx)
+	}
+
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	if len(lb.endpoints) == 0 {
+		if err == nil {
+			err = errors.New("endpoints of " + lb.target.Hostname() + " are being resolved")
+		}
+		return nil, err
+	}
+
+	for i := range lb.endpoints {
+		ep := lb.endpoints[(lb.next+i)%len(lb.endpoints)]
+		if !now.Before(ep.ejectedUntil) {
+			lb.next = (lb.next + i + 1) % len(lb.endpoints)
+			return ep, nil
+		}
+	}
+
+	ep := lb.endpoints[lb.next%len(lb.endpoints)]
+	lb.next = (lb.next + 1) % len(lb.endpoints)
+	return ep, nil
+}
+
+// record accounts for the delivery result, returning true if the endpoint
+// has just been ejected.
+func (lb *loadBalancer) record(ep *endpoint, failed bool, now time.Time) bool {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	if !failed {
+		ep.failures = 0
+		ep.ejectedUntil = time.Time{}
+		return false
+	}
+
+	ep.failures++
+	if ep.failures < lb.ejectionThreshold || now.Before(ep.ejectedUntil) {
+		return false
+	}
+
+	ep.failures = 0
+	ep.ejectedUntil = now.Add(lb.ejectionTime)
+	return true
+}
+
+// resolveDue returns true if the endpoints must be resolved, which only
+// one of the concurrent deliveries does while the rest use the known
+// endpoints.
+func (lb *loadBalancer) resolveDue(now time.Time) bool {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	if now.Sub(lb.resolvedAt) < lb.resolveInterval {
+		return false
+	}
+	// Do not keep retrying the lookup on every delivery.
+	lb.resolvedAt = now
+	return true
+}
+
+// resolve looks up the endpoints, keeping the failure accounting of those
+// still present. On failure the previous endpoints are kept.
+func (lb *loadBalancer) resolve(ctx context.Context) error {
+	hosts, err := lb.lookup(ctx)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return errors.New("no endpoints were resolved for " + lb.target.Hostname())
+	}
+
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	current := make(map[string]*endpoint, len(lb.endpoints))
+	for _, ep := range lb.endpoints {
+		current[ep.addr] = ep
+	}
+
+	endpoints := make([]*endpoint, 0, len(hosts))
+	for _, h := range hosts {
+		if ep, ok := current[h]; ok {
+			endpoints = append(endpoints, ep)
+			delete(current, h)
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{addr: h, transport: lb.endpointTransport(h)})
+	}
+	lb.endpoints = endpoints
+
+	// Requests in flight keep the connections they use.
+	for _, ep := range current {
+		ep.transport.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// endpointTransport returns a transport that connects to the address
+// regardless of the request host. Connections are pooled per endpoint.
+func (lb *loadBalancer) endpointTransport(addr string) *http.Transport {
+	base := lb.base
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+
+	dial := base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	t := base.Clone()
+	// Endpoints are connected to directly.
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+	return t
+}
+
+// close releases the idle connections to the endpoints.
+func (lb *loadBalancer) close() {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	for _, ep := range lb.endpoints {
+		ep.transport.CloseIdleConnections()
+	}
+}
+
+// lookup returns the host:port pairs the target resolves to.
+func (lb *loadBalancer) lookup(ctx context.Context) ([]string, error) {
+	if lb.srvService != "" {
+		_, srvs, err := lb.resolver.LookupSRV(ctx, lb.srvService, "tcp", lb.target.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("could not look up SRV records: %w", err)
+		}
+
+		hosts := make([]string, 0, len(srvs))
+		for _, srv := range srvs {
+			hosts = append(hosts, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+		}
+		return hosts, nil
+	}
+
+	addrs, err := lb.resolver.LookupHost(ctx, lb.target.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("could not look up host addresses: %w", err)
+	}
+
+	port := lb.target.Port()
+	if port == "" {
+		port = "80"
+		if lb.target.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	hosts := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		hosts = append(hosts, net.JoinHostPort(a, port))
+	}
+	return hosts, nil
+}
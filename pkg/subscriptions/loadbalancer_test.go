@@ -0,0 +1,179 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakeResolver struct {
+	addrs []string
+	srvs  []*net.SRV
+	err   error
+}
+
+func (f *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	return f.addrs, f.err
+}
+
+func (f *fakeResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return "_" + service + "._" + proto + "." + name, f.srvs, f.err
+}
+
+func TestLoadBalancer(t *testing.T) {
+	threshold := int32(2)
+	lb, err := newLoadBalancer("http://consumer.default.svc:8080/path", &cfgbroker.LoadBalancer{
+		EjectionThreshold: &threshold,
+		EjectionTime:      strPtr("PT10S"),
+	}, nil)
+	require.NoError(t, err)
+	res := &fakeResolver{addrs: []string{"10.0.0.1", "10.0.0.2"}}
+	lb.resolver = res
+
+	now := time.Now()
+	pick := func() *endpoint {
+		ep, err := lb.pick(context.Background(), now)
+		require.NoError(t, err)
+		return ep
+	}
+
+	ep1, ep2 := pick(), pick()
+	assert.Equal(t, "10.0.0.1:8080", ep1.addr)
+	assert.Equal(t, "10.0.0.2:8080", ep2.addr)
+	assert.Equal(t, ep1, pick(), "Endpoints must be picked in round robin")
+
+	assert.False(t, lb.record(ep1, true, now))
+	assert.True(t, lb.record(ep1, true, now), "Endpoint must be ejected after consecutive failures")
+	assert.Equal(t, ep2, pick())
+	assert.Equal(t, ep2, pick(), "Ejected endpoints must not be picked")
+
+	// When every endpoint is ejected all of them are used.
+	lb.record(ep2, true, now)
+	lb.record(ep2, true, now)
+	assert.NotEqual(t, pick(), pick())
+
+	// Ejected endpoints are picked again after the ejection time.
+	now = now.Add(11 * time.Second)
+	assert.ElementsMatch(t, []*endpoint{ep1, ep2}, []*endpoint{pick(), pick()})
+
+	// Failed lookups keep the known endpoints, which keep their state
+	// when resolved again.
+	now = now.Add(time.Minute)
+	res.err = errors.New("lookup failed")
+	assert.Contains(t, []*endpoint{ep1, ep2}, pick())
+
+	now = now.Add(time.Minute)
+	res.err = nil
+	res.addrs = []string{"10.0.0.2", "10.0.0.3"}
+	assert.ElementsMatch(t, []string{"10.0.0.2:8080", "10.0.0.3:8080"}, []string{pick().addr, pick().addr})
+	assert.Contains(t, lb.endpoints, ep2)
+}
+
+func TestLoadBalancerSRV(t *testing.T) {
+	lb, err := newLoadBalancer("https://consumer.default.svc", &cfgbroker.LoadBalancer{SRVService: strPtr("http")}, nil)
+	require.NoError(t, err)
+	lb.resolver = &fakeResolver{srvs: []*net.SRV{
+		{Target: "pod-0.consumer.default.svc.", Port: 8443},
+	}}
+
+	ep, err := lb.pick(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "pod-0.consumer.default.svc:8443", ep.addr)
+
+	lb, err = newLoadBalancer("http://consumer.default.svc", &cfgbroker.LoadBalancer{}, nil)
+	require.NoError(t, err)
+	lb.resolver = &fakeResolver{err: errors.New("lookup failed")}
+	_, err = lb.pick(context.Background(), time.Now())
+	assert.Error(t, err)
+}
+
+// blockingResolver looks up the addresses once released.
+type blockingResolver struct {
+	fakeResolver
+	release chan struct{}
+}
+
+func (b *blockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	<-b.release
+	return b.fakeResolver.LookupHost(ctx, host)
+}
+
+func TestLoadBalancerResolveUnlocked(t *testing.T) {
+	lb, err := newLoadBalancer("http://consumer.default.svc", &cfgbroker.LoadBalancer{}, nil)
+	require.NoError(t, err)
+	res := &blockingResolver{fakeResolver: fakeResolver{addrs: []string{"10.0.0.1"}}, release: make(chan struct{})}
+	lb.resolver = res
+
+	now := time.Now()
+	close(res.release)
+	_, err = lb.pick(context.Background(), now)
+	require.NoError(t, err)
+
+	// Deliveries use the known endpoints while they are resolved again.
+	res.release = make(chan struct{})
+	res.addrs = []string{"10.0.0.2"}
+	now = now.Add(time.Minute)
+	resolved := make(chan struct{})
+	go func() {
+		defer close(resolved)
+		_, _ = lb.pick(context.Background(), now)
+	}()
+
+	assert.Eventually(t, func() bool {
+		ep, err := lb.pick(context.Background(), now)
+		return err == nil && ep.addr == "10.0.0.1:80"
+	}, time.Second, 10*time.Millisecond)
+
+	close(res.release)
+	<-resolved
+	ep, err := lb.pick(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:80", ep.addr)
+}
+
+func TestLoadBalancerEndpointTransport(t *testing.T) {
+	hosts := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	target := "http://consumer.default.svc:" + port + "/path"
+
+	lb, err := newLoadBalancer(target, &cfgbroker.LoadBalancer{}, nil)
+	require.NoError(t, err)
+	lb.resolver = &fakeResolver{addrs: []string{"127.0.0.1"}}
+	defer lb.close()
+
+	ep, err := lb.pick(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	// The target URL is kept while the endpoint is dialed.
+	ctx := contextWithEndpoint(context.Background(), lb.target.Host, ep)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	require.NoError(t, err)
+	res, err := (&http.Client{Transport: withEndpoint(nil)}).Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, "consumer.default.svc:"+port, <-hosts)
+
+	// Requests to other hosts are not sent to the endpoint.
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, "http://deadletter.invalid", nil)
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: withEndpoint(nil)}).Do(req)
+	assert.Error(t, err)
+}
@@ -5,18 +5,28 @@ package subscriptions
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"reflect"
+	"sort"
 	"sync"
+	"time"
 
-	obshttp "github.com/cloudevents/sdk-go/observability/opencensus/v2/http"
-	ceclient "github.com/cloudevents/sdk-go/v2/client"
 	"go.uber.org/zap"
 
 	"knative.dev/pkg/logging"
 
+	"github.com/triggermesh/brokers/pkg/audit"
 	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/hops"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/destination"
+	"github.com/triggermesh/brokers/pkg/features"
+	"github.com/triggermesh/brokers/pkg/firehose"
+	"github.com/triggermesh/brokers/pkg/sla"
+	"github.com/triggermesh/brokers/pkg/stream"
 	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/pkg/throughput"
 )
 
 type Subscription struct {
@@ -29,23 +39,364 @@ type Manager struct {
 
 	backend backend.Interface
 
+	// Batching of the replies produced to the backend, disabled if size is
+	// not greater than one.
+	replyBatchSize  int
+	replyBatchDelay time.Duration
+	replies         *backend.ProduceBatcher
+
 	// Subscribers map indexed by name
 	subscribers map[string]*subscriber
 
+	// Verify events content hash before delivering.
+	integrity bool
+	// Path to the file where events that fail verifications are stored.
+	quarantinePath string
+
+	// Sink for delivery audit records.
+	auditSink audit.Sink
+
+	// Hub for streaming dispatch decisions.
+	firehose *firehose.Hub
+
+	// Recorder for hourly dispatch counters.
+	throughput *throughput.Recorder
+
+	// Reporter for per trigger delivery outcomes.
+	sla *sla.Reporter
+
+	// Store for the deliveries of triggers that use fixtures.
+	fixtureStore backend.FixtureStore
+
+	// Honor the debug extension of events.
+	debug bool
+
+	// Events older than this time to live are not delivered.
+	ttl time.Duration
+
+	// Honor the Retry-After header of target responses.
+	honorRetryAfter bool
+	maxRetryAfter   time.Duration
+
+	// Budget of the retries to targets, shared by all triggers, disabled
+	// if nil.
+	retryBudget *retryBudget
+	// Limiter of the requests in flight to target hosts shared by all
+	// subscribers, disabled if nil.
+	hostLimiter *hostLimiter
+	// Store of the retry state of events, which is not kept if nil.
+	retryStates backend.RetryStateStore
+	// Backoffs of at least this time requeue the event at the backend
+	// instead of waiting. Zero disables requeueing.
+	requeueMinDelay time.Duration
+	// Time deliveries of updated triggers wait for those in flight using
+	// the former filter or target. Zero does not wait.
+	updateDrainTimeout time.Duration
+
+	// Hub of the streams consumers connect to, stream targets are not
+	// applied if nil.
+	streams *stream.Hub
+
+	// Resolver of the objects targets reference.
+	resolver destination.Resolver
+
+	// Hops replies can do when the events they reply to do not inform
+	// them.
+	maxHops int32
+
+	// Connection settings for delivery, and the transport shared by
+	// targets that do not override them.
+	transportConfig HTTPTransportConfig
+	transport       *http.Transport
+
+	// Do not activate triggers whose filters fail to compile.
+	strictFilters bool
+	// Triggers not activated due to filter errors, indexed by name.
+	rejected map[string]rejectedTrigger
+
+	// Purger of the backend position of deleted triggers once their grace
+	// period expires, disabled if nil.
+	purger              backend.SubscriptionPurger
+	deletionGracePeriod time.Duration
+	deleted             map[string]*deletedTrigger
+
+	// Assignment of triggers to replicas, disabled if nil.
+	shards *shards
+	// Leaser of the triggers assigned to the replica when sharding, which
+	// are owned right away if nil.
+	triggerLeaser backend.TriggerLeaser
+	// Election of the replica that dispatches all triggers, disabled if
+	// nil.
+	leadership *leadership
+	// Last applied configuration, which is applied again when triggers
+	// are reassigned.
+	config *cfgbroker.Config
+
+	// Feature flags of the broker, at their default state if nil.
+	features *features.Set
+
+	// Events being dispatched, waited for when draining.
+	inFlight inFlight
+	// Events whose dispatch to all triggers is being waited for.
+	fanOut fanOut
+
 	ctx context.Context
 	m   sync.RWMutex
 }
 
-func New(inctx context.Context, logger *zap.SugaredLogger, be backend.Interface) (*Manager, error) {
+type ManagerOption func(*Manager)
+
+func New(inctx context.Context, logger *zap.SugaredLogger, be backend.Interface, opts ...ManagerOption) (*Manager, error) {
 	// Needed for Knative filters
 	ctx := logging.WithLogger(inctx, logger)
 
-	return &Manager{
-		backend:     be,
-		subscribers: make(map[string]*subscriber),
-		logger:      logger,
-		ctx:         ctx,
-	}, nil
+	m := &Manager{
+		backend:         be,
+		subscribers:     make(map[string]*subscriber),
+		rejected:        make(map[string]rejectedTrigger),
+		deleted:         make(map[string]*deletedTrigger),
+		transportConfig: DefaultHTTPTransportConfig(),
+		maxHops:         hops.DefaultMax,
+		logger:          logger,
+		ctx:             ctx,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.shards != nil {
+		m.shards.leaser = m.triggerLeaser
+	}
+
+	if m.hostLimiter != nil {
+		r, err := metrics.NewHostReporter(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create target host stats reporter: %w", err)
+		}
+		m.hostLimiter.reporter = r
+	}
+
+	m.transport = m.transportConfig.newTransport()
+	if m.replyBatchSize > 1 {
+		m.replies = backend.NewProduceBatcher(be, m.replyBatchSize, m.replyBatchDelay)
+	}
+
+	return m, nil
+}
+
+// ManagerWithIntegrity enables verification of the events content hash
+// before delivering them.
+func ManagerWithIntegrity(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.integrity = enabled
+	}
+}
+
+// ManagerWithAuditSink sets a sink that receives a record for
+// every delivery.
+func ManagerWithAuditSink(sink audit.Sink) ManagerOption {
+	return func(m *Manager) {
+		m.auditSink = sink
+	}
+}
+
+// ManagerWithFirehose publishes dispatch decisions and delivery outcomes
+// to the hub.
+func ManagerWithFirehose(hub *firehose.Hub) ManagerOption {
+	return func(m *Manager) {
+		m.firehose = hub
+	}
+}
+
+// ManagerWithThroughput counts dispatched events at the recorder.
+func ManagerWithThroughput(r *throughput.Recorder) ManagerOption {
+	return func(m *Manager) {
+		m.throughput = r
+	}
+}
+
+// ManagerWithSLAReporter counts delivery outcomes at the SLA reporter.
+func ManagerWithSLAReporter(r *sla.Reporter) ManagerOption {
+	return func(m *Manager) {
+		m.sla = r
+	}
+}
+
+// ManagerWithFeatures gates the experimental delivery behaviors with the
+// feature flags of the broker.
+func ManagerWithFeatures(fs *features.Set) ManagerOption {
+	return func(m *Manager) {
+		m.features = fs
+	}
+}
+
+// ManagerWithFixtureStore keeps the deliveries recorded by triggers that use
+// fixtures at the store.
+func ManagerWithFixtureStore(store backend.FixtureStore) ManagerOption {
+	return func(m *Manager) {
+		m.fixtureStore = store
+	}
+}
+
+// ManagerWithDebug honors the debug extension of events, which forces
+// tracing, verbose logging and auditing of their delivery.
+func ManagerWithDebug(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.debug = enabled
+	}
+}
+
+// ManagerWithEventTTL sets the time to live for events, after which they
+// are sent to the dead letter sinks instead of being delivered. Zero means
+// events only expire if they inform the TTL extension.
+func ManagerWithEventTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+// ManagerWithRetryAfter honors the Retry-After header of 429 and 503 target
+// responses, which overrides the computed backoff before retrying, waiting
+// up to the maximum. Zero means no maximum.
+func ManagerWithRetryAfter(enabled bool, max time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.honorRetryAfter = enabled
+		m.maxRetryAfter = max
+	}
+}
+
+// ManagerWithRetryBudget caps the fraction of the requests sent to targets
+// by all triggers that are retries. Retries beyond the budget are not
+// attempted, unless fewer than the minimum per second were. Zero disables the
+// budget.
+func ManagerWithRetryBudget(ratio float64, minRetriesPerSecond int) ManagerOption {
+	return func(m *Manager) {
+		if ratio > 0 {
+			m.retryBudget = newRetryBudget(ratio, minRetriesPerSecond)
+		}
+	}
+}
+
+// ManagerWithRetryStates keeps the retry state of events at the store, so
+// that events dispatched again after a restart resume their retries.
+func ManagerWithRetryStates(store backend.RetryStateStore) ManagerOption {
+	return func(m *Manager) {
+		m.retryStates = store
+	}
+}
+
+// ManagerWithRequeue produces events whose retry backoff is at least the
+// minimum delay back to the backend, to be delivered again to the trigger
+// once due, instead of waiting for the retry. Zero disables requeueing.
+func ManagerWithRequeue(minDelay time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.requeueMinDelay = minDelay
+	}
+}
+
+// ManagerWithUpdateDrain makes deliveries of triggers whose filter or
+// target changed wait up to the timeout for the deliveries in flight using
+// the former definition. Zero does not wait.
+func ManagerWithUpdateDrain(timeout time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.updateDrainTimeout = timeout
+	}
+}
+
+// ManagerWithHostConcurrency caps the requests in flight to targets by all
+// triggers, in total and per target host. Zero means unlimited.
+func ManagerWithHostConcurrency(maxInFlight, maxInFlightPerHost int) ManagerOption {
+	return func(m *Manager) {
+		if maxInFlight > 0 || maxInFlightPerHost > 0 {
+			m.hostLimiter = newHostLimiter(maxInFlight, maxInFlightPerHost)
+		}
+	}
+}
+
+// ManagerWithStreams sets the hub of the streams that stream targets push
+// events to. Triggers with stream targets are not applied if nil.
+func ManagerWithStreams(h *stream.Hub) ManagerOption {
+	return func(m *Manager) {
+		m.streams = h
+	}
+}
+
+// ManagerWithResolver sets the resolver of the Kubernetes objects that
+// targets reference. Triggers referencing objects are not applied if nil.
+func ManagerWithResolver(r destination.Resolver) ManagerOption {
+	return func(m *Manager) {
+		m.resolver = r
+	}
+}
+
+// ManagerWithMaxHops sets the hops that replies can do when the events they
+// reply to do not inform them. Zero keeps the default.
+func ManagerWithMaxHops(n int32) ManagerOption {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxHops = n
+		}
+	}
+}
+
+// ManagerWithHTTPTransport sets the connection settings used to deliver
+// events, which targets can override.
+func ManagerWithHTTPTransport(c HTTPTransportConfig) ManagerOption {
+	return func(m *Manager) {
+		m.transportConfig = c
+	}
+}
+
+// ManagerWithStrictFilters refuses to activate triggers whose filters fail
+// to compile. Updates to existing triggers with such filters are not applied.
+func ManagerWithStrictFilters(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.strictFilters = enabled
+	}
+}
+
+// ManagerWithQuarantinePath sets the file where events that fail
+// verifications are written. If empty those events are discarded.
+func ManagerWithQuarantinePath(path string) ManagerOption {
+	return func(m *Manager) {
+		m.quarantinePath = path
+	}
+}
+
+// ManagerWithReplyBatching produces the replies of targets to the backend
+// in batches of up to size events, waiting up to the delay for the batch to
+// fill. Sizes not greater than one disable batching.
+func ManagerWithReplyBatching(size int, delay time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.replyBatchSize = size
+		m.replyBatchDelay = delay
+	}
+}
+
+// ManagerWithDeletedTriggers purges the backend position of the triggers
+// deleted through the admin API when their grace period expires.
+func ManagerWithDeletedTriggers(p backend.SubscriptionPurger, gracePeriod time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.purger = p
+		m.deletionGracePeriod = gracePeriod
+	}
+}
+
+// replyProducer returns the producer for the replies of targets.
+func (m *Manager) replyProducer() backend.EventProducer {
+	if m.replies == nil {
+		return m.backend
+	}
+	return m.replies
+}
+
+// FlushReplies produces the replies waiting to be batched, producing any
+// later reply right away. It must be called before stopping the backend.
+func (m *Manager) FlushReplies() {
+	if m.replies != nil {
+		m.replies.Close()
+	}
 }
 
 func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
@@ -53,17 +404,59 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 	m.m.Lock()
 	defer m.m.Unlock()
 
+	m.config = c
+	m.updateFromConfig(c)
+}
+
+// Triggers returns the names of the configured triggers, including those
+// assigned to other replicas.
+func (m *Manager) Triggers() []string {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	if m.config == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(m.config.Triggers))
+	for name := range m.config.Triggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updateFromConfig applies the configuration. The manager lock must be
+// held.
+func (m *Manager) updateFromConfig(c *cfgbroker.Config) {
+	// Triggers assigned to other replicas, or dispatched by the leader
+	// replica, are not dispatched.
+	triggers := m.leadership.owned(m.shards.owned(c.Triggers))
+
 	for name, sub := range m.subscribers {
-		if _, ok := c.Triggers[name]; !ok {
+		if _, ok := triggers[name]; !ok {
 			m.logger.Infow("Deleting subscription", zap.String("name", name))
 			sub.unsubscribe()
 			delete(m.subscribers, name)
 		}
 	}
 
-	for name, trigger := range c.Triggers {
+	for name := range m.rejected {
+		if _, ok := triggers[name]; !ok {
+			delete(m.rejected, name)
+		}
+	}
+
+	m.updateDeletedTriggers(c)
+
+	for name, trigger := range triggers {
 		s, ok := m.subscribers[name]
 		if !ok {
+			if r, ok := m.rejected[name]; ok && reflect.DeepEqual(r.trigger, trigger) {
+				// If there are no changes to the rejected trigger, skip.
+				continue
+			}
+
 			// Create CloudEvents client with reporter for Trigger.
 			ir, err := metrics.NewReporter(m.ctx, name)
 			if err != nil {
@@ -71,24 +464,46 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 				continue
 			}
 
-			p, err := obshttp.NewObservedHTTP()
-			if err != nil {
-				m.logger.Errorw("Could not create CloudEvents HTTP protocol", zap.String("trigger", name), zap.Error(err))
+			ferrs := m.compileFilters(name, trigger, ir)
+			if len(ferrs) != 0 && m.strictFilters {
+				m.logger.Errorw("Trigger not activated due to filter errors", zap.String("trigger", name))
+				m.rejected[name] = rejectedTrigger{trigger: trigger, errors: ferrs}
 				continue
 			}
-
-			ceClient, err := ceclient.New(p, ceclient.WithObservabilityService(metrics.NewOpenCensusObservabilityService(ir)))
-			if err != nil {
-				m.logger.Errorw("Could not create CloudEvents HTTP client", zap.String("trigger", name), zap.Error(err))
-			}
+			delete(m.rejected, name)
 
 			s = &subscriber{
-				name:      name,
-				backend:   m.backend,
-				ceClient:  ceClient,
-				parentCtx: m.ctx,
-				logger:    m.logger,
+				name:               name,
+				backend:            m.backend,
+				replies:            m.replyProducer(),
+				transportConfig:    m.transportConfig,
+				sharedTransport:    m.transport,
+				reporter:           ir,
+				integrity:          m.integrity,
+				quarantinePath:     m.quarantinePath,
+				auditSink:          m.auditSink,
+				firehose:           m.firehose,
+				throughput:         m.throughput,
+				sla:                m.sla,
+				features:           m.features,
+				fixtureStore:       m.fixtureStore,
+				debug:              m.debug,
+				ttl:                m.ttl,
+				honorRetryAfter:    m.honorRetryAfter,
+				maxRetryAfter:      m.maxRetryAfter,
+				retryBudget:        m.retryBudget,
+				hostLimiter:        m.hostLimiter,
+				retryStates:        m.retryStates,
+				requeueMinDelay:    m.requeueMinDelay,
+				updateDrainTimeout: m.updateDrainTimeout,
+				streams:            m.streams,
+				resolver:           m.resolver,
+				maxHops:            m.maxHops,
+				inFlight:           &m.inFlight,
+				parentCtx:          m.ctx,
+				logger:             m.logger,
 			}
+			s.stats.setFilterErrors(ferrs)
 
 			m.logger.Infow("Creating new subscription from trigger configuration", zap.String("name", name), zap.Any("trigger", trigger))
 			if err := s.updateTrigger(trigger); err != nil {
@@ -96,8 +511,17 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 				continue
 			}
 
-			if err := m.backend.Subscribe(name, s.dispatchCloudEvent); err != nil {
+			opts := []backend.SubscribeOption{backend.SubscribeWithOrderingKey(s.orderingKey)}
+			if trigger.StartingOffset != nil {
+				opts = append(opts, backend.SubscribeWithStartingOffset(backend.StartingOffset(*trigger.StartingOffset)))
+			}
+			if trigger.Paused && m.pauser(name) != nil {
+				opts = append(opts, backend.SubscribeWithPaused(true))
+			}
+
+			if err := m.backend.Subscribe(name, m.trackDispatch(name, s.dispatchCloudEvent), opts...); err != nil {
 				m.logger.Errorw("Could not create subscription for trigger", zap.String("trigger", name), zap.Error(err))
+				s.close()
 				continue
 			}
 
@@ -106,16 +530,75 @@ func (m *Manager) UpdateFromConfig(c *cfgbroker.Config) {
 			continue
 		}
 
-		if reflect.DeepEqual(s.trigger, trigger) {
+		if reflect.DeepEqual(s.view().trigger, trigger) {
 			// If there are no changes to the subscription, skip.
 			continue
 		}
 
+		ferrs := m.compileFilters(name, trigger, s.reporter)
+		s.stats.setFilterErrors(ferrs)
+		if len(ferrs) != 0 && m.strictFilters {
+			m.logger.Errorw("Trigger update not applied due to filter errors", zap.String("trigger", name))
+			continue
+		}
+
 		// Update existing subscription with new data.
 		m.logger.Infow("Updating subscription upon trigger configuration", zap.String("name", name), zap.Any("trigger", trigger))
+		paused := s.view().trigger.Paused
 		if err := s.updateTrigger(trigger); err != nil {
 			m.logger.Errorw("Could not setup trigger", zap.String("name", name), zap.Error(err))
 			return
 		}
+
+		if trigger.Paused != paused {
+			m.setPaused(name, trigger.Paused)
+		}
+	}
+}
+
+// pauser returns the backend as a subscription pauser, nil if the backend
+// cannot pause subscriptions, in which case the trigger is not paused.
+func (m *Manager) pauser(name string) backend.SubscriptionPauser {
+	p, ok := m.backend.(backend.SubscriptionPauser)
+	if !ok {
+		m.logger.Errorw("Backend cannot pause subscriptions, trigger events are dispatched", zap.String("trigger", name))
+		return nil
+	}
+	return p
+}
+
+// setPaused pauses or resumes dispatching events to the trigger.
+func (m *Manager) setPaused(name string, paused bool) {
+	p := m.pauser(name)
+	if p == nil {
+		return
+	}
+
+	var err error
+	if paused {
+		err = p.PauseSubscription(name)
+	} else {
+		err = p.ResumeSubscription(name)
+	}
+	if err != nil {
+		m.logger.Errorw("Could not change trigger pause", zap.String("trigger", name), zap.Bool("paused", paused), zap.Error(err))
+		return
+	}
+	m.logger.Infow("Trigger pause changed", zap.String("trigger", name), zap.Bool("paused", paused))
+}
+
+// rejectedTrigger is a trigger that was not activated.
+type rejectedTrigger struct {
+	trigger cfgbroker.Trigger
+	errors  []string
+}
+
+// compileFilters checks the trigger filters, reporting the errors found.
+func (m *Manager) compileFilters(name string, trigger cfgbroker.Trigger, r metrics.Reporter) []string {
+	errs := compileFilters(m.ctx, trigger.Filters, "filters")
+	if len(errs) != 0 {
+		r.ReportFilterCompileErrors(len(errs))
+		m.logger.Errorw("Trigger filters failed to compile", zap.String("trigger", name), zap.Strings("errors", errs))
 	}
+	return errs
 }
@@ -16,6 +16,8 @@ import (
 	"github.com/cloudevents/sdk-go/v2/observability"
 
 	"github.com/cloudevents/sdk-go/v2/protocol"
+
+	"github.com/triggermesh/brokers/pkg/common/debug"
 )
 
 type opencensusObservabilityService struct {
@@ -48,7 +50,13 @@ func (o opencensusObservabilityService) RecordSendingEvent(ctx context.Context,
 
 func (o opencensusObservabilityService) RecordRequestEvent(ctx context.Context, sentEvent cloudevents.Event) (context.Context, func(errOrResult error, event *cloudevents.Event)) {
 	start := time.Now()
-	ctx, span := trace.StartSpan(ctx, observability.ClientSpanName, trace.WithSpanKind(trace.SpanKindClient))
+	opts := []trace.StartOption{trace.WithSpanKind(trace.SpanKindClient)}
+	// Events flagged for debugging are always traced.
+	if debug.FromContext(ctx) {
+		opts = append(opts, trace.WithSampler(trace.AlwaysSample()))
+	}
+
+	ctx, span := trace.StartSpan(ctx, observability.ClientSpanName, opts...)
 	if span.IsRecordingEvents() {
 		span.AddAttributes(occlient.EventTraceAttributes(&sentEvent)...)
 	}
@@ -23,12 +23,22 @@ const (
 	LabelDelivered     = "delivered"
 	LabelSentEventType = "sent_type"
 	LabelTrigger       = "trigger_name"
+	LabelCircuitState  = "circuit_state"
+	LabelFixture       = "fixture_outcome"
+	LabelGuard         = "guard"
+	LabelTargetHost    = "target_host"
+	LabelFilterResult  = "filter_result"
 )
 
 var (
 	sentEventTypeKey  = tag.MustNewKey(LabelSentEventType)
 	deliveredEventKey = tag.MustNewKey(LabelDelivered)
 	triggerKey        = tag.MustNewKey(LabelTrigger)
+	circuitStateKey   = tag.MustNewKey(LabelCircuitState)
+	fixtureKey        = tag.MustNewKey(LabelFixture)
+	guardKey          = tag.MustNewKey(LabelGuard)
+	targetHostKey     = tag.MustNewKey(LabelTargetHost)
+	filterResultKey   = tag.MustNewKey(LabelFilterResult)
 
 	// eventCountM is a counter which records the number of events received
 	// by the Broker.
@@ -44,6 +54,109 @@ var (
 		"trigger/event_latency",
 		"The latency in milliseconds for the broker Trigger subscriptions.",
 		"ms")
+
+	// integrityMismatchCountM is a counter which records the number of
+	// events whose content hash did not match.
+	integrityMismatchCountM = stats.Int64(
+		"trigger/integrity_mismatch_count",
+		"Number of events that failed the content integrity verification.",
+		stats.UnitDimensionless,
+	)
+
+	// expiredEventCountM is a counter which records the number of events
+	// that were not delivered because they exceeded their time to live.
+	expiredEventCountM = stats.Int64(
+		"trigger/expired_event_count",
+		"Number of events that expired before being delivered.",
+		stats.UnitDimensionless,
+	)
+
+	// hopsExhaustedCountM is a counter which records the number of replies
+	// that were not forwarded because they exhausted their hops.
+	hopsExhaustedCountM = stats.Int64(
+		"trigger/hops_exhausted_count",
+		"Number of replies that exhausted their hops before being forwarded.",
+		stats.UnitDimensionless,
+	)
+
+	// retryShedCountM is a counter which records the number of retries
+	// that were not attempted because the retry budget was exhausted.
+	retryShedCountM = stats.Int64(
+		"trigger/retry_shed_count",
+		"Number of retries not attempted due to the exhausted retry budget.",
+		stats.UnitDimensionless,
+	)
+
+	// circuitBreakerTransitionCountM is a counter which records the number
+	// of times the target circuit breaker was opened or closed.
+	circuitBreakerTransitionCountM = stats.Int64(
+		"trigger/circuit_breaker_transition_count",
+		"Number of times the target circuit breaker changed its state.",
+		stats.UnitDimensionless,
+	)
+
+	// filterCompileErrorCountM is a counter which records the number of
+	// filters that could not be compiled when applying trigger configurations.
+	filterCompileErrorCountM = stats.Int64(
+		"trigger/filter_compile_error_count",
+		"Number of trigger filters that failed to compile.",
+		stats.UnitDimensionless,
+	)
+
+	// filterEvaluationCountM is a counter which records the number of
+	// events evaluated by the trigger filters, either matched or filtered.
+	filterEvaluationCountM = stats.Int64(
+		"trigger/filter_evaluation_count",
+		"Number of events evaluated by the trigger filters.",
+		stats.UnitDimensionless,
+	)
+
+	// filterLatencyMs measures the latency in milliseconds for evaluating
+	// the trigger filters.
+	filterLatencyMs = stats.Float64(
+		"trigger/filter_latency",
+		"The latency in milliseconds for evaluating the trigger filters.",
+		"ms")
+
+	// fixtureCountM is a counter which records the number of deliveries
+	// recorded or asserted as fixtures.
+	fixtureCountM = stats.Int64(
+		"trigger/fixture_count",
+		"Number of deliveries recorded or asserted as fixtures.",
+		stats.UnitDimensionless,
+	)
+
+	// guardRejectedCountM is a counter which records the number of events
+	// that were not delivered because they did not conform to the guards.
+	guardRejectedCountM = stats.Int64(
+		"trigger/guard_rejected_count",
+		"Number of events that did not conform to the trigger guards.",
+		stats.UnitDimensionless,
+	)
+
+	// sampledOutCountM is a counter which records the number of events
+	// that were not delivered because they were not part of the sample.
+	sampledOutCountM = stats.Int64(
+		"trigger/sampled_out_count",
+		"Number of events not delivered due to the trigger sampling.",
+		stats.UnitDimensionless,
+	)
+
+	// concurrencyLimitM is a gauge which records the limit of deliveries in
+	// flight to the target when adaptive concurrency is enabled.
+	concurrencyLimitM = stats.Int64(
+		"trigger/concurrency_limit",
+		"Limit of deliveries in flight to the trigger target.",
+		stats.UnitDimensionless,
+	)
+
+	// hostInFlightM is a gauge which records the requests in flight to
+	// each target host when delivery concurrency is limited.
+	hostInFlightM = stats.Int64(
+		"delivery/host_in_flight",
+		"Number of requests in flight to the target host.",
+		stats.UnitDimensionless,
+	)
 )
 
 func registerStatViews() error {
@@ -68,6 +181,97 @@ func registerStatViews() error {
 			Aggregation: view.Count(),
 			TagKeys:     tagKeys,
 		},
+		&view.View{
+			Name:        integrityMismatchCountM.Name(),
+			Description: integrityMismatchCountM.Description(),
+			Measure:     integrityMismatchCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        expiredEventCountM.Name(),
+			Description: expiredEventCountM.Description(),
+			Measure:     expiredEventCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        hopsExhaustedCountM.Name(),
+			Description: hopsExhaustedCountM.Description(),
+			Measure:     hopsExhaustedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        retryShedCountM.Name(),
+			Description: retryShedCountM.Description(),
+			Measure:     retryShedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        circuitBreakerTransitionCountM.Name(),
+			Description: circuitBreakerTransitionCountM.Description(),
+			Measure:     circuitBreakerTransitionCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, circuitStateKey},
+		},
+		&view.View{
+			Name:        filterCompileErrorCountM.Name(),
+			Description: filterCompileErrorCountM.Description(),
+			Measure:     filterCompileErrorCountM,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        filterEvaluationCountM.Name(),
+			Description: filterEvaluationCountM.Description(),
+			Measure:     filterEvaluationCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, filterResultKey},
+		},
+		&view.View{
+			Name:        filterLatencyMs.Name(),
+			Description: filterLatencyMs.Description(),
+			Measure:     filterLatencyMs,
+			Aggregation: view.Distribution(0, .001, .01, .1, 1, 10, 100),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        fixtureCountM.Name(),
+			Description: fixtureCountM.Description(),
+			Measure:     fixtureCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, fixtureKey},
+		},
+		&view.View{
+			Name:        guardRejectedCountM.Name(),
+			Description: guardRejectedCountM.Description(),
+			Measure:     guardRejectedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey, guardKey},
+		},
+		&view.View{
+			Name:        sampledOutCountM.Name(),
+			Description: sampledOutCountM.Description(),
+			Measure:     sampledOutCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        concurrencyLimitM.Name(),
+			Description: concurrencyLimitM.Description(),
+			Measure:     concurrencyLimitM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{triggerKey},
+		},
+		&view.View{
+			Name:        hostInFlightM.Name(),
+			Description: hostInFlightM.Description(),
+			Measure:     hostInFlightM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{targetHostKey},
+		},
 	)
 }
 
@@ -77,6 +281,23 @@ func initContext(ctx context.Context, triggerName string) (context.Context, erro
 
 type Reporter interface {
 	ReportTriggeredEvent(delivered bool, sentType, receivedType string, msLatency float64)
+	ReportIntegrityMismatch()
+	ReportExpiredEvent()
+	ReportHopsExhausted()
+	ReportRetryShed()
+	ReportCircuitBreakerTransition(state string)
+	ReportFilterCompileErrors(count int)
+	ReportFilterEvaluation(matched bool, msLatency float64)
+	ReportFixture(outcome string)
+	ReportGuardRejection(guard string)
+	ReportSampledOut()
+	ReportConcurrencyLimit(limit int)
+}
+
+// HostReporter reports the requests in flight to target hosts, which are
+// shared by all triggers.
+type HostReporter interface {
+	ReportHostInFlight(host string, inFlight int)
 }
 
 // Reporter holds cached metric objects to report ingress metrics.
@@ -87,10 +308,9 @@ type reporter struct {
 
 var once sync.Once
 
-// NewReporter retuns a StatReporter for ingested events.
-func NewReporter(context context.Context, trigger string) (Reporter, error) {
-	r := &reporter{}
-
+// registerOnce registers the stat views the first time a reporter is
+// created.
+func registerOnce() error {
 	var err error
 	once.Do(func() {
 		if err = registerStatViews(); err != nil {
@@ -98,7 +318,14 @@ func NewReporter(context context.Context, trigger string) (Reporter, error) {
 			return
 		}
 	})
+	return err
+}
 
+// NewReporter retuns a StatReporter for ingested events.
+func NewReporter(context context.Context, trigger string) (Reporter, error) {
+	r := &reporter{}
+
+	err := registerOnce()
 	if err != nil {
 		return nil, err
 	}
@@ -123,3 +350,69 @@ func (r *reporter) ReportTriggeredEvent(delivered bool, sentType, receivedType s
 	knmetrics.Record(ctx, latencyMs.M(msLatency), stats.WithTags(tag.Insert(metrics.ReceivedEventTypeKey, receivedType)))
 	knmetrics.Record(ctx, eventCountM.M(1))
 }
+
+func (r *reporter) ReportIntegrityMismatch() {
+	knmetrics.Record(r.ctx, integrityMismatchCountM.M(1))
+}
+
+func (r *reporter) ReportExpiredEvent() {
+	knmetrics.Record(r.ctx, expiredEventCountM.M(1))
+}
+
+func (r *reporter) ReportHopsExhausted() {
+	knmetrics.Record(r.ctx, hopsExhaustedCountM.M(1))
+}
+
+func (r *reporter) ReportRetryShed() {
+	knmetrics.Record(r.ctx, retryShedCountM.M(1))
+}
+
+func (r *reporter) ReportCircuitBreakerTransition(state string) {
+	knmetrics.Record(r.ctx, circuitBreakerTransitionCountM.M(1), stats.WithTags(tag.Insert(circuitStateKey, state)))
+}
+
+func (r *reporter) ReportFilterCompileErrors(count int) {
+	knmetrics.Record(r.ctx, filterCompileErrorCountM.M(int64(count)))
+}
+
+func (r *reporter) ReportFilterEvaluation(matched bool, msLatency float64) {
+	result := "filtered"
+	if matched {
+		result = "matched"
+	}
+	knmetrics.Record(r.ctx, filterEvaluationCountM.M(1), stats.WithTags(tag.Insert(filterResultKey, result)))
+	knmetrics.Record(r.ctx, filterLatencyMs.M(msLatency))
+}
+
+func (r *reporter) ReportFixture(outcome string) {
+	knmetrics.Record(r.ctx, fixtureCountM.M(1), stats.WithTags(tag.Insert(fixtureKey, outcome)))
+}
+
+func (r *reporter) ReportGuardRejection(guard string) {
+	knmetrics.Record(r.ctx, guardRejectedCountM.M(1), stats.WithTags(tag.Insert(guardKey, guard)))
+}
+
+func (r *reporter) ReportSampledOut() {
+	knmetrics.Record(r.ctx, sampledOutCountM.M(1))
+}
+
+func (r *reporter) ReportConcurrencyLimit(limit int) {
+	knmetrics.Record(r.ctx, concurrencyLimitM.M(int64(limit)))
+}
+
+type hostReporter struct {
+	ctx context.Context
+}
+
+// NewHostReporter returns a reporter for the requests in flight to target
+// hosts.
+func NewHostReporter(ctx context.Context) (HostReporter, error) {
+	if err := registerOnce(); err != nil {
+		return nil, err
+	}
+	return &hostReporter{ctx: ctx}, nil
+}
+
+func (r *hostReporter) ReportHostInFlight(host string, inFlight int) {
+	knmetrics.Record(r.ctx, hostInFlightM.M(int64(inFlight)), stats.WithTags(tag.Insert(targetHostKey, host)))
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/rickb777/date/period"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+const (
+	// Content type of the objects, a JSON event per line.
+	ndjsonContentType = "application/x-ndjson"
+
+	// Default batching for object store targets that do not inform it.
+	defaultObjectStoreBatchMaxCount = 100
+
+	defaultObjectStoreRegion = "us-east-1"
+)
+
+var objectStoreEndpoints = map[string]string{
+	cfgbroker.ObjectStoreProviderS3:  "https://s3.amazonaws.com",
+	cfgbroker.ObjectStoreProviderGCS: "https://storage.googleapis.com",
+}
+
+// objectWriter writes objects to the bucket.
+type objectWriter interface {
+	PutObject(ctx context.Context, key string, body []byte) error
+}
+
+type minioWriter struct {
+	client *minio.Client
+	bucket string
+}
+
+func (w *minioWriter) PutObject(ctx context.Context, key string, body []byte) error {
+	_, err := w.client.PutObject(ctx, w.bucket, key, bytes.NewReader(body), int64(len(body)),
+		minio.PutObjectOptions{ContentType: ndjsonContentType})
+	return err
+}
+
+// objectStoreTarget writes batches of events to a bucket, an object per
+// partition of each batch.
+type objectStoreTarget struct {
+	cfg cfgbroker.ObjectStoreTarget
+
+	writer  objectWriter
+	batcher *batcher
+
+	// Attempts to write each object after the first one fails.
+	retries int
+	backoff time.Duration
+
+	now func() time.Time
+}
+
+func newObjectStoreTarget(cfg *cfgbroker.ObjectStoreTarget, bc *cfgbroker.Batching, do *cfgbroker.DeliveryOptions) (*objectStoreTarget, error) {
+	w, err := newMinioWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectStoreTargetWithWriter(cfg, bc, do, w)
+}
+
+func newObjectStoreTargetWithWriter(cfg *cfgbroker.ObjectStoreTarget, bc *cfgbroker.Batching, do *cfgbroker.DeliveryOptions, w objectWriter) (*objectStoreTarget, error) {
+	o := &objectStoreTarget{
+		cfg:    *cfg,
+		writer: w,
+		now:    time.Now,
+	}
+
+	if do != nil && do.Retry != nil {
+		o.retries = int(*do.Retry)
+		if do.BackoffDelay != nil {
+			p, err := period.Parse(*do.BackoffDelay)
+			if err != nil {
+				return nil, fmt.Errorf("backoff delay cannot be parsed: %w", err)
+			}
+			o.backoff = p.DurationApprox()
+		}
+	}
+
+	if bc == nil {
+		bc = &cfgbroker.Batching{MaxCount: defaultObjectStoreBatchMaxCount}
+	}
+	b, err := newBatcher(bc, func(ctx context.Context, events []*cloudevents.Event) protocol.Result {
+		if err := o.write(ctx, events); err != nil {
+			return err
+		}
+		return protocol.ResultACK
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.batcher = b
+
+	return o, nil
+}
+
+func newMinioWriter(cfg *cfgbroker.ObjectStoreTarget) (*minioWriter, error) {
+	endpoint := objectStoreEndpoints[cfg.Provider]
+	if cfg.Endpoint != nil {
+		endpoint = *cfg.Endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("object store endpoint cannot be parsed: %w", err)
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	switch {
+	case cfg.Credentials != nil && cfg.Credentials.SecretAccessKeyFrom != nil:
+		key, err := secret.New(cfg.Credentials.SecretAccessKeyFrom)
+		if err != nil {
+			return nil, fmt.Errorf("object store secret access key: %w", err)
+		}
+		creds = credentials.New(&secretCredentials{accessKeyID: cfg.Credentials.AccessKeyID, secretAccessKey: key})
+	case cfg.Credentials != nil:
+		creds = credentials.NewStaticV4(cfg.Credentials.AccessKeyID, cfg.Credentials.SecretAccessKey, "")
+	}
+
+	region := defaultObjectStoreRegion
+	if cfg.Region != nil {
+		region = *cfg.Region
+	}
+
+	c, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Scheme == "https",
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create object store client: %w", err)
+	}
+
+	return &minioWriter{client: c, bucket: cfg.Bucket}, nil
+}
+
+// secretCredentials reads the secret access key for every request, so
+// that rotated keys are honored.
+type secretCredentials struct {
+	accessKeyID     string
+	secretAccessKey *secret.Secret
+}
+
+func (c *secretCredentials) Retrieve() (credentials.Value, error) {
+	key, err := c.secretAccessKey.Value()
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("could not read object store secret access key: %w", err)
+	}
+	return credentials.Value{
+		AccessKeyID:     c.accessKeyID,
+		SecretAccessKey: key,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+func (c *secretCredentials) IsExpired() bool {
+	return true
+}
+
+// url identifies the target at logs, audit records and status.
+func (o *objectStoreTarget) url() string {
+	scheme := "s3"
+	if o.cfg.Provider == cfgbroker.ObjectStoreProviderGCS {
+		scheme = "gs"
+	}
+
+	u := scheme + "://" + o.cfg.Bucket + "/"
+	if o.cfg.Prefix != nil {
+		u += *o.cfg.Prefix
+	}
+	return u
+}
+
+// write groups the events by partition and writes each group as an
+// object, returning an error if any of them could not be written.
+func (o *objectStoreTarget) write(ctx context.Context, events []*cloudevents.Event) error {
+	now := o.now().UTC()
+
+	var partitions []string
+	groups := make(map[string]*bytes.Buffer)
+	for _, e := range events {
+		p := o.partition(e, now)
+		buf, ok := groups[p]
+		if !ok {
+			buf = &bytes.Buffer{}
+			groups[p] = buf
+			partitions = append(partitions, p)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("could not serialize event %q: %w", e.ID(), err)
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	suffix, err := objectSuffix(now)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range partitions {
+		key := path.Join(p, suffix)
+		if o.cfg.Prefix != nil {
+			key = *o.cfg.Prefix + key
+		}
+		if err := o.put(ctx, key, groups[p].Bytes()); err != nil {
+			return fmt.Errorf("could not write object %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *objectStoreTarget) put(ctx context.Context, key string, body []byte) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = o.writer.PutObject(ctx, key, body); err == nil || attempt == o.retries {
+			return err
+		}
+
+		select {
+		case <-time.After(o.backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// partition returns the path of the event partition, using the event time
+// or the time of the write when the event does not inform it.
+func (o *objectStoreTarget) partition(event *cloudevents.Event, now time.Time) string {
+	t := now
+	if et := event.Time(); !et.IsZero() {
+		t = et.UTC()
+	}
+
+	segments := make([]string, 0, len(o.cfg.Partitioning))
+	for _, p := range o.cfg.Partitioning {
+		switch p {
+		case cfgbroker.ObjectStorePartitionDate:
+			segments = append(segments, "date="+t.Format("2006-01-02"))
+		case cfgbroker.ObjectStorePartitionHour:
+			segments = append(segments, "hour="+t.Format("15"))
+		case cfgbroker.ObjectStorePartitionType:
+			segments = append(segments, "type="+url.PathEscape(event.Type()))
+		}
+	}
+
+	return path.Join(segments...)
+}
+
+// objectSuffix returns a name for the objects of a batch that sorts by
+// write time and does not collide with other writers.
+func objectSuffix(now time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate object name: %w", err)
+	}
+	return now.Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(b) + ".ndjson", nil
+}
+
+// deliverToObjectStore adds the event to the batch being written to the
+// object store, returning once the batch is written.
+func (s delivery) deliverToObjectStore(ctx context.Context, event *cloudevents.Event) error {
+	start := time.Now()
+
+	result := s.objectStore.batcher.add(ctx, event)
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
+
+	if !cloudevents.IsACK(result) {
+		s.logger.Errorw(fmt.Sprintf("Failed to archive event to %s", s.objectStore.url()),
+			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return result
+	}
+
+	s.debugw(ctx, fmt.Sprintf("Event archived to %s", s.objectStore.url()), zap.String("id", event.ID()))
+	return nil
+}
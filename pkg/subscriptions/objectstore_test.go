@@ -0,0 +1,225 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fakeObjectWriter struct {
+	objects map[string]string
+	err     error
+	calls   int
+	m       sync.Mutex
+}
+
+func (w *fakeObjectWriter) PutObject(_ context.Context, key string, body []byte) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.calls++
+	if w.err != nil {
+		return w.err
+	}
+	if w.objects == nil {
+		w.objects = map[string]string{}
+	}
+	w.objects[key] = string(body)
+	return nil
+}
+
+func TestObjectStoreWrite(t *testing.T) {
+	w := &fakeObjectWriter{}
+	cfg := &cfgbroker.ObjectStoreTarget{
+		Provider:     cfgbroker.ObjectStoreProviderS3,
+		Bucket:       "archive",
+		Prefix:       strPtr("events/"),
+		Partitioning: []string{"date", "hour", "type"},
+	}
+	o, err := newObjectStoreTargetWithWriter(cfg, nil, nil, w)
+	require.NoError(t, err)
+	assert.Equal(t, "s3://archive/events/", o.url())
+
+	now := time.Date(2023, 3, 1, 10, 30, 0, 0, time.UTC)
+	o.now = func() time.Time { return now }
+
+	newEvent := func(id, typ string, tm time.Time) *cloudevents.Event {
+		e := lib.NewCloudEvent(lib.CloudEventWithIDOption(id), lib.CloudEventWithTypeOption(typ))
+		e.SetTime(tm)
+		return &e
+	}
+	events := []*cloudevents.Event{
+		newEvent("1", "order.created", now),
+		newEvent("2", "order/paid", now),
+		newEvent("3", "order.created", now.Add(-time.Hour)),
+		newEvent("4", "order.created", now),
+	}
+	require.NoError(t, o.write(context.Background(), events))
+
+	ids := map[string][]string{}
+	for key, body := range w.objects {
+		assert.True(t, strings.HasSuffix(key, ".ndjson"), "Unexpected object name %q", key)
+		partition := key[:strings.LastIndexByte(key, '/')]
+
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			e := cloudevents.NewEvent()
+			require.NoError(t, json.Unmarshal([]byte(line), &e))
+			ids[partition] = append(ids[partition], e.ID())
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"events/date=2023-03-01/hour=10/type=order.created": {"1", "4"},
+		"events/date=2023-03-01/hour=10/type=order%2Fpaid":  {"2"},
+		"events/date=2023-03-01/hour=09/type=order.created": {"3"},
+	}, ids)
+}
+
+func TestObjectStoreTarget(t *testing.T) {
+	dlsHits := 0
+	dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dlsHits++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer dls.Close()
+
+	var puts []string
+	var body string
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		puts = append(puts, r.URL.Path)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer store.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			ObjectStore: &cfgbroker.ObjectStoreTarget{
+				Provider: cfgbroker.ObjectStoreProviderS3,
+				Bucket:   "archive",
+				Endpoint: &store.URL,
+				Prefix:   strPtr("events/"),
+				Credentials: &cfgbroker.ObjectStoreCredentials{
+					AccessKeyID:     "key",
+					SecretAccessKey: "secret",
+				},
+			},
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				DeadLetterURL: &dls.URL,
+			},
+		},
+		Batching: &cfgbroker.Batching{MaxCount: 1},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Equal(t, "s3://archive/events/", cloudevents.TargetFromContext(s.view().ctx).String())
+	assert.Nil(t, s.view().batcher, "Object store targets must not use the HTTP batcher")
+
+	ev := lib.NewCloudEvent()
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	require.Len(t, puts, 1)
+	assert.True(t, strings.HasPrefix(puts[0], "/archive/events/"), "Unexpected object path %q", puts[0])
+	assert.Contains(t, body, `"id":"`+ev.ID()+`"`)
+	assert.Equal(t, 0, dlsHits)
+
+	// The target is kept when the trigger configuration does not change.
+	target := s.view().objectStore
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Same(t, target, s.view().objectStore)
+
+	// Events that cannot be archived are sent to the dead letter sink.
+	w := &fakeObjectWriter{err: errors.New("access denied")}
+	s.view().objectStore.writer = w
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+	assert.Equal(t, 1, dlsHits)
+	assert.Equal(t, 1, w.calls)
+}
+
+func TestObjectStoreDispatch(t *testing.T) {
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		inFlight:  &inFlight{},
+		parentCtx: context.Background(),
+		logger:    zap.NewNop().Sugar(),
+	}
+
+	latency := "PT10S"
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			ObjectStore: &cfgbroker.ObjectStoreTarget{
+				Provider: cfgbroker.ObjectStoreProviderS3,
+				Bucket:   "archive",
+				Credentials: &cfgbroker.ObjectStoreCredentials{
+					AccessKeyID:     "key",
+					SecretAccessKey: "secret",
+				},
+			},
+		},
+		Batching: &cfgbroker.Batching{MaxCount: 3, MaxLatency: &latency},
+	}))
+	w := &fakeObjectWriter{}
+	s.view().objectStore.writer = w
+
+	// Events dispatched one at a time, as the memory backend does, must
+	// complete the batch instead of waiting for its latency.
+	for i := 0; i < 3; i++ {
+		ev := lib.NewCloudEvent()
+		require.NoError(t, s.dispatchCloudEvent(&ev))
+	}
+
+	_, err = s.inFlight.wait(context.Background())
+	require.NoError(t, err)
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	require.Len(t, w.objects, 1)
+	for _, body := range w.objects {
+		assert.Equal(t, 3, strings.Count(body, "\n"))
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// offsetBackend records the starting offset of each subscription.
+type offsetBackend struct {
+	backend.Interface
+	offsets map[string]backend.StartingOffset
+}
+
+func (b *offsetBackend) Subscribe(name string, ccb backend.ConsumerDispatcher, opts ...backend.SubscribeOption) error {
+	b.offsets[name] = backend.NewSubscribeOptions(opts...).StartingOffset
+	return b.Interface.Subscribe(name, ccb, opts...)
+}
+
+func TestStartingOffset(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	b := &offsetBackend{
+		Interface: memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger),
+		offsets:   map[string]backend.StartingOffset{},
+	}
+
+	m, err := New(context.Background(), logger, b)
+	require.NoError(t, err)
+
+	earliest := cfgbroker.StartingOffsetEarliest
+	latest := cfgbroker.StartingOffsetLatest
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"default":  {},
+		"replay":   {StartingOffset: &earliest},
+		"from-now": {StartingOffset: &latest},
+	}})
+
+	assert.Equal(t, map[string]backend.StartingOffset{
+		"default":  "",
+		"replay":   backend.StartingOffsetEarliest,
+		"from-now": backend.StartingOffsetLatest,
+	}, b.offsets)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Context attributes available to ordering expressions.
+var contextAttributes = []string{
+	"specversion", "id", "source", "type", "subject", "datacontenttype", "dataschema", "time",
+}
+
+// orderingKey returns the partition key of the event when the trigger
+// requires ordered delivery.
+func (s *subscriber) orderingKey(event *cloudevents.Event) (string, bool) {
+	d := s.view()
+	return eventOrderingKey(d.trigger.Ordering, d.orderingExpression, event)
+}
+
+// eventOrderingKey returns the partition key of the event for the ordering
+// configuration and its parsed expression.
+func eventOrderingKey(ordering *cfgbroker.Ordering, expr *template.Template, event *cloudevents.Event) (string, bool) {
+	switch {
+	case ordering == nil:
+		return "", false
+	case expr != nil:
+		return evalOrderingExpression(expr, event)
+	}
+
+	return eventAttribute(event, ordering.Key)
+}
+
+// newOrderingExpression parses the ordering expression of the trigger, nil
+// if not informed. Referencing missing fields fails the evaluation, instead
+// of rendering them as "<no value>".
+func newOrderingExpression(ordering *cfgbroker.Ordering) (*template.Template, error) {
+	if ordering == nil || ordering.Expression == "" {
+		return nil, nil
+	}
+
+	t, err := template.New("ordering").Option("missingkey=error").Parse(ordering.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("ordering expression cannot be parsed: %w", err)
+	}
+
+	return t, nil
+}
+
+// evalOrderingExpression renders the partition key for the event.
+func evalOrderingExpression(t *template.Template, event *cloudevents.Event) (string, bool) {
+	data := make(map[string]interface{}, len(contextAttributes)+len(event.Extensions())+1)
+	for _, a := range contextAttributes {
+		if v, ok := eventAttribute(event, a); ok {
+			data[a] = v
+		}
+	}
+	for name := range event.Extensions() {
+		if v, ok := eventAttribute(event, name); ok {
+			data[name] = v
+		}
+	}
+
+	if ct := event.DataContentType(); len(event.Data()) != 0 && (ct == "" || strings.Contains(ct, "json")) {
+		var payload interface{}
+		if err := json.Unmarshal(event.Data(), &payload); err == nil {
+			data["data"] = payload
+		}
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", false
+	}
+
+	return sb.String(), sb.Len() != 0
+}
+
+// eventAttribute returns the string representation of the event context
+// attribute or extension.
+func eventAttribute(event *cloudevents.Event, name string) (string, bool) {
+	var v string
+	switch name {
+	case "specversion":
+		v = event.SpecVersion()
+	case "id":
+		v = event.ID()
+	case "source":
+		v = event.Source()
+	case "type":
+		v = event.Type()
+	case "subject":
+		v = event.Subject()
+	case "datacontenttype":
+		v = event.DataContentType()
+	case "dataschema":
+		v = event.DataSchema()
+	case "time":
+		if t := event.Time(); !t.IsZero() {
+			v = types.FormatTime(t)
+		}
+	default:
+		ext, ok := event.Extensions()[name]
+		if !ok {
+			return "", false
+		}
+		s, err := types.Format(ext)
+		if err != nil {
+			return "", false
+		}
+		v = s
+	}
+
+	return v, v != ""
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestOrderingExpression(t *testing.T) {
+	expr, err := newOrderingExpression(&cfgbroker.Ordering{Expression: "{{.source}}/{{.data.customer.id}}"})
+	require.NoError(t, err)
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithSourceOption("shop"))
+	require.NoError(t, ev.SetData(cloudevents.ApplicationJSON, map[string]interface{}{
+		"customer": map[string]interface{}{"id": 42},
+	}))
+	key, ok := evalOrderingExpression(expr, &ev)
+	assert.True(t, ok)
+	assert.Equal(t, "shop/42", key)
+
+	ev = lib.NewCloudEvent(lib.CloudEventWithSourceOption("shop"))
+	require.NoError(t, ev.SetData(cloudevents.ApplicationJSON, map[string]interface{}{"order": 1}))
+	_, ok = evalOrderingExpression(expr, &ev)
+	assert.False(t, ok, "Events missing the referenced fields must not be ordered")
+
+	expr, err = newOrderingExpression(&cfgbroker.Ordering{Expression: "{{.tenant}}"})
+	require.NoError(t, err)
+	ev = lib.NewCloudEvent(lib.CloudEventWithExtensionOption("tenant", "acme"))
+	key, ok = evalOrderingExpression(expr, &ev)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", key)
+
+	expr, err = newOrderingExpression(&cfgbroker.Ordering{Key: "source"})
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// pauseBackend records whether each subscription is paused.
+type pauseBackend struct {
+	backend.Interface
+	paused map[string]bool
+}
+
+func (b *pauseBackend) Subscribe(name string, ccb backend.ConsumerDispatcher, opts ...backend.SubscribeOption) error {
+	b.paused[name] = backend.NewSubscribeOptions(opts...).Paused
+	return b.Interface.Subscribe(name, ccb, opts...)
+}
+
+func (b *pauseBackend) PauseSubscription(name string) error {
+	b.paused[name] = true
+	return nil
+}
+
+func (b *pauseBackend) ResumeSubscription(name string) error {
+	b.paused[name] = false
+	return nil
+}
+
+func TestPausedTriggers(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	b := &pauseBackend{
+		Interface: memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger),
+		paused:    map[string]bool{},
+	}
+
+	m, err := New(context.Background(), logger, b)
+	require.NoError(t, err)
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"active": {},
+		"paused": {Paused: true},
+	}})
+	assert.Equal(t, map[string]bool{"active": false, "paused": true}, b.paused)
+	assert.True(t, m.Status().Triggers["paused"].Paused)
+
+	m.UpdateFromConfig(&cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"active": {Paused: true},
+		"paused": {},
+	}})
+	assert.Equal(t, map[string]bool{"active": true, "paused": false}, b.paused,
+		"Triggers must be paused and resumed when updated")
+	assert.False(t, m.Status().Triggers["paused"].Paused)
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+	"knative.dev/eventing/pkg/eventfilter"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// rewriteReply rewrites the attributes of the reply event as configured
+// for the trigger.
+func rewriteReply(r *cfgbroker.Reply, trigger string, event *cloudevents.Event) {
+	if r == nil {
+		return
+	}
+
+	rp := strings.NewReplacer("{trigger}", trigger, "{source}", event.Source(), "{type}", event.Type())
+	if r.Source != nil {
+		event.SetSource(rp.Replace(*r.Source))
+	}
+	if r.Type != nil {
+		event.SetType(rp.Replace(*r.Type))
+	}
+}
+
+// inheritHops decrements the hops of the reply from those of the event it
+// replies to, returning false if no hops remain for the reply, which is then
+// sent to the dead letter sinks of the trigger instead of being forwarded.
+func (s delivery) inheritHops(ctx context.Context, event, reply *cloudevents.Event) (bool, error) {
+	ok, err := hops.Inherit(event, reply, s.maxHops)
+	if err != nil {
+		s.logger.Errorw("Failed to set the reply hops", zap.Error(err),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+		return false, fmt.Errorf("could not set the reply hops: %w", err)
+	}
+	if ok {
+		return true, nil
+	}
+
+	s.reporter.ReportHopsExhausted()
+
+	// Replies of dead letter sinks to events that exhausted their hops are
+	// discarded, so that they do not loop through the dead letter sinks.
+	if n, ok := hops.Remaining(event); !ok || n > 0 {
+		t := s.trigger.Target
+		if s.sendToDeadLetterSinks(s.parentCtx, &t, reply) {
+			return false, nil
+		}
+	}
+
+	s.logger.Warnw("Reply discarded after exhausting its hops, which might be due to a loop", zap.String("trigger", s.name),
+		zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+	return false, nil
+}
+
+// routeReply sends the reply to the trigger reply target when it matches
+// the reply target filters, returning false if the reply must be produced
+// to the broker instead.
+func (s delivery) routeReply(ctx context.Context, reply *cloudevents.Event) (bool, error) {
+	rt := s.trigger.ReplyTarget
+	if rt == nil || s.replyFilter.Filter(ctx, *reply) == eventfilter.FailFilter {
+		return false, nil
+	}
+
+	// Events the reply target responds with are discarded.
+	if _, result := s.sendToDestination(ctx, rt.URL, rt.Ref, reply); !cloudevents.IsACK(result) {
+		s.logger.Errorw("Failed to send reply to the reply target", zap.String("trigger", s.name), zap.Error(result),
+			zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+
+		// Not routing the reply is considered an error, as is not
+		// producing it to the broker.
+		return true, fmt.Errorf("could not send reply: %w", result)
+	}
+
+	s.debugw(ctx, "Reply sent to the reply target", zap.String("id", reply.ID()))
+	return true, nil
+}
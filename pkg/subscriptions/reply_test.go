@@ -0,0 +1,163 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/common/hops"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestRewriteReply(t *testing.T) {
+	source := "broker/{trigger}"
+	typ := "{trigger}.{type}"
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithTypeOption("order.priced"), lib.CloudEventWithSourceOption("pricing"))
+	rewriteReply(&cfgbroker.Reply{Source: &source, Type: &typ}, "pricing-trigger", &ev)
+	assert.Equal(t, "broker/pricing-trigger", ev.Source())
+	assert.Equal(t, "pricing-trigger.order.priced", ev.Type())
+
+	// Attributes that are not rewritten are kept.
+	ev = lib.NewCloudEvent(lib.CloudEventWithTypeOption("order.priced"), lib.CloudEventWithSourceOption("pricing"))
+	rewriteReply(&cfgbroker.Reply{Source: &typ}, "pricing-trigger", &ev)
+	assert.Equal(t, "pricing-trigger.order.priced", ev.Source())
+	assert.Equal(t, "order.priced", ev.Type())
+}
+
+type replyRecorder struct {
+	events []cloudevents.Event
+}
+
+func (r *replyRecorder) Produce(_ context.Context, event *cloudevents.Event) error {
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func TestReplyTarget(t *testing.T) {
+	var received []cloudevents.Event
+	replyTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+		require.NoError(t, err)
+		received = append(received, *ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer replyTarget.Close()
+
+	replyType := "order.priced"
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := lib.NewCloudEvent(lib.CloudEventWithTypeOption(replyType))
+		require.NoError(t, cehttp.WriteResponseWriter(r.Context(), binding.ToMessage(&reply), http.StatusOK, w))
+	}))
+	defer target.Close()
+
+	rep, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	be := &replyRecorder{}
+	s := subscriber{
+		name:            "test-subscriber",
+		replies:         be,
+		reporter:        rep,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		maxHops:         2,
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{URL: &target.URL},
+		ReplyTarget: &cfgbroker.ReplyTarget{
+			URL:     &replyTarget.URL,
+			Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.priced"}}},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	require.NoError(t, s.view().deliver(s.view().ctx, &ev))
+	require.Len(t, received, 1)
+	assert.Empty(t, be.events, "Routed replies must not be produced to the broker")
+	n, ok := hops.Remaining(&received[0])
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), n)
+
+	// Replies to routed replies exhaust their hops.
+	require.NoError(t, s.view().deliver(s.view().ctx, &received[0]))
+	assert.Len(t, received, 1, "Replies without hops remaining must be discarded")
+
+	// Replies that do not match the filters are produced to the broker.
+	replyType = "order.rejected"
+	require.NoError(t, s.view().deliver(s.view().ctx, &ev))
+	assert.Len(t, received, 1)
+	assert.Len(t, be.events, 1)
+}
+
+func TestReplyHops(t *testing.T) {
+	var deadLettered []cloudevents.Event
+	dls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+		require.NoError(t, err)
+		deadLettered = append(deadLettered, *ev)
+
+		// Dead letter sinks replying to events without hops remaining
+		// must not make them loop.
+		reply := lib.NewCloudEvent()
+		require.NoError(t, cehttp.WriteResponseWriter(r.Context(), binding.ToMessage(&reply), http.StatusOK, w))
+	}))
+	defer dls.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := lib.NewCloudEvent()
+		require.NoError(t, cehttp.WriteResponseWriter(r.Context(), binding.ToMessage(&reply), http.StatusOK, w))
+	}))
+	defer target.Close()
+
+	rep, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	be := &replyRecorder{}
+	s := subscriber{
+		name:            "test-subscriber",
+		replies:         be,
+		reporter:        rep,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		maxHops:         2,
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL:             &target.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{DeadLetterURL: &dls.URL},
+		},
+	}))
+
+	ev := lib.NewCloudEvent()
+	require.NoError(t, s.view().deliver(s.view().ctx, &ev))
+	require.Len(t, be.events, 1)
+	n, ok := hops.Remaining(&be.events[0])
+	assert.True(t, ok, "Replies must inform their hops")
+	assert.Equal(t, int32(1), n)
+
+	// Replies to re-ingested replies exhaust their hops.
+	require.NoError(t, s.view().deliver(s.view().ctx, &be.events[0]))
+	assert.Len(t, be.events, 1, "Replies without hops remaining must not be produced to the broker")
+	require.Len(t, deadLettered, 1, "Replies without hops remaining must be sent to the dead letter sinks")
+	n, _ = hops.Remaining(&deadLettered[0])
+	assert.Equal(t, int32(0), n)
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// RequeueTriggerExtension is the CloudEvents extension used internally
+	// by the broker for events requeued after a failed delivery, which
+	// informs the only trigger they are dispatched to.
+	RequeueTriggerExtension = "triggermeshrequeuetrigger"
+
+	// RequeueAttemptsExtension is the CloudEvents extension used internally
+	// by the broker for events requeued after a failed delivery, which
+	// informs the retries scheduled so far.
+	RequeueAttemptsExtension = "triggermeshrequeueattempts"
+)
+
+// errRequeued is returned by deliveries whose event was requeued at the
+// backend to be retried once due.
+var errRequeued = errors.New("event was requeued to be delivered again")
+
+type requeueAttemptsKey struct{}
+
+// withRequeueAttempts returns a context that informs the retries scheduled
+// so far for the requeued event being delivered.
+func withRequeueAttempts(ctx context.Context, attempts int) context.Context {
+	return context.WithValue(ctx, requeueAttemptsKey{}, attempts)
+}
+
+// requeueAttemptsFrom returns the retries scheduled so far for the requeued
+// event being delivered, false if the event was not requeued.
+func requeueAttemptsFrom(ctx context.Context) (int, bool) {
+	attempts, ok := ctx.Value(requeueAttemptsKey{}).(int)
+	return attempts, ok
+}
+
+// requeuedFor returns the trigger the event was requeued for and the
+// retries scheduled so far, false if the event was not requeued.
+func requeuedFor(event *cloudevents.Event) (string, int, bool) {
+	exts := event.Extensions()
+	v, ok := exts[RequeueTriggerExtension]
+	if !ok {
+		return "", 0, false
+	}
+	trigger, err := types.ToString(v)
+	if err != nil {
+		return "", 0, false
+	}
+
+	attempts, err := types.ToInteger(exts[RequeueAttemptsExtension])
+	if err != nil {
+		attempts = 0
+	}
+	return trigger, int(attempts), true
+}
+
+// unwrapRequeued returns a copy of the requeued event without the
+// extensions informing its requeue.
+func unwrapRequeued(event *cloudevents.Event) *cloudevents.Event {
+	ev := event.Clone()
+	for _, name := range []string{RequeueTriggerExtension, RequeueAttemptsExtension, backend.RequeueAtExtension} {
+		_ = ev.Context.SetExtension(name, nil)
+	}
+	return &ev
+}
+
+// requeueEvent produces a copy of the event to the backend to be delivered
+// again to the trigger once due, returning true if it was requeued.
+func (s delivery) requeueEvent(ctx context.Context, event *cloudevents.Event, attempts int, at time.Time) bool {
+	ev := event.Clone()
+	ev.SetExtension(RequeueTriggerExtension, s.name)
+	ev.SetExtension(RequeueAttemptsExtension, int32(attempts))
+	ev.SetExtension(backend.RequeueAtExtension, types.Timestamp{Time: at})
+
+	if err := s.backend.Produce(ctx, &ev); err != nil {
+		s.logger.Warnw("Could not requeue event, waiting for the retry instead", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+		return false
+	}
+
+	s.debugw(ctx, "Event requeued to be delivered again", zap.String("id", event.ID()),
+		zap.Int("attempts", attempts), zap.Time("at", at))
+	return true
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type requeueBackend struct {
+	backend.Interface
+
+	produced []*cloudevents.Event
+	m        sync.Mutex
+}
+
+func (b *requeueBackend) Produce(_ context.Context, event *cloudevents.Event) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.produced = append(b.produced, event)
+	return nil
+}
+
+func TestDeliverToTargetRequeue(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	retry := int32(3)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT10S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &srv.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+
+	b := &requeueBackend{}
+	s := &subscriber{
+		name:            "test-subscriber",
+		backend:         b,
+		reporter:        r,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		requeueMinDelay: time.Second,
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	start := time.Now()
+	err = s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev)
+	assert.ErrorIs(t, err, errRequeued)
+	assert.Less(t, time.Since(start), time.Second, "Requeued events must not wait for the backoff")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	require.Len(t, b.produced, 1)
+	requeued := b.produced[0]
+	name, attempts, ok := requeuedFor(requeued)
+	require.True(t, ok)
+	assert.Equal(t, s.name, name)
+	assert.Equal(t, 1, attempts)
+
+	at, scheduled, err := backend.ScheduledTime(requeued, time.Now())
+	require.NoError(t, err)
+	assert.True(t, scheduled, "Requeued events must be scheduled")
+	assert.WithinDuration(t, start.Add(10*time.Second), at, time.Second)
+
+	unwrapped := unwrapRequeued(requeued)
+	_, _, ok = requeuedFor(unwrapped)
+	assert.False(t, ok)
+	assert.NotContains(t, unwrapped.Extensions(), backend.RequeueAtExtension)
+	assert.Contains(t, requeued.Extensions(), RequeueTriggerExtension, "Requeued events must not be modified")
+
+	// Requeued events resume their retries, which are not requeued once
+	// exhausted.
+	ctx := withRequeueAttempts(s.view().ctx, int(retry))
+	err = s.view().deliverToTarget(ctx, &trigger.Target, unwrapped)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errRequeued)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Len(t, b.produced, 1)
+}
+
+func TestDispatchRequeued(t *testing.T) {
+	var delivered int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := &subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+	trigger := cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.created"}}},
+		Target:  cfgbroker.Target{URL: &srv.URL},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	requeue := func(trigger string) *cloudevents.Event {
+		ev := lib.NewCloudEvent(lib.CloudEventWithTypeOption("order.created"))
+		ev.SetExtension(RequeueTriggerExtension, trigger)
+		ev.SetExtension(RequeueAttemptsExtension, int32(1))
+		return &ev
+	}
+
+	require.NoError(t, s.dispatchCloudEvent(requeue("other-trigger")))
+	assert.Zero(t, atomic.LoadInt32(&delivered), "Events requeued for other triggers must not be delivered")
+
+	// Requeued events already matched the filter, which is not evaluated
+	// again.
+	require.NoError(t, s.dispatchCloudEvent(requeue(s.name)))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&delivered))
+	assert.Zero(t, s.status().Evaluated, "Requeued events must not be accounted as filter evaluations")
+}
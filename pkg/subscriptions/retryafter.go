@@ -0,0 +1,155 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+)
+
+type retryAfterCaptureKey struct{}
+
+// retryAfterCapture keeps the longest wait informed by the Retry-After
+// header of the target responses during a delivery attempt.
+type retryAfterCapture struct {
+	wait time.Duration
+	ok   bool
+	m    sync.Mutex
+}
+
+func withRetryAfterCapture(ctx context.Context) (context.Context, *retryAfterCapture) {
+	c := &retryAfterCapture{}
+	return context.WithValue(ctx, retryAfterCaptureKey{}, c), c
+}
+
+func (c *retryAfterCapture) record(wait time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if !c.ok || wait > c.wait {
+		c.wait = wait
+	}
+	c.ok = true
+}
+
+// get returns the captured wait, false if no response informed it.
+func (c *retryAfterCapture) get() (time.Duration, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.wait, c.ok
+}
+
+// retryAfterRoundTripper captures the Retry-After header of the 429 and
+// 503 responses, when the request context captures it.
+type retryAfterRoundTripper struct {
+	base http.RoundTripper
+}
+
+func withRetryAfter(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &retryAfterRoundTripper{base: rt}
+}
+
+func (rt *retryAfterRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := rt.base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return res, nil
+	}
+	c, ok := req.Context().Value(retryAfterCaptureKey{}).(*retryAfterCapture)
+	if !ok {
+		return res, nil
+	}
+	if wait, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		c.record(wait)
+	}
+
+	return res, nil
+}
+
+// parseRetryAfter returns the wait informed by a Retry-After header value,
+// which is either a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		// Prevent overflowing for absurd values.
+		if secs > math.MaxInt64/int64(time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if wait := t.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// retryBackoff waits before retrying the delivery, for the time informed by
+// the target Retry-After header up to the maximum when captured, or for the
+// time computed by the retry parameters otherwise. An error is returned
+// when retries are exhausted or the context is done.
+func (s delivery) retryBackoff(ctx context.Context, rp *cecontext.RetryParams, tries int, c *retryAfterCapture) error {
+	wait, err := s.retryWait(rp, tries, c)
+	if err != nil {
+		return err
+	}
+	return sleepContext(ctx, wait)
+}
+
+// retryWait returns the time to wait before retrying the delivery, or an
+// error when retries are exhausted.
+func (s delivery) retryWait(rp *cecontext.RetryParams, tries int, c *retryAfterCapture) (time.Duration, error) {
+	if tries > rp.MaxTries {
+		return 0, errors.New("too many retries")
+	}
+
+	if c != nil {
+		if wait, ok := c.get(); ok {
+			if s.retryAfterMax > 0 && wait > s.retryAfterMax {
+				wait = s.retryAfterMax
+			}
+			return wait, nil
+		}
+	}
+
+	return rp.BackoffFor(tries), nil
+}
+
+// sleepContext waits for the duration, returning an error if the context
+// is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.New("context has been cancelled")
+	case <-t.C:
+		return nil
+	}
+}
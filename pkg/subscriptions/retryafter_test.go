@@ -0,0 +1,158 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		"seconds":     {value: "3", expected: 3 * time.Second, ok: true},
+		"zero":        {value: "0", expected: 0, ok: true},
+		"negative":    {value: "-1"},
+		"date":        {value: "Mon, 01 May 2023 10:00:30 GMT", expected: 30 * time.Second, ok: true},
+		"past date":   {value: "Mon, 01 May 2023 09:00:00 GMT", expected: 0, ok: true},
+		"empty":       {value: ""},
+		"not valid":   {value: "soon"},
+		"overflowing": {value: "99999999999999999", expected: time.Duration(1<<63 - 1), ok: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tc.value, now)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, wait)
+		})
+	}
+}
+
+func TestDeliverToTargetRetryAfter(t *testing.T) {
+	var hits int32
+	var retryAfter atomic.Value
+	retryAfter.Store("1")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", retryAfter.Load().(string))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	retry := int32(1)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT10S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &srv.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+
+	newSubscriber := func(honor bool, max time.Duration) *subscriber {
+		s := &subscriber{
+			name:            "test-subscriber",
+			reporter:        r,
+			sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+			parentCtx:       context.Background(),
+			honorRetryAfter: honor,
+			maxRetryAfter:   max,
+			logger:          zaptest.NewLogger(t).Sugar(),
+		}
+		require.NoError(t, s.updateTrigger(trigger))
+		return s
+	}
+
+	deliver := func(s *subscriber) (time.Duration, error) {
+		atomic.StoreInt32(&hits, 0)
+		ev := lib.NewCloudEvent()
+		start := time.Now()
+		err := s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev)
+		return time.Since(start), err
+	}
+
+	t.Run("header overrides backoff", func(t *testing.T) {
+		elapsed, err := deliver(newSubscriber(true, time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		assert.GreaterOrEqual(t, elapsed, time.Second)
+		assert.Less(t, elapsed, 5*time.Second, "The backoff delay must not be waited")
+	})
+
+	t.Run("wait is capped", func(t *testing.T) {
+		retryAfter.Store("3600")
+		defer retryAfter.Store("1")
+
+		elapsed, err := deliver(newSubscriber(true, 100*time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		assert.Less(t, elapsed, 5*time.Second)
+	})
+
+	t.Run("trigger overrides maximum", func(t *testing.T) {
+		retryAfter.Store("3600")
+		defer retryAfter.Store("1")
+
+		max := "PT0.1S"
+		trigger.Target.DeliveryOptions.RetryAfterMax = &max
+		defer func() { trigger.Target.DeliveryOptions.RetryAfterMax = nil }()
+
+		elapsed, err := deliver(newSubscriber(true, time.Hour))
+		require.NoError(t, err)
+		assert.Less(t, elapsed, 5*time.Second)
+	})
+
+	t.Run("trigger ignores header", func(t *testing.T) {
+		max := "PT0S"
+		trigger.Target.DeliveryOptions.RetryAfterMax = &max
+		defer func() { trigger.Target.DeliveryOptions.RetryAfterMax = nil }()
+
+		s := newSubscriber(true, time.Minute)
+		assert.False(t, s.view().retryAfter)
+	})
+
+	t.Run("not honored", func(t *testing.T) {
+		s := newSubscriber(false, time.Minute)
+		assert.False(t, s.view().retryAfter)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		none := int32(0)
+		trigger.Target.DeliveryOptions.Retry = &none
+		defer func() { trigger.Target.DeliveryOptions.Retry = &retry }()
+
+		elapsed, err := deliver(newSubscriber(true, time.Minute))
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+		assert.Less(t, elapsed, time.Second)
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Retries are budgeted over the requests sent to targets during this
+	// window, tracked using one bucket per second.
+	retryBudgetWindow  = 10 * time.Second
+	retryBudgetBuckets = int(retryBudgetWindow / time.Second)
+)
+
+// retryBudget caps the fraction of the requests sent to targets that are
+// retries. It is shared by all triggers, so that retries do not amplify the
+// load on targets that are already failing.
+type retryBudget struct {
+	// Maximum fraction of the requests that can be retries.
+	ratio float64
+	// Retries allowed during the window regardless of the ratio, so that
+	// low traffic triggers can retry.
+	minRetries int
+
+	buckets [retryBudgetBuckets]retryBudgetBucket
+	m       sync.Mutex
+}
+
+type retryBudgetBucket struct {
+	// Second of the counters, which are reset when the bucket is
+	// reused for a later second.
+	second   int64
+	requests int
+	retries  int
+}
+
+func newRetryBudget(ratio float64, minRetriesPerSecond int) *retryBudget {
+	return &retryBudget{
+		ratio:      ratio,
+		minRetries: minRetriesPerSecond * retryBudgetBuckets,
+	}
+}
+
+// request counts a first attempt to deliver an event.
+func (b *retryBudget) request(now time.Time) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.bucket(now).requests++
+}
+
+// allowRetry returns true and counts the retry if it fits in the budget.
+func (b *retryBudget) allowRetry(now time.Time) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	var requests, retries int
+	oldest := now.Unix() - int64(retryBudgetBuckets) + 1
+	for i := range b.buckets {
+		if b.buckets[i].second >= oldest {
+			requests += b.buckets[i].requests
+			retries += b.buckets[i].retries
+		}
+	}
+
+	// Retries are also requests sent to targets.
+	if retries >= b.minRetries && float64(retries+1) > b.ratio*float64(requests+retries+1) {
+		return false
+	}
+
+	b.bucket(now).retries++
+	return true
+}
+
+// bucket returns the bucket for the current second.
+func (b *retryBudget) bucket(now time.Time) *retryBudgetBucket {
+	second := now.Unix()
+	bk := &b.buckets[second%int64(retryBudgetBuckets)]
+	if bk.second != second {
+		*bk = retryBudgetBucket{second: second}
+	}
+	return bk
+}
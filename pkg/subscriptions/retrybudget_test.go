@@ -0,0 +1,90 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	b := newRetryBudget(0.2, 0)
+	for i := 0; i < 8; i++ {
+		b.request(now)
+	}
+	assert.True(t, b.allowRetry(now))
+	assert.True(t, b.allowRetry(now))
+	assert.False(t, b.allowRetry(now), "Retries must not exceed the fraction of the requests")
+
+	// Requests older than the window do not count.
+	later := now.Add(retryBudgetWindow)
+	assert.False(t, b.allowRetry(later))
+	for i := 0; i < 4; i++ {
+		b.request(later)
+	}
+	assert.True(t, b.allowRetry(later))
+
+	b = newRetryBudget(0.2, 1)
+	var allowed int
+	for i := 0; i < 20; i++ {
+		if b.allowRetry(now) {
+			allowed++
+		}
+	}
+	assert.Equal(t, retryBudgetBuckets, allowed, "The minimum retries must be allowed regardless of the ratio")
+}
+
+func TestDeliverToTargetRetryBudget(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	retry := int32(3)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT0.1S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &srv.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+
+	s := &subscriber{
+		name:            "test-subscriber",
+		reporter:        r,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		retryBudget:     newRetryBudget(0.5, 0),
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	assert.Error(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "Retries beyond the budget must not be attempted")
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+// retryStateKey returns the key of the event retry state, which is unique
+// per event source and ID.
+func retryStateKey(event *cloudevents.Event) string {
+	return event.Source() + "/" + event.ID()
+}
+
+// resumeRetries returns the retries already scheduled for the event when
+// it was requeued, or when its retry state is kept, waiting for the last
+// scheduled retry to be due. Errors reading the state start the retries
+// over.
+func (s delivery) resumeRetries(ctx context.Context, event *cloudevents.Event) (int, error) {
+	if attempts, ok := requeueAttemptsFrom(ctx); ok {
+		return attempts, nil
+	}
+	if s.retryStates == nil {
+		return 0, nil
+	}
+
+	st, err := s.retryStates.RetryState(ctx, s.name, retryStateKey(event))
+	switch {
+	case err != nil:
+		s.logger.Warnw("Could not read event retry state", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+		return 0, nil
+	case st == nil:
+		return 0, nil
+	}
+
+	s.debugw(ctx, "Resuming event retries", zap.String("id", event.ID()),
+		zap.Int("attempts", st.Attempts), zap.Time("nextRetry", st.NextRetry))
+	if wait := time.Until(st.NextRetry); wait > 0 {
+		if err := sleepContext(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+	return st.Attempts, nil
+}
+
+// saveRetries keeps the retries scheduled for the event, when retry states
+// are kept.
+func (s delivery) saveRetries(ctx context.Context, attempts int, next time.Time, event *cloudevents.Event) {
+	if s.retryStates == nil {
+		return
+	}
+
+	st := backend.RetryState{Attempts: attempts, NextRetry: next}
+	if err := s.retryStates.SaveRetryState(ctx, s.name, retryStateKey(event), st); err != nil {
+		s.logger.Warnw("Could not save event retry state", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+	}
+}
+
+// forgetRetries removes the retry state of the event once its delivery
+// ends, if any retry was scheduled.
+func (s delivery) forgetRetries(ctx context.Context, attempts int, event *cloudevents.Event) {
+	if s.retryStates == nil || attempts == 0 {
+		return
+	}
+
+	if err := s.retryStates.DeleteRetryState(ctx, s.name, retryStateKey(event)); err != nil {
+		s.logger.Warnw("Could not delete event retry state", zap.String("trigger", s.name),
+			zap.String("id", event.ID()), zap.Error(err))
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+type fakeRetryStates struct {
+	states map[string]backend.RetryState
+	m      sync.Mutex
+}
+
+func (f *fakeRetryStates) SaveRetryState(_ context.Context, trigger, key string, state backend.RetryState) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.states[trigger+"/"+key] = state
+	return nil
+}
+
+func (f *fakeRetryStates) RetryState(_ context.Context, trigger, key string) (*backend.RetryState, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	st, ok := f.states[trigger+"/"+key]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (f *fakeRetryStates) DeleteRetryState(_ context.Context, trigger, key string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	delete(f.states, trigger+"/"+key)
+	return nil
+}
+
+func TestDeliverToTargetRetryState(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	retry := int32(3)
+	policy := cfgbroker.BackoffPolicyConstant
+	delay := "PT0.1S"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{
+			URL: &srv.URL,
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				Retry:         &retry,
+				BackoffPolicy: &policy,
+				BackoffDelay:  &delay,
+			},
+		},
+	}
+
+	states := &fakeRetryStates{states: map[string]backend.RetryState{}}
+	s := &subscriber{
+		name:            "test-subscriber",
+		reporter:        r,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		retryStates:     states,
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+
+	// The broker stops while backing off before the first retry.
+	ctx, cancel := context.WithTimeout(s.view().ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, s.view().deliverToTarget(ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	st, err := states.RetryState(ctx, s.name, retryStateKey(&ev))
+	require.NoError(t, err)
+	require.NotNil(t, st, "Retry state must be kept when the context is done")
+	assert.Equal(t, 1, st.Attempts)
+
+	// Dispatching the event again resumes its retries.
+	assert.Error(t, s.view().deliverToTarget(s.view().ctx, &trigger.Target, &ev))
+	assert.Equal(t, int32(1+retry), atomic.LoadInt32(&hits), "Resumed deliveries must not start their retries over")
+	assert.Empty(t, states.states, "Retry state must be removed once retries are exhausted")
+}
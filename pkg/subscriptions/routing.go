@@ -0,0 +1,33 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.uber.org/zap"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// resolveTarget returns the context targeting the URL the templated target
+// URL resolves to for the event. Events that do not inform the templated
+// attributes are targeted to the default URL, or to none if not informed.
+func (s delivery) resolveTarget(ctx context.Context, target *cfgbroker.Target, event *cloudevents.Event) context.Context {
+	u := s.urlTemplate.Resolve(func(attribute string) (string, bool) {
+		return eventAttribute(event, attribute)
+	})
+	if u != "" {
+		return cloudevents.ContextWithTarget(ctx, u)
+	}
+
+	s.debugw(ctx, "Event does not inform the attributes of the target URL template",
+		zap.String("id", event.ID()), zap.Strings("attributes", s.urlTemplate.Attributes()))
+
+	if target.DefaultURL != nil {
+		return cloudevents.ContextWithTarget(ctx, *target.DefaultURL)
+	}
+	return cloudevents.ContextWithTarget(ctx, "")
+}
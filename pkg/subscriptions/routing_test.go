@@ -0,0 +1,64 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestTemplatedTargetURL(t *testing.T) {
+	paths := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		reporter:  r,
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	url := srv.URL + "/{type}/{subject}"
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{URL: &url},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent(lib.CloudEventWithTypeOption("order.created"))
+	ev.SetSubject("orders/1")
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev)
+
+	// Events that do not inform the subject are lost without default URL.
+	missing := lib.NewCloudEvent()
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &missing)
+
+	defaultURL := srv.URL + "/default"
+	trigger.Target.DefaultURL = &defaultURL
+	require.NoError(t, s.updateTrigger(trigger))
+	s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &missing)
+
+	assert.Equal(t, []string{"/order.created/orders%2F1", "/default"}, paths)
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"hash/fnv"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Resolution of sampling percentages, in hundredths of a percent.
+const samplingScale = 10000
+
+// sampled returns true if the event is part of the sample, which is always
+// the case when sampling is not configured. The decision only depends on the
+// event ID, so that every replica and every delivery attempt of the event
+// take the same one.
+func sampled(s *cfgbroker.Sampling, event *cloudevents.Event) bool {
+	if s == nil {
+		return true
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(event.ID()))
+	sum := h.Sum64()
+
+	switch {
+	case s.Every != nil:
+		return sum%uint64(*s.Every) == 0
+	case s.Percentage != nil:
+		return sum%samplingScale < uint64(*s.Percentage*samplingScale/100)
+	}
+	return true
+}
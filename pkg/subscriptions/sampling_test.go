@@ -0,0 +1,52 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestSampled(t *testing.T) {
+	percentage := func(p float64) *cfgbroker.Sampling { return &cfgbroker.Sampling{Percentage: &p} }
+	every := func(k int32) *cfgbroker.Sampling { return &cfgbroker.Sampling{Every: &k} }
+
+	tcs := map[string]struct {
+		sampling *cfgbroker.Sampling
+		min, max int
+	}{
+		"not configured":  {min: 10000, max: 10000},
+		"all":             {sampling: percentage(100), min: 10000, max: 10000},
+		"none":            {sampling: percentage(0), min: 0, max: 0},
+		"ten percent":     {sampling: percentage(10), min: 900, max: 1100},
+		"one of every 1":  {sampling: every(1), min: 10000, max: 10000},
+		"one of every 20": {sampling: every(20), min: 450, max: 550},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			n := 0
+			for i := 0; i < 10000; i++ {
+				ev := lib.NewCloudEvent(lib.CloudEventWithIDOption(strconv.Itoa(i)))
+				if sampled(tc.sampling, &ev) {
+					n++
+				}
+			}
+			assert.GreaterOrEqual(t, n, tc.min)
+			assert.LessOrEqual(t, n, tc.max)
+		})
+	}
+
+	// The decision only depends on the event ID.
+	a := lib.NewCloudEvent(lib.CloudEventWithIDOption("abc"), lib.CloudEventWithTypeOption("a"))
+	b := lib.NewCloudEvent(lib.CloudEventWithIDOption("abc"), lib.CloudEventWithTypeOption("b"))
+	for _, s := range []*cfgbroker.Sampling{percentage(50), every(3)} {
+		assert.Equal(t, sampled(s, &a), sampled(s, &b))
+	}
+}
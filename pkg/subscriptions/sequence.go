@@ -0,0 +1,105 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"go.uber.org/zap"
+	"knative.dev/eventing/pkg/eventfilter"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// SequenceStepExtension informs the sequence error destinations of the
+// index of the step that did not accept the event.
+const SequenceStepExtension = "triggermeshsequencestep"
+
+type sequenceKey struct{}
+
+// withSequence returns a context whose deliveries send the events the
+// target replies with through the trigger sequence.
+func withSequence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sequenceKey{}, true)
+}
+
+// inSequence returns true if the deliveries of the context are to the
+// trigger target, whose replies go through the trigger sequence.
+func inSequence(ctx context.Context) bool {
+	v, _ := ctx.Value(sequenceKey{}).(bool)
+	return v
+}
+
+// runSequence sends the reply through the steps of the trigger sequence,
+// returning the event the last step replied with, nil if the sequence
+// ended without a reply.
+func (s delivery) runSequence(ctx context.Context, reply *cloudevents.Event) (*cloudevents.Event, error) {
+	for i := range s.trigger.Sequence {
+		step := &s.trigger.Sequence[i]
+		if s.sequenceFilters[i].Filter(ctx, *reply) == eventfilter.FailFilter {
+			s.debugw(ctx, "Skipped sequence step due to filter", zap.Int("step", i), zap.String("id", reply.ID()))
+			continue
+		}
+
+		res, result := s.sendToDestination(ctx, step.URL, step.Ref, reply)
+		if !cloudevents.IsACK(result) {
+			s.logger.Errorw("Sequence step did not accept the event", zap.String("trigger", s.name), zap.Int("step", i),
+				zap.Error(result), zap.String("type", reply.Type()), zap.String("source", reply.Source()), zap.String("id", reply.ID()))
+			return nil, s.divertSequenceError(ctx, i, step, reply, result)
+		}
+
+		if res == nil {
+			s.debugw(ctx, "Sequence ended without reply", zap.Int("step", i), zap.String("id", reply.ID()))
+			return nil, nil
+		}
+		reply = res
+	}
+
+	return reply, nil
+}
+
+// divertSequenceError sends the event that the step did not accept to the
+// step error destination, informing the step at an extension. Returns an
+// error if the step has no error destination or it did not accept the
+// event.
+func (s delivery) divertSequenceError(ctx context.Context, i int, step *cfgbroker.SequenceStep, event *cloudevents.Event, result protocol.Result) error {
+	if step.OnError == nil {
+		return fmt.Errorf("sequence step %d failed: %w", i, result)
+	}
+
+	diverted := event.Clone()
+	diverted.SetExtension(SequenceStepExtension, int32(i))
+	if _, result := s.sendToDestination(ctx, step.OnError.URL, step.OnError.Ref, &diverted); !cloudevents.IsACK(result) {
+		s.logger.Errorw("Sequence error destination did not accept the event", zap.String("trigger", s.name), zap.Int("step", i),
+			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return fmt.Errorf("sequence step %d failed and its error destination did not accept the event: %w", i, result)
+	}
+
+	s.debugw(ctx, "Event sent to the sequence error destination", zap.Int("step", i), zap.String("id", event.ID()))
+	return nil
+}
+
+// sendToDestination sends the event to the URL or the referenced object,
+// returning the event it replied with, if any.
+func (s delivery) sendToDestination(ctx context.Context, u *string, ref *cfgbroker.Reference, event *cloudevents.Event) (*cloudevents.Event, protocol.Result) {
+	var target string
+	if ref != nil {
+		var err error
+		if target, err = s.resolveReference(ctx, ref, u); err != nil {
+			return nil, fmt.Errorf("could not resolve the destination: %w", err)
+		}
+	} else {
+		target = *u
+	}
+
+	res, result := s.client.Request(cloudevents.ContextWithTarget(s.parentCtx, target), *event)
+	result = httpResultOutcome(result)
+	if !cloudevents.IsACK(result) {
+		s.invalidateReference(ref, result)
+	}
+	return res, result
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+// replyingServer replies to the events it receives with an event of the
+// given type, or with the status code when not 200.
+func replyingServer(t *testing.T, replyType string, code *int, received *[]cloudevents.Event) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+		require.NoError(t, err)
+		*received = append(*received, *ev)
+
+		if *code != http.StatusOK {
+			w.WriteHeader(*code)
+			return
+		}
+		reply := lib.NewCloudEvent(lib.CloudEventWithTypeOption(replyType))
+		require.NoError(t, cehttp.WriteResponseWriter(r.Context(), binding.ToMessage(&reply), *code, w))
+	}))
+}
+
+func TestSequence(t *testing.T) {
+	var atTarget, atPrice, atSkipped, atShip, atErrors []cloudevents.Event
+	ok, accepted, shipStatus := http.StatusOK, http.StatusAccepted, http.StatusOK
+	target := replyingServer(t, "order.validated", &ok, &atTarget)
+	defer target.Close()
+	price := replyingServer(t, "order.priced", &ok, &atPrice)
+	defer price.Close()
+	skipped := replyingServer(t, "order.skipped", &ok, &atSkipped)
+	defer skipped.Close()
+	ship := replyingServer(t, "order.shipped", &shipStatus, &atShip)
+	defer ship.Close()
+	errs := replyingServer(t, "", &accepted, &atErrors)
+	defer errs.Close()
+
+	rep, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	be := &replyRecorder{}
+	s := subscriber{
+		name:            "test-subscriber",
+		replies:         be,
+		reporter:        rep,
+		sharedTransport: DefaultHTTPTransportConfig().newTransport(),
+		parentCtx:       context.Background(),
+		maxHops:         10,
+		logger:          zaptest.NewLogger(t).Sugar(),
+	}
+
+	trigger := cfgbroker.Trigger{
+		Target: cfgbroker.Target{URL: &target.URL},
+		Sequence: []cfgbroker.SequenceStep{
+			{URL: &price.URL},
+			{URL: &skipped.URL, Filters: []cfgbroker.Filter{{Exact: map[string]string{"type": "order.validated"}}}},
+			{URL: &ship.URL, OnError: &cfgbroker.SequenceDestination{URL: &errs.URL}},
+		},
+	}
+	require.NoError(t, s.updateTrigger(trigger))
+
+	ev := lib.NewCloudEvent()
+	require.NoError(t, s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev))
+	require.Len(t, atPrice, 1)
+	assert.Equal(t, "order.validated", atPrice[0].Type(), "Steps must receive the reply of the previous step")
+	assert.Empty(t, atSkipped, "Steps must be skipped when their filters do not match")
+	require.Len(t, atShip, 1)
+	assert.Equal(t, "order.priced", atShip[0].Type())
+	require.Len(t, be.events, 1)
+	assert.Equal(t, "order.shipped", be.events[0].Type(), "The reply of the last step must be produced to the broker")
+
+	// Events not accepted by a step are sent to its error destination.
+	shipStatus = http.StatusInternalServerError
+	require.NoError(t, s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev))
+	require.Len(t, atErrors, 1)
+	assert.Equal(t, "order.priced", atErrors[0].Type())
+	assert.Equal(t, "2", atErrors[0].Extensions()[SequenceStepExtension])
+	assert.Len(t, be.events, 1)
+
+	// Deliveries fail when steps without error destination do not accept
+	// events.
+	trigger.Sequence[2].OnError = nil
+	require.NoError(t, s.updateTrigger(trigger))
+	assert.Error(t, s.view().dispatchCloudEventToTarget(s.view().ctx, s.parentCtx, &trigger.Target, &ev))
+	assert.Len(t, atErrors, 1)
+}
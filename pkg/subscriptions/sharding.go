@@ -0,0 +1,281 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Timeout for releasing the lease when the manager stops.
+const releaseLeaseTimeout = 5 * time.Second
+
+// shards assigns triggers to the replicas holding a lease at the backend,
+// by consistent hashing of the trigger names, so that each replica only
+// compiles and dispatches its share of the triggers.
+type shards struct {
+	registry backend.ReplicaRegistry
+	replica  string
+	lease    time.Duration
+
+	// Replicas holding a lease at the last renewal, sorted by name, and
+	// the ring built from them. No trigger is owned until the first
+	// renewal.
+	replicas []string
+	ring     *hashRing
+
+	// Leaser of the triggers assigned to the replica, which are only
+	// owned while the replica holds their lease, so that reassigned
+	// triggers are not dispatched by two replicas at once. Assigned
+	// triggers are owned right away if nil.
+	leaser backend.TriggerLeaser
+	// Triggers whose lease the replica holds and when it was last renewed,
+	// only modified when renewing the leases.
+	held map[string]time.Time
+}
+
+// leaseExpired returns whether a lease last renewed at the given time must
+// be given up. Leases are given up before they expire at the backend, by a
+// margin longer than the interval between renewals, each third of their
+// duration, so that they are never relied upon once another replica can
+// acquire them.
+func leaseExpired(renewed, now time.Time, lease time.Duration) bool {
+	return now.Sub(renewed) >= lease/2
+}
+
+// ManagerWithSharding only dispatches the triggers assigned to the replica
+// among those that renew their lease at the registry.
+func ManagerWithSharding(r backend.ReplicaRegistry, replica string, lease time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.shards = &shards{
+			registry: r,
+			replica:  replica,
+			lease:    lease,
+		}
+	}
+}
+
+// ManagerWithTriggerLeases only dispatches the triggers assigned to the
+// replica when sharding while the replica holds their lease at the leaser.
+func ManagerWithTriggerLeases(l backend.TriggerLeaser) ManagerOption {
+	return func(m *Manager) {
+		m.triggerLeaser = l
+	}
+}
+
+// owned returns the triggers assigned to the replica, whose lease it holds
+// when leasing triggers. The manager lock must be held.
+func (s *shards) owned(triggers map[string]cfgbroker.Trigger) map[string]cfgbroker.Trigger {
+	if s == nil {
+		return triggers
+	}
+
+	owned := make(map[string]cfgbroker.Trigger)
+	if s.ring == nil {
+		return owned
+	}
+	for name, t := range triggers {
+		if s.ring.get(name) != s.replica {
+			continue
+		}
+		if _, ok := s.held[name]; s.leaser != nil && !ok {
+			continue
+		}
+		owned[name] = t
+	}
+	return owned
+}
+
+// assigned returns the names of the triggers assigned to the replica. The
+// manager lock must be held.
+func (s *shards) assigned(triggers map[string]cfgbroker.Trigger) []string {
+	var names []string
+	if s.ring == nil {
+		return names
+	}
+	for name := range triggers {
+		if s.ring.get(name) == s.replica {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start renews the replica lease when sharding is enabled, each third of
+// the lease duration, releasing it once the context is done, or takes part
+// in the leader election when enabled. It returns an error if the first
+// renewal fails.
+func (m *Manager) Start(ctx context.Context) error {
+	if m.leadership != nil {
+		return m.startLeaderElection(ctx)
+	}
+	if m.shards == nil {
+		return nil
+	}
+
+	if err := m.renewLease(ctx); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(m.shards.lease / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			rctx, cancel := context.WithTimeout(context.Background(), releaseLeaseTimeout)
+			defer cancel()
+			if m.shards.leaser != nil {
+				m.releaseTriggerLeases(rctx, m.shards.held)
+			}
+			if err := m.shards.registry.ReleaseReplicaLease(rctx, m.shards.replica); err != nil {
+				m.logger.Warnw("Could not release sharding lease", zap.Error(err))
+			}
+			return nil
+
+		case <-t.C:
+			// Replicas that cannot renew their lease keep their triggers,
+			// which other replicas will also dispatch once the lease
+			// expires, sharing their backend subscription. Leased triggers
+			// are given up before their lease expires.
+			if err := m.renewLease(ctx); err != nil {
+				m.logger.Errorw("Could not renew sharding lease", zap.Error(err))
+			}
+		}
+	}
+}
+
+// renewLease renews the replica lease, reassigning the triggers when the
+// replicas holding a lease change, and renews the leases of the assigned
+// triggers when leasing triggers.
+func (m *Manager) renewLease(ctx context.Context) error {
+	replicas, err := m.shards.registry.RenewReplicaLease(ctx, m.shards.replica, m.shards.lease)
+	if err != nil {
+		if m.shards.leaser != nil {
+			m.expireTriggerLeases()
+		}
+		return fmt.Errorf("could not renew sharding lease: %w", err)
+	}
+	sort.Strings(replicas)
+
+	m.m.Lock()
+	changed := !reflect.DeepEqual(replicas, m.shards.replicas)
+	if changed {
+		m.shards.replicas = replicas
+		m.shards.ring = newHashRing(replicas)
+	}
+
+	if m.shards.leaser == nil {
+		defer m.m.Unlock()
+		if changed {
+			m.logger.Infow("Triggers reassigned to sharding replicas", zap.Strings("replicas", replicas))
+			if m.config != nil {
+				m.updateFromConfig(m.config)
+			}
+		}
+		return nil
+	}
+
+	var assigned []string
+	if m.config != nil {
+		assigned = m.shards.assigned(m.config.Triggers)
+	}
+	m.m.Unlock()
+
+	held := m.acquireTriggerLeases(ctx, assigned)
+	released := make(map[string]time.Time)
+	for name, renewed := range m.shards.held {
+		if _, ok := held[name]; !ok {
+			released[name] = renewed
+		}
+	}
+
+	m.m.Lock()
+	leasesChanged := len(released) != 0 || len(held) != len(m.shards.held)
+	m.shards.held = held
+	if changed || leasesChanged {
+		m.logger.Infow("Triggers reassigned to sharding replicas", zap.Strings("replicas", replicas),
+			zap.Int("leased", len(held)))
+		if m.config != nil {
+			m.updateFromConfig(m.config)
+		}
+	}
+	m.m.Unlock()
+
+	// Leases are released once the triggers are no longer dispatched.
+	m.releaseTriggerLeases(ctx, released)
+	return nil
+}
+
+// acquireTriggerLeases acquires or renews the leases of the triggers,
+// returning those held by the replica along with when they were renewed.
+// Triggers whose lease cannot be renewed due to errors are kept until
+// their last renewal expires.
+func (m *Manager) acquireTriggerLeases(ctx context.Context, triggers []string) map[string]time.Time {
+	held := make(map[string]time.Time, len(triggers))
+	for _, name := range triggers {
+		now := time.Now()
+		ok, err := m.shards.leaser.AcquireTriggerLease(ctx, name, m.shards.replica, m.shards.lease)
+		switch {
+		case err != nil:
+			m.logger.Errorw("Could not renew trigger lease", zap.String("trigger", name), zap.Error(err))
+			renewed, wasHeld := m.shards.held[name]
+			if !wasHeld {
+				break
+			}
+			if leaseExpired(renewed, time.Now(), m.shards.lease) {
+				m.logger.Warnw("Trigger lease expired, giving up the trigger", zap.String("trigger", name))
+				break
+			}
+			held[name] = renewed
+		case ok:
+			held[name] = now
+		default:
+			m.logger.Debugw("Trigger lease is held by another replica", zap.String("trigger", name))
+		}
+	}
+	return held
+}
+
+// expireTriggerLeases gives up the triggers whose lease expired, when the
+// leases cannot be renewed.
+func (m *Manager) expireTriggerLeases() {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	held := make(map[string]time.Time, len(m.shards.held))
+	now := time.Now()
+	for name, renewed := range m.shards.held {
+		if leaseExpired(renewed, now, m.shards.lease) {
+			m.logger.Warnw("Trigger lease expired, giving up the trigger", zap.String("trigger", name))
+			continue
+		}
+		held[name] = renewed
+	}
+	if len(held) == len(m.shards.held) {
+		return
+	}
+
+	m.shards.held = held
+	if m.config != nil {
+		m.updateFromConfig(m.config)
+	}
+}
+
+func (m *Manager) releaseTriggerLeases(ctx context.Context, triggers map[string]time.Time) {
+	for name := range triggers {
+		if err := m.shards.leaser.ReleaseTriggerLease(ctx, name, m.shards.replica); err != nil {
+			m.logger.Warnw("Could not release trigger lease", zap.String("trigger", name), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+type fakeRegistry struct {
+	leases map[string]time.Time
+	now    time.Time
+	err    error
+	m      sync.Mutex
+}
+
+func (r *fakeRegistry) RenewReplicaLease(_ context.Context, replica string, lease time.Duration) ([]string, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	r.leases[replica] = r.now.Add(lease)
+	replicas := []string{}
+	for name, expiry := range r.leases {
+		if expiry.After(r.now) {
+			replicas = append(replicas, name)
+		}
+	}
+	return replicas, nil
+}
+
+func (r *fakeRegistry) ReleaseReplicaLease(_ context.Context, replica string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.leases, replica)
+	return nil
+}
+
+type fakeLeaser struct {
+	holders map[string]string
+	err     error
+	m       sync.Mutex
+}
+
+func (l *fakeLeaser) AcquireTriggerLease(_ context.Context, trigger, replica string, _ time.Duration) (bool, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.err != nil {
+		return false, l.err
+	}
+
+	if holder, ok := l.holders[trigger]; ok && holder != replica {
+		return false, nil
+	}
+	l.holders[trigger] = replica
+	return true, nil
+}
+
+func (l *fakeLeaser) ReleaseTriggerLease(_ context.Context, trigger, replica string) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.holders[trigger] == replica {
+		delete(l.holders, trigger)
+	}
+	return nil
+}
+
+func TestSharding(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	ctx := context.Background()
+	r := &fakeRegistry{leases: map[string]time.Time{}, now: time.Now()}
+
+	c := &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{}}
+	for i := 0; i < 50; i++ {
+		c.Triggers[fmt.Sprintf("trigger-%d", i)] = cfgbroker.Trigger{}
+	}
+
+	newManager := func(replica string) *Manager {
+		b := memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger)
+		m, err := New(ctx, logger, b, ManagerWithSharding(r, replica, time.Minute))
+		require.NoError(t, err)
+		return m
+	}
+	owned := func(m *Manager) map[string]struct{} {
+		m.m.RLock()
+		defer m.m.RUnlock()
+		names := map[string]struct{}{}
+		for name := range m.subscribers {
+			names[name] = struct{}{}
+		}
+		return names
+	}
+
+	m1 := newManager("replica-1")
+	m1.UpdateFromConfig(c)
+	assert.Empty(t, owned(m1), "Triggers must not be owned before the first lease renewal")
+
+	require.NoError(t, m1.renewLease(ctx))
+	assert.Len(t, owned(m1), len(c.Triggers))
+
+	// Triggers are split when another replica takes a lease.
+	m2 := newManager("replica-2")
+	m2.UpdateFromConfig(c)
+	require.NoError(t, m2.renewLease(ctx))
+	require.NoError(t, m1.renewLease(ctx))
+
+	o1, o2 := owned(m1), owned(m2)
+	assert.NotEmpty(t, o1)
+	assert.NotEmpty(t, o2)
+	assert.Len(t, c.Triggers, len(o1)+len(o2))
+	for name := range o1 {
+		assert.NotContains(t, o2, name, "Trigger owned by both replicas")
+	}
+
+	// The remaining replica takes over the triggers of replicas whose
+	// lease expired.
+	r.m.Lock()
+	r.now = r.now.Add(2 * time.Minute)
+	r.m.Unlock()
+	require.NoError(t, m1.renewLease(ctx))
+	assert.Len(t, owned(m1), len(c.Triggers))
+}
+
+func TestExclusiveSharding(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	ctx := context.Background()
+	r := &fakeRegistry{leases: map[string]time.Time{}, now: time.Now()}
+	l := &fakeLeaser{holders: map[string]string{}}
+
+	c := &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{}}
+	for i := 0; i < 50; i++ {
+		c.Triggers[fmt.Sprintf("trigger-%d", i)] = cfgbroker.Trigger{}
+	}
+
+	newManager := func(replica string) *Manager {
+		b := memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger)
+		m, err := New(ctx, logger, b, ManagerWithSharding(r, replica, time.Minute), ManagerWithTriggerLeases(l))
+		require.NoError(t, err)
+		m.UpdateFromConfig(c)
+		return m
+	}
+	owned := func(m *Manager) map[string]struct{} {
+		m.m.RLock()
+		defer m.m.RUnlock()
+		names := map[string]struct{}{}
+		for name := range m.subscribers {
+			names[name] = struct{}{}
+		}
+		return names
+	}
+
+	m1 := newManager("replica-1")
+	require.NoError(t, m1.renewLease(ctx))
+	assert.Len(t, owned(m1), len(c.Triggers))
+	assert.Len(t, l.holders, len(c.Triggers))
+
+	// The new replica does not dispatch its share of the triggers until the
+	// previous owner releases their leases.
+	m2 := newManager("replica-2")
+	require.NoError(t, m2.renewLease(ctx))
+	assert.Empty(t, owned(m2), "Triggers leased by another replica must not be owned")
+
+	require.NoError(t, m1.renewLease(ctx))
+	o1 := owned(m1)
+	assert.NotEmpty(t, o1)
+	assert.Less(t, len(o1), len(c.Triggers))
+	assert.Len(t, l.holders, len(o1), "Leases of reassigned triggers must be released")
+
+	require.NoError(t, m2.renewLease(ctx))
+	o2 := owned(m2)
+	assert.Len(t, c.Triggers, len(o1)+len(o2))
+	for name := range o1 {
+		assert.NotContains(t, o2, name, "Trigger owned by both replicas")
+	}
+}
+
+func TestExclusiveShardingExpiredLeases(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+	ctx := context.Background()
+	lease := 100 * time.Millisecond
+
+	c := &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{}}
+	for i := 0; i < 5; i++ {
+		c.Triggers[fmt.Sprintf("trigger-%d", i)] = cfgbroker.Trigger{}
+	}
+
+	testCases := map[string]struct {
+		fail func(r *fakeRegistry, l *fakeLeaser)
+	}{
+		"trigger leases not renewed": {
+			fail: func(_ *fakeRegistry, l *fakeLeaser) { l.err = errors.New("backend unavailable") },
+		},
+		"replica lease not renewed": {
+			fail: func(r *fakeRegistry, _ *fakeLeaser) { r.err = errors.New("backend unavailable") },
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := &fakeRegistry{leases: map[string]time.Time{}, now: time.Now()}
+			l := &fakeLeaser{holders: map[string]string{}}
+
+			b := memory.New(&memory.MemoryArgs{BufferSize: 10, ProduceTimeout: "PT1S"}, logger)
+			m, err := New(ctx, logger, b, ManagerWithSharding(r, "replica-1", lease), ManagerWithTriggerLeases(l))
+			require.NoError(t, err)
+			m.UpdateFromConfig(c)
+			owned := func() int {
+				m.m.RLock()
+				defer m.m.RUnlock()
+				return len(m.subscribers)
+			}
+
+			require.NoError(t, m.renewLease(ctx))
+			assert.Equal(t, len(c.Triggers), owned())
+
+			// Triggers are kept while their last renewal is recent, and
+			// given up before the lease expires at the backend.
+			tc.fail(r, l)
+			_ = m.renewLease(ctx)
+			assert.Equal(t, len(c.Triggers), owned(), "Triggers must be kept until their lease is about to expire")
+
+			time.Sleep(lease / 2)
+			_ = m.renewLease(ctx)
+			assert.Zero(t, owned(), "Triggers whose lease is about to expire must be given up")
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"knative.dev/eventing/pkg/eventfilter"
+
+	"github.com/triggermesh/brokers/pkg/common/urltemplate"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/stream"
+)
+
+// errUnsubscribed is returned to the backend for events dispatched after
+// the subscriber was unsubscribed.
+var errUnsubscribed = errors.New("trigger was unsubscribed")
+
+// triggerSnapshot is the immutable state built from a trigger
+// configuration. Updating the trigger builds a new snapshot that replaces
+// the current one atomically, so that dispatching events does not lock
+// the subscriber while delivering. Replaced snapshots release their
+// resources once the deliveries that were using them finish.
+type triggerSnapshot struct {
+	trigger cfgbroker.Trigger
+
+	// Filter compiled from the trigger filters.
+	filter eventfilter.Filter
+
+	// Filter compiled from the reply target filters, nil if there is no
+	// reply target.
+	replyFilter eventfilter.Filter
+
+	// Filters compiled from the filters of each sequence step.
+	sequenceFilters []eventfilter.Filter
+
+	// Local context used to send CloudEvents, which contains the target
+	// and delivery options.
+	ctx context.Context
+
+	// Client for the target, along with its dedicated transport when the
+	// target overrides the connection settings.
+	client    cloudevents.Client
+	transport *http.Transport
+
+	// Activation conditions, nil if the trigger is always active.
+	activation *activationGate
+
+	// Circuit breaker for the target, nil if not configured.
+	breaker *circuitBreaker
+
+	// Adaptive concurrency limiter for the target, nil if not configured.
+	limiter *concurrencyLimiter
+
+	// Headers and credentials for the target, nil if not configured.
+	auth *targetAuth
+
+	// Batcher for events delivered to the target, nil if not configured.
+	batcher *batcher
+
+	// Load balancer for the target endpoints, nil if not configured.
+	balancer *loadBalancer
+
+	// Consistent hash ring of the target replicas, nil if not configured.
+	replicas *hashRing
+
+	// Parsed target URL template, nil if the target URL is not templated.
+	urlTemplate *urltemplate.Template
+
+	// Kafka target, nil if the target is not Kafka.
+	kafka *kafkaTarget
+
+	// Object store target, nil if the target is not an object store.
+	objectStore *objectStoreTarget
+
+	// Stream of the trigger, nil if the target is not a stream.
+	stream *stream.Stream
+
+	// AMQP target, nil if the target is not AMQP.
+	amqp *amqpTarget
+
+	// Parsed ordering expression, nil if not configured.
+	orderingExpression *template.Template
+
+	// Honor the Retry-After header of the target responses, waiting up
+	// to the maximum when not zero.
+	retryAfter    bool
+	retryAfterMax time.Duration
+
+	// Deliveries using the snapshot, which is drained once retired and
+	// all of them finished.
+	inFlight atomic.Int64
+	retired  atomic.Bool
+	drained  chan struct{}
+	drain    sync.Once
+
+	// Drained channel of the snapshot this one replaced, which deliveries
+	// wait for until the deadline, nil if they do not wait. Deliveries
+	// that stop waiting are reported once.
+	previous         <-chan struct{}
+	previousDeadline time.Time
+	previousTimeout  sync.Once
+}
+
+func newTriggerSnapshot() *triggerSnapshot {
+	return &triggerSnapshot{drained: make(chan struct{})}
+}
+
+// acquire registers a delivery using the snapshot, returning false if the
+// snapshot was retired.
+func (ts *triggerSnapshot) acquire() bool {
+	ts.inFlight.Add(1)
+	if ts.retired.Load() {
+		ts.release()
+		return false
+	}
+	return true
+}
+
+// release unregisters a delivery using the snapshot.
+func (ts *triggerSnapshot) release() {
+	if ts.inFlight.Add(-1) == 0 && ts.retired.Load() {
+		ts.drain.Do(func() { close(ts.drained) })
+	}
+}
+
+// awaitPrevious waits for the deliveries in flight of the replaced
+// snapshot, returning false if they did not finish before the deadline or
+// the context is done.
+func (ts *triggerSnapshot) awaitPrevious(ctx context.Context) bool {
+	if ts.previous == nil {
+		return true
+	}
+
+	select {
+	case <-ts.previous:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(time.Until(ts.previousDeadline))
+	defer timer.Stop()
+
+	select {
+	case <-ts.previous:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return false
+}
+
+// retire rejects new deliveries, and calls the cleanup function once the
+// deliveries in flight finish.
+func (ts *triggerSnapshot) retire(cleanup func()) {
+	ts.retired.Store(true)
+	if ts.inFlight.Load() == 0 {
+		ts.drain.Do(func() { close(ts.drained) })
+	}
+
+	go func() {
+		<-ts.drained
+		cleanup()
+	}()
+}
+
+// close releases the resources of the snapshot that are not used by the
+// next one, all of them if nil.
+func (ts *triggerSnapshot) close(next *triggerSnapshot) {
+	if next == nil {
+		next = &triggerSnapshot{}
+	}
+
+	if ts.activation != nil && ts.activation != next.activation {
+		ts.activation.stop()
+	}
+	if ts.transport != nil && ts.transport != next.transport {
+		ts.transport.CloseIdleConnections()
+	}
+	if ts.balancer != nil && ts.balancer != next.balancer {
+		ts.balancer.close()
+	}
+	if ts.kafka != nil && ts.kafka != next.kafka {
+		ts.kafka.close()
+	}
+	if ts.stream != nil && ts.stream != next.stream {
+		ts.stream.Close()
+	}
+	if ts.amqp != nil && ts.amqp != next.amqp {
+		ts.amqp.close()
+	}
+}
+
+// delivery is the view of a subscriber through one of its trigger
+// snapshots, which methods delivering events are defined on.
+type delivery struct {
+	*subscriber
+	*triggerSnapshot
+}
+
+// snapshot returns the delivery view of the current trigger snapshot,
+// which must be released when done. False is returned if the subscriber
+// was unsubscribed.
+func (s *subscriber) snapshot() (delivery, bool) {
+	for {
+		ts := s.current.Load()
+		if ts == nil {
+			return delivery{}, false
+		}
+		if ts.acquire() {
+			return delivery{subscriber: s, triggerSnapshot: ts}, true
+		}
+		// Retired snapshots that are still current belong to
+		// unsubscribed subscribers.
+		if s.current.Load() == ts {
+			return delivery{}, false
+		}
+	}
+}
+
+// view returns the delivery view of the current trigger snapshot without
+// registering a delivery, for reading its state. Subscribers without
+// trigger use an empty snapshot along with their client.
+func (s *subscriber) view() delivery {
+	ts := s.current.Load()
+	if ts == nil {
+		ts = newTriggerSnapshot()
+		ts.client = s.ceClient
+	}
+	return delivery{subscriber: s, triggerSnapshot: ts}
+}
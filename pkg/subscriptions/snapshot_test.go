@@ -0,0 +1,108 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestTriggerSnapshot(t *testing.T) {
+	s := subscriber{
+		name:      "test-subscriber",
+		parentCtx: context.Background(),
+		logger:    zaptest.NewLogger(t).Sugar(),
+	}
+
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{}))
+	d, ok := s.snapshot()
+	require.True(t, ok)
+
+	// Updates do not wait for the deliveries in flight, which keep
+	// using the snapshot they started with.
+	url := "http://target"
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url}}))
+	assert.Nil(t, d.trigger.Target.URL)
+	assert.Equal(t, &url, s.view().trigger.Target.URL)
+
+	retired := d.triggerSnapshot
+	select {
+	case <-retired.drained:
+		t.Fatal("Replaced snapshots must not be drained while deliveries are in flight")
+	default:
+	}
+	d.release()
+	select {
+	case <-retired.drained:
+	case <-time.After(time.Second):
+		t.Fatal("Replaced snapshot was not drained")
+	}
+
+	// Unsubscribed subscribers reject deliveries.
+	s.close()
+	_, ok = s.snapshot()
+	assert.False(t, ok)
+	ev := lib.NewCloudEvent()
+	assert.ErrorIs(t, s.dispatchCloudEvent(&ev), errUnsubscribed)
+}
+
+func TestTriggerSnapshotUpdateDrain(t *testing.T) {
+	s := subscriber{
+		name:               "test-subscriber",
+		parentCtx:          context.Background(),
+		updateDrainTimeout: time.Hour,
+		logger:             zaptest.NewLogger(t).Sugar(),
+	}
+
+	url := "http://target"
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url}}))
+	d, ok := s.snapshot()
+	require.True(t, ok)
+
+	// Changes not affecting the filters nor the target do not wait.
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url}, DryRun: true}))
+	assert.True(t, s.view().awaitPrevious(context.Background()))
+	d.release()
+
+	d, ok = s.snapshot()
+	require.True(t, ok)
+
+	// Deliveries of the new target wait for those of the former one.
+	other := "http://other"
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &other}}))
+	next := s.view()
+
+	done := make(chan bool)
+	go func() {
+		done <- next.awaitPrevious(context.Background())
+	}()
+	select {
+	case <-done:
+		t.Fatal("Deliveries must wait for those in flight of the former definition")
+	case <-time.After(50 * time.Millisecond):
+	}
+	d.release()
+	select {
+	case waited := <-done:
+		assert.True(t, waited)
+	case <-time.After(time.Second):
+		t.Fatal("Deliveries did not proceed once the former ones finished")
+	}
+
+	// Deliveries proceed when the former ones do not finish in time.
+	s.updateDrainTimeout = 10 * time.Millisecond
+	d, ok = s.snapshot()
+	require.True(t, ok)
+	defer d.release()
+	require.NoError(t, s.updateTrigger(cfgbroker.Trigger{Target: cfgbroker.Target{URL: &url}}))
+	assert.False(t, s.view().awaitPrevious(context.Background()))
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/triggermesh/brokers/pkg/fixtures"
+	"github.com/triggermesh/brokers/pkg/status"
+)
+
+// deliveryStats keeps track of deliveries to the trigger target.
+type deliveryStats struct {
+	hasTarget    bool
+	filterErrors []string
+
+	delivered        uint64
+	failed           uint64
+	lastFailed       bool
+	lastDeliveryTime *time.Time
+	lastError        string
+	lastErrorTime    *time.Time
+
+	// Events evaluated by the filters, and those that matched.
+	evaluated uint64
+	matched   uint64
+
+	// Deliveries handled as fixtures by outcome.
+	fixtures map[string]uint64
+
+	m sync.Mutex
+}
+
+func (d *deliveryStats) setTarget(url string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.hasTarget != (url != "") {
+		d.lastFailed = false
+	}
+	d.hasTarget = url != ""
+}
+
+func (d *deliveryStats) setFilterErrors(errs []string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.filterErrors = errs
+}
+
+func (d *deliveryStats) record(err error) {
+	now := time.Now()
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if err == nil {
+		d.delivered++
+		d.lastFailed = false
+		d.lastDeliveryTime = &now
+		return
+	}
+
+	d.failed++
+	d.lastFailed = true
+	d.lastError = err.Error()
+	d.lastErrorTime = &now
+}
+
+func (d *deliveryStats) recordFilter(matched bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.evaluated++
+	if matched {
+		d.matched++
+	}
+}
+
+func (d *deliveryStats) recordFixture(outcome fixtures.Outcome) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.fixtures == nil {
+		d.fixtures = make(map[string]uint64)
+	}
+	d.fixtures[string(outcome)]++
+}
+
+func (d *deliveryStats) status() status.TriggerStatus {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	ts := status.TriggerStatus{
+		Ready:            true,
+		Delivered:        d.delivered,
+		Failed:           d.failed,
+		LastDeliveryTime: d.lastDeliveryTime,
+		LastError:        d.lastError,
+		LastErrorTime:    d.lastErrorTime,
+		FilterErrors:     d.filterErrors,
+		Evaluated:        d.evaluated,
+		Matched:          d.matched,
+	}
+
+	if d.evaluated != 0 {
+		rate := float64(d.matched) / float64(d.evaluated)
+		ts.MatchRate = &rate
+	}
+
+	if len(d.fixtures) != 0 {
+		ts.Fixtures = make(map[string]uint64, len(d.fixtures))
+		for outcome, n := range d.fixtures {
+			ts.Fixtures[outcome] = n
+		}
+	}
+
+	switch {
+	case len(d.filterErrors) != 0:
+		ts.Ready = false
+		ts.Reason = "FilterCompilationFailed"
+	case !d.hasTarget:
+		ts.Ready = false
+		ts.Reason = "TargetNotConfigured"
+	case d.lastFailed:
+		ts.Ready = false
+		ts.Reason = "DeliveryFailed"
+	}
+
+	return ts
+}
+
+// Status returns the delivery status for each trigger.
+func (m *Manager) Status() *status.Status {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	s := &status.Status{
+		Time:     time.Now(),
+		Triggers: make(map[string]status.TriggerStatus, len(m.subscribers)),
+	}
+	for name, sub := range m.subscribers {
+		s.Triggers[name] = sub.status()
+	}
+
+	// Triggers that were not activated due to filter errors.
+	for name, r := range m.rejected {
+		s.Triggers[name] = status.TriggerStatus{
+			Ready:        false,
+			Reason:       "FilterCompilationFailed",
+			FilterErrors: r.errors,
+		}
+	}
+
+	return s
+}
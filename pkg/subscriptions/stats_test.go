@@ -0,0 +1,123 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/pkg/fixtures"
+)
+
+func TestDeliveryStats(t *testing.T) {
+	tcs := map[string]struct {
+		target       string
+		filterErrors []string
+		deliveries   []error
+		filters      []bool
+		fixtures     []fixtures.Outcome
+
+		ready     bool
+		reason    string
+		delivered uint64
+		failed    uint64
+		lastError string
+		matchRate *float64
+		outcomes  map[string]uint64
+	}{
+		"no target": {
+			ready:  false,
+			reason: "TargetNotConfigured",
+		},
+		"filter errors": {
+			target:       "http://target",
+			filterErrors: []string{"unknown attribute"},
+			ready:        false,
+			reason:       "FilterCompilationFailed",
+		},
+		"delivered": {
+			target:     "http://target",
+			deliveries: []error{nil, nil},
+			ready:      true,
+			delivered:  2,
+		},
+		"last delivery failed": {
+			target:     "http://target",
+			deliveries: []error{nil, errors.New("connection refused")},
+			ready:      false,
+			reason:     "DeliveryFailed",
+			delivered:  1,
+			failed:     1,
+			lastError:  "connection refused",
+		},
+		"recovered from failure": {
+			target:     "http://target",
+			deliveries: []error{errors.New("connection refused"), nil},
+			ready:      true,
+			delivered:  1,
+			failed:     1,
+			lastError:  "connection refused",
+		},
+		"filter evaluations": {
+			target:    "http://target",
+			filters:   []bool{true, false, false, true},
+			ready:     true,
+			matchRate: func() *float64 { r := 0.5; return &r }(),
+		},
+		"fixtures": {
+			target:   "http://target",
+			fixtures: []fixtures.Outcome{fixtures.OutcomeMatched, fixtures.OutcomeMatched, fixtures.OutcomeMismatched},
+			ready:    true,
+			outcomes: map[string]uint64{"matched": 2, "mismatched": 1},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			d := &deliveryStats{}
+			d.setTarget(tc.target)
+			d.setFilterErrors(tc.filterErrors)
+			for _, err := range tc.deliveries {
+				d.record(err)
+			}
+			for _, matched := range tc.filters {
+				d.recordFilter(matched)
+			}
+			for _, o := range tc.fixtures {
+				d.recordFixture(o)
+			}
+
+			ts := d.status()
+			assert.Equal(t, tc.ready, ts.Ready)
+			assert.Equal(t, tc.reason, ts.Reason)
+			assert.Equal(t, tc.delivered, ts.Delivered)
+			assert.Equal(t, tc.failed, ts.Failed)
+			assert.Equal(t, tc.lastError, ts.LastError)
+			assert.Equal(t, tc.matchRate, ts.MatchRate)
+			assert.Equal(t, tc.outcomes, ts.Fixtures)
+			assert.Equal(t, uint64(len(tc.filters)), ts.Evaluated)
+		})
+	}
+}
+
+func TestDeliveryStatsTargetChange(t *testing.T) {
+	d := &deliveryStats{}
+	d.setTarget("http://target")
+	d.record(errors.New("connection refused"))
+	require.Equal(t, "DeliveryFailed", d.status().Reason)
+
+	// Changing the target URL keeps the former failure.
+	d.setTarget("http://other-target")
+	assert.Equal(t, "DeliveryFailed", d.status().Reason)
+
+	// Removing the target clears the former failure.
+	d.setTarget("")
+	d.setTarget("http://target")
+	ts := d.status()
+	assert.True(t, ts.Ready)
+	assert.Equal(t, uint64(1), ts.Failed)
+}
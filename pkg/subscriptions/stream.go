@@ -0,0 +1,39 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"go.uber.org/zap"
+)
+
+// deliverToStream buffers the event for the consumers of the trigger
+// stream, failing if they did not catch up and the buffer is full.
+func (s delivery) deliverToStream(ctx context.Context, event *cloudevents.Event) error {
+	start := time.Now()
+
+	err := s.stream.Publish(event)
+
+	var result protocol.Result = protocol.ResultACK
+	if err != nil {
+		result = err
+	}
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
+
+	target := cloudevents.TargetFromContext(ctx).String()
+	if err != nil {
+		s.logger.Errorw(fmt.Sprintf("Failed to push event to %s", target),
+			zap.Error(err), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return err
+	}
+
+	s.debugw(ctx, fmt.Sprintf("Event pushed to %s", target), zap.String("id", event.ID()))
+	return nil
+}
@@ -5,10 +5,17 @@ package subscriptions
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/rickb777/date/period"
 	"go.uber.org/zap"
 
@@ -16,40 +23,224 @@ import (
 	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
 	"knative.dev/pkg/logging"
 
+	"github.com/triggermesh/brokers/pkg/audit"
 	"github.com/triggermesh/brokers/pkg/backend"
+	"github.com/triggermesh/brokers/pkg/common/debug"
+	"github.com/triggermesh/brokers/pkg/common/expiry"
+	"github.com/triggermesh/brokers/pkg/common/integrity"
+	"github.com/triggermesh/brokers/pkg/common/provenance"
+	"github.com/triggermesh/brokers/pkg/common/urltemplate"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/destination"
+	"github.com/triggermesh/brokers/pkg/features"
+	"github.com/triggermesh/brokers/pkg/firehose"
+	"github.com/triggermesh/brokers/pkg/sla"
+	"github.com/triggermesh/brokers/pkg/status"
+	"github.com/triggermesh/brokers/pkg/stream"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+	"github.com/triggermesh/brokers/pkg/subscriptions/wasm"
+	"github.com/triggermesh/brokers/pkg/throughput"
 )
 
-type subscriber struct {
-	trigger cfgbroker.Trigger
+// errNotDelivered is returned to the backend for events that were neither
+// delivered to the target nor to a dead letter sink.
+var errNotDelivered = errors.New("event was not delivered to the target nor to a dead letter sink")
 
+type subscriber struct {
 	name     string
 	backend  backend.Interface
+	reporter metrics.Reporter
+
+	// Client for targets that do not override the connection settings,
+	// created when the first trigger snapshot is built if nil.
 	ceClient cloudevents.Client
 
-	// We need to have both the parent context used to build the subscriber and the
-	// local context used to send CloudEvents that contains the target and delivery
-	// options.
-	// The local context will be re-created from the parent context every time a
-	// change is done to the trigger
+	// Producer for the replies of the target, the backend if nil.
+	replies backend.EventProducer
+
+	// Delivery connections use the shared transport unless the target
+	// overrides its settings, which creates a dedicated transport.
+	transportConfig HTTPTransportConfig
+	sharedTransport *http.Transport
+
+	integrity      bool
+	quarantinePath string
+
+	// auditSink is optional and receives a record per delivery.
+	auditSink audit.Sink
+
+	// firehose is optional and receives dispatch decisions.
+	firehose *firehose.Hub
+
+	// throughput is optional and counts dispatched events.
+	throughput *throughput.Recorder
+
+	// sla is optional and counts delivery outcomes.
+	sla *sla.Reporter
+
+	// fixtureStore is optional and keeps the deliveries recorded as
+	// fixtures.
+	fixtureStore backend.FixtureStore
+
+	// Honor the debug extension of events.
+	debug bool
+
+	// Events older than this time to live are not delivered.
+	ttl time.Duration
+
+	// Honor the Retry-After header of target responses, waiting up to the
+	// maximum when not zero. Triggers can override the maximum.
+	honorRetryAfter bool
+	maxRetryAfter   time.Duration
+
+	// Budget of the retries to targets shared by all subscribers,
+	// disabled if nil.
+	retryBudget *retryBudget
+
+	// Limiter of the requests in flight to target hosts shared by all
+	// subscribers, disabled if nil.
+	hostLimiter *hostLimiter
+
+	// Store of the retry state of events, which is not kept if nil.
+	retryStates backend.RetryStateStore
+
+	// Feature flags of the broker, at their default state if nil.
+	features *features.Set
+
+	// Backoffs of at least this time requeue the event at the backend
+	// instead of waiting. Zero disables requeueing.
+	requeueMinDelay time.Duration
+
+	// Time deliveries of updated triggers wait for those in flight using
+	// the former filter or target. Zero does not wait.
+	updateDrainTimeout time.Duration
+
+	// Hub of the streams of stream targets, which are not applied if nil.
+	streams *stream.Hub
+
+	// Resolver of the objects targets reference, which cannot be used
+	// if nil.
+	resolver destination.Resolver
+
+	// Hops a reply can do, when the event it replies to does not inform
+	// them.
+	maxHops int32
+
+	// Events dispatched asynchronously, which draining waits for. Not
+	// accounted if nil.
+	inFlight *inFlight
+
+	// Delivery statistics for status reporting.
+	stats deliveryStats
+
+	// Current trigger snapshot, replaced when the trigger is updated.
+	current atomic.Pointer[triggerSnapshot]
+
+	// Parent context used to build the subscriber, from which the
+	// snapshot local contexts are created.
 	parentCtx context.Context
-	ctx       context.Context
 
 	logger *zap.SugaredLogger
-	m      sync.RWMutex
+
+	// Serializes trigger updates, deliveries do not lock.
+	m sync.Mutex
 }
 
+// unsubscribe removes the subscription from the backend, releasing the
+// resources of the trigger snapshot once its deliveries finish.
 func (s *subscriber) unsubscribe() {
 	s.backend.Unsubscribe(s.name)
+	s.close()
 }
 
+// close retires the current trigger snapshot, rejecting deliveries.
+func (s *subscriber) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if ts := s.current.Load(); ts != nil {
+		ts.retire(func() {
+			ts.close(nil)
+		})
+	}
+}
+
+// updateTrigger builds a snapshot for the trigger that replaces the
+// current one, keeping the state of the settings that did not change.
 func (s *subscriber) updateTrigger(trigger cfgbroker.Trigger) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	prev := s.view()
+
 	// Target URL might be informed as empty to support temporary
 	// unavailability.
 	url := ""
 	if trigger.Target.URL != nil {
 		url = *trigger.Target.URL
 	}
+
+	var kafka *kafkaTarget
+	if trigger.Target.Kafka != nil {
+		// Keep the producer if neither the configuration nor the delivery
+		// options changed.
+		if prev.kafka != nil && reflect.DeepEqual(trigger.Target.Kafka, prev.trigger.Target.Kafka) &&
+			reflect.DeepEqual(trigger.Target.DeliveryOptions, prev.trigger.Target.DeliveryOptions) {
+			kafka = prev.kafka
+		} else {
+			var err error
+			if kafka, err = newKafkaTarget(trigger.Target.Kafka, trigger.Target.DeliveryOptions); err != nil {
+				return fmt.Errorf("could not apply trigger %q Kafka target: %w", s.name, err)
+			}
+		}
+		url = kafka.url()
+	}
+
+	var objectStore *objectStoreTarget
+	if trigger.Target.ObjectStore != nil {
+		// Keep the pending batch if neither the configuration, the
+		// batching nor the delivery options changed.
+		if prev.objectStore != nil && reflect.DeepEqual(trigger.Target.ObjectStore, prev.trigger.Target.ObjectStore) &&
+			reflect.DeepEqual(trigger.Batching, prev.trigger.Batching) &&
+			reflect.DeepEqual(trigger.Target.DeliveryOptions, prev.trigger.Target.DeliveryOptions) {
+			objectStore = prev.objectStore
+		} else {
+			var err error
+			if objectStore, err = newObjectStoreTarget(trigger.Target.ObjectStore, trigger.Batching, trigger.Target.DeliveryOptions); err != nil {
+				return fmt.Errorf("could not apply trigger %q object store target: %w", s.name, err)
+			}
+		}
+		url = objectStore.url()
+	}
+
+	var st *stream.Stream
+	if trigger.Target.Stream != nil {
+		if s.streams == nil {
+			return fmt.Errorf("could not apply trigger %q stream target: streams are not enabled", s.name)
+		}
+		size := stream.DefaultBufferSize
+		if trigger.Target.Stream.BufferSize != nil {
+			size = int(*trigger.Target.Stream.BufferSize)
+		}
+		st = s.streams.Stream(s.name, size)
+		url = "stream://" + s.name
+	}
+
+	var amqp *amqpTarget
+	if trigger.Target.AMQP != nil {
+		// Keep the connection if neither the configuration nor the
+		// delivery options changed.
+		if prev.amqp != nil && reflect.DeepEqual(trigger.Target.AMQP, prev.trigger.Target.AMQP) &&
+			reflect.DeepEqual(trigger.Target.DeliveryOptions, prev.trigger.Target.DeliveryOptions) {
+			amqp = prev.amqp
+		} else {
+			var err error
+			if amqp, err = newAMQPTarget(trigger.Target.AMQP, trigger.Target.DeliveryOptions); err != nil {
+				return fmt.Errorf("could not apply trigger %q AMQP target: %w", s.name, err)
+			}
+		}
+		url = amqp.url()
+	}
+
 	ctx := cloudevents.ContextWithTarget(s.parentCtx, url)
 
 	if trigger.Target.DeliveryOptions != nil &&
@@ -77,89 +268,845 @@ func (s *subscriber) updateTrigger(trigger cfgbroker.Trigger) error {
 		}
 	}
 
-	s.m.Lock()
-	defer s.m.Unlock()
+	if trigger.Target.Ref != nil && s.resolver == nil {
+		return fmt.Errorf("could not apply trigger %q target reference: no resolver is configured", s.name)
+	}
+
+	retryAfter, retryAfterMax := s.honorRetryAfter, s.maxRetryAfter
+	if retryAfter && trigger.Target.DeliveryOptions != nil && trigger.Target.DeliveryOptions.RetryAfterMax != nil {
+		p, err := period.Parse(*trigger.Target.DeliveryOptions.RetryAfterMax)
+		if err != nil {
+			return fmt.Errorf("could not apply trigger %q configuration due to retry after max parsing: %w", s.name, err)
+		}
+		// A zero maximum ignores the Retry-After header for the trigger.
+		retryAfterMax = p.DurationApprox()
+		retryAfter = retryAfterMax > 0
+	}
+
+	// Keep the cached credentials if neither the headers nor the
+	// authentication changed.
+	auth := prev.auth
+	if auth == nil || !reflect.DeepEqual(trigger.Target.Headers, prev.trigger.Target.Headers) ||
+		!reflect.DeepEqual(trigger.Target.Auth, prev.trigger.Target.Auth) {
+		var err error
+		if auth, err = newTargetAuth(s.parentCtx, trigger.Target.Headers, trigger.Target.Auth); err != nil {
+			return fmt.Errorf("could not apply trigger %q authentication: %w", s.name, err)
+		}
+	}
+	ctx = contextWithTargetAuth(ctx, auth)
 
-	s.trigger = trigger
-	s.ctx = ctx
+	// Conditions are first evaluated before replacing the current gate,
+	// without blocking deliveries.
+	updateActivation := !reflect.DeepEqual(trigger.Activation, prev.trigger.Activation) ||
+		(trigger.Activation != nil && prev.activation == nil)
+
+	var breaker *circuitBreaker
+	if trigger.Target.DeliveryOptions != nil && trigger.Target.DeliveryOptions.CircuitBreaker != nil {
+		// Keep the circuit state if the configuration did not change.
+		if prev.breaker != nil && prev.trigger.Target.DeliveryOptions != nil &&
+			reflect.DeepEqual(trigger.Target.DeliveryOptions.CircuitBreaker, prev.trigger.Target.DeliveryOptions.CircuitBreaker) {
+			breaker = prev.breaker
+		} else {
+			var err error
+			if breaker, err = newCircuitBreaker(trigger.Target.DeliveryOptions.CircuitBreaker); err != nil {
+				return fmt.Errorf("could not apply trigger %q circuit breaker: %w", s.name, err)
+			}
+		}
+	}
+
+	var limiter *concurrencyLimiter
+	if trigger.Target.DeliveryOptions != nil && trigger.Target.DeliveryOptions.AdaptiveConcurrency != nil {
+		// Keep the current limit if the configuration did not change.
+		if prev.limiter != nil && prev.trigger.Target.DeliveryOptions != nil &&
+			reflect.DeepEqual(trigger.Target.DeliveryOptions.AdaptiveConcurrency, prev.trigger.Target.DeliveryOptions.AdaptiveConcurrency) {
+			limiter = prev.limiter
+		} else {
+			var err error
+			if limiter, err = newConcurrencyLimiter(trigger.Target.DeliveryOptions.AdaptiveConcurrency); err != nil {
+				return fmt.Errorf("could not apply trigger %q adaptive concurrency: %w", s.name, err)
+			}
+			s.reporter.ReportConcurrencyLimit(limiter.currentLimit())
+		}
+	}
+
+	ceClient, transport := prev.client, prev.transport
+	if ceClient == nil {
+		ceClient = s.ceClient
+	}
+	if ceClient == nil || !reflect.DeepEqual(trigger.Target.HTTPClient, prev.trigger.Target.HTTPClient) {
+		var err error
+		if ceClient, transport, err = s.newCloudEventsClient(trigger.Target.HTTPClient); err != nil {
+			return fmt.Errorf("could not apply trigger %q HTTP client: %w", s.name, err)
+		}
+	}
+
+	// Object store targets batch events on their own.
+	var bt *batcher
+	if trigger.Batching != nil && objectStore == nil {
+		// Keep the batcher if neither its configuration nor the client changed.
+		if prev.batcher != nil && ceClient == prev.client && reflect.DeepEqual(trigger.Batching, prev.trigger.Batching) {
+			bt = prev.batcher
+		} else {
+			var rt http.RoundTripper = http.DefaultTransport
+			switch {
+			case transport != nil:
+				rt = transport
+			case s.sharedTransport != nil:
+				rt = s.sharedTransport
+			}
+
+			var err error
+			if bt, err = newBatcher(trigger.Batching, s.batchSender(&http.Client{Transport: tracingRoundTripper(withTargetAuth(rt))})); err != nil {
+				return fmt.Errorf("could not apply trigger %q batching: %w", s.name, err)
+			}
+		}
+	}
+
+	var lb *loadBalancer
+	if trigger.Target.LoadBalancer != nil && url != "" {
+		// Keep the endpoints health if neither the configuration, the URL nor the client changed.
+		if prev.balancer != nil && prev.trigger.Target.URL != nil && *prev.trigger.Target.URL == url &&
+			ceClient == prev.client && reflect.DeepEqual(trigger.Target.LoadBalancer, prev.trigger.Target.LoadBalancer) {
+			lb = prev.balancer
+		} else {
+			base := transport
+			if base == nil {
+				base = s.sharedTransport
+			}
+
+			var err error
+			if lb, err = newLoadBalancer(url, trigger.Target.LoadBalancer, base); err != nil {
+				return fmt.Errorf("could not apply trigger %q load balancer: %w", s.name, err)
+			}
+		}
+	}
+
+	if trigger.Fixtures != nil && s.fixtureStore == nil {
+		return fmt.Errorf("could not apply trigger %q fixtures: the backend does not support them", s.name)
+	}
+
+	var urlTemplate *urltemplate.Template
+	if urltemplate.IsTemplate(url) {
+		var err error
+		if urlTemplate, err = urltemplate.Parse(url); err != nil {
+			return fmt.Errorf("could not apply trigger %q target URL template: %w", s.name, err)
+		}
+	}
+
+	var replicas *hashRing
+	if len(trigger.Target.ReplicaURLs) != 0 && url != "" {
+		replicas = newHashRing(append([]string{url}, trigger.Target.ReplicaURLs...))
+	}
+
+	orderingExpression, err := newOrderingExpression(trigger.Ordering)
+	if err != nil {
+		return fmt.Errorf("could not apply trigger %q ordering: %w", s.name, err)
+	}
+
+	// Filters are compiled once per trigger, not once per event.
+	filter := prev.filter
+	if filter == nil || !reflect.DeepEqual(trigger.Filters, prev.trigger.Filters) {
+		filter = subscriptionsapi.NewAllFilter(materializeFiltersList(s.parentCtx, trigger.Filters)...)
+	}
+
+	var replyFilter eventfilter.Filter
+	if trigger.ReplyTarget != nil {
+		replyFilter = prev.replyFilter
+		if replyFilter == nil || prev.trigger.ReplyTarget == nil || !reflect.DeepEqual(trigger.ReplyTarget.Filters, prev.trigger.ReplyTarget.Filters) {
+			replyFilter = subscriptionsapi.NewAllFilter(materializeFiltersList(s.parentCtx, trigger.ReplyTarget.Filters)...)
+		}
+		if trigger.ReplyTarget.Ref != nil && s.resolver == nil {
+			return fmt.Errorf("could not apply trigger %q reply target reference: no resolver is configured", s.name)
+		}
+	}
+
+	sequenceFilters := make([]eventfilter.Filter, len(trigger.Sequence))
+	for i, step := range trigger.Sequence {
+		if i < len(prev.sequenceFilters) && reflect.DeepEqual(step.Filters, prev.trigger.Sequence[i].Filters) {
+			sequenceFilters[i] = prev.sequenceFilters[i]
+		} else {
+			sequenceFilters[i] = subscriptionsapi.NewAllFilter(materializeFiltersList(s.parentCtx, step.Filters)...)
+		}
+		if (step.Ref != nil || step.OnError != nil && step.OnError.Ref != nil) && s.resolver == nil {
+			return fmt.Errorf("could not apply trigger %q sequence step %d reference: no resolver is configured", s.name, i)
+		}
+	}
+
+	var gate *activationGate
+	if updateActivation && trigger.Activation != nil {
+		var err error
+		gate, err = newActivationGate(trigger.Activation, s.logger.With(zap.String("trigger", s.name)))
+		if err != nil {
+			return fmt.Errorf("could not apply trigger %q activation conditions: %w", s.name, err)
+		}
+		gate.start(s.parentCtx)
+	}
+
+	next := newTriggerSnapshot()
+	next.trigger = trigger
+	next.ctx = ctx
+	next.client = ceClient
+	next.transport = transport
+	next.activation = prev.activation
+	if updateActivation {
+		next.activation = gate
+	}
+	next.breaker = breaker
+	next.limiter = limiter
+	next.auth = auth
+	next.batcher = bt
+	next.balancer = lb
+	next.replicas = replicas
+	next.urlTemplate = urlTemplate
+	next.kafka = kafka
+	next.objectStore = objectStore
+	next.stream = st
+	next.amqp = amqp
+	next.orderingExpression = orderingExpression
+	next.retryAfter = retryAfter
+	next.retryAfterMax = retryAfterMax
+	next.filter = filter
+	next.replyFilter = replyFilter
+	next.sequenceFilters = sequenceFilters
+
+	if trigger.Target.Ref != nil {
+		s.stats.setTarget(trigger.Target.Ref.String())
+	} else {
+		s.stats.setTarget(url)
+	}
+
+	// Deliveries of a changed filter or target wait for those in flight
+	// using the former definition, so that events are not delivered to
+	// the former and new targets interleaved.
+	if old := s.current.Load(); old != nil && s.updateDrainTimeout > 0 &&
+		(!reflect.DeepEqual(trigger.Filters, old.trigger.Filters) ||
+			!reflect.DeepEqual(trigger.Target, old.trigger.Target)) {
+		next.previous = old.drained
+		next.previousDeadline = time.Now().Add(s.updateDrainTimeout)
+	}
+
+	// Resources that were not carried over to the new snapshot are
+	// released once the deliveries using the former one finish.
+	if old := s.current.Swap(next); old != nil {
+		old.retire(func() {
+			old.close(next)
+		})
+	}
 
 	return nil
 }
 
-func (s *subscriber) dispatchCloudEvent(event *cloudevents.Event) {
-	s.m.RLock()
-	defer s.m.RUnlock()
+// newCloudEventsClient creates the client for the target connection
+// settings, returning the dedicated transport if they are overridden.
+func (s *subscriber) newCloudEventsClient(h *cfgbroker.HTTPClient) (cloudevents.Client, *http.Transport, error) {
+	if h == nil {
+		c, err := newCloudEventsClient(s.sharedTransport, s.reporter)
+		return c, nil, err
+	}
+
+	tc, err := s.transportConfig.override(h)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	res := subscriptionsapi.NewAllFilter(materializeFiltersList(s.ctx, s.trigger.Filters)...).Filter(s.ctx, *event)
-	if res == eventfilter.FailFilter {
-		s.logger.Debugw("Skipped delivery due to filter", zap.Any("event", *event))
-		return
+	t := tc.newTransport()
+	c, err := newCloudEventsClient(t, s.reporter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, t, nil
+}
+
+// status returns the delivery status of the trigger.
+func (s *subscriber) status() status.TriggerStatus {
+	ts := s.stats.status()
+
+	d := s.view()
+	if d.activation != nil {
+		active := d.activation.isActive()
+		ts.Active = &active
+	}
+	ts.Paused = d.trigger.Paused
+	ts.DryRun = d.trigger.DryRun
+
+	if ts.Ready && d.breaker != nil && d.breaker.isOpen() {
+		ts.Ready = false
+		ts.Reason = "CircuitOpen"
+	}
+
+	return ts
+}
+
+// dispatchCloudEvent delivers the event using the current trigger
+// snapshot, which is not replaced while the delivery is in flight.
+func (s *subscriber) dispatchCloudEvent(event *cloudevents.Event) error {
+	d, ok := s.snapshot()
+	if !ok {
+		return errUnsubscribed
+	}
+
+	if !d.awaitPrevious(s.parentCtx) {
+		d.previousTimeout.Do(func() {
+			s.logger.Warnw("Delivering with the updated trigger while deliveries of its former definition are in flight",
+				zap.String("trigger", s.name))
+		})
+	}
+
+	// Batched deliveries wait for their batch to be sent, which would keep
+	// backends that dispatch events one at a time from completing batches.
+	// Those are acknowledged to the backend once dispatched to the batch,
+	// unless the trigger needs the delivery outcome for its guarantees.
+	if d.batched() && !d.atLeastOnce() && d.trigger.Ordering == nil {
+		ev := event.Clone()
+		if s.inFlight != nil {
+			s.inFlight.begin()
+		}
+		go func() {
+			defer d.release()
+			if s.inFlight != nil {
+				defer s.inFlight.end()
+			}
+			_ = d.dispatch(&ev)
+		}()
+		return nil
+	}
+
+	defer d.release()
+	return d.dispatch(event)
+}
+
+func (s delivery) dispatch(event *cloudevents.Event) error {
+	if s.integrity {
+		if err := integrity.Verify(event); err != nil {
+			if !errors.Is(err, integrity.ErrMissingHash) {
+				s.reporter.ReportIntegrityMismatch()
+				s.quarantine(event, err)
+				return nil
+			}
+			s.logger.Debugw("Delivering event without content hash", zap.String("id", event.ID()))
+		}
+	}
+
+	ctx, parentCtx := s.ctx, s.parentCtx
+
+	// Requeued events are only dispatched to the trigger they were
+	// requeued for, resuming their retries. They already matched the
+	// trigger filter, which is neither evaluated nor accounted again.
+	trigger, attempts, requeued := requeuedFor(event)
+	if requeued {
+		if trigger != s.name {
+			return nil
+		}
+		event = unwrapRequeued(event)
+		ctx = withRequeueAttempts(ctx, attempts)
+	}
+
+	if s.debug && debug.Enabled(event) {
+		ctx, parentCtx = debug.ContextWithEnabled(ctx), debug.ContextWithEnabled(parentCtx)
+		s.logger.Infow("Dispatching debug event", zap.Bool("debug", true), zap.String("trigger", s.name), zap.Any("event", *event))
+	}
+
+	if s.activation != nil && !s.activation.isActive() {
+		s.debugw(ctx, "Skipped delivery due to inactive trigger", zap.String("id", event.ID()))
+		s.publish(event, firehose.DecisionInactive)
+		return nil
+	}
+
+	if !requeued {
+		start := time.Now()
+		res := s.filter.Filter(ctx, *event)
+		s.reporter.ReportFilterEvaluation(res != eventfilter.FailFilter, float64(time.Since(start))/float64(time.Millisecond))
+		s.stats.recordFilter(res != eventfilter.FailFilter)
+		if res == eventfilter.FailFilter {
+			s.debugw(ctx, "Skipped delivery due to filter", zap.Any("event", *event))
+			s.publish(event, firehose.DecisionFiltered)
+			s.assertNotDelivered(ctx, event)
+			return nil
+		}
+	}
+
+	if s.trigger.DryRun {
+		s.debugw(ctx, "Skipped delivery due to dry run", zap.String("id", event.ID()))
+		s.publish(event, firehose.DecisionDryRun)
+		s.auditDryRun(ctx, event)
+		return nil
+	}
+
+	if !sampled(s.trigger.Sampling, event) {
+		s.debugw(ctx, "Skipped delivery due to sampling", zap.String("id", event.ID()))
+		s.reporter.ReportSampledOut()
+		s.publish(event, firehose.DecisionSampledOut)
+		return nil
 	}
 
 	t := s.trigger.Target
-	s.dispatchCloudEventToTarget(&t, event)
+
+	if expiry.Expired(event, s.ttl, time.Now()) {
+		s.reporter.ReportExpiredEvent()
+		s.publish(event, firehose.DecisionExpired)
+		if s.sendToDeadLetterSinks(parentCtx, &t, event) {
+			s.sla.DeadLettered(s.name)
+		} else {
+			s.debugw(ctx, "Expired event discarded",
+				zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		}
+		return nil
+	}
+
+	if guard, reason := checkGuards(s.trigger.Guards, event); reason != "" {
+		s.reporter.ReportGuardRejection(guard)
+		s.publish(event, firehose.DecisionGuarded)
+		s.divertGuarded(ctx, parentCtx, &t, event, reason)
+		return nil
+	}
+
+	err := s.dispatchCloudEventToTarget(ctx, parentCtx, &t, event)
+	if err != nil && s.atLeastOnce() {
+		return err
+	}
+	return nil
 }
 
-func (s *subscriber) dispatchCloudEventToTarget(target *cfgbroker.Target, event *cloudevents.Event) {
-	// Only try to send if target URL has been configured.
-	url := cloudevents.TargetFromContext(s.ctx)
-	if url != nil && s.send(s.ctx, event) {
-		return
+func (s delivery) dispatchCloudEventToTarget(ctx, parentCtx context.Context, target *cfgbroker.Target, event *cloudevents.Event) error {
+	if s.urlTemplate != nil {
+		ctx = s.resolveTarget(ctx, target, event)
+	}
+	if target.Ref != nil {
+		ctx = s.resolveDestination(ctx, target)
 	}
 
-	if target.DeliveryOptions != nil && target.DeliveryOptions.DeadLetterURL != nil &&
-		*target.DeliveryOptions.DeadLetterURL != "" {
-		dlsCtx := cloudevents.ContextWithTarget(s.parentCtx, *target.DeliveryOptions.DeadLetterURL)
-		if s.send(dlsCtx, event) {
-			return
+	// Only try to send if target URL has been configured.
+	url := cloudevents.TargetFromContext(ctx)
+	switch {
+	case url == nil:
+	case s.breaker != nil && !s.breaker.allow(time.Now()):
+		s.debugw(ctx, "Skipped target due to open circuit", zap.String("id", event.ID()))
+		s.publish(event, firehose.DecisionCircuitOpen)
+		s.sla.Undelivered(s.name)
+	default:
+		if s.replicas != nil {
+			key, ok := eventOrderingKey(s.trigger.Ordering, s.orderingExpression, event)
+			if !ok {
+				key = event.ID()
+			}
+			ctx = cloudevents.ContextWithTarget(ctx, s.replicas.get(key))
+		}
+
+		if len(s.sequenceFilters) != 0 {
+			ctx = withSequence(ctx)
+		}
+
+		var response *responseCapture
+		if s.trigger.Fixtures != nil {
+			ctx, response = withResponseCapture(ctx)
+		}
+
+		var err error
+		if s.limiter != nil {
+			err = s.limiter.acquire(ctx)
+		}
+
+		start := time.Now()
+		if err == nil {
+			switch {
+			case s.kafka != nil:
+				err = s.deliverToKafka(ctx, event)
+			case s.objectStore != nil:
+				err = s.deliverToObjectStore(ctx, event)
+			case s.stream != nil:
+				err = s.deliverToStream(ctx, event)
+			case s.amqp != nil:
+				err = s.deliverToAMQP(ctx, event)
+			case s.batcher != nil:
+				err = s.deliverBatched(ctx, target, event)
+			default:
+				err = s.deliverToTarget(ctx, target, event)
+			}
+
+			if s.limiter != nil {
+				if limit, changed := s.limiter.release(err, start, time.Now()); changed {
+					s.reporter.ReportConcurrencyLimit(limit)
+				}
+			}
+		}
+		if err == nil && response != nil {
+			s.handleFixture(ctx, event, response.event)
+		}
+		// Requeued events only count as undelivered once their retries
+		// are exhausted.
+		requeued := errors.Is(err, errRequeued)
+		s.stats.record(err)
+		s.throughput.Dispatched(s.name, err == nil)
+		if err == nil {
+			s.sla.Delivered(s.name, time.Since(start))
+		} else if !requeued {
+			s.sla.Undelivered(s.name)
+		}
+		if s.breaker != nil {
+			if state, changed := s.breaker.record(err, time.Now()); changed {
+				s.reporter.ReportCircuitBreakerTransition(string(state))
+				s.logger.Warnw("Target circuit breaker changed state", zap.String("trigger", s.name),
+					zap.String("state", string(state)), zap.String("target", url.String()))
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		s.invalidateReference(target.Ref, err)
+		if requeued {
+			return nil
 		}
 	}
 
-	// Attribute "lost": true is set help log aggregators identify
-	// lost events by querying.
-	msg := "Event was lost"
+	if s.sendToDeadLetterSinks(parentCtx, target, event) {
+		s.sla.DeadLettered(s.name)
+		return nil
+	}
+	s.sla.Lost(s.name)
+
+	var to string
 	if url != nil {
-		msg += " while sending to " + url.String()
+		to = " while sending to " + url.String()
+	}
+
+	// Events not acknowledged are dispatched again by the backend.
+	if s.atLeastOnce() {
+		s.logger.Errorw("Event was not delivered"+to+", it will be dispatched again",
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return errNotDelivered
 	}
-	s.logger.Errorw(msg, zap.Bool("lost", true),
+
+	// Attribute "lost": true is set help log aggregators identify
+	// lost events by querying.
+	s.logger.Errorw("Event was lost"+to, zap.Bool("lost", true),
 		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+	return errNotDelivered
 }
 
-func (s *subscriber) send(ctx context.Context, event *cloudevents.Event) bool {
-	res, result := s.ceClient.Request(ctx, *event)
+// batched returns true if events wait for the batch they are added to
+// before being delivered, which object store targets always do.
+func (s delivery) batched() bool {
+	return s.batcher != nil || s.objectStore != nil
+}
+
+// atLeastOnce returns true if events that could not be delivered must not
+// be acknowledged to the backend.
+func (s delivery) atLeastOnce() bool {
+	return s.trigger.DeliveryGuarantee != nil && *s.trigger.DeliveryGuarantee == cfgbroker.DeliveryGuaranteeAtLeastOnce
+}
+
+// sendToDeadLetterSinks escalates the event through the target dead letter
+// sinks, returning true if any of them accepted it.
+func (s delivery) sendToDeadLetterSinks(ctx context.Context, target *cfgbroker.Target, event *cloudevents.Event) bool {
+	if target.DeliveryOptions == nil {
+		return false
+	}
+
+	if target.DeliveryOptions.DeadLetterURL != nil && *target.DeliveryOptions.DeadLetterURL != "" {
+		dlsCtx := cloudevents.ContextWithTarget(ctx, *target.DeliveryOptions.DeadLetterURL)
+		if s.send(dlsCtx, event) {
+			return true
+		}
+	}
+
+	if target.DeliveryOptions.DeadLetterTarget != nil {
+		if s.sendToDeadLetterTarget(ctx, target.DeliveryOptions.DeadLetterTarget, event) {
+			return true
+		}
+	}
+
+	// Escalate through the chain of dead letter sinks.
+	for _, dls := range target.DeliveryOptions.DeadLetterSinks {
+		if s.sendToDeadLetterSink(ctx, &dls, event) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s delivery) sendToDeadLetterSink(ctx context.Context, dls *cfgbroker.DeadLetterSink, event *cloudevents.Event) bool {
+	switch {
+	case dls.URL != nil && *dls.URL != "":
+		return s.send(cloudevents.ContextWithTarget(ctx, *dls.URL), event)
+
+	case dls.File != nil && *dls.File != "":
+		if err := appendEventToFile(*dls.File, event); err != nil {
+			s.logger.Errorw(fmt.Sprintf("Failed to write event to dead letter file %s", *dls.File),
+				zap.Error(err), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+			return false
+		}
+		s.debugw(ctx, fmt.Sprintf("Event written to dead letter file %s", *dls.File), zap.String("id", event.ID()))
+		return true
+	}
+
+	return false
+}
+
+func (s delivery) send(ctx context.Context, event *cloudevents.Event) bool {
+	return s.deliver(ctx, event) == nil
+}
+
+// deliver sends the event to the target at the context, producing the
+// response to the backend, and returns an error if the delivery failed.
+func (s delivery) deliver(ctx context.Context, event *cloudevents.Event) error {
+	var host string
+	if s.hostLimiter != nil {
+		host = targetHost(ctx)
+		if err := s.hostLimiter.acquire(ctx, host); err != nil {
+			return fmt.Errorf("could not send event to target host %s: %w", host, err)
+		}
+	}
+
+	start := time.Now()
+	res, result := s.client.Request(ctx, *event)
+	if s.hostLimiter != nil {
+		s.hostLimiter.release(host)
+	}
+	result = httpResultOutcome(result)
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
 
 	switch {
 	case cloudevents.IsACK(result):
+		s.debugw(ctx, fmt.Sprintf("Event delivered to %s", cloudevents.TargetFromContext(ctx).String()),
+			zap.String("id", event.ID()), zap.Any("response", res))
+		captureResponse(ctx, res)
+		if res != nil && inSequence(ctx) {
+			var err error
+			if res, err = s.runSequence(ctx, res); err != nil {
+				return err
+			}
+		}
 		if res != nil {
-			if err := s.backend.Produce(ctx, res); err != nil {
+			rewriteReply(s.trigger.Reply, s.name, res)
+
+			if ok, err := s.inheritHops(ctx, event, res); !ok {
+				return err
+			}
+
+			if s.integrity {
+				if err := integrity.Sign(res); err != nil {
+					s.logger.Errorw("Failed to compute response content hash", zap.Error(err),
+						zap.String("type", res.Type()), zap.String("source", res.Source()), zap.String("id", res.ID()))
+					return fmt.Errorf("could not compute response content hash: %w", err)
+				}
+			}
+
+			if routed, err := s.routeReply(ctx, res); routed {
+				return err
+			}
+
+			replies := s.replies
+			if replies == nil {
+				replies = s.backend
+			}
+			if err := replies.Produce(ctx, res); err != nil {
 				s.logger.Errorw(fmt.Sprintf("Failed to consume response from %s",
 					cloudevents.TargetFromContext(ctx).String()),
 					zap.Error(err), zap.String("type", res.Type()), zap.String("source", res.Source()), zap.String("id", res.ID()))
 
 				// Not ingesting the response is considered an error.
 				// TODO make this configurable.
-				return false
+				return fmt.Errorf("could not consume response: %w", err)
 			}
 		}
-		return true
+		return nil
 
 	case cloudevents.IsUndelivered(result):
 		s.logger.Errorw(fmt.Sprintf("Failed to send event to %s",
 			cloudevents.TargetFromContext(ctx).String()),
 			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-		return false
+		return result
 
 	case cloudevents.IsNACK(result):
 		s.logger.Errorw(fmt.Sprintf("Event not accepted at %s",
 			cloudevents.TargetFromContext(ctx).String()),
 			zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-		return false
+		return result
 	}
 
 	s.logger.Errorw(fmt.Sprintf("Unknown event send outcome at %s",
 		cloudevents.TargetFromContext(ctx).String()),
 		zap.Error(result), zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
-	return false
+	return result
+}
+
+// httpResultOutcome returns the HTTP result for failed requests whose
+// response could not be converted into an event, which the CloudEvents
+// client reports as ACK.
+func httpResultOutcome(result protocol.Result) protocol.Result {
+	var httpResult *cehttp.Result
+	if result != nil && cloudevents.IsACK(result) && errors.As(result, &httpResult) &&
+		httpResult.StatusCode/100 != 2 {
+		return httpResult
+	}
+	return result
+}
+
+// deliverBatched sends the event to the target along with other events,
+// delivering it on its own if the batch is not accepted.
+func (s delivery) deliverBatched(ctx context.Context, target *cfgbroker.Target, event *cloudevents.Event) error {
+	start := time.Now()
+	result := s.batcher.add(ctx, event)
+	if !cloudevents.IsACK(result) {
+		return s.deliverToTarget(ctx, target, event)
+	}
+
+	s.audit(ctx, event, result, start)
+	s.publishDelivery(ctx, event, result, start)
+	s.debugw(ctx, fmt.Sprintf("Event delivered in batch to %s", cloudevents.TargetFromContext(ctx).String()),
+		zap.String("id", event.ID()))
+
+	return nil
+}
+
+// batchSender returns the function that sends batches to the target.
+func (s *subscriber) batchSender(client *http.Client) batchSender {
+	return func(ctx context.Context, events []*cloudevents.Event) protocol.Result {
+		var host string
+		if s.hostLimiter != nil {
+			host = targetHost(ctx)
+			if err := s.hostLimiter.acquire(ctx, host); err != nil {
+				return fmt.Errorf("could not send batch to target host %s: %w", host, err)
+			}
+		}
+
+		result := sendBatch(ctx, client, events)
+		if s.hostLimiter != nil {
+			s.hostLimiter.release(host)
+		}
+		if !cloudevents.IsACK(result) {
+			s.logger.Warnw("Batch not accepted, delivering its events one by one", zap.String("trigger", s.name),
+				zap.Int("events", len(events)), zap.Error(result))
+		}
+		return result
+	}
+}
+
+func (s *subscriber) audit(ctx context.Context, event *cloudevents.Event, result protocol.Result, start time.Time) {
+	dbg := debug.FromContext(ctx)
+	if s.auditSink == nil && !dbg {
+		return
+	}
+
+	r := &audit.Record{
+		Time:        start,
+		EventID:     event.ID(),
+		EventSource: event.Source(),
+		EventType:   event.Type(),
+		Trigger:     s.name,
+		Attempts:    1,
+		LatencyMs:   float64(time.Since(start)) / float64(time.Millisecond),
+		Provenance:  provenance.Chain(event),
+	}
+
+	if t := cloudevents.TargetFromContext(ctx); t != nil {
+		r.Target = t.String()
+	}
+
+	var rr *cehttp.RetriesResult
+	if protocol.ResultAs(result, &rr) {
+		r.Attempts += rr.Retries
+	}
+
+	r.Outcome = deliveryOutcome(result)
+	if result != nil && r.Outcome != audit.OutcomeDelivered {
+		r.Error = result.Error()
+	}
+
+	s.writeAudit(r)
+}
+
+// writeAudit writes the record to the audit sink, or to the log for debug
+// events if there is no audit sink.
+func (s *subscriber) writeAudit(r *audit.Record) {
+	if s.auditSink == nil {
+		s.logger.Infow("Delivery audit record", zap.Bool("debug", true), zap.Any("record", r))
+		return
+	}
+
+	s.auditSink.Write(r)
+}
+
+func deliveryOutcome(result protocol.Result) audit.Outcome {
+	switch {
+	case cloudevents.IsACK(result):
+		return audit.OutcomeDelivered
+	case cloudevents.IsUndelivered(result):
+		return audit.OutcomeUndelivered
+	case cloudevents.IsNACK(result):
+		return audit.OutcomeRejected
+	}
+	return audit.OutcomeUnknown
+}
+
+// publish informs the dispatch decision to firehose observers.
+func (s *subscriber) publish(event *cloudevents.Event, decision firehose.Decision) {
+	if !s.firehose.Active() {
+		return
+	}
+	s.firehose.Publish(s.firehoseRecord(event, decision))
+}
+
+// publishDelivery informs the delivery outcome to firehose observers.
+func (s *subscriber) publishDelivery(ctx context.Context, event *cloudevents.Event, result protocol.Result, start time.Time) {
+	if !s.firehose.Active() {
+		return
+	}
+
+	r := s.firehoseRecord(event, firehose.DecisionDelivery)
+	r.Time = start
+	r.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+	r.Outcome = string(deliveryOutcome(result))
+	if t := cloudevents.TargetFromContext(ctx); t != nil {
+		r.Target = t.String()
+	}
+	if result != nil && !cloudevents.IsACK(result) {
+		r.Error = result.Error()
+	}
+
+	s.firehose.Publish(r)
+}
+
+func (s *subscriber) firehoseRecord(event *cloudevents.Event, decision firehose.Decision) *firehose.Record {
+	return &firehose.Record{
+		Time:        time.Now(),
+		Trigger:     s.name,
+		Decision:    decision,
+		EventID:     event.ID(),
+		EventSource: event.Source(),
+		EventType:   event.Type(),
+	}
+}
+
+// debugw logs at info level for events flagged for debugging, and at
+// debug level for any other event.
+func (s *subscriber) debugw(ctx context.Context, msg string, fields ...interface{}) {
+	if debug.FromContext(ctx) {
+		s.logger.Infow(msg, append(fields, zap.Bool("debug", true), zap.String("trigger", s.name))...)
+		return
+	}
+	s.logger.Debugw(msg, fields...)
+}
+
+// quarantine stores events that cannot be trusted for delivery.
+func (s *subscriber) quarantine(event *cloudevents.Event, reason error) {
+	if s.quarantinePath == "" {
+		s.logger.Errorw("Event discarded", zap.Error(reason), zap.Bool("lost", true),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return
+	}
+
+	if err := appendEventToFile(s.quarantinePath, event); err != nil {
+		s.logger.Errorw("Event could not be quarantined", zap.Errors("error", []error{reason, err}), zap.Bool("lost", true),
+			zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
+		return
+	}
+
+	s.logger.Warnw("Event quarantined", zap.Error(reason), zap.String("path", s.quarantinePath),
+		zap.String("type", event.Type()), zap.String("source", event.Source()), zap.String("id", event.ID()))
 }
 
 func materializeFiltersList(ctx context.Context, filters []cfgbroker.Filter) []eventfilter.Filter {
@@ -206,6 +1153,18 @@ func materializeSubscriptionsAPIFilter(ctx context.Context, filter cfgbroker.Fil
 		materializedFilter = subscriptionsapi.NewAnyFilter(materializeFiltersList(ctx, filter.Any)...)
 	case filter.Not != nil:
 		materializedFilter = subscriptionsapi.NewNotFilter(materializeSubscriptionsAPIFilter(ctx, *filter.Not))
+	case filter.CESQL != "":
+		materializedFilter, err = newCESQLFilter(filter.CESQL)
+		if err != nil {
+			logging.FromContext(ctx).Debugw("Invalid CESQL expression", zap.String("expression", filter.CESQL), zap.Error(err))
+			return nil
+		}
+	case filter.WASM != nil:
+		materializedFilter, err = wasm.NewFilter(ctx, filter.WASM.Module)
+		if err != nil {
+			logging.FromContext(ctx).Debugw("Invalid WASM filter", zap.String("module", filter.WASM.Module), zap.Error(err))
+			return nil
+		}
 	}
 	return materializedFilter
 }
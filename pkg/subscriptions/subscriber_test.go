@@ -5,6 +5,9 @@ package subscriptions
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -12,10 +15,12 @@ import (
 	cetest "github.com/cloudevents/sdk-go/v2/client/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
 	"github.com/triggermesh/brokers/pkg/backend/impl/memory"
 	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
 	"github.com/triggermesh/brokers/test/lib"
 )
 
@@ -75,6 +80,17 @@ func TestSubscriberFilter(t *testing.T) {
 			expectedIds: []string{"t1s1", "t1s1ex2"},
 		},
 
+		"cesql expression": {
+			trigger: cfgbroker.Trigger{
+				Filters: []cfgbroker.Filter{
+					{
+						CESQL: "type = 'type2' AND source = 'source2'",
+					},
+				},
+			},
+			events:      eventPool,
+			expectedIds: []string{"t2s2ex1", "t2s2ex2"},
+		},
 		"exact extension": {
 			trigger: cfgbroker.Trigger{
 				Filters: []cfgbroker.Filter{
@@ -162,10 +178,14 @@ func TestSubscriberFilter(t *testing.T) {
 				ProduceTimeout: "PT10S",
 			}, logger)
 
+			r, err := metrics.NewReporter(ctx, "test-subscriber")
+			require.NoError(t, err)
+
 			client, rcv := cetest.NewMockRequesterClient(t, len(tc.events), testReceiver)
 			s := subscriber{
 				backend:   b,
 				name:      "test-subscriber",
+				reporter:  r,
 				ceClient:  client,
 				parentCtx: ctx,
 				logger:    logger,
@@ -175,7 +195,7 @@ func TestSubscriberFilter(t *testing.T) {
 			url := "http://test"
 			tc.trigger.Target.URL = &url
 
-			err := s.updateTrigger(tc.trigger)
+			err = s.updateTrigger(tc.trigger)
 			require.NoError(t, err, "Could not set trigger for subscription")
 			for _, ev := range tc.events {
 				s.dispatchCloudEvent(&ev)
@@ -210,6 +230,138 @@ func TestSubscriberFilter(t *testing.T) {
 	}
 }
 
+func TestCompileFilters(t *testing.T) {
+	errs := compileFilters(context.Background(), []cfgbroker.Filter{
+		{CESQL: "type = 'type1'"},
+		{Any: []cfgbroker.Filter{
+			{Exact: map[string]string{"type": "type1"}},
+			{CESQL: "type = = 'type1'"},
+		}},
+	}, "filters")
+
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "filters[1].any[1]: ")
+}
+
 func testReceiver(inMessage cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
 	return nil, cloudevents.ResultACK
 }
+
+func TestDeliveryGuarantee(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(t, err)
+
+	atLeastOnce := cfgbroker.DeliveryGuaranteeAtLeastOnce
+	bestEffort := cfgbroker.DeliveryGuaranteeBestEffort
+
+	testCases := map[string]struct {
+		guarantee *cfgbroker.DeliveryGuaranteeType
+		filtered  bool
+		expectErr bool
+	}{
+		"default": {},
+		"best effort": {
+			guarantee: &bestEffort,
+		},
+		"at least once": {
+			guarantee: &atLeastOnce,
+			expectErr: true,
+		},
+		"at least once filtered": {
+			guarantee: &atLeastOnce,
+			filtered:  true,
+		},
+	}
+
+	r, err := metrics.NewReporter(context.Background(), "test-subscriber")
+	require.NoError(t, err)
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			s := subscriber{
+				name:      "test-subscriber",
+				reporter:  r,
+				ceClient:  client,
+				parentCtx: context.Background(),
+				logger:    zaptest.NewLogger(t).Sugar(),
+			}
+
+			trigger := cfgbroker.Trigger{
+				Target:            cfgbroker.Target{URL: &target.URL},
+				DeliveryGuarantee: tc.guarantee,
+			}
+			if tc.filtered {
+				trigger.Filters = []cfgbroker.Filter{{Exact: map[string]string{"type": "not.matching"}}}
+			}
+			require.NoError(t, s.updateTrigger(trigger))
+
+			ev := lib.NewCloudEvent()
+			err := s.dispatchCloudEvent(&ev)
+			if tc.expectErr {
+				assert.Error(t, err, "Undelivered events must not be acknowledged")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// BenchmarkDispatchCloudEvent dispatches events concurrently to a target
+// with some latency while the trigger is being updated, which must not
+// block the deliveries in flight nor the new ones.
+func BenchmarkDispatchCloudEvent(b *testing.B) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer target.Close()
+
+	client, err := cloudevents.NewClientHTTP()
+	require.NoError(b, err)
+
+	s := subscriber{
+		name:      "test-subscriber",
+		ceClient:  client,
+		parentCtx: context.Background(),
+		logger:    zap.NewNop().Sugar(),
+	}
+
+	trigger := func(tenant string) cfgbroker.Trigger {
+		return cfgbroker.Trigger{
+			Target: cfgbroker.Target{
+				URL:     &target.URL,
+				Headers: map[string]string{"X-Tenant": tenant},
+			},
+		}
+	}
+	require.NoError(b, s.updateTrigger(trigger("a")))
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				_ = s.updateTrigger(trigger(fmt.Sprint(i % 2)))
+			}
+		}
+	}()
+
+	ev := lib.NewCloudEvent()
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := s.dispatchCloudEvent(&ev); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	ceclient "github.com/cloudevents/sdk-go/v2/client"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rickb777/date/period"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+	"github.com/triggermesh/brokers/pkg/subscriptions/metrics"
+)
+
+// HTTPTransportConfig tunes the connections used to deliver events.
+type HTTPTransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DisableKeepAlives   bool
+	HTTP2               bool
+}
+
+// DefaultHTTPTransportConfig matches the Go default HTTP transport.
+func DefaultHTTPTransportConfig() HTTPTransportConfig {
+	return HTTPTransportConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: http.DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		HTTP2:               true,
+	}
+}
+
+// override returns the configuration with the target settings applied.
+func (c HTTPTransportConfig) override(h *cfgbroker.HTTPClient) (HTTPTransportConfig, error) {
+	if h == nil {
+		return c, nil
+	}
+
+	if h.MaxIdleConns != nil {
+		c.MaxIdleConns = int(*h.MaxIdleConns)
+	}
+	if h.MaxIdleConnsPerHost != nil {
+		c.MaxIdleConnsPerHost = int(*h.MaxIdleConnsPerHost)
+	}
+	if h.MaxConnsPerHost != nil {
+		c.MaxConnsPerHost = int(*h.MaxConnsPerHost)
+	}
+	if h.IdleConnTimeout != nil {
+		p, err := period.Parse(*h.IdleConnTimeout)
+		if err != nil {
+			return c, fmt.Errorf("idle connection timeout is not an ISO8601 duration: %w", err)
+		}
+		c.IdleConnTimeout = p.DurationApprox()
+	}
+	if h.KeepAlive != nil {
+		p, err := period.Parse(*h.KeepAlive)
+		if err != nil {
+			return c, fmt.Errorf("keep-alive period is not an ISO8601 duration: %w", err)
+		}
+		c.KeepAlive = p.DurationApprox()
+	}
+	if h.DisableKeepAlives != nil {
+		c.DisableKeepAlives = *h.DisableKeepAlives
+	}
+	if h.HTTP2 != nil {
+		c.HTTP2 = *h.HTTP2
+	}
+
+	return c, nil
+}
+
+func (c HTTPTransportConfig) newTransport() *http.Transport {
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: c.KeepAlive,
+		}).DialContext,
+		ForceAttemptHTTP2:     c.HTTP2,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		IdleConnTimeout:       c.IdleConnTimeout,
+		DisableKeepAlives:     c.DisableKeepAlives,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	if !c.HTTP2 {
+		// A non nil empty map disables HTTP/2 negotiation.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return t
+}
+
+// newCloudEventsClient creates a CloudEvents client that propagates traces,
+// applies the target credentials, captures the Retry-After header of
+// responses and sends requests through the transport, or the one of the
+// load balanced endpoint.
+func newCloudEventsClient(rt http.RoundTripper, r metrics.Reporter) (cloudevents.Client, error) {
+	p, err := cehttp.New(
+		cehttp.WithClient(http.Client{}),
+		cehttp.WithRoundTripper(tracingRoundTripper(withRetryAfter(withTargetAuth(withEndpoint(rt))))),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create CloudEvents HTTP protocol: %w", err)
+	}
+
+	c, err := ceclient.New(p, ceclient.WithObservabilityService(metrics.NewOpenCensusObservabilityService(r)))
+	if err != nil {
+		return nil, fmt.Errorf("could not create CloudEvents HTTP client: %w", err)
+	}
+
+	return c, nil
+}
+
+// tracingRoundTripper propagates traces through the round tripper.
+func tracingRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &ochttp.Transport{
+		Propagation: &tracecontext.HTTPFormat{},
+		Base:        rt,
+		FormatSpanName: func(r *http.Request) string {
+			return "cloudevents.http." + r.URL.Path
+		},
+	}
+}
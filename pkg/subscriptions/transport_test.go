@@ -0,0 +1,46 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestHTTPTransportOverride(t *testing.T) {
+	base := DefaultHTTPTransportConfig()
+
+	c, err := base.override(nil)
+	require.NoError(t, err)
+	assert.Equal(t, base, c)
+
+	perHost := int32(50)
+	timeout := "PT5M"
+	http2 := false
+	c, err = base.override(&cfgbroker.HTTPClient{
+		MaxIdleConnsPerHost: &perHost,
+		IdleConnTimeout:     &timeout,
+		HTTP2:               &http2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, base.MaxIdleConns, c.MaxIdleConns)
+	assert.Equal(t, 50, c.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Minute, c.IdleConnTimeout)
+	assert.False(t, c.HTTP2)
+
+	tr := c.newTransport()
+	assert.Equal(t, 50, tr.MaxIdleConnsPerHost)
+	assert.False(t, tr.ForceAttemptHTTP2)
+	assert.NotNil(t, tr.TLSNextProto, "HTTP/2 must be disabled")
+
+	wrong := "5m"
+	_, err = base.override(&cfgbroker.HTTPClient{KeepAlive: &wrong})
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/rickb777/date/period"
+	"knative.dev/pkg/apis"
+
+	"github.com/triggermesh/brokers/pkg/common/secret"
+	"github.com/triggermesh/brokers/pkg/common/urltemplate"
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// ValidateConfig fully validates the configuration, adding to its schema
+// validation the checks that would otherwise fail when triggers are
+// applied, or skip filters when dispatching events: filters must compile,
+// target and dead letter URLs must be absolute, backoff delays must be
+// ISO8601 durations, target credentials and secrets must be readable and
+// Kafka producer settings must be valid.
+func ValidateConfig(ctx context.Context, c *cfgbroker.Config) *apis.FieldError {
+	if c == nil {
+		return nil
+	}
+
+	errs := c.Validate(ctx)
+
+	for name, t := range c.Triggers {
+		errs = errs.Also(validateTrigger(ctx, &t).ViaFieldKey("triggers", name))
+	}
+
+	for bn, b := range c.Brokers {
+		for name, t := range b.Triggers {
+			errs = errs.Also(validateTrigger(ctx, &t).ViaFieldKey("triggers", name).ViaFieldKey("brokers", bn))
+		}
+	}
+
+	return errs
+}
+
+func validateTrigger(ctx context.Context, t *cfgbroker.Trigger) (errs *apis.FieldError) {
+	for _, e := range filterErrors(ctx, t.Filters, "filters") {
+		errs = errs.Also(&apis.FieldError{
+			Message: "Filter cannot be compiled",
+			Paths:   []string{e.path},
+			Details: e.err.Error(),
+		})
+	}
+
+	if t.ReplyTarget != nil {
+		for _, e := range filterErrors(ctx, t.ReplyTarget.Filters, "replyTarget.filters") {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Filter cannot be compiled",
+				Paths:   []string{e.path},
+				Details: e.err.Error(),
+			})
+		}
+	}
+
+	for i, step := range t.Sequence {
+		for _, e := range filterErrors(ctx, step.Filters, fmt.Sprintf("sequence[%d].filters", i)) {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Filter cannot be compiled",
+				Paths:   []string{e.path},
+				Details: e.err.Error(),
+			})
+		}
+	}
+
+	return errs.Also(validateTarget(&t.Target).ViaField("target"))
+}
+
+func validateTarget(t *cfgbroker.Target) (errs *apis.FieldError) {
+	// Targets referencing objects inform a relative URL.
+	if t.URL != nil && *t.URL != "" && !urltemplate.IsTemplate(*t.URL) && t.Ref == nil {
+		errs = errs.Also(validateAbsoluteURL(*t.URL, "url"))
+	}
+	if t.DefaultURL != nil {
+		errs = errs.Also(validateAbsoluteURL(*t.DefaultURL, "defaultURL"))
+	}
+	for i, u := range t.FallbackURLs {
+		errs = errs.Also(validateAbsoluteURL(u, apis.CurrentField).ViaFieldIndex("fallbackURLs", i))
+	}
+	for i, u := range t.ReplicaURLs {
+		errs = errs.Also(validateAbsoluteURL(u, apis.CurrentField).ViaFieldIndex("replicaURLs", i))
+	}
+
+	// Secrets are only read when the authentication schema is valid to
+	// not report the same error twice.
+	if t.Auth != nil && t.Auth.Validate(context.Background()) == nil {
+		if _, err := newTargetAuth(context.Background(), nil, t.Auth); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Target credentials cannot be read",
+				Paths:   []string{"auth"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	for field, src := range targetSecretSources(t) {
+		if src.Validate(context.Background()) != nil {
+			continue
+		}
+		if _, err := secret.Read(src); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Target credentials cannot be read",
+				Paths:   []string{field},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	doErrs := validateDeliveryOptions(t.DeliveryOptions).ViaField("deliveryOptions")
+	errs = errs.Also(doErrs)
+
+	// The Kafka producer settings include the delivery options, which
+	// are only checked when valid to not report the same error twice.
+	if t.Kafka != nil && doErrs == nil {
+		if _, err := saramaConfig(t.Kafka, t.DeliveryOptions); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Kafka producer settings are not valid",
+				Paths:   []string{"kafka"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	return errs
+}
+
+// targetSecretSources returns the secret sources informed by the target
+// settings other than authentication, indexed by field.
+func targetSecretSources(t *cfgbroker.Target) map[string]*cfgbroker.SecretSource {
+	srcs := map[string]*cfgbroker.SecretSource{}
+	if t.Kafka != nil && t.Kafka.SASL != nil && t.Kafka.SASL.PasswordFrom != nil {
+		srcs["kafka.sasl.passwordFrom"] = t.Kafka.SASL.PasswordFrom
+	}
+	if t.AMQP != nil && t.AMQP.SASL != nil && t.AMQP.SASL.PasswordFrom != nil {
+		srcs["amqp.sasl.passwordFrom"] = t.AMQP.SASL.PasswordFrom
+	}
+	if t.ObjectStore != nil && t.ObjectStore.Credentials != nil && t.ObjectStore.Credentials.SecretAccessKeyFrom != nil {
+		srcs["objectStore.credentials.secretAccessKeyFrom"] = t.ObjectStore.Credentials.SecretAccessKeyFrom
+	}
+	return srcs
+}
+
+func validateDeliveryOptions(do *cfgbroker.DeliveryOptions) (errs *apis.FieldError) {
+	if do == nil {
+		return
+	}
+
+	if do.BackoffDelay != nil {
+		if _, err := period.Parse(*do.BackoffDelay); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "Backoff delay is not an ISO8601 duration",
+				Paths:   []string{"backoffDelay"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	if do.DeadLetterURL != nil && *do.DeadLetterURL != "" {
+		errs = errs.Also(validateAbsoluteURL(*do.DeadLetterURL, "deadLetterURL"))
+	}
+
+	for i, dls := range do.DeadLetterSinks {
+		if dls.URL != nil && *dls.URL != "" {
+			errs = errs.Also(validateAbsoluteURL(*dls.URL, "url").ViaFieldIndex("deadLetterSinks", i))
+		}
+	}
+
+	return errs
+}
+
+func validateAbsoluteURL(u, field string) *apis.FieldError {
+	if pu, err := url.Parse(u); err != nil || pu.Scheme == "" || pu.Host == "" {
+		return &apis.FieldError{
+			Message: "URL must be absolute",
+			Paths:   []string{field},
+			Details: u,
+		}
+	}
+	return nil
+}
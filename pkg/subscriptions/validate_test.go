@@ -0,0 +1,96 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package subscriptions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{{CESQL: "type = 'test.type'"}},
+		Target: cfgbroker.Target{
+			URL: strPtr("http://target/{type}"),
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				BackoffDelay:  strPtr("PT1S"),
+				DeadLetterURL: strPtr("http://dls"),
+			},
+		},
+	}
+
+	invalid := cfgbroker.Trigger{
+		Filters: []cfgbroker.Filter{
+			{Exact: map[string]string{"type": "test.type"}},
+			{Any: []cfgbroker.Filter{{CESQL: "type = "}}},
+		},
+		Target: cfgbroker.Target{
+			URL:          strPtr("/relative"),
+			FallbackURLs: []string{"http://fallback", "fallback"},
+			Auth: &cfgbroker.TargetAuth{Bearer: &cfgbroker.BearerAuth{
+				Token: cfgbroker.SecretSource{Env: strPtr("TEST_VALIDATE_MISSING_TOKEN")},
+			}},
+			DeliveryOptions: &cfgbroker.DeliveryOptions{
+				BackoffDelay: strPtr("1s"),
+				DeadLetterSinks: []cfgbroker.DeadLetterSink{
+					{URL: strPtr("dls")},
+				},
+			},
+		},
+	}
+
+	c := &cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{
+			"valid":   valid,
+			"invalid": invalid,
+		},
+		Brokers: map[string]cfgbroker.Broker{
+			"team": {Triggers: map[string]cfgbroker.Trigger{"invalid": invalid}},
+		},
+	}
+	assert.Nil(t, ValidateConfig(context.Background(), &cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{"valid": valid},
+	}))
+
+	var fields []string
+	for _, e := range cfgbroker.ValidationErrors(ValidateConfig(context.Background(), c)) {
+		fields = append(fields, e.Field)
+	}
+	expected := []string{
+		"triggers[invalid].filters[1].any[0]",
+		"triggers[invalid].target.auth",
+		"triggers[invalid].target.deliveryOptions.backoffDelay",
+		"triggers[invalid].target.deliveryOptions.deadLetterSinks[0].url",
+		"triggers[invalid].target.fallbackURLs[1]",
+		"triggers[invalid].target.url",
+	}
+	for _, f := range expected {
+		assert.Contains(t, fields, f)
+		assert.Contains(t, fields, "brokers[team]."+f)
+	}
+	assert.Len(t, fields, 2*len(expected))
+}
+
+func TestValidateConfigSecrets(t *testing.T) {
+	c := &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+		"amqp": {Target: cfgbroker.Target{AMQP: &cfgbroker.AMQPTarget{
+			URL:     "amqp://amqp.example.com",
+			Address: "orders",
+			SASL: &cfgbroker.AMQPSASL{
+				User:         "broker",
+				PasswordFrom: &cfgbroker.SecretSource{Env: strPtr("TEST_VALIDATE_MISSING_PASSWORD")},
+			},
+		}}},
+	}}
+
+	errs := cfgbroker.ValidationErrors(ValidateConfig(context.Background(), c))
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "triggers[amqp].target.amqp.sasl.passwordFrom", errs[0].Field)
+		assert.Equal(t, "Target credentials cannot be read", errs[0].Message)
+	}
+}
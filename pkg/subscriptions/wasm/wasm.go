@@ -0,0 +1,275 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package wasm evaluates trigger filters implemented as WebAssembly modules.
+//
+// Modules must export:
+//   - memory: the module linear memory.
+//   - allocate(size i32) i32: returns a pointer where size bytes can be written.
+//   - filter(ptr i32, len i32) i32: receives the JSON serialized CloudEvent
+//     written at ptr and returns non zero if the event passes the filter.
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"go.uber.org/zap"
+
+	"knative.dev/eventing/pkg/eventfilter"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	exportMemory   = "memory"
+	exportAllocate = "allocate"
+	exportFilter   = "filter"
+
+	// Maximum time for evaluating an event.
+	evaluationTimeout = time.Second
+
+	// Number of idle module instances kept for reuse.
+	maxIdleInstances = 16
+)
+
+var (
+	runtimeOnce sync.Once
+	runtime     wazero.Runtime
+
+	// Modules are compiled once per path.
+	modules = map[string]*module{}
+	m       sync.Mutex
+)
+
+// Signatures of the functions modules must export.
+var exportedFunctions = map[string]struct {
+	params  []api.ValueType
+	results []api.ValueType
+}{
+	exportAllocate: {
+		params:  []api.ValueType{api.ValueTypeI32},
+		results: []api.ValueType{api.ValueTypeI32},
+	},
+	exportFilter: {
+		params:  []api.ValueType{api.ValueTypeI32, api.ValueTypeI32},
+		results: []api.ValueType{api.ValueTypeI32},
+	},
+}
+
+type module struct {
+	compiled wazero.CompiledModule
+	modTime  time.Time
+	idle     chan api.Module
+}
+
+func getRuntime() wazero.Runtime {
+	runtimeOnce.Do(func() {
+		runtime = wazero.NewRuntimeWithConfig(context.Background(),
+			wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	})
+	return runtime
+}
+
+// loadModule returns the compiled module for the path, compiling it again
+// if the file was modified.
+func loadModule(ctx context.Context, path string) (*module, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read WASM module: %w", err)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if mod, ok := modules[path]; ok && mod.modTime.Equal(fi.ModTime()) {
+		return mod, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read WASM module: %w", err)
+	}
+
+	compiled, err := getRuntime().CompileModule(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile WASM module %q: %w", path, err)
+	}
+
+	if err := validateExports(compiled); err != nil {
+		compiled.Close(ctx)
+		return nil, fmt.Errorf("WASM module %q is not a valid filter: %w", path, err)
+	}
+
+	mod := &module{
+		compiled: compiled,
+		modTime:  fi.ModTime(),
+		idle:     make(chan api.Module, maxIdleInstances),
+	}
+
+	// Release instances of the previous version of the module.
+	if prev, ok := modules[path]; ok {
+		go prev.drain()
+	}
+	modules[path] = mod
+
+	return mod, nil
+}
+
+// validateExports makes sure that the module exports the memory and the
+// functions that are called when evaluating events.
+func validateExports(compiled wazero.CompiledModule) error {
+	if _, ok := compiled.ExportedMemories()[exportMemory]; !ok {
+		return fmt.Errorf("module does not export %q", exportMemory)
+	}
+
+	exports := compiled.ExportedFunctions()
+	for name, sig := range exportedFunctions {
+		fn, ok := exports[name]
+		if !ok {
+			return fmt.Errorf("module does not export function %q", name)
+		}
+		if !equalTypes(fn.ParamTypes(), sig.params) || !equalTypes(fn.ResultTypes(), sig.results) {
+			return fmt.Errorf("function %q must have signature (%s) -> %s", name,
+				typeNames(sig.params), typeNames(sig.results))
+		}
+	}
+
+	return nil
+}
+
+func equalTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func typeNames(types []api.ValueType) string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, api.ValueTypeName(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func (mod *module) get(ctx context.Context) (api.Module, error) {
+	select {
+	case inst := <-mod.idle:
+		return inst, nil
+	default:
+	}
+
+	// Anonymous modules can be instantiated multiple times.
+	return getRuntime().InstantiateModule(ctx, mod.compiled, wazero.NewModuleConfig().WithName(""))
+}
+
+func (mod *module) put(inst api.Module) {
+	select {
+	case mod.idle <- inst:
+	default:
+		inst.Close(context.Background())
+	}
+}
+
+func (mod *module) drain() {
+	for {
+		select {
+		case inst := <-mod.idle:
+			inst.Close(context.Background())
+		default:
+			return
+		}
+	}
+}
+
+func (mod *module) evaluate(ctx context.Context, data []byte) (bool, error) {
+	// Instances are not reused after errors, since those might be due to
+	// the context being done, which closes the instance.
+	inst, err := mod.get(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("could not instantiate WASM module: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, evaluationTimeout)
+	defer cancel()
+
+	res, err := inst.ExportedFunction(exportAllocate).Call(ctx, uint64(len(data)))
+	if err != nil {
+		inst.Close(context.Background())
+		return false, fmt.Errorf("could not allocate WASM memory: %w", err)
+	}
+
+	ptr := uint32(res[0])
+	if !inst.ExportedMemory(exportMemory).Write(ptr, data) {
+		inst.Close(context.Background())
+		return false, fmt.Errorf("WASM allocated memory out of range")
+	}
+
+	res, err = inst.ExportedFunction(exportFilter).Call(ctx, uint64(ptr), uint64(len(data)))
+	if err != nil {
+		inst.Close(context.Background())
+		return false, fmt.Errorf("could not evaluate WASM filter: %w", err)
+	}
+
+	mod.put(inst)
+	return uint32(res[0]) != 0, nil
+}
+
+type filter struct {
+	path   string
+	module atomic.Pointer[module]
+}
+
+// NewFilter returns a filter that evaluates events using the WASM
+// module at the path.
+func NewFilter(ctx context.Context, path string) (eventfilter.Filter, error) {
+	mod, err := loadModule(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &filter{path: path}
+	f.module.Store(mod)
+	return f, nil
+}
+
+func (f *filter) Filter(ctx context.Context, event cloudevents.Event) eventfilter.FilterResult {
+	data, err := json.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("Could not serialize event for WASM filter", zap.Error(err))
+		return eventfilter.FailFilter
+	}
+
+	// Loading the module again picks up modifications to the file, which
+	// keeps using the last compiled module when it cannot be loaded.
+	if mod, err := loadModule(ctx, f.path); err != nil {
+		logging.FromContext(ctx).Warnw("Could not reload WASM module", zap.String("module", f.path), zap.Error(err))
+	} else {
+		f.module.Store(mod)
+	}
+
+	ok, err := f.module.Load().evaluate(ctx, data)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("WASM filter failed", zap.String("module", f.path), zap.Error(err))
+		return eventfilter.FailFilter
+	}
+
+	if ok {
+		return eventfilter.PassFilter
+	}
+	return eventfilter.FailFilter
+}
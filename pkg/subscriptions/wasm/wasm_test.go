@@ -0,0 +1,147 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wasm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"knative.dev/eventing/pkg/eventfilter"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+// Module that passes events which JSON representation is longer than
+// 300 bytes. allocate always returns offset 1024.
+var lengthFilterModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// types: (i32) -> i32, (i32, i32) -> i32
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	// functions
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	// memory
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// exports: memory, allocate, filter
+	0x07, 0x1e, 0x03,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x08, 'a', 'l', 'l', 'o', 'c', 'a', 't', 'e', 0x00, 0x00,
+	0x06, 'f', 'i', 'l', 't', 'e', 'r', 0x00, 0x01,
+	// code
+	0x0a, 0x10, 0x02,
+	// allocate: i32.const 1024
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	// filter: local.get 1, i32.const 300, i32.gt_u
+	0x08, 0x00, 0x20, 0x01, 0x41, 0xac, 0x02, 0x4b, 0x0b,
+}
+
+// Module that exports the filter functions but not its memory.
+var noMemoryModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// types: (i32) -> i32, (i32, i32) -> i32
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	// functions
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	// exports: allocate, filter
+	0x07, 0x15, 0x02,
+	0x08, 'a', 'l', 'l', 'o', 'c', 'a', 't', 'e', 0x00, 0x00,
+	0x06, 'f', 'i', 'l', 't', 'e', 'r', 0x00, 0x01,
+	// code
+	0x0a, 0x10, 0x02,
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	0x08, 0x00, 0x20, 0x01, 0x41, 0xac, 0x02, 0x4b, 0x0b,
+}
+
+// Module whose filter function only receives the pointer.
+var filterSignatureModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// types: (i32) -> i32, (i32, i32) -> i32
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	// functions: both (i32) -> i32
+	0x03, 0x03, 0x02, 0x00, 0x00,
+	// memory
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// exports: memory, allocate, filter
+	0x07, 0x1e, 0x03,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x08, 'a', 'l', 'l', 'o', 'c', 'a', 't', 'e', 0x00, 0x00,
+	0x06, 'f', 'i', 'l', 't', 'e', 'r', 0x00, 0x01,
+	// code
+	0x0a, 0x10, 0x02,
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	// filter: local.get 0, i32.const 300, i32.gt_u
+	0x08, 0x00, 0x20, 0x00, 0x41, 0xac, 0x02, 0x4b, 0x0b,
+}
+
+func TestWASMFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.wasm")
+	require.NoError(t, os.WriteFile(path, lengthFilterModule, 0o600))
+
+	ctx := context.Background()
+	f, err := NewFilter(ctx, path)
+	require.NoError(t, err)
+
+	small := lib.NewCloudEvent(lib.CloudEventWithIDOption("1"))
+	small.SetData("text/plain", "x")
+	assert.Equal(t, eventfilter.FailFilter, f.Filter(ctx, small))
+
+	// Run several times to exercise instance reuse.
+	for i := 0; i < 3; i++ {
+		large := lib.NewCloudEvent(lib.CloudEventWithIDOption("2"))
+		large.SetData("text/plain", strings.Repeat("x", 300))
+		assert.Equal(t, eventfilter.PassFilter, f.Filter(ctx, large))
+	}
+
+	// Modules that cannot be loaded again keep the last compiled version.
+	require.NoError(t, os.Remove(path))
+	large := lib.NewCloudEvent(lib.CloudEventWithIDOption("3"))
+	large.SetData("text/plain", strings.Repeat("x", 300))
+	assert.Equal(t, eventfilter.PassFilter, f.Filter(ctx, large))
+
+	_, err = NewFilter(ctx, filepath.Join(t.TempDir(), "missing.wasm"))
+	assert.Error(t, err)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.wasm")
+	require.NoError(t, os.WriteFile(invalid, []byte("not wasm"), 0o600))
+	_, err = NewFilter(ctx, invalid)
+	assert.Error(t, err)
+}
+
+func TestWASMFilterExports(t *testing.T) {
+	tcs := map[string]struct {
+		module []byte
+		err    string
+	}{
+		"valid filter": {
+			module: lengthFilterModule,
+		},
+		"memory not exported": {
+			module: noMemoryModule,
+			err:    `does not export "memory"`,
+		},
+		"filter signature not valid": {
+			module: filterSignatureModule,
+			err:    `function "filter" must have signature (i32, i32) -> i32`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "filter.wasm")
+			require.NoError(t, os.WriteFile(path, tc.module, 0o600))
+
+			_, err := NewFilter(context.Background(), path)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tap streams samples of the ingested events that match ad-hoc
+// filters to temporary observers, for debugging without creating triggers.
+package tap
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"golang.org/x/time/rate"
+	"knative.dev/eventing/pkg/eventfilter"
+	"knative.dev/eventing/pkg/eventfilter/subscriptionsapi"
+)
+
+const (
+	// Events buffered per tap, further events are dropped if the observer
+	// cannot keep up.
+	tapBufferSize = 256
+
+	// MaxRate is the maximum number of events per second sent to a tap.
+	MaxRate = 100
+)
+
+// ParseFilter returns the filter for the CESQL expression, which matches
+// every event if empty.
+func ParseFilter(expr string) (f eventfilter.Filter, err error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	// The parser might panic when reporting some syntax errors.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("error while parsing expression %s: %v", expr, r)
+		}
+	}()
+	return subscriptionsapi.NewCESQLFilter(expr)
+}
+
+// Tap receives the events that match its filter.
+type Tap struct {
+	C <-chan cloudevents.Event
+
+	c       chan cloudevents.Event
+	filter  eventfilter.Filter
+	sample  float64
+	limiter *rate.Limiter
+	dropped uint64
+}
+
+// Dropped returns the number of matching events that were not sent to the
+// tap because it could not keep up or exceeded its rate.
+func (t *Tap) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+func (t *Tap) matches(ctx context.Context, event *cloudevents.Event) bool {
+	if t.filter != nil && t.filter.Filter(ctx, *event) == eventfilter.FailFilter {
+		return false
+	}
+	return t.sample >= 1 || rand.Float64() < t.sample
+}
+
+// Hub distributes the ingested events to taps.
+type Hub struct {
+	taps  map[*Tap]struct{}
+	count int32
+	m     sync.RWMutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		taps: make(map[*Tap]struct{}),
+	}
+}
+
+// Active returns true if there are taps attached, which allows skipping
+// evaluating events.
+func (h *Hub) Active() bool {
+	return h != nil && atomic.LoadInt32(&h.count) != 0
+}
+
+// Attach registers a tap for a sample ratio, from 0 to 1, of the events
+// that match the filter, sending up to maxRate events per second.
+func (h *Hub) Attach(filter eventfilter.Filter, sample, maxRate float64) *Tap {
+	c := make(chan cloudevents.Event, tapBufferSize)
+	t := &Tap{
+		C:       c,
+		c:       c,
+		filter:  filter,
+		sample:  sample,
+		limiter: rate.NewLimiter(rate.Limit(maxRate), int(maxRate)+1),
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.taps[t] = struct{}{}
+	atomic.StoreInt32(&h.count, int32(len(h.taps)))
+
+	return t
+}
+
+// Detach removes the tap and closes its channel.
+func (h *Hub) Detach(t *Tap) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, ok := h.taps[t]; !ok {
+		return
+	}
+	delete(h.taps, t)
+	atomic.StoreInt32(&h.count, int32(len(h.taps)))
+	close(t.c)
+}
+
+// Publish sends a copy of the event to the matching taps without blocking.
+func (h *Hub) Publish(ctx context.Context, event *cloudevents.Event) {
+	if !h.Active() {
+		return
+	}
+
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for t := range h.taps {
+		if !t.matches(ctx, event) {
+			continue
+		}
+
+		if !t.limiter.Allow() {
+			atomic.AddUint64(&t.dropped, 1)
+			continue
+		}
+
+		select {
+		case t.c <- event.Clone():
+		default:
+			atomic.AddUint64(&t.dropped, 1)
+		}
+	}
+}
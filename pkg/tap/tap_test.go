@@ -0,0 +1,53 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/triggermesh/brokers/test/lib"
+)
+
+func TestHub(t *testing.T) {
+	ctx := context.Background()
+	h := NewHub()
+	assert.False(t, h.Active())
+
+	f, err := ParseFilter("type = 'order.created'")
+	require.NoError(t, err)
+
+	tp := h.Attach(f, 1, 2)
+	assert.True(t, h.Active())
+
+	ignored := lib.NewCloudEvent(lib.CloudEventWithTypeOption("order.deleted"))
+	h.Publish(ctx, &ignored)
+	for i := 0; i < 5; i++ {
+		ev := lib.NewCloudEvent(lib.CloudEventWithIDOption("matching"), lib.CloudEventWithTypeOption("order.created"))
+		h.Publish(ctx, &ev)
+	}
+
+	// The limiter allows a burst of rate + 1 events.
+	assert.Len(t, tp.C, 3)
+	assert.Equal(t, uint64(2), tp.Dropped())
+	ev := <-tp.C
+	assert.Equal(t, "matching", ev.ID())
+
+	h.Detach(tp)
+	assert.False(t, h.Active())
+
+	// Empty expressions match every event.
+	f, err = ParseFilter("")
+	require.NoError(t, err)
+	tp = h.Attach(f, 1, MaxRate)
+	h.Publish(ctx, &ignored)
+	assert.Len(t, tp.C, 1)
+	h.Detach(tp)
+
+	_, err = ParseFilter("type = = 'order.created'")
+	assert.Error(t, err)
+}
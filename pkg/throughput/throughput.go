@@ -0,0 +1,276 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package throughput keeps hourly counters of ingested and dispatched
+// events for capacity planning.
+package throughput
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/triggermesh/brokers/pkg/backend"
+)
+
+const (
+	// Period for adding the counters to the store.
+	flushPeriod = time.Minute
+
+	// Timeout for adding the counters to the store when stopping.
+	flushTimeout = 5 * time.Second
+
+	// Default range for queries that do not inform it.
+	defaultQueryRange = 24 * time.Hour
+
+	ingestedField   = "ingested"
+	triggerPrefix   = "trigger/"
+	deliveredSuffix = "/delivered"
+	failedSuffix    = "/failed"
+)
+
+// Aggregate contains the counters for an hour.
+type Aggregate struct {
+	Hour     time.Time                   `json:"hour"`
+	Ingested int64                       `json:"ingested"`
+	Triggers map[string]TriggerAggregate `json:"triggers,omitempty"`
+}
+
+// TriggerAggregate contains the counters of a trigger for an hour.
+type TriggerAggregate struct {
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+}
+
+// Recorder counts events, periodically adding the counters to the store.
+// A nil recorder does not count events.
+type Recorder struct {
+	store     backend.ThroughputStore
+	retention time.Duration
+
+	// Counters not yet added to the store, indexed by hour.
+	pending map[time.Time]map[string]int64
+	m       sync.Mutex
+
+	logger *zap.SugaredLogger
+}
+
+// NewRecorder creates a recorder whose counters are kept at the store for
+// the retention duration.
+func NewRecorder(store backend.ThroughputStore, retention time.Duration, logger *zap.SugaredLogger) *Recorder {
+	return &Recorder{
+		store:     store,
+		retention: retention,
+		pending:   make(map[time.Time]map[string]int64),
+		logger:    logger,
+	}
+}
+
+// Ingested counts an event ingested by the broker.
+func (r *Recorder) Ingested() {
+	r.add(ingestedField)
+}
+
+// Dispatched counts an event dispatched by a trigger.
+func (r *Recorder) Dispatched(trigger string, delivered bool) {
+	if delivered {
+		r.add(triggerPrefix + trigger + deliveredSuffix)
+	} else {
+		r.add(triggerPrefix + trigger + failedSuffix)
+	}
+}
+
+func (r *Recorder) add(field string) {
+	if r == nil {
+		return
+	}
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	counts, ok := r.pending[hour]
+	if !ok {
+		counts = make(map[string]int64)
+		r.pending[hour] = counts
+	}
+	counts[field]++
+}
+
+// Start adds the counters to the store periodically until the context is
+// done, when the remaining counters are added.
+func (r *Recorder) Start(ctx context.Context) error {
+	t := time.NewTicker(flushPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			defer cancel()
+			r.flush(fctx)
+			return nil
+		case <-t.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+func (r *Recorder) flush(ctx context.Context) {
+	r.m.Lock()
+	pending := r.pending
+	r.pending = make(map[time.Time]map[string]int64)
+	r.m.Unlock()
+
+	for hour, counts := range pending {
+		if err := r.store.AddThroughput(ctx, hour, counts, r.retention); err != nil {
+			r.logger.Errorw("Could not store throughput counters", zap.Time("hour", hour), zap.Error(err))
+
+			// Keep the counters to be added at the next flush.
+			r.m.Lock()
+			for field, n := range counts {
+				if _, ok := r.pending[hour]; !ok {
+					r.pending[hour] = make(map[string]int64)
+				}
+				r.pending[hour][field] += n
+			}
+			r.m.Unlock()
+		}
+	}
+}
+
+// Query returns the aggregates of the hours within the range, including
+// the counters not yet stored, sorted by hour.
+func (r *Recorder) Query(ctx context.Context, from, to time.Time) ([]Aggregate, error) {
+	stored, err := r.store.Throughput(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	r.m.Lock()
+	for hour, counts := range r.pending {
+		if hour.Before(from) || hour.After(to) {
+			continue
+		}
+		if _, ok := stored[hour]; !ok {
+			stored[hour] = make(map[string]int64)
+		}
+		for field, n := range counts {
+			stored[hour][field] += n
+		}
+	}
+	r.m.Unlock()
+
+	aggs := make([]Aggregate, 0, len(stored))
+	for hour, counts := range stored {
+		aggs = append(aggs, newAggregate(hour, counts))
+	}
+	sort.Slice(aggs, func(i, j int) bool {
+		return aggs[i].Hour.Before(aggs[j].Hour)
+	})
+
+	return aggs, nil
+}
+
+func newAggregate(hour time.Time, counts map[string]int64) Aggregate {
+	agg := Aggregate{
+		Hour:     hour.UTC(),
+		Ingested: counts[ingestedField],
+		Triggers: make(map[string]TriggerAggregate),
+	}
+
+	for field, n := range counts {
+		if !strings.HasPrefix(field, triggerPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(field, triggerPrefix)
+
+		switch {
+		case strings.HasSuffix(name, deliveredSuffix):
+			name = strings.TrimSuffix(name, deliveredSuffix)
+			ta := agg.Triggers[name]
+			ta.Delivered += n
+			agg.Triggers[name] = ta
+		case strings.HasSuffix(name, failedSuffix):
+			name = strings.TrimSuffix(name, failedSuffix)
+			ta := agg.Triggers[name]
+			ta.Failed += n
+			agg.Triggers[name] = ta
+		}
+	}
+
+	return agg
+}
+
+// Handler serves the aggregates for the range informed at the from and
+// to query parameters using RFC3339, which defaults to the last 24 hours.
+// The trigger parameter restricts the aggregates to a single trigger.
+func Handler(r *Recorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := req.URL.Query()
+		to := time.Now()
+		if v := q.Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "to parameter is not an RFC3339 time")
+				return
+			}
+			to = t
+		}
+
+		from := to.Add(-defaultQueryRange)
+		if v := q.Get("from"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "from parameter is not an RFC3339 time")
+				return
+			}
+			from = t
+		}
+
+		if !from.Before(to) {
+			writeError(w, http.StatusBadRequest, "from parameter must be before to parameter")
+			return
+		}
+
+		// Include the hour that contains the start of the range.
+		aggs, err := r.Query(req.Context(), from.UTC().Truncate(time.Hour), to.UTC())
+		if err != nil {
+			r.logger.Errorw("Could not query throughput counters", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "could not query throughput counters")
+			return
+		}
+
+		if trigger := q.Get("trigger"); trigger != "" {
+			for i := range aggs {
+				ta, ok := aggs[i].Triggers[trigger]
+				aggs[i].Triggers = map[string]TriggerAggregate{}
+				if ok {
+					aggs[i].Triggers[trigger] = ta
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(aggs)
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package throughput
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	hours map[time.Time]map[string]int64
+	err   error
+}
+
+func (f *fakeStore) AddThroughput(_ context.Context, hour time.Time, counts map[string]int64, _ time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	if _, ok := f.hours[hour]; !ok {
+		f.hours[hour] = make(map[string]int64)
+	}
+	for k, n := range counts {
+		f.hours[hour][k] += n
+	}
+	return nil
+}
+
+func (f *fakeStore) Throughput(_ context.Context, from, to time.Time) (map[time.Time]map[string]int64, error) {
+	res := make(map[time.Time]map[string]int64)
+	for h, counts := range f.hours {
+		if h.Before(from) || h.After(to) {
+			continue
+		}
+		c := make(map[string]int64)
+		for k, n := range counts {
+			c[k] = n
+		}
+		res[h] = c
+	}
+	return res, nil
+}
+
+func TestRecorder(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	store := &fakeStore{hours: map[time.Time]map[string]int64{
+		hour.Add(-time.Hour): {"ingested": 5, "trigger/t1/delivered": 5},
+	}}
+	r := NewRecorder(store, time.Hour, zap.NewNop().Sugar())
+
+	r.Ingested()
+	r.Ingested()
+	r.Dispatched("t1", true)
+	r.Dispatched("t1", false)
+	r.Dispatched("t2", true)
+
+	// Counters that failed to be stored are kept.
+	store.err = errors.New("unavailable")
+	r.flush(context.Background())
+	store.err = nil
+	r.flush(context.Background())
+	r.Ingested()
+
+	aggs, err := r.Query(context.Background(), hour.Add(-2*time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []Aggregate{{
+		Hour:     hour.Add(-time.Hour),
+		Ingested: 5,
+		Triggers: map[string]TriggerAggregate{"t1": {Delivered: 5}},
+	}, {
+		Hour:     hour,
+		Ingested: 3,
+		Triggers: map[string]TriggerAggregate{
+			"t1": {Delivered: 1, Failed: 1},
+			"t2": {Delivered: 1},
+		},
+	}}, aggs)
+
+	var nilRecorder *Recorder
+	assert.NotPanics(t, nilRecorder.Ingested)
+}
+
+func TestHandler(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	store := &fakeStore{hours: map[time.Time]map[string]int64{
+		hour:                      {"ingested": 2, "trigger/t1/delivered": 1, "trigger/t2/failed": 1},
+		hour.Add(-48 * time.Hour): {"ingested": 7},
+	}}
+	h := Handler(NewRecorder(store, time.Hour, zap.NewNop().Sugar()))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/throughput?trigger=t2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var aggs []Aggregate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &aggs))
+	require.Len(t, aggs, 1, "Default range must not include older hours")
+	assert.Equal(t, int64(2), aggs[0].Ingested)
+	assert.Equal(t, map[string]TriggerAggregate{"t2": {Failed: 1}}, aggs[0].Triggers)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/throughput?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
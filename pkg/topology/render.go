@@ -0,0 +1,114 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteJSON writes the graph as a JSON document.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(g)
+}
+
+// DOT renders the graph using the Graphviz DOT language.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph topology {\n  rankdir=LR;\n")
+
+	for _, n := range g.Nodes {
+		attrs := "shape=box"
+		switch n.Kind {
+		case NodeBroker:
+			attrs = "shape=box3d"
+		case NodeTrigger:
+			attrs = "shape=ellipse"
+		case NodeDeadLetter:
+			attrs = "shape=box, style=dashed"
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s, %s];\n", strconv.Quote(n.ID), strconv.Quote(nodeLabel(&n, "\n")), attrs)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		if l := edgeLabel(&e); l != "" {
+			fmt.Fprintf(&sb, " [label=%s, style=dashed]", strconv.Quote(l))
+		}
+		sb.WriteString(";\n")
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart.
+func (g *Graph) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+
+	// Mermaid identifiers cannot contain most characters of node IDs.
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		id := "n" + strconv.Itoa(i)
+		ids[n.ID] = id
+
+		l := mermaidEscape(nodeLabel(&n, "<br/>"))
+		switch n.Kind {
+		case NodeBroker:
+			fmt.Fprintf(&sb, "  %s[[\"%s\"]]\n", id, l)
+		case NodeTrigger:
+			fmt.Fprintf(&sb, "  %s(\"%s\")\n", id, l)
+		case NodeDeadLetter:
+			fmt.Fprintf(&sb, "  %s[/\"%s\"/]\n", id, l)
+		default:
+			fmt.Fprintf(&sb, "  %s[\"%s\"]\n", id, l)
+		}
+	}
+
+	for _, e := range g.Edges {
+		if l := edgeLabel(&e); l != "" {
+			fmt.Fprintf(&sb, "  %s -.->|%s| %s\n", ids[e.From], mermaidEscape(l), ids[e.To])
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+
+	return sb.String()
+}
+
+// nodeLabel returns the label of the node, followed by the filters of
+// triggers using the line separator.
+func nodeLabel(n *Node, sep string) string {
+	l := n.Label
+	if n.Kind == NodeBroker {
+		l = "broker " + l
+	}
+	if n.Filters != "" {
+		l += sep + n.Filters
+	}
+	return l
+}
+
+// edgeLabel returns the label of edges other than the subscriptions and
+// deliveries events usually flow through.
+func edgeLabel(e *Edge) string {
+	switch e.Kind {
+	case EdgeSubscription, EdgeDelivery:
+		return ""
+	}
+	if e.Order != 0 {
+		return e.Kind + " " + strconv.Itoa(e.Order)
+	}
+	return e.Kind
+}
+
+var mermaidReplacer = strings.NewReplacer(`"`, "#quot;", "|", "#124;")
+
+func mermaidEscape(s string) string {
+	return mermaidReplacer.Replace(s)
+}
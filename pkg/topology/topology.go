@@ -0,0 +1,307 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package topology renders the routing topology of the broker
+// configuration as a graph.
+package topology
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+// Name of the node for the default broker.
+const DefaultBroker = "default"
+
+// Kinds of nodes.
+const (
+	NodeBroker     = "broker"
+	NodeTrigger    = "trigger"
+	NodeTarget     = "target"
+	NodeDeadLetter = "deadLetter"
+)
+
+// Kinds of edges.
+const (
+	// Brokers to the triggers they dispatch events to.
+	EdgeSubscription = "subscription"
+	// Triggers to their target.
+	EdgeDelivery = "delivery"
+	// Triggers to the URLs tried when the delivery to the target fails.
+	EdgeFallback = "fallback"
+	// Triggers to the replicas of their target URL.
+	EdgeReplica = "replica"
+	// Triggers to the sinks of events that could not be delivered.
+	EdgeDeadLetter = "deadLetter"
+	// Triggers to the URL receiving events that cannot be routed by a
+	// templated target URL.
+	EdgeDefault = "default"
+	// Triggers to the steps their target replies go through.
+	EdgeSequence = "sequence"
+	// Triggers to the destinations of events sequence steps did not
+	// accept.
+	EdgeSequenceError = "sequenceError"
+)
+
+// Graph of brokers, triggers and the destinations events are sent to.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node of the topology. Targets and dead letter sinks referenced by
+// several triggers are a single node.
+type Node struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+
+	// Broker of trigger nodes.
+	Broker string `json:"broker,omitempty"`
+	// Filters summary of trigger nodes, empty when all events are
+	// delivered.
+	Filters string `json:"filters,omitempty"`
+	// Owner of triggers registered by consumers.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Edge between nodes. Dead letter sinks of a trigger are tried in the
+// order informed by their edges.
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Order int    `json:"order,omitempty"`
+}
+
+// Build returns the topology of the configuration, including the
+// hosted brokers. Nodes and edges are sorted so that the same
+// configuration always renders the same graph.
+func Build(c *cfgbroker.Config) *Graph {
+	b := &builder{nodes: make(map[string]Node)}
+
+	b.addBroker(DefaultBroker, c.Triggers)
+	for name, hb := range c.Brokers {
+		b.addBroker(name, hb.Triggers)
+	}
+
+	g := &Graph{
+		Nodes: make([]Node, 0, len(b.nodes)),
+		Edges: b.edges,
+	}
+	for _, n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		if g.Edges[i].Kind != g.Edges[j].Kind {
+			return g.Edges[i].Kind < g.Edges[j].Kind
+		}
+		if g.Edges[i].Order != g.Edges[j].Order {
+			return g.Edges[i].Order < g.Edges[j].Order
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+
+	return g
+}
+
+type builder struct {
+	nodes map[string]Node
+	edges []Edge
+}
+
+func (b *builder) addBroker(name string, triggers map[string]cfgbroker.Trigger) {
+	bid := "broker:" + name
+	b.nodes[bid] = Node{ID: bid, Kind: NodeBroker, Label: name}
+
+	for tname, t := range triggers {
+		tid := "trigger:" + name + "/" + tname
+		b.nodes[tid] = Node{
+			ID:      tid,
+			Kind:    NodeTrigger,
+			Label:   tname,
+			Broker:  name,
+			Filters: SummarizeFilters(t.Filters),
+			Owner:   t.Owner,
+		}
+		b.edges = append(b.edges, Edge{From: bid, To: tid, Kind: EdgeSubscription})
+		b.addTarget(tid, &t.Target)
+		b.addSequence(tid, t.Sequence)
+	}
+}
+
+func (b *builder) addTarget(tid string, t *cfgbroker.Target) {
+	switch {
+	case t.Kafka != nil:
+		b.link(tid, NodeTarget, "kafka://"+strings.Join(t.Kafka.Brokers, ",")+"/"+t.Kafka.Topic, EdgeDelivery, 0)
+	case t.ObjectStore != nil:
+		b.link(tid, NodeTarget, t.ObjectStore.Provider+"://"+t.ObjectStore.Bucket, EdgeDelivery, 0)
+	case t.Stream != nil:
+		b.link(tid, NodeTarget, "stream://"+strings.TrimPrefix(tid, "trigger:"), EdgeDelivery, 0)
+	case t.AMQP != nil:
+		b.link(tid, NodeTarget, t.AMQP.NodeURL(), EdgeDelivery, 0)
+	case t.Ref != nil:
+		b.link(tid, NodeTarget, t.Ref.String(), EdgeDelivery, 0)
+	case t.URL != nil && *t.URL != "":
+		b.link(tid, NodeTarget, *t.URL, EdgeDelivery, 0)
+	}
+
+	if t.DefaultURL != nil {
+		b.link(tid, NodeTarget, *t.DefaultURL, EdgeDefault, 0)
+	}
+	for _, u := range t.FallbackURLs {
+		b.link(tid, NodeTarget, u, EdgeFallback, 0)
+	}
+	for _, u := range t.ReplicaURLs {
+		b.link(tid, NodeTarget, u, EdgeReplica, 0)
+	}
+
+	d := t.DeliveryOptions
+	if d == nil {
+		return
+	}
+
+	order := 1
+	switch {
+	case d.DeadLetterURL != nil && *d.DeadLetterURL != "":
+		b.link(tid, NodeDeadLetter, *d.DeadLetterURL, EdgeDeadLetter, order)
+		order++
+	case d.DeadLetterTarget != nil:
+		b.link(tid, NodeDeadLetter, d.DeadLetterTarget.URL, EdgeDeadLetter, order)
+		order++
+	}
+	for _, s := range d.DeadLetterSinks {
+		switch {
+		case s.URL != nil && *s.URL != "":
+			b.link(tid, NodeDeadLetter, *s.URL, EdgeDeadLetter, order)
+		case s.File != nil:
+			b.link(tid, NodeDeadLetter, "file://"+*s.File, EdgeDeadLetter, order)
+		}
+		order++
+	}
+}
+
+// addSequence links the trigger to the steps of its sequence, in order.
+func (b *builder) addSequence(tid string, steps []cfgbroker.SequenceStep) {
+	for i, s := range steps {
+		b.link(tid, NodeTarget, destinationLabel(s.URL, s.Ref), EdgeSequence, i+1)
+		if s.OnError != nil {
+			b.link(tid, NodeTarget, destinationLabel(s.OnError.URL, s.OnError.Ref), EdgeSequenceError, i+1)
+		}
+	}
+}
+
+// destinationLabel returns the referenced object, along with its relative
+// URL if any, or the URL of the destination.
+func destinationLabel(u *string, ref *cfgbroker.Reference) string {
+	switch {
+	case ref == nil:
+		return *u
+	case u != nil && *u != "":
+		return ref.String() + *u
+	default:
+		return ref.String()
+	}
+}
+
+// link adds an edge from the trigger to the destination, adding its node
+// when not referenced before.
+func (b *builder) link(tid, kind, dest, edge string, order int) {
+	id := kind + ":" + dest
+	if _, ok := b.nodes[id]; !ok {
+		b.nodes[id] = Node{ID: id, Kind: kind, Label: dest}
+	}
+	b.edges = append(b.edges, Edge{From: tid, To: id, Kind: edge, Order: order})
+}
+
+// SummarizeFilters returns a compact, human readable, representation of
+// the filters, which all need to match.
+func SummarizeFilters(fs []cfgbroker.Filter) string {
+	if len(fs) == 0 {
+		return ""
+	}
+	if len(fs) == 1 {
+		return summarize(&fs[0])
+	}
+	return summarizeList("all", fs)
+}
+
+func summarize(f *cfgbroker.Filter) string {
+	var parts []string
+	if len(f.All) != 0 {
+		parts = append(parts, summarizeList("all", f.All))
+	}
+	if len(f.Any) != 0 {
+		parts = append(parts, summarizeList("any", f.Any))
+	}
+	if f.Not != nil {
+		parts = append(parts, "not("+summarize(f.Not)+")")
+	}
+	parts = appendAttributes(parts, "exact", "=", f.Exact)
+	parts = appendAttributes(parts, "prefix", "^=", f.Prefix)
+	parts = appendAttributes(parts, "suffix", "$=", f.Suffix)
+	if f.CESQL != "" {
+		parts = append(parts, "cesql("+f.CESQL+")")
+	}
+	if f.WASM != nil {
+		parts = append(parts, "wasm("+f.WASM.Module+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func summarizeList(op string, fs []cfgbroker.Filter) string {
+	parts := make([]string, 0, len(fs))
+	for i := range fs {
+		parts = append(parts, summarize(&fs[i]))
+	}
+	return op + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func appendAttributes(parts []string, op, sep string, attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s(%s%s%s)", op, k, sep, attrs[k]))
+	}
+	return parts
+}
+
+// Handler serves the topology of the configuration returned by the
+// function, as JSON or, using the format query parameter, as a Graphviz
+// DOT or Mermaid diagram.
+func Handler(config func() *cfgbroker.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		g := Build(config())
+
+		switch f := r.URL.Query().Get("format"); f {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			_ = g.WriteJSON(w)
+		case "dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			_, _ = w.Write([]byte(g.DOT()))
+		case "mermaid":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(g.Mermaid()))
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q, must be one of json, dot or mermaid", f), http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 TriggerMesh Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package topology
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfgbroker "github.com/triggermesh/brokers/pkg/config/broker"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestBuild(t *testing.T) {
+	c := &cfgbroker.Config{
+		Triggers: map[string]cfgbroker.Trigger{
+			"orders": {
+				Filters: []cfgbroker.Filter{
+					{Exact: map[string]string{"type": "order.created", "source": "shop"}},
+					{Not: &cfgbroker.Filter{Prefix: map[string]string{"subject": "test"}}},
+				},
+				Target: cfgbroker.Target{
+					URL:          strPtr("http://orders"),
+					FallbackURLs: []string{"http://orders-backup"},
+					DeliveryOptions: &cfgbroker.DeliveryOptions{
+						DeadLetterURL: strPtr("http://dls"),
+						DeadLetterSinks: []cfgbroker.DeadLetterSink{
+							{File: strPtr("/var/dls.jsonl")},
+						},
+					},
+				},
+				Owner: "team-a",
+			},
+			"all": {
+				Target: cfgbroker.Target{
+					URL:             strPtr("http://orders"),
+					DeliveryOptions: &cfgbroker.DeliveryOptions{DeadLetterURL: strPtr("http://dls")},
+				},
+			},
+		},
+		Brokers: map[string]cfgbroker.Broker{
+			"billing": {Triggers: map[string]cfgbroker.Trigger{
+				"invoices": {Target: cfgbroker.Target{Kafka: &cfgbroker.KafkaTarget{Brokers: []string{"kafka:9092"}, Topic: "invoices"}}},
+			}},
+		},
+	}
+
+	g := Build(c)
+
+	nodes := make(map[string]Node)
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	assert.Len(t, nodes, 10, "Destinations shared by triggers must be a single node")
+	assert.Equal(t, Node{
+		ID:      "trigger:default/orders",
+		Kind:    NodeTrigger,
+		Label:   "orders",
+		Broker:  DefaultBroker,
+		Filters: "all(exact(source=shop), exact(type=order.created), not(prefix(subject^=test)))",
+		Owner:   "team-a",
+	}, nodes["trigger:default/orders"])
+	assert.Contains(t, nodes, "target:kafka://kafka:9092/invoices")
+	assert.Contains(t, nodes, "deadLetter:file:///var/dls.jsonl")
+
+	assert.Contains(t, g.Edges, Edge{From: "broker:billing", To: "trigger:billing/invoices", Kind: EdgeSubscription})
+	assert.Contains(t, g.Edges, Edge{From: "trigger:default/orders", To: "target:http://orders-backup", Kind: EdgeFallback})
+	assert.Contains(t, g.Edges, Edge{From: "trigger:default/orders", To: "deadLetter:http://dls", Kind: EdgeDeadLetter, Order: 1})
+	assert.Contains(t, g.Edges, Edge{From: "trigger:default/orders", To: "deadLetter:file:///var/dls.jsonl", Kind: EdgeDeadLetter, Order: 2})
+
+	assert.Equal(t, g, Build(c), "The same configuration must render the same graph")
+
+	dot := g.DOT()
+	assert.Contains(t, dot, `"trigger:default/orders" -> "deadLetter:http://dls" [label="deadLetter 1", style=dashed];`)
+	assert.Contains(t, g.Mermaid(), `n0[["broker billing"]]`)
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(func() *cfgbroker.Config {
+		return &cfgbroker.Config{Triggers: map[string]cfgbroker.Trigger{
+			"t1": {Target: cfgbroker.Target{URL: strPtr("http://t1")}},
+		}}
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/topology", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var g Graph
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &g))
+	assert.Len(t, g.Nodes, 3)
+	assert.Len(t, g.Edges, 2)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/topology?format=mermaid", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "flowchart LR\n")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/topology?format=svg", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -51,3 +51,9 @@ func CloudEventWithExtensionOption(key, value string) CloudEventOption {
 		e.SetExtension(key, value)
 	}
 }
+
+func CloudEventWithDataOption(contentType string, data []byte) CloudEventOption {
+	return func(e *cloudevents.Event) {
+		_ = e.SetData(contentType, data)
+	}
+}